@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/operator"
+	"github.com/spf13/cobra"
+)
+
+type operatorOpts struct {
+	namespace  string
+	workDir    string
+	server     string
+	token      string
+	caFile     string
+	certFile   string
+	keyFile    string
+	showOutput bool
+}
+
+func main() {
+	opts := operatorOpts{}
+	cmd := &cobra.Command{
+		Use:   "kismatic-operator",
+		Short: "kismatic-operator installs and upgrades the clusters described by Cluster custom resources",
+		Long: `kismatic-operator watches the Cluster custom resources (clusters.kismatic.apprenda.com),
+and installs or upgrades the clusters described by their plans. The progress is reported
+in the status of the resources.
+
+The operator runs in a Kubernetes cluster with the credentials of its service account, or
+on a bastion host with the credentials given by the flags. It must run from the directory
+of the Kismatic distribution, and have access to the SSH keys referenced by the plans.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return run(opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.namespace, "namespace", "", "namespace of the clusters to manage. All the namespaces are watched when empty")
+	cmd.Flags().StringVar(&opts.workDir, "work-dir", "clusters", "path to the directory where the generated assets and the runs of each cluster are stored")
+	cmd.Flags().StringVar(&opts.server, "server", "", "URL of the Kubernetes API, when running outside of a cluster")
+	cmd.Flags().StringVar(&opts.token, "token", "", "bearer token used to authenticate with the Kubernetes API")
+	cmd.Flags().StringVar(&opts.caFile, "certificate-authority", "", "path to the CA certificate of the Kubernetes API")
+	cmd.Flags().StringVar(&opts.certFile, "client-certificate", "", "path to the client certificate used to authenticate with the Kubernetes API")
+	cmd.Flags().StringVar(&opts.keyFile, "client-key", "", "path to the key of the client certificate")
+	cmd.Flags().BoolVar(&opts.showOutput, "show-output", false, "write the console output of the operations to stdout")
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func run(opts operatorOpts) error {
+	logging.SetOutput(os.Stderr)
+	config := &operator.ClientConfig{
+		Server:   opts.server,
+		Token:    opts.token,
+		CAFile:   opts.caFile,
+		CertFile: opts.certFile,
+		KeyFile:  opts.keyFile,
+	}
+	if opts.server == "" {
+		var err error
+		if config, err = operator.InClusterConfig(); err != nil {
+			return err
+		}
+	}
+	client, err := operator.NewClient(*config)
+	if err != nil {
+		return err
+	}
+	c := &operator.Controller{
+		Client:        client,
+		Namespace:     opts.namespace,
+		WorkDirectory: opts.workDir,
+	}
+	if opts.showOutput {
+		c.Out = os.Stdout
+	}
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		logging.Info("stopping the operator")
+		close(stop)
+	}()
+	logging.Info("starting the operator", "namespace", opts.namespace, "workDir", opts.workDir)
+	return c.Run(stop)
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/apprenda/kismatic/pkg/cli"
 	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/tracing"
 	"github.com/apprenda/kismatic/pkg/util"
 )
 
@@ -26,8 +28,18 @@ func main() {
 		util.PrintColor(os.Stderr, util.Red, "Error initializing command: %v\n", err)
 		os.Exit(1)
 	}
-	if err := cmd.Execute(); err != nil {
-		util.PrintColor(os.Stderr, util.Red, "%v\n", err)
-		os.Exit(1)
+	err = cmd.Execute()
+	if terr := tracing.Shutdown(err); terr != nil {
+		logging.Warn("error exporting traces", "error", terr)
+		util.PrettyPrintWarn(os.Stderr, "Error exporting traces: %v", terr)
+	}
+	if err != nil {
+		code := cli.ExitCode(err)
+		logging.Error("command failed", "error", err, "exitCode", code)
+		if msg := err.Error(); msg != "" {
+			util.PrintColor(os.Stderr, util.Red, "%v\n", msg)
+		}
+		os.Exit(code)
 	}
+	logging.Info("command completed")
 }
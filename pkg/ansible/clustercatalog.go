@@ -22,9 +22,24 @@ type ClusterCatalog struct {
 	EnableModifyHosts         bool   `yaml:"modify_hosts_file"`
 	EnablePackageInstallation bool   `yaml:"allow_package_installation"`
 	DisconnectedInstallation  bool   `yaml:"disconnected_installation"`
+	FIPS                      bool   `yaml:"fips"`
+	PrebuiltNodeImage         bool   `yaml:"prebuilt_node_image"`
 	KuberangPath              string `yaml:"kuberang_path"`
 	LoadBalancedFQDN          string `yaml:"kubernetes_load_balanced_fqdn"`
 
+	// Authentication of the users of the cluster. Basic authentication is
+	// only used when the mode is basic.
+	Authentication struct {
+		Mode       string
+		AdminToken string `yaml:"admin_token"`
+		OIDC       struct {
+			IssuerURL     string `yaml:"issuer_url"`
+			ClientID      string `yaml:"client_id"`
+			UsernameClaim string `yaml:"username_claim"`
+			GroupsClaim   string `yaml:"groups_claim"`
+		}
+	}
+
 	APIServerOptions             map[string]string `yaml:"kubernetes_api_server_option_overrides"`
 	KubeControllerManagerOptions map[string]string `yaml:"kube_controller_manager_option_overrides"`
 	KubeSchedulerOptions         map[string]string `yaml:"kube_scheduler_option_overrides"`
@@ -33,6 +48,58 @@ type ClusterCatalog struct {
 
 	AdditionalFiles []AdditionalFile `yaml:"additional_files"`
 
+	// CompletedPhases are the phases of the installation that are skipped on
+	// each node, keyed by host
+	CompletedPhases map[string][]string `yaml:"completed_phases"`
+
+	AssetDistribution struct {
+		Enabled          bool
+		BandwidthLimit   int  `yaml:"bandwidth_limit"`
+		PeerDistribution bool `yaml:"peer_distribution"`
+		Port             int
+		// Seeds is the node each node copies the additional files from, keyed by host
+		Seeds map[string]string
+	} `yaml:"asset_distribution"`
+
+	NodeClean struct {
+		AllImages           bool `yaml:"all_images"`
+		Volumes             bool
+		ContainerLogMaxSize string `yaml:"container_log_max_size"`
+		JournalMaxSize      string `yaml:"journal_max_size"`
+	} `yaml:"node_clean"`
+
+	EtcdMaintenance struct {
+		// Defrag defragments the data of the etcd members after backing it up
+		Defrag bool
+	} `yaml:"etcd_maintenance"`
+
+	Reset struct {
+		// Components are the components that are reset. All the components
+		// are reset when empty.
+		Components []string
+		Purge      bool
+		BackupDir  string `yaml:"backup_dir"`
+	}
+
+	PlaySerial struct {
+		Default string
+		Plays   map[string]string
+	} `yaml:"play_serial"`
+
+	RemoveNode struct {
+		Host  string
+		Drain bool
+	} `yaml:"remove_node"`
+
+	NodeMaintenance struct {
+		Host  string
+		Drain struct {
+			TimeoutSeconds  int `yaml:"timeout_seconds"`
+			Force           bool
+			DeleteLocalData bool `yaml:"delete_local_data"`
+		}
+	} `yaml:"node_maintenance"`
+
 	ConfigureDockerWithPrivateRegistry bool   `yaml:"configure_docker_with_private_registry"`
 	DockerRegistryCAPath               string `yaml:"docker_certificates_ca_path"`
 	DockerRegistryServer               string `yaml:"docker_registry_full_url"`
@@ -56,6 +123,10 @@ type ClusterCatalog struct {
 
 	NFSVolumes []NFSVolume `yaml:"nfs_volumes"`
 
+	Tenants []Tenant
+
+	ImageCredentialProviders []ImageCredentialProvider `yaml:"image_credential_providers"`
+
 	EnableGluster bool `yaml:"configure_storage"`
 
 	// volume add vars
@@ -76,6 +147,12 @@ type ClusterCatalog struct {
 
 	DiagnosticsDirectory string `yaml:"diagnostics_dir"`
 	DiagnosticsDateTime  string `yaml:"diagnostics_date_time"`
+	// the performance collectors of the diagnostics
+	DiagnosticsPerformance struct {
+		Enabled         bool `yaml:"enabled"`
+		WindowSeconds   int  `yaml:"window_seconds"`
+		IntervalSeconds int  `yaml:"interval_seconds"`
+	} `yaml:"diagnostics_performance"`
 
 	Docker struct {
 		Enabled bool
@@ -93,14 +170,30 @@ type ClusterCatalog struct {
 
 	LocalKubeconfigDirectory string `yaml:"local_kubeconfig_directory"`
 
+	ServiceAccount struct {
+		Enabled       bool
+		User          string
+		AuthorizedKey string   `yaml:"authorized_key"`
+		SudoCommands  []string `yaml:"sudo_commands"`
+	} `yaml:"service_account"`
+
 	CloudProvider string `yaml:"cloud_provider"`
 	CloudConfig   string `yaml:"cloud_config_local"`
 
+	VSphere struct {
+		Enabled      bool
+		StorageClass string `yaml:"storage_class"`
+		Datastore    string
+		DiskFormat   string `yaml:"disk_format"`
+	}
+
 	DNS struct {
 		Enabled  bool
 		Provider string
 		Options  struct {
-			Replicas int
+			Replicas        int
+			UpstreamServers []string `yaml:"upstream_servers"`
+			BlockExternal   bool     `yaml:"block_external"`
 		}
 	}
 
@@ -144,6 +237,11 @@ type ClusterCatalog struct {
 		Enabled bool
 	}
 
+	HeapsterMigration struct {
+		Enabled    bool
+		RemoveData bool `yaml:"remove_data"`
+	} `yaml:"heapster_migration"`
+
 	Dashboard struct {
 		Enabled bool
 		Options struct {
@@ -160,6 +258,22 @@ type ClusterCatalog struct {
 		Enabled bool
 	}
 
+	LocalVolumeProvisioner struct {
+		Enabled        bool
+		StorageClasses []LocalStorageClass      `yaml:"storage_classes"`
+		NodeVolumes    map[string][]LocalVolume `yaml:"node_volumes"`
+	} `yaml:"local_volume_provisioner"`
+
+	Heketi struct {
+		Enabled             bool
+		URL                 string
+		User                string
+		Secret              string
+		ClusterID           string `yaml:"cluster_id"`
+		StorageClass        string `yaml:"storage_class"`
+		DefaultStorageClass bool   `yaml:"default_storage_class"`
+	}
+
 	InsecureNetworkingEtcd bool `yaml:"insecure_networking_etcd"`
 
 	HTTPProxy  string `yaml:"http_proxy"`
@@ -183,6 +297,58 @@ type NFSVolume struct {
 	Path string
 }
 
+type Tenant struct {
+	Namespace    string
+	Labels       map[string]string
+	Quota        map[string]string
+	LimitRange   *TenantLimitRange   `yaml:"limit_range"`
+	RoleBindings []TenantRoleBinding `yaml:"role_bindings"`
+}
+
+type TenantLimitRange struct {
+	Default        map[string]string
+	DefaultRequest map[string]string `yaml:"default_request"`
+	Max            map[string]string
+	Min            map[string]string
+}
+
+type TenantRoleBinding struct {
+	Name            string
+	ClusterRole     string `yaml:"cluster_role"`
+	Users           []string
+	Groups          []string
+	ServiceAccounts []TenantServiceAccount `yaml:"service_accounts"`
+}
+
+type TenantServiceAccount struct {
+	Namespace string
+	Name      string
+}
+
+type ImageCredentialProvider struct {
+	Helper                 string
+	HelperPath             string `yaml:"helper_path"`
+	Registries             []string
+	RefreshIntervalSeconds int `yaml:"refresh_interval_seconds"`
+}
+
+type LocalStorageClass struct {
+	Name          string
+	HostDir       string `yaml:"host_dir"`
+	VolumeMode    string `yaml:"volume_mode"`
+	ReclaimPolicy string `yaml:"reclaim_policy"`
+	Default       bool
+}
+
+// LocalVolume is exposed to the provisioner as the entry Name
+// in the host directory of the StorageClass
+type LocalVolume struct {
+	Name    string
+	Path    string
+	HostDir string `yaml:"host_dir"`
+	Block   bool
+}
+
 type AdditionalFile struct {
 	Source      string
 	Destination string
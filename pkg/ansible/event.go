@@ -26,6 +26,8 @@ type runnerResult struct {
 	Attempts int
 	// Maximum number of retries for a given task
 	MaxRetries int `json:"retries"`
+	// Changed is true when the task made changes to the host
+	Changed bool
 }
 
 type runnerResultEvent struct {
@@ -44,6 +44,11 @@ func (i Inventory) ToINI() []byte {
 			if n.InternalIP != "" {
 				internalIP = n.InternalIP
 			}
+			// nodes without a private key file authenticate with the SSH agent
+			if n.SSHPrivateKey == "" {
+				fmt.Fprintf(w, "%q ansible_host=%q internal_ipv4=%q ansible_port=%d ansible_user=%q\n", n.Host, n.PublicIP, internalIP, n.SSHPort, n.SSHUser)
+				continue
+			}
 			fmt.Fprintf(w, "%q ansible_host=%q internal_ipv4=%q ansible_ssh_private_key_file=%q ansible_port=%d ansible_user=%q\n", n.Host, n.PublicIP, internalIP, n.SSHPrivateKey, n.SSHPort, n.SSHUser)
 		}
 	}
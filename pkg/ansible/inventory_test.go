@@ -70,3 +70,30 @@ func TestInventoryINIGeneration(t *testing.T) {
 	}
 
 }
+
+func TestInventoryINIGenerationWithoutPrivateKey(t *testing.T) {
+	inv := Inventory{
+		Roles: []Role{
+			{
+				Name: "master",
+				Nodes: []Node{
+					{
+						Host:     "master01",
+						PublicIP: "10.0.0.2",
+						SSHPort:  22,
+						SSHUser:  "alice",
+					},
+				},
+			},
+		},
+	}
+
+	ini := string(inv.ToINI())
+
+	expected := `[master]
+"master01" ansible_host="10.0.0.2" internal_ipv4="10.0.0.2" ansible_port=22 ansible_user="alice"
+`
+	if ini != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, ini)
+	}
+}
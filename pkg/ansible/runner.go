@@ -9,9 +9,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/util"
 )
 
 const (
@@ -36,8 +40,15 @@ type Runner interface {
 	// against the specific node.
 	// It returns a read-only channel that must be consumed for the playbook execution to proceed.
 	StartPlaybookOnNode(playbookFile string, inventory Inventory, cc ClusterCatalog, node ...string) (<-chan Event, error)
+	// Stop terminates the execution of the playbook. WaitPlaybook returns an
+	// error once the execution is terminated.
+	Stop() error
 }
 
+// stopGracePeriod is the time given to ansible to stop the workers and exit
+// after it is interrupted, before it is killed
+var stopGracePeriod = 30 * time.Second
+
 type runner struct {
 	// Out is the stdout writer for the Ansible process
 	out io.Writer
@@ -49,10 +60,32 @@ type runner struct {
 	runDir       string
 	waitPlaybook func() error
 	namedPipe    string
+	process      *os.Process
+	// workDir is the working directory of the playbook, which holds the
+	// inventory and the cluster catalog passed to ansible
+	workDir string
+	// stopVaultPassword stops piping the vault password to ansible
+	stopVaultPassword func()
+	options           RunnerOptions
 }
 
-// NewRunner returns a new runner for running Ansible playbooks.
-func NewRunner(out, errOut io.Writer, ansibleDir string, runDir string) (Runner, error) {
+// RunnerOptions are the options of the runner
+type RunnerOptions struct {
+	// ShredCredentials overwrites the copies of the inventory and the cluster
+	// catalog passed to ansible before they are removed
+	ShredCredentials bool
+	// VaultClusterCatalog encrypts the cluster catalog with ansible-vault,
+	// using a random password for each playbook that is piped to ansible.
+	// The catalog recorded in the run directory is encrypted as well.
+	VaultClusterCatalog bool
+	// Forks is the number of hosts on which ansible runs each task at the
+	// same time. The forks of the ansible configuration are used when zero.
+	Forks int
+}
+
+// NewRunner returns a new runner for running Ansible playbooks. The inventory
+// and the cluster catalog are recorded in the run directory.
+func NewRunner(out, errOut io.Writer, ansibleDir string, runDir string, options RunnerOptions) (Runner, error) {
 	// Ansible depends on python 2.7 being installed and on the path as "python".
 	// Validate that it is available
 	if _, err := exec.LookPath("python"); err != nil {
@@ -70,6 +103,7 @@ func NewRunner(out, errOut io.Writer, ansibleDir string, runDir string) (Runner,
 		pythonPath: ppath,
 		ansibleDir: ansibleDir,
 		runDir:     runDir,
+		options:    options,
 	}, nil
 }
 
@@ -82,6 +116,10 @@ func (r *runner) WaitPlaybook() error {
 	execErr := r.waitPlaybook()
 	// Process exited, we can clean up named pipe
 	removeErr := os.Remove(r.namedPipe)
+	if err := r.removeWorkDir(); err != nil {
+		logging.Warn("error removing the working directory of the playbook", "dir", r.workDir, "error", err)
+		fmt.Fprintf(r.errOut, "Failed to clean up the working directory %q of the playbook: %v\n", r.workDir, err)
+	}
 	if removeErr != nil && execErr != nil {
 		return fmt.Errorf("an error occurred running ansible: %v. Removing named pipe at %q failed: %v", execErr, r.namedPipe, removeErr)
 	}
@@ -94,6 +132,21 @@ func (r *runner) WaitPlaybook() error {
 	return nil
 }
 
+// Stop interrupts the ansible process running the playbook, which stops its
+// workers and exits. The process is killed if it is still running after the
+// grace period.
+func (r *runner) Stop() error {
+	if r.process == nil {
+		return fmt.Errorf("stop called, but playbook not started")
+	}
+	if err := r.process.Signal(os.Interrupt); err != nil {
+		return fmt.Errorf("error interrupting ansible: %v", err)
+	}
+	p := r.process
+	time.AfterFunc(stopGracePeriod, func() { p.Kill() })
+	return nil
+}
+
 // RunPlaybook with the given inventory and extra vars
 func (r *runner) StartPlaybook(playbookFile string, inv Inventory, cc ClusterCatalog) (<-chan Event, error) {
 	return r.startPlaybook(playbookFile, inv, cc) // Don't set the --limit arg
@@ -108,7 +161,12 @@ func (r *runner) StartPlaybookOnNode(playbookFile string, inv Inventory, cc Clus
 }
 
 func (r *runner) startPlaybook(playbookFile string, inv Inventory, cc ClusterCatalog, nodes ...string) (<-chan Event, error) {
-	playbook := filepath.Join(r.ansibleDir, "playbooks", playbookFile)
+	// playbooks outside of the ansible directory, such as the ones of hooks,
+	// are given with an absolute path
+	playbook := playbookFile
+	if !filepath.IsAbs(playbook) {
+		playbook = filepath.Join(r.ansibleDir, "playbooks", playbookFile)
+	}
 	if _, err := os.Stat(playbook); os.IsNotExist(err) {
 		return nil, fmt.Errorf("playbook %q does not exist", playbook)
 	}
@@ -117,29 +175,77 @@ func (r *runner) startPlaybook(playbookFile string, inv Inventory, cc ClusterCat
 	if err != nil {
 		return nil, fmt.Errorf("error writing cluster catalog data to yaml: %v", err)
 	}
-	clusterCatalogFile := filepath.Join(r.ansibleDir, "clustercatalog.yaml")
-	if err = ioutil.WriteFile(clusterCatalogFile, yamlBytes, 0644); err != nil {
+	// Each playbook gets its own working directory, so that the operations
+	// running at the same time do not overwrite each other's inventory. The
+	// directory is only accessible to the current user, and is removed once
+	// the playbook exits.
+	r.workDir, err = ioutil.TempDir("", "kismatic-ansible-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating working directory: %v", err)
+	}
+	eventStream, err := r.startPlaybookInWorkDir(playbook, yamlBytes, inv, nodes...)
+	if err != nil {
+		if rerr := r.removeWorkDir(); rerr != nil {
+			logging.Warn("error removing the working directory of the playbook", "dir", r.workDir, "error", rerr)
+		}
+		return nil, err
+	}
+	return eventStream, nil
+}
+
+func (r *runner) startPlaybookInWorkDir(playbook string, clusterCatalog []byte, inv Inventory, nodes ...string) (<-chan Event, error) {
+	var vaultPassword []byte
+	if r.options.VaultClusterCatalog {
+		var err error
+		if vaultPassword, err = VaultPassword(); err != nil {
+			return nil, err
+		}
+		if clusterCatalog, err = EncryptVault(clusterCatalog, vaultPassword); err != nil {
+			return nil, fmt.Errorf("error encrypting cluster catalog: %v", err)
+		}
+	}
+	// The cluster catalog has the passwords of the cluster, and the inventory
+	// the SSH keys of the nodes
+	clusterCatalogFile := filepath.Join(r.workDir, "clustercatalog.yaml")
+	if err := ioutil.WriteFile(clusterCatalogFile, clusterCatalog, 0600); err != nil {
 		return nil, fmt.Errorf("error writing cluster catalog file to %q: %v", clusterCatalogFile, err)
 	}
+	logging.Debug("rendered cluster catalog", "file", clusterCatalogFile, "size", len(clusterCatalog))
 
-	inventoryFile := filepath.Join(r.ansibleDir, "inventory.ini")
-	if err := ioutil.WriteFile(inventoryFile, inv.ToINI(), 0644); err != nil {
+	inventory := inv.ToINI()
+	inventoryFile := filepath.Join(r.workDir, "inventory.ini")
+	if err := ioutil.WriteFile(inventoryFile, inventory, 0600); err != nil {
 		return nil, fmt.Errorf("error writing inventory file to %q: %v", inventoryFile, err)
 	}
+	logging.Debug("rendered inventory", "file", inventoryFile)
 
-	if err := copyFileContents(clusterCatalogFile, filepath.Join(r.runDir, "clustercatalog.yaml")); err != nil {
+	if err := ioutil.WriteFile(filepath.Join(r.runDir, "clustercatalog.yaml"), clusterCatalog, 0600); err != nil {
 		return nil, fmt.Errorf("error copying clustercatalog.yaml to %q: %v", r.runDir, err)
 	}
-	if err := copyFileContents(inventoryFile, filepath.Join(r.runDir, "inventory.ini")); err != nil {
+	if err := ioutil.WriteFile(filepath.Join(r.runDir, "inventory.ini"), inventory, 0600); err != nil {
 		return nil, fmt.Errorf("error copying inventory.ini to %q: %v", r.runDir, err)
 	}
 
 	cmd := exec.Command(filepath.Join(r.ansibleDir, "bin", "ansible-playbook"), "-i", inventoryFile, "-s", playbook, "--extra-vars", "@"+clusterCatalogFile)
+	if vaultPassword != nil {
+		// ansible reads the password from a named pipe, so that it is never
+		// written to the disk
+		passwordPipe := filepath.Join(r.workDir, "vault-password")
+		if err := syscall.Mkfifo(passwordPipe, 0600); err != nil {
+			return nil, fmt.Errorf("error creating named pipe %q: %v", passwordPipe, err)
+		}
+		r.stopVaultPassword = pipeVaultPassword(passwordPipe, vaultPassword)
+		cmd.Args = append(cmd.Args, "--vault-password-file", passwordPipe)
+	}
 	cmd.Stdout = r.out
 	cmd.Stderr = r.errOut
 
 	log.SetOutput(r.out)
 
+	if r.options.Forks > 0 {
+		cmd.Args = append(cmd.Args, "--forks", strconv.Itoa(r.options.Forks))
+	}
+
 	limitArg := strings.Join(nodes, ",")
 	if limitArg != "" {
 		cmd.Args = append(cmd.Args, "--limit", limitArg)
@@ -150,7 +256,7 @@ func (r *runner) startPlaybook(playbookFile string, inv Inventory, cc ClusterCat
 	cmd.Args = append(cmd.Args, "-vvvv")
 
 	// Create named pipe
-	np, err := createTempNamedPipe()
+	np, err := createTempNamedPipe(r.workDir)
 	if err != nil {
 		return nil, err
 	}
@@ -177,6 +283,8 @@ func (r *runner) startPlaybook(playbookFile string, inv Inventory, cc ClusterCat
 		return nil, fmt.Errorf("error running playbook: %v", err)
 	}
 	r.waitPlaybook = cmd.Wait
+	r.process = cmd.Process
+	logging.Info("started ansible-playbook", "playbook", filepath.Base(playbook), "args", cmd.Args, "pid", cmd.Process.Pid)
 
 	// Create the event stream out of the named pipe
 	eventStreamFile, err := os.OpenFile(r.namedPipe, os.O_RDWR, os.ModeNamedPipe)
@@ -187,11 +295,31 @@ func (r *runner) startPlaybook(playbookFile string, inv Inventory, cc ClusterCat
 	return eventStream, nil
 }
 
+// removeWorkDir removes the working directory of the playbook, after
+// shredding the files that hold credentials when requested
+func (r *runner) removeWorkDir() error {
+	if r.workDir == "" {
+		return nil
+	}
+	if r.stopVaultPassword != nil {
+		r.stopVaultPassword()
+		r.stopVaultPassword = nil
+	}
+	if r.options.ShredCredentials {
+		for _, f := range []string{"clustercatalog.yaml", "inventory.ini"} {
+			if err := util.ShredFile(filepath.Join(r.workDir, f)); err != nil {
+				return err
+			}
+		}
+	}
+	return os.RemoveAll(r.workDir)
+}
+
 // create a named pipe for getting json events out of ansible.
 // add random int to file name to avoid collision.
-func createTempNamedPipe() (string, error) {
+func createTempNamedPipe(dir string) (string, error) {
 	start := time.Now()
-	np := filepath.Join(os.TempDir(), fmt.Sprintf("ansible-pipe-%d-%s", rand.Int(), start.Format("2006-01-02-15-04-05.99999")))
+	np := filepath.Join(dir, fmt.Sprintf("ansible-pipe-%d-%s", rand.Int(), start.Format("2006-01-02-15-04-05.99999")))
 	if err := syscall.Mkfifo(np, 0644); err != nil {
 		return "", fmt.Errorf("error creating named pipe %q: %v", np, err)
 	}
@@ -207,20 +335,3 @@ func getPythonPath() (string, error) {
 	lib64 := filepath.Join(wd, "ansible", "lib64", "python2.7", "site-packages")
 	return fmt.Sprintf("%s:%s", lib, lib64), nil
 }
-
-func copyFileContents(src, dst string) (err error) {
-	in, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer in.Close()
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-	if _, err = io.Copy(out, in); err != nil {
-		return err
-	}
-	return out.Sync()
-}
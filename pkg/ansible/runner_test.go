@@ -2,11 +2,13 @@ package ansible
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestWaitPlaybook(t *testing.T) {
-	r, err := NewRunner(ioutil.Discard, ioutil.Discard, "", "/tmp")
+	r, err := NewRunner(ioutil.Discard, ioutil.Discard, "", "/tmp", RunnerOptions{})
 	if err != nil {
 		t.Fatalf("Error creating runner: %v", err)
 	}
@@ -15,3 +17,34 @@ func TestWaitPlaybook(t *testing.T) {
 		t.Error("Did not get the expected error when calling WaitPlaybook")
 	}
 }
+
+func TestStopPlaybookNotStarted(t *testing.T) {
+	r, err := NewRunner(ioutil.Discard, ioutil.Discard, "", "/tmp", RunnerOptions{})
+	if err != nil {
+		t.Fatalf("Error creating runner: %v", err)
+	}
+	if err := r.Stop(); err == nil {
+		t.Error("Did not get an error when calling Stop before starting the playbook")
+	}
+}
+
+func TestRemoveWorkDir(t *testing.T) {
+	for _, shred := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "kismatic-ansible-test")
+		if err != nil {
+			t.Fatalf("Error creating temp dir: %v", err)
+		}
+		for _, f := range []string{"clustercatalog.yaml", "inventory.ini"} {
+			if err := ioutil.WriteFile(filepath.Join(dir, f), []byte("admin_password: secret"), 0600); err != nil {
+				t.Fatalf("Error writing file: %v", err)
+			}
+		}
+		r := &runner{workDir: dir, options: RunnerOptions{ShredCredentials: shred}}
+		if err := r.removeWorkDir(); err != nil {
+			t.Errorf("shred %v: unexpected error: %v", shred, err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("shred %v: expected the working directory to be removed, got %v", shred, err)
+		}
+	}
+}
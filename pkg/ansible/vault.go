@@ -0,0 +1,154 @@
+package ansible
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// The format of the files encrypted by ansible-vault, version 1.1
+const (
+	vaultHeader     = "$ANSIBLE_VAULT;1.1;AES256"
+	vaultSaltSize   = 32
+	vaultKeySize    = 32
+	vaultIterations = 10000
+	vaultLineLength = 80
+)
+
+// VaultPassword returns a random password for encrypting a file with
+// ansible-vault
+func VaultPassword() ([]byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("error generating vault password: %v", err)
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// EncryptVault encrypts the data in the format of ansible-vault, so that
+// ansible decrypts it when it is given the password
+func EncryptVault(data []byte, password []byte) ([]byte, error) {
+	salt := make([]byte, vaultSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating vault salt: %v", err)
+	}
+	cipherKey, hmacKey, iv := vaultKeys(password, salt)
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating vault cipher: %v", err)
+	}
+	// the data is padded to the block size as in PKCS#7
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	padded := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, padded)
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+
+	encoded := strings.Join([]string{hex.EncodeToString(salt), hex.EncodeToString(mac.Sum(nil)), hex.EncodeToString(ciphertext)}, "\n")
+	vaultText := hex.EncodeToString([]byte(encoded))
+	out := &bytes.Buffer{}
+	fmt.Fprintln(out, vaultHeader)
+	for i := 0; i < len(vaultText); i += vaultLineLength {
+		end := i + vaultLineLength
+		if end > len(vaultText) {
+			end = len(vaultText)
+		}
+		fmt.Fprintln(out, vaultText[i:end])
+	}
+	return out.Bytes(), nil
+}
+
+// DecryptVault decrypts data encrypted by ansible-vault with the password
+func DecryptVault(vaulted []byte, password []byte) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(string(vaulted)), "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[0]) != vaultHeader {
+		return nil, fmt.Errorf("the data is not encrypted with ansible-vault %s", vaultHeader)
+	}
+	encoded, err := hex.DecodeString(strings.Join(lines[1:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding vault data: %v", err)
+	}
+	parts := strings.Split(string(encoded), "\n")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid vault data")
+	}
+	var decoded [3][]byte
+	for i, p := range parts {
+		if decoded[i], err = hex.DecodeString(p); err != nil {
+			return nil, fmt.Errorf("error decoding vault data: %v", err)
+		}
+	}
+	salt, expectedMAC, ciphertext := decoded[0], decoded[1], decoded[2]
+	cipherKey, hmacKey, iv := vaultKeys(password, salt)
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil), expectedMAC) {
+		return nil, fmt.Errorf("the vault password is incorrect, or the data was modified")
+	}
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating vault cipher: %v", err)
+	}
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(padded, ciphertext)
+	if len(padded) == 0 {
+		return nil, fmt.Errorf("invalid vault data")
+	}
+	padding := int(padded[len(padded)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(padded) {
+		return nil, fmt.Errorf("invalid vault data padding")
+	}
+	return padded[:len(padded)-padding], nil
+}
+
+// vaultKeys derives the key of the cipher, the key of the HMAC and the
+// initial counter from the password
+func vaultKeys(password, salt []byte) (cipherKey, hmacKey, iv []byte) {
+	key := pbkdf2.Key(password, salt, vaultIterations, 2*vaultKeySize+aes.BlockSize, sha256.New)
+	return key[:vaultKeySize], key[vaultKeySize : 2*vaultKeySize], key[2*vaultKeySize:]
+}
+
+// pipeVaultPassword writes the password to the named pipe once it is opened
+// by ansible. The returned function waits until the password is written, and
+// unblocks the writer when ansible did not read it.
+func pipeVaultPassword(pipe string, password []byte) func() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f, err := os.OpenFile(pipe, os.O_WRONLY, os.ModeNamedPipe)
+		if err != nil {
+			logging.Warn("error opening the vault password pipe", "pipe", pipe, "error", err)
+			return
+		}
+		defer f.Close()
+		if _, err := f.Write(password); err != nil {
+			logging.Warn("error writing the vault password", "pipe", pipe, "error", err)
+		}
+	}()
+	return func() {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		f, err := os.OpenFile(pipe, os.O_RDONLY|syscall.O_NONBLOCK, os.ModeNamedPipe)
+		if err != nil {
+			logging.Warn("error opening the vault password pipe", "pipe", pipe, "error", err)
+			return
+		}
+		<-done
+		f.Close()
+	}
+}
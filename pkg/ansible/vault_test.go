@@ -0,0 +1,69 @@
+package ansible
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestEncryptVault(t *testing.T) {
+	password, err := VaultPassword()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, data := range []string{"", "kubernetes_admin_password: secret\n", strings.Repeat("a", 32)} {
+		vaulted, err := EncryptVault([]byte(data), password)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(vaulted)), "\n")
+		if lines[0] != "$ANSIBLE_VAULT;1.1;AES256" {
+			t.Errorf("expected the ansible-vault header, got %q", lines[0])
+		}
+		for _, l := range lines[1:] {
+			if len(l) > 80 {
+				t.Errorf("expected lines of at most 80 characters, got %d", len(l))
+			}
+		}
+		if strings.Contains(string(vaulted), "secret") {
+			t.Errorf("expected the data to be encrypted")
+		}
+		decrypted, err := DecryptVault(vaulted, password)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(decrypted) != data {
+			t.Errorf("expected %q, got %q", data, decrypted)
+		}
+		if _, err := DecryptVault(vaulted, []byte("wrong")); err == nil {
+			t.Errorf("expected an error with the wrong password")
+		}
+	}
+}
+
+func TestPipeVaultPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kismatic-vault-test")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	pipe := filepath.Join(dir, "vault-password")
+	if err := syscall.Mkfifo(pipe, 0600); err != nil {
+		t.Fatalf("Error creating named pipe: %v", err)
+	}
+	stop := pipeVaultPassword(pipe, []byte("password"))
+	b, err := ioutil.ReadFile(pipe)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(b, []byte("password")) {
+		t.Errorf("expected the password, got %q", b)
+	}
+	stop()
+
+	// the writer is unblocked when the password is never read
+	stop = pipeVaultPassword(pipe, []byte("password"))
+	stop()
+}
@@ -20,6 +20,11 @@ type addNodeOpts struct {
 	OutputFormat             string
 	Verbose                  bool
 	SkipPreFlight            bool
+	Force                    bool
+	Timings                  bool
+	ShowTasks                string
+	HideTasks                string
+	RestartComponents        []string
 }
 
 var validRoles = []string{"worker", "ingress", "storage"}
@@ -68,9 +73,13 @@ func NewCmdAddNode(out io.Writer, installOpts *installOpts) *cobra.Command {
 	cmd.Flags().StringSliceVarP(&opts.NodeLabels, "labels", "l", []string{}, "key=value pairs separated by ','")
 	cmd.Flags().StringVar(&opts.GeneratedAssetsDirectory, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	cmd.Flags().BoolVar(&opts.RestartServices, "restart-services", false, "force restart clusters services (Use with care)")
+	addRestartComponentsFlag(cmd.Flags(), &opts.RestartComponents)
 	cmd.Flags().BoolVar(&opts.Verbose, "verbose", false, "enable verbose logging from the installation")
-	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
 	cmd.Flags().BoolVar(&opts.SkipPreFlight, "skip-preflight", false, "skip pre-flight checks, useful when rerunning kismatic")
+	addForceVersionFlag(cmd.Flags(), &opts.Force)
+	addTimingsFlag(cmd.Flags(), &opts.Timings)
+	addTaskFilterFlags(cmd.Flags(), &opts.ShowTasks, &opts.HideTasks)
 	return cmd
 }
 
@@ -80,11 +89,16 @@ func doAddNode(out io.Writer, planFile string, opts *addNodeOpts, newNode instal
 		return planFileNotFoundErr{filename: planFile}
 	}
 	execOpts := install.ExecutorOptions{
-		GeneratedAssetsDirectory: opts.GeneratedAssetsDirectory,
-		OutputFormat:             opts.OutputFormat,
-		Verbose:                  opts.Verbose,
-	}
-	executor, err := install.NewExecutor(out, os.Stderr, execOpts)
+		GeneratedAssetsDirectory:   opts.GeneratedAssetsDirectory,
+		OutputFormat:               opts.OutputFormat,
+		Verbose:                    opts.Verbose,
+		IgnoreVersionCompatibility: opts.Force,
+		Timings:                    opts.Timings,
+		ShowTasks:                  opts.ShowTasks,
+		HideTasks:                  opts.HideTasks,
+		RestartComponents:          opts.RestartComponents,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(execOpts))
 	if err != nil {
 		return err
 	}
@@ -94,13 +108,13 @@ func doAddNode(out io.Writer, planFile string, opts *addNodeOpts, newNode instal
 	}
 	if _, errs := install.ValidateNode(&newNode); errs != nil {
 		util.PrintValidationErrors(out, errs)
-		return errors.New("information provided about the new node is invalid")
+		return withExitCode(ExitCodeValidationFailed, errors.New("information provided about the new node is invalid"))
 	}
 	// add new node to the plan just for validation
 	validatePlan := install.AddNodeToPlan(*plan, newNode, opts.Roles)
 	if _, errs := install.ValidatePlan(&validatePlan); errs != nil {
 		util.PrintValidationErrors(out, errs)
-		return errors.New("the plan file failed validation")
+		return withExitCode(ExitCodeValidationFailed, errors.New("the plan file failed validation"))
 	}
 	nodeSSHCon := &install.SSHConnection{
 		SSHConfig: &plan.Cluster.SSH,
@@ -108,20 +122,24 @@ func doAddNode(out io.Writer, planFile string, opts *addNodeOpts, newNode instal
 	}
 	if _, errs := install.ValidateSSHConnection(nodeSSHCon, "New node"); errs != nil {
 		util.PrintValidationErrors(out, errs)
-		return errors.New("could not establish SSH connection to the new node")
+		return withExitCode(ExitCodeValidationFailed, errors.New("could not establish SSH connection to the new node"))
 	}
 	if err = ensureNodeIsNew(*plan, newNode); err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	if err = checkVersionCompatibility(out, plan, install.OperationAddNode, opts.Force); err != nil {
 		return err
 	}
 	if !opts.SkipPreFlight {
 		util.PrintHeader(out, "Running Pre-Flight Checks On New Node", '=')
 		if err = executor.RunNewNodePreFlightCheck(*plan, newNode); err != nil {
-			return err
+			return withExitCode(ExitCodePreflightFailed, err)
 		}
 	}
-	updatedPlan, err := executor.AddNode(plan, newNode, opts.Roles, opts.RestartServices)
+	updatedPlan, summary, err := executor.AddNode(plan, newNode, opts.Roles, opts.RestartServices)
 	if err != nil {
-		return err
+		install.PrintRunSummary(out, summary)
+		return withExitCode(playbookExitCode(err), err)
 	}
 	if err := planner.Write(updatedPlan); err != nil {
 		return fmt.Errorf("error updating plan file to include the new node: %v", err)
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type adoptOpts struct {
+	master             string
+	clusterName        string
+	generatedAssetsDir string
+	ssh                install.SSHConfig
+}
+
+// NewCmdAdopt creates a new install adopt command
+func NewCmdAdopt(out io.Writer, installOpts *installOpts) *cobra.Command {
+	opts := &adoptOpts{}
+	cmd := &cobra.Command{
+		Use:   "adopt",
+		Short: "generate a plan file for an existing cluster that was built with kubeadm",
+		Long: `Generate a plan file for an existing cluster that was built with kubeadm.
+
+The configuration, the nodes and the add-ons of the cluster are read from one of
+its masters over SSH, and written to the plan file. The certificate authorities
+and the service account signing key of the cluster are imported into the generated
+assets directory, so that the certificates generated by KET are trusted by the
+existing nodes.
+
+Review the plan file, and the warnings about the parts of the cluster that KET
+manages differently, before validating the plan and upgrading the cluster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			if opts.master == "" {
+				return errors.New("the --master flag is required")
+			}
+			client, err := ssh.NewClient(opts.master, opts.ssh.Port, opts.ssh.User, opts.ssh.Key)
+			if err != nil {
+				return fmt.Errorf("error creating SSH client for %s: %v", opts.master, err)
+			}
+			planner := &install.FilePlanner{File: installOpts.planFilename}
+			return doAdopt(out, planner, client, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.master, "master", "", "IP address of a master node of the kubeadm cluster")
+	cmd.Flags().StringVar(&opts.clusterName, "cluster-name", "kubernetes", "name of the cluster in the generated plan file")
+	cmd.Flags().StringVar(&opts.ssh.User, "ssh-user", "kismaticuser", "user used to connect to the nodes over SSH")
+	cmd.Flags().StringVar(&opts.ssh.Key, "ssh-key", "kismaticuser.key", "path to the SSH private key of the user")
+	cmd.Flags().IntVar(&opts.ssh.Port, "ssh-port", 22, "port used to connect to the nodes over SSH")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	return cmd
+}
+
+func doAdopt(out io.Writer, planner install.Planner, client ssh.Client, opts *adoptOpts) error {
+	if planner.PlanExists() {
+		return fmt.Errorf("the plan file already exists")
+	}
+	util.PrintHeader(out, "Inspecting the kubeadm Cluster", '=')
+	cluster, err := install.InspectKubeadmCluster(client)
+	if err != nil {
+		util.PrettyPrintErr(out, "Reading the kubeadm cluster from %s", opts.master)
+		return err
+	}
+	var masters int
+	for _, n := range cluster.Nodes {
+		if n.Master {
+			masters++
+		}
+	}
+	util.PrettyPrintOk(out, "Found Kubernetes %s with %d masters and %d workers", cluster.Version, masters, len(cluster.Nodes)-masters)
+
+	plan, warnings := install.AdoptedPlan(*cluster, opts.clusterName, opts.ssh)
+	pki := &install.LocalPKI{GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"), Log: out}
+	if err := pki.ImportKubeadmPKI(plan, *cluster); err != nil {
+		util.PrettyPrintErr(out, "Importing the certificate authorities")
+		return err
+	}
+	util.PrettyPrintOk(out, "Imported the certificate authorities into %q", pki.GeneratedCertsDirectory)
+	if err := planner.Write(plan); err != nil {
+		util.PrettyPrintErr(out, "Writing the plan file")
+		return fmt.Errorf("error writing plan file: %v", err)
+	}
+	util.PrettyPrintOk(out, "Wrote the plan file")
+
+	for _, w := range warnings {
+		util.PrettyPrintWarn(out, "%s", w)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Review the plan file, and validate it with \"kismatic install validate\".")
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/apprenda/kismatic/pkg/util"
@@ -21,6 +23,16 @@ type applyCmd struct {
 	skipPreFlight      bool
 	restartServices    bool
 	limit              []string
+	force              bool
+	maxParallelChecks  int
+	// autoApprove regenerates the certificates that do not match the plan
+	autoApprove bool
+	// resumeFrom is the run directory of the failed installation to resume
+	resumeFrom string
+	// dnsValidation is run after the smoke test when set
+	dnsValidation *install.DNSValidation
+	// resilienceTest is run after the smoke test when set
+	resilienceTest *install.ResilienceTest
 }
 
 type applyOpts struct {
@@ -30,6 +42,21 @@ type applyOpts struct {
 	outputFormat       string
 	skipPreFlight      bool
 	limit              []string
+	exclude            []string
+	force              bool
+	timings            bool
+	showTasks          string
+	hideTasks          string
+	smokeTestEngine    string
+	skipDNSValidation  bool
+	resilienceTest     bool
+	rebootWorker       string
+	maxParallelChecks  int
+	forceFull          bool
+	rollbackOnFailure  bool
+	autoApprove        bool
+	resumeFrom         string
+	restartComponents  []string
 }
 
 // NewCmdApply creates a cluter using the plan file
@@ -42,13 +69,27 @@ func NewCmdApply(out io.Writer, installOpts *installOpts) *cobra.Command {
 			if len(args) != 0 {
 				return fmt.Errorf("Unexpected args: %v", args)
 			}
+			if applyOpts.rebootWorker != "" && !applyOpts.resilienceTest {
+				return fmt.Errorf("--resilience-reboot-worker requires --resilience-test")
+			}
+			if applyOpts.resumeFrom != "" && applyOpts.forceFull {
+				return fmt.Errorf("--resume-from cannot be used with --force-full")
+			}
 			planner := &install.FilePlanner{File: installOpts.planFilename}
 			executorOpts := install.ExecutorOptions{
-				GeneratedAssetsDirectory: applyOpts.generatedAssetsDir,
-				OutputFormat:             applyOpts.outputFormat,
-				Verbose:                  applyOpts.verbose,
+				GeneratedAssetsDirectory:   applyOpts.generatedAssetsDir,
+				OutputFormat:               applyOpts.outputFormat,
+				Verbose:                    applyOpts.verbose,
+				IgnoreVersionCompatibility: applyOpts.force,
+				Timings:                    applyOpts.timings,
+				ShowTasks:                  applyOpts.showTasks,
+				HideTasks:                  applyOpts.hideTasks,
+				SmokeTestEngine:            applyOpts.smokeTestEngine,
+				ForceFullInstall:           applyOpts.forceFull,
+				RollbackOnFailure:          applyOpts.rollbackOnFailure,
+				RestartComponents:          applyOpts.restartComponents,
 			}
-			executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+			executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(executorOpts))
 			if err != nil {
 				return err
 			}
@@ -63,24 +104,77 @@ func NewCmdApply(out io.Writer, installOpts *installOpts) *cobra.Command {
 				outputFormat:       applyOpts.outputFormat,
 				skipPreFlight:      applyOpts.skipPreFlight,
 				restartServices:    applyOpts.restartServices,
-				limit:              applyOpts.limit,
+				limit:              limitWithExclusions(applyOpts.limit, applyOpts.exclude),
+				force:              applyOpts.force,
+				maxParallelChecks:  applyOpts.maxParallelChecks,
+				autoApprove:        applyOpts.autoApprove,
+				resumeFrom:         applyOpts.resumeFrom,
+			}
+			if !applyOpts.skipDNSValidation {
+				applyCmd.dnsValidation = &install.DNSValidation{
+					Out:          out,
+					MaxLatency:   time.Second,
+					Timeout:      5 * time.Minute,
+					PollInterval: 2 * time.Second,
+				}
+			}
+			if applyOpts.resilienceTest {
+				applyCmd.resilienceTest = &install.ResilienceTest{
+					Out:          out,
+					RebootWorker: applyOpts.rebootWorker,
+					Timeout:      10 * time.Minute,
+					PollInterval: 5 * time.Second,
+				}
 			}
 			return applyCmd.run()
 		},
 	}
 
 	// Flags
-	cmd.Flags().StringSliceVar(&applyOpts.limit, "limit", []string{}, "comma-separated list of hostnames to limit the execution to a subset of nodes")
+	addLimitFlags(cmd.Flags(), &applyOpts.limit, &applyOpts.exclude)
 	cmd.Flags().StringVar(&applyOpts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	cmd.Flags().BoolVar(&applyOpts.restartServices, "restart-services", false, "force restart cluster services (Use with care)")
+	addRestartComponentsFlag(cmd.Flags(), &applyOpts.restartComponents)
 	cmd.Flags().BoolVar(&applyOpts.verbose, "verbose", false, "enable verbose logging from the installation")
-	cmd.Flags().StringVarP(&applyOpts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().StringVarP(&applyOpts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
 	cmd.Flags().BoolVar(&applyOpts.skipPreFlight, "skip-preflight", false, "skip pre-flight checks, useful when rerunning kismatic")
+	addPreflightParallelismFlag(cmd.Flags(), &applyOpts.maxParallelChecks)
+	cmd.Flags().BoolVar(&applyOpts.forceFull, "force-full", false, "run all the phases of the installation, instead of skipping the phases that completed on the nodes during a previous failed installation")
+	cmd.Flags().StringVar(&applyOpts.resumeFrom, "resume-from", "", "run directory of a failed installation (e.g. runs/apply/2018-06-01-10-00-00) to resume from the point where it failed, or \"last\" to resume the most recent installation")
+	cmd.Flags().BoolVar(&applyOpts.rollbackOnFailure, "rollback-on-failure", false, "reset the nodes that were modified by the installation when it fails, instead of leaving them half configured. Nodes that were installed before the installation are not reset (Use with care)")
+	cmd.Flags().BoolVar(&applyOpts.autoApprove, "auto-approve", false, "regenerate the certificates that do not match the plan file, such as after the IP address of a node, the load balanced FQDN or the service CIDR changed, and redistribute them to the nodes")
+	addForceVersionFlag(cmd.Flags(), &applyOpts.force)
+	addTimingsFlag(cmd.Flags(), &applyOpts.timings)
+	addTaskFilterFlags(cmd.Flags(), &applyOpts.showTasks, &applyOpts.hideTasks)
+	addSmokeTestEngineFlag(cmd.Flags(), &applyOpts.smokeTestEngine)
+	cmd.Flags().BoolVar(&applyOpts.skipDNSValidation, "skip-dns-validation", false, "skip the validation of service, pod and external name resolution from a pod after the installation")
+	cmd.Flags().BoolVar(&applyOpts.resilienceTest, "resilience-test", false, "after the installation, kill one replica of each control plane component and verify that the cluster recovers (Use with care)")
+	cmd.Flags().StringVar(&applyOpts.rebootWorker, "resilience-reboot-worker", "", "hostname of a worker node that is rebooted during the resilience test (Use with care)")
 
 	return cmd
 }
 
 func (c *applyCmd) run() error {
+	// Certificates that no longer match the plan are handled before the
+	// validation, which rejects them
+	restartServices := c.restartServices
+	if c.planner.PlanExists() {
+		plan, err := c.planner.Read()
+		if err != nil {
+			return fmt.Errorf("error reading plan file: %v", err)
+		}
+		// the errors of an invalid plan, and the assets of another cluster,
+		// are reported by the validation
+		ok, _ := install.ValidatePlan(plan)
+		if ok && install.VerifyAssetsCluster(plan, c.generatedAssetsDir) == nil {
+			regenerated, err := c.regenerateStaleCertificates(plan)
+			if err != nil {
+				return err
+			}
+			restartServices = restartServices || regenerated
+		}
+	}
+
 	// Validate and run pre-flight
 	opts := &validateOpts{
 		planFile:           c.planFile,
@@ -89,16 +183,22 @@ func (c *applyCmd) run() error {
 		skipPreFlight:      c.skipPreFlight,
 		generatedAssetsDir: c.generatedAssetsDir,
 		limit:              c.limit,
+		maxParallelChecks:  c.maxParallelChecks,
 	}
 	err := doValidate(c.out, c.planner, opts)
 	if err != nil {
-		return fmt.Errorf("error validating plan: %v", err)
+		return withExitCode(ExitCode(err), fmt.Errorf("error validating plan: %v", err))
 	}
 	plan, err := c.planner.Read()
 	if err != nil {
 		return fmt.Errorf("error reading plan file: %v", err)
 	}
 
+	// Refuse to operate on clusters running unsupported versions
+	if err := checkVersionCompatibility(c.out, plan, install.OperationApply, c.force); err != nil {
+		return err
+	}
+
 	// Generate certificates
 	if err := c.executor.GenerateCertificates(plan, false); err != nil {
 		return fmt.Errorf("error installing: %v", err)
@@ -112,19 +212,62 @@ func (c *applyCmd) run() error {
 	}
 	util.PrettyPrintOk(c.out, "Generated kubeconfig file in the %q directory", c.generatedAssetsDir)
 
+	// Write the cluster information for external tooling
+	clusterInfoFile, err := install.WriteClusterInfo(plan, c.generatedAssetsDir)
+	if err != nil {
+		return fmt.Errorf("error writing cluster info file: %v", err)
+	}
+	util.PrettyPrintOk(c.out, "Generated cluster info file %q", clusterInfoFile)
+
+	// Register the masters with the load balancer of the Kubernetes API
+	if az := plan.Cluster.CloudProvider.Azure; az != nil && az.MasterBackendPool != nil {
+		util.PrintHeader(c.out, "Registering Masters With The Azure Load Balancer", '=')
+		if err := install.RegisterAzureMasters(c.out, plan); err != nil {
+			return fmt.Errorf("error registering masters with the load balancer: %v", err)
+		}
+	}
+
 	// Perform the installation
-	if err := c.executor.Install(plan, c.restartServices, c.limit...); err != nil {
-		return fmt.Errorf("error installing: %v", err)
+	if summary, err := c.install(plan, restartServices); err != nil {
+		install.PrintRunSummary(c.out, summary)
+		return withExitCode(playbookExitCode(err), fmt.Errorf("error installing: %v", err))
 	}
 
 	// Run smoketest
 	// Don't run
 	if plan.NetworkConfigured() {
-		if err := c.executor.RunSmokeTest(plan); err != nil {
-			return fmt.Errorf("error running smoke test: %v", err)
+		if _, err := c.executor.RunSmokeTest(plan); err != nil {
+			return withExitCode(playbookExitCode(err), fmt.Errorf("error running smoke test: %v", err))
 		}
 	}
 
+	// Validate the cluster DNS, which is the most common source of problems
+	// after the installation
+	if c.dnsValidation != nil && plan.NetworkConfigured() && !plan.AddOns.DNS.Disable {
+		util.PrintHeader(c.out, "Validating Cluster DNS", '=')
+		if _, err := c.dnsValidation.Run(plan); err != nil {
+			return fmt.Errorf("error validating cluster DNS: %v", err)
+		}
+	}
+
+	// Run the resilience test, only when explicitly requested
+	if c.resilienceTest != nil {
+		util.PrintHeader(c.out, "Running Resilience Test", '=')
+		if err := c.resilienceTest.Run(plan); err != nil {
+			return fmt.Errorf("error running resilience test: %v", err)
+		}
+	}
+
+	// Publish the cluster information once the cluster is ready to be used
+	if plan.Cluster.ClusterInfo.PublishURL != "" {
+		util.PrettyPrint(c.out, "Publishing cluster info to %q", plan.Cluster.ClusterInfo.PublishURL)
+		if err := install.PublishClusterInfo(plan, clusterInfoFile); err != nil {
+			util.PrintError(c.out)
+			return err
+		}
+		util.PrintOkln(c.out)
+	}
+
 	util.PrintColor(c.out, util.Green, "\nThe cluster was installed successfully!\n")
 	fmt.Fprintln(c.out)
 
@@ -138,3 +281,43 @@ func (c *applyCmd) run() error {
 
 	return nil
 }
+
+// regenerateStaleCertificates reports the certificates whose subject
+// alternative names no longer match the plan. When approved, the certificates
+// are backed up so that they are generated again, and returns true, as the
+// services must be restarted to use the new certificates.
+func (c *applyCmd) regenerateStaleCertificates(plan *install.Plan) (bool, error) {
+	pki := &install.LocalPKI{GeneratedCertsDirectory: filepath.Join(c.generatedAssetsDir, "keys"), Log: c.out}
+	stale, err := pki.StaleCertificates(plan)
+	if err != nil {
+		return false, fmt.Errorf("error verifying the existing certificates: %v", err)
+	}
+	if len(stale) == 0 {
+		return false, nil
+	}
+	util.PrintHeader(c.out, "Detecting Certificates Affected By Plan Changes", '=')
+	for _, s := range stale {
+		util.PrettyPrintWarn(c.out, "Certificate for %s", s)
+	}
+	if !c.autoApprove {
+		return false, withExitCode(ExitCodeValidationFailed, fmt.Errorf("%d certificates do not match the plan file. Rerun with --auto-approve to regenerate them and redistribute them to the nodes", len(stale)))
+	}
+	if err := pki.BackUpCertificates(stale); err != nil {
+		return false, err
+	}
+	util.PrettyPrintOk(c.out, "Backed up %d certificates to regenerate them", len(stale))
+	return true, nil
+}
+
+// install runs the installation, resuming a failed installation when
+// --resume-from is set, and returns the summary of the tasks that were run
+func (c *applyCmd) install(plan *install.Plan, restartServices bool) (install.RunSummary, error) {
+	if c.resumeFrom == "" {
+		return c.executor.Install(plan, restartServices, c.limit...)
+	}
+	r, ok := c.executor.(install.ResumableExecutor)
+	if !ok {
+		return install.RunSummary{}, fmt.Errorf("the executor cannot resume installations")
+	}
+	return r.ResumeInstall(plan, restartServices, c.resumeFrom, c.limit...)
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+type capacityOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	kubectlPath        string
+	podCPU             string
+	podMemory          string
+	outputFormat       string
+}
+
+// NewCmdCapacity returns the command for reporting the resource capacity of the cluster
+func NewCmdCapacity(out io.Writer) *cobra.Command {
+	opts := &capacityOpts{}
+	cmd := &cobra.Command{
+		Use:   "capacity",
+		Short: "Report the resource headroom of the nodes of the cluster",
+		Long: `Report the resource headroom of the nodes of the cluster, per role.
+
+The allocatable resources of the nodes, and the requests and limits of the pods
+running on them, are read with kubectl using the kubeconfig file generated
+during the installation. The headroom is the allocatable CPU and memory that is
+not requested by the pods. The number of typical pods, as described by the
+--pod-cpu and --pod-memory flags, that still fit in the headroom of the
+schedulable nodes is projected, which is useful after adding nodes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doCapacity(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().StringVar(&opts.kubectlPath, "kubectl-path", "kubectl", "path to the kubectl binary used to read the resources of the cluster")
+	cmd.Flags().StringVar(&opts.podCPU, "pod-cpu", "100m", "CPU requested by a typical pod, used for the projection")
+	cmd.Flags().StringVar(&opts.podMemory, "pod-memory", "256Mi", "memory requested by a typical pod, used for the projection")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "table", `output format (options "table"|"json")`)
+	return cmd
+}
+
+func doCapacity(out io.Writer, opts *capacityOpts) error {
+	if opts.outputFormat != "table" && opts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	var typicalPod install.PodResources
+	var err error
+	if typicalPod.MilliCPU, err = install.ParseMilliCPU(opts.podCPU); err != nil {
+		return err
+	}
+	if typicalPod.MemoryBytes, err = install.ParseMemoryBytes(opts.podMemory); err != nil {
+		return err
+	}
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	scanner := install.CapacityScanner{
+		BinaryPath: opts.kubectlPath,
+		Kubeconfig: filepath.Join(opts.generatedAssetsDir, "kubeconfig"),
+	}
+	report, err := scanner.Scan(*plan, typicalPod)
+	if err != nil {
+		return err
+	}
+	return printCapacity(out, report, opts.outputFormat)
+}
+
+// printCapacity prints the capacity report
+func printCapacity(out io.Writer, report *install.CapacityReport, format string) error {
+	if format == "json" {
+		b, err := json.MarshalIndent(report, "", "    ")
+		if err != nil {
+			return fmt.Errorf("marshal error: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+		return nil
+	}
+	cpu := func(r install.PodResources) string { return fmt.Sprintf("%dm", r.MilliCPU) }
+	memory := func(r install.PodResources) string { return HumanFormat(float64(r.MemoryBytes)) }
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tNODES\tCPU ALLOCATABLE\tCPU REQUESTS\tCPU HEADROOM\tMEMORY ALLOCATABLE\tMEMORY REQUESTS\tMEMORY HEADROOM\tFITTING PODS\t")
+	for _, r := range report.Roles {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t\n", r.Role, r.Nodes, cpu(r.Allocatable), cpu(r.Requests), cpu(r.Headroom), memory(r.Allocatable), memory(r.Requests), memory(r.Headroom), r.FittingPods)
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLES\tPODS\tCPU REQUESTS\tCPU LIMITS\tCPU HEADROOM\tMEMORY REQUESTS\tMEMORY LIMITS\tMEMORY HEADROOM\tFITTING PODS\t")
+	for _, n := range report.Nodes {
+		fitting := fmt.Sprintf("%d", n.FittingPods)
+		if !n.Schedulable {
+			fitting = "unschedulable"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", n.Name, strings.Join(n.Roles, ","), n.Pods, n.AllocatablePods, cpu(n.Requests), cpu(n.Limits), cpu(n.Headroom), memory(n.Requests), memory(n.Limits), memory(n.Headroom), fitting)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\nA typical pod requests %s of CPU and %s of memory. A node with more than one role is counted in each of its roles.\n", cpu(report.TypicalPod), memory(report.TypicalPod))
+	return nil
+}
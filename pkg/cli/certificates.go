@@ -17,6 +17,7 @@ func NewCmdCertificates(out io.Writer) *cobra.Command {
 	}
 
 	cmd.AddCommand(NewCmdGenerate(out))
+	cmd.AddCommand(NewCmdRotateProxyClientCA(out))
 
 	return cmd
 }
@@ -17,6 +17,8 @@ type certificatesGenerateOpts struct {
 	organizations      []string
 	overwrite          bool
 	generatedAssetsDir string
+	outputFormat       string
+	planFilename       string
 }
 
 // NewCmdGenerate creates a new certificates generate command
@@ -24,9 +26,16 @@ func NewCmdGenerate(out io.Writer) *cobra.Command {
 	opts := &certificatesGenerateOpts{}
 
 	cmd := &cobra.Command{
-		Use:   "generate <name> [options]",
+		Use:   "generate [<name>] [options]",
 		Short: "Generate a cluster certificate, expects 'ca.pem' and 'ca-key.pem' to be in the --generated-assets-dir",
+		Long: `Generate a certificate signed by the cluster CA, for users and for external components
+such as monitoring agents and admission webhooks. The name of the certificate defaults
+to the common name. With --output kubeconfig, a kubeconfig file that authenticates with
+the certificate is also generated, using the cluster information of the plan file.`,
 		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && opts.commonName != "" {
+				return nil
+			}
 			if len(args) == 0 || args[0] == "" {
 				cmd.Help()
 				return fmt.Errorf("no valid <name> argument provided")
@@ -42,16 +51,27 @@ func NewCmdGenerate(out io.Writer) *cobra.Command {
 				cmd.Help()
 				return fmt.Errorf("--validity-period must be greater than 0")
 			}
-			return doCertificatesGenerate(args[0], opts, out)
+			if opts.outputFormat != "pem" && opts.outputFormat != "kubeconfig" {
+				return fmt.Errorf("output format %q is not supported. Options are \"pem\"|\"kubeconfig\"", opts.outputFormat)
+			}
+			name := opts.commonName
+			if len(args) == 1 {
+				name = args[0]
+			}
+			return doCertificatesGenerate(name, opts, out)
 		},
 	}
 
 	cmd.Flags().StringVar(&opts.commonName, "common-name", "", "override the common name. If left blank, will use <name>")
 	cmd.Flags().IntVar(&opts.validityPeriod, "validity-period", 365, "specify the number of days this certificate should be valid for. Expiration date will be calculated relative to the machine's clock.")
 	cmd.Flags().StringSliceVar(&opts.subjAltNames, "subj-alt-names", []string{}, "comma-separated list of names that should be included in the certificate's subject alternative names field.")
+	cmd.Flags().StringSliceVar(&opts.subjAltNames, "sans", []string{}, "alias of --subj-alt-names.")
 	cmd.Flags().StringSliceVar(&opts.organizations, "organizations", []string{}, "comma-separated list of names that should be included in the certificate's organization field.")
+	cmd.Flags().StringSliceVar(&opts.organizations, "groups", []string{}, "alias of --organizations. Kubernetes derives the groups of the user from the organizations, such as system:masters.")
 	cmd.Flags().BoolVar(&opts.overwrite, "overwrite", false, "overwrite existing certificate if it already exists in the target directory.")
 	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "pem", "output format of the certificate (options \"pem\"|\"kubeconfig\")")
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
 
 	return cmd
 }
@@ -83,5 +103,21 @@ func doCertificatesGenerate(name string, opts *certificatesGenerateOpts, out io.
 		util.PrettyPrintOk(out, "Certficate '%s.pem' created successfully in '%s' directory", name, opts.generatedAssetsDir)
 	}
 
+	if opts.outputFormat == "kubeconfig" {
+		planner := &install.FilePlanner{File: opts.planFilename}
+		if !planner.PlanExists() {
+			return planFileNotFoundErr{filename: opts.planFilename}
+		}
+		plan, err := planner.Read()
+		if err != nil {
+			return fmt.Errorf("failed to read plan file: %v", err)
+		}
+		file, err := install.GenerateUserKubeconfig(plan, opts.generatedAssetsDir, name)
+		if err != nil {
+			return fmt.Errorf("error generating kubeconfig file: %v", err)
+		}
+		util.PrettyPrintOk(out, "Kubeconfig file %q created successfully", file)
+	}
+
 	return nil
 }
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type rotateProxyClientCAOpts struct {
+	planFilename       string
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+}
+
+// NewCmdRotateProxyClientCA creates a new certificates rotate-proxy-client-ca command
+func NewCmdRotateProxyClientCA(out io.Writer) *cobra.Command {
+	opts := &rotateProxyClientCAOpts{}
+	cmd := &cobra.Command{
+		Use:   "rotate-proxy-client-ca",
+		Short: "Replace the proxy-client CA used by the aggregation layer, and redistribute it to the nodes",
+		Long: `Replace the proxy-client CA used by the aggregation layer, and the proxy-client
+certificate that it signs. The new certificates are redistributed to the nodes, the API
+servers are restarted one at a time, and the aggregation layer is validated through the
+metrics-server APIService. The previous certificates are kept with a .bak extension.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doRotateProxyClientCA(out, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	return cmd
+}
+
+func doRotateProxyClientCA(out io.Writer, opts *rotateProxyClientCAOpts) error {
+	planner := &install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read plan file: %v", err)
+	}
+	executorOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(executorOpts))
+	if err != nil {
+		return err
+	}
+
+	util.PrintHeader(out, "Rotating The Proxy-Client Certificate Authority", '=')
+	pki := &install.LocalPKI{
+		CACsr:                   filepath.Join("ansible", "playbooks", "tls", "ca-csr.json"),
+		GeneratedCertsDirectory: filepath.Join(opts.generatedAssetsDir, "keys"),
+		Log:                     out,
+	}
+	ca, err := pki.GetClusterCA()
+	if err != nil {
+		return err
+	}
+	if _, err := pki.RotateProxyClientCA(plan, ca); err != nil {
+		return fmt.Errorf("error rotating the proxy-client CA: %v", err)
+	}
+
+	if _, err := executor.RunPlay("rotate-proxy-client-ca.yaml", plan, false); err != nil {
+		return withExitCode(playbookExitCode(err), fmt.Errorf("error distributing the proxy-client CA: %v", err))
+	}
+	util.PrintColor(out, util.Green, "\nThe proxy-client CA was rotated successfully\n\n")
+	return nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+func addNoColorFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().Bool("no-color", false, "disable colored output. Color is also disabled when the NO_COLOR environment variable is set, or when using the \"ci\" or \"json\" output formats")
+}
+
+// setupColor disables colored output, both from kismatic and from ansible,
+// when requested with --no-color or NO_COLOR, or when the "ci" or "json"
+// output formats are used.
+func setupColor(cmd *cobra.Command) {
+	noColor, _ := cmd.Flags().GetBool("no-color")
+	if os.Getenv("NO_COLOR") != "" {
+		noColor = true
+	}
+	if f := cmd.Flags().Lookup("output"); f != nil && (f.Value.String() == "ci" || f.Value.String() == "json") {
+		noColor = true
+	}
+	if !noColor {
+		return
+	}
+	util.SetColor(false)
+	os.Setenv("ANSIBLE_NOCOLOR", "1")
+}
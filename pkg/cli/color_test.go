@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func TestSetupColor(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	defer os.Unsetenv("ANSIBLE_NOCOLOR")
+	tests := []struct {
+		args    []string
+		env     string
+		noColor bool
+	}{
+		{args: []string{}},
+		{args: []string{"--no-color"}, noColor: true},
+		{args: []string{"-o", "ci"}, noColor: true},
+		{args: []string{"-o", "json"}, noColor: true},
+		{args: []string{"-o", "simple"}, env: "1", noColor: true},
+	}
+	for i, test := range tests {
+		color.NoColor = false
+		os.Unsetenv("ANSIBLE_NOCOLOR")
+		os.Setenv("NO_COLOR", test.env)
+		var output string
+		root := &cobra.Command{Use: "kismatic"}
+		addNoColorFlag(root)
+		cmd := &cobra.Command{
+			Use: "apply",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				setupColor(cmd)
+				return nil
+			},
+		}
+		cmd.Flags().StringVarP(&output, "output", "o", "simple", "")
+		root.AddCommand(cmd)
+		root.SetOutput(ioutil.Discard)
+		root.SetArgs(append([]string{"apply"}, test.args...))
+		if err := root.Execute(); err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if color.NoColor != test.noColor {
+			t.Errorf("test %d: expected no color to be %v, but got %v", i, test.noColor, color.NoColor)
+		}
+		if (os.Getenv("ANSIBLE_NOCOLOR") != "") != test.noColor {
+			t.Errorf("test %d: expected ansible color to be disabled along with kismatic color", i)
+		}
+	}
+	os.Unsetenv("NO_COLOR")
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 
+	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/spf13/pflag"
 )
 
@@ -10,6 +11,40 @@ func addPlanFileFlag(flagSet *pflag.FlagSet, p *string) {
 	flagSet.StringVarP(p, "plan-file", "f", "kismatic-cluster.yaml", "path to the installation plan file")
 }
 
+func addTaskFilterFlags(flagSet *pflag.FlagSet, show, hide *string) {
+	flagSet.StringVar(show, "show-tasks", "", "only display the tasks whose name, or the name of their play, matches the regular expression. Failures are always displayed")
+	flagSet.StringVar(hide, "hide-tasks", "", "do not display the tasks whose name, or the name of their play, matches the regular expression. Failures are always displayed")
+}
+
+func addSmokeTestEngineFlag(flagSet *pflag.FlagSet, p *string) {
+	flagSet.StringVar(p, "smoke-test-engine", install.KuberangSmokeTestEngine, "engine used to run the smoke test (options \"kuberang\"|\"native\"). The native engine does not require the kuberang binary")
+}
+
+func addTimingsFlag(flagSet *pflag.FlagSet, p *bool) {
+	flagSet.BoolVar(p, "timings", false, "print the time spent on each play and host at the end of each task (recorded in the run manifest)")
+}
+
+func addRestartComponentsFlag(flagSet *pflag.FlagSet, p *[]string) {
+	flagSet.StringSliceVar(p, "restart-components", []string{}, fmt.Sprintf("comma-separated list of components to force restart, instead of all the services restarted by --restart-services %v", install.RestartComponents()))
+}
+
+// addLimitFlags adds the flags that limit the execution to a subset of the
+// nodes, and that exclude some of the nodes from it
+func addLimitFlags(flagSet *pflag.FlagSet, limit *[]string, exclude *[]string) {
+	flagSet.StringSliceVar(limit, "limit", []string{}, "comma-separated list of hostnames, roles or node label selectors (key=value) to limit the execution to a subset of nodes")
+	flagSet.StringSliceVar(exclude, "exclude", []string{}, "comma-separated list of hostnames, roles or node label selectors (key=value) to exclude from the execution")
+}
+
+// limitWithExclusions returns the limit, followed by the expressions that
+// exclude the nodes of the exclude flag
+func limitWithExclusions(limit []string, exclude []string) []string {
+	return append(append([]string{}, limit...), install.ExcludeFromLimit(exclude...)...)
+}
+
+func addPreflightParallelismFlag(flagSet *pflag.FlagSet, p *int) {
+	flagSet.IntVar(p, "max-parallel-preflight", 10, "the maximum number of nodes on which pre-flight checks are run in parallel. The output of each node is prefixed with its name")
+}
+
 type planFileNotFoundErr struct {
 	filename string
 }
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/pflag"
+)
+
+func addForceVersionFlag(flagSet *pflag.FlagSet, p *bool) {
+	flagSet.BoolVar(p, "force", false, "proceed even if the cluster is running versions that are not supported by this version of kismatic (recorded in the run manifest)")
+}
+
+// checkVersionCompatibility detects the versions installed on the cluster
+// nodes, and verifies that the operation is supported by this version of kismatic.
+func checkVersionCompatibility(out io.Writer, plan *install.Plan, op install.Operation, force bool) error {
+	cv, err := install.ListInstalledVersions(plan)
+	if err != nil {
+		util.PrettyPrintErr(out, "Checking cluster version compatibility")
+		return fmt.Errorf("error detecting the versions installed on the cluster: %v", err)
+	}
+	return reportVersionCompatibility(out, cv, op, force)
+}
+
+// reportVersionCompatibility prints the result of the version compatibility
+// checks. When force is true, incompatibilities are reported as warnings.
+func reportVersionCompatibility(out io.Writer, cv install.ClusterVersion, op install.Operation, force bool) error {
+	errs := install.CheckVersionCompatibility(cv, op)
+	if len(errs) == 0 {
+		util.PrettyPrintOk(out, "Checking cluster version compatibility")
+		return nil
+	}
+	for _, err := range errs {
+		logging.Warn("version compatibility error", "operation", op, "error", err, "force", force)
+	}
+	if force {
+		util.PrettyPrintWarn(out, "Checking cluster version compatibility")
+		util.PrintValidationErrors(out, errs)
+		util.PrettyPrintWarn(out, "Ignoring version compatibility errors due to --force")
+		return nil
+	}
+	util.PrettyPrintErr(out, "Checking cluster version compatibility")
+	util.PrintValidationErrors(out, errs)
+	return withExitCode(ExitCodeValidationFailed, errors.New("Cluster version compatibility errors prevent the operation from proceeding. Use --force to proceed anyway"))
+}
@@ -0,0 +1,289 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	completeNodes = "nodes"
+	completeSteps = "steps"
+)
+
+// bashCompletionFunc is appended to the generated bash completion script. It
+// shells out to the hidden "__complete" command to complete node names and
+// step names using the plan file that is set on the command line.
+const bashCompletionFunc = `__kismatic_plan_file()
+{
+    local plan_file="kismatic-cluster.yaml"
+    if [[ -z "${BASH_VERSION}" || "${BASH_VERSINFO[0]}" -gt 3 ]]; then
+        if [[ -n "${flaghash[--plan-file]}" ]]; then
+            plan_file="${flaghash[--plan-file]}"
+        elif [[ -n "${flaghash[--plan-file=]}" ]]; then
+            plan_file="${flaghash[--plan-file=]}"
+        elif [[ -n "${flaghash[-f]}" ]]; then
+            plan_file="${flaghash[-f]}"
+        fi
+    fi
+    echo "${plan_file}"
+}
+
+__kismatic_complete()
+{
+    local kismatic_out
+    if kismatic_out=$(kismatic __complete "$1" --plan-file "$(__kismatic_plan_file)" 2>/dev/null); then
+        COMPREPLY=( $( compgen -W "${kismatic_out[*]}" -- "$cur" ) )
+    fi
+}
+
+__kismatic_get_nodes()
+{
+    __kismatic_complete nodes
+}
+
+__kismatic_get_steps()
+{
+    __kismatic_complete steps
+}
+
+__custom_func() {
+    case ${last_command} in
+        kismatic_ssh)
+            __kismatic_get_nodes
+            return
+            ;;
+        kismatic_install_step)
+            __kismatic_get_steps
+            return
+            ;;
+        *)
+            ;;
+    esac
+}
+`
+
+// NewCmdCompletion returns the completion command
+func NewCmdCompletion(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion SHELL",
+		Short: "Output shell completion code for the specified shell (bash, zsh or fish)",
+		Long: `Output shell completion code for the specified shell (bash, zsh or fish).
+
+Node names and step names are completed using the plan file set with --plan-file,
+or kismatic-cluster.yaml in the current directory.`,
+		Example: `  # Load completion into the current bash shell
+  source <(kismatic completion bash)
+
+  # Load completion into the current zsh shell
+  source <(kismatic completion zsh)
+
+  # Install completion for fish
+  kismatic completion fish > ~/.config/fish/completions/kismatic.fish`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doCompletion(out, cmd.Root(), args[0])
+		},
+	}
+	return cmd
+}
+
+func doCompletion(out io.Writer, root *cobra.Command, shell string) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletion(out)
+	case "zsh":
+		return genZshCompletion(out, root)
+	case "fish":
+		return genFishCompletion(out, root)
+	default:
+		return fmt.Errorf("shell %q is not supported (options \"bash\"|\"zsh\"|\"fish\")", shell)
+	}
+}
+
+// the zsh completion is the bash completion script running under bashcompinit,
+// which supports the dynamic completion of nodes and steps.
+func genZshCompletion(out io.Writer, root *cobra.Command) error {
+	fmt.Fprintln(out, "#compdef kismatic")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "autoload -U +X compinit && compinit")
+	fmt.Fprintln(out, "autoload -U +X bashcompinit && bashcompinit")
+	fmt.Fprintln(out)
+	buf := &bytes.Buffer{}
+	if err := root.GenBashCompletion(buf); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+func genFishCompletion(out io.Writer, root *cobra.Command) error {
+	name := root.Name()
+	fmt.Fprintf(out, "# fish completion for %s\n\n", name)
+	fmt.Fprintf(out, `function __%[1]s_plan_file
+    set -l tokens (commandline -opc)
+    set -l plan_file kismatic-cluster.yaml
+    for i in (seq (count $tokens))
+        switch $tokens[$i]
+            case '--plan-file=*'
+                set plan_file (string replace -- '--plan-file=' '' $tokens[$i])
+            case --plan-file -f
+                if test $i -lt (count $tokens)
+                    set plan_file $tokens[(math $i + 1)]
+                end
+        end
+    end
+    echo $plan_file
+end
+
+function __%[1]s_complete
+    %[1]s __complete $argv[1] --plan-file (__%[1]s_plan_file) 2>/dev/null
+end
+
+function __%[1]s_using_path
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l path
+    for t in $tokens
+        switch $t
+            case '-*'
+            case '*'
+                set path $path $t
+        end
+    end
+    test "$path" = "$argv"
+end
+
+`, name)
+	writeFishCommand(out, root, nil)
+	fmt.Fprintf(out, "complete -c %s -n '__%s_using_path ssh' -f -a '(__%s_complete %s)'\n", name, name, name, completeNodes)
+	fmt.Fprintf(out, "complete -c %s -n '__%s_using_path install step' -f -a '(__%s_complete %s)'\n", name, name, name, completeSteps)
+	return nil
+}
+
+func writeFishCommand(out io.Writer, cmd *cobra.Command, path []string) {
+	name := cmd.Root().Name()
+	condition := fmt.Sprintf("__%s_using_path %s", name, strings.Join(path, " "))
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() {
+			continue
+		}
+		fmt.Fprintf(out, "complete -c %s -n '%s' -f -a %s -d %s\n", name, condition, c.Name(), fishQuote(c.Short))
+	}
+	writeFishFlags := func(f *pflag.Flag) {
+		if f.Hidden || f.Deprecated != "" {
+			return
+		}
+		line := fmt.Sprintf("complete -c %s -n '%s' -l %s", name, condition, f.Name)
+		if f.Shorthand != "" {
+			line += " -s " + f.Shorthand
+		}
+		if f.Value.Type() != "bool" {
+			line += " -r"
+		}
+		if _, ok := f.Annotations[cobra.BashCompCustom]; ok {
+			line += fmt.Sprintf(" -f -a '(__%s_complete %s)'", name, completeNodes)
+		}
+		fmt.Fprintf(out, "%s -d %s\n", line, fishQuote(f.Usage))
+	}
+	cmd.NonInheritedFlags().VisitAll(writeFishFlags)
+	cmd.InheritedFlags().VisitAll(writeFishFlags)
+	fmt.Fprintln(out)
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() {
+			continue
+		}
+		writeFishCommand(out, c, append(path, c.Name()))
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.Replace(strings.Replace(s, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+}
+
+// annotateCompletions walks the command tree, and configures the bash
+// completion of the flags that take node names.
+func annotateCompletions(cmd *cobra.Command) {
+	for _, name := range []string{"limit", "exclude"} {
+		if cmd.Flags().Lookup(name) != nil {
+			cmd.Flags().SetAnnotation(name, cobra.BashCompCustom, []string{"__kismatic_get_nodes"})
+		}
+	}
+	for _, c := range cmd.Commands() {
+		annotateCompletions(c)
+	}
+}
+
+// NewCmdComplete returns the hidden command used by the completion scripts
+// to list the nodes and steps that can be completed.
+func NewCmdComplete(out io.Writer) *cobra.Command {
+	var planFilename string
+	cmd := &cobra.Command{
+		Use:    "__complete nodes|steps",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			var (
+				words []string
+				err   error
+			)
+			switch args[0] {
+			case completeNodes:
+				words, err = completionNodes(planFilename)
+			case completeSteps:
+				words, err = completionSteps(filepath.Join("ansible", "playbooks"))
+			default:
+				return fmt.Errorf("cannot complete %q", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			for _, w := range words {
+				fmt.Fprintln(out, w)
+			}
+			return nil
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &planFilename)
+	return cmd
+}
+
+func completionNodes(planFilename string) ([]string, error) {
+	planner := &install.FilePlanner{File: planFilename}
+	if !planner.PlanExists() {
+		return nil, planFileNotFoundErr{filename: planFilename}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file: %v", err)
+	}
+	var hosts []string
+	for _, n := range plan.GetUniqueNodes() {
+		hosts = append(hosts, n.Host)
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+func completionSteps(playbooksDir string) ([]string, error) {
+	plays, err := install.ListPlays(playbooksDir)
+	if err != nil {
+		return nil, err
+	}
+	var steps []string
+	for _, p := range plays {
+		steps = append(steps, p.Name)
+	}
+	return steps, nil
+}
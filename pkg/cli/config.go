@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const configFileEnv = "KISMATIC_CONFIG"
+
+// the flags whose defaults can be set in the configuration file, and with
+// the KISMATIC_<FLAG> environment variables
+var configurableFlags = []string{
+	"output",
+	"verbose",
+	"generated-assets-dir",
+	"runs-dir",
+	"max-parallel-preflight",
+	"max-parallel-workers",
+	"log-file",
+	"tee-file",
+	"tee-socket",
+	"log-sink",
+	"no-color",
+	"operation-timeout",
+	"play-timeout",
+	"preflight-timeout",
+	"apply-timeout",
+	"upgrade-timeout",
+	"diagnose-on-timeout",
+	"diagnose-on-failure",
+	"retries",
+	"retry-backoff",
+	"shred-credentials",
+	"vault-cluster-catalog",
+	"hooks-file",
+	"notification-webhook",
+	"forks",
+	"serial",
+}
+
+// configFilePath returns the path of the configuration file, which is
+// ~/.kismatic/config unless KISMATIC_CONFIG is set
+func configFilePath() string {
+	if p := os.Getenv(configFileEnv); p != "" {
+		return p
+	}
+	return filepath.Join(os.Getenv("HOME"), ".kismatic", "config")
+}
+
+// readConfigFile returns the settings of the configuration file, by flag
+// name. No settings are returned when the file does not exist.
+func readConfigFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration file %q: %v", path, err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing configuration file %q: %v", path, err)
+	}
+	config := map[string]string{}
+	for k, v := range raw {
+		if !util.Contains(k, configurableFlags) {
+			return nil, fmt.Errorf("unknown setting %q in configuration file %q. Options are %v", k, path, configurableFlags)
+		}
+		config[k] = fmt.Sprint(v)
+	}
+	return config, nil
+}
+
+// configEnvVar returns the name of the environment variable of the flag
+func configEnvVar(flag string) string {
+	return "KISMATIC_" + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+// setupConfig sets the defaults of the flags of the command that were not
+// set on the command line, from the environment and the configuration file
+func setupConfig(cmd *cobra.Command) error {
+	config, err := readConfigFile(configFilePath())
+	if err != nil {
+		return err
+	}
+	return applyConfig(cmd.Flags(), config, os.Getenv)
+}
+
+// applyConfig sets the flags that were not set on the command line. The
+// environment variables take precedence over the configuration file.
+func applyConfig(flags *pflag.FlagSet, config map[string]string, getenv func(string) string) error {
+	for _, name := range configurableFlags {
+		f := flags.Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		// the output flag is only set on the commands that run ansible, as
+		// other commands have their own output formats
+		if name == "output" && !strings.HasPrefix(f.Usage, "installation output format") {
+			continue
+		}
+		source := configEnvVar(name)
+		v := getenv(source)
+		if v == "" {
+			source = "configuration file"
+			v = config[name]
+		}
+		if v == "" {
+			continue
+		}
+		if err := flags.Set(name, v); err != nil {
+			return fmt.Errorf("invalid value %q for %q set in %s: %v", v, name, source, err)
+		}
+	}
+	return nil
+}
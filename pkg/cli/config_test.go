@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config, err := readConfigFile(filepath.Join(dir, "config"))
+	if err != nil || config != nil {
+		t.Errorf("expected no settings when the file does not exist, got %v %v", config, err)
+	}
+
+	file := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(file, []byte("output: table\nverbose: true\nmax-parallel-preflight: 5\n"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	config, err = readConfigFile(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"output": "table", "verbose": "true", "max-parallel-preflight": "5"}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %v, got %v", expected, config)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("force: true\n"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if _, err := readConfigFile(file); err == nil {
+		t.Errorf("expected an error for a setting that cannot be configured")
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	output := flags.StringP("output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	verbose := flags.Bool("verbose", false, "")
+	assetsDir := flags.String("generated-assets-dir", "generated", "")
+	parallel := flags.Int("max-parallel-preflight", 10, "")
+	if err := flags.Parse([]string{"--generated-assets-dir", "cli"}); err != nil {
+		t.Fatalf("error parsing flags: %v", err)
+	}
+	config := map[string]string{"output": "table", "verbose": "true", "generated-assets-dir": "config", "max-parallel-preflight": "5"}
+	env := map[string]string{"KISMATIC_MAX_PARALLEL_PREFLIGHT": "2"}
+	getenv := func(k string) string { return env[k] }
+
+	if err := applyConfig(flags, config, getenv); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *output != "table" || !*verbose {
+		t.Errorf("expected the configuration file to set the defaults, got output %q verbose %v", *output, *verbose)
+	}
+	if *assetsDir != "cli" {
+		t.Errorf("expected the command line to take precedence, got %q", *assetsDir)
+	}
+	if *parallel != 2 {
+		t.Errorf("expected the environment to take precedence over the configuration file, got %d", *parallel)
+	}
+}
+
+func TestApplyConfigOtherOutputFormats(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	output := flags.StringP("output", "o", "simple", `output format (options "simple"|"json")`)
+	if err := applyConfig(flags, map[string]string{"output": "table"}, func(string) string { return "" }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *output != "simple" {
+		t.Errorf("expected the output format of the command to be left alone, got %q", *output)
+	}
+}
+
+func TestApplyConfigInvalidValue(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("max-parallel-preflight", 10, "")
+	if err := applyConfig(flags, map[string]string{"max-parallel-preflight": "many"}, func(string) string { return "" }); err == nil {
+		t.Errorf("expected an error for an invalid value")
+	}
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type conformanceOpts struct {
+	generatedAssetsDir string
+	runsDir            string
+	sonobuoyPath       string
+	mode               string
+	timeout            time.Duration
+	keepResources      bool
+}
+
+// NewCmdConformance creates a new conformance command
+func NewCmdConformance(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "conformance",
+		Short: "Run the Kubernetes conformance tests against the cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewCmdConformanceRun(out))
+
+	return cmd
+}
+
+// NewCmdConformanceRun creates a new conformance run command
+func NewCmdConformanceRun(out io.Writer) *cobra.Command {
+	opts := &conformanceOpts{}
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run the Kubernetes conformance tests using Sonobuoy, and retrieve the results",
+		Long: `Run the Kubernetes conformance tests using Sonobuoy, and retrieve the results.
+
+The sonobuoy binary is used to deploy Sonobuoy to the cluster, using the
+kubeconfig file generated during the installation. Once the tests complete,
+the results are retrieved into the runs directory, along with a summary of
+the tests that passed and failed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doConformanceRun(out, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().StringVar(&opts.runsDir, "runs-dir", "runs", "path to the directory where the results of the conformance tests are stored")
+	cmd.Flags().StringVar(&opts.sonobuoyPath, "sonobuoy-path", "sonobuoy", "path to the sonobuoy binary")
+	cmd.Flags().StringVar(&opts.mode, "mode", "", "sonobuoy mode used to run the tests. Uses the sonobuoy default when not set")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 3*time.Hour, "maximum time to wait for the conformance tests to complete")
+	cmd.Flags().BoolVar(&opts.keepResources, "keep-resources", false, "do not remove Sonobuoy from the cluster after retrieving the results")
+	return cmd
+}
+
+func doConformanceRun(out io.Writer, opts *conformanceOpts) error {
+	util.PrintHeader(out, "Running Conformance Tests", '=')
+	kubeconfig := filepath.Join(opts.generatedAssetsDir, "kubeconfig")
+	if stat, err := os.Stat(kubeconfig); os.IsNotExist(err) || stat.IsDir() {
+		return fmt.Errorf("Did not find required kubeconfig file %q", kubeconfig)
+	}
+	resultsDir := filepath.Join(opts.runsDir, "conformance", time.Now().Format("2006-01-02-15-04-05"))
+	if err := os.MkdirAll(resultsDir, 0777); err != nil {
+		return fmt.Errorf("error creating directory %q: %v", resultsDir, err)
+	}
+	sonobuoy := install.Sonobuoy{
+		BinaryPath:    opts.sonobuoyPath,
+		Kubeconfig:    kubeconfig,
+		Mode:          opts.mode,
+		Timeout:       opts.timeout,
+		PollInterval:  30 * time.Second,
+		KeepResources: opts.keepResources,
+	}
+	results, err := sonobuoy.Run(out, resultsDir)
+	if err != nil {
+		return err
+	}
+	printConformanceResults(out, results)
+	if results.Failed > 0 {
+		return withExitCode(ExitCodeConformanceFailed, fmt.Errorf("%d conformance test(s) failed", results.Failed))
+	}
+	return nil
+}
+
+func printConformanceResults(out io.Writer, results *install.ConformanceResults) {
+	util.PrintHeader(out, "Results", '=')
+	fmt.Fprintf(out, "Passed: %d\n", results.Passed)
+	fmt.Fprintf(out, "Failed: %d\n", results.Failed)
+	fmt.Fprintf(out, "Skipped: %d\n", results.Skipped)
+	for _, t := range results.FailedTests {
+		fmt.Fprintf(out, "  - %s\n", t)
+	}
+	fmt.Fprintf(out, "\nThe results can be found in %q\n", results.ResultsFile)
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+type credentialsShowOpts struct {
+	generatedAssetsDir string
+	force              bool
+}
+
+// NewCmdCredentials creates a new credentials command
+func NewCmdCredentials(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "credentials",
+		Short: "Manage the credentials generated for the cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewCmdCredentialsShow(out))
+
+	return cmd
+}
+
+// NewCmdCredentialsShow creates a new credentials show command
+func NewCmdCredentialsShow(out io.Writer) *cobra.Command {
+	opts := &credentialsShowOpts{}
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Display the credentials generated for the cluster",
+		Long: `Display the credentials that were generated for the cluster: the admin password when
+cluster.generate_admin_password is set in the plan file, and the admin token when
+cluster.authentication.mode is token.
+
+The credentials are stored in the generated assets directory, encrypted with the key set
+in the KISMATIC_CREDENTIALS_KEY environment variable. They are only read when their file is
+only accessible to the current user.
+
+The credentials are displayed once: the command fails when they were already displayed,
+unless --force is set. New credentials generated by a later operation are displayed again.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doCredentialsShow(out, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process are stored")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "display the credentials even if they were already displayed")
+	return cmd
+}
+
+func doCredentialsShow(out io.Writer, opts *credentialsShowOpts) error {
+	c, err := install.ReadAdminCredentials(opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("no credentials were generated in %q, set cluster.generate_admin_password or the token authentication mode in the plan file to generate them", opts.generatedAssetsDir)
+	}
+	if c.Shown && !opts.force {
+		return fmt.Errorf("the credentials were already displayed, use --force to display them again")
+	}
+	// The credentials are marked as shown before they are displayed, so that
+	// they are never displayed more than once without --force
+	if !c.Shown {
+		if err := install.MarkAdminCredentialsShown(opts.generatedAssetsDir, c); err != nil {
+			return err
+		}
+	}
+	if c.AdminPassword != "" {
+		fmt.Fprintf(out, "Admin password: %s\n", c.AdminPassword)
+	}
+	if c.AdminToken != "" {
+		fmt.Fprintf(out, "Admin token: %s\n", c.AdminToken)
+	}
+	return nil
+}
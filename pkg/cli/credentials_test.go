@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestCredentialsShowOnce(t *testing.T) {
+	defer os.Unsetenv("KISMATIC_CREDENTIALS_KEY")
+	os.Setenv("KISMATIC_CREDENTIALS_KEY", "key")
+	dir, err := ioutil.TempDir("", "credentials-show")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	vaulted, err := ansible.EncryptVault([]byte("admin_password: secret\n"), []byte("key"))
+	if err != nil {
+		t.Fatalf("error encrypting credentials: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "credentials.yaml"), vaulted, 0600); err != nil {
+		t.Fatalf("error writing credentials: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	if err := doCredentialsShow(out, &credentialsShowOpts{generatedAssetsDir: dir}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Admin password: secret") {
+		t.Errorf("expected the admin password to be displayed, got %q", out.String())
+	}
+
+	out.Reset()
+	err = doCredentialsShow(out, &credentialsShowOpts{generatedAssetsDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("expected an error when the credentials were already displayed, got %v", err)
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Errorf("expected the admin password not to be displayed again, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := doCredentialsShow(out, &credentialsShowOpts{generatedAssetsDir: dir, force: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Admin password: secret") {
+		t.Errorf("expected the admin password to be displayed with --force, got %q", out.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/apprenda/kismatic/pkg/util"
@@ -14,6 +15,9 @@ type diagsOpts struct {
 	planFilename string
 	verbose      bool
 	outputFormat string
+	// the performance collectors are run when the window is set
+	performanceWindow   time.Duration
+	performanceInterval time.Duration
 }
 
 // NewCmdDiagnostic collects diagnostic data on remote nodes
@@ -35,7 +39,9 @@ func NewCmdDiagnostic(out io.Writer) *cobra.Command {
 	// PersistentFlags
 	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFilename)
 	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
-	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	cmd.Flags().DurationVar(&opts.performanceWindow, "performance-window", 0, "sample the etcd metrics, the API server request latencies, the usage of the nodes and the conntrack table over this duration, such as 5m. The performance collectors are not run when 0")
+	cmd.Flags().DurationVar(&opts.performanceInterval, "performance-interval", 5*time.Second, "the time between two samples of the performance collectors")
 
 	return cmd
 }
@@ -70,8 +76,12 @@ func doDiagnostics(out io.Writer, opts *diagsOpts) error {
 	options := install.ExecutorOptions{
 		OutputFormat: opts.outputFormat,
 		Verbose:      opts.verbose,
+		PerformanceProfile: install.PerformanceProfile{
+			Window:   opts.performanceWindow,
+			Interval: opts.performanceInterval,
+		},
 	}
-	executor, err := install.NewDiagnosticsExecutor(out, os.Stderr, options)
+	executor, err := install.NewDiagnosticsExecutor(out, os.Stderr, globalExecutorOptions(options))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apprenda/kismatic/pkg/eventstream"
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+// eventStreamAddress is set with --event-stream-address
+var eventStreamAddress string
+
+// eventStreamAllowedOrigins is set with --event-stream-allowed-origins
+var eventStreamAllowedOrigins []string
+
+// eventStreamAllowedHosts is set with --event-stream-allowed-hosts
+var eventStreamAllowedHosts []string
+
+// eventStream streams the events of the operations when
+// --event-stream-address is set
+var eventStream *eventstream.Server
+
+func addEventStreamFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&eventStreamAddress, "event-stream-address", "", "address (e.g. :8090) where the events of the operations are streamed to remote user interfaces over a websocket, at ws://<address>/events. The events are streamed on localhost when the address has no host")
+	cmd.PersistentFlags().StringSliceVar(&eventStreamAllowedOrigins, "event-stream-allowed-origins", nil, "origins (e.g. https://ui.example.com) of the web pages allowed to connect to the event stream, in addition to the pages served from its address")
+	cmd.PersistentFlags().StringSliceVar(&eventStreamAllowedHosts, "event-stream-allowed-hosts", nil, "host names (e.g. bastion.example.com) clients can connect to the event stream with, in addition to localhost, IP addresses and the host of --event-stream-address")
+}
+
+// setupEventStream starts streaming the events of the operations if an
+// address is set
+func setupEventStream(stderr io.Writer) error {
+	if eventStreamAddress == "" || eventStream != nil {
+		return nil
+	}
+	s, err := eventstream.Listen(eventStreamAddress)
+	if err != nil {
+		return fmt.Errorf("error starting the event stream: %v", err)
+	}
+	s.AllowedOrigins = eventStreamAllowedOrigins
+	s.AllowedHosts = eventStreamAllowedHosts
+	eventStream = s
+	logging.Info("streaming events", "address", s.Addr())
+	fmt.Fprintf(stderr, "Streaming events on ws://%s%s\n", s.Addr(), eventstream.Path)
+	return nil
+}
+
+// eventStreamWriter returns the writer of the event stream, or nil when the
+// events are not streamed
+func eventStreamWriter() io.Writer {
+	if eventStream == nil {
+		return nil
+	}
+	return eventStream
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+// the timeouts of the operations, set with --operation-timeout,
+// --play-timeout, --preflight-timeout, --apply-timeout, --upgrade-timeout
+// and --diagnose-on-timeout
+var (
+	operationTimeout  time.Duration
+	playTimeout       time.Duration
+	taskTimeouts      install.TaskTimeouts
+	diagnoseOnTimeout bool
+)
+
+// diagnoseOnFailure is set with --diagnose-on-failure
+var diagnoseOnFailure bool
+
+// the retry policy of the failed playbooks, set with --retries and
+// --retry-backoff
+var (
+	retries      int
+	retryBackoff time.Duration
+)
+
+// shredCredentials is set with --shred-credentials
+var shredCredentials bool
+
+// vaultClusterCatalog is set with --vault-cluster-catalog
+var vaultClusterCatalog bool
+
+// notificationWebhook is set with --notification-webhook
+var notificationWebhook string
+
+// hooksFile is set with --hooks-file
+var hooksFile string
+
+// logSink is set with --log-sink
+var logSink string
+
+// showProgress is set with --show-progress
+var showProgress bool
+
+// the parallelism of the playbooks, set with --forks and --serial
+var (
+	forks  int
+	serial string
+)
+
+func addTimeoutFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().DurationVar(&operationTimeout, "operation-timeout", 0, "maximum duration of each playbook run by the operations, after which the playbook is terminated (e.g. 2h). Disabled when 0")
+	cmd.PersistentFlags().DurationVar(&playTimeout, "play-timeout", 0, "maximum duration of each play of the playbooks, after which the playbook is terminated (e.g. 30m). Disabled when 0")
+	cmd.PersistentFlags().DurationVar(&taskTimeouts.Preflight, "preflight-timeout", 0, "maximum duration of the pre-flight checks, overriding --operation-timeout (e.g. 15m). Disabled when 0")
+	cmd.PersistentFlags().DurationVar(&taskTimeouts.Apply, "apply-timeout", 0, "maximum duration of the installation of the cluster or of new nodes, overriding --operation-timeout (e.g. 2h). Disabled when 0")
+	cmd.PersistentFlags().DurationVar(&taskTimeouts.Upgrade, "upgrade-timeout", 0, "maximum duration of the upgrade of the nodes and of the cluster services, overriding --operation-timeout (e.g. 3h). Disabled when 0")
+	cmd.PersistentFlags().BoolVar(&diagnoseOnTimeout, "diagnose-on-timeout", false, "collect diagnostics from the nodes when a playbook times out")
+}
+
+func addRetryFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().IntVar(&retries, "retries", 0, "maximum number of times a playbook that failed on some nodes is run again on those nodes, such as after a transient SSH or package mirror error")
+	cmd.PersistentFlags().DurationVar(&retryBackoff, "retry-backoff", 10*time.Second, "time to wait before the first retry of a failed playbook, doubled before each of the following retries")
+}
+
+func addShredCredentialsFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&shredCredentials, "shred-credentials", false, "overwrite the files that hold the passwords and SSH keys of the cluster before removing them at the end of each playbook. The cluster catalog and the plan file are then not kept in the run directory")
+}
+
+func addVaultClusterCatalogFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&vaultClusterCatalog, "vault-cluster-catalog", false, "encrypt the variables passed to ansible with ansible-vault, using a random password for each playbook, so that they are not kept in cleartext in the run directory")
+}
+
+func addNotificationWebhookFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&notificationWebhook, "notification-webhook", "", "URL that is sent a JSON payload when each task of the operations starts, succeeds or fails")
+}
+
+func addHooksFileFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&hooksFile, "hooks-file", "", "path to a file listing the commands and the playbooks to run before and after the tasks of the operations")
+}
+
+func addLogSinkFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&logSink, "log-sink", "", "mirror the ansible log of each playbook to journald, or to a syslog server such as udp://logs.example.com:514, tcp://logs.example.com:601 or unix:///dev/log, in addition to the ansible.log file of the run directory")
+}
+
+func addShowProgressFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&showProgress, "show-progress", false, "show the percentage of each playbook that completed, the elapsed time and an estimate of the remaining time before the name of each play")
+}
+
+func addParallelismFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().IntVar(&forks, "forks", 0, "number of nodes on which each task of the playbooks runs at the same time, overriding the forks of the plan file. Increase it for clusters of more than 50 nodes")
+	cmd.PersistentFlags().StringVar(&serial, "serial", "", "number of nodes, or percentage of the nodes such as 25%, that each play configures before moving on to the next nodes, overriding the serial of the plan file")
+}
+
+func addDiagnoseOnFailureFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&diagnoseOnFailure, "diagnose-on-failure", false, "collect diagnostics from the nodes on which a playbook fails, or from all the targeted nodes when a playbook times out")
+}
+
+// globalExecutorOptions returns the executor options with the settings of
+// the flags of the kismatic command, which apply to all the operations
+func globalExecutorOptions(opts install.ExecutorOptions) install.ExecutorOptions {
+	opts.OutputFile = teeFile
+	opts.OutputSocket = teeSocket
+	opts.LogSink = logSink
+	opts.EventStream = eventStreamWriter()
+	opts.Timeout = operationTimeout
+	opts.PlayTimeout = playTimeout
+	opts.TaskTimeouts = taskTimeouts
+	opts.DiagnoseOnTimeout = diagnoseOnTimeout
+	opts.DiagnoseOnFailure = diagnoseOnFailure
+	opts.Retry = install.RetryPolicy{MaxRetries: retries, Backoff: retryBackoff}
+	opts.ShredCredentials = shredCredentials
+	opts.VaultClusterCatalog = vaultClusterCatalog
+	opts.HooksFile = hooksFile
+	opts.NotificationWebhook = notificationWebhook
+	opts.ShowProgress = showProgress
+	opts.Parallelism.Forks = forks
+	opts.Parallelism.Serial = serial
+	opts.Context = interruptContext()
+	return opts
+}
+
+var (
+	interruptOnce sync.Once
+	interruptCtx  context.Context
+)
+
+// interruptContext returns a context that is cancelled when kismatic is
+// interrupted with Ctrl-C, so that the running playbook is stopped cleanly
+// and the run is recorded as aborted. Interrupting kismatic again terminates
+// it immediately.
+func interruptContext() context.Context {
+	interruptOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		interruptCtx = ctx
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		go func() {
+			<-sig
+			signal.Stop(sig)
+			cancel()
+		}()
+	})
+	return interruptCtx
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes returned by kismatic. These are part of the CLI contract, and
+// must not change between releases.
+const (
+	// ExitCodeSuccess is returned when the command succeeds
+	ExitCodeSuccess = 0
+	// ExitCodeError is returned when the command fails for any reason that
+	// does not have a more specific exit code
+	ExitCodeError = 1
+	// ExitCodeValidationFailed is returned when the plan file, the SSH
+	// connectivity, the certificates or the cluster versions fail validation
+	ExitCodeValidationFailed = 3
+	// ExitCodePreflightFailed is returned when the pre-flight checks or the
+	// upgrade safety checks fail
+	ExitCodePreflightFailed = 4
+	// ExitCodePlaybookFailed is returned when an ansible playbook fails
+	ExitCodePlaybookFailed = 5
+	// ExitCodePartialUpgrade is returned when a partial upgrade (--partial-ok)
+	// completes. The cluster services are left to be upgraded.
+	ExitCodePartialUpgrade = 6
+	// ExitCodeAborted is returned when the user does not confirm a prompt, or
+	// interrupts a running operation
+	ExitCodeAborted = 7
+	// ExitCodeConformanceFailed is returned when the conformance tests run, and
+	// one or more tests fail
+	ExitCodeConformanceFailed = 8
+)
+
+// exitError is an error that results in a specific exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+// withExitCode returns an error that results in the given exit code
+func withExitCode(code int, err error) error {
+	return exitError{code: code, err: err}
+}
+
+// ExitCode returns the exit code that corresponds to the given error
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitCodeSuccess
+	}
+	if e, ok := err.(exitError); ok {
+		return e.code
+	}
+	return ExitCodeError
+}
+
+// playbookExitCode returns the exit code of an operation that failed running
+// a playbook with the given error
+func playbookExitCode(err error) int {
+	if install.IsAborted(err) {
+		return ExitCodeAborted
+	}
+	return ExitCodePlaybookFailed
+}
+
+var errAborted = withExitCode(ExitCodeAborted, fmt.Errorf("Operation aborted"))
+
+func addAssumeYesFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolP("assume-yes", "y", false, "automatically answer yes to all prompts that do not confirm unsafe operations, and use the default value for all other input (non-interactive mode)")
+}
+
+// assumeYes returns true if the --assume-yes flag was set on the command line
+func assumeYes(cmd *cobra.Command) bool {
+	yes, _ := cmd.Flags().GetBool("assume-yes")
+	return yes
+}
+
+// confirm prompts the user for confirmation. It returns errAborted if the
+// user does not answer yes.
+func confirm(in io.Reader, out io.Writer, yes bool, prompt string) error {
+	if yes {
+		fmt.Fprintf(out, "=> %s [N/y]: y (--assume-yes)\n", prompt)
+		return nil
+	}
+	ans, err := util.PromptForString(in, out, prompt, "N", []string{"N", "y"})
+	if err != nil {
+		return fmt.Errorf("error getting user response: %v", err)
+	}
+	if strings.ToLower(ans) != "y" {
+		return errAborted
+	}
+	return nil
+}
+
+// confirmUnsafe prompts the user for confirmation to continue despite
+// unsafe conditions. Unlike the other prompts, it is not answered by
+// --assume-yes: the operation is aborted unless the user answers yes, and
+// continuing without user interaction requires setting the given flag.
+func confirmUnsafe(in io.Reader, out io.Writer, yes bool, prompt string, flag string) error {
+	if yes {
+		fmt.Fprintf(out, "=> %s [N/y]: N (--assume-yes does not confirm unsafe operations, set %s to continue)\n", prompt, flag)
+		return errAborted
+	}
+	return confirm(in, out, false, prompt)
+}
+
+// promptForInt prompts the user for a number, unless --assume-yes is set,
+// in which case the default value is used.
+func promptForInt(in io.Reader, out io.Writer, yes bool, prompt string, defaultValue int) (int, error) {
+	if yes {
+		fmt.Fprintf(out, "=> %s [%d]: %[2]d (--assume-yes)\n", prompt, defaultValue)
+		return defaultValue, nil
+	}
+	return util.PromptForInt(in, out, prompt, defaultValue)
+}
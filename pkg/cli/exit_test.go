@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{err: nil, expected: ExitCodeSuccess},
+		{err: errors.New("some error"), expected: ExitCodeError},
+		{err: withExitCode(ExitCodeValidationFailed, errors.New("invalid plan")), expected: ExitCodeValidationFailed},
+		{err: withExitCode(ExitCodePartialUpgrade, nil), expected: ExitCodePartialUpgrade},
+		{err: errAborted, expected: ExitCodeAborted},
+	}
+	for _, test := range tests {
+		if code := ExitCode(test.err); code != test.expected {
+			t.Errorf("error %v: expected exit code %d, but got %d", test.err, test.expected, code)
+		}
+	}
+}
+
+func TestPlaybookExitCode(t *testing.T) {
+	if code := playbookExitCode(errors.New("task failed")); code != ExitCodePlaybookFailed {
+		t.Errorf("expected exit code %d, but got %d", ExitCodePlaybookFailed, code)
+	}
+	if code := playbookExitCode(install.AbortedError{Task: "apply"}); code != ExitCodeAborted {
+		t.Errorf("expected exit code %d, but got %d", ExitCodeAborted, code)
+	}
+}
+
+func TestExitCodePreservedWhenWrapped(t *testing.T) {
+	err := withExitCode(ExitCodePreflightFailed, errors.New("preflight failed"))
+	wrapped := withExitCode(ExitCode(err), fmt.Errorf("error validating plan: %v", err))
+	if code := ExitCode(wrapped); code != ExitCodePreflightFailed {
+		t.Errorf("expected exit code %d, but got %d", ExitCodePreflightFailed, code)
+	}
+	if wrapped.Error() != "error validating plan: preflight failed" {
+		t.Errorf("unexpected error message %q", wrapped.Error())
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		in        string
+		assumeYes bool
+		expected  error
+	}{
+		{in: "y\n", expected: nil},
+		{in: "N\n", expected: errAborted},
+		{in: "\n", expected: errAborted},
+		// EOF when there is no terminal attached
+		{in: "", expected: errAborted},
+		{in: "", assumeYes: true, expected: nil},
+	}
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		err := confirm(strings.NewReader(test.in), out, test.assumeYes, "Continue?")
+		if err != test.expected {
+			t.Errorf("input %q, assume yes %v: expected %v, but got %v", test.in, test.assumeYes, test.expected, err)
+		}
+	}
+}
+
+func TestConfirmUnsafe(t *testing.T) {
+	tests := []struct {
+		in        string
+		assumeYes bool
+		expected  error
+	}{
+		{in: "y\n", expected: nil},
+		{in: "N\n", expected: errAborted},
+		{in: "", expected: errAborted},
+		// --assume-yes does not confirm unsafe operations
+		{in: "y\n", assumeYes: true, expected: errAborted},
+	}
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		err := confirmUnsafe(strings.NewReader(test.in), out, test.assumeYes, "Continue?", "--ignore-safety-checks")
+		if err != test.expected {
+			t.Errorf("input %q, assume yes %v: expected %v, but got %v", test.in, test.assumeYes, test.expected, err)
+		}
+		if test.assumeYes && !strings.Contains(out.String(), "--ignore-safety-checks") {
+			t.Errorf("expected the flag that confirms the operation to be printed, got %q", out.String())
+		}
+	}
+}
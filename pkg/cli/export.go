@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type exportOpts struct {
+	planFile  string
+	os        string
+	outputDir string
+}
+
+// NewCmdExport creates a new export command
+func NewCmdExport(out io.Writer) *cobra.Command {
+	opts := &exportOpts{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export artifacts for provisioning nodes that meet the requirements of the plan",
+		Long: `Export artifacts for provisioning nodes that meet the requirements of the plan.
+
+The artifacts set up the hostname, the SSH user and its authorized key, the
+package repositories, the packages and the kernel parameters required by
+each node, so that images can be baked, or instances provisioned, ahead of
+the installation.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
+	cmd.PersistentFlags().StringVar(&opts.os, "os", "centos", fmt.Sprintf("operating system of the nodes (options %v)", install.ExportOperatingSystems))
+	cmd.PersistentFlags().StringVar(&opts.outputDir, "output-dir", "export", "path to the directory where the artifacts are written")
+
+	cmd.AddCommand(NewCmdExportCloudInit(out, opts))
+	cmd.AddCommand(NewCmdExportTerraform(out, opts))
+	cmd.AddCommand(NewCmdExportKubeadm(out, opts))
+
+	return cmd
+}
+
+// NewCmdExportCloudInit creates a new command for exporting cloud-init user-data
+func NewCmdExportCloudInit(out io.Writer, opts *exportOpts) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cloud-init",
+		Short: "Write the cloud-init user-data of each node in the plan",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			_, reqs, err := exportNodeRequirements(out, opts)
+			if err != nil {
+				return err
+			}
+			dir := filepath.Join(opts.outputDir, "cloud-init")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("error creating directory %q: %v", dir, err)
+			}
+			for _, r := range reqs {
+				userData, err := r.CloudInitUserData()
+				if err != nil {
+					return err
+				}
+				file := filepath.Join(dir, r.Host+".yaml")
+				if err := ioutil.WriteFile(file, []byte(userData), 0644); err != nil {
+					return fmt.Errorf("error writing %q: %v", file, err)
+				}
+				util.PrettyPrintOk(out, "Wrote cloud-init user-data of %q to %q", r.Host, file)
+			}
+			return nil
+		},
+	}
+}
+
+// NewCmdExportTerraform creates a new command for exporting terraform locals
+func NewCmdExportTerraform(out io.Writer, opts *exportOpts) *cobra.Command {
+	return &cobra.Command{
+		Use:   "terraform",
+		Short: "Write a Terraform snippet with the private IP, roles and cloud-init user-data of each node in the plan",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			plan, reqs, err := exportNodeRequirements(out, opts)
+			if err != nil {
+				return err
+			}
+			tf, err := install.Terraform(plan.Cluster.Name, reqs)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+				return fmt.Errorf("error creating directory %q: %v", opts.outputDir, err)
+			}
+			file := filepath.Join(opts.outputDir, "kismatic-nodes.tf")
+			if err := ioutil.WriteFile(file, []byte(tf), 0644); err != nil {
+				return fmt.Errorf("error writing %q: %v", file, err)
+			}
+			util.PrettyPrintOk(out, "Wrote Terraform snippet to %q", file)
+			return nil
+		},
+	}
+}
+
+// NewCmdExportKubeadm creates a new command for exporting the kubeadm configuration of the cluster
+func NewCmdExportKubeadm(out io.Writer, opts *exportOpts) *cobra.Command {
+	var generatedAssetsDir string
+	cmd := &cobra.Command{
+		Use:   "kubeadm",
+		Short: "Write the kubeadm configuration and certificates of a control plane equivalent to the one installed by KET",
+		Long: `Write the kubeadm configuration and certificates of a control plane equivalent to the one installed by KET.
+
+The kubeadm init configuration of each master is written to kubeadm/<host>.yaml,
+and the certificate authorities, the service account signing key and the etcd
+client certificate of the cluster are written to kubeadm/pki, in the layout of
+/etc/kubernetes/pki. The etcd cluster installed by KET is used as an external
+etcd cluster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			planner := install.FilePlanner{File: opts.planFile}
+			if !planner.PlanExists() {
+				return planFileNotFoundErr{filename: opts.planFile}
+			}
+			plan, err := planner.Read()
+			if err != nil {
+				return fmt.Errorf("error reading plan file: %v", err)
+			}
+			dir := filepath.Join(opts.outputDir, "kubeadm")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("error creating directory %q: %v", dir, err)
+			}
+			for _, n := range plan.Master.Nodes {
+				config, err := install.KubeadmMasterConfig(*plan, n)
+				if err != nil {
+					return err
+				}
+				file := filepath.Join(dir, n.Host+".yaml")
+				if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+					return fmt.Errorf("error writing %q: %v", file, err)
+				}
+				util.PrettyPrintOk(out, "Wrote kubeadm configuration of %q to %q", n.Host, file)
+			}
+			pkiDir := filepath.Join(dir, "pki")
+			if err := install.ExportKubeadmPKI(filepath.Join(generatedAssetsDir, "keys"), pkiDir); err != nil {
+				return err
+			}
+			util.PrettyPrintOk(out, "Wrote kubeadm certificates to %q", pkiDir)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process are stored")
+	return cmd
+}
+
+func exportNodeRequirements(out io.Writer, opts *exportOpts) (*install.Plan, []install.NodeRequirements, error) {
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		util.PrettyPrintErr(out, "Reading plan file")
+		return nil, nil, fmt.Errorf("plan file %q does not exist", opts.planFile)
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		util.PrettyPrintErr(out, "Reading plan file")
+		return nil, nil, fmt.Errorf("error reading plan file: %v", err)
+	}
+	if ok, errs := install.ValidateNodes(plan.GetUniqueNodes()); !ok {
+		util.PrintValidationErrors(out, errs)
+		return nil, nil, fmt.Errorf("error validating nodes")
+	}
+	authorizedKey, err := ssh.AuthorizedKey(plan.Cluster.SSH.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting the public key of the SSH key: %v", err)
+	}
+	reqs, err := install.NodeRequirementsForPlan(*plan, opts.os, authorizedKey)
+	return plan, reqs, err
+}
@@ -28,24 +28,68 @@ func (fp *fakePlanner) Write(p *install.Plan) error {
 
 type fakeExecutor struct {
 	installCalled bool
+	prepareCalled bool
+	cleanedNodes  []string
+	resetCalled   bool
+	resetNodes    []string
+	resetOpts     install.ResetOptions
+	cordoned      []string
+	uncordoned    []string
+	drained       []string
+	drainOpts     install.DrainOptions
+	plays         []install.Play
+	playRun       string
 	err           error
 }
 
-func (fe *fakeExecutor) AddNode(p *install.Plan, newNode install.Node, roles []string, restartServices bool) (*install.Plan, error) {
-	return nil, nil
+func (fe *fakeExecutor) AddNode(p *install.Plan, newNode install.Node, roles []string, restartServices bool) (*install.Plan, install.RunSummary, error) {
+	return nil, install.RunSummary{}, nil
+}
+
+func (fe *fakeExecutor) RemoveNode(p *install.Plan, host string, drain bool) (*install.Plan, install.RunSummary, error) {
+	return nil, install.RunSummary{}, nil
+}
+
+func (fe *fakeExecutor) Cordon(p *install.Plan, host string) error {
+	fe.cordoned = append(fe.cordoned, host)
+	return fe.err
+}
+
+func (fe *fakeExecutor) Uncordon(p *install.Plan, host string) error {
+	fe.uncordoned = append(fe.uncordoned, host)
+	return fe.err
+}
+
+func (fe *fakeExecutor) Drain(p *install.Plan, host string, opts install.DrainOptions) error {
+	fe.drained = append(fe.drained, host)
+	fe.drainOpts = opts
+	return fe.err
 }
 
 func (fe *fakeExecutor) GenerateCertificates(*install.Plan, bool) error {
 	return nil
 }
 
-func (fe *fakeExecutor) Install(p *install.Plan, restartServices bool, nodes ...string) error {
+func (fe *fakeExecutor) Install(p *install.Plan, restartServices bool, nodes ...string) (install.RunSummary, error) {
 	fe.installCalled = true
+	return install.RunSummary{}, fe.err
+}
+
+func (fe *fakeExecutor) PreparePackages(p *install.Plan, nodes ...string) error {
+	fe.prepareCalled = true
 	return fe.err
 }
 
-func (fe *fakeExecutor) Reset(p *install.Plan, nodes ...string) error {
-	return nil
+func (fe *fakeExecutor) Reset(p *install.Plan, opts install.ResetOptions, nodes ...string) (install.RunSummary, error) {
+	fe.resetCalled = true
+	fe.resetNodes = nodes
+	fe.resetOpts = opts
+	return install.RunSummary{}, fe.err
+}
+
+func (fe *fakeExecutor) CleanNodes(p *install.Plan, opts install.NodeCleanOptions, nodes ...string) error {
+	fe.cleanedNodes = nodes
+	return fe.err
 }
 
 func (fe *fakeExecutor) RunPreFlightCheck(p *install.Plan, nodes ...string) error {
@@ -60,10 +104,14 @@ func (fe *fakeExecutor) RunUpgradePreFlightCheck(*install.Plan, install.Listable
 	return nil
 }
 
-func (fe *fakeExecutor) UpgradeNodes(install.Plan, []install.ListableNode, bool, int, bool) error {
+func (fe *fakeExecutor) RunUpgradePreFlightChecks(*install.Plan, []install.ListableNode, int) map[string]error {
 	return nil
 }
 
+func (fe *fakeExecutor) UpgradeNodes(install.Plan, []install.ListableNode, bool, int, bool) (install.RunSummary, error) {
+	return install.RunSummary{}, nil
+}
+
 func (fe *fakeExecutor) ValidateControlPlane(install.Plan) error {
 	return nil
 }
@@ -72,18 +120,27 @@ func (fe *fakeExecutor) UpgradeDockerRegistry(install.Plan) error {
 	return nil
 }
 
-func (fe *fakeExecutor) UpgradeClusterServices(install.Plan) error {
-	return nil
+func (fe *fakeExecutor) UpgradeClusterServices(install.Plan) (install.RunSummary, error) {
+	return install.RunSummary{}, nil
 }
 
-func (fe *fakeExecutor) RunSmokeTest(p *install.Plan) error {
-	return nil
+func (fe *fakeExecutor) RunSmokeTest(p *install.Plan) (install.RunSummary, error) {
+	return install.RunSummary{}, nil
 }
 
-func (fe *fakeExecutor) RunPlay(string, *install.Plan, bool, ...string) error {
+func (fe *fakeExecutor) RunNodeSmokeTest(p *install.Plan, host string) error {
 	return nil
 }
 
+func (fe *fakeExecutor) RunPlay(name string, p *install.Plan, restartServices bool, nodes ...string) (install.RunSummary, error) {
+	fe.playRun = name
+	return install.RunSummary{}, nil
+}
+
+func (fe *fakeExecutor) ListPlays() ([]install.Play, error) {
+	return fe.plays, nil
+}
+
 func (fe *fakeExecutor) AddVolume(*install.Plan, install.StorageVolume) error {
 	return nil
 }
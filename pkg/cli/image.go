@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+const (
+	nodeImageScriptFilename   = "kismatic-node-image.sh"
+	nodeImageTemplateFilename = "kismatic-node-image.json"
+)
+
+type imageBuildOpts struct {
+	planFile            string
+	os                  string
+	builderFile         string
+	outputDir           string
+	packerPath          string
+	templateOnly        bool
+	imagesManifestsFile string
+}
+
+// NewCmdImage creates a new image command
+func NewCmdImage(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "Build machine images for the nodes of the cluster",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewCmdImageBuild(out))
+
+	return cmd
+}
+
+// NewCmdImageBuild creates a new image build command
+func NewCmdImageBuild(out io.Writer) *cobra.Command {
+	opts := &imageBuildOpts{}
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build a machine image with the packages and container images of the cluster preloaded",
+		Long: `Build a machine image with the packages and container images of the cluster preloaded.
+
+The image is built with packer, using the builder defined in the builder file.
+The builder is a packer builder in JSON format, such as an amazon-ebs builder,
+that starts from a base image of the operating system. The image is provisioned
+with a script that installs the packages required by all the node roles, and
+pulls the container images for the Kubernetes version of the plan file.
+
+Set "prebuilt_node_image" to true in the plan file when the nodes are
+provisioned from the image, so that KET does not install the packages.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doImageBuild(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFile)
+	cmd.Flags().StringVar(&opts.os, "os", "centos", fmt.Sprintf("operating system of the base image (options %v)", install.ExportOperatingSystems))
+	cmd.Flags().StringVar(&opts.builderFile, "builder-file", "", "path to the file with the packer builder in JSON format")
+	cmd.Flags().StringVar(&opts.outputDir, "output-dir", "image", "path to the directory where the packer template and provisioning script are written")
+	cmd.Flags().StringVar(&opts.packerPath, "packer-path", "packer", "path to the packer binary")
+	cmd.Flags().BoolVar(&opts.templateOnly, "template-only", false, "only write the packer template and provisioning script, without building the image")
+	cmd.Flags().StringVar(&opts.imagesManifestsFile, "images-manifest-file", "", "path to the container images manifest file")
+	return cmd
+}
+
+func doImageBuild(out io.Writer, opts *imageBuildOpts) error {
+	util.PrintHeader(out, "Build Node Image", '=')
+	if opts.builderFile == "" {
+		return errors.New("the packer builder file must be provided using the \"--builder-file\" option")
+	}
+	planner := install.FilePlanner{File: opts.planFile}
+	if !planner.PlanExists() {
+		util.PrettyPrintErr(out, "Reading plan file")
+		return fmt.Errorf("plan file %q does not exist", opts.planFile)
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		util.PrettyPrintErr(out, "Reading plan file")
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	util.PrettyPrintOk(out, "Reading plan file %q", opts.planFile)
+
+	im, err := readImageManifest(manifestPath(opts.imagesManifestsFile), plan.Versions())
+	if err != nil {
+		return err
+	}
+	var images []string
+	for _, img := range im.OfficialImages {
+		images = append(images, img.String())
+	}
+	build, err := install.NodeImageBuildForPlan(*plan, opts.os, images)
+	if err != nil {
+		return err
+	}
+	script, err := build.Script()
+	if err != nil {
+		return err
+	}
+	builder, err := ioutil.ReadFile(opts.builderFile)
+	if err != nil {
+		return fmt.Errorf("error reading packer builder file: %v", err)
+	}
+	template, err := install.PackerTemplate(builder, nodeImageScriptFilename)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating directory %q: %v", opts.outputDir, err)
+	}
+	scriptFile := filepath.Join(opts.outputDir, nodeImageScriptFilename)
+	if err := ioutil.WriteFile(scriptFile, []byte(script), 0755); err != nil {
+		return fmt.Errorf("error writing %q: %v", scriptFile, err)
+	}
+	templateFile := filepath.Join(opts.outputDir, nodeImageTemplateFilename)
+	if err := ioutil.WriteFile(templateFile, template, 0644); err != nil {
+		return fmt.Errorf("error writing %q: %v", templateFile, err)
+	}
+	util.PrettyPrintOk(out, "Wrote packer template to %q", templateFile)
+	if opts.templateOnly {
+		return nil
+	}
+
+	if _, err := exec.LookPath(opts.packerPath); err != nil {
+		return fmt.Errorf("Did not find packer at %q. The packer CLI must be available for building the image.", opts.packerPath)
+	}
+	util.PrintHeader(out, "Running Packer", '=')
+	cmd := exec.Command(opts.packerPath, "build", nodeImageTemplateFilename)
+	cmd.Dir = opts.outputDir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error building the image with packer: %v", err)
+	}
+	util.PrintColor(out, util.Green, "\nThe node image was built successfully.\n")
+	fmt.Fprintln(out)
+	return nil
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/inspector"
+	inspectorcmd "github.com/apprenda/kismatic/pkg/inspector/cmd"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+type inspectorServeOpts struct {
+	port                        int
+	nodeRoles                   string
+	packageInstallationDisabled bool
+	dockerInstallationDisabled  bool
+	disconnectedInstallation    bool
+}
+
+type inspectorClientOpts struct {
+	target            string
+	nodeRoles         string
+	outputFormat      string
+	rulesFile         string
+	useUpgradeRules   bool
+	kubernetesVersion string
+	additionalVars    []string
+}
+
+var inspectorExample = `# On the machine to be validated, start the inspector server
+kismatic inspector serve --node-roles master,etcd
+
+# From the workstation, validate the machine as a master and etcd node
+kismatic inspector client --target 10.0.1.24:9090 --node-roles master,etcd
+`
+
+// NewCmdInspector creates a new inspector command
+func NewCmdInspector(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspector",
+		Short: "Validate individual machines against a node role",
+		Long: `Validate individual machines against a node role, before they are added to a plan file.
+
+The inspector server is run on the machine to be validated, and the checks are
+run from the workstation with the inspector client. The checks are the same
+pre-flight checks that are run when installing the cluster.`,
+		Example: inspectorExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewCmdInspectorServe(out))
+	cmd.AddCommand(NewCmdInspectorClient(out))
+
+	return cmd
+}
+
+// NewCmdInspectorServe creates a new inspector serve command
+func NewCmdInspectorServe(out io.Writer) *cobra.Command {
+	opts := &inspectorServeOpts{}
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start the inspector server on the machine to be validated",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doInspectorServe(out, opts)
+		},
+	}
+	cmd.Flags().IntVar(&opts.port, "port", 9090, "the port number for standing up the inspector server")
+	cmd.Flags().StringVar(&opts.nodeRoles, "node-roles", "", "comma-separated list of the node's roles. Valid roles are 'etcd', 'master', 'worker', 'ingress', 'storage'")
+	cmd.Flags().BoolVar(&opts.packageInstallationDisabled, "pkg-installation-disabled", false, "when true, the inspector ensures that the necessary packages are installed on the machine")
+	cmd.Flags().BoolVar(&opts.dockerInstallationDisabled, "docker-installation-disabled", false, "when true, the inspector ensures that docker is installed on the machine")
+	cmd.Flags().BoolVar(&opts.disconnectedInstallation, "disconnected-installation", false, "when true, the inspector checks for the packages required by a disconnected installation")
+	return cmd
+}
+
+func doInspectorServe(out io.Writer, opts *inspectorServeOpts) error {
+	if opts.nodeRoles == "" {
+		return errors.New("the node roles must be provided using the \"--node-roles\" option")
+	}
+	facts, err := inspectorcmd.GetNodeRoles(opts.nodeRoles)
+	if err != nil {
+		return err
+	}
+	if opts.disconnectedInstallation {
+		facts = append(facts, "disconnected")
+	}
+	s, err := inspector.NewServer(facts, opts.port, opts.packageInstallationDisabled, opts.dockerInstallationDisabled, opts.disconnectedInstallation)
+	if err != nil {
+		return fmt.Errorf("error starting inspector server: %v", err)
+	}
+	fmt.Fprintf(out, "Inspector is listening on port %d\n", opts.port)
+	fmt.Fprintf(out, "Node roles: %s\n", opts.nodeRoles)
+	fmt.Fprintf(out, "Package installation disabled: %v\n", opts.packageInstallationDisabled)
+	fmt.Fprintf(out, "Docker installation disabled: %v\n", opts.dockerInstallationDisabled)
+	fmt.Fprintf(out, "Disconnected installation: %v\n", opts.disconnectedInstallation)
+	fmt.Fprintf(out, "Run the checks from the workstation: kismatic inspector client --target [NODE_IP]:%d --node-roles %s\n", opts.port, opts.nodeRoles)
+	return s.Start()
+}
+
+// NewCmdInspectorClient creates a new inspector client command
+func NewCmdInspectorClient(out io.Writer) *cobra.Command {
+	opts := &inspectorClientOpts{}
+	cmd := &cobra.Command{
+		Use:   "client",
+		Short: "Run the checks against a machine running the inspector server",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doInspectorClient(out, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.target, "target", "", "the address of the inspector server, in the form HOST:PORT")
+	cmd.Flags().StringVar(&opts.nodeRoles, "node-roles", "", "comma-separated list of the node's roles. Must match the roles of the inspector server")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "table", "output format (options \"table\"|\"json\")")
+	cmd.Flags().StringVar(&opts.rulesFile, "rules-file", "", "path to an inspector rules file. If blank, the pre-flight rules are used")
+	cmd.Flags().BoolVar(&opts.useUpgradeRules, "upgrade", false, "use the upgrade pre-flight rules, rather than the install rules")
+	cmd.Flags().StringVar(&opts.kubernetesVersion, "kubernetes-version", "", "the Kubernetes version the packages are checked against. Defaults to the version supported by this version of KET")
+	cmd.Flags().StringSliceVar(&opts.additionalVars, "additional-vars", []string{}, "key=value pairs separated by ',' to template the rules")
+	return cmd
+}
+
+func doInspectorClient(out io.Writer, opts *inspectorClientOpts) error {
+	if opts.target == "" {
+		return errors.New("the address of the inspector server must be provided using the \"--target\" option")
+	}
+	if opts.nodeRoles == "" {
+		return errors.New("the node roles must be provided using the \"--node-roles\" option")
+	}
+	if err := inspectorcmd.ValidateOutputType(opts.outputFormat); err != nil {
+		return err
+	}
+	roles, err := inspectorcmd.GetNodeRoles(opts.nodeRoles)
+	if err != nil {
+		return err
+	}
+	vars, err := install.InspectorRuleVariables(opts.kubernetesVersion)
+	if err != nil {
+		return err
+	}
+	for _, v := range opts.additionalVars {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid key=value %q", v)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	rules, err := inspectorcmd.GetRules(out, opts.rulesFile, opts.useUpgradeRules, vars)
+	if err != nil {
+		return err
+	}
+	c, err := inspector.NewClient(opts.target, roles)
+	if err != nil {
+		return fmt.Errorf("error creating inspector client: %v", err)
+	}
+	results, err := c.ExecuteRules(rules)
+	if err != nil {
+		return fmt.Errorf("error running checks against %q: %v", opts.target, err)
+	}
+	if err := inspectorcmd.PrintResults(out, results, opts.outputFormat); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Success {
+			return withExitCode(ExitCodePreflightFailed, fmt.Errorf("%q did not pass the checks for the %s role(s)", opts.target, opts.nodeRoles))
+		}
+	}
+	return nil
+}
@@ -25,9 +25,13 @@ func NewCmdInstall(in io.Reader, out io.Writer) *cobra.Command {
 	// Subcommands
 	cmd.AddCommand(NewCmdPlan(in, out, opts))
 	cmd.AddCommand(NewCmdValidate(out, opts))
+	cmd.AddCommand(NewCmdPrepare(out, opts))
 	cmd.AddCommand(NewCmdApply(out, opts))
 	cmd.AddCommand(NewCmdAddNode(out, opts))
+	cmd.AddCommand(NewCmdRemoveNode(out, opts))
+	cmd.AddCommand(NewCmdReplaceStorageNode(out, opts))
 	cmd.AddCommand(NewCmdStep(out, opts))
+	cmd.AddCommand(NewCmdAdopt(out, opts))
 
 	// PersistentFlags
 	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFilename)
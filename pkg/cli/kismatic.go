@@ -16,13 +16,41 @@ more documentation is available at https://github.com/apprenda/kismatic`,
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
-		SilenceUsage:  true,
-		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := setupConfig(cmd); err != nil {
+				return err
+			}
+			if err := setupLogFile(cmd, args); err != nil {
+				return err
+			}
+			setupTracing(stderr, cmd)
+			setupColor(cmd)
+			return setupEventStream(stderr)
+		},
+		SilenceUsage:           true,
+		SilenceErrors:          true,
+		BashCompletionFunction: bashCompletionFunc,
 	}
+	addAssumeYesFlag(cmd)
+	addLogFileFlag(cmd)
+	addTeeFlags(cmd)
+	addLogSinkFlag(cmd)
+	addTimeoutFlags(cmd)
+	addDiagnoseOnFailureFlag(cmd)
+	addRetryFlags(cmd)
+	addShredCredentialsFlag(cmd)
+	addVaultClusterCatalogFlag(cmd)
+	addHooksFileFlag(cmd)
+	addNotificationWebhookFlag(cmd)
+	addShowProgressFlag(cmd)
+	addParallelismFlags(cmd)
+	addNoColorFlag(cmd)
+	addEventStreamFlag(cmd)
 
 	cmd.AddCommand(NewCmdVersion(buildDate, out))
 	cmd.AddCommand(NewCmdInstall(in, out))
 	cmd.AddCommand(NewCmdReset(in, out))
+	cmd.AddCommand(NewCmdNodes(in, out))
 	cmd.AddCommand(NewCmdVolume(in, out))
 	cmd.AddCommand(NewCmdIP(out))
 	cmd.AddCommand(NewCmdDashboard(in, out))
@@ -30,8 +58,22 @@ more documentation is available at https://github.com/apprenda/kismatic`,
 	cmd.AddCommand(NewCmdInfo(out))
 	cmd.AddCommand(NewCmdUpgrade(in, out))
 	cmd.AddCommand(NewCmdDiagnostic(out))
+	cmd.AddCommand(NewCmdUI(out))
+	cmd.AddCommand(NewCmdSchedule(out))
 	cmd.AddCommand(NewCmdCertificates(out))
+	cmd.AddCommand(NewCmdCredentials(out))
+	cmd.AddCommand(NewCmdConformance(out))
+	cmd.AddCommand(NewCmdCapacity(out))
 	cmd.AddCommand(NewCmdSeedRegistry(out, stderr))
+	cmd.AddCommand(NewCmdExport(out))
+	cmd.AddCommand(NewCmdImage(out))
+	cmd.AddCommand(NewCmdInspector(out))
+	cmd.AddCommand(NewCmdSelfUpdate(in, out))
+	cmd.AddCommand(NewCmdCompletion(out))
+	cmd.AddCommand(NewCmdComplete(out))
+
+	addPluginCommands(cmd, in, out, stderr)
+	annotateCompletions(cmd)
 
 	return cmd, nil
 }
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+func addLogFileFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().String("log-file", "", "path to a file where a structured (JSON) log of the kismatic process is appended, regardless of the output format")
+}
+
+// setupLogFile sends the kismatic log to the file set with --log-file
+func setupLogFile(cmd *cobra.Command, args []string) error {
+	file, _ := cmd.Flags().GetString("log-file")
+	if file == "" {
+		return nil
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening log file %q: %v", file, err)
+	}
+	logging.SetOutput(f)
+	logging.Info("running command", "command", cmd.CommandPath(), "args", os.Args[1:], "version", install.KismaticVersion)
+	return nil
+}
@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdNodes returns the nodes command
+func NewCmdNodes(in io.Reader, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Perform maintenance operations on the nodes of the cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewCmdNodesClean(in, out))
+	cmd.AddCommand(NewCmdNodesStatus(out))
+	cmd.AddCommand(NewCmdNodesCordon(out))
+	cmd.AddCommand(NewCmdNodesUncordon(out))
+	cmd.AddCommand(NewCmdNodesDrain(out))
+	return cmd
+}
+
+type nodesCleanCmd struct {
+	in        io.Reader
+	out       io.Writer
+	planner   install.Planner
+	executor  install.Executor
+	diskUsage func(p *install.Plan, host string) ([]install.DiskUsage, error)
+	assumeYes bool
+
+	// Flags
+	planFile           string
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	limit              []string
+	exclude            []string
+	opts               install.NodeCleanOptions
+}
+
+// NewCmdNodesClean returns the command for freeing disk space on the nodes
+func NewCmdNodesClean(in io.Reader, out io.Writer) *cobra.Command {
+	c := &nodesCleanCmd{in: in, out: out, diskUsage: install.NodeDiskUsage}
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "free disk space on the nodes by removing unused container images and volumes, and truncating logs",
+		Long: `Free disk space on the nodes by removing unused container images and volumes, and truncating logs.
+
+The stopped containers and the dangling images are always removed. Use the flags
+to remove all the unused images and volumes, and to truncate the container logs
+and the systemd journal. The disk usage of each node is reported before and after
+the cleanup.
+
+Removing all the unused images on clusters that do not have access to a container
+registry can prevent pods from starting on the nodes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(install.ExecutorOptions{
+				GeneratedAssetsDirectory: c.generatedAssetsDir,
+				OutputFormat:             c.outputFormat,
+				Verbose:                  c.verbose,
+			}))
+			if err != nil {
+				return err
+			}
+			c.planner = &install.FilePlanner{File: c.planFile}
+			c.executor = executor
+			c.assumeYes = assumeYes(cmd)
+			c.limit = limitWithExclusions(c.limit, c.exclude)
+			return c.run()
+		},
+	}
+	addLimitFlags(cmd.Flags(), &c.limit, &c.exclude)
+	cmd.Flags().BoolVar(&c.opts.AllImages, "all-images", false, "remove all the images that are not used by a container, instead of only the dangling images")
+	cmd.Flags().BoolVar(&c.opts.Volumes, "volumes", false, "remove the docker volumes that are not used by a container")
+	cmd.Flags().StringVar(&c.opts.ContainerLogMaxSize, "container-log-max-size", "", "truncate the container logs that are larger than the size, such as 100M or 1G")
+	cmd.Flags().StringVar(&c.opts.JournalMaxSize, "journal-max-size", "", "reduce the systemd journal to the size, such as 500M or 1G")
+	cmd.Flags().StringVar(&c.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&c.verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&c.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	addPlanFileFlag(cmd.Flags(), &c.planFile)
+	return cmd
+}
+
+func (c nodesCleanCmd) run() error {
+	if err := c.opts.Validate(); err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	if !c.planner.PlanExists() {
+		return planFileNotFoundErr{filename: c.planFile}
+	}
+	plan, err := c.planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	hosts, err := cleanHosts(plan, c.limit)
+	if err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	if c.opts.Volumes {
+		if err := confirm(c.in, c.out, c.assumeYes, "The data in the unused docker volumes will be lost, continue?"); err != nil {
+			return err
+		}
+	}
+
+	util.PrintHeader(c.out, "Disk Usage Before Cleaning", '=')
+	before := c.nodesDiskUsage(plan, hosts)
+	if err := c.executor.CleanNodes(plan, c.opts, c.limit...); err != nil {
+		return withExitCode(playbookExitCode(err), fmt.Errorf("error cleaning nodes: %v", err))
+	}
+	util.PrintHeader(c.out, "Disk Usage After Cleaning", '=')
+	after := c.nodesDiskUsage(plan, hosts)
+	printDiskUsageChanges(c.out, hosts, before, after)
+	return nil
+}
+
+// nodesDiskUsage returns the disk usage of each node. The nodes whose usage
+// cannot be read are reported, and left out.
+func (c nodesCleanCmd) nodesDiskUsage(plan *install.Plan, hosts []string) map[string][]install.DiskUsage {
+	usage := map[string][]install.DiskUsage{}
+	for _, h := range hosts {
+		u, err := c.diskUsage(plan, h)
+		if err != nil {
+			util.PrettyPrintWarn(c.out, "Could not get the disk usage of node %q: %v", h, err)
+			continue
+		}
+		util.PrettyPrintOk(c.out, "Read the disk usage of node %q", h)
+		usage[h] = u
+	}
+	return usage
+}
+
+// cleanHosts returns the hosts that are cleaned, which are all the nodes of
+// the plan unless the limit is set
+func cleanHosts(plan *install.Plan, limit []string) ([]string, error) {
+	if len(limit) > 0 {
+		return plan.ResolveLimit(limit)
+	}
+	var all []string
+	for _, n := range plan.GetUniqueNodes() {
+		all = append(all, n.Host)
+	}
+	return all, nil
+}
+
+func printDiskUsageChanges(out io.Writer, hosts []string, before, after map[string][]install.DiskUsage) {
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tMOUNT\tUSED BEFORE\tUSED AFTER\tFREED")
+	for _, h := range hosts {
+		for _, b := range before[h] {
+			for _, a := range after[h] {
+				if a.Mount != b.Mount {
+					continue
+				}
+				var freed uint64
+				if b.UsedKB > a.UsedKB {
+					freed = b.UsedKB - a.UsedKB
+				}
+				fmt.Fprintf(w, "%s\t%s\t%.1f%%\t%.1f%%\t%d MB\n", h, b.Mount, b.UsedPercent(), a.UsedPercent(), freed/1024)
+			}
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+// the maintenance operations of a node
+const (
+	nodeCordon   = "cordon"
+	nodeUncordon = "uncordon"
+	nodeDrain    = "drain"
+)
+
+type nodesMaintenanceCmd struct {
+	out       io.Writer
+	planner   install.Planner
+	executor  install.Executor
+	operation string
+
+	// Flags
+	planFile           string
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	drainOpts          install.DrainOptions
+}
+
+// NewCmdNodesCordon returns the command for marking a node as unschedulable
+func NewCmdNodesCordon(out io.Writer) *cobra.Command {
+	return newNodesMaintenanceCmd(out, nodeCordon, "mark a node as unschedulable", `Mark a node as unschedulable, so that no new pods are scheduled on it.
+
+kubectl is run on a master node, using the SSH configuration of the plan file.`)
+}
+
+// NewCmdNodesUncordon returns the command for marking a node as schedulable
+func NewCmdNodesUncordon(out io.Writer) *cobra.Command {
+	return newNodesMaintenanceCmd(out, nodeUncordon, "mark a node as schedulable", `Mark a node as schedulable, once its maintenance is done.
+
+kubectl is run on a master node, using the SSH configuration of the plan file.`)
+}
+
+// NewCmdNodesDrain returns the command for evicting the pods of a node
+func NewCmdNodesDrain(out io.Writer) *cobra.Command {
+	return newNodesMaintenanceCmd(out, nodeDrain, "cordon a node and evict its pods before its maintenance", `Cordon a node and evict its pods before its maintenance, such as kernel patching
+or a hardware swap. The pods of the daemon sets are not evicted. Use 'kismatic
+nodes uncordon' once the maintenance is done.
+
+kubectl is run on a master node, using the SSH configuration of the plan file.`)
+}
+
+func newNodesMaintenanceCmd(out io.Writer, operation, short, long string) *cobra.Command {
+	c := &nodesMaintenanceCmd{out: out, operation: operation}
+	cmd := &cobra.Command{
+		Use:   operation + " NODE_NAME",
+		Short: short,
+		Long:  long,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(install.ExecutorOptions{
+				GeneratedAssetsDirectory: c.generatedAssetsDir,
+				OutputFormat:             c.outputFormat,
+				Verbose:                  c.verbose,
+			}))
+			if err != nil {
+				return err
+			}
+			c.planner = &install.FilePlanner{File: c.planFile}
+			c.executor = executor
+			return c.run(args[0])
+		},
+	}
+	if operation == nodeDrain {
+		cmd.Flags().DurationVar(&c.drainOpts.Timeout, "timeout", 5*time.Minute, "maximum time to wait for the pods to be evicted")
+		cmd.Flags().BoolVar(&c.drainOpts.Force, "force", false, "evict the pods that are not managed by a controller, which are not recreated")
+		cmd.Flags().BoolVar(&c.drainOpts.DeleteLocalData, "delete-local-data", false, "evict the pods that use emptyDir volumes, whose data is lost")
+	}
+	cmd.Flags().StringVar(&c.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&c.verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&c.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	addPlanFileFlag(cmd.Flags(), &c.planFile)
+	return cmd
+}
+
+func (c nodesMaintenanceCmd) run(host string) error {
+	if err := c.drainOpts.Validate(); err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	if !c.planner.PlanExists() {
+		return planFileNotFoundErr{filename: c.planFile}
+	}
+	plan, err := c.planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	if !plan.HostExists(host) {
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("node %q was not found in the plan", host))
+	}
+	switch c.operation {
+	case nodeCordon:
+		err = c.executor.Cordon(plan, host)
+	case nodeUncordon:
+		err = c.executor.Uncordon(plan, host)
+	case nodeDrain:
+		err = c.executor.Drain(plan, host, c.drainOpts)
+	}
+	if err != nil {
+		return withExitCode(playbookExitCode(err), fmt.Errorf("error running %s on node %q: %v", c.operation, host, err))
+	}
+	return nil
+}
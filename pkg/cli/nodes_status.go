@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type nodesStatusCmd struct {
+	out         io.Writer
+	planner     install.Planner
+	nodesStatus func(p *install.Plan) install.NodesReport
+
+	// Flags
+	planFile     string
+	outputFormat string
+	patch        bool
+}
+
+// NewCmdNodesStatus returns the command that reports the status of the nodes
+func NewCmdNodesStatus(out io.Writer) *cobra.Command {
+	c := &nodesStatusCmd{out: out, nodesStatus: install.NodesStatus}
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "check that the nodes of the plan are reachable and registered in the cluster, and report the nodes of the cluster that are not in the plan",
+		Long: `Check that the nodes of the plan accept SSH connections and that their kubelet
+registered them in the cluster, and report the nodes registered in the cluster
+that are not in the plan.
+
+With --patch, a JSON patch (RFC 6902) that reconciles the plan with the cluster
+is written instead: the nodes of the cluster that are not in the plan are added
+as worker nodes, and the worker, ingress and storage nodes that are not
+registered are removed. Review the patch before applying it to the plan.
+
+The command fails when a node is unreachable, not registered or not ready, or
+when the cluster has nodes that are not in the plan.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			c.planner = &install.FilePlanner{File: c.planFile}
+			return c.run()
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &c.planFile)
+	cmd.Flags().StringVarP(&c.outputFormat, "output", "o", "table", `output format (options "table"|"json")`)
+	cmd.Flags().BoolVar(&c.patch, "patch", false, "write a JSON patch that reconciles the plan with the nodes registered in the cluster")
+	return cmd
+}
+
+func (c nodesStatusCmd) run() error {
+	if c.outputFormat != "table" && c.outputFormat != "json" {
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("output format %q is not supported", c.outputFormat))
+	}
+	if !c.planner.PlanExists() {
+		return planFileNotFoundErr{filename: c.planFile}
+	}
+	plan, err := c.planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	report := c.nodesStatus(plan)
+	if c.patch {
+		if report.ClusterError != "" {
+			return fmt.Errorf("cannot reconcile the plan with the cluster: %s", report.ClusterError)
+		}
+		b, err := json.MarshalIndent(report.PlanPatch(plan), "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling patch: %v", err)
+		}
+		fmt.Fprintln(c.out, string(b))
+		return nil
+	}
+	if c.outputFormat == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling status: %v", err)
+		}
+		fmt.Fprintln(c.out, string(b))
+	} else {
+		printNodesStatus(c.out, report)
+	}
+	unhealthy := 0
+	for _, s := range report.Nodes {
+		if !s.Healthy() {
+			unhealthy++
+		}
+	}
+	if report.ClusterError != "" {
+		return errors.New(report.ClusterError)
+	}
+	if unhealthy > 0 {
+		return fmt.Errorf("%d node(s) are unreachable, not ready, or do not match the plan", unhealthy)
+	}
+	return nil
+}
+
+func printNodesStatus(out io.Writer, report install.NodesReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tIP\tROLES\tIN PLAN\tSSH\tKUBELET")
+	for _, s := range report.Nodes {
+		inPlan, ssh, kubelet := "yes", "ok", "-"
+		if !s.InPlan {
+			inPlan, ssh = "no", "-"
+		} else if !s.Reachable {
+			ssh = "unreachable"
+		}
+		switch {
+		case s.Registered && s.Ready:
+			kubelet = "ready"
+		case s.Registered:
+			kubelet = "not ready"
+		case s.KubeletExpected && report.ClusterError == "":
+			kubelet = "not registered"
+		case s.KubeletExpected:
+			kubelet = "unknown"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Host, s.IP, strings.Join(s.Roles, ","), inPlan, ssh, kubelet)
+	}
+	w.Flush()
+	for _, s := range report.Nodes {
+		if s.SSHError != "" {
+			util.PrettyPrintWarn(out, "Could not connect to node %q: %s", s.Host, s.SSHError)
+		}
+	}
+	if report.ClusterError != "" {
+		util.PrettyPrintWarn(out, "The registration of the nodes is unknown: %s", report.ClusterError)
+	}
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+func TestNodesCleanReportsDiskUsage(t *testing.T) {
+	out := &bytes.Buffer{}
+	fp := &fakePlanner{
+		exists: true,
+		plan: &install.Plan{
+			Worker: install.NodeGroup{Nodes: []install.Node{{Host: "worker01"}, {Host: "worker02"}}},
+		},
+	}
+	fe := &fakeExecutor{}
+	calls := 0
+	c := nodesCleanCmd{
+		out:      out,
+		planner:  fp,
+		executor: fe,
+		limit:    []string{"worker02"},
+		diskUsage: func(p *install.Plan, host string) ([]install.DiskUsage, error) {
+			calls++
+			used := uint64(900 * 1024)
+			if fe.cleanedNodes != nil {
+				used = 400 * 1024
+			}
+			return []install.DiskUsage{{Mount: "/", SizeKB: 1000 * 1024, UsedKB: used}}, nil
+		},
+	}
+	if err := c.run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fe.cleanedNodes) != 1 || fe.cleanedNodes[0] != "worker02" {
+		t.Errorf("expected worker02 to be cleaned, but got %v", fe.cleanedNodes)
+	}
+	if calls != 2 {
+		t.Errorf("expected the disk usage to be read twice, but was read %d times", calls)
+	}
+	if !strings.Contains(out.String(), "90.0%") || !strings.Contains(out.String(), "40.0%") || !strings.Contains(out.String(), "500 MB") {
+		t.Errorf("expected the disk usage changes to be reported, but got:\n%s", out.String())
+	}
+}
+
+func TestNodesCleanUnknownNode(t *testing.T) {
+	fp := &fakePlanner{exists: true, plan: &install.Plan{Worker: install.NodeGroup{Nodes: []install.Node{{Host: "worker01"}}}}}
+	fe := &fakeExecutor{}
+	c := nodesCleanCmd{out: &bytes.Buffer{}, planner: fp, executor: fe, limit: []string{"worker09"}}
+	if err := c.run(); err == nil {
+		t.Error("expected an error when cleaning a node that is not in the plan")
+	}
+	if fe.cleanedNodes != nil {
+		t.Error("expected no nodes to be cleaned")
+	}
+}
+
+func TestNodesCleanVolumesRequiresConfirmation(t *testing.T) {
+	fp := &fakePlanner{exists: true, plan: &install.Plan{Worker: install.NodeGroup{Nodes: []install.Node{{Host: "worker01"}}}}}
+	fe := &fakeExecutor{}
+	c := nodesCleanCmd{in: strings.NewReader("n\n"), out: &bytes.Buffer{}, planner: fp, executor: fe}
+	c.opts.Volumes = true
+	if err := c.run(); err == nil {
+		t.Error("expected an error when the cleanup is not confirmed")
+	}
+	if fe.cleanedNodes != nil {
+		t.Error("expected no nodes to be cleaned")
+	}
+}
+
+func TestNodesStatusReportsUnhealthyNodes(t *testing.T) {
+	out := &bytes.Buffer{}
+	fp := &fakePlanner{exists: true, plan: &install.Plan{}}
+	c := nodesStatusCmd{
+		out:          out,
+		planner:      fp,
+		outputFormat: "table",
+		nodesStatus: func(p *install.Plan) install.NodesReport {
+			return install.NodesReport{Nodes: []install.NodeStatus{
+				{Host: "worker01", IP: "10.0.0.1", Roles: []string{"worker"}, InPlan: true, Reachable: true, KubeletExpected: true, Registered: true, Ready: true},
+				{Host: "worker02", IP: "10.0.0.2", Roles: []string{"worker"}, InPlan: true, SSHError: "connection refused", KubeletExpected: true},
+				{Host: "worker09", IP: "10.0.0.9", KubeletExpected: true, Registered: true, Ready: true},
+			}}
+		},
+	}
+	if err := c.run(); err == nil {
+		t.Error("expected an error when nodes are unhealthy")
+	}
+	for _, expected := range []string{"worker01  10.0.0.1  worker  yes      ok           ready", "unreachable", "not registered", "worker09  10.0.0.9          no", "connection refused"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+
+	out.Reset()
+	c.patch = true
+	if err := c.run(); err != nil {
+		t.Errorf("unexpected error writing the patch: %v", err)
+	}
+	if !strings.Contains(out.String(), `"path": "/worker/nodes/-"`) {
+		t.Errorf("expected the patch to add worker09, got:\n%s", out.String())
+	}
+}
+
+func TestNodesMaintenance(t *testing.T) {
+	plan := &install.Plan{Worker: install.NodeGroup{Nodes: []install.Node{{Host: "worker01"}}}}
+	for _, op := range []string{nodeCordon, nodeUncordon, nodeDrain} {
+		fe := &fakeExecutor{}
+		c := nodesMaintenanceCmd{out: &bytes.Buffer{}, planner: &fakePlanner{exists: true, plan: plan}, executor: fe, operation: op}
+		if err := c.run("worker01"); err != nil {
+			t.Errorf("%s: unexpected error: %v", op, err)
+		}
+		nodes := map[string][]string{nodeCordon: fe.cordoned, nodeUncordon: fe.uncordoned, nodeDrain: fe.drained}
+		if len(nodes[op]) != 1 || nodes[op][0] != "worker01" {
+			t.Errorf("%s: expected worker01 to be maintained, got %v", op, nodes[op])
+		}
+		if err := c.run("worker09"); err == nil {
+			t.Errorf("%s: expected an error for a node that is not in the plan", op)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"io"
 
 	"github.com/apprenda/kismatic/pkg/install"
-	"github.com/apprenda/kismatic/pkg/util"
 	"github.com/spf13/cobra"
 )
 
@@ -19,17 +18,17 @@ func NewCmdPlan(in io.Reader, out io.Writer, options *installOpts) *cobra.Comman
 				return fmt.Errorf("Unexpected args: %v", args)
 			}
 			planner := &install.FilePlanner{File: options.planFilename}
-			return doPlan(in, out, planner, options.planFilename)
+			return doPlan(in, out, planner, options.planFilename, assumeYes(cmd))
 		},
 	}
 
 	return cmd
 }
 
-func doPlan(in io.Reader, out io.Writer, planner install.Planner, planFile string) error {
+func doPlan(in io.Reader, out io.Writer, planner install.Planner, planFile string, yes bool) error {
 	fmt.Fprintln(out, "Plan your Kubernetes cluster:")
 
-	etcdNodes, err := util.PromptForInt(in, out, "Number of etcd nodes", 3)
+	etcdNodes, err := promptForInt(in, out, yes, "Number of etcd nodes", 3)
 	if err != nil {
 		return fmt.Errorf("Error reading number of etcd nodes: %v", err)
 	}
@@ -37,7 +36,7 @@ func doPlan(in io.Reader, out io.Writer, planner install.Planner, planFile strin
 		return fmt.Errorf("The number of etcd nodes must be greater than zero")
 	}
 
-	masterNodes, err := util.PromptForInt(in, out, "Number of master nodes", 2)
+	masterNodes, err := promptForInt(in, out, yes, "Number of master nodes", 2)
 	if err != nil {
 		return fmt.Errorf("Error reading number of master nodes: %v", err)
 	}
@@ -45,7 +44,7 @@ func doPlan(in io.Reader, out io.Writer, planner install.Planner, planFile strin
 		return fmt.Errorf("The number of master nodes must be greater than zero")
 	}
 
-	workerNodes, err := util.PromptForInt(in, out, "Number of worker nodes", 3)
+	workerNodes, err := promptForInt(in, out, yes, "Number of worker nodes", 3)
 	if err != nil {
 		return fmt.Errorf("Error reading number of worker nodes: %v", err)
 	}
@@ -53,7 +52,7 @@ func doPlan(in io.Reader, out io.Writer, planner install.Planner, planFile strin
 		return fmt.Errorf("The number of worker nodes must be greater than zero")
 	}
 
-	ingressNodes, err := util.PromptForInt(in, out, "Number of ingress nodes (optional, set to 0 if not required)", 2)
+	ingressNodes, err := promptForInt(in, out, yes, "Number of ingress nodes (optional, set to 0 if not required)", 2)
 	if err != nil {
 		return fmt.Errorf("Error reading number of ingress nodes: %v", err)
 	}
@@ -61,7 +60,7 @@ func doPlan(in io.Reader, out io.Writer, planner install.Planner, planFile strin
 		return fmt.Errorf("The number of ingress nodes must be greater than or equal to zero")
 	}
 
-	storageNodes, err := util.PromptForInt(in, out, "Number of storage nodes (optional, set to 0 if not required)", 0)
+	storageNodes, err := promptForInt(in, out, yes, "Number of storage nodes (optional, set to 0 if not required)", 0)
 	if err != nil {
 		return fmt.Errorf("Error reading number of storage nodes: %v", err)
 	}
@@ -69,7 +68,7 @@ func doPlan(in io.Reader, out io.Writer, planner install.Planner, planFile strin
 		return fmt.Errorf("The number of storage nodes must be greater than or equal to zero")
 	}
 
-	files, err := util.PromptForInt(in, out, "Number of existing files or directories to be copied", 0)
+	files, err := promptForInt(in, out, yes, "Number of existing files or directories to be copied", 0)
 	if err != nil {
 		return fmt.Errorf("Error reading number of files or directories: %v", err)
 	}
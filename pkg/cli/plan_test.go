@@ -80,7 +80,7 @@ func TestPlanCmdPlanNotFound(t *testing.T) {
 			exists: true,
 		}
 
-		err := doPlan(test.in, out, fp, "")
+		err := doPlan(test.in, out, fp, "", false)
 
 		if err != nil && !test.shouldError {
 			t.Errorf("unexpected error running command: %v", err)
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// pluginPrefix is the prefix of the executables on the PATH that are
+// exposed as kismatic subcommands
+const pluginPrefix = "kismatic-"
+
+// Environment variables set when running a plugin
+const (
+	pluginPlanFileEnv           = "KISMATIC_PLAN_FILE"
+	pluginGeneratedAssetsDirEnv = "KISMATIC_GENERATED_ASSETS_DIR"
+	pluginKubeconfigEnv         = "KUBECONFIG"
+)
+
+type plugin struct {
+	name string
+	path string
+}
+
+// findPlugins returns the plugins found in the directories of the given path
+// list. When the same plugin is found in multiple directories, the first one wins.
+func findPlugins(pathList string) []plugin {
+	var plugins []plugin
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasPrefix(f.Name(), pluginPrefix) || f.Mode()&0111 == 0 {
+				continue
+			}
+			name := strings.TrimPrefix(f.Name(), pluginPrefix)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, plugin{name: name, path: filepath.Join(dir, f.Name())})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].name < plugins[j].name })
+	return plugins
+}
+
+// addPluginCommands adds a subcommand for each plugin found on the PATH.
+// Plugins cannot override the built-in commands.
+func addPluginCommands(root *cobra.Command, in io.Reader, out, stderr io.Writer) {
+	for _, p := range findPlugins(os.Getenv("PATH")) {
+		if cmd, _, err := root.Find([]string{p.name}); err == nil && cmd != root {
+			continue
+		}
+		root.AddCommand(newPluginCommand(p, in, out, stderr))
+	}
+}
+
+func newPluginCommand(p plugin, in io.Reader, out, stderr io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:   p.name,
+		Short: fmt.Sprintf("Run the %q plugin", p.path),
+		Long: fmt.Sprintf(`Run the %q plugin.
+
+All arguments are passed to the plugin. The path to the plan file, the generated
+assets directory and the cluster's kubeconfig are exposed to the plugin through
+the %s, %s and %s environment variables.
+The --plan-file and --generated-assets-dir flags are honored when they are passed.`, p.path, pluginPlanFileEnv, pluginGeneratedAssetsDirEnv, pluginKubeconfigEnv),
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := pluginEnv(args)
+			if err != nil {
+				return err
+			}
+			c := exec.Command(p.path, args...)
+			c.Stdin = in
+			c.Stdout = out
+			c.Stderr = stderr
+			c.Env = append(os.Environ(), env...)
+			err = c.Run()
+			// The plugin is responsible for reporting its own errors, so
+			// only its exit code is propagated
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+					return withExitCode(status.ExitStatus(), nil)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("error running plugin %q: %v", p.name, err)
+			}
+			return nil
+		},
+	}
+}
+
+// pluginEnv returns the environment variables for running a plugin with the
+// given arguments.
+func pluginEnv(args []string) ([]string, error) {
+	planFile := flagValue(args, "kismatic-cluster.yaml", "--plan-file", "-f")
+	generatedDir := flagValue(args, "generated", "--generated-assets-dir")
+	planFile, err := filepath.Abs(planFile)
+	if err != nil {
+		return nil, fmt.Errorf("error getting absolute path of plan file: %v", err)
+	}
+	generatedDir, err = filepath.Abs(generatedDir)
+	if err != nil {
+		return nil, fmt.Errorf("error getting absolute path of generated assets directory: %v", err)
+	}
+	return []string{
+		fmt.Sprintf("%s=%s", pluginPlanFileEnv, planFile),
+		fmt.Sprintf("%s=%s", pluginGeneratedAssetsDirEnv, generatedDir),
+		fmt.Sprintf("%s=%s", pluginKubeconfigEnv, filepath.Join(generatedDir, "kubeconfig")),
+	}, nil
+}
+
+// flagValue returns the value of the first flag found in args with one of the
+// given names, or the default value if none is found.
+func flagValue(args []string, def string, names ...string) string {
+	for i, a := range args {
+		for _, n := range names {
+			if a == n && i+1 < len(args) {
+				return args[i+1]
+			}
+			if strings.HasPrefix(a, n+"=") {
+				return strings.TrimPrefix(a, n+"=")
+			}
+		}
+	}
+	return def
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindPlugins(t *testing.T) {
+	dir1, err := ioutil.TempDir("", "kismatic-plugins")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir1)
+	dir2, err := ioutil.TempDir("", "kismatic-plugins")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir2)
+
+	files := []struct {
+		dir  string
+		name string
+		mode os.FileMode
+	}{
+		{dir: dir1, name: "kismatic-backup", mode: 0755},
+		{dir: dir1, name: "kismatic-notexecutable", mode: 0644},
+		{dir: dir1, name: "kubectl", mode: 0755},
+		{dir: dir2, name: "kismatic-backup", mode: 0755},
+		{dir: dir2, name: "kismatic-audit", mode: 0755},
+	}
+	for _, f := range files {
+		if err := ioutil.WriteFile(filepath.Join(f.dir, f.name), []byte("#!/bin/sh\n"), f.mode); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+	}
+
+	plugins := findPlugins(strings.Join([]string{dir1, dir2, "/does/not/exist"}, string(os.PathListSeparator)))
+	expected := []plugin{
+		{name: "audit", path: filepath.Join(dir2, "kismatic-audit")},
+		{name: "backup", path: filepath.Join(dir1, "kismatic-backup")},
+	}
+	if len(plugins) != len(expected) {
+		t.Fatalf("expected plugins %v, but got %v", expected, plugins)
+	}
+	for i := range expected {
+		if plugins[i] != expected[i] {
+			t.Errorf("expected plugin %v, but got %v", expected[i], plugins[i])
+		}
+	}
+}
+
+func TestFlagValue(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{}, expected: "default"},
+		{args: []string{"--plan-file", "my-plan.yaml"}, expected: "my-plan.yaml"},
+		{args: []string{"-f", "my-plan.yaml", "other"}, expected: "my-plan.yaml"},
+		{args: []string{"--plan-file=my-plan.yaml"}, expected: "my-plan.yaml"},
+		{args: []string{"--plan-file"}, expected: "default"},
+	}
+	for _, test := range tests {
+		if v := flagValue(test.args, "default", "--plan-file", "-f"); v != test.expected {
+			t.Errorf("args %v: expected %q, but got %q", test.args, test.expected, v)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	root, err := NewKismaticCommand("", "", &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("error creating command: %v", err)
+	}
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		out := &bytes.Buffer{}
+		if err := doCompletion(out, root, shell); err != nil {
+			t.Errorf("error generating %s completion: %v", shell, err)
+		}
+		if !strings.Contains(out.String(), "__kismatic_complete") {
+			t.Errorf("%s completion does not complete nodes and steps", shell)
+		}
+	}
+	if err := doCompletion(&bytes.Buffer{}, root, "powershell"); err == nil {
+		t.Errorf("expected an error for an unsupported shell")
+	}
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type prepareCmd struct {
+	out      io.Writer
+	planFile string
+	planner  install.Planner
+	executor install.Executor
+
+	// Flags
+	generatedAssetsDir string
+	verbose            bool
+	outputFormat       string
+	limit              []string
+	exclude            []string
+	timings            bool
+	showTasks          string
+	hideTasks          string
+}
+
+// NewCmdPrepare returns the prepare command
+func NewCmdPrepare(out io.Writer, opts *installOpts) *cobra.Command {
+	prepareCmd := &prepareCmd{out: out}
+	cmd := &cobra.Command{
+		Use:   "prepare",
+		Short: "download the packages and container images required by the nodes, without installing them",
+		Long: `Download the packages and container images required by the nodes, without installing them.
+
+The packages are downloaded to the cache of the package manager of each node,
+and the container images are pulled on the nodes where docker is running. The
+installation or upgrade can then be run in a shorter maintenance window. When
+preparing an upgrade, the plan file must be set to the new version.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			execOpts := install.ExecutorOptions{
+				GeneratedAssetsDirectory: prepareCmd.generatedAssetsDir,
+				OutputFormat:             prepareCmd.outputFormat,
+				Verbose:                  prepareCmd.verbose,
+				Timings:                  prepareCmd.timings,
+				ShowTasks:                prepareCmd.showTasks,
+				HideTasks:                prepareCmd.hideTasks,
+			}
+			executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(execOpts))
+			if err != nil {
+				return err
+			}
+			prepareCmd.planFile = opts.planFilename
+			prepareCmd.planner = &install.FilePlanner{File: prepareCmd.planFile}
+			prepareCmd.executor = executor
+			prepareCmd.limit = limitWithExclusions(prepareCmd.limit, prepareCmd.exclude)
+			return prepareCmd.run()
+		},
+	}
+	addLimitFlags(cmd.Flags(), &prepareCmd.limit, &prepareCmd.exclude)
+	cmd.Flags().StringVar(&prepareCmd.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&prepareCmd.verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&prepareCmd.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	addTimingsFlag(cmd.Flags(), &prepareCmd.timings)
+	addTaskFilterFlags(cmd.Flags(), &prepareCmd.showTasks, &prepareCmd.hideTasks)
+	return cmd
+}
+
+func (c prepareCmd) run() error {
+	valOpts := &validateOpts{
+		planFile:           c.planFile,
+		verbose:            c.verbose,
+		outputFormat:       c.outputFormat,
+		skipPreFlight:      true,
+		generatedAssetsDir: c.generatedAssetsDir,
+		limit:              c.limit,
+	}
+	if err := doValidate(c.out, c.planner, valOpts); err != nil {
+		return err
+	}
+	plan, err := c.planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+	if err := c.executor.PreparePackages(plan, c.limit...); err != nil {
+		return withExitCode(playbookExitCode(err), err)
+	}
+	util.PrintColor(c.out, util.Green, "\nThe packages and images were downloaded successfully\n\n")
+	return nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+func TestPrepareCmdInvalidPlanFound(t *testing.T) {
+	out := &bytes.Buffer{}
+	fp := &fakePlanner{
+		exists: true,
+		plan:   &install.Plan{},
+	}
+	fe := &fakeExecutor{}
+
+	prepareCmd := &prepareCmd{
+		out:      out,
+		planner:  fp,
+		executor: fe,
+	}
+
+	if err := prepareCmd.run(); err == nil {
+		t.Error("expected error due to invalid plan, but did not get one")
+	}
+	if fe.prepareCalled {
+		t.Error("prepare was called with an invalid plan")
+	}
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+type removeNodeOpts struct {
+	drain                    bool
+	generatedAssetsDirectory string
+	outputFormat             string
+	verbose                  bool
+	timings                  bool
+	showTasks                string
+	hideTasks                string
+}
+
+// NewCmdRemoveNode returns the command for removing a worker node from the cluster
+func NewCmdRemoveNode(out io.Writer, installOpts *installOpts) *cobra.Command {
+	opts := &removeNodeOpts{}
+	cmd := &cobra.Command{
+		Use:     "remove-node NODE_NAME",
+		Short:   "remove a worker node from an existing Kubernetes cluster",
+		Aliases: []string{"remove-worker"},
+		Long: `Remove a worker node from an existing Kubernetes cluster.
+
+The node is cordoned and drained, deleted from the Kubernetes API, and reset.
+The plan file is updated once the node is removed. Only the nodes that are
+workers, or workers and ingress nodes, can be removed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return cmd.Usage()
+			}
+			return doRemoveNode(out, installOpts.planFilename, opts, args[0])
+		},
+	}
+	cmd.Flags().BoolVar(&opts.drain, "drain", true, "evict the pods of the node before it is removed")
+	cmd.Flags().StringVar(&opts.generatedAssetsDirectory, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	addTimingsFlag(cmd.Flags(), &opts.timings)
+	addTaskFilterFlags(cmd.Flags(), &opts.showTasks, &opts.hideTasks)
+	return cmd
+}
+
+func doRemoveNode(out io.Writer, planFile string, opts *removeNodeOpts, host string) error {
+	planner := &install.FilePlanner{File: planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read plan file: %v", err)
+	}
+	if _, err := install.RemoveNodeFromPlan(*plan, host); err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	execOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDirectory,
+		OutputFormat:             opts.outputFormat,
+		Verbose:                  opts.verbose,
+		Timings:                  opts.timings,
+		ShowTasks:                opts.showTasks,
+		HideTasks:                opts.hideTasks,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(execOpts))
+	if err != nil {
+		return err
+	}
+	updatedPlan, summary, err := executor.RemoveNode(plan, host, opts.drain)
+	if err != nil {
+		install.PrintRunSummary(out, summary)
+		return withExitCode(playbookExitCode(err), err)
+	}
+	if err := planner.Write(updatedPlan); err != nil {
+		return fmt.Errorf("error updating plan file to remove the node: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type replaceStorageNodeOpts struct {
+	GeneratedAssetsDirectory string
+	OutputFormat             string
+	Verbose                  bool
+	SkipPreFlight            bool
+	Force                    bool
+	HealTimeout              time.Duration
+}
+
+// NewCmdReplaceStorageNode returns the command for replacing a failed storage node
+func NewCmdReplaceStorageNode(out io.Writer, installOpts *installOpts) *cobra.Command {
+	opts := &replaceStorageNodeOpts{}
+	cmd := &cobra.Command{
+		Use:   "replace-storage-node OLD_NODE_NAME NEW_NODE_NAME NEW_NODE_IP [NEW_NODE_INTERNAL_IP]",
+		Short: "replace a failed storage node with a new node",
+		Long: `Replace a failed storage node with a new node.
+
+The new node is added to the cluster as a storage node, and the bricks of the
+failed node are replaced by bricks on the new node. The data of the bricks is
+restored from the other replicas of the volumes, and the failed node is removed
+from the storage cluster and from the plan file once all the volumes have healed.
+
+If the replacement fails, it can be resumed by running the same command again.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 3 || len(args) > 4 {
+				return cmd.Usage()
+			}
+			newNode := install.Node{
+				Host: args[1],
+				IP:   args[2],
+			}
+			if len(args) == 4 {
+				newNode.InternalIP = args[3]
+			}
+			return doReplaceStorageNode(out, installOpts.planFilename, opts, args[0], newNode)
+		},
+	}
+	cmd.Flags().StringVar(&opts.GeneratedAssetsDirectory, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
+	cmd.Flags().BoolVar(&opts.Verbose, "verbose", false, "enable verbose logging from the installation")
+	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	cmd.Flags().BoolVar(&opts.SkipPreFlight, "skip-preflight", false, "skip pre-flight checks on the new node")
+	cmd.Flags().DurationVar(&opts.HealTimeout, "heal-timeout", 2*time.Hour, "maximum time to wait for the volumes to heal")
+	addForceVersionFlag(cmd.Flags(), &opts.Force)
+	return cmd
+}
+
+func doReplaceStorageNode(out io.Writer, planFile string, opts *replaceStorageNodeOpts, oldHost string, newNode install.Node) error {
+	planner := &install.FilePlanner{File: planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read plan file: %v", err)
+	}
+	var oldNode *install.Node
+	for i, n := range plan.Storage.Nodes {
+		if n.Host == oldHost {
+			oldNode = &plan.Storage.Nodes[i]
+		}
+	}
+	if oldNode == nil {
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("%q is not a storage node according to the plan file", oldHost))
+	}
+	if oldNode.Host == newNode.Host {
+		return withExitCode(ExitCodeValidationFailed, errors.New("the new node must be different from the node that is replaced"))
+	}
+	if _, errs := install.ValidateNode(&newNode); errs != nil {
+		util.PrintValidationErrors(out, errs)
+		return withExitCode(ExitCodeValidationFailed, errors.New("information provided about the new node is invalid"))
+	}
+	if err = checkVersionCompatibility(out, plan, install.OperationAddNode, opts.Force); err != nil {
+		return err
+	}
+
+	// The new node is already a storage node when resuming a replacement
+	resuming := false
+	for _, n := range plan.Storage.Nodes {
+		if n.Equal(newNode) {
+			resuming = true
+		}
+	}
+	if resuming {
+		util.PrettyPrintOk(out, "Node %q is already a storage node, resuming the replacement of %q", newNode.Host, oldHost)
+	} else {
+		if err := addReplacementStorageNode(out, planner, plan, opts, oldHost, newNode); err != nil {
+			return err
+		}
+	}
+
+	util.PrintHeader(out, "Replacing Bricks Of Failed Storage Node", '=')
+	replacement := install.BrickReplacement{
+		Out:          out,
+		HealTimeout:  opts.HealTimeout,
+		PollInterval: 10 * time.Second,
+	}
+	if err := replacement.Run(plan, *oldNode, newNode); err != nil {
+		return fmt.Errorf("error replacing storage node %q: %v", oldHost, err)
+	}
+	updatedPlan := install.ReplaceStorageNodeInPlan(*plan, oldHost, newNode)
+	if err := planner.Write(&updatedPlan); err != nil {
+		return fmt.Errorf("error updating plan file to remove the replaced node: %v", err)
+	}
+	util.PrintColor(out, util.Green, "\nStorage node %q was replaced by %q successfully!\n\n", oldHost, newNode.Host)
+	return nil
+}
+
+// addReplacementStorageNode adds the new node to the cluster as a storage node,
+// and adds it to the plan file, which still contains the failed node until its
+// bricks are replaced
+func addReplacementStorageNode(out io.Writer, planner install.Planner, plan *install.Plan, opts *replaceStorageNodeOpts, oldHost string, newNode install.Node) error {
+	// The failed node is left out of the inventory, as it might not be reachable
+	planWithoutOld := install.RemoveStorageNodeFromPlan(*plan, oldHost)
+	validatePlan := install.AddNodeToPlan(planWithoutOld, newNode, []string{"storage"})
+	if _, errs := install.ValidatePlan(&validatePlan); errs != nil {
+		util.PrintValidationErrors(out, errs)
+		return withExitCode(ExitCodeValidationFailed, errors.New("the plan file failed validation"))
+	}
+	nodeSSHCon := &install.SSHConnection{
+		SSHConfig: &plan.Cluster.SSH,
+		Node:      &newNode,
+	}
+	if _, errs := install.ValidateSSHConnection(nodeSSHCon, "New node"); errs != nil {
+		util.PrintValidationErrors(out, errs)
+		return withExitCode(ExitCodeValidationFailed, errors.New("could not establish SSH connection to the new node"))
+	}
+	if err := ensureNodeIsNew(*plan, newNode); err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	execOpts := install.ExecutorOptions{
+		GeneratedAssetsDirectory:   opts.GeneratedAssetsDirectory,
+		OutputFormat:               opts.OutputFormat,
+		Verbose:                    opts.Verbose,
+		IgnoreVersionCompatibility: opts.Force,
+	}
+	executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(execOpts))
+	if err != nil {
+		return err
+	}
+	if !opts.SkipPreFlight {
+		util.PrintHeader(out, "Running Pre-Flight Checks On New Node", '=')
+		if err := executor.RunNewNodePreFlightCheck(planWithoutOld, newNode); err != nil {
+			return withExitCode(ExitCodePreflightFailed, err)
+		}
+	}
+	if _, _, err := executor.AddNode(&planWithoutOld, newNode, []string{"storage"}, false); err != nil {
+		return withExitCode(playbookExitCode(err), err)
+	}
+	// Record the new node, so that the replacement can be resumed
+	updatedPlan := install.AddNodeToPlan(*plan, newNode, []string{"storage"})
+	if err := planner.Write(&updatedPlan); err != nil {
+		return fmt.Errorf("error updating plan file to include the new node: %v", err)
+	}
+	return nil
+}
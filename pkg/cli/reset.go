@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -17,8 +19,15 @@ type resetOpts struct {
 	verbose            bool
 	outputFormat       string
 	limit              []string
+	exclude            []string
+	roles              []string
+	components         []string
+	purge              bool
 	force              bool
 	removeAssets       bool
+	timings            bool
+	showTasks          string
+	hideTasks          string
 }
 
 // NewCmdReset resets nodes
@@ -27,36 +36,39 @@ func NewCmdReset(in io.Reader, out io.Writer) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "reset",
 		Short: "reset any changes made to the hosts by 'apply'",
+		Long: `Reset any changes made to the hosts by 'apply'.
+
+The name of the cluster must be typed to confirm the reset, unless --force is set.
+The reset can be limited to the nodes of some roles, and to some components. The
+Kubernetes and etcd directories are backed up on each node before they are removed,
+unless --purge is set.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) != 0 {
 				return fmt.Errorf("Unexpected args: %v", args)
 			}
-			if opts.force == false {
-				ans, err := util.PromptForString(in, out, "Are you sure you want to reset the cluster? All data will be lost", "N", []string{"N", "y"})
-				if err != nil {
-					return fmt.Errorf("error getting user response: %v", err)
-				}
-				if strings.ToLower(ans) != "y" {
-					os.Exit(0)
-				}
-			}
-			return doReset(out, opts)
+			opts.limit = limitWithExclusions(opts.limit, opts.exclude)
+			return doReset(in, out, opts)
 		},
 	}
 
-	cmd.Flags().StringSliceVar(&opts.limit, "limit", []string{}, "comma-separated list of hostnames to limit the execution to a subset of nodes")
+	addLimitFlags(cmd.Flags(), &opts.limit, &opts.exclude)
+	cmd.Flags().StringSliceVar(&opts.roles, "roles", []string{}, "comma-separated list of roles to limit the execution to the nodes with those roles (options \"etcd\"|\"master\"|\"worker\"|\"ingress\"|\"storage\")")
+	cmd.Flags().StringSliceVar(&opts.components, "components", []string{}, fmt.Sprintf("comma-separated list of components to reset, instead of all the components %v", install.ResetComponents()))
+	cmd.Flags().BoolVar(&opts.purge, "purge", false, "remove the Kubernetes and etcd data without leaving a backup on the nodes")
 	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
-	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
 	cmd.Flags().BoolVar(&opts.force, "force", false, `do not prompt`)
 	cmd.Flags().BoolVar(&opts.removeAssets, "remove-assets", false, "remove generated-assets-dir")
+	addTimingsFlag(cmd.Flags(), &opts.timings)
+	addTaskFilterFlags(cmd.Flags(), &opts.showTasks, &opts.hideTasks)
 
 	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFilename)
 
 	return cmd
 }
 
-func doReset(out io.Writer, opts *resetOpts) error {
+func doReset(in io.Reader, out io.Writer, opts *resetOpts) error {
 	planner := &install.FilePlanner{File: opts.planFilename}
 	if !planner.PlanExists() {
 		return planFileNotFoundErr{filename: opts.planFilename}
@@ -69,13 +81,37 @@ func doReset(out io.Writer, opts *resetOpts) error {
 		GeneratedAssetsDirectory: opts.generatedAssetsDir,
 		OutputFormat:             opts.outputFormat,
 		Verbose:                  opts.verbose,
+		Timings:                  opts.timings,
+		ShowTasks:                opts.showTasks,
+		HideTasks:                opts.hideTasks,
 	}
-	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(executorOpts))
 	if err != nil {
 		return err
 	}
-	if err := executor.Reset(plan, opts.limit...); err != nil {
-		return fmt.Errorf("error running reset: %v", err)
+	return reset(in, out, plan, executor, opts)
+}
+
+func reset(in io.Reader, out io.Writer, plan *install.Plan, executor install.Executor, opts *resetOpts) error {
+	resetOpts := install.ResetOptions{Components: opts.components, Purge: opts.purge}
+	if err := resetOpts.Validate(); err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	partial := len(opts.components) > 0 || len(opts.roles) > 0 || len(opts.limit) > 0
+	if opts.removeAssets && partial {
+		return withExitCode(ExitCodeValidationFailed, errors.New("--remove-assets cannot be used when resetting a subset of the nodes or components"))
+	}
+	nodes, err := resetNodes(plan, opts.limit, opts.roles)
+	if err != nil {
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+	if !opts.force {
+		if err := confirmClusterName(in, out, plan.Cluster.Name); err != nil {
+			return err
+		}
+	}
+	if _, err := executor.Reset(plan, resetOpts, nodes...); err != nil {
+		return withExitCode(playbookExitCode(err), fmt.Errorf("error running reset: %v", err))
 	}
 
 	if opts.removeAssets {
@@ -93,3 +129,53 @@ func doReset(out io.Writer, opts *resetOpts) error {
 
 	return nil
 }
+
+// resetNodes returns the hosts that are reset. When roles are given, only the
+// nodes that have one of the roles are reset. All the nodes are reset when
+// the returned list is empty.
+func resetNodes(plan *install.Plan, limit []string, roles []string) ([]string, error) {
+	validRoles := []string{"etcd", "master", "worker", "ingress", "storage"}
+	for _, r := range roles {
+		if !util.Contains(r, validRoles) {
+			return nil, fmt.Errorf("invalid role %q. Options are %v", r, validRoles)
+		}
+	}
+	limit, err := plan.ResolveLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return limit, nil
+	}
+	var hosts []string
+	for _, n := range plan.GetUniqueNodes() {
+		if len(limit) > 0 && !util.Contains(n.Host, limit) {
+			continue
+		}
+		for _, r := range plan.GetRolesForIP(n.IP) {
+			if util.Contains(r, roles) {
+				hosts = append(hosts, n.Host)
+				break
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no nodes with the roles %v were found", roles)
+	}
+	return hosts, nil
+}
+
+// confirmClusterName prompts the user for the name of the cluster. It returns
+// errAborted if the name does not match.
+func confirmClusterName(in io.Reader, out io.Writer, name string) error {
+	fmt.Fprintf(out, "=> Resetting the cluster removes the data on its nodes. Type the name of the cluster (%s) to confirm: ", name)
+	s := bufio.NewScanner(in)
+	s.Scan()
+	if s.Err() != nil {
+		return fmt.Errorf("error getting user response: %v", s.Err())
+	}
+	if strings.TrimSpace(s.Text()) != name {
+		return errAborted
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+func resetTestPlan() *install.Plan {
+	return &install.Plan{
+		Cluster: install.Cluster{Name: "prod"},
+		Etcd:    install.NodeGroup{Nodes: []install.Node{{Host: "etcd01", IP: "10.0.0.1"}}},
+		Master:  install.MasterNodeGroup{Nodes: []install.Node{{Host: "master01", IP: "10.0.0.2"}}},
+		Worker:  install.NodeGroup{Nodes: []install.Node{{Host: "worker01", IP: "10.0.0.3"}, {Host: "worker02", IP: "10.0.0.4"}}},
+	}
+}
+
+func TestResetRequiresClusterName(t *testing.T) {
+	tests := []struct {
+		input string
+		force bool
+		reset bool
+	}{
+		{input: "prod\n", reset: true},
+		{input: "y\n"},
+		{input: ""},
+		{force: true, reset: true},
+	}
+	for i, test := range tests {
+		fe := &fakeExecutor{}
+		opts := &resetOpts{force: test.force}
+		err := reset(strings.NewReader(test.input), &bytes.Buffer{}, resetTestPlan(), fe, opts)
+		if test.reset && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if !test.reset && err == nil {
+			t.Errorf("test %d: expected an error when the cluster name is not confirmed", i)
+		}
+		if fe.resetCalled != test.reset {
+			t.Errorf("test %d: expected reset to be called: %v", i, test.reset)
+		}
+	}
+}
+
+func TestResetRoles(t *testing.T) {
+	fe := &fakeExecutor{}
+	opts := &resetOpts{force: true, roles: []string{"worker"}, components: []string{"kubelet"}, purge: true}
+	if err := reset(nil, &bytes.Buffer{}, resetTestPlan(), fe, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fe.resetNodes) != 2 || fe.resetNodes[0] != "worker01" || fe.resetNodes[1] != "worker02" {
+		t.Errorf("expected the workers to be reset, but got %v", fe.resetNodes)
+	}
+	if len(fe.resetOpts.Components) != 1 || !fe.resetOpts.Purge {
+		t.Errorf("unexpected reset options %+v", fe.resetOpts)
+	}
+}
+
+func TestResetExcludedNodes(t *testing.T) {
+	fe := &fakeExecutor{}
+	opts := &resetOpts{force: true, roles: []string{"worker"}, limit: limitWithExclusions(nil, []string{"worker02"})}
+	if err := reset(nil, &bytes.Buffer{}, resetTestPlan(), fe, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fe.resetNodes) != 1 || fe.resetNodes[0] != "worker01" {
+		t.Errorf("expected worker01 to be reset, but got %v", fe.resetNodes)
+	}
+}
+
+func TestResetInvalidOptions(t *testing.T) {
+	tests := []resetOpts{
+		{force: true, roles: []string{"dashboard"}},
+		{force: true, components: []string{"dashboard"}},
+		{force: true, roles: []string{"ingress"}},
+		{force: true, limit: []string{"worker01"}, removeAssets: true},
+	}
+	for i, opts := range tests {
+		fe := &fakeExecutor{}
+		if err := reset(nil, &bytes.Buffer{}, resetTestPlan(), fe, &opts); err == nil {
+			t.Errorf("test %d: expected an error", i)
+		}
+		if fe.resetCalled {
+			t.Errorf("test %d: expected reset not to be called", i)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/scheduler"
+	"github.com/spf13/cobra"
+)
+
+type scheduleOpts struct {
+	configFile string
+}
+
+// NewCmdSchedule runs recurring maintenance operations against the clusters
+func NewCmdSchedule(out io.Writer) *cobra.Command {
+	opts := &scheduleOpts{}
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Run recurring maintenance operations, such as diagnostics, etcd backups or certificate audits, against one or more clusters",
+		Long: `Run recurring maintenance operations against one or more clusters.
+
+The clusters, the jobs and their cron schedules are defined in the configuration file.
+The operations that can be scheduled are:
+
+  diagnose            collect diagnostics from the nodes
+  etcd-backup         back up the etcd clusters on each etcd node
+  etcd-defrag         back up and defragment the etcd clusters, one node at a time
+  certificates-audit  report the certificates that expire within expiry_days days
+
+The result and the output of each job are recorded in the "scheduled" directory of the
+runs directory of each cluster. The notification command is run when a job fails.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&opts.configFile, "config", "c", "kismatic-schedule.yaml", "path to the configuration file of the clusters and the jobs")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "run",
+		Short: "Run the jobs on their schedule until interrupted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			s, err := newScheduler(out, opts.configFile)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Running %d job(s) against %d cluster(s)\n", len(s.Config.Jobs), len(s.Config.Clusters))
+			return s.Run(interruptContext().Done())
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the jobs and the next time they run",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			s, err := newScheduler(out, opts.configFile)
+			if err != nil {
+				return err
+			}
+			return printNextRuns(out, s, time.Now())
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "run-job JOB",
+		Short: "Run a job now, regardless of its schedule",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("the name of the job is required")
+			}
+			s, err := newScheduler(out, opts.configFile)
+			if err != nil {
+				return err
+			}
+			for _, j := range s.Config.Jobs {
+				if j.Name != args[0] {
+					continue
+				}
+				for _, r := range s.RunJob(j) {
+					if !r.Succeeded {
+						return fmt.Errorf("the job %q failed on cluster %q, see %s", j.Name, r.Cluster, r.RunDirectory)
+					}
+				}
+				return nil
+			}
+			return fmt.Errorf("the job %q is not defined in %q", args[0], opts.configFile)
+		},
+	})
+	return cmd
+}
+
+func newScheduler(out io.Writer, configFile string) (*scheduler.Scheduler, error) {
+	config, err := scheduler.ReadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	operations := scheduledOperations()
+	if err := config.Validate(operations); err != nil {
+		return nil, fmt.Errorf("invalid scheduler configuration %q: %v", configFile, err)
+	}
+	return &scheduler.Scheduler{Config: config, Operations: operations, Out: out}, nil
+}
+
+func printNextRuns(out io.Writer, s *scheduler.Scheduler, now time.Time) error {
+	runs, err := s.NextRuns(now)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tOPERATION\tSCHEDULE\tCLUSTERS\tNEXT RUN")
+	for _, r := range runs {
+		clusters := "all"
+		if len(r.Job.Clusters) > 0 {
+			clusters = fmt.Sprintf("%v", r.Job.Clusters)
+		}
+		next := "never"
+		if !r.Time.IsZero() {
+			next = r.Time.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Job.Name, r.Job.Operation, r.Job.Schedule, clusters, next)
+	}
+	return w.Flush()
+}
+
+// scheduledOperations returns the operations that can be scheduled
+func scheduledOperations() map[string]scheduler.Operation {
+	return map[string]scheduler.Operation{
+		"diagnose": func(c scheduler.Cluster, j scheduler.Job, out io.Writer) error {
+			plan, err := readScheduledPlan(c)
+			if err != nil {
+				return err
+			}
+			opts := scheduledExecutorOptions(c)
+			opts.DiagnosticsDirecty = filepath.Join(c.RunsDirectory, "diagnostics")
+			e, err := install.NewDiagnosticsExecutor(out, out, opts)
+			if err != nil {
+				return err
+			}
+			return e.DiagnoseNodes(*plan)
+		},
+		"etcd-backup": func(c scheduler.Cluster, j scheduler.Job, out io.Writer) error {
+			return maintainEtcd(c, out, false)
+		},
+		"etcd-defrag": func(c scheduler.Cluster, j scheduler.Job, out io.Writer) error {
+			return maintainEtcd(c, out, true)
+		},
+		"certificates-audit": func(c scheduler.Cluster, j scheduler.Job, out io.Writer) error {
+			return auditCertificates(c, out, j.ExpiryDays)
+		},
+	}
+}
+
+func readScheduledPlan(c scheduler.Cluster) (*install.Plan, error) {
+	planner := install.FilePlanner{File: c.PlanFile}
+	if !planner.PlanExists() {
+		return nil, planFileNotFoundErr{filename: c.PlanFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %q: %v", c.PlanFile, err)
+	}
+	return plan, nil
+}
+
+func scheduledExecutorOptions(c scheduler.Cluster) install.ExecutorOptions {
+	return globalExecutorOptions(install.ExecutorOptions{
+		GeneratedAssetsDirectory: c.GeneratedAssetsDirectory,
+		RunsDirectory:            c.RunsDirectory,
+		OutputFormat:             "ci",
+	})
+}
+
+func maintainEtcd(c scheduler.Cluster, out io.Writer, defrag bool) error {
+	plan, err := readScheduledPlan(c)
+	if err != nil {
+		return err
+	}
+	e, err := install.NewExecutor(out, out, scheduledExecutorOptions(c))
+	if err != nil {
+		return err
+	}
+	m, ok := e.(install.EtcdMaintenanceExecutor)
+	if !ok {
+		return fmt.Errorf("the executor does not support the maintenance of etcd")
+	}
+	return m.MaintainEtcd(plan, defrag)
+}
+
+func auditCertificates(c scheduler.Cluster, out io.Writer, days int) error {
+	certsDir := filepath.Join(c.GeneratedAssetsDirectory, "keys")
+	expiring, err := install.ExpiringCertificates(certsDir, time.Now(), time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	if len(expiring) == 0 {
+		fmt.Fprintf(out, "None of the certificates in %q expire within %d days\n", certsDir, days)
+		return nil
+	}
+	var names []string
+	for _, cert := range expiring {
+		fmt.Fprintf(out, "%s expires on %s\n", cert.Name, cert.NotAfter.Format(time.RFC3339))
+		names = append(names, cert.Name)
+	}
+	return fmt.Errorf("%d certificate(s) expire within %d days: %v", len(expiring), days, names)
+}
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v2"
 
@@ -32,6 +33,13 @@ one defined in the plan file.
 If you want to further control how your registry is seeded, or if you are only
 interested in the list of all images that can be used in a KET installation, you
 may use the --list-only flag.
+
+The provenance of the images can be verified before they are pushed to the
+registry. The --image-digests-file flag takes a file that maps each image to its
+expected SHA256 digest, and the --cosign-key flag takes the public key used to
+verify the cosign signatures of the images. Digests are required for all images
+when the plan file is set up for a disconnected installation. The results of
+the verification are recorded in the run manifest of the seed-registry run.
 `
 
 const imagesManifestFile = "./ansible/playbooks/group_vars/container_images.yaml"
@@ -42,6 +50,9 @@ type seedRegistryOptions struct {
 	planFile            string
 	imagesManifestsFile string
 	registryServer      string
+	imageDigestsFile    string
+	cosignKey           string
+	runsDir             string
 }
 
 type imageManifest struct {
@@ -79,6 +90,9 @@ func NewCmdSeedRegistry(stdout, stderr io.Writer) *cobra.Command {
 	cmd.Flags().BoolVar(&options.verbose, "verbose", false, "enable verbose logging")
 	cmd.Flags().StringVar(&options.registryServer, "server", "", "set to the location of the registry server, without the protocol (e.g. localhost:5000)")
 	cmd.Flags().StringVar(&options.imagesManifestsFile, "images-manifest-file", "", "path to the container images manifest file")
+	cmd.Flags().StringVar(&options.imageDigestsFile, "image-digests-file", "", "path to the file with the expected SHA256 digests of the images")
+	cmd.Flags().StringVar(&options.cosignKey, "cosign-key", "", "path to the public key used to verify the cosign signatures of the images")
+	cmd.Flags().StringVar(&options.runsDir, "runs-dir", "runs", "path to the directory where the verification results are recorded")
 	addPlanFileFlag(cmd.Flags(), &options.planFile)
 	return cmd
 }
@@ -128,9 +142,10 @@ func doSeedRegistry(stdout, stderr io.Writer, options seedRegistryOptions) error
 	// The registry specified through the command-line flag takes precedence
 	// over the one defined in the plan file.
 	server := options.registryServer
+	planner := install.FilePlanner{File: options.planFile}
+	var disconnected bool
 	if server == "" {
 		// we need to get the server from the plan file
-		planner := install.FilePlanner{File: options.planFile}
 		if !planner.PlanExists() {
 			util.PrettyPrintErr(stdout, "Reading installation plan file %q", options.planFile)
 			fmt.Fprintln(stdout, `Run "kismatic install plan" to generate it or use the "--server" option`)
@@ -155,6 +170,14 @@ func doSeedRegistry(stdout, stderr io.Writer, options seedRegistryOptions) error
 		server = plan.DockerRegistry.Server
 		// set versions from the plan file
 		versions = plan.Versions()
+		disconnected = plan.Cluster.DisconnectedInstallation
+	} else if planner.PlanExists() {
+		plan, err := planner.Read()
+		if err != nil {
+			util.PrettyPrintErr(stdout, "Reading installation plan file %q", options.planFile)
+			return fmt.Errorf("error reading plan file: %v", err)
+		}
+		disconnected = plan.Cluster.DisconnectedInstallation
 	}
 
 	im, err := readImageManifest(manifest, versions)
@@ -162,6 +185,33 @@ func doSeedRegistry(stdout, stderr io.Writer, options seedRegistryOptions) error
 		return err
 	}
 
+	verifier, err := imageVerifier(stdout, options, disconnected)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	var verifications []install.ImageVerification
+	var verify func(string) error
+	if verifier != nil {
+		verify = func(ref string) error {
+			result := verifier.Verify(ref)
+			verifications = append(verifications, result)
+			if result.Error != "" {
+				return errors.New(result.Error)
+			}
+			return nil
+		}
+		defer func() {
+			dir, err := install.WriteImageVerifications(options.runsDir, start, verifications)
+			if err != nil {
+				util.PrettyPrintErr(stdout, "Recording image verification results")
+				fmt.Fprintf(stderr, "%v\n", err)
+				return
+			}
+			fmt.Fprintf(stdout, "Image verification results recorded in %s\n", dir)
+		}()
+	}
+
 	// Seed the registry with the images
 	n := len(im.OfficialImages)
 	i := 1
@@ -172,7 +222,7 @@ func doSeedRegistry(stdout, stderr io.Writer, options seedRegistryOptions) error
 			pad = 0
 		}
 		fmt.Fprintf(stdout, l+strings.Repeat(" ", pad))
-		if err := seedImage(stdout, stderr, img, server, options.verbose); err != nil {
+		if err := seedImage(stdout, stderr, img, server, options.verbose, verify); err != nil {
 			return fmt.Errorf("Error seeding image %q: %v", img, err)
 		}
 		util.PrintOkln(stdout)
@@ -184,7 +234,37 @@ func doSeedRegistry(stdout, stderr io.Writer, options seedRegistryOptions) error
 	return nil
 }
 
-func seedImage(stdout, stderr io.Writer, img image, registry string, verbose bool) error {
+// imageVerifier returns the verifier of the image provenance, or nil when the
+// images are not to be verified
+func imageVerifier(stdout io.Writer, options seedRegistryOptions, disconnected bool) (*install.ImageVerifier, error) {
+	if options.imageDigestsFile == "" {
+		if disconnected {
+			return nil, errors.New("The plan file is set up for a disconnected installation. The image digests must be provided using the \"--image-digests-file\" option.")
+		}
+		if options.cosignKey != "" {
+			return nil, errors.New("The image digests must be provided using the \"--image-digests-file\" option to verify the image signatures.")
+		}
+		return nil, nil
+	}
+	digests, err := install.ReadImageDigests(options.imageDigestsFile)
+	if err != nil {
+		util.PrettyPrintErr(stdout, "Reading image digests file %q", options.imageDigestsFile)
+		return nil, err
+	}
+	util.PrettyPrintOk(stdout, "Reading image digests file %q", options.imageDigestsFile)
+	if options.cosignKey != "" {
+		if _, err := exec.LookPath("cosign"); err != nil {
+			return nil, errors.New("Did not find cosign installed on this node. The cosign CLI must be available for verifying the image signatures.")
+		}
+	}
+	return &install.ImageVerifier{
+		Digests:        digests,
+		RequireDigests: disconnected,
+		CosignKey:      options.cosignKey,
+	}, nil
+}
+
+func seedImage(stdout, stderr io.Writer, img image, registry string, verbose bool, verify func(image string) error) error {
 	runDockerCmd := func(args ...string) error {
 		command := exec.Command("docker", args...)
 		command.Stderr = stderr
@@ -198,6 +278,12 @@ func seedImage(stdout, stderr io.Writer, img image, registry string, verbose boo
 	if err := runDockerCmd("pull", img.String()); err != nil {
 		return err
 	}
+	// verify the provenance of the pulled image before pushing it
+	if verify != nil {
+		if err := verify(img.String()); err != nil {
+			return err
+		}
+	}
 	// tag
 	privateImgTag := fmt.Sprintf("%s/%s", registry, img)
 	if err := runDockerCmd("tag", img.String(), privateImgTag); err != nil {
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/selfupdate"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+type selfUpdateOpts struct {
+	channelURL  string
+	downloadURL string
+	version     string
+	publicKey   string
+	force       bool
+	assumeYes   bool
+}
+
+// NewCmdSelfUpdate returns the self-update command
+func NewCmdSelfUpdate(in io.Reader, out io.Writer) *cobra.Command {
+	opts := &selfUpdateOpts{}
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "update kismatic and its bundled assets to the latest release",
+		Long: `Update kismatic and its bundled assets to the latest release.
+
+The release artifact is downloaded from the release channel, and its SHA256 checksum
+and its signature are verified before any changes are made. The signature is verified
+with the public key embedded in kismatic, unless another key is set with --public-key. The kismatic binary and the bundled assets
+(ansible, inspector, kuberang, etc.) are then swapped in place. Files that are not part
+of the release, such as plan files and generated assets, are left untouched.
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			opts.assumeYes = assumeYes(cmd)
+			return doSelfUpdate(in, out, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.channelURL, "channel", selfupdate.DefaultReleaseChannel, "URL of the release channel")
+	cmd.Flags().StringVar(&opts.downloadURL, "download-url", selfupdate.DefaultDownloadURL, "base URL for downloading release artifacts")
+	cmd.Flags().StringVar(&opts.version, "version", "", "install a specific version instead of the latest release")
+	cmd.Flags().StringVar(&opts.publicKey, "public-key", selfupdate.ReleasePublicKey, "base64 encoded ECDSA public key that signs the releases of the release channel")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "do not prompt, and install the release even if it is not newer than the current version")
+	return cmd
+}
+
+func doSelfUpdate(in io.Reader, out io.Writer, opts *selfUpdateOpts) error {
+	channel := selfupdate.Channel{URL: opts.channelURL, DownloadURL: opts.downloadURL}
+	var release *selfupdate.Release
+	var err error
+	if opts.version != "" {
+		release, err = channel.Release(opts.version)
+	} else {
+		release, err = channel.Latest()
+	}
+	if err != nil {
+		return err
+	}
+	if !opts.force && !release.Version.GT(install.KismaticVersion) {
+		util.PrettyPrintOk(out, "Kismatic %s is already up to date", install.KismaticVersion)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error determining the location of the kismatic binary: %v", err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return fmt.Errorf("error determining the location of the kismatic binary: %v", err)
+	}
+	installDir := filepath.Dir(exe)
+
+	if !opts.force {
+		if err := confirm(in, out, opts.assumeYes, fmt.Sprintf("Update kismatic in %q from %s to %s?", installDir, install.KismaticVersion, release.Version)); err != nil {
+			return err
+		}
+	}
+
+	util.PrintHeader(out, "Updating Kismatic", '=')
+	updater := selfupdate.Updater{InstallDir: installDir, PublicKey: opts.publicKey, Out: out}
+	if err := updater.Update(release); err != nil {
+		return fmt.Errorf("error updating kismatic: %v", err)
+	}
+	util.PrintColor(out, util.Green, "\nKismatic was updated to %s successfully!\n\n", release.Version)
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/apprenda/kismatic/pkg/install"
 	"github.com/apprenda/kismatic/pkg/util"
@@ -23,6 +25,14 @@ type stepCmd struct {
 	verbose            bool
 	outputFormat       string
 	limit              []string
+	exclude            []string
+	force              bool
+	timings            bool
+	showTasks          string
+	hideTasks          string
+	restartComponents  []string
+	dryRun             bool
+	list               bool
 }
 
 // NewCmdStep returns the step command
@@ -34,35 +44,85 @@ func NewCmdStep(out io.Writer, opts *installOpts) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "step PLAY_NAME",
 		Short: "run a specific task of the installation workflow (debug feature)",
+		Long: `Run a specific task of the installation workflow (debug feature).
+
+Use --list to print the name and the description of the tasks that can be run.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 1 {
+			if len(args) != 1 && !stepCmd.list {
 				return cmd.Usage()
 			}
 			execOpts := install.ExecutorOptions{
-				GeneratedAssetsDirectory: stepCmd.generatedAssetsDir,
-				OutputFormat:             stepCmd.outputFormat,
-				Verbose:                  stepCmd.verbose,
+				GeneratedAssetsDirectory:   stepCmd.generatedAssetsDir,
+				OutputFormat:               stepCmd.outputFormat,
+				Verbose:                    stepCmd.verbose,
+				IgnoreVersionCompatibility: stepCmd.force,
+				Timings:                    stepCmd.timings,
+				ShowTasks:                  stepCmd.showTasks,
+				HideTasks:                  stepCmd.hideTasks,
+				RestartComponents:          stepCmd.restartComponents,
+				DryRun:                     stepCmd.dryRun,
 			}
-			executor, err := install.NewExecutor(out, os.Stderr, execOpts)
+			executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(execOpts))
 			if err != nil {
 				return err
 			}
+			stepCmd.executor = executor
+			if stepCmd.list {
+				return stepCmd.listPlays()
+			}
 			stepCmd.task = args[0]
+			stepCmd.limit = limitWithExclusions(stepCmd.limit, stepCmd.exclude)
 			stepCmd.planFile = opts.planFilename
 			stepCmd.planner = &install.FilePlanner{File: stepCmd.planFile}
-			stepCmd.executor = executor
 			return stepCmd.run()
 		},
 	}
-	cmd.Flags().StringSliceVar(&stepCmd.limit, "limit", []string{}, "comma-separated list of hostnames to limit the execution to a subset of nodes")
+	addLimitFlags(cmd.Flags(), &stepCmd.limit, &stepCmd.exclude)
 	cmd.Flags().StringVar(&stepCmd.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	cmd.Flags().BoolVar(&stepCmd.restartServices, "restart-services", false, "force restart cluster services (Use with care)")
+	addRestartComponentsFlag(cmd.Flags(), &stepCmd.restartComponents)
+	cmd.Flags().BoolVar(&stepCmd.dryRun, "dry-run", false, "render the inventory, the cluster catalog and the plays of the task in its run directory, without running it")
 	cmd.Flags().BoolVar(&stepCmd.verbose, "verbose", false, "enable verbose logging from the installation")
-	cmd.Flags().StringVarP(&stepCmd.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.Flags().StringVarP(&stepCmd.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
+	addForceVersionFlag(cmd.Flags(), &stepCmd.force)
+	addTimingsFlag(cmd.Flags(), &stepCmd.timings)
+	addTaskFilterFlags(cmd.Flags(), &stepCmd.showTasks, &stepCmd.hideTasks)
+	cmd.Flags().BoolVar(&stepCmd.list, "list", false, "list the tasks that can be run, with their description")
 	return cmd
 }
 
+func (c stepCmd) listPlays() error {
+	plays, err := c.executor.ListPlays()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPLAYS\tTAGS\tDESCRIPTION\t")
+	for _, p := range plays {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t\n", p.Name, len(p.Plays), strings.Join(p.Tags, ","), p.Description)
+	}
+	return w.Flush()
+}
+
+// validatePlay returns an error when the task cannot be run, before the plan
+// is validated
+func (c stepCmd) validatePlay() error {
+	plays, err := c.executor.ListPlays()
+	if err != nil {
+		return err
+	}
+	for _, p := range plays {
+		if p.Name == c.task {
+			return nil
+		}
+	}
+	return fmt.Errorf("task %q does not exist. Use \"kismatic install step --list\" to list the tasks that can be run", c.task)
+}
+
 func (c stepCmd) run() error {
+	if err := c.validatePlay(); err != nil {
+		return err
+	}
 	valOpts := &validateOpts{
 		planFile:           c.planFile,
 		verbose:            c.verbose,
@@ -78,10 +138,18 @@ func (c stepCmd) run() error {
 	if err != nil {
 		return fmt.Errorf("error reading plan file: %v", err)
 	}
-	util.PrintHeader(c.out, "Running Task", '=')
-	if err := c.executor.RunPlay(c.task, plan, c.restartServices, c.limit...); err != nil {
+	if err := checkVersionCompatibility(c.out, plan, install.OperationStep, c.force); err != nil {
 		return err
 	}
+	util.PrintHeader(c.out, "Running Task", '=')
+	if summary, err := c.executor.RunPlay(c.task, plan, c.restartServices, c.limit...); err != nil {
+		install.PrintRunSummary(c.out, summary)
+		return withExitCode(playbookExitCode(err), err)
+	}
+	if c.dryRun {
+		util.PrintColor(c.out, util.Green, "\nTask rendered successfully\n\n")
+		return nil
+	}
 	util.PrintColor(c.out, util.Green, "\nTask completed successfully\n\n")
 	return nil
 }
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+func TestStepValidatePlay(t *testing.T) {
+	executor := &fakeExecutor{plays: []install.Play{{Name: "_docker.yaml"}, {Name: "_kubelet.yaml"}}}
+	c := stepCmd{task: "_kubelet.yaml", executor: executor}
+	if err := c.validatePlay(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	c.task = "_kubelets.yaml"
+	if err := c.validatePlay(); err == nil {
+		t.Errorf("expected an error for a task that does not exist")
+	}
+}
+
+func TestStepListPlays(t *testing.T) {
+	out := &bytes.Buffer{}
+	executor := &fakeExecutor{plays: []install.Play{{Name: "_docker.yaml", Description: "Install Docker", Plays: []string{"Install Docker"}}}}
+	c := stepCmd{out: out, executor: executor}
+	if err := c.listPlays(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "_docker.yaml") || !strings.Contains(out.String(), "Install Docker") {
+		t.Errorf("expected the plays to be listed, got %q", out.String())
+	}
+}
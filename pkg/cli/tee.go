@@ -0,0 +1,11 @@
+package cli
+
+import "github.com/spf13/cobra"
+
+// the additional outputs of the executors, set with --tee-file and --tee-socket
+var teeFile, teeSocket string
+
+func addTeeFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&teeFile, "tee-file", "", "path to a file where the console output of the operations is appended, in addition to being displayed")
+	cmd.PersistentFlags().StringVar(&teeSocket, "tee-socket", "", "path to a unix socket where the console output of the operations is sent, in addition to being displayed")
+}
@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"io"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/tracing"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// setupTracing starts tracing the command if an OpenTelemetry collector is
+// configured in the environment. Tracing errors never fail the command.
+func setupTracing(stderr io.Writer, cmd *cobra.Command) {
+	err := tracing.Init(cmd.CommandPath(), "kismatic.command", cmd.CommandPath(), "kismatic.version", install.KismaticVersion.String())
+	if err != nil {
+		logging.Warn("tracing is disabled", "error", err)
+		util.PrettyPrintWarn(stderr, "Tracing is disabled: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/webui"
+	"github.com/pkg/browser"
+	"github.com/spf13/cobra"
+)
+
+type uiOpts struct {
+	planFilename       string
+	address            string
+	generatedAssetsDir string
+	runsDir            string
+	open               bool
+	allowedHosts       []string
+}
+
+// NewCmdUI serves the web application that shows the progress of the
+// operations
+func NewCmdUI(out io.Writer) *cobra.Command {
+	opts := &uiOpts{}
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "Serve a local web application showing the plan, the progress of the operations on each node and the history of the runs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("Unexpected args: %v", args)
+			}
+			return doUI(out, opts)
+		},
+	}
+	addPlanFileFlag(cmd.Flags(), &opts.planFilename)
+	cmd.Flags().StringVar(&opts.address, "address", "127.0.0.1:8080", "address to serve the web application on")
+	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process are stored")
+	cmd.Flags().StringVar(&opts.runsDir, "runs-dir", "runs", "path to the directory where the runs of the operations are stored")
+	cmd.Flags().BoolVar(&opts.open, "open", false, "open the web application in the default browser")
+	cmd.Flags().StringSliceVar(&opts.allowedHosts, "allowed-hosts", nil, "host names (e.g. bastion.example.com) the web application can be reached with, in addition to localhost, IP addresses and the host of --address")
+	return cmd
+}
+
+func doUI(out io.Writer, opts *uiOpts) error {
+	planner := install.FilePlanner{File: opts.planFilename}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: opts.planFilename}
+	}
+	options := install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.generatedAssetsDir,
+		RunsDirectory:            opts.runsDir,
+		OutputFormat:             "ci",
+	}
+	operations := map[string]webui.Operation{
+		"preflight": func(plan *install.Plan, events io.Writer, opOut io.Writer) error {
+			e, err := install.NewPreFlightExecutor(opOut, opOut, uiExecutorOptions(options, events))
+			if err != nil {
+				return err
+			}
+			return e.RunPreFlightCheck(plan)
+		},
+		"diagnose": func(plan *install.Plan, events io.Writer, opOut io.Writer) error {
+			e, err := install.NewDiagnosticsExecutor(opOut, opOut, uiExecutorOptions(options, events))
+			if err != nil {
+				return err
+			}
+			return e.DiagnoseNodes(*plan)
+		},
+	}
+	server := webui.NewServer(opts.planFilename, opts.runsDir, operations)
+	server.AllowedHosts = opts.allowedHosts
+	if host, _, err := net.SplitHostPort(opts.address); err == nil && host != "" {
+		server.AllowedHosts = append(server.AllowedHosts, host)
+	}
+	l, err := net.Listen("tcp", opts.address)
+	if err != nil {
+		return fmt.Errorf("error listening on %q: %v", opts.address, err)
+	}
+	url := "http://" + l.Addr().String()
+	fmt.Fprintf(out, "Serving the web application on %s\n", url)
+	if opts.open {
+		if err := browser.OpenURL(url); err != nil {
+			fmt.Fprintf(out, "Could not open the browser: %v\n", err)
+		}
+	}
+	return http.Serve(l, server.Handler())
+}
+
+// uiExecutorOptions returns the options of the executors of the operations
+// started from the web application, whose events are streamed to it
+func uiExecutorOptions(opts install.ExecutorOptions, events io.Writer) install.ExecutorOptions {
+	opts = globalExecutorOptions(opts)
+	opts.EventStream = events
+	return opts
+}
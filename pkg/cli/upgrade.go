@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/apprenda/kismatic/pkg/data"
 	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/logging"
 	"github.com/apprenda/kismatic/pkg/util"
 	"github.com/spf13/cobra"
 )
@@ -22,9 +26,28 @@ type upgradeOpts struct {
 	online             bool
 	planFile           string
 	restartServices    bool
+	restartComponents  []string
 	partialAllowed     bool
 	maxParallelWorkers int
+	maxParallelCP      int
+	maxParallelChecks  int
 	dryRun             bool
+	force              bool
+	assumeYes          bool
+	timings            bool
+	showTasks          string
+	hideTasks          string
+	smokeTestEngine    string
+	kubectlPath        string
+	skipDeprecations   bool
+	ignoreRemovedAPIs  bool
+	canary             string
+	canaryAutoProceed  bool
+	impactReport       bool
+	drainPolicy        string
+	drainTimeout       time.Duration
+	migrateHeapster    bool
+	removeHeapsterData bool
 }
 
 // NewCmdUpgrade returns the upgrade command
@@ -44,6 +67,22 @@ Nodes in the cluster are upgraded in the following order:
 1. Etcd nodes
 2. Master nodes
 3. Worker nodes (regardless of specialization)
+
+The etcd and master nodes are upgraded one at a time. With --max-parallel-control-plane,
+up to all the master nodes but one are upgraded at a time when the master nodes are load
+balanced, and up to (n-1)/2 of the n etcd nodes at a time when, in addition, the cluster has
+5 or more etcd nodes, so that etcd keeps its quorum. Etcd nodes that are also master nodes
+are upgraded no more than the master nodes are.
+
+When a canary worker node is given with --canary, the etcd and master nodes and the canary
+are upgraded first. The smoke test is then run on the canary, and confirmation is required to
+continue with the remaining nodes, unless --canary-auto-proceed is set.
+
+Before upgrading, the cluster is scanned for objects that were created with API versions
+that are deprecated or removed in the target Kubernetes version. The report is written
+to the generated assets directory, and confirmation is required to continue if any of
+the APIs are removed in the target version. --assume-yes does not confirm it: set
+--ignore-removed-apis to continue without user interaction.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return cmd.Help()
@@ -52,11 +91,26 @@ Nodes in the cluster are upgraded in the following order:
 
 	cmd.PersistentFlags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	cmd.PersistentFlags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
-	cmd.PersistentFlags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"raw\")")
+	cmd.PersistentFlags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options \"simple\"|\"table\"|\"ci\"|\"json\"|\"raw\")")
 	cmd.PersistentFlags().BoolVar(&opts.skipPreflight, "skip-preflight", false, "skip upgrade pre-flight checks")
 	cmd.PersistentFlags().BoolVar(&opts.restartServices, "restart-services", false, "force restart cluster services (Use with care)")
+	addRestartComponentsFlag(cmd.PersistentFlags(), &opts.restartComponents)
 	cmd.PersistentFlags().BoolVar(&opts.partialAllowed, "partial-ok", false, "allow the upgrade of ready nodes, and skip nodes that have been deemed unready for upgrade")
-	cmd.PersistentFlags().BoolVar(&opts.dryRun, "dry-run", false, "simulate the upgrade, but don't actually upgrade the cluster")
+	cmd.PersistentFlags().BoolVar(&opts.dryRun, "dry-run", false, "simulate the upgrade, but don't actually upgrade the cluster. The inventory, the cluster catalog and the plays of each step are rendered in its run directory")
+	addForceVersionFlag(cmd.PersistentFlags(), &opts.force)
+	addTimingsFlag(cmd.PersistentFlags(), &opts.timings)
+	addTaskFilterFlags(cmd.PersistentFlags(), &opts.showTasks, &opts.hideTasks)
+	addSmokeTestEngineFlag(cmd.PersistentFlags(), &opts.smokeTestEngine)
+	cmd.PersistentFlags().IntVar(&opts.maxParallelChecks, "max-parallel-preflight", 1, "the maximum number of nodes on which upgrade pre-flight checks are run in parallel. When greater than 1, the output of each node is prefixed with its name")
+	cmd.PersistentFlags().IntVar(&opts.maxParallelCP, "max-parallel-control-plane", 1, "the maximum number of etcd nodes, and of master nodes, to be upgraded in parallel. Master nodes are only upgraded in parallel when they are load balanced, and etcd nodes when, in addition, there are 5 or more, without exceeding the number of etcd nodes that can be down while keeping the quorum")
+	cmd.PersistentFlags().StringVar(&opts.kubectlPath, "kubectl-path", "kubectl", "path to the kubectl binary used to scan the cluster for deprecated APIs")
+	cmd.PersistentFlags().BoolVar(&opts.skipDeprecations, "skip-deprecation-report", false, "skip scanning the cluster for APIs that are deprecated or removed in the target Kubernetes version")
+	cmd.PersistentFlags().BoolVar(&opts.ignoreRemovedAPIs, "ignore-removed-apis", false, "continue with the upgrade without confirmation when objects use APIs that are removed in the target Kubernetes version")
+	cmd.PersistentFlags().StringVar(&opts.canary, "canary", "", "hostname of a worker node that is upgraded and smoke tested before the remaining worker nodes")
+	cmd.PersistentFlags().BoolVar(&opts.canaryAutoProceed, "canary-auto-proceed", false, "continue with the remaining nodes without confirmation when the smoke test succeeds on the canary node")
+	cmd.PersistentFlags().BoolVar(&opts.impactReport, "impact-report", false, "print the changes that the upgrade would make to each node, and exit without making any changes")
+	cmd.PersistentFlags().BoolVar(&opts.migrateHeapster, "migrate-heapster", false, "replace the Heapster add-on with metrics-server when upgrading the cluster services. Heapster and InfluxDB are removed once kubectl top reports the metrics of the nodes, and the plan file is updated")
+	cmd.PersistentFlags().BoolVar(&opts.removeHeapsterData, "remove-heapster-data", false, "also delete the persistent volume claim of InfluxDB when migrating from Heapster")
 	addPlanFileFlag(cmd.PersistentFlags(), &opts.planFile)
 
 	// Subcommands
@@ -81,6 +135,7 @@ availability. For this reason, this method should not be used for clusters that
 production workloads.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.assumeYes = assumeYes(cmd)
 			return doUpgrade(in, out, opts)
 		},
 	}
@@ -104,10 +159,13 @@ before any changes are applied.
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts.online = true
+			opts.assumeYes = assumeYes(cmd)
 			return doUpgrade(in, out, opts)
 		},
 	}
 	cmd.PersistentFlags().BoolVar(&opts.ignoreSafetyChecks, "ignore-safety-checks", false, "ignore upgrade safety checks and continue with the upgrade")
+	cmd.PersistentFlags().StringVar(&opts.drainPolicy, "drain-policy", install.DrainPolicyWait, fmt.Sprintf("what to do when pod disruption budgets do not allow the eviction of the pods of a node %v", install.DrainPolicies()))
+	cmd.PersistentFlags().DurationVar(&opts.drainTimeout, "drain-timeout", 10*time.Minute, "the time to wait for pod disruption budgets to allow the eviction of the pods of a node, when the drain policy is \"wait\"")
 	return &cmd
 }
 
@@ -115,22 +173,41 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 	if opts.maxParallelWorkers < 1 {
 		return fmt.Errorf("max-parallel-workers must be greater or equal to 1, got: %d", opts.maxParallelWorkers)
 	}
+	if opts.maxParallelCP < 1 {
+		return fmt.Errorf("max-parallel-control-plane must be greater or equal to 1, got: %d", opts.maxParallelCP)
+	}
+	if opts.maxParallelChecks < 1 {
+		return fmt.Errorf("max-parallel-preflight must be greater or equal to 1, got: %d", opts.maxParallelChecks)
+	}
 
 	planFile := opts.planFile
 	planner := install.FilePlanner{File: planFile}
 	executorOpts := install.ExecutorOptions{
-		GeneratedAssetsDirectory: opts.generatedAssetsDir,
-		OutputFormat:             opts.outputFormat,
-		Verbose:                  opts.verbose,
-		DryRun:                   opts.dryRun,
+		GeneratedAssetsDirectory:   opts.generatedAssetsDir,
+		OutputFormat:               opts.outputFormat,
+		Verbose:                    opts.verbose,
+		DryRun:                     opts.dryRun,
+		IgnoreVersionCompatibility: opts.force,
+		Timings:                    opts.timings,
+		ShowTasks:                  opts.showTasks,
+		HideTasks:                  opts.hideTasks,
+		SmokeTestEngine:            opts.smokeTestEngine,
+		DrainPolicy:                opts.drainPolicy,
+		DrainTimeout:               opts.drainTimeout,
+		MaxParallelControlPlane:    opts.maxParallelCP,
+		RestartComponents:          opts.restartComponents,
+		HeapsterMigration: install.HeapsterMigration{
+			Enabled:    opts.migrateHeapster,
+			RemoveData: opts.removeHeapsterData,
+		},
 	}
-	executor, err := install.NewExecutor(out, os.Stderr, executorOpts)
+	executor, err := install.NewExecutor(out, os.Stderr, globalExecutorOptions(executorOpts))
 	if err != nil {
 		return err
 	}
 	preflightExecOpts := executorOpts
 	preflightExecOpts.DryRun = false // We always want to run preflight, even if doing a dry-run
-	preflightExec, err := install.NewPreFlightExecutor(out, os.Stderr, preflightExecOpts)
+	preflightExec, err := install.NewPreFlightExecutor(out, os.Stderr, globalExecutorOptions(preflightExecOpts))
 	if err != nil {
 		return err
 	}
@@ -139,13 +216,13 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 	// Read plan file
 	if !planner.PlanExists() {
 		util.PrettyPrintErr(out, "Reading plan file")
-		return fmt.Errorf("plan file %q does not exist", planFile)
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("plan file %q does not exist", planFile))
 	}
 	util.PrettyPrintOk(out, "Reading plan file")
 	plan, err := planner.Read()
 	if err != nil {
 		util.PrettyPrintErr(out, "Reading plan file")
-		return fmt.Errorf("error reading plan file %q: %v", planFile, err)
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("error reading plan file %q: %v", planFile, err))
 	}
 
 	// Validate the plan file before we do anything
@@ -157,42 +234,61 @@ func doUpgrade(in io.Reader, out io.Writer, opts *upgradeOpts) error {
 		return err
 	}
 
+	// Get the cluster and node versions
+	cv, err := install.ListVersions(plan)
+	if err != nil {
+		return fmt.Errorf("error listing cluster versions: %v", err)
+	}
+	if err = reportVersionCompatibility(out, cv, install.OperationUpgrade, opts.force); err != nil {
+		return err
+	}
+
+	// Figure out which nodes to upgrade
+	toUpgrade, toSkip := install.NodesToUpgrade(*plan, cv)
+
+	if opts.impactReport {
+		return reportUpgradeImpact(out, *plan, *opts, toUpgrade)
+	}
+
 	// Generate new certs, or use existing ones. Always ensure that the CA exists.
 	if err = executor.GenerateCertificates(plan, true); err != nil {
 		return err
 	}
 
 	util.PrintHeader(out, "Generating Kubeconfig File", '=')
-	isDiff, err := install.RegenerateKubeconfig(plan, opts.generatedAssetsDir)
-	if err != nil {
-		return fmt.Errorf("error generating kubeconfig file: %v", err)
-	}
-
-	if isDiff {
-		util.PrettyPrintWarn(out, "An updated kubeconfig file has been generated in %q", opts.generatedAssetsDir)
+	if opts.dryRun {
+		util.PrettyPrintSkipped(out, "Would generate the kubeconfig file in %q", opts.generatedAssetsDir)
 	} else {
-		util.PrettyPrintOk(out, "Found existing kubeconfig file in %q", opts.generatedAssetsDir)
+		isDiff, err := install.RegenerateKubeconfig(plan, opts.generatedAssetsDir)
+		if err != nil {
+			return fmt.Errorf("error generating kubeconfig file: %v", err)
+		}
+		if isDiff {
+			util.PrettyPrintWarn(out, "An updated kubeconfig file has been generated in %q", opts.generatedAssetsDir)
+		} else {
+			util.PrettyPrintOk(out, "Found existing kubeconfig file in %q", opts.generatedAssetsDir)
+		}
 	}
 
-	// Get the cluster and node versions
-	cv, err := install.ListVersions(plan)
-	if err != nil {
-		return fmt.Errorf("error listing cluster versions: %v", err)
+	if !opts.skipDeprecations {
+		if err = reportDeprecatedAPIs(in, out, *plan, *opts); err != nil {
+			return err
+		}
 	}
 
-	// Figure out which nodes to upgrade
-	var toUpgrade []install.ListableNode
-	var toSkip []install.ListableNode
-	for _, n := range cv.Nodes {
-		// run if KET version or component versions are different
-		// don't check component versions if the node has only "etcd" role
-		if install.IsOlderVersion(n.Version) || (!(len(n.Roles) == 1 && n.Roles[0] == "etcd") && plan.Cluster.Version != n.ComponentVersions.Kubernetes) {
-			toUpgrade = append(toUpgrade, n)
-		} else {
-			toSkip = append(toSkip, n)
+	if opts.canary != "" {
+		if _, err = canaryNode(opts.canary, toUpgrade); err != nil {
+			return withExitCode(ExitCodeValidationFailed, err)
 		}
 	}
 
+	for _, n := range toUpgrade {
+		logging.Info("node requires upgrade", "host", n.Node.Host, "roles", n.Roles, "version", n.Version, "kubernetesVersion", n.ComponentVersions.Kubernetes)
+	}
+	for _, n := range toSkip {
+		logging.Info("node is at the target version", "host", n.Node.Host, "roles", n.Roles, "version", n.Version)
+	}
+
 	// Print the nodes that will be skipped
 	if len(toSkip) > 0 {
 		util.PrintHeader(out, "Skipping nodes", '=')
@@ -221,18 +317,26 @@ when performing a full upgrade. When you are ready, you may use "kismatic upgrad
 without the "--partial-ok" flag to perform a full upgrade.
 
 `)
-		return nil
+		return withExitCode(ExitCodePartialUpgrade, nil)
 	}
 
 	// Upgrade the cluster services
 	util.PrintHeader(out, "Upgrade: Cluster Services", '=')
-	if err := executor.UpgradeClusterServices(*plan); err != nil {
-		return fmt.Errorf("Failed to upgrade cluster services: %v", err)
+	if _, err := executor.UpgradeClusterServices(*plan); err != nil {
+		return withExitCode(playbookExitCode(err), fmt.Errorf("Failed to upgrade cluster services: %v", err))
+	}
+	if opts.migrateHeapster && !opts.dryRun && plan.AddOns.HeapsterMonitoring != nil && !plan.AddOns.HeapsterMonitoring.Disable {
+		migrated := install.MigrateHeapsterInPlan(*plan)
+		if err := planner.Write(&migrated); err != nil {
+			return fmt.Errorf("error writing the plan file after migrating from Heapster to metrics-server: %v", err)
+		}
+		plan = &migrated
+		util.PrettyPrintOk(out, "Disabled Heapster and enabled metrics-server in the plan file")
 	}
 
 	if plan.NetworkConfigured() {
-		if err := executor.RunSmokeTest(plan); err != nil {
-			return fmt.Errorf("Smoke test failed: %v", err)
+		if _, err := executor.RunSmokeTest(plan); err != nil {
+			return withExitCode(playbookExitCode(err), fmt.Errorf("Smoke test failed: %v", err))
 		}
 	}
 
@@ -244,6 +348,101 @@ without the "--partial-ok" flag to perform a full upgrade.
 	return nil
 }
 
+// reportUpgradeImpact prints the changes that the upgrade would make to each
+// node, and writes the report to the generated assets directory
+func reportUpgradeImpact(out io.Writer, plan install.Plan, opts upgradeOpts, toUpgrade []install.ListableNode) error {
+	util.PrintHeader(out, "Upgrade Impact Report", '=')
+	// the versions are read from the group variables next to the images manifest
+	manifest, err := install.ReadVersionsManifest(filepath.Dir(manifestPath("")), plan)
+	if err != nil {
+		return fmt.Errorf("error reading the versions manifest: %v", err)
+	}
+	report := install.BuildUpgradeImpactReport(plan, toUpgrade, *manifest, opts.online)
+	if len(report.Nodes) == 0 {
+		util.PrettyPrintOk(out, "All nodes are at the target version")
+	}
+	for _, n := range report.Nodes {
+		fmt.Fprintf(out, "%s (%s), estimated downtime %s\n", n.Host, strings.Join(n.Roles, ", "), n.EstimatedDowntime)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, c := range n.Changes {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t-> %s\n", c.Kind, c.Name, c.From, c.To)
+		}
+		w.Flush()
+		if len(n.RestartedServices) > 0 {
+			fmt.Fprintf(out, "  restarts: %s\n", strings.Join(n.RestartedServices, ", "))
+		}
+		if n.Drained {
+			fmt.Fprintln(out, "  the node is drained before it is upgraded")
+		}
+		fmt.Fprintln(out)
+	}
+	for _, r := range report.Roles {
+		fmt.Fprintf(out, "%s (%d nodes), estimated downtime per node %s: %s\n", r.Role, r.Nodes, r.EstimatedDowntime, r.Impact)
+	}
+	file, err := report.Write(opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nThe upgrade impact report was written to %q\n", file)
+	return nil
+}
+
+// reportDeprecatedAPIs scans the cluster for objects that use APIs that are
+// deprecated or removed in the target version, and asks for confirmation if
+// the upgrade would remove APIs in use
+func reportDeprecatedAPIs(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOpts) error {
+	util.PrintHeader(out, "Deprecated API Report", '=')
+	scanner := install.DeprecationScanner{
+		BinaryPath: opts.kubectlPath,
+		Kubeconfig: filepath.Join(opts.generatedAssetsDir, "kubeconfig"),
+	}
+	report, err := scanner.Scan(plan.Cluster.Version)
+	if err != nil {
+		util.PrettyPrintWarn(out, "Could not scan the cluster for deprecated APIs: %v", err)
+		return nil
+	}
+	file, err := report.Write(opts.generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	if len(report.Findings) == 0 {
+		util.PrettyPrintOk(out, "No deprecated APIs in use for Kubernetes %s", plan.Cluster.Version)
+		return nil
+	}
+	for _, f := range report.Findings {
+		name := f.Name
+		if f.Namespace != "" {
+			name = f.Namespace + "/" + f.Name
+		}
+		if f.Removed {
+			util.PrettyPrintErr(out, "%s %q uses %s, which is removed in %s. Use %s instead", f.Kind, name, f.APIVersion, f.RemovedIn, f.Replacement)
+		} else {
+			util.PrettyPrintWarn(out, "%s %q uses %s, which is deprecated in %s and removed in %s. Use %s instead", f.Kind, name, f.APIVersion, f.DeprecatedIn, f.RemovedIn, f.Replacement)
+		}
+	}
+	fmt.Fprintf(out, "The deprecation report was written to %q\n", file)
+	if removed := report.Removed(); len(removed) > 0 && !opts.dryRun {
+		return confirmRemovedAPIs(in, out, opts, len(removed), plan.Cluster.Version)
+	}
+	return nil
+}
+
+// confirmRemovedAPIs asks for confirmation to upgrade the cluster although
+// objects use APIs that are removed in the target version, unless
+// --ignore-removed-apis is set
+func confirmRemovedAPIs(in io.Reader, out io.Writer, opts upgradeOpts, removed int, version string) error {
+	fmt.Fprintln(out)
+	if opts.ignoreRemovedAPIs {
+		util.PrettyPrintWarn(out, "Ignoring the objects that use removed APIs and continuing with the upgrade")
+		return nil
+	}
+	err := confirmUnsafe(in, out, opts.assumeYes, fmt.Sprintf("%d objects use APIs that are removed in %s, continue with the upgrade anyway?", removed, version), "--ignore-removed-apis")
+	if err == errAborted {
+		return withExitCode(ExitCodePreflightFailed, errors.New("Objects that use removed APIs must be updated before upgrading the cluster."))
+	}
+	return err
+}
+
 func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOpts, nodesNeedUpgrade []install.ListableNode, executor install.Executor, preflightExec install.PreFlightExecutor) error {
 	// Run safety checks if doing an online upgrade
 	unsafeNodes := []install.ListableNode{}
@@ -283,7 +482,7 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 			var safetyErr error
 			// If we found any unsafe nodes, and we are not doing a partial upgrade, or using --ignore-safety-checks exit.
 			if len(unsafeNodes) > 0 && !opts.partialAllowed {
-				safetyErr = errors.New("Unable to perform an online upgrade due to the unsafe conditions detected.")
+				safetyErr = withExitCode(ExitCodePreflightFailed, errors.New("Unable to perform an online upgrade due to the unsafe conditions detected."))
 			}
 			// Block the upgrade if partial is allowed but there is an etcd or master node
 			// that cannot be upgraded
@@ -291,7 +490,7 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 				for _, n := range unsafeNodes {
 					for _, r := range n.Roles {
 						if r == "master" || r == "etcd" {
-							safetyErr = errors.New("Unable to perform an online upgrade due to the unsafe conditions detected.")
+							safetyErr = withExitCode(ExitCodePreflightFailed, errors.New("Unable to perform an online upgrade due to the unsafe conditions detected."))
 							break
 						}
 					}
@@ -300,14 +499,14 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 			// did any safety checks fail
 			if safetyErr != nil {
 				fmt.Fprintln(out)
-				ans, err := util.PromptForString(in, out, "Unsafe conditions detected, continue with the upgrade anyway?", "N", []string{"N", "y"})
-				if err != nil {
-					return fmt.Errorf("error getting user response: %v", err)
-				}
-				// if not "y" fail safety checks, otherwise continue with upgrade
-				if strings.ToLower(ans) != "y" {
+				err := confirmUnsafe(in, out, opts.assumeYes, "Unsafe conditions detected, continue with the upgrade anyway?", "--ignore-safety-checks")
+				// if not confirmed fail safety checks, otherwise continue with upgrade
+				if err == errAborted {
 					return safetyErr
 				}
+				if err != nil {
+					return err
+				}
 				opts.ignoreSafetyChecks = true
 				util.PrettyPrintWarn(out, "\nIgnoring safety checks and continuing with the upgrade")
 			}
@@ -316,7 +515,15 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 
 	// Run upgrade preflight on the nodes that are to be upgraded
 	unreadyNodes := []install.ListableNode{}
-	if !opts.skipPreflight {
+	if !opts.skipPreflight && opts.maxParallelChecks > 1 {
+		util.PrintHeader(out, fmt.Sprintf("Preflight Checks: %d nodes", len(nodesNeedUpgrade)), '=')
+		failed := preflightExec.RunUpgradePreFlightChecks(&plan, nodesNeedUpgrade, opts.maxParallelChecks)
+		for _, node := range nodesNeedUpgrade {
+			if _, ok := failed[node.Node.Host]; ok {
+				unreadyNodes = append(unreadyNodes, node)
+			}
+		}
+	} else if !opts.skipPreflight {
 		for _, node := range nodesNeedUpgrade {
 			util.PrintHeader(out, fmt.Sprintf("Preflight Checks: %s %s", node.Node.Host, node.Roles), '=')
 			if err := preflightExec.RunUpgradePreFlightCheck(&plan, node); err != nil {
@@ -328,7 +535,7 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 
 	// Block upgrade if we found unready nodes, and we are not doing a partial upgrade
 	if len(unreadyNodes) > 0 && !opts.partialAllowed {
-		return errors.New("Errors found during preflight checks")
+		return withExitCode(ExitCodePreflightFailed, errors.New("Errors found during preflight checks"))
 	}
 
 	// Block the upgrade if partial is allowed but there is an etcd or master node
@@ -337,12 +544,19 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 		for _, n := range unreadyNodes {
 			for _, r := range n.Roles {
 				if r == "master" || r == "etcd" {
-					return errors.New("Errors found during preflight checks")
+					return withExitCode(ExitCodePreflightFailed, errors.New("Errors found during preflight checks"))
 				}
 			}
 		}
 	}
 
+	for _, n := range unsafeNodes {
+		logging.Warn("node failed upgrade safety checks", "host", n.Node.Host, "ignored", opts.ignoreSafetyChecks)
+	}
+	for _, n := range unreadyNodes {
+		logging.Warn("node failed upgrade preflight checks", "host", n.Node.Host)
+	}
+
 	// Filter out the nodes that are unsafe/unready
 	toUpgrade := []install.ListableNode{}
 	for _, n := range nodesNeedUpgrade {
@@ -365,9 +579,89 @@ func upgradeNodes(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOp
 		}
 	}
 
+	if opts.canary != "" {
+		return upgradeWithCanary(in, out, plan, opts, toUpgrade, executor)
+	}
+
 	// Run the upgrade on the nodes that need it
-	if err := executor.UpgradeNodes(plan, toUpgrade, opts.online, opts.maxParallelWorkers, opts.restartServices); err != nil {
-		return fmt.Errorf("Failed to upgrade nodes: %v", err)
+	if summary, err := executor.UpgradeNodes(plan, toUpgrade, opts.online, opts.maxParallelWorkers, opts.restartServices); err != nil {
+		install.PrintRunSummary(out, summary)
+		return withExitCode(playbookExitCode(err), fmt.Errorf("Failed to upgrade nodes: %v", err))
 	}
 	return nil
 }
+
+// upgradeWithCanary upgrades the etcd and master nodes and the canary node,
+// and runs the smoke test on the canary before upgrading the remaining nodes
+func upgradeWithCanary(in io.Reader, out io.Writer, plan install.Plan, opts upgradeOpts, toUpgrade []install.ListableNode, executor install.Executor) error {
+	first, rest, err := splitCanaryUpgrade(opts.canary, toUpgrade)
+	if err != nil {
+		return withExitCode(ExitCodePreflightFailed, err)
+	}
+	summary, err := executor.UpgradeNodes(plan, first, opts.online, 1, opts.restartServices)
+	if err != nil {
+		install.PrintRunSummary(out, summary)
+		return withExitCode(playbookExitCode(err), fmt.Errorf("Failed to upgrade nodes: %v", err))
+	}
+	if err := executor.RunNodeSmokeTest(&plan, opts.canary); err != nil {
+		return withExitCode(playbookExitCode(err), fmt.Errorf("Smoke test failed on canary node %q, the remaining nodes were not upgraded: %v", opts.canary, err))
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out)
+	util.PrettyPrintOk(out, "Canary node %q was upgraded successfully", opts.canary)
+	if !opts.canaryAutoProceed && !opts.dryRun {
+		err := confirm(in, out, opts.assumeYes, fmt.Sprintf("Continue with the upgrade of the remaining %d nodes?", len(rest)))
+		if err == errAborted {
+			return withExitCode(ExitCodeAborted, fmt.Errorf("The upgrade was stopped after the canary node %q. Run the upgrade again to upgrade the remaining nodes.", opts.canary))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if restSummary, err := executor.UpgradeNodes(plan, rest, opts.online, opts.maxParallelWorkers, opts.restartServices); err != nil {
+		install.PrintRunSummary(out, summary.Merge(restSummary))
+		return withExitCode(playbookExitCode(err), fmt.Errorf("Failed to upgrade nodes: %v", err))
+	}
+	return nil
+}
+
+// canaryNode returns the canary node, which must be a worker node that
+// requires an upgrade and is not an etcd or master node
+func canaryNode(host string, toUpgrade []install.ListableNode) (install.ListableNode, error) {
+	for _, n := range toUpgrade {
+		if n.Node.Host != host {
+			continue
+		}
+		if util.Contains("etcd", n.Roles) || util.Contains("master", n.Roles) {
+			return n, fmt.Errorf("canary node %q cannot be an etcd or master node", host)
+		}
+		if !util.Contains("worker", n.Roles) {
+			return n, fmt.Errorf("canary node %q must be a worker node", host)
+		}
+		return n, nil
+	}
+	return install.ListableNode{}, fmt.Errorf("canary node %q is not one of the nodes to be upgraded", host)
+}
+
+// splitCanaryUpgrade returns the nodes that are upgraded before the smoke test
+// of the canary, which are the etcd and master nodes followed by the canary,
+// and the remaining nodes
+func splitCanaryUpgrade(host string, toUpgrade []install.ListableNode) (first, rest []install.ListableNode, err error) {
+	canary, err := canaryNode(host, toUpgrade)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, n := range toUpgrade {
+		if n.Node.Host == host {
+			continue
+		}
+		if util.Contains("etcd", n.Roles) || util.Contains("master", n.Roles) {
+			first = append(first, n)
+		} else {
+			rest = append(rest, n)
+		}
+	}
+	return append(first, canary), rest, nil
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+func TestSplitCanaryUpgrade(t *testing.T) {
+	nodes := []install.ListableNode{
+		{Node: install.Node{Host: "worker01"}, Roles: []string{"worker"}},
+		{Node: install.Node{Host: "etcd01"}, Roles: []string{"etcd"}},
+		{Node: install.Node{Host: "worker02"}, Roles: []string{"worker", "ingress"}},
+		{Node: install.Node{Host: "master01"}, Roles: []string{"master"}},
+		{Node: install.Node{Host: "ingress01"}, Roles: []string{"ingress"}},
+	}
+	first, rest, err := splitCanaryUpgrade("worker02", nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hosts := func(nodes []install.ListableNode) []string {
+		h := []string{}
+		for _, n := range nodes {
+			h = append(h, n.Node.Host)
+		}
+		return h
+	}
+	if got := hosts(first); len(got) != 3 || got[0] != "etcd01" || got[1] != "master01" || got[2] != "worker02" {
+		t.Errorf("expected the etcd, master and canary nodes to be upgraded first, but got %v", got)
+	}
+	if got := hosts(rest); len(got) != 2 || got[0] != "worker01" || got[1] != "ingress01" {
+		t.Errorf("expected the remaining nodes to be upgraded last, but got %v", got)
+	}
+
+	for _, host := range []string{"master01", "ingress01", "worker03"} {
+		if _, _, err := splitCanaryUpgrade(host, nodes); err == nil {
+			t.Errorf("expected an error when the canary is %q", host)
+		}
+	}
+}
+
+func TestConfirmRemovedAPIs(t *testing.T) {
+	tests := []struct {
+		opts     upgradeOpts
+		in       string
+		exitCode int
+	}{
+		{opts: upgradeOpts{assumeYes: true}, exitCode: ExitCodePreflightFailed},
+		{opts: upgradeOpts{assumeYes: true, ignoreRemovedAPIs: true}, exitCode: ExitCodeSuccess},
+		{opts: upgradeOpts{ignoreRemovedAPIs: true}, exitCode: ExitCodeSuccess},
+		{in: "y\n", exitCode: ExitCodeSuccess},
+		{in: "N\n", exitCode: ExitCodePreflightFailed},
+	}
+	for i, test := range tests {
+		err := confirmRemovedAPIs(strings.NewReader(test.in), &bytes.Buffer{}, test.opts, 2, "v1.10.3")
+		if code := ExitCode(err); code != test.exitCode {
+			t.Errorf("test %d: expected exit code %d, got %d (%v)", i, test.exitCode, code, err)
+		}
+	}
+}
@@ -19,6 +19,8 @@ type validateOpts struct {
 	outputFormat       string
 	skipPreFlight      bool
 	limit              []string
+	exclude            []string
+	maxParallelChecks  int
 }
 
 // NewCmdValidate creates a new install validate command
@@ -33,14 +35,16 @@ func NewCmdValidate(out io.Writer, installOpts *installOpts) *cobra.Command {
 			}
 			planner := &install.FilePlanner{File: installOpts.planFilename}
 			opts.planFile = installOpts.planFilename
+			opts.limit = limitWithExclusions(opts.limit, opts.exclude)
 			return doValidate(out, planner, opts)
 		},
 	}
-	cmd.Flags().StringSliceVar(&opts.limit, "limit", []string{}, "comma-separated list of hostnames to limit the execution to a subset of nodes")
+	addLimitFlags(cmd.Flags(), &opts.limit, &opts.exclude)
 	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
 	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging from the installation")
 	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", "installation output format (options simple|raw)")
 	cmd.Flags().BoolVar(&opts.skipPreFlight, "skip-preflight", false, "skip pre-flight checks")
+	addPreflightParallelismFlag(cmd.Flags(), &opts.maxParallelChecks)
 	return cmd
 }
 
@@ -50,17 +54,16 @@ func doValidate(out io.Writer, planner install.Planner, opts *validateOpts) erro
 	if !planner.PlanExists() {
 		util.PrettyPrintErr(out, "Reading installation plan file [ERROR]")
 		fmt.Fprintln(out, "Run \"kismatic install plan\" to generate it")
-		return fmt.Errorf("plan does not exist")
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("plan does not exist"))
 	}
 	plan, err := planner.Read()
 	if err != nil {
 		util.PrettyPrintErr(out, "Reading installation plan file %q", opts.planFile)
-		return fmt.Errorf("error reading plan file: %v", err)
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("error reading plan file: %v", err))
 	}
-	for _, host := range opts.limit {
-		if !plan.HostExists(host) {
-			return fmt.Errorf("host %q in '--limit' option does not match any hosts in the plan file", host)
-		}
+	limit, err := plan.ResolveLimit(opts.limit)
+	if err != nil {
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("invalid '--limit' option: %v", err))
 	}
 	util.PrettyPrintOk(out, "Reading installation plan file %q", opts.planFile)
 
@@ -74,6 +77,26 @@ func doValidate(out io.Writer, planner install.Planner, opts *validateOpts) erro
 		return err
 	}
 
+	// Generate the IAM policies required by the nodes
+	if plan.Cluster.CloudProvider.Provider == "aws" {
+		files, err := install.WriteAWSIAMPolicies(filepath.Join(opts.generatedAssetsDir, "aws"))
+		if err != nil {
+			return err
+		}
+		util.PrettyPrintOk(out, "Generating the IAM policies required by the nodes %v", files)
+	}
+
+	// Validate load balanced FQDN
+	if err := validateLoadBalancedFQDN(out, plan); err != nil {
+		return err
+	}
+
+	// Validate that the generated assets belong to the cluster
+	if err := install.VerifyAssetsCluster(plan, opts.generatedAssetsDir); err != nil {
+		util.PrettyPrintErr(out, "Validating generated assets")
+		return withExitCode(ExitCodeValidationFailed, err)
+	}
+
 	// get a new pki
 	pki, err := newPKI(out, opts)
 	if err != nil {
@@ -84,22 +107,43 @@ func doValidate(out io.Writer, planner install.Planner, opts *validateOpts) erro
 	if !ok {
 		util.PrettyPrintErr(out, "Validating cluster certificates")
 		util.PrintValidationErrors(out, errs)
-		return fmt.Errorf("Cluster certificates validation error prevents installation from proceeding")
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("Cluster certificates validation error prevents installation from proceeding"))
 	}
 
 	if opts.skipPreFlight {
 		return nil
 	}
+	// Check the cloud provider credentials
+	if plan.Cluster.CloudProvider.Provider != "" {
+		ok, errs := install.ValidateCloudProviderCredentials(plan)
+		if !ok {
+			util.PrettyPrintErr(out, "Validating cloud provider credentials")
+			util.PrintValidationErrors(out, errs)
+			return withExitCode(ExitCodePreflightFailed, fmt.Errorf("Cloud provider credentials validation error prevents installation from proceeding"))
+		}
+		util.PrettyPrintOk(out, "Validating cloud provider credentials")
+		ok, errs = install.ValidateCloudProviderNodes(plan)
+		if !ok {
+			util.PrettyPrintErr(out, "Validating cloud provider configuration of the nodes")
+			util.PrintValidationErrors(out, errs)
+			return withExitCode(ExitCodePreflightFailed, fmt.Errorf("Cloud provider node validation error prevents installation from proceeding"))
+		}
+		util.PrettyPrintOk(out, "Validating cloud provider configuration of the nodes")
+	}
 	// Run pre-flight
 	options := install.ExecutorOptions{
-		OutputFormat: opts.outputFormat,
-		Verbose:      opts.verbose,
+		OutputFormat:         opts.outputFormat,
+		Verbose:              opts.verbose,
+		PreflightParallelism: opts.maxParallelChecks,
 	}
-	e, err := install.NewPreFlightExecutor(out, os.Stderr, options)
+	e, err := install.NewPreFlightExecutor(out, os.Stderr, globalExecutorOptions(options))
 	if err != nil {
 		return err
 	}
-	return e.RunPreFlightCheck(plan, opts.limit...)
+	if err := e.RunPreFlightCheck(plan, limit...); err != nil {
+		return withExitCode(ExitCodePreflightFailed, err)
+	}
+	return nil
 }
 
 // TODO this should really not be here
@@ -122,7 +166,7 @@ func validatePlan(out io.Writer, plan *install.Plan) error {
 	if !ok {
 		util.PrettyPrintErr(out, "Validating installation plan file")
 		util.PrintValidationErrors(out, errs)
-		return fmt.Errorf("Plan file validation error prevents installation from proceeding")
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("Plan file validation error prevents installation from proceeding"))
 	}
 	util.PrettyPrintOk(out, "Validating installation plan file")
 	return nil
@@ -133,8 +177,19 @@ func validateSSHConnectivity(out io.Writer, plan *install.Plan) error {
 	if !ok {
 		util.PrettyPrintErr(out, "Validating SSH connectivity to nodes")
 		util.PrintValidationErrors(out, errs)
-		return fmt.Errorf("SSH connectivity validation error prevents installation from proceeding")
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("SSH connectivity validation error prevents installation from proceeding"))
 	}
 	util.PrettyPrintOk(out, "Validating SSH connectivity to nodes")
 	return nil
 }
+
+func validateLoadBalancedFQDN(out io.Writer, plan *install.Plan) error {
+	ok, errs := install.ValidateLoadBalancedFQDN(plan)
+	if !ok {
+		util.PrettyPrintErr(out, "Validating load balanced FQDN")
+		util.PrintValidationErrors(out, errs)
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("Load balanced FQDN validation error prevents installation from proceeding"))
+	}
+	util.PrettyPrintOk(out, "Validating load balanced FQDN")
+	return nil
+}
@@ -4,30 +4,52 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/selfupdate"
 	"github.com/spf13/cobra"
 )
 
 type versionOut struct {
-	Version   string
-	BuildDate string
-	GoVersion string
+	Version       string
+	BuildDate     string
+	GoVersion     string
+	LatestVersion string `json:",omitempty"`
 }
 
 // NewCmdVersion returns the version command
 func NewCmdVersion(buildDate string, out io.Writer) *cobra.Command {
 	var outFormat string
+	var check bool
+	var channelURL string
+	var supported bool
 	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "display the Kismatic CLI version",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if supported {
+				return printSupportMatrix(out, outFormat)
+			}
 			v := versionOut{
 				Version:   install.KismaticVersion.String(),
 				BuildDate: buildDate,
 				GoVersion: runtime.Version(),
 			}
+			var latest *selfupdate.Release
+			if check {
+				channel := selfupdate.Channel{URL: channelURL, DownloadURL: selfupdate.DefaultDownloadURL}
+				r, err := channel.Latest()
+				if err != nil {
+					return fmt.Errorf("error checking for updates: %v", err)
+				}
+				latest = r
+				v.LatestVersion = r.Version.String()
+			}
 			if outFormat == "json" {
 				b, err := json.MarshalIndent(v, "", "    ")
 				if err != nil {
@@ -40,9 +62,66 @@ func NewCmdVersion(buildDate string, out io.Writer) *cobra.Command {
 			fmt.Fprintf(out, "  Version: %s\n", install.KismaticVersion)
 			fmt.Fprintf(out, "  Built: %s\n", buildDate)
 			fmt.Fprintf(out, "  Go Version: %s\n", runtime.Version())
+			if latest != nil {
+				fmt.Fprintf(out, "  Latest Version: %s\n", latest.Version)
+				if latest.Version.GT(install.KismaticVersion) {
+					fmt.Fprintf(out, "\nA newer version is available. Run \"kismatic self-update\" to upgrade.\n")
+				}
+			}
 			return nil
 		},
 	}
 	cmd.Flags().StringVarP(&outFormat, "output", "o", "simple", `output format (options "simple"|"json")`)
+	cmd.Flags().BoolVar(&check, "check", false, "check the release channel for a newer version")
+	cmd.Flags().StringVar(&channelURL, "channel", selfupdate.DefaultReleaseChannel, "URL of the release channel")
+	cmd.Flags().BoolVar(&supported, "supported", false, "list the Kubernetes versions, operating systems, add-ons and package versions supported by this version")
 	return cmd
 }
+
+func printSupportMatrix(out io.Writer, outFormat string) error {
+	// the image versions are read from the group variables next to the images
+	// manifest, and are left out when they are not found
+	manifest, _ := install.ReadVersionsManifest(filepath.Dir(manifestPath("")), install.Plan{})
+	s := install.SupportedVersions(manifest)
+	switch outFormat {
+	case "json":
+		b, err := json.MarshalIndent(s, "", "    ")
+		if err != nil {
+			return fmt.Errorf("error marshaling data: %v", err)
+		}
+		fmt.Fprintln(out, string(b))
+		return nil
+	case "simple":
+	default:
+		return fmt.Errorf("output format %q is not supported", outFormat)
+	}
+	fmt.Fprintf(out, "Kismatic %s supports:\n", s.KismaticVersion)
+	fmt.Fprintf(out, "  Kubernetes: %s (default %s, upgrades from %s)\n", s.Kubernetes.Versions, s.Kubernetes.Default, s.Kubernetes.UpgradeFrom)
+	fmt.Fprintf(out, "  Operating Systems: %s\n", strings.Join(s.OperatingSystems, ", "))
+	fmt.Fprintf(out, "  CNI Providers: %s\n", strings.Join(s.CNIProviders, ", "))
+	fmt.Fprintf(out, "  DNS Providers: %s\n", strings.Join(s.DNSProviders, ", "))
+	fmt.Fprintf(out, "  Cloud Providers: %s\n", strings.Join(s.CloudProviders, ", "))
+	fmt.Fprintln(out, "  Packages:")
+	packages := make([]string, 0, len(s.Packages))
+	for name := range s.Packages {
+		packages = append(packages, name)
+	}
+	sort.Strings(packages)
+	for _, name := range packages {
+		fmt.Fprintf(out, "    %s: %s (yum), %s (apt)\n", name, s.Packages[name]["yum"], s.Packages[name]["apt"])
+	}
+	if len(s.Images) > 0 {
+		fmt.Fprintln(out, "  Images:")
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		names := make([]string, 0, len(s.Images))
+		for name := range s.Images {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "    %s\t%s\n", name, s.Images[name])
+		}
+		w.Flush()
+	}
+	return nil
+}
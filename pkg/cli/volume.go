@@ -19,6 +19,7 @@ func NewCmdVolume(in io.Reader, out io.Writer) *cobra.Command {
 	addPlanFileFlag(cmd.PersistentFlags(), &planFile)
 	cmd.AddCommand(NewCmdVolumeAdd(out, &planFile))
 	cmd.AddCommand(NewCmdVolumeList(out, &planFile))
+	cmd.AddCommand(NewCmdVolumeUsage(out, &planFile))
 	cmd.AddCommand(NewCmdVolumeDelete(in, out, &planFile))
 	return cmd
 }
@@ -8,7 +8,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/apprenda/kismatic/pkg/data"
 	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/util"
 	"github.com/spf13/cobra"
 )
 
@@ -22,17 +24,25 @@ type volumeAddOptions struct {
 	generatedAssetsDir string
 	reclaimPolicy      string
 	accessModes        string
+	file               string
 }
 
 // NewCmdVolumeAdd returns the command for adding storage volumes
 func NewCmdVolumeAdd(out io.Writer, planFile *string) *cobra.Command {
 	opts := volumeAddOptions{}
 	cmd := &cobra.Command{
-		Use:   "add size_in_gigabytes [volume-name]",
+		Use:   "add [size_in_gigabytes] [volume-name]",
 		Short: "add storage volumes to the Kubernetes cluster",
 		Long: `Add storage volumes to the Kubernetes cluster.
 
-This function requires a target cluster that has storage nodes.`,
+This function requires a target cluster that has storage nodes, or that uses
+an external GlusterFS cluster through the Heketi add-on. Volumes created through
+Heketi are placed by Heketi, and do not support a distribution count or allowed addresses.
+
+Multiple volumes can be declared in a volume manifest file, which is provided
+with the --volumes-file flag. Volumes in the manifest that already exist on the cluster
+are skipped, and the missing volumes are created. Existing volumes are not
+modified.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return doVolumeAdd(out, opts, *planFile, args)
 		},
@@ -40,21 +50,31 @@ This function requires a target cluster that has storage nodes.`,
   # with StorageClass "durable". Grant access to the volume to any client with an IP
   # that starts with 10.10.
   kismatic volume add 10 storage01 -r 2 -d 2 -c="durable" -a 10.10.*.*
+
+  # Create the volumes declared in volumes.yaml that do not exist yet
+  kismatic volume add --volumes-file volumes.yaml
 		`,
 	}
-	cmd.Flags().IntVarP(&opts.replicaCount, "replica-count", "r", 2, "The number of times each file will be written.")
-	cmd.Flags().IntVarP(&opts.distributionCount, "distribution-count", "d", 1, "This is the degree to which data will be distributed across the cluster. By default, it won't be -- each replica will receive 100% of the data. Distribution makes listing or backing up the cluster more complicated by spreading data around the cluster but makes reads and writes more performant.")
-	cmd.Flags().StringVarP(&opts.storageClass, "storage-class", "c", "kismatic", "The StorageClass to present for claims in Kubernetes. Classes should identify properties of volumes in business terms, such as 'durable' or 'fast-reads'")
+	cmd.Flags().IntVarP(&opts.replicaCount, "replica-count", "r", install.DefaultVolumeReplicaCount, "The number of times each file will be written.")
+	cmd.Flags().IntVarP(&opts.distributionCount, "distribution-count", "d", install.DefaultVolumeDistributionCount, "This is the degree to which data will be distributed across the cluster. By default, it won't be -- each replica will receive 100% of the data. Distribution makes listing or backing up the cluster more complicated by spreading data around the cluster but makes reads and writes more performant.")
+	cmd.Flags().StringVarP(&opts.storageClass, "storage-class", "c", install.DefaultVolumeStorageClass, "The StorageClass to present for claims in Kubernetes. Classes should identify properties of volumes in business terms, such as 'durable' or 'fast-reads'")
 	cmd.Flags().StringSliceVarP(&opts.allowAddress, "allow-address", "a", nil, "Comma delimited list of address wildcards permitted access to the volume in addition to Kubernetes nodes.")
 	cmd.Flags().BoolVar(&opts.verbose, "verbose", false, "enable verbose logging")
 	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "simple", `output format (options simple|raw)`)
 	cmd.Flags().StringVar(&opts.generatedAssetsDir, "generated-assets-dir", "generated", "path to the directory where assets generated during the installation process will be stored")
-	cmd.Flags().StringVar(&opts.reclaimPolicy, "reclaim-policy", "Retain", "Persistent volume reclaim policy (options Retain|Recycle|Delete)")
-	cmd.Flags().StringVar(&opts.accessModes, "access-modes", "ReadWriteMany", "Comma-separated list of access modes for the persistent volume (options ReadWriteOnce|ReadOnlyMany|ReadWriteMany)")
+	cmd.Flags().StringVar(&opts.reclaimPolicy, "reclaim-policy", install.DefaultVolumeReclaimPolicy, "Persistent volume reclaim policy (options Retain|Recycle|Delete)")
+	cmd.Flags().StringVar(&opts.accessModes, "access-modes", install.DefaultVolumeAccessMode, "Comma-separated list of access modes for the persistent volume (options ReadWriteOnce|ReadOnlyMany|ReadWriteMany)")
+	cmd.Flags().StringVar(&opts.file, "volumes-file", "", "path to a volume manifest that declares the volumes to add. Volumes that already exist are skipped.")
 	return cmd
 }
 
 func doVolumeAdd(out io.Writer, opts volumeAddOptions, planFile string, args []string) error {
+	if opts.file != "" {
+		if len(args) != 0 {
+			return errors.New("the volume size and name cannot be provided when adding volumes from a manifest file")
+		}
+		return doVolumeAddFromManifest(out, opts, planFile)
+	}
 	// get volume name and size from arguments
 	var volumeName string
 	var volumeSizeStrGB string
@@ -75,10 +95,126 @@ func doVolumeAdd(out io.Writer, opts volumeAddOptions, planFile string, args []s
 		return errors.New("the volume size provided is not valid")
 	}
 
+	v := install.StorageVolume{
+		Name:              volumeName,
+		SizeGB:            volumeSizeGB,
+		ReplicateCount:    opts.replicaCount,
+		DistributionCount: opts.distributionCount,
+		StorageClass:      opts.storageClass,
+		ReclaimPolicy:     opts.reclaimPolicy,
+		AccessModes:       strings.Split(opts.accessModes, ","),
+	}
+	if opts.allowAddress != nil {
+		v.AllowAddresses = opts.allowAddress
+	}
+	if ok, errs := install.ValidateStorageVolume(v); !ok {
+		fmt.Println("The storage volume configuration is not valid:")
+		for _, e := range errs {
+			fmt.Printf("- %s\n", e)
+		}
+		return withExitCode(ExitCodeValidationFailed, errors.New("storage volume validation failed"))
+	}
+
+	exec, plan, err := setupVolumeAdd(out, opts, planFile)
+	if err != nil {
+		return err
+	}
+	if err := addVolume(exec, plan, v); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Successfully added the persistent volume to the kubernetes cluster.")
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Use \"kubectl describe pv %s\" to view volume details.\n", v.Name)
+	return nil
+}
+
+func doVolumeAddFromManifest(out io.Writer, opts volumeAddOptions, planFile string) error {
+	manifest, err := install.ReadVolumeManifest(opts.file)
+	if err != nil {
+		return fmt.Errorf("error reading volume manifest %q: %v", opts.file, err)
+	}
+	volumes, err := manifest.StorageVolumes()
+	if err != nil {
+		return withExitCode(ExitCodeValidationFailed, fmt.Errorf("the volume manifest is not valid: %v", err))
+	}
+	// validate all the volumes before making any changes to the cluster
+	valid := true
+	for _, v := range volumes {
+		if ok, errs := install.ValidateStorageVolume(v); !ok {
+			valid = false
+			fmt.Fprintf(out, "The configuration of the storage volume %q is not valid:\n", v.Name)
+			for _, e := range errs {
+				fmt.Fprintf(out, "- %s\n", e)
+			}
+		}
+	}
+	if !valid {
+		return withExitCode(ExitCodeValidationFailed, errors.New("storage volume validation failed"))
+	}
+
+	exec, plan, err := setupVolumeAdd(out, opts, planFile)
+	if err != nil {
+		return err
+	}
+	client, err := plan.GetSSHClient("master")
+	if err != nil {
+		return err
+	}
+	missing, existing, err := volumesToCreate(volumes, data.RemoteKubectl{SSHClient: client})
+	if err != nil {
+		return err
+	}
+	for _, name := range existing {
+		util.PrettyPrintOk(out, "Volume %q already exists, skipping", name)
+	}
+	for _, v := range missing {
+		if err := addVolume(exec, plan, v); err != nil {
+			return err
+		}
+		util.PrettyPrintOk(out, "Added volume %q", v.Name)
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%d volumes were added and %d volumes already existed.\n", len(missing), len(existing))
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Use \"kismatic volume list\" to view volume details.")
+	return nil
+}
+
+// volumesToCreate returns the volumes that do not have a persistent volume on
+// the cluster, and the names of the volumes that do
+func volumesToCreate(volumes []install.StorageVolume, pvLister data.PVLister) ([]install.StorageVolume, []string, error) {
+	pvs, err := pvLister.ListPersistentVolumes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing persistent volumes: %v", err)
+	}
+	exists := map[string]bool{}
+	if pvs != nil {
+		for _, pv := range pvs.Items {
+			exists[pv.Name] = true
+		}
+	}
+	var missing []install.StorageVolume
+	var existing []string
+	for _, v := range volumes {
+		if exists[v.Name] {
+			existing = append(existing, v.Name)
+			continue
+		}
+		missing = append(missing, v)
+	}
+	return missing, existing, nil
+}
+
+// setupVolumeAdd reads and validates the plan, and returns the executor used
+// to add volumes
+func setupVolumeAdd(out io.Writer, opts volumeAddOptions, planFile string) (install.Executor, *install.Plan, error) {
 	// setup ansible for execution
 	planner := &install.FilePlanner{File: planFile}
 	if !planner.PlanExists() {
-		return planFileNotFoundErr{filename: planFile}
+		return nil, nil, planFileNotFoundErr{filename: planFile}
 	}
 	execOpts := install.ExecutorOptions{
 		OutputFormat: opts.outputFormat,
@@ -86,13 +222,13 @@ func doVolumeAdd(out io.Writer, opts volumeAddOptions, planFile string, args []s
 		// Need to refactor executor code... this will do for now as we don't need the generated assets dir in this command
 		GeneratedAssetsDirectory: opts.generatedAssetsDir,
 	}
-	exec, err := install.NewExecutor(out, out, execOpts)
+	exec, err := install.NewExecutor(out, out, globalExecutorOptions(execOpts))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	plan, err := planner.Read()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Run validation
@@ -104,36 +240,19 @@ func doVolumeAdd(out io.Writer, opts volumeAddOptions, planFile string, args []s
 		generatedAssetsDir: opts.generatedAssetsDir,
 	}
 	if err := doValidate(out, planner, vopts); err != nil {
-		return err
+		return nil, nil, err
 	}
+	return exec, plan, nil
+}
 
-	v := install.StorageVolume{
-		Name:              volumeName,
-		SizeGB:            volumeSizeGB,
-		ReplicateCount:    opts.replicaCount,
-		DistributionCount: opts.distributionCount,
-		StorageClass:      opts.storageClass,
-		ReclaimPolicy:     opts.reclaimPolicy,
-		AccessModes:       strings.Split(opts.accessModes, ","),
-	}
-	if opts.allowAddress != nil {
-		v.AllowAddresses = opts.allowAddress
-	}
-	if ok, errs := install.ValidateStorageVolume(v); !ok {
-		fmt.Println("The storage volume configuration is not valid:")
-		for _, e := range errs {
-			fmt.Printf("- %s\n", e)
-		}
-		return errors.New("storage volume validation failed")
-	}
+func addVolume(exec install.Executor, plan *install.Plan, v install.StorageVolume) error {
 	if err := exec.AddVolume(plan, v); err != nil {
-		return fmt.Errorf("error adding new volume: %v", err)
+		switch err.(type) {
+		case install.InvalidStorageVolumeError, install.InsufficientStorageError:
+			return withExitCode(ExitCodeValidationFailed, fmt.Errorf("error adding volume %q: %v", v.Name, err))
+		}
+		return withExitCode(playbookExitCode(err), fmt.Errorf("error adding volume %q: %v", v.Name, err))
 	}
-
-	fmt.Fprintln(out)
-	fmt.Fprintln(out, "Successfully added the persistent volume to the kubernetes cluster.")
-	fmt.Fprintln(out)
-	fmt.Fprintf(out, "Use \"kubectl describe pv %s\" to view volume details.\n", v.Name)
 	return nil
 }
 
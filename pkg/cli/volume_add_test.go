@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+func TestVolumesToCreate(t *testing.T) {
+	pvLister := fakeKubernetesGetter{
+		pvList: []byte(`{"items": [{"metadata": {"name": "storage01"}}, {"metadata": {"name": "other"}}]}`),
+	}
+	volumes := []install.StorageVolume{{Name: "storage01"}, {Name: "storage02"}, {Name: "storage03"}}
+	missing, existing, err := volumesToCreate(volumes, pvLister)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(missing, []install.StorageVolume{{Name: "storage02"}, {Name: "storage03"}}) {
+		t.Errorf("unexpected volumes to create: %+v", missing)
+	}
+	if !reflect.DeepEqual(existing, []string{"storage01"}) {
+		t.Errorf("unexpected existing volumes: %v", existing)
+	}
+
+	// no persistent volumes on the cluster
+	missing, existing, err = volumesToCreate(volumes, fakeKubernetesGetter{pvsInNil: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(missing) != 3 || len(existing) != 0 {
+		t.Errorf("expected all volumes to be created, got %+v", missing)
+	}
+
+	if _, _, err := volumesToCreate(volumes, fakeKubernetesGetter{pvsShouldError: true}); err == nil {
+		t.Errorf("expected an error when listing persistent volumes fails")
+	}
+}
@@ -3,11 +3,8 @@ package cli
 import (
 	"fmt"
 	"io"
-	"os"
-	"strings"
 
 	"github.com/apprenda/kismatic/pkg/install"
-	"github.com/apprenda/kismatic/pkg/util"
 	"github.com/spf13/cobra"
 )
 
@@ -25,16 +22,13 @@ func NewCmdVolumeDelete(in io.Reader, out io.Writer, planFile *string) *cobra.Co
 		Use:   "delete volume-name",
 		Short: "delete storage volumes",
 		Long: `Delete storage volumes created by the 'volume add' command.
+When the Heketi add-on is enabled, the volume is deleted through the Heketi API.
 		
 WARNING all data in the volume will be lost.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if opts.force == false {
-				ans, err := util.PromptForString(in, out, "Are you sure you want to delete this volume? All data will be lost", "N", []string{"N", "y"})
-				if err != nil {
-					return fmt.Errorf("error getting user response: %v", err)
-				}
-				if strings.ToLower(ans) != "y" {
-					os.Exit(0)
+				if err := confirm(in, out, assumeYes(cmd), "Are you sure you want to delete this volume? All data will be lost"); err != nil {
+					return err
 				}
 			}
 			return doVolumeDelete(out, opts, *planFile, args)
@@ -68,7 +62,7 @@ func doVolumeDelete(out io.Writer, opts volumeDeleteOptions, planFile string, ar
 		// Need to refactor executor code... this will do for now as we don't need the generated assets dir in this command
 		GeneratedAssetsDirectory: opts.generatedAssetsDir,
 	}
-	exec, err := install.NewExecutor(out, out, execOpts)
+	exec, err := install.NewExecutor(out, out, globalExecutorOptions(execOpts))
 	if err != nil {
 		return err
 	}
@@ -90,7 +84,7 @@ func doVolumeDelete(out io.Writer, opts volumeDeleteOptions, planFile string, ar
 	}
 
 	if err := exec.DeleteVolume(plan, volumeName); err != nil {
-		return fmt.Errorf("error deleting volume: %v", err)
+		return withExitCode(playbookExitCode(err), fmt.Errorf("error deleting volume: %v", err))
 	}
 
 	fmt.Fprintln(out)
@@ -80,3 +80,23 @@ func VolumeBrickToString(bricks []Brick) string {
 	}
 	return strings.Join(vbList, ",")
 }
+
+//UsageResponse contains the usage of the volumes, and the percentage of used space above which a volume is nearly full
+type UsageResponse struct {
+	WarnPercent float64       `json:"warnPercent"`
+	Volumes     []VolumeUsage `json:"items"`
+}
+
+//VolumeUsage contains the capacity, used and available space of a volume in bytes, as well as its status and claim
+type VolumeUsage struct {
+	Name           string  `json:"name"`
+	StorageClass   string  `json:"storageClass,omitempty"`
+	QuotaSet       bool    `json:"quotaSet"`
+	CapacityBytes  float64 `json:"capacityBytes"`
+	UsedBytes      float64 `json:"usedBytes"`
+	AvailableBytes float64 `json:"availableBytes"`
+	UsedPercent    float64 `json:"usedPercent"`
+	NearlyFull     bool    `json:"nearlyFull"`
+	Status         string  `json:"status"`
+	Claim          *Claim  `json:"claim,omitempty"`
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/spf13/cobra"
+)
+
+type volumeUsageOptions struct {
+	outputFormat string
+	warnPercent  float64
+}
+
+// NewCmdVolumeUsage returns the command for reporting the usage of storage volumes
+func NewCmdVolumeUsage(out io.Writer, planFile *string) *cobra.Command {
+	opts := volumeUsageOptions{}
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "report the capacity and usage of storage volumes on the Kubernetes cluster",
+		Long: `Report the capacity, used and free space of storage volumes on the Kubernetes cluster.
+The space is read from the quota of the GlusterFS volumes, and volumes that are
+used above the warning threshold are reported as nearly full.
+This function requires a target cluster that has storage nodes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return cmd.Usage()
+			}
+			return doVolumeUsage(out, opts, *planFile)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.outputFormat, "output", "o", "table", `output format (options "table"|"json")`)
+	cmd.Flags().Float64Var(&opts.warnPercent, "warn-percent", 80, "percentage of used space above which a volume is reported as nearly full")
+	return cmd
+}
+
+func doVolumeUsage(out io.Writer, opts volumeUsageOptions, planFile string) error {
+	if opts.outputFormat != "table" && opts.outputFormat != "json" {
+		return fmt.Errorf("output format %q is not supported", opts.outputFormat)
+	}
+	if opts.warnPercent <= 0 || opts.warnPercent > 100 {
+		return fmt.Errorf("warn-percent must be greater than 0 and less than or equal to 100")
+	}
+
+	planner := &install.FilePlanner{File: planFile}
+	if !planner.PlanExists() {
+		return planFileNotFoundErr{filename: planFile}
+	}
+	plan, err := planner.Read()
+	if err != nil {
+		return fmt.Errorf("error reading plan file: %v", err)
+	}
+
+	clientStorage, err := plan.GetSSHClient("storage")
+	if err != nil {
+		return err
+	}
+	glusterClient := data.RemoteGlusterCLI{SSHClient: clientStorage}
+	clientMaster, err := plan.GetSSHClient("master")
+	if err != nil {
+		return err
+	}
+	kubernetesClient := data.RemoteKubectl{SSHClient: clientMaster}
+
+	resp, err := buildUsageResponse(glusterClient, kubernetesClient, opts.warnPercent)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		fmt.Fprintln(out, "No volumes were found on the cluster. You may use `kismatic volume add` to create new volumes.")
+		return nil
+	}
+	return printUsage(out, resp, opts.outputFormat)
+}
+
+func buildUsageResponse(glusterClient data.GlusterClient, pvLister data.PVLister, warnPercent float64) (*UsageResponse, error) {
+	glusterVolumeInfo, err := glusterClient.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+	if glusterVolumeInfo == nil || glusterVolumeInfo.VolumeInfo == nil || glusterVolumeInfo.VolumeInfo.Volumes == nil {
+		return nil, nil
+	}
+	pvs, err := pvLister.ListPersistentVolumes()
+	if err != nil {
+		return nil, err
+	}
+	pvsMap := make(map[string]data.PersistentVolume)
+	if pvs != nil {
+		for _, pv := range pvs.Items {
+			pvsMap[pv.Name] = pv
+		}
+	}
+
+	resp := UsageResponse{WarnPercent: warnPercent}
+	for _, gv := range glusterVolumeInfo.VolumeInfo.Volumes.Volume {
+		v := VolumeUsage{
+			Name:   gv.Name,
+			Status: "Unknown",
+		}
+		quota, err := glusterClient.GetQuota(gv.Name)
+		if err != nil {
+			return nil, err
+		}
+		if quota != nil && quota.VolumeQuota != nil && quota.VolumeQuota.Limit != nil && quota.VolumeQuota.Limit.HardLimit > 0 {
+			limit := quota.VolumeQuota.Limit
+			v.QuotaSet = true
+			v.CapacityBytes = limit.HardLimit
+			v.UsedBytes = limit.UsedSpace
+			v.AvailableBytes = limit.AvailSpace
+			v.UsedPercent = limit.UsedSpace / limit.HardLimit * 100
+			v.NearlyFull = v.UsedPercent >= warnPercent
+		}
+		if pv, ok := pvsMap[gv.Name]; ok {
+			v.StorageClass = pv.ObjectMeta.Annotations["volume.beta.kubernetes.io/storage-class"]
+			v.Status = string(pv.Status.Phase)
+			if pv.Spec.ClaimRef != nil {
+				v.Claim = &Claim{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}
+			}
+		}
+		resp.Volumes = append(resp.Volumes, v)
+	}
+	if len(resp.Volumes) == 0 {
+		return nil, nil
+	}
+	return &resp, nil
+}
+
+// printUsage prints the volume usage report
+func printUsage(out io.Writer, resp *UsageResponse, format string) error {
+	if format == "json" {
+		prettyResp, err := json.MarshalIndent(resp, "", "    ")
+		if err != nil {
+			return fmt.Errorf("marshal error: %v", err)
+		}
+		fmt.Fprintln(out, string(prettyResp))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTORAGECLASS\tCAPACITY\tUSED\tAVAILABLE\tUSE%\tSTATUS\tCLAIM\t")
+	var nearlyFull []string
+	for _, v := range resp.Volumes {
+		capacity, used, available, percent := "Unknown", "Unknown", "Unknown", "Unknown"
+		if v.QuotaSet {
+			capacity = HumanFormat(v.CapacityBytes)
+			used = HumanFormat(v.UsedBytes)
+			available = HumanFormat(v.AvailableBytes)
+			percent = fmt.Sprintf("%.0f%%", v.UsedPercent)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", v.Name, v.StorageClass, capacity, used, available, percent, v.Status, v.Claim.Readable())
+		if v.NearlyFull {
+			nearlyFull = append(nearlyFull, v.Name)
+		}
+	}
+	w.Flush()
+	if len(nearlyFull) > 0 {
+		fmt.Fprintf(out, "\nVolumes that are %.0f%% or more used: %v\n", resp.WarnPercent, nearlyFull)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const usageTestVolumes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <volInfo>
+    <volumes>
+      <volume>
+        <name>storage1</name>
+        <brickCount>1</brickCount>
+        <replicaCount>1</replicaCount>
+        <bricks>
+          <brick uuid="3cf478d7-27da-4382-8e9f-44cc72a7beb2">node1:/data/storage1<name>node1:/data/storage1</name></brick>
+        </bricks>
+      </volume>
+      <volume>
+        <name>storage2</name>
+        <brickCount>1</brickCount>
+        <replicaCount>1</replicaCount>
+        <bricks>
+          <brick uuid="3cf478d7-27da-4382-8e9f-44cc72a7beb2">node1:/data/storage2<name>node1:/data/storage2</name></brick>
+        </bricks>
+      </volume>
+      <volume>
+        <name>storage3</name>
+        <brickCount>1</brickCount>
+        <replicaCount>1</replicaCount>
+        <bricks>
+          <brick uuid="3cf478d7-27da-4382-8e9f-44cc72a7beb2">node1:/data/storage3<name>node1:/data/storage3</name></brick>
+        </bricks>
+      </volume>
+      <count>3</count>
+    </volumes>
+  </volInfo>
+</cliOutput>`
+
+func usageTestQuota(hardLimit, used int64) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <volQuota>
+    <limit>
+      <path>/</path>
+      <hard_limit>%d</hard_limit>
+      <used_space>%d</used_space>
+      <avail_space>%d</avail_space>
+    </limit>
+  </volQuota>
+</cliOutput>`, hardLimit, used, hardLimit-used))
+}
+
+const usageTestPVs = `{
+    "apiVersion": "v1",
+    "items": [
+        {
+            "apiVersion": "v1",
+            "kind": "PersistentVolume",
+            "metadata": {
+                "annotations": {
+                    "volume.beta.kubernetes.io/storage-class": "durable"
+                },
+                "name": "storage1"
+            },
+            "spec": {
+                "claimRef": {
+                    "kind": "PersistentVolumeClaim",
+                    "name": "my-claim",
+                    "namespace": "default"
+                }
+            },
+            "status": {
+                "phase": "Bound"
+            }
+        }
+    ],
+    "kind": "List"
+}`
+
+func TestBuildUsageResponse(t *testing.T) {
+	gluster := fakeGlusterGetter{
+		glusterVolumeList: []byte(usageTestVolumes),
+		glusterQuotas: map[string][]byte{
+			"storage1": usageTestQuota(1073741824, 966367641),
+			"storage2": usageTestQuota(1073741824, 0),
+		},
+	}
+	kubernetes := fakeKubernetesGetter{pvList: []byte(usageTestPVs)}
+	resp, err := buildUsageResponse(gluster, kubernetes, 80)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp == nil || len(resp.Volumes) != 3 {
+		t.Fatalf("expected 3 volumes, got %+v", resp)
+	}
+
+	full := resp.Volumes[0]
+	if !full.QuotaSet || full.CapacityBytes != 1073741824 || full.UsedPercent < 89 || full.UsedPercent > 91 || !full.NearlyFull {
+		t.Errorf("unexpected usage of a nearly full volume: %+v", full)
+	}
+	if full.Status != "Bound" || full.StorageClass != "durable" || full.Claim.Readable() != "default/my-claim" {
+		t.Errorf("unexpected claim of the volume: %+v", full)
+	}
+	empty := resp.Volumes[1]
+	if !empty.QuotaSet || empty.UsedPercent != 0 || empty.NearlyFull || empty.Status != "Unknown" {
+		t.Errorf("unexpected usage of an empty volume: %+v", empty)
+	}
+	noQuota := resp.Volumes[2]
+	if noQuota.QuotaSet || noQuota.NearlyFull {
+		t.Errorf("unexpected usage of a volume without quota: %+v", noQuota)
+	}
+
+	var out bytes.Buffer
+	if err := printUsage(&out, resp, "table"); err != nil {
+		t.Fatalf("unexpected error printing the report: %v", err)
+	}
+	if !strings.Contains(out.String(), "90%") || !strings.Contains(out.String(), "80% or more used: [storage1]") {
+		t.Errorf("unexpected table report:\n%s", out.String())
+	}
+	out.Reset()
+	if err := printUsage(&out, resp, "json"); err != nil {
+		t.Fatalf("unexpected error printing the report: %v", err)
+	}
+	if !strings.Contains(out.String(), `"nearlyFull": true`) {
+		t.Errorf("unexpected json report:\n%s", out.String())
+	}
+}
+
+func TestBuildUsageResponseNoVolumes(t *testing.T) {
+	resp, err := buildUsageResponse(fakeGlusterGetter{isNil: true}, fakeKubernetesGetter{}, 80)
+	if err != nil || resp != nil {
+		t.Errorf("expected no volumes and no error, got %+v and %v", resp, err)
+	}
+	if _, err := buildUsageResponse(fakeGlusterGetter{shouldError: true}, fakeKubernetesGetter{}, 80); err == nil {
+		t.Errorf("expected an error when the volumes cannot be listed")
+	}
+}
@@ -65,3 +65,52 @@ func UnmarshalVolumeQuota(raw string) (*GlusterVolumeQuotaCliOutput, error) {
 
 	return &glusterVolumeQuota, nil
 }
+
+// ReplaceBrick replaces a brick of the volume with a new brick. The data of the
+// replaced brick is not migrated, it must be restored with a heal of the volume.
+func (g RemoteGlusterCLI) ReplaceBrick(volume, oldBrick, newBrick string) error {
+	out, err := g.SSHClient.Output(true, fmt.Sprintf("sudo gluster volume replace-brick %s %s %s commit force", volume, oldBrick, newBrick))
+	if err != nil {
+		return fmt.Errorf("error replacing brick %s of volume %s: %v: %s", oldBrick, volume, err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// HealFull triggers a full self-heal of the volume
+func (g RemoteGlusterCLI) HealFull(volume string) error {
+	out, err := g.SSHClient.Output(true, fmt.Sprintf("sudo gluster volume heal %s full", volume))
+	if err != nil {
+		return fmt.Errorf("error starting heal of volume %s: %v: %s", volume, err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// HealInfo returns the entries of the volume that are pending heal
+func (g RemoteGlusterCLI) HealInfo(volume string) (*GlusterHealInfoCliOutput, error) {
+	raw, err := g.SSHClient.Output(true, fmt.Sprintf("sudo gluster volume heal %s info --xml", volume))
+	if err != nil {
+		return nil, fmt.Errorf("error getting heal info of volume %s: %v", volume, err)
+	}
+	return UnmarshalHealInfo(raw)
+}
+
+func UnmarshalHealInfo(raw string) (*GlusterHealInfoCliOutput, error) {
+	var healInfo GlusterHealInfoCliOutput
+	if err := xml.Unmarshal([]byte(strings.TrimSpace(raw)), &healInfo); err != nil {
+		return nil, fmt.Errorf("error unmarshalling heal info data: %v", err)
+	}
+	if healInfo.HealInfo == nil || healInfo.HealInfo.Bricks == nil {
+		return nil, fmt.Errorf("error getting heal info data")
+	}
+	return &healInfo, nil
+}
+
+// DetachPeer removes the host from the trusted storage pool, even if it is
+// not reachable
+func (g RemoteGlusterCLI) DetachPeer(host string) error {
+	out, err := g.SSHClient.Output(true, fmt.Sprintf("sudo gluster peer detach %s force --mode=script", host))
+	if err != nil {
+		return fmt.Errorf("error detaching peer %s: %v: %s", host, err, strings.TrimSpace(out))
+	}
+	return nil
+}
@@ -41,3 +41,40 @@ func TestUnmarshalVolumeQuota(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalHealInfo(t *testing.T) {
+	raw := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cliOutput>
+  <healInfo>
+    <bricks>
+      <brick hostUuid="9b5bd5e6-6f5e-4b3e-9f3b-3ad5e1b8c2a1">
+        <name>storage01:/data/storage01</name>
+        <status>Connected</status>
+        <numberOfEntries>12</numberOfEntries>
+      </brick>
+      <brick hostUuid="-">
+        <name>storage02:/data/storage01</name>
+        <status>Transport endpoint is not connected</status>
+        <numberOfEntries>-</numberOfEntries>
+      </brick>
+    </bricks>
+  </healInfo>
+  <opRet>0</opRet>
+  <opErrno>0</opErrno>
+  <opErrstr/>
+</cliOutput>`
+	info, err := UnmarshalHealInfo(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bricks := info.HealInfo.Bricks.Brick
+	if len(bricks) != 2 {
+		t.Fatalf("expected 2 bricks, got %d", len(bricks))
+	}
+	if bricks[0].Name != "storage01:/data/storage01" || bricks[0].NumberOfEntries != "12" || bricks[1].NumberOfEntries != "-" {
+		t.Errorf("unexpected bricks: %+v %+v", bricks[0], bricks[1])
+	}
+	if _, err := UnmarshalHealInfo(`<cliOutput><opRet>-1</opRet></cliOutput>`); err == nil {
+		t.Errorf("expected an error when the heal info is missing")
+	}
+}
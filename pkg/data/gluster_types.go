@@ -49,3 +49,24 @@ type GlusterVolumes struct {
 	Count  uint             `xml:" count,omitempty" json:"count,omitempty"`
 	Volume []*GlusterVolume `xml:" volume,omitempty" json:"volume,omitempty"`
 }
+
+// gluster volume heal $VOLUME info --xml
+//==============================================================================
+type GlusterHealInfoCliOutput struct {
+	HealInfo *GlusterHealInfo `xml:" healInfo,omitempty" json:"healInfo,omitempty"`
+}
+
+type GlusterHealInfo struct {
+	Bricks *GlusterHealInfoBricks `xml:" bricks,omitempty" json:"bricks,omitempty"`
+}
+
+type GlusterHealInfoBricks struct {
+	Brick []*GlusterHealInfoBrick `xml:" brick,omitempty" json:"brick,omitempty"`
+}
+
+type GlusterHealInfoBrick struct {
+	Name   string `xml:" name,omitempty" json:"name,omitempty"`
+	Status string `xml:" status,omitempty" json:"status,omitempty"`
+	// The number of entries pending heal, or "-" when the brick is not connected
+	NumberOfEntries string `xml:" numberOfEntries,omitempty" json:"numberOfEntries,omitempty"`
+}
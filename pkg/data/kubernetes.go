@@ -48,6 +48,56 @@ type StatefulSetGetter interface {
 	GetStatefulSet(namespace, name string) (*StatefulSet, error)
 }
 
+// DeploymentGetter gets a deployment
+type DeploymentGetter interface {
+	GetDeployment(namespace, name string) (*Deployment, error)
+}
+
+// PodGetter gets a pod
+type PodGetter interface {
+	GetPod(namespace, name string) (*Pod, error)
+}
+
+// PodLogsGetter gets the logs of a pod
+type PodLogsGetter interface {
+	GetPodLogs(namespace, name string) (string, error)
+}
+
+// ServiceGetter gets a service
+type ServiceGetter interface {
+	GetService(namespace, name string) (*Service, error)
+}
+
+// StorageClassLister lists the storage classes of a Kubernetes cluster
+type StorageClassLister interface {
+	ListStorageClasses() (*StorageClassList, error)
+}
+
+// ManifestApplier creates or updates the resources of a manifest
+type ManifestApplier interface {
+	Apply(manifest string) error
+}
+
+// NamespaceDeleter deletes a namespace, along with the resources it contains
+type NamespaceDeleter interface {
+	DeleteNamespace(name string) error
+}
+
+// PodDisruptionBudgetLister lists the pod disruption budgets of a Kubernetes cluster
+type PodDisruptionBudgetLister interface {
+	ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error)
+}
+
+// NodeLister lists the nodes registered in a Kubernetes cluster
+type NodeLister interface {
+	ListNodes() (*NodeList, error)
+}
+
+// NodeCordoner marks a node as unschedulable
+type NodeCordoner interface {
+	Cordon(node string) error
+}
+
 type KubernetesClient interface {
 	PodLister
 	PVLister
@@ -210,6 +260,160 @@ func (k RemoteKubectl) GetStatefulSet(namespace, name string) (*StatefulSet, err
 	return &s, nil
 }
 
+// GetDeployment returns the deployment with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetDeployment(namespace, name string) (*Deployment, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get deployment --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Deployment: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Deployment %s/%s was not found", namespace, name)
+	}
+	var d Deployment
+	if err := json.Unmarshal([]byte(raw), &d); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Deployment: %v", err)
+	}
+	return &d, nil
+}
+
+// GetPod returns the pod with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetPod(namespace, name string) (*Pod, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get pod --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Pod: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Pod %s/%s was not found", namespace, name)
+	}
+	var p Pod
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Pod: %v", err)
+	}
+	return &p, nil
+}
+
+// GetPodLogs returns the logs of the first container of the pod with the
+// given name in the given namespace
+func (k RemoteKubectl) GetPodLogs(namespace, name string) (string, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config logs --namespace %s %s", namespace, name)
+	out, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return "", fmt.Errorf("error getting logs of Pod %s/%s: %v: %s", namespace, name, err, out)
+	}
+	return out, nil
+}
+
+// GetService returns the service with the given name in the given namespace.
+// If not found, returns an error.
+func (k RemoteKubectl) GetService(namespace, name string) (*Service, error) {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get service --namespace %s -o json %s", namespace, name)
+	raw, err := k.SSHClient.Output(true, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Service: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return nil, fmt.Errorf("Service %s/%s was not found", namespace, name)
+	}
+	var s Service
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, fmt.Errorf("error unmarshalling Service: %v", err)
+	}
+	return &s, nil
+}
+
+// ListStorageClasses returns StorageClass data
+func (k RemoteKubectl) ListStorageClasses() (*StorageClassList, error) {
+	raw, err := k.SSHClient.Output(true, "sudo kubectl --kubeconfig /root/.kube/config get storageclass -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage class data: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return &StorageClassList{}, nil
+	}
+	var l StorageClassList
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		return nil, fmt.Errorf("error unmarshalling storage class data: %v", err)
+	}
+	return &l, nil
+}
+
+// ListPodDisruptionBudgets returns the pod disruption budgets of all namespaces
+func (k RemoteKubectl) ListPodDisruptionBudgets() (*PodDisruptionBudgetList, error) {
+	raw, err := k.SSHClient.Output(true, "sudo kubectl --kubeconfig /root/.kube/config get pdb --all-namespaces=true -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting pod disruption budget data: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return &PodDisruptionBudgetList{}, nil
+	}
+	var l PodDisruptionBudgetList
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		return nil, fmt.Errorf("error unmarshalling pod disruption budget data: %v", err)
+	}
+	return &l, nil
+}
+
+// ListNodes returns the nodes registered in the cluster
+func (k RemoteKubectl) ListNodes() (*NodeList, error) {
+	raw, err := k.SSHClient.Output(true, "sudo kubectl --kubeconfig /root/.kube/config get nodes -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting node data: %v", err)
+	}
+	if isNoResourcesResponse(raw) {
+		return &NodeList{}, nil
+	}
+	var l NodeList
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		return nil, fmt.Errorf("error unmarshalling node data: %v", err)
+	}
+	return &l, nil
+}
+
+// Cordon marks the node as unschedulable
+func (k RemoteKubectl) Cordon(node string) error {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config cordon %s", node)
+	if out, err := k.SSHClient.Output(true, cmd); err != nil {
+		return fmt.Errorf("error cordoning node %s: %v: %s", node, err, out)
+	}
+	return nil
+}
+
+// Apply creates or updates the resources defined in the manifest
+func (k RemoteKubectl) Apply(manifest string) error {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config apply -f - <<'EOF'\n%s\nEOF", manifest)
+	if out, err := k.SSHClient.Output(false, cmd); err != nil {
+		return fmt.Errorf("error applying manifest: %v: %s", err, out)
+	}
+	return nil
+}
+
+// DeleteNamespace deletes the namespace with the given name. The command
+// returns without waiting for the resources of the namespace to be deleted.
+func (k RemoteKubectl) DeleteNamespace(name string) error {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config delete namespace %s --ignore-not-found", name)
+	if out, err := k.SSHClient.Output(true, cmd); err != nil {
+		return fmt.Errorf("error deleting namespace %s: %v: %s", name, err, out)
+	}
+	return nil
+}
+
+// Delete deletes the resource of the given kind and name. The namespace is
+// ignored when empty. Nothing is done if the resource does not exist.
+func (k RemoteKubectl) Delete(kind, namespace, name string) error {
+	cmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config delete %s %s --ignore-not-found", kind, name)
+	if namespace != "" {
+		cmd += " -n " + namespace
+	}
+	if out, err := k.SSHClient.Output(true, cmd); err != nil {
+		return fmt.Errorf("error deleting %s %s: %v: %s", kind, name, err, out)
+	}
+	return nil
+}
+
 // kubectl will print this message when no resources are returned
 func isNoResourcesResponse(s string) bool {
 	if strings.Contains(strings.TrimSpace(s), "No resources found") {
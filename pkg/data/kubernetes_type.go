@@ -6,7 +6,27 @@ type PodList struct {
 
 type Pod struct {
 	ObjectMeta `json:"metadata,omitempty"`
-	Spec       PodSpec `json:"spec,omitempty"`
+	Spec       PodSpec   `json:"spec,omitempty"`
+	Status     PodStatus `json:"status,omitempty"`
+}
+
+// PodPhase is a label for the condition of a pod at the current time.
+type PodPhase string
+
+// These are the valid phases of a pod.
+const (
+	PodPending   PodPhase = "Pending"
+	PodRunning   PodPhase = "Running"
+	PodSucceeded PodPhase = "Succeeded"
+	PodFailed    PodPhase = "Failed"
+	PodUnknown   PodPhase = "Unknown"
+)
+
+// PodStatus represents information about the status of a pod.
+type PodStatus struct {
+	Phase PodPhase `json:"phase,omitempty"`
+	// A human readable message indicating details about why the pod is in this condition.
+	Message string `json:"message,omitempty"`
 }
 
 type ObjectMeta struct {
@@ -123,6 +143,21 @@ type PersistentVolumeClaim struct {
 
 	// Spec defines the volume requested by a pod author
 	Spec PersistentVolumeClaimSpec
+
+	// Status represents the current information/status of a persistent volume claim
+	Status PersistentVolumeClaimStatus `json:"status,omitempty"`
+}
+
+// PersistentVolumeClaimPhase is the phase of a persistent volume claim
+type PersistentVolumeClaimPhase string
+
+// ClaimBound is the phase of a claim that is bound to a persistent volume
+const ClaimBound PersistentVolumeClaimPhase = "Bound"
+
+// PersistentVolumeClaimStatus is the current status of a persistent volume claim.
+type PersistentVolumeClaimStatus struct {
+	// Phase represents the current phase of PersistentVolumeClaim.
+	Phase PersistentVolumeClaimPhase `json:"phase,omitempty"`
 }
 
 // PersistentVolumeClaimSpec describes the common attributes of storage devices
@@ -245,3 +280,212 @@ type StatefulSetStatus struct {
 	// Replicas is the number of actual replicas.
 	Replicas int32
 }
+
+// Deployment enables declarative updates for Pods and ReplicaSets.
+type Deployment struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DeploymentSpec   `json:"spec,omitempty"`
+	Status DeploymentStatus `json:"status,omitempty"`
+}
+
+// DeploymentSpec is the specification of the desired behavior of the Deployment.
+type DeploymentSpec struct {
+	// Number of desired pods.
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+// DeploymentStatus is the most recently observed status of the Deployment.
+type DeploymentStatus struct {
+	// Total number of non-terminated pods targeted by this deployment that have the desired template spec.
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+	// Total number of available pods (ready for at least minReadySeconds) targeted by this deployment.
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+}
+
+// Service is a named abstraction of software service
+type Service struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceSpec `json:"spec,omitempty"`
+}
+
+// ServiceSpec describes the attributes that a user creates on a service.
+type ServiceSpec struct {
+	// The IP address of the service, allocated by the master.
+	ClusterIP string `json:"clusterIP,omitempty"`
+}
+
+// StorageClassList is a collection of storage classes.
+type StorageClassList struct {
+	TypeMeta `json:",inline"`
+	ListMeta `json:"metadata,omitempty"`
+	Items    []StorageClass `json:"items"`
+}
+
+// StorageClass describes the parameters for a class of storage for which
+// PersistentVolumes can be dynamically provisioned.
+type StorageClass struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+	// Provisioner indicates the type of the provisioner.
+	Provisioner string `json:"provisioner"`
+}
+
+// IsDefault returns true if the storage class is the default class of the
+// cluster, which is used for claims that do not request a class.
+func (s StorageClass) IsDefault() bool {
+	return s.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" ||
+		s.Annotations["storageclass.beta.kubernetes.io/is-default-class"] == "true"
+}
+
+// PodDisruptionBudgetList is a collection of pod disruption budgets.
+type PodDisruptionBudgetList struct {
+	TypeMeta `json:",inline"`
+	ListMeta `json:"metadata,omitempty"`
+	Items    []PodDisruptionBudget `json:"items"`
+}
+
+// PodDisruptionBudget limits the number of pods of a collection that can be
+// evicted at the same time.
+type PodDisruptionBudget struct {
+	TypeMeta   `json:",inline"`
+	ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodDisruptionBudgetSpec   `json:"spec,omitempty"`
+	Status PodDisruptionBudgetStatus `json:"status,omitempty"`
+}
+
+// PodDisruptionBudgetSpec is the description of a pod disruption budget.
+type PodDisruptionBudgetSpec struct {
+	// Label query over the pods whose evictions are managed by the budget.
+	Selector *LabelSelector `json:"selector,omitempty"`
+}
+
+// PodDisruptionBudgetStatus represents information about the status of a
+// pod disruption budget.
+type PodDisruptionBudgetStatus struct {
+	// Number of pod disruptions that are currently allowed.
+	PodDisruptionsAllowed int32 `json:"disruptionsAllowed"`
+	// Current number of healthy pods.
+	CurrentHealthy int32 `json:"currentHealthy"`
+	// Minimum desired number of healthy pods.
+	DesiredHealthy int32 `json:"desiredHealthy"`
+}
+
+// LabelSelector is a label query over a set of resources.
+type LabelSelector struct {
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty"`
+	MatchExpressions []LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// LabelSelectorRequirement is a selector that contains values, a key, and an
+// operator that relates the key and values.
+type LabelSelectorRequirement struct {
+	Key string `json:"key"`
+	// Valid operators are In, NotIn, Exists and DoesNotExist.
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// Matches returns true if the labels satisfy all the requirements of the
+// selector. An empty selector matches nothing.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	if s == nil || (len(s.MatchLabels) == 0 && len(s.MatchExpressions) == 0) {
+		return false
+	}
+	for k, v := range s.MatchLabels {
+		if val, ok := labels[k]; !ok || val != v {
+			return false
+		}
+	}
+	for _, r := range s.MatchExpressions {
+		val, ok := labels[r.Key]
+		switch r.Operator {
+		case "In":
+			if !ok || !containsString(r.Values, val) {
+				return false
+			}
+		case "NotIn":
+			if ok && containsString(r.Values, val) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeList is a list of the nodes registered in the cluster
+type NodeList struct {
+	TypeMeta `json:",inline"`
+	ListMeta `json:"metadata,omitempty"`
+	Items    []Node `json:"items"`
+}
+
+// Node is a node registered in the cluster by its kubelet
+type Node struct {
+	ObjectMeta `json:"metadata,omitempty"`
+	Status     NodeStatus `json:"status,omitempty"`
+}
+
+// NodeStatus is the observed state of a node
+type NodeStatus struct {
+	Conditions []NodeCondition `json:"conditions,omitempty"`
+	Addresses  []NodeAddress   `json:"addresses,omitempty"`
+}
+
+// NodeCondition describes an aspect of the state of a node, such as Ready
+type NodeCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// NodeAddress is an address of a node, such as its InternalIP or Hostname
+type NodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+// Ready returns true when the Ready condition of the node is true
+func (n Node) Ready() bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// HasAddress returns true when the address is one of the addresses of the
+// node
+func (n Node) HasAddress(address string) bool {
+	for _, a := range n.Status.Addresses {
+		if a.Address == address {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package data
+
+import "testing"
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend"}
+	tests := []struct {
+		selector *LabelSelector
+		matches  bool
+	}{
+		{nil, false},
+		{&LabelSelector{}, false},
+		{&LabelSelector{MatchLabels: map[string]string{"app": "web"}}, true},
+		{&LabelSelector{MatchLabels: map[string]string{"app": "db"}}, false},
+		{&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "In", Values: []string{"frontend", "backend"}}}}, true},
+		{&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "tier", Operator: "NotIn", Values: []string{"frontend"}}}}, false},
+		{&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "app", Operator: "Exists"}}}, true},
+		{&LabelSelector{MatchExpressions: []LabelSelectorRequirement{{Key: "app", Operator: "DoesNotExist"}}}, false},
+		{&LabelSelector{MatchLabels: map[string]string{"app": "web"}, MatchExpressions: []LabelSelectorRequirement{{Key: "track", Operator: "DoesNotExist"}}}, true},
+	}
+	for i, test := range tests {
+		if got := test.selector.Matches(labels); got != test.matches {
+			t.Errorf("test %d: expected %v, but got %v", i, test.matches, got)
+		}
+	}
+}
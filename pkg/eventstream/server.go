@@ -0,0 +1,229 @@
+// Package eventstream streams the explained events of the operations to
+// remote user interfaces over websockets. Each event is sent as a text
+// frame containing the JSON mapping of the ExplainEvent message defined in
+// events.proto.
+package eventstream
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+)
+
+// Path of the websocket endpoint that streams the events
+const Path = "/events"
+
+// subscriberBuffer is the number of events buffered for each subscriber.
+// The events are dropped for the subscribers that fall behind.
+const subscriberBuffer = 256
+
+// Server streams the events written to it to the connected clients. An
+// event is a line of JSON, as written by explain.JSONExplainer. The server is
+// an http.Handler of the websocket endpoint.
+type Server struct {
+	// AllowedOrigins are the origins, e.g. https://ui.example.com, of the
+	// web pages that can connect to the server, in addition to the pages
+	// served from the host of the server. Clients that do not send an
+	// Origin header, which are not browsers, are always accepted.
+	AllowedOrigins []string
+	// AllowedHosts are the host names, e.g. bastion.example.com, clients
+	// can connect to the server with, in addition to localhost, the IP
+	// addresses and the host of the listen address
+	AllowedHosts []string
+	listener     net.Listener
+	// listenHost is the host of the address the server listens on
+	listenHost string
+	mu         sync.Mutex
+	clients    map[chan []byte]struct{}
+	// pending is the incomplete line of the last write
+	pending []byte
+}
+
+// NewServer returns a server to register in an existing http server
+func NewServer() *Server {
+	return &Server{clients: map[chan []byte]struct{}{}}
+}
+
+// Listen starts a server that streams the events on the address. The server
+// listens on localhost when the address has no host, e.g. :8090.
+func Listen(addr string) (*Server, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	if host == "" {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %q: %v", addr, err)
+	}
+	s := NewServer()
+	s.listener = l
+	s.listenHost = host
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	go http.Serve(l, mux)
+	return s, nil
+}
+
+// Addr is the address the server started with Listen listens on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops listening for new clients, when the server was started with
+// Listen
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// Write broadcasts each complete line to the connected clients
+func (s *Server) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, p...)
+	for {
+		i := bytes.IndexByte(s.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := make([]byte, i)
+		copy(line, s.pending[:i])
+		s.pending = s.pending[i+1:]
+		for c := range s.clients {
+			select {
+			case c <- line:
+			default:
+				// the client fell behind, drop the event
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// ServeHTTP upgrades the request to a websocket, and streams the events to
+// it until the client goes away
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !HostAllowed(r.Host, s.AllowedHosts) && !HostAllowed(r.Host, []string{s.listenHost}) {
+		logging.Warn("rejecting event stream client", "remoteAddr", r.RemoteAddr, "host", r.Host)
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return
+	}
+	if !s.originAllowed(r) {
+		logging.Warn("rejecting event stream client", "remoteAddr", r.RemoteAddr, "origin", r.Header.Get("Origin"))
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, err := upgrade(w, r)
+	if err != nil {
+		logging.Warn("error accepting event stream client", "remoteAddr", r.RemoteAddr, "error", err)
+		return
+	}
+	defer conn.Close()
+	events := s.subscribe()
+	defer s.unsubscribe(events)
+
+	// Read the control frames of the client, until it closes the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			op, payload, err := conn.readFrame()
+			if err != nil {
+				return
+			}
+			switch op {
+			case opPing:
+				conn.writeFrame(opPong, payload)
+			case opClose:
+				conn.writeFrame(opClose, nil)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case e := <-events:
+			if err := conn.writeFrame(opText, e); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// HostAllowed returns true if the Host header of a request is localhost, an
+// IP address or one of the allowed hosts. Under DNS rebinding, a page served
+// from a name the attacker controls can send requests to a server listening
+// on localhost, and both the Host header and the origin of the page are that
+// name, so the origin of the page alone cannot be trusted.
+func HostAllowed(hostHeader string, allowedHosts []string) bool {
+	host := hostHeader
+	if h, _, err := net.SplitHostPort(hostHeader); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+	for _, h := range allowedHosts {
+		if h != "" && strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// originAllowed returns true if the page that opened the websocket is
+// served from the host of the server or from one of the allowed origins, so
+// that the events cannot be read by the other pages open in the browser
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, o := range s.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) subscribe() chan []byte {
+	c := make(chan []byte, subscriberBuffer)
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+	return c
+}
+
+func (s *Server) unsubscribe(c chan []byte) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+}
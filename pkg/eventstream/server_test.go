@@ -0,0 +1,248 @@
+package eventstream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example of RFC 6455
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %q", got)
+	}
+}
+
+// handshake sends the websocket handshake to the server, with the origin
+// header if it is set
+func handshake(t *testing.T, s *Server, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	return handshakeWithHost(t, s, s.Addr(), origin)
+}
+
+// handshakeWithHost opens a websocket with the Host header
+func handshakeWithHost(t *testing.T, s *Server, host, origin string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+	var originHeader string
+	if origin != "" {
+		originHeader = "Origin: " + origin + "\r\n"
+	}
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\n%sUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", Path, host, originHeader)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("error reading handshake response: %v", err)
+	}
+	return conn, r, resp
+}
+
+// dial connects a websocket client to the server
+func dial(t *testing.T, s *Server) (net.Conn, *bufio.Reader) {
+	conn, r, resp := handshake(t, s, "")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %s", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %q", got)
+	}
+	return conn, r
+}
+
+func readFrame(t *testing.T, r *bufio.Reader) (byte, string) {
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		t.Fatalf("error reading frame: %v", err)
+	}
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var l [2]byte
+		io.ReadFull(r, l[:])
+		n = uint64(binary.BigEndian.Uint16(l[:]))
+	case 127:
+		var l [8]byte
+		io.ReadFull(r, l[:])
+		n = binary.BigEndian.Uint64(l[:])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("error reading payload: %v", err)
+	}
+	return h[0] & 0x0F, string(payload)
+}
+
+// writeFrame writes a masked frame, as sent by clients
+func writeFrame(conn net.Conn, opcode byte, payload string) {
+	mask := [4]byte{1, 2, 3, 4}
+	b := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	b = append(b, mask[:]...)
+	for i := 0; i < len(payload); i++ {
+		b = append(b, payload[i]^mask[i%4])
+	}
+	conn.Write(b)
+}
+
+// waitForClients waits until the server has n subscribed clients
+func waitForClients(t *testing.T, s *Server, n int) {
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		count := len(s.clients)
+		s.mu.Unlock()
+		if count == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d clients", n)
+}
+
+func TestServerStreamsEvents(t *testing.T) {
+	s, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	defer s.Close()
+	conn, r := dial(t, s)
+	defer conn.Close()
+	waitForClients(t, s, 1)
+
+	// An event split across writes is sent once complete
+	s.Write([]byte(`{"type":"play_start",`))
+	s.Write([]byte(`"play":"etcd"}` + "\n" + `{"type":"play_end"}` + "\n"))
+	long := `{"stdout":"` + strings.Repeat("x", 300) + `"}`
+	s.Write([]byte(long + "\n"))
+
+	expected := []string{`{"type":"play_start","play":"etcd"}`, `{"type":"play_end"}`, long}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, e := range expected {
+		op, payload := readFrame(t, r)
+		if op != opText {
+			t.Errorf("expected a text frame, got opcode %d", op)
+		}
+		if payload != e {
+			t.Errorf("expected event %s, got %s", e, payload)
+		}
+	}
+
+	writeFrame(conn, opPing, "hello")
+	if op, payload := readFrame(t, r); op != opPong || payload != "hello" {
+		t.Errorf("expected pong with the payload of the ping, got opcode %d with %q", op, payload)
+	}
+	writeFrame(conn, opClose, "")
+	if op, _ := readFrame(t, r); op != opClose {
+		t.Errorf("expected close frame, got opcode %d", op)
+	}
+	waitForClients(t, s, 0)
+}
+
+func TestServerRejectsPlainRequests(t *testing.T) {
+	s, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	defer s.Close()
+	resp, err := http.Get("http://" + s.Addr() + Path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("expected status 426, got %s", resp.Status)
+	}
+}
+
+func TestListenDefaultsToLocalhost(t *testing.T) {
+	s, err := Listen(":0")
+	if err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	defer s.Close()
+	if host, _, _ := net.SplitHostPort(s.Addr()); host != "127.0.0.1" {
+		t.Errorf("expected the server to listen on localhost, got %s", s.Addr())
+	}
+}
+
+func TestServerChecksOrigin(t *testing.T) {
+	s, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	defer s.Close()
+	s.AllowedOrigins = []string{"https://ui.example.com/"}
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"http://" + s.Addr(), http.StatusSwitchingProtocols},
+		{"https://ui.example.com", http.StatusSwitchingProtocols},
+		{"https://evil.example.com", http.StatusForbidden},
+		{"http://ui.example.com", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		conn, _, resp := handshake(t, s, test.origin)
+		conn.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("expected status %d for origin %q, got %s", test.status, test.origin, resp.Status)
+		}
+	}
+}
+
+func TestServerChecksHost(t *testing.T) {
+	s, err := Listen("localhost:0")
+	if err != nil {
+		t.Fatalf("error starting server: %v", err)
+	}
+	defer s.Close()
+	_, port, _ := net.SplitHostPort(s.Addr())
+	s.AllowedHosts = []string{"bastion.example.com"}
+	tests := []struct {
+		host   string
+		origin string
+		status int
+	}{
+		{s.Addr(), "http://" + s.Addr(), http.StatusSwitchingProtocols},
+		{"localhost:" + port, "", http.StatusSwitchingProtocols},
+		{"[::1]:" + port, "", http.StatusSwitchingProtocols},
+		{"bastion.example.com:" + port, "", http.StatusSwitchingProtocols},
+		// DNS rebinding: the page and the Host header are the attacker's name
+		{"rebind.example.com:" + port, "http://rebind.example.com:" + port, http.StatusForbidden},
+		{"", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		conn, _, resp := handshakeWithHost(t, s, test.host, test.origin)
+		conn.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("expected status %d for host %q, got %s", test.status, test.host, resp.Status)
+		}
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"127.0.0.1:8090", true},
+		{"10.0.0.5:8090", true},
+		{"[::1]:8090", true},
+		{"LOCALHOST:8090", true},
+		{"localhost", true},
+		{"ui.example.com:8090", true},
+		{"evil.example.com:8090", false},
+		{"localhost.evil.example.com", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := HostAllowed(test.host, []string{"ui.example.com"}); got != test.allowed {
+			t.Errorf("expected host %q allowed to be %v, got %v", test.host, test.allowed, got)
+		}
+	}
+}
@@ -62,13 +62,13 @@ func NewCmdClient(out io.Writer) *cobra.Command {
 }
 
 func runClient(out io.Writer, opts clientOpts) error {
-	if err := validateOutputType(opts.outputType); err != nil {
+	if err := ValidateOutputType(opts.outputType); err != nil {
 		return err
 	}
 	if opts.nodeRoles == "" {
 		return fmt.Errorf("--node-roles is required")
 	}
-	roles, err := getNodeRoles(opts.nodeRoles)
+	roles, err := GetNodeRoles(opts.nodeRoles)
 	if err != nil {
 		return err
 	}
@@ -76,7 +76,7 @@ func runClient(out io.Writer, opts clientOpts) error {
 	if err != nil {
 		return fmt.Errorf("error creating inspector client: %v", err)
 	}
-	rules, err := getRulesFromFileOrDefault(out, opts.rulesFile, opts.useUpgradeDefaults, opts.additionalVariables)
+	rules, err := GetRules(out, opts.rulesFile, opts.useUpgradeDefaults, opts.additionalVariables)
 	if err != nil {
 		return err
 	}
@@ -85,7 +85,7 @@ func runClient(out io.Writer, opts clientOpts) error {
 	if err != nil {
 		return fmt.Errorf("error running inspector against remote node: %v", err)
 	}
-	if err := printResults(out, results, opts.outputType); err != nil {
+	if err := PrintResults(out, results, opts.outputType); err != nil {
 		return err
 	}
 	for _, r := range results {
@@ -8,7 +8,9 @@ import (
 	"github.com/apprenda/kismatic/pkg/inspector/rule"
 )
 
-func getNodeRoles(commaSepRoles string) ([]string, error) {
+// GetNodeRoles returns the node roles in the comma-separated list, or an error
+// if any of them is not a valid role
+func GetNodeRoles(commaSepRoles string) ([]string, error) {
 	roles := strings.Split(commaSepRoles, ",")
 	for _, r := range roles {
 		if r != "etcd" && r != "master" && r != "worker" && r != "ingress" && r != "storage" {
@@ -18,7 +20,9 @@ func getNodeRoles(commaSepRoles string) ([]string, error) {
 	return roles, nil
 }
 
-func getRulesFromFileOrDefault(out io.Writer, file string, useUpgradeRules bool, vars map[string]string) ([]rule.Rule, error) {
+// GetRules returns the rules read from the file, or the default install or
+// upgrade rules when the file is not set. The rules are templated with vars.
+func GetRules(out io.Writer, file string, useUpgradeRules bool, vars map[string]string) ([]rule.Rule, error) {
 	if file != "" {
 		rules, err := rule.ReadFromFile(file, vars)
 		if err != nil {
@@ -35,7 +39,8 @@ func getRulesFromFileOrDefault(out io.Writer, file string, useUpgradeRules bool,
 	return rule.DefaultRules(vars), nil
 }
 
-func validateOutputType(outputType string) error {
+// ValidateOutputType returns an error if the output type is not supported
+func ValidateOutputType(outputType string) error {
 	if outputType != "json" && outputType != "table" {
 		return fmt.Errorf("output type %q not supported", outputType)
 	}
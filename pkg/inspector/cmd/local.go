@@ -61,15 +61,15 @@ func runLocal(out io.Writer, opts localOpts) error {
 	if opts.nodeRoles == "" {
 		return fmt.Errorf("node role is required")
 	}
-	roles, err := getNodeRoles(opts.nodeRoles)
+	roles, err := GetNodeRoles(opts.nodeRoles)
 	if err != nil {
 		return err
 	}
-	if err = validateOutputType(opts.outputType); err != nil {
+	if err = ValidateOutputType(opts.outputType); err != nil {
 		return err
 	}
 	// Gather rules
-	rules, err := getRulesFromFileOrDefault(out, opts.rulesFile, opts.useUpgradeDefaults, opts.additionalVariables)
+	rules, err := GetRules(out, opts.rulesFile, opts.useUpgradeDefaults, opts.additionalVariables)
 	if err != nil {
 		return err
 	}
@@ -97,7 +97,7 @@ func runLocal(out io.Writer, opts localOpts) error {
 	if err != nil {
 		return fmt.Errorf("error running local rules: %v", err)
 	}
-	if err := printResults(out, results, opts.outputType); err != nil {
+	if err := PrintResults(out, results, opts.outputType); err != nil {
 		return fmt.Errorf("error printing results: %v", err)
 	}
 	for _, r := range results {
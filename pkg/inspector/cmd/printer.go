@@ -9,7 +9,8 @@ import (
 	"github.com/apprenda/kismatic/pkg/inspector/rule"
 )
 
-func printResults(out io.Writer, results []rule.Result, outputType string) error {
+// PrintResults prints the results in the output type, "json" or "table"
+func PrintResults(out io.Writer, results []rule.Result, outputType string) error {
 	switch outputType {
 	case "json":
 		return printResultsAsJSON(out, results)
@@ -48,7 +48,7 @@ func runServer(out io.Writer, opts serverOpts) error {
 	if opts.nodeRoles == "" {
 		return fmt.Errorf("--node-roles is required")
 	}
-	nodeFacts, err := getNodeRoles(opts.nodeRoles)
+	nodeFacts, err := GetNodeRoles(opts.nodeRoles)
 	if err != nil {
 		return err
 	}
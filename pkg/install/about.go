@@ -59,57 +59,46 @@ func IsLessThanVersion(this semver.Version, that string) bool {
 	return this.LT(thatVersion)
 }
 
+const (
+	ketVersionFile       = "/etc/kismatic-version"
+	componentVersionFile = "/etc/component-versions"
+)
+
 // ListVersions connects to the cluster described in the plan file and
 // gathers version information about it.
 func ListVersions(plan *Plan) (ClusterVersion, error) {
+	return listVersions(plan, false)
+}
+
+// ListInstalledVersions connects to the cluster described in the plan file and
+// gathers version information about the nodes that have been installed.
+// Nodes that have not been installed by Kismatic are not included.
+func ListInstalledVersions(plan *Plan) (ClusterVersion, error) {
+	return listVersions(plan, true)
+}
+
+func listVersions(plan *Plan, skipNotInstalled bool) (ClusterVersion, error) {
 	nodes := plan.GetUniqueNodes()
 	cv := ClusterVersion{
 		Nodes: []ListableNode{},
 	}
 
-	sshDeets := plan.Cluster.SSH
-	ketVerFile := "/etc/kismatic-version"
-	componentVerFile := "/etc/component-versions"
-	for i, node := range nodes {
-		client, err := ssh.NewClient(node.IP, sshDeets.Port, sshDeets.User, sshDeets.Key)
-		if err != nil {
-			return cv, fmt.Errorf("error creating SSH client: %v", err)
-		}
-
-		// get KET version
-		ketOutput, err := client.Output(false, fmt.Sprintf("cat %s", ketVerFile))
+	for _, node := range nodes {
+		thisVersion, versions, installed, err := nodeVersions(node, plan.Cluster.SSH.operationConfig())
 		if err != nil {
-			// the output var contains the actual error message from the cat command, which has
-			// more meaningful info
-			return cv, fmt.Errorf("error getting KET version for node %q: %q", node.Host, ketOutput)
+			return cv, err
 		}
-
-		thisVersion, err := parseVersion(ketOutput)
-		if err != nil {
-			return cv, fmt.Errorf("invalid version %q found in version file %q of node %s", ketOutput, ketVerFile, node.Host)
-		}
-
-		// get component versions
-		versionsOutput, err := client.Output(false, fmt.Sprintf("cat %s", componentVerFile))
-		// don't fail if the file is not found, will default to empty
-		// TODO remove
-		if err != nil && !strings.Contains(versionsOutput, "No such file or directory") {
-			// the output var contains the actual error message from the cat command, which has
-			// more meaningful info
-			return cv, fmt.Errorf("error getting component versions for node %q: %q", node.Host, versionsOutput)
-		}
-		versions := ComponentVersions{}
-		if !strings.Contains(versionsOutput, "No such file or directory") {
-			err = yaml.Unmarshal([]byte(versionsOutput), &versions)
-			if err != nil {
-				return cv, fmt.Errorf("error unmarshalling component versions file: %q", componentVerFile)
+		if !installed {
+			if skipNotInstalled {
+				continue
 			}
+			return cv, fmt.Errorf("error getting KET version for node %q: %q was not found", node.Host, ketVersionFile)
 		}
 
 		cv.Nodes = append(cv.Nodes, ListableNode{node, plan.GetRolesForIP(node.IP), thisVersion, versions})
 
 		// If looking at the first node, set the versions and move on
-		if i == 0 {
+		if len(cv.Nodes) == 1 {
 			cv.EarliestVersion = thisVersion
 			cv.LatestVersion = thisVersion
 			continue
@@ -127,6 +116,49 @@ func ListVersions(plan *Plan) (ClusterVersion, error) {
 	return cv, nil
 }
 
+// nodeVersions reads the KET and component versions from the node.
+// Returns false if the KET version file does not exist on the node.
+func nodeVersions(node Node, sshDeets SSHConfig) (semver.Version, ComponentVersions, bool, error) {
+	versions := ComponentVersions{}
+	client, err := ssh.NewClient(node.IP, sshDeets.Port, sshDeets.User, sshDeets.Key)
+	if err != nil {
+		return semver.Version{}, versions, false, fmt.Errorf("error creating SSH client: %v", err)
+	}
+
+	// get KET version
+	ketOutput, err := client.Output(false, fmt.Sprintf("cat %s", ketVersionFile))
+	if err != nil && strings.Contains(ketOutput, "No such file or directory") {
+		return semver.Version{}, versions, false, nil
+	}
+	if err != nil {
+		// the output var contains the actual error message from the cat command, which has
+		// more meaningful info
+		return semver.Version{}, versions, false, fmt.Errorf("error getting KET version for node %q: %q", node.Host, ketOutput)
+	}
+
+	thisVersion, err := parseVersion(ketOutput)
+	if err != nil {
+		return semver.Version{}, versions, false, fmt.Errorf("invalid version %q found in version file %q of node %s", ketOutput, ketVersionFile, node.Host)
+	}
+
+	// get component versions
+	versionsOutput, err := client.Output(false, fmt.Sprintf("cat %s", componentVersionFile))
+	// don't fail if the file is not found, will default to empty
+	// TODO remove
+	if err != nil && !strings.Contains(versionsOutput, "No such file or directory") {
+		// the output var contains the actual error message from the cat command, which has
+		// more meaningful info
+		return semver.Version{}, versions, false, fmt.Errorf("error getting component versions for node %q: %q", node.Host, versionsOutput)
+	}
+	if !strings.Contains(versionsOutput, "No such file or directory") {
+		err = yaml.Unmarshal([]byte(versionsOutput), &versions)
+		if err != nil {
+			return semver.Version{}, versions, false, fmt.Errorf("error unmarshalling component versions file: %q", componentVersionFile)
+		}
+	}
+	return thisVersion, versions, true, nil
+}
+
 // NodesWithRoles returns a filtered list of ListableNode slice based on the node's roles
 func NodesWithRoles(nodes []ListableNode, roles ...string) []ListableNode {
 	var subset []ListableNode
@@ -139,3 +171,18 @@ func NodesWithRoles(nodes []ListableNode, roles ...string) []ListableNode {
 	}
 	return subset
 }
+
+// NodesToUpgrade returns the nodes that require an upgrade, and the nodes
+// that are at the target version
+func NodesToUpgrade(plan Plan, cv ClusterVersion) (toUpgrade []ListableNode, toSkip []ListableNode) {
+	for _, n := range cv.Nodes {
+		// run if KET version or component versions are different
+		// don't check component versions if the node has only "etcd" role
+		if IsOlderVersion(n.Version) || (!(len(n.Roles) == 1 && n.Roles[0] == "etcd") && plan.Cluster.Version != n.ComponentVersions.Kubernetes) {
+			toUpgrade = append(toUpgrade, n)
+		} else {
+			toSkip = append(toSkip, n)
+		}
+	}
+	return toUpgrade, toSkip
+}
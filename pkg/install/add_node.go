@@ -11,10 +11,14 @@ var errMissingClusterCA = errors.New("The Certificate Authority's private key an
 	"the cluster are required for adding worker nodes.")
 
 // AddNode adds a worker node to the original cluster described in the plan.
-// If successful, the updated plan is returned.
-func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []string, restartServices bool) (*Plan, error) {
+// If successful, the updated plan is returned. The summary of the tasks that
+// were run is returned whether the node was added or not.
+func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []string, restartServices bool) (*Plan, RunSummary, error) {
+	if err := VerifyAssetsCluster(originalPlan, ae.options.GeneratedAssetsDirectory); err != nil {
+		return nil, RunSummary{}, err
+	}
 	if err := checkAddNodePrereqs(ae.pki, newNode); err != nil {
-		return nil, err
+		return nil, RunSummary{}, err
 	}
 	updatedPlan := AddNodeToPlan(*originalPlan, newNode, roles)
 
@@ -22,17 +26,22 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 	util.PrintHeader(ae.stdout, "Generating Certificate For New Node", '=')
 	ca, err := ae.pki.GetClusterCA()
 	if err != nil {
-		return nil, err
+		return nil, RunSummary{}, err
 	}
 	if err = ae.pki.GenerateNodeCertificate(&updatedPlan, newNode, ca); err != nil {
-		return nil, fmt.Errorf("error generating certificate for new node: %v", err)
+		return nil, RunSummary{}, fmt.Errorf("error generating certificate for new node: %v", err)
+	}
+
+	summary, err := ae.createServiceAccount(&updatedPlan, newNode.Host)
+	if err != nil {
+		return nil, summary, err
 	}
 
 	// Run the playbook to add the node
-	inventory := buildInventoryFromPlan(&updatedPlan)
+	inventory := ae.buildInventory(&updatedPlan)
 	cc, err := ae.buildClusterCatalog(&updatedPlan)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate ansible vars: %v", err)
+		return nil, summary, fmt.Errorf("failed to generate ansible vars: %v", err)
 	}
 
 	// We need to run ansible against all hosts to update the hosts files
@@ -46,14 +55,14 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 			clusterCatalog: *cc,
 			explainer:      ae.defaultExplainer(),
 		}
-		if err = ae.execute(t); err != nil {
-			return nil, fmt.Errorf("error updating hosts files on all nodes: %v", err)
+		taskSummary, err := ae.execute(t)
+		summary = summary.Merge(taskSummary)
+		if err != nil {
+			return nil, summary, fmt.Errorf("error updating hosts files on all nodes: %v", err)
 		}
 	}
 
-	if restartServices {
-		cc.EnableRestart()
-	}
+	ae.enableRestart(cc, restartServices)
 	util.PrintHeader(ae.stdout, "Adding New Node to Cluster", '=')
 	t := task{
 		name:           "add-node",
@@ -64,8 +73,10 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 		explainer:      ae.defaultExplainer(),
 		limit:          []string{newNode.Host},
 	}
-	if err = ae.execute(t); err != nil {
-		return nil, fmt.Errorf("error running playbook: %v", err)
+	taskSummary, err := ae.execute(t)
+	summary = summary.Merge(taskSummary)
+	if err != nil {
+		return nil, summary, fmt.Errorf("error running playbook: %v", err)
 	}
 
 	// Verify that the node registered with API server
@@ -80,8 +91,10 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 		explainer:      ae.defaultExplainer(),
 		limit:          []string{newNode.Host},
 	}
-	if err = ae.execute(t); err != nil {
-		return nil, fmt.Errorf("error running node smoke test: %v", err)
+	taskSummary, err = ae.execute(t)
+	summary = summary.Merge(taskSummary)
+	if err != nil {
+		return nil, summary, fmt.Errorf("error running node smoke test: %v", err)
 	}
 
 	// Allow access to new node to any storage volumes defined
@@ -95,11 +108,13 @@ func (ae *ansibleExecutor) AddNode(originalPlan *Plan, newNode Node, roles []str
 			clusterCatalog: *cc,
 			explainer:      ae.defaultExplainer(),
 		}
-		if err = ae.execute(t); err != nil {
-			return nil, fmt.Errorf("error adding new node to volume allow list: %v", err)
+		taskSummary, err := ae.execute(t)
+		summary = summary.Merge(taskSummary)
+		if err != nil {
+			return nil, summary, fmt.Errorf("error adding new node to volume allow list: %v", err)
 		}
 	}
-	return &updatedPlan, nil
+	return &updatedPlan, summary, nil
 }
 
 func AddNodeToPlan(plan Plan, node Node, roles []string) Plan {
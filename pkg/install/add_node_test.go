@@ -33,7 +33,7 @@ func TestAddNodeCertMissingCAMissing(t *testing.T) {
 		},
 	}
 	newNode := Node{}
-	newPlan, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
+	newPlan, _, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
 	if newPlan != nil {
 		t.Errorf("add worker returned an updated plan")
 	}
@@ -70,7 +70,7 @@ func TestAddNodeCertMissingCAExists(t *testing.T) {
 		},
 	}
 	newNode := Node{}
-	_, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
+	_, _, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
 	if err != nil {
 		t.Errorf("unexpected error while adding worker: %v", err)
 	}
@@ -115,7 +115,7 @@ func TestAddNodePlanIsUpdated(t *testing.T) {
 	newNode := Node{
 		Host: "test",
 	}
-	updatedPlan, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
+	updatedPlan, _, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
 	if err != nil {
 		t.Errorf("unexpected error while adding worker: %v", err)
 	}
@@ -172,7 +172,7 @@ func TestAddIngressPlanIsUpdated(t *testing.T) {
 	newNode := Node{
 		Host: "test",
 	}
-	updatedPlan, err := e.AddNode(originalPlan, newNode, []string{"ingress"}, true)
+	updatedPlan, _, err := e.AddNode(originalPlan, newNode, []string{"ingress"}, true)
 	if err != nil {
 		t.Errorf("unexpected error while adding worker: %v", err)
 	}
@@ -229,7 +229,7 @@ func TestAddStoragePlanIsUpdated(t *testing.T) {
 	newNode := Node{
 		Host: "test",
 	}
-	updatedPlan, err := e.AddNode(originalPlan, newNode, []string{"storage"}, true)
+	updatedPlan, _, err := e.AddNode(originalPlan, newNode, []string{"storage"}, true)
 	if err != nil {
 		t.Errorf("unexpected error while adding worker: %v", err)
 	}
@@ -302,7 +302,7 @@ func TestAddAllRolesPlanIsUpdated(t *testing.T) {
 	newNode := Node{
 		Host: "test",
 	}
-	updatedPlan, err := e.AddNode(originalPlan, newNode, []string{"worker", "ingress", "storage"}, true)
+	updatedPlan, _, err := e.AddNode(originalPlan, newNode, []string{"worker", "ingress", "storage"}, true)
 	if err != nil {
 		t.Errorf("unexpected error while adding worker: %v", err)
 	}
@@ -377,7 +377,7 @@ func TestAddNodePlanNotUpdatedAfterFailure(t *testing.T) {
 	newNode := Node{
 		Host: "test",
 	}
-	updatedPlan, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
+	updatedPlan, _, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
 	if err == nil {
 		t.Errorf("expected an error, but didn't get one")
 	}
@@ -422,7 +422,7 @@ func TestAddNodeRestartServicesEnabled(t *testing.T) {
 	newNode := Node{
 		Host: "test",
 	}
-	_, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
+	_, _, err := e.AddNode(originalPlan, newNode, []string{"worker"}, true)
 	if err != nil {
 		t.Errorf("unexpected error")
 	}
@@ -481,7 +481,7 @@ func TestAddNodeHostsFilesDNSEnabled(t *testing.T) {
 	newNode := Node{
 		Host: "test",
 	}
-	_, err := e.AddNode(originalPlan, newNode, []string{"worker"}, false)
+	_, _, err := e.AddNode(originalPlan, newNode, []string{"worker"}, false)
 	if err != nil {
 		t.Errorf("unexpected error")
 	}
@@ -539,12 +539,25 @@ type fakeRunner struct {
 
 func (f *fakeRunner) StartPlaybook(playbookFile string, inventory ansible.Inventory, cc ansible.ClusterCatalog) (<-chan ansible.Event, error) {
 	f.allNodesPlaybooks = append(f.allNodesPlaybooks, playbookFile)
-	return f.eventChan, f.err
+	f.incomingCatalog = cc
+	return f.events(), f.err
 }
 func (f *fakeRunner) WaitPlaybook() error { return f.err }
+func (f *fakeRunner) Stop() error        { return nil }
 func (f *fakeRunner) StartPlaybookOnNode(playbookFile string, inventory ansible.Inventory, cc ansible.ClusterCatalog, node ...string) (<-chan ansible.Event, error) {
 	f.incomingCatalog = cc
-	return f.eventChan, f.err
+	return f.events(), f.err
+}
+
+// events returns the stream of the playbook, which ends without events
+// unless they are set
+func (f *fakeRunner) events() <-chan ansible.Event {
+	if f.eventChan != nil {
+		return f.eventChan
+	}
+	events := make(chan ansible.Event)
+	close(events)
+	return events
 }
 
 func fakeRunnerExplainer(execError error) func(explain.AnsibleEventExplainer, io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
@@ -0,0 +1,181 @@
+package install
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// adminCredentialsFilename is the file of the generated assets directory
+	// that holds the credentials generated by KET, encrypted with ansible-vault
+	adminCredentialsFilename = "credentials.yaml"
+	// credentialsKeyEnvVar is the environment variable that holds the vault
+	// password of the credentials. It is provided by the user, so that the
+	// key is not stored along with the credentials.
+	credentialsKeyEnvVar = "KISMATIC_CREDENTIALS_KEY"
+)
+
+// AdminCredentials are the credentials of the cluster that were generated by KET
+type AdminCredentials struct {
+	AdminPassword string `yaml:"admin_password,omitempty"`
+	AdminToken    string `yaml:"admin_token,omitempty"`
+	// Shown is set once the credentials were displayed to the user, and
+	// cleared when new credentials are generated
+	Shown bool `yaml:"shown,omitempty"`
+}
+
+// credentialsKey returns the vault password of the credentials, set by the
+// user in the environment
+func credentialsKey() ([]byte, error) {
+	key := os.Getenv(credentialsKeyEnvVar)
+	if key == "" {
+		return nil, fmt.Errorf("the %s environment variable must be set to the key that encrypts the generated credentials", credentialsKeyEnvVar)
+	}
+	return []byte(key), nil
+}
+
+// ReadAdminCredentials returns the credentials generated by KET in the generated
+// assets directory, or nil if no credentials were generated. The credentials
+// are decrypted with the key in the KISMATIC_CREDENTIALS_KEY environment
+// variable, and are only read when their file is only accessible to the
+// current user.
+func ReadAdminCredentials(generatedAssetsDir string) (*AdminCredentials, error) {
+	file := filepath.Join(generatedAssetsDir, adminCredentialsFilename)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err := checkOwnerOnlyFile(file); err != nil {
+		return nil, err
+	}
+	key, err := credentialsKey()
+	if err != nil {
+		return nil, err
+	}
+	vaulted, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading credentials: %v", err)
+	}
+	b, err := ansible.DecryptVault(vaulted, key)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting credentials in %s: %v", file, err)
+	}
+	c := &AdminCredentials{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("error unmarshaling credentials: %v", err)
+	}
+	return c, nil
+}
+
+// MarkAdminCredentialsShown records in the generated assets directory that
+// the credentials were displayed to the user
+func MarkAdminCredentialsShown(generatedAssetsDir string, c *AdminCredentials) error {
+	c.Shown = true
+	return writeAdminCredentials(generatedAssetsDir, *c)
+}
+
+// writeAdminCredentials encrypts the credentials with the key in the
+// environment, and writes them to the generated assets directory
+func writeAdminCredentials(generatedAssetsDir string, c AdminCredentials) error {
+	key, err := credentialsKey()
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error marshaling credentials: %v", err)
+	}
+	vaulted, err := ansible.EncryptVault(b, key)
+	if err != nil {
+		return fmt.Errorf("error encrypting credentials: %v", err)
+	}
+	if err := os.MkdirAll(generatedAssetsDir, 0777); err != nil {
+		return fmt.Errorf("error creating directory %s: %v", generatedAssetsDir, err)
+	}
+	file := filepath.Join(generatedAssetsDir, adminCredentialsFilename)
+	if err := ioutil.WriteFile(file, vaulted, 0600); err != nil {
+		return fmt.Errorf("error writing credentials to %s: %v", file, err)
+	}
+	return nil
+}
+
+// checkOwnerOnlyFile returns an error if the file can be accessed by users
+// other than its owner
+func checkOwnerOnlyFile(file string) error {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", file, err)
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("permissions %#o for %q are too open, the file must only be accessible to its owner. Permissions should be set to 0600.", perm, file)
+	}
+	return nil
+}
+
+// generateSecret returns a random secret, such as the password of the admin user
+func generateSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating secret: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// setGeneratedCredentials makes the credentials generated by KET available to
+// ansible through the environment, so that they are not written to the
+// cluster catalog. The admin password is generated when password is true, and
+// the admin token when token is true. The credentials are generated, and
+// stored in the generated assets directory, the first time they are needed.
+func (ae *ansibleExecutor) setGeneratedCredentials(password, token bool) error {
+	c, err := ReadAdminCredentials(ae.options.GeneratedAssetsDirectory)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		c = &AdminCredentials{}
+	}
+	var generated []string
+	if password && c.AdminPassword == "" {
+		if c.AdminPassword, err = generateSecret(); err != nil {
+			return err
+		}
+		generated = append(generated, "admin password")
+	}
+	if token && c.AdminToken == "" {
+		if c.AdminToken, err = generateSecret(); err != nil {
+			return err
+		}
+		generated = append(generated, "admin token")
+	}
+	if len(generated) > 0 && !ae.options.DryRun {
+		// The new credentials have not been displayed yet
+		c.Shown = false
+		if err := writeAdminCredentials(ae.options.GeneratedAssetsDirectory, *c); err != nil {
+			return err
+		}
+		util.PrettyPrintOk(ae.stdout, "Generated the %s, run \"kismatic credentials show\" to display the credentials", strings.Join(generated, " and "))
+	}
+	if password {
+		if err := os.Setenv(adminPasswordEnvVar, c.AdminPassword); err != nil {
+			return fmt.Errorf("error setting admin password: %v", err)
+		}
+	}
+	if token {
+		if err := os.Setenv(adminTokenEnvVar, c.AdminToken); err != nil {
+			return fmt.Errorf("error setting admin token: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package install
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadAdminCredentialsNotGenerated(t *testing.T) {
+	c, err := ReadAdminCredentials(mustGetTempDir(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c != nil {
+		t.Errorf("expected no credentials, got %v", c)
+	}
+}
+
+func TestWriteAndReadAdminCredentials(t *testing.T) {
+	defer os.Unsetenv(credentialsKeyEnvVar)
+	os.Setenv(credentialsKeyEnvVar, "key")
+	dir := mustGetTempDir(t)
+	if err := writeAdminCredentials(dir, AdminCredentials{AdminPassword: "secret"}); err != nil {
+		t.Fatalf("unexpected error writing credentials: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, adminCredentialsFilename))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected the credentials to be only accessible to the owner, got %v", fi.Mode().Perm())
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the credentials to be written, got %d files", len(files))
+	}
+	c, err := ReadAdminCredentials(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reading credentials: %v", err)
+	}
+	if c == nil || c.AdminPassword != "secret" {
+		t.Errorf("expected the admin password to be read, got %v", c)
+	}
+}
+
+func TestAdminCredentialsRequireKey(t *testing.T) {
+	defer os.Unsetenv(credentialsKeyEnvVar)
+	dir := mustGetTempDir(t)
+	if err := writeAdminCredentials(dir, AdminCredentials{AdminPassword: "secret"}); err == nil || !strings.Contains(err.Error(), credentialsKeyEnvVar) {
+		t.Errorf("expected an error about the missing key, got %v", err)
+	}
+	os.Setenv(credentialsKeyEnvVar, "key")
+	if err := writeAdminCredentials(dir, AdminCredentials{AdminPassword: "secret"}); err != nil {
+		t.Fatalf("unexpected error writing credentials: %v", err)
+	}
+	os.Unsetenv(credentialsKeyEnvVar)
+	if _, err := ReadAdminCredentials(dir); err == nil || !strings.Contains(err.Error(), credentialsKeyEnvVar) {
+		t.Errorf("expected an error about the missing key, got %v", err)
+	}
+	os.Setenv(credentialsKeyEnvVar, "wrong")
+	if _, err := ReadAdminCredentials(dir); err == nil {
+		t.Errorf("expected an error decrypting the credentials with the wrong key")
+	}
+}
+
+func TestReadAdminCredentialsOpenPermissions(t *testing.T) {
+	defer os.Unsetenv(credentialsKeyEnvVar)
+	os.Setenv(credentialsKeyEnvVar, "key")
+	dir := mustGetTempDir(t)
+	if err := writeAdminCredentials(dir, AdminCredentials{AdminPassword: "secret"}); err != nil {
+		t.Fatalf("unexpected error writing credentials: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(dir, adminCredentialsFilename), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := ReadAdminCredentials(dir)
+	if err == nil || !strings.Contains(err.Error(), "too open") {
+		t.Errorf("expected an error about the permissions of the credentials, got %v", err)
+	}
+}
+
+func TestSetGeneratedAdminPassword(t *testing.T) {
+	defer os.Unsetenv(adminPasswordEnvVar)
+	defer os.Unsetenv(credentialsKeyEnvVar)
+	os.Setenv(credentialsKeyEnvVar, "key")
+	dir := mustGetTempDir(t)
+	out := &bytes.Buffer{}
+	ae := &ansibleExecutor{options: ExecutorOptions{GeneratedAssetsDirectory: dir}, stdout: out}
+	if err := ae.setGeneratedCredentials(true, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	password := os.Getenv(adminPasswordEnvVar)
+	if len(password) != 32 {
+		t.Errorf("expected a generated password of 32 characters, got %q", password)
+	}
+	if strings.Contains(out.String(), password) {
+		t.Errorf("the generated password was printed: %s", out.String())
+	}
+	// the password is generated once
+	os.Unsetenv(adminPasswordEnvVar)
+	if err := ae.setGeneratedCredentials(true, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := os.Getenv(adminPasswordEnvVar); got != password {
+		t.Errorf("expected the stored password %q, got %q", password, got)
+	}
+	p := &Plan{}
+	p.Cluster.GenerateAdminPassword = true
+	if got := adminPassword(p); !strings.Contains(got, adminPasswordEnvVar) {
+		t.Errorf("expected the admin password to be read from the environment, got %q", got)
+	}
+}
+
+func TestSetGeneratedAdminPasswordDryRun(t *testing.T) {
+	defer os.Unsetenv(adminPasswordEnvVar)
+	dir := mustGetTempDir(t)
+	ae := &ansibleExecutor{options: ExecutorOptions{GeneratedAssetsDirectory: dir, DryRun: true}, stdout: &bytes.Buffer{}}
+	if err := ae.setGeneratedCredentials(true, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, adminCredentialsFilename)); !os.IsNotExist(err) {
+		t.Errorf("expected no credentials to be written in a dry run, got %v", err)
+	}
+}
+
+func TestValidateGenerateAdminPassword(t *testing.T) {
+	p := validPlan()
+	p.Cluster.GenerateAdminPassword = true
+	if ok, errs := ValidatePlan(&p); !ok {
+		t.Errorf("expected the plan to be valid, got %v", errs)
+	}
+	p.Cluster.AdminPassword = "password"
+	if ok, _ := ValidatePlan(&p); ok {
+		t.Errorf("expected the plan to be invalid when the admin password is also set")
+	}
+}
+
+func TestMarkAdminCredentialsShown(t *testing.T) {
+	defer os.Unsetenv(adminPasswordEnvVar)
+	defer os.Unsetenv(adminTokenEnvVar)
+	defer os.Unsetenv(credentialsKeyEnvVar)
+	os.Setenv(credentialsKeyEnvVar, "key")
+	dir := mustGetTempDir(t)
+	ae := &ansibleExecutor{options: ExecutorOptions{GeneratedAssetsDirectory: dir}, stdout: &bytes.Buffer{}}
+	if err := ae.setGeneratedCredentials(true, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c, err := ReadAdminCredentials(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reading credentials: %v", err)
+	}
+	if c.Shown {
+		t.Errorf("expected the generated credentials not to be shown")
+	}
+	if err := MarkAdminCredentialsShown(dir, c); err != nil {
+		t.Fatalf("unexpected error marking the credentials shown: %v", err)
+	}
+	if c, err = ReadAdminCredentials(dir); err != nil || !c.Shown {
+		t.Errorf("expected the credentials to be shown, got %v: %v", c, err)
+	}
+	// the stored credentials do not reset the marker
+	if err := ae.setGeneratedCredentials(true, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c, err = ReadAdminCredentials(dir); err != nil || !c.Shown {
+		t.Errorf("expected the credentials to still be shown, got %v: %v", c, err)
+	}
+	// the new token has not been shown
+	if err := ae.setGeneratedCredentials(true, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c, err = ReadAdminCredentials(dir); err != nil || c.Shown {
+		t.Errorf("expected the new credentials not to be shown, got %v: %v", c, err)
+	}
+}
@@ -0,0 +1,384 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/csr"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// kubeadm keeps the kubeconfig of the cluster administrator and the
+// certificates of the cluster in these locations of the masters
+const (
+	kubeadmAdminKubeconfig = "/etc/kubernetes/admin.conf"
+	kubeadmPKIDir          = "/etc/kubernetes/pki"
+	kubeadmMasterLabel     = "node-role.kubernetes.io/master"
+)
+
+// KubeadmCluster is an existing cluster that was built with kubeadm
+type KubeadmCluster struct {
+	Version       string
+	PodSubnet     string
+	ServiceSubnet string
+	// ControlPlaneEndpoint is the load balanced address of the API servers.
+	// It is empty when the API server of the first master is used.
+	ControlPlaneEndpoint string
+	// AdvertiseAddress is the address of the API server of the first master
+	AdvertiseAddress string
+	// ExternalEtcdEndpoints are the endpoints of the etcd cluster. They are
+	// empty when etcd runs in static pods on the masters.
+	ExternalEtcdEndpoints []string
+	Nodes                 []KubeadmNode
+	// CNIProvider is the KET provider of the pod network. It is custom when
+	// the pod network is not provided by KET.
+	CNIProvider string
+	// CNIDaemonSet is the name of the daemon set that runs the pod network
+	CNIDaemonSet string
+	DNSProvider  string
+
+	CACert            []byte
+	CAKey             []byte
+	FrontProxyCACert  []byte
+	FrontProxyCAKey   []byte
+	ServiceAccountKey []byte
+}
+
+// KubeadmNode is a node of a kubeadm cluster
+type KubeadmNode struct {
+	Name   string
+	IP     string
+	Master bool
+	// Labels are the labels of the node that were not set by Kubernetes
+	Labels map[string]string
+}
+
+// the kubeadm configuration is stored under MasterConfiguration up to
+// Kubernetes 1.11, and under ClusterConfiguration after that
+type kubeadmConfigMap struct {
+	Data struct {
+		MasterConfiguration  string `json:"MasterConfiguration"`
+		ClusterConfiguration string `json:"ClusterConfiguration"`
+	} `json:"data"`
+}
+
+type kubeadmConfig struct {
+	API struct {
+		AdvertiseAddress     string `yaml:"advertiseAddress"`
+		ControlPlaneEndpoint string `yaml:"controlPlaneEndpoint"`
+	} `yaml:"api"`
+	ControlPlaneEndpoint string `yaml:"controlPlaneEndpoint"`
+	Etcd                 struct {
+		Endpoints []string `yaml:"endpoints"`
+		External  struct {
+			Endpoints []string `yaml:"endpoints"`
+		} `yaml:"external"`
+	} `yaml:"etcd"`
+	KubernetesVersion string `yaml:"kubernetesVersion"`
+	Networking        struct {
+		PodSubnet     string `yaml:"podSubnet"`
+		ServiceSubnet string `yaml:"serviceSubnet"`
+	} `yaml:"networking"`
+}
+
+type kubeadmNodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Status struct {
+			Addresses []struct {
+				Type    string `json:"type"`
+				Address string `json:"address"`
+			} `json:"addresses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type kubeadmWorkloadList struct {
+	Items []struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// InspectKubeadmCluster reads the configuration, the nodes, the add-ons and
+// the certificate authorities of a kubeadm cluster from one of its masters
+func InspectKubeadmCluster(client ssh.Client) (*KubeadmCluster, error) {
+	kubectl := fmt.Sprintf("sudo kubectl --kubeconfig %s", kubeadmAdminKubeconfig)
+	c := &KubeadmCluster{}
+	raw, err := client.Output(false, kubectl+" -n kube-system get configmap kubeadm-config -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting the kubeadm configuration: %v: %s", err, raw)
+	}
+	if err := parseKubeadmConfig(raw, c); err != nil {
+		return nil, err
+	}
+	raw, err = client.Output(false, kubectl+" get nodes -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting the nodes: %v: %s", err, raw)
+	}
+	if c.Nodes, err = parseKubeadmNodes(raw); err != nil {
+		return nil, err
+	}
+	raw, err = client.Output(false, kubectl+" -n kube-system get daemonsets,deployments -o json")
+	if err != nil {
+		return nil, fmt.Errorf("error getting the add-ons: %v: %s", err, raw)
+	}
+	if err := parseKubeadmAddOns(raw, c); err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name     string
+		dest     *[]byte
+		optional bool
+	}{
+		{name: "ca.crt", dest: &c.CACert},
+		{name: "ca.key", dest: &c.CAKey},
+		{name: "front-proxy-ca.crt", dest: &c.FrontProxyCACert, optional: true},
+		{name: "front-proxy-ca.key", dest: &c.FrontProxyCAKey, optional: true},
+		{name: "sa.key", dest: &c.ServiceAccountKey, optional: true},
+	}
+	for _, f := range files {
+		file := kubeadmPKIDir + "/" + f.name
+		out, err := client.Output(false, "sudo cat "+file)
+		if err != nil {
+			if f.optional {
+				continue
+			}
+			return nil, fmt.Errorf("error reading %s: %v: %s", file, err, out)
+		}
+		*f.dest = []byte(out)
+	}
+	return c, nil
+}
+
+func parseKubeadmConfig(raw string, c *KubeadmCluster) error {
+	var cm kubeadmConfigMap
+	if err := json.Unmarshal([]byte(raw), &cm); err != nil {
+		return fmt.Errorf("error unmarshalling the kubeadm configuration: %v", err)
+	}
+	data := cm.Data.ClusterConfiguration
+	if data == "" {
+		data = cm.Data.MasterConfiguration
+	}
+	if data == "" {
+		return fmt.Errorf("the kubeadm-config config map does not have a kubeadm configuration")
+	}
+	var kc kubeadmConfig
+	if err := yaml.Unmarshal([]byte(data), &kc); err != nil {
+		return fmt.Errorf("error unmarshalling the kubeadm configuration: %v", err)
+	}
+	c.Version = kc.KubernetesVersion
+	c.PodSubnet = kc.Networking.PodSubnet
+	c.ServiceSubnet = kc.Networking.ServiceSubnet
+	c.AdvertiseAddress = kc.API.AdvertiseAddress
+	c.ControlPlaneEndpoint = kc.ControlPlaneEndpoint
+	if c.ControlPlaneEndpoint == "" {
+		c.ControlPlaneEndpoint = kc.API.ControlPlaneEndpoint
+	}
+	if len(kc.Etcd.External.Endpoints) > 0 {
+		c.ExternalEtcdEndpoints = kc.Etcd.External.Endpoints
+	} else if len(kc.Etcd.Endpoints) > 0 {
+		c.ExternalEtcdEndpoints = kc.Etcd.Endpoints
+	}
+	return nil
+}
+
+func parseKubeadmNodes(raw string) ([]KubeadmNode, error) {
+	var l kubeadmNodeList
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		return nil, fmt.Errorf("error unmarshalling the nodes: %v", err)
+	}
+	var nodes []KubeadmNode
+	for _, i := range l.Items {
+		n := KubeadmNode{Name: i.Metadata.Name, Labels: map[string]string{}}
+		for _, a := range i.Status.Addresses {
+			if a.Type == "InternalIP" {
+				n.IP = a.Address
+				break
+			}
+		}
+		for k, v := range i.Metadata.Labels {
+			if k == kubeadmMasterLabel {
+				n.Master = true
+				continue
+			}
+			if strings.Contains(k, "kubernetes.io/") {
+				continue
+			}
+			n.Labels[k] = v
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("the cluster does not have any nodes")
+	}
+	return nodes, nil
+}
+
+func parseKubeadmAddOns(raw string, c *KubeadmCluster) error {
+	var l kubeadmWorkloadList
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		return fmt.Errorf("error unmarshalling the add-ons: %v", err)
+	}
+	c.CNIProvider = cniProviderCustom
+	c.DNSProvider = "kubedns"
+	for _, i := range l.Items {
+		switch {
+		case i.Kind == "DaemonSet" && i.Metadata.Name == "calico-node":
+			c.CNIProvider = cniProviderCalico
+			c.CNIDaemonSet = i.Metadata.Name
+		case i.Kind == "DaemonSet" && i.Metadata.Name == "weave-net":
+			c.CNIProvider = cniProviderWeave
+			c.CNIDaemonSet = i.Metadata.Name
+		case i.Kind == "DaemonSet" && c.CNIDaemonSet == "" && i.Metadata.Name != "kube-proxy":
+			// other daemon sets, such as kube-flannel-ds, are reported as the
+			// pod network unless calico or weave were found
+			c.CNIDaemonSet = i.Metadata.Name
+		case i.Kind == "Deployment" && i.Metadata.Name == "coredns":
+			c.DNSProvider = "coredns"
+		}
+	}
+	return nil
+}
+
+// AdoptedPlan returns a plan file that describes the kubeadm cluster. The
+// returned warnings are the parts of the cluster that KET manages differently,
+// and that must be reviewed before the cluster is upgraded with KET.
+func AdoptedPlan(c KubeadmCluster, name string, sshConfig SSHConfig) (*Plan, []string) {
+	var warnings []string
+	p := buildPlanFromTemplateOptions(PlanTemplateOptions{})
+	p.Cluster.Name = name
+	p.Cluster.SSH = sshConfig
+	if c.Version != "" {
+		p.Cluster.Version = c.Version
+	}
+	if c.PodSubnet != "" {
+		p.Cluster.Networking.PodCIDRBlock = c.PodSubnet
+	} else {
+		warnings = append(warnings, fmt.Sprintf("The pod subnet is not set in the kubeadm configuration. Set pod_cidr_block to the subnet used by the pod network (defaulted to %s).", p.Cluster.Networking.PodCIDRBlock))
+	}
+	if c.ServiceSubnet != "" {
+		p.Cluster.Networking.ServiceCIDRBlock = c.ServiceSubnet
+	}
+
+	p.AddOns.CNI.Provider = c.CNIProvider
+	if c.CNIProvider == cniProviderCustom {
+		ds := c.CNIDaemonSet
+		if ds == "" {
+			ds = "an unknown pod network"
+		}
+		warnings = append(warnings, fmt.Sprintf("The pod network (%s) is not provided by KET, and is left as a custom CNI provider that KET does not upgrade.", ds))
+	}
+	p.AddOns.DNS.Provider = c.DNSProvider
+
+	var masters, workers []Node
+	for _, n := range c.Nodes {
+		node := Node{Host: n.Name, IP: n.IP}
+		if len(n.Labels) > 0 {
+			node.Labels = n.Labels
+		}
+		if n.Master {
+			masters = append(masters, node)
+		} else {
+			workers = append(workers, node)
+		}
+	}
+	p.Master.Nodes = masters
+	p.Master.ExpectedCount = len(masters)
+	p.Worker.Nodes = workers
+	p.Worker.ExpectedCount = len(workers)
+
+	endpoint := c.ControlPlaneEndpoint
+	if endpoint == "" {
+		endpoint = c.AdvertiseAddress
+	}
+	if endpoint == "" && len(masters) > 0 {
+		endpoint = masters[0].IP
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		endpoint = host
+	}
+	p.Master.LoadBalancedFQDN = endpoint
+	p.Master.LoadBalancedShortName = endpoint
+
+	if len(c.ExternalEtcdEndpoints) > 0 {
+		var etcd []Node
+		for _, e := range c.ExternalEtcdEndpoints {
+			host := e
+			if u, err := url.Parse(e); err == nil && u.Host != "" {
+				host = u.Host
+			}
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			etcd = append(etcd, Node{IP: host})
+		}
+		p.Etcd.Nodes = etcd
+		warnings = append(warnings, "The etcd nodes were found from the endpoints of the external etcd cluster. Set the host names of the etcd nodes in the plan file.")
+	} else {
+		p.Etcd.Nodes = masters
+		warnings = append(warnings, "etcd runs in static pods on the masters, while KET runs etcd as a service on the etcd nodes. The data of the cluster must be migrated to the etcd service before the cluster is upgraded with KET.")
+	}
+	p.Etcd.ExpectedCount = len(p.Etcd.Nodes)
+
+	if len(c.FrontProxyCACert) == 0 {
+		warnings = append(warnings, "The front proxy certificate authority was not found. KET generates a new one, and the aggregated API servers must trust it.")
+	}
+	if len(c.ServiceAccountKey) == 0 {
+		warnings = append(warnings, "The service account signing key was not found. KET generates a new one, which invalidates the existing service account tokens.")
+	}
+	sort.Strings(warnings)
+	return &p, warnings
+}
+
+// ImportKubeadmPKI writes the certificate authorities of the kubeadm cluster
+// to the generated certificates directory, so that the certificates generated
+// by KET are trusted by the existing nodes. The service account signing key is
+// imported with a new certificate, so that the existing tokens remain valid.
+func (lp *LocalPKI) ImportKubeadmPKI(p *Plan, c KubeadmCluster) error {
+	exists, err := lp.CertificateAuthorityExists()
+	if err != nil {
+		return fmt.Errorf("error verifying CA certificate/key: %v", err)
+	}
+	if exists {
+		return fmt.Errorf("a certificate authority already exists in %q", lp.GeneratedCertsDirectory)
+	}
+	if err := tls.WriteCert(c.CAKey, c.CACert, "ca", lp.GeneratedCertsDirectory); err != nil {
+		return fmt.Errorf("error writing CA files: %v", err)
+	}
+	if len(c.FrontProxyCACert) > 0 && len(c.FrontProxyCAKey) > 0 {
+		if err := tls.WriteCert(c.FrontProxyCAKey, c.FrontProxyCACert, "proxy-client-ca", lp.GeneratedCertsDirectory); err != nil {
+			return fmt.Errorf("error writing proxy-client CA files: %v", err)
+		}
+	}
+	if len(c.ServiceAccountKey) == 0 {
+		return lp.writeCertsManifest()
+	}
+	expiry, err := time.ParseDuration(p.Cluster.Certificates.Expiry)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid duration for certificate expiry", p.Cluster.Certificates.Expiry)
+	}
+	ca := &tls.CA{Cert: c.CACert, Key: c.CAKey}
+	req := csr.CertificateRequest{CN: serviceAccountCertCommonName}
+	cert, err := tls.NewCertForKey(ca, c.ServiceAccountKey, req, expiry)
+	if err != nil {
+		return fmt.Errorf("error generating the service account certificate: %v", err)
+	}
+	if err := tls.WriteCert(c.ServiceAccountKey, cert, serviceAccountCertFilename, lp.GeneratedCertsDirectory); err != nil {
+		return fmt.Errorf("error writing the service account certificate: %v", err)
+	}
+	return lp.writeCertsManifest()
+}
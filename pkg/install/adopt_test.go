@@ -0,0 +1,178 @@
+package install
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+func TestParseKubeadmConfig(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected KubeadmCluster
+	}{
+		{
+			raw: `{"data":{"MasterConfiguration":"api:\n  advertiseAddress: 10.0.0.2\n  bindPort: 6443\netcd:\n  endpoints: []\nkubernetesVersion: v1.10.3\nnetworking:\n  dnsDomain: cluster.local\n  podSubnet: 10.244.0.0/16\n  serviceSubnet: 10.96.0.0/12\n"}}`,
+			expected: KubeadmCluster{
+				Version:          "v1.10.3",
+				PodSubnet:        "10.244.0.0/16",
+				ServiceSubnet:    "10.96.0.0/12",
+				AdvertiseAddress: "10.0.0.2",
+			},
+		},
+		{
+			raw: `{"data":{"ClusterConfiguration":"controlPlaneEndpoint: k8s.example.com:6443\netcd:\n  external:\n    endpoints:\n    - https://10.0.1.1:2379\nkubernetesVersion: v1.12.1\nnetworking:\n  serviceSubnet: 10.96.0.0/12\n"}}`,
+			expected: KubeadmCluster{
+				Version:               "v1.12.1",
+				ServiceSubnet:         "10.96.0.0/12",
+				ControlPlaneEndpoint:  "k8s.example.com:6443",
+				ExternalEtcdEndpoints: []string{"https://10.0.1.1:2379"},
+			},
+		},
+	}
+	for i, test := range tests {
+		c := KubeadmCluster{}
+		if err := parseKubeadmConfig(test.raw, &c); err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(c, test.expected) {
+			t.Errorf("test %d: expected %+v, but got %+v", i, test.expected, c)
+		}
+	}
+	if err := parseKubeadmConfig(`{"data":{}}`, &KubeadmCluster{}); err == nil {
+		t.Error("expected an error when the config map does not have a kubeadm configuration")
+	}
+}
+
+func TestParseKubeadmNodes(t *testing.T) {
+	raw := `{"items":[
+		{"metadata":{"name":"master01","labels":{"kubernetes.io/hostname":"master01","node-role.kubernetes.io/master":""}},
+		 "status":{"addresses":[{"type":"InternalIP","address":"10.0.0.2"},{"type":"Hostname","address":"master01"}]}},
+		{"metadata":{"name":"worker01","labels":{"beta.kubernetes.io/os":"linux","team":"payments"}},
+		 "status":{"addresses":[{"type":"InternalIP","address":"10.0.0.3"}]}}]}`
+	nodes, err := parseKubeadmNodes(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []KubeadmNode{
+		{Name: "master01", IP: "10.0.0.2", Master: true, Labels: map[string]string{}},
+		{Name: "worker01", IP: "10.0.0.3", Labels: map[string]string{"team": "payments"}},
+	}
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, nodes)
+	}
+}
+
+func TestParseKubeadmAddOns(t *testing.T) {
+	tests := []struct {
+		raw       string
+		cni       string
+		daemonSet string
+		dns       string
+	}{
+		{
+			raw:       `{"items":[{"kind":"DaemonSet","metadata":{"name":"kube-proxy"}},{"kind":"DaemonSet","metadata":{"name":"calico-node"}},{"kind":"Deployment","metadata":{"name":"kube-dns"}}]}`,
+			cni:       cniProviderCalico,
+			daemonSet: "calico-node",
+			dns:       "kubedns",
+		},
+		{
+			raw:       `{"items":[{"kind":"DaemonSet","metadata":{"name":"kube-flannel-ds"}},{"kind":"DaemonSet","metadata":{"name":"kube-proxy"}},{"kind":"Deployment","metadata":{"name":"coredns"}}]}`,
+			cni:       cniProviderCustom,
+			daemonSet: "kube-flannel-ds",
+			dns:       "coredns",
+		},
+	}
+	for i, test := range tests {
+		c := KubeadmCluster{}
+		if err := parseKubeadmAddOns(test.raw, &c); err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if c.CNIProvider != test.cni || c.CNIDaemonSet != test.daemonSet || c.DNSProvider != test.dns {
+			t.Errorf("test %d: unexpected add-ons %q %q %q", i, c.CNIProvider, c.CNIDaemonSet, c.DNSProvider)
+		}
+	}
+}
+
+func TestAdoptedPlan(t *testing.T) {
+	c := KubeadmCluster{
+		Version:              "v1.10.3",
+		PodSubnet:            "10.244.0.0/16",
+		ServiceSubnet:        "10.96.0.0/12",
+		ControlPlaneEndpoint: "k8s.example.com:6443",
+		CNIProvider:          cniProviderWeave,
+		DNSProvider:          "coredns",
+		Nodes: []KubeadmNode{
+			{Name: "master01", IP: "10.0.0.2", Master: true},
+			{Name: "worker01", IP: "10.0.0.3", Labels: map[string]string{"team": "payments"}},
+		},
+		FrontProxyCACert:  []byte("cert"),
+		ServiceAccountKey: []byte("key"),
+	}
+	p, warnings := AdoptedPlan(c, "prod", SSHConfig{User: "ops", Key: "ops.key", Port: 22})
+	if p.Cluster.Name != "prod" || p.Cluster.Version != "v1.10.3" || p.Cluster.SSH.User != "ops" {
+		t.Errorf("unexpected cluster %+v", p.Cluster)
+	}
+	if p.Cluster.Networking.PodCIDRBlock != "10.244.0.0/16" || p.Cluster.Networking.ServiceCIDRBlock != "10.96.0.0/12" {
+		t.Errorf("unexpected networking %+v", p.Cluster.Networking)
+	}
+	if p.AddOns.CNI.Provider != cniProviderWeave || p.AddOns.DNS.Provider != "coredns" {
+		t.Errorf("unexpected add-ons %q %q", p.AddOns.CNI.Provider, p.AddOns.DNS.Provider)
+	}
+	if p.Master.LoadBalancedFQDN != "k8s.example.com" || len(p.Master.Nodes) != 1 || p.Master.Nodes[0].Host != "master01" {
+		t.Errorf("unexpected masters %+v", p.Master)
+	}
+	if len(p.Worker.Nodes) != 1 || p.Worker.Nodes[0].Labels["team"] != "payments" || p.Worker.ExpectedCount != 1 {
+		t.Errorf("unexpected workers %+v", p.Worker)
+	}
+	if len(p.Etcd.Nodes) != 1 || p.Etcd.Nodes[0].Host != "master01" {
+		t.Errorf("expected the masters to be the etcd nodes, but got %+v", p.Etcd)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected a warning about the etcd static pods, but got %v", warnings)
+	}
+}
+
+func TestImportKubeadmPKI(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	caKey, caCert, err := tls.NewCACert(pki.CACsr, "kubernetes", "1h")
+	if err != nil {
+		t.Fatalf("error generating CA: %v", err)
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	saKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	c := KubeadmCluster{CACert: caCert, CAKey: caKey, ServiceAccountKey: saKey}
+
+	if err := pki.ImportKubeadmPKI(getPlan(), c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca, err := pki.GetClusterCA()
+	if err != nil || string(ca.Cert) != string(caCert) {
+		t.Errorf("expected the CA to be imported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(pki.GeneratedCertsDirectory, "proxy-client-ca.pem")); !os.IsNotExist(err) {
+		t.Error("expected the proxy client CA not to be imported when kubeadm did not have one")
+	}
+	cert := mustReadCertFile(filepath.Join(pki.GeneratedCertsDirectory, "service-account.pem"), t)
+	if pub, ok := cert.PublicKey.(*rsa.PublicKey); !ok || pub.N.Cmp(priv.PublicKey.N) != 0 {
+		t.Error("expected the service account certificate to be issued for the kubeadm key")
+	}
+	if cert.Subject.CommonName != serviceAccountCertCommonName {
+		t.Errorf("unexpected common name %q", cert.Subject.CommonName)
+	}
+
+	if err := pki.ImportKubeadmPKI(getPlan(), c); err == nil {
+		t.Error("expected an error when the CA already exists")
+	}
+}
@@ -0,0 +1,135 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+)
+
+// artifactDownloadTimeout is the maximum time allowed for downloading an artifact
+const artifactDownloadTimeout = 10 * time.Minute
+
+var artifactHTTPClient = &http.Client{Timeout: artifactDownloadTimeout}
+
+func isArtifactURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// resolveArtifact returns the path on the local machine to the binary of the
+// artifact. The default path is returned if the artifact has no source.
+// Artifacts hosted on an HTTP(S) server are downloaded into the cache
+// directory, and reused on subsequent runs if they are still valid.
+func resolveArtifact(a Artifact, defaultPath string, cacheDir string) (string, error) {
+	if a.Source == "" {
+		return defaultPath, nil
+	}
+	if !isArtifactURL(a.Source) {
+		if err := verifyArtifactChecksum(a.Source, a.SHA256); err != nil {
+			return "", err
+		}
+		abs, err := filepath.Abs(a.Source)
+		if err != nil {
+			return "", fmt.Errorf("failed to determine absolute path to %s: %v", a.Source, err)
+		}
+		return abs, nil
+	}
+
+	// Use a directory per URL, so that changing the source of an artifact
+	// does not reuse the binary downloaded from the previous source
+	urlSum := sha256.Sum256([]byte(a.Source))
+	dir, err := filepath.Abs(filepath.Join(cacheDir, hex.EncodeToString(urlSum[:8])))
+	if err != nil {
+		return "", fmt.Errorf("failed to determine absolute path to %s: %v", cacheDir, err)
+	}
+	name := path.Base(strings.SplitN(a.Source, "?", 2)[0])
+	if name == "" || name == "/" || name == "." {
+		name = "artifact"
+	}
+	dest := filepath.Join(dir, name)
+	log := logging.With("artifact", a.Source, "path", dest)
+	if _, err := os.Stat(dest); err == nil {
+		if err := verifyArtifactChecksum(dest, a.SHA256); err == nil {
+			log.Debug("using cached artifact")
+			return dest, nil
+		}
+		log.Info("cached artifact is invalid, downloading it again")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("error creating artifact cache directory %q: %v", dir, err)
+	}
+	log.Info("downloading artifact")
+	if err := downloadArtifact(a.Source, dest, a.SHA256); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// downloadArtifact downloads the artifact into dest. The file is only written
+// if the download succeeds and matches the checksum, when one is given.
+func downloadArtifact(url, dest, checksum string) error {
+	resp, err := artifactHTTPClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("error downloading artifact from %q: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error downloading artifact from %q: server returned %s", url, resp.Status)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(dest), ".download-")
+	if err != nil {
+		return fmt.Errorf("error creating file for artifact: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error downloading artifact from %q: %v", url, err)
+	}
+	if checksum != "" {
+		if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, checksum) {
+			return fmt.Errorf("artifact downloaded from %q has SHA256 checksum %s, expected %s", url, sum, checksum)
+		}
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return fmt.Errorf("error setting permissions of artifact: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("error saving artifact to %q: %v", dest, err)
+	}
+	return nil
+}
+
+// verifyArtifactChecksum returns an error if the file does not exist, or if its
+// SHA256 checksum does not match the given checksum. The checksum is not
+// verified if it is empty.
+func verifyArtifactChecksum(file, checksum string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error opening artifact: %v", err)
+	}
+	defer f.Close()
+	if checksum == "" {
+		return nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("error reading artifact %q: %v", file, err)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, checksum) {
+		return fmt.Errorf("artifact %q has SHA256 checksum %s, expected %s", file, sum, checksum)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checksumOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestResolveArtifactDefault(t *testing.T) {
+	path, err := resolveArtifact(Artifact{}, "inspector/linux/amd64/kismatic-inspector", "cache")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "inspector/linux/amd64/kismatic-inspector" {
+		t.Errorf("expected the default path, got %q", path)
+	}
+}
+
+func TestResolveArtifactLocalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	content := []byte("inspector")
+	file := filepath.Join(dir, "kismatic-inspector")
+	if err := ioutil.WriteFile(file, content, 0755); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	path, err := resolveArtifact(Artifact{Source: file, SHA256: checksumOf(content)}, "default", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != file {
+		t.Errorf("expected %q, got %q", file, path)
+	}
+
+	if _, err := resolveArtifact(Artifact{Source: file, SHA256: checksumOf([]byte("other"))}, "default", dir); err == nil {
+		t.Errorf("expected an error when the checksum does not match")
+	}
+	if _, err := resolveArtifact(Artifact{Source: filepath.Join(dir, "missing")}, "default", dir); err == nil {
+		t.Errorf("expected an error when the file does not exist")
+	}
+}
+
+func TestResolveArtifactDownload(t *testing.T) {
+	content := []byte("kuberang")
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(content)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "artifacts-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := Artifact{Source: server.URL + "/linux/amd64/kuberang", SHA256: checksumOf(content)}
+	path, err := resolveArtifact(a, "default", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(path) != "kuberang" {
+		t.Errorf("expected the artifact to be saved as kuberang, got %q", path)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading downloaded artifact: %v", err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("expected downloaded artifact to contain %q, got %q", content, got)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("error getting file info: %v", err)
+	}
+	if fi.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected downloaded artifact to be executable, got mode %v", fi.Mode())
+	}
+
+	// The cached artifact is reused
+	if _, err := resolveArtifact(a, "default", dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the artifact to be downloaded once, got %d requests", requests)
+	}
+
+	// A cached artifact that no longer matches the checksum is downloaded again
+	if err := ioutil.WriteFile(path, []byte("corrupted"), 0755); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if _, err := resolveArtifact(a, "default", dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected the artifact to be downloaded again, got %d requests", requests)
+	}
+}
+
+func TestResolveArtifactDownloadChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "artifacts-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := Artifact{Source: server.URL + "/kuberang", SHA256: checksumOf([]byte("kuberang"))}
+	if _, err := resolveArtifact(a, "default", dir); err == nil {
+		t.Fatalf("expected an error when the checksum does not match")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if len(files) != 0 {
+		t.Errorf("expected no files to be left in the cache, got %v", files)
+	}
+}
+
+func TestResolveArtifactDownloadError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "artifacts-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := resolveArtifact(Artifact{Source: server.URL + "/kuberang"}, "default", dir); err == nil {
+		t.Fatalf("expected an error when the server returns an error")
+	}
+}
@@ -0,0 +1,18 @@
+package install
+
+// assetDistributionSeeds returns the node that each node copies the additional
+// files from, keyed by host. The first node of each site is the seed of the
+// site, which copies the files from the local machine.
+func assetDistributionSeeds(nodes []Node) map[string]string {
+	seeds := map[string]string{}
+	siteSeeds := map[string]string{}
+	for _, n := range nodes {
+		seed, ok := siteSeeds[n.Site]
+		if !ok {
+			seed = n.Host
+			siteSeeds[n.Site] = seed
+		}
+		seeds[n.Host] = seed
+	}
+	return seeds
+}
@@ -0,0 +1,52 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssetDistributionSeeds(t *testing.T) {
+	nodes := []Node{
+		{Host: "etcd01"},
+		{Host: "master01", Site: "dc1"},
+		{Host: "worker01", Site: "dc1"},
+		{Host: "worker02", Site: "dc2"},
+		{Host: "worker03"},
+		{Host: "worker04", Site: "dc2"},
+	}
+	expected := map[string]string{
+		"etcd01":   "etcd01",
+		"master01": "master01",
+		"worker01": "master01",
+		"worker02": "worker02",
+		"worker03": "etcd01",
+		"worker04": "worker02",
+	}
+	if seeds := assetDistributionSeeds(nodes); !reflect.DeepEqual(seeds, expected) {
+		t.Errorf("expected seeds %v, but got %v", expected, seeds)
+	}
+}
+
+func TestValidateAssetDistribution(t *testing.T) {
+	tests := []struct {
+		ad    AssetDistribution
+		valid bool
+	}{
+		{ad: AssetDistribution{}, valid: true},
+		{ad: AssetDistribution{BandwidthLimit: 1024, PeerDistribution: true, Port: 8730}, valid: true},
+		{ad: AssetDistribution{BandwidthLimit: -1}},
+		{ad: AssetDistribution{PeerDistribution: true, Port: 70000}},
+	}
+	for i, test := range tests {
+		if ok, _ := test.ad.validate(); ok != test.valid {
+			t.Errorf("test %d: expected valid to be %v, but got %v", i, test.valid, ok)
+		}
+	}
+}
+
+func TestValidateNodeSiteDefinedOnce(t *testing.T) {
+	p := validPlan()
+	p.Etcd.Nodes[0].Site = "dc1"
+	p.Ingress.Nodes[0].Site = "dc2"
+	assertInvalidPlan(t, p)
+}
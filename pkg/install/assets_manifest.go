@@ -0,0 +1,78 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const assetsManifestFilename = "assets-manifest.json"
+
+// AssetsManifest identifies the cluster that the generated assets, such as
+// the certificates and the kubeconfig, belong to
+type AssetsManifest struct {
+	// ClusterName is the name of the cluster in the plan
+	ClusterName string `json:"clusterName"`
+	// PlanHash is the hash of the plan that last used the assets
+	PlanHash string `json:"planHash"`
+}
+
+// AssetsMismatchError is returned when the generated assets belong to a
+// different cluster than the one in the plan
+type AssetsMismatchError struct {
+	Dir         string
+	ClusterName string
+	PlanCluster string
+}
+
+func (e AssetsMismatchError) Error() string {
+	return fmt.Sprintf("the generated assets in %q belong to the cluster %q, but the plan file is for the cluster %q. Use a different generated assets directory for each cluster", e.Dir, e.ClusterName, e.PlanCluster)
+}
+
+// ReadAssetsManifest returns the manifest of the generated assets directory.
+// It returns nil when the directory does not have a manifest, such as when
+// the assets were generated by a previous version.
+func ReadAssetsManifest(dir string) (*AssetsManifest, error) {
+	file := filepath.Join(dir, assetsManifestFilename)
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading assets manifest from %s: %v", file, err)
+	}
+	m := &AssetsManifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("error unmarshaling assets manifest from %s: %v", file, err)
+	}
+	return m, nil
+}
+
+// VerifyAssetsCluster returns an AssetsMismatchError if the generated assets
+// belong to a different cluster than the one in the plan
+func VerifyAssetsCluster(p *Plan, dir string) error {
+	m, err := ReadAssetsManifest(dir)
+	if err != nil {
+		return err
+	}
+	if m == nil || m.ClusterName == p.Cluster.Name {
+		return nil
+	}
+	return AssetsMismatchError{Dir: dir, ClusterName: m.ClusterName, PlanCluster: p.Cluster.Name}
+}
+
+// writeAssetsManifest records the cluster of the plan in the generated assets
+// directory
+func writeAssetsManifest(p *Plan, dir string) error {
+	b, err := json.MarshalIndent(AssetsManifest{ClusterName: p.Cluster.Name, PlanHash: planHash(p)}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling assets manifest: %v", err)
+	}
+	file := filepath.Join(dir, assetsManifestFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing assets manifest to %s: %v", file, err)
+	}
+	return nil
+}
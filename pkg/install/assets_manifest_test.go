@@ -0,0 +1,41 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVerifyAssetsCluster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets-manifest-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &Plan{}
+	p.Cluster.Name = "production"
+	if err := VerifyAssetsCluster(p, dir); err != nil {
+		t.Errorf("expected assets without a manifest to be used, but got %v", err)
+	}
+	if err := writeAssetsManifest(p, dir); err != nil {
+		t.Fatalf("unexpected error writing the manifest: %v", err)
+	}
+	m, err := ReadAssetsManifest(dir)
+	if err != nil {
+		t.Fatalf("unexpected error reading the manifest: %v", err)
+	}
+	if m.ClusterName != "production" || m.PlanHash != planHash(p) {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+	if err := VerifyAssetsCluster(p, dir); err != nil {
+		t.Errorf("expected the assets to belong to the cluster, but got %v", err)
+	}
+
+	other := &Plan{}
+	other.Cluster.Name = "staging"
+	err = VerifyAssetsCluster(other, dir)
+	if _, ok := err.(AssetsMismatchError); !ok {
+		t.Errorf("expected an AssetsMismatchError for another cluster, but got %v", err)
+	}
+}
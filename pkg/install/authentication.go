@@ -0,0 +1,119 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/blang/semver"
+)
+
+const (
+	basicAuthMode = "basic"
+	certAuthMode  = "cert"
+	tokenAuthMode = "token"
+	oidcAuthMode  = "oidc"
+
+	// basicAuthRemovedIn is the minor version of Kubernetes in which basic
+	// authentication was removed from the API server
+	basicAuthRemovedIn = 19
+
+	// adminTokenEnvVar is the environment variable that holds the token of
+	// the admin user generated by KET. Ansible reads the token from the
+	// environment, so that it is not written to the cluster catalog.
+	adminTokenEnvVar = "KISMATIC_ADMIN_TOKEN"
+)
+
+func authModes() []string {
+	return []string{certAuthMode, tokenAuthMode, oidcAuthMode}
+}
+
+// supportsBasicAuth returns true if the API server of the Kubernetes version
+// supports basic authentication
+func supportsBasicAuth(version semver.Version) bool {
+	return version.Major == 1 && version.Minor < basicAuthRemovedIn
+}
+
+// hasAdminPassword returns true if the admin password is set in the plan,
+// referenced in a secrets manager or generated by KET
+func hasAdminPassword(c Cluster) bool {
+	return c.AdminPassword != "" || c.AdminPasswordSecret != nil || c.GenerateAdminPassword
+}
+
+// authenticationMode returns the mode used to authenticate the users of the
+// cluster. For compatibility with the plans that set the admin password,
+// basic authentication is used when the mode is not set, unless the
+// Kubernetes version no longer supports it.
+func authenticationMode(c Cluster, version semver.Version) string {
+	if c.Authentication.Mode != "" {
+		return c.Authentication.Mode
+	}
+	if hasAdminPassword(c) && supportsBasicAuth(version) {
+		return basicAuthMode
+	}
+	return certAuthMode
+}
+
+func (a *Authentication) validate() (bool, []error) {
+	v := newValidator()
+	if a.Mode != "" && !util.Contains(a.Mode, authModes()) {
+		v.addError(fmt.Errorf("%q is not a valid authentication mode. Options are %v", a.Mode, authModes()))
+	}
+	if a.Mode == oidcAuthMode {
+		if a.OIDC.IssuerURL == "" {
+			v.addError(errors.New("OIDC issuer URL is required"))
+		} else if u, err := url.Parse(a.OIDC.IssuerURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			v.addError(fmt.Errorf("OIDC issuer URL %q must be an https URL", a.OIDC.IssuerURL))
+		}
+		if a.OIDC.ClientID == "" {
+			v.addError(errors.New("OIDC client ID is required"))
+		}
+	}
+	return v.valid()
+}
+
+// validateAuthentication returns the errors of the admin password, which
+// requires basic authentication
+func validateAuthentication(c Cluster, version semver.Version) []error {
+	if !hasAdminPassword(c) {
+		return nil
+	}
+	if c.Authentication.Mode != "" {
+		return []error{fmt.Errorf("Admin password requires basic authentication, it cannot be set with the %q authentication mode", c.Authentication.Mode)}
+	}
+	if !supportsBasicAuth(version) {
+		return []error{fmt.Errorf("Admin password cannot be set, basic authentication was removed in Kubernetes v1.%d. Set cluster.authentication.mode instead", basicAuthRemovedIn)}
+	}
+	return nil
+}
+
+// setAuthentication sets the authentication of the users in the cluster
+// catalog. The admin password is only passed to ansible when basic
+// authentication is used, so that the playbooks do not configure it otherwise.
+func (ae *ansibleExecutor) setAuthentication(cc *ansible.ClusterCatalog, p *Plan, version semver.Version) error {
+	mode := authenticationMode(p.Cluster, version)
+	generatePassword := mode == basicAuthMode && p.Cluster.GenerateAdminPassword
+	generateToken := mode == tokenAuthMode
+	if generatePassword || generateToken {
+		if err := ae.setGeneratedCredentials(generatePassword, generateToken); err != nil {
+			return err
+		}
+	}
+	cc.Authentication.Mode = mode
+	if mode != basicAuthMode {
+		cc.AdminPassword = ""
+	}
+	if mode == tokenAuthMode {
+		cc.Authentication.AdminToken = fmt.Sprintf("{{ lookup('env', '%s') }}", adminTokenEnvVar)
+	}
+	if mode == oidcAuthMode {
+		oidc := p.Cluster.Authentication.OIDC
+		cc.Authentication.OIDC.IssuerURL = oidc.IssuerURL
+		cc.Authentication.OIDC.ClientID = oidc.ClientID
+		cc.Authentication.OIDC.UsernameClaim = oidc.UsernameClaim
+		cc.Authentication.OIDC.GroupsClaim = oidc.GroupsClaim
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package install
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/blang/semver"
+)
+
+func TestAuthenticationMode(t *testing.T) {
+	tests := []struct {
+		cluster  Cluster
+		version  string
+		expected string
+	}{
+		{
+			cluster:  Cluster{},
+			version:  "1.10.3",
+			expected: certAuthMode,
+		},
+		{
+			cluster:  Cluster{AdminPassword: "password"},
+			version:  "1.10.3",
+			expected: basicAuthMode,
+		},
+		{
+			cluster:  Cluster{GenerateAdminPassword: true},
+			version:  "1.10.3",
+			expected: basicAuthMode,
+		},
+		{
+			cluster:  Cluster{AdminPassword: "password"},
+			version:  "1.19.0",
+			expected: certAuthMode,
+		},
+		{
+			cluster:  Cluster{Authentication: Authentication{Mode: oidcAuthMode}},
+			version:  "1.10.3",
+			expected: oidcAuthMode,
+		},
+	}
+	for i, test := range tests {
+		if got := authenticationMode(test.cluster, semver.MustParse(test.version)); got != test.expected {
+			t.Errorf("test %d: expected %q, got %q", i, test.expected, got)
+		}
+	}
+}
+
+func TestValidateAuthentication(t *testing.T) {
+	tests := []struct {
+		auth  Authentication
+		valid bool
+	}{
+		{
+			auth:  Authentication{},
+			valid: true,
+		},
+		{
+			auth:  Authentication{Mode: "token"},
+			valid: true,
+		},
+		{
+			auth:  Authentication{Mode: "basic"},
+			valid: false,
+		},
+		{
+			auth:  Authentication{Mode: "oidc", OIDC: OIDC{IssuerURL: "https://accounts.example.com", ClientID: "kubernetes"}},
+			valid: true,
+		},
+		{
+			auth:  Authentication{Mode: "oidc", OIDC: OIDC{IssuerURL: "http://accounts.example.com", ClientID: "kubernetes"}},
+			valid: false,
+		},
+		{
+			auth:  Authentication{Mode: "oidc", OIDC: OIDC{IssuerURL: "https://accounts.example.com"}},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		if ok, errs := test.auth.validate(); ok != test.valid {
+			t.Errorf("test %d: expected valid to be %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func TestValidateAdminPasswordAuthentication(t *testing.T) {
+	c := Cluster{AdminPassword: "password", Authentication: Authentication{Mode: tokenAuthMode}}
+	if errs := validateAuthentication(c, semver.MustParse("1.10.3")); len(errs) != 1 {
+		t.Errorf("expected an error when the admin password is set with the token mode, got %v", errs)
+	}
+	c.Authentication.Mode = ""
+	if errs := validateAuthentication(c, semver.MustParse("1.10.3")); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if errs := validateAuthentication(c, semver.MustParse("1.19.0")); len(errs) != 1 {
+		t.Errorf("expected an error when the version does not support basic authentication, got %v", errs)
+	}
+}
+
+func TestSetAuthentication(t *testing.T) {
+	defer os.Unsetenv(adminTokenEnvVar)
+	defer os.Unsetenv(credentialsKeyEnvVar)
+	os.Setenv(credentialsKeyEnvVar, "key")
+	tests := []struct {
+		auth          Authentication
+		adminPassword string
+		version       string
+		mode          string
+		password      string
+		token         bool
+	}{
+		{
+			adminPassword: "password",
+			version:       "1.10.3",
+			mode:          basicAuthMode,
+			password:      "password",
+		},
+		{
+			adminPassword: "password",
+			version:       "1.19.0",
+			mode:          certAuthMode,
+		},
+		{
+			auth:    Authentication{Mode: tokenAuthMode},
+			version: "1.10.3",
+			mode:    tokenAuthMode,
+			token:   true,
+		},
+		{
+			auth:    Authentication{Mode: oidcAuthMode, OIDC: OIDC{IssuerURL: "https://accounts.example.com", ClientID: "kubernetes"}},
+			version: "1.10.3",
+			mode:    oidcAuthMode,
+		},
+	}
+	for i, test := range tests {
+		dir := mustGetTempDir(t)
+		ae := &ansibleExecutor{options: ExecutorOptions{GeneratedAssetsDirectory: dir}, stdout: &bytes.Buffer{}}
+		p := &Plan{}
+		p.Cluster.AdminPassword = test.adminPassword
+		p.Cluster.Authentication = test.auth
+		cc := &ansible.ClusterCatalog{AdminPassword: adminPassword(p)}
+		if err := ae.setAuthentication(cc, p, semver.MustParse(test.version)); err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if cc.Authentication.Mode != test.mode {
+			t.Errorf("test %d: expected the mode %q, got %q", i, test.mode, cc.Authentication.Mode)
+		}
+		if cc.AdminPassword != test.password {
+			t.Errorf("test %d: expected the admin password %q, got %q", i, test.password, cc.AdminPassword)
+		}
+		if test.token {
+			if !strings.Contains(cc.Authentication.AdminToken, adminTokenEnvVar) {
+				t.Errorf("test %d: expected the admin token to be read from the environment, got %q", i, cc.Authentication.AdminToken)
+			}
+			c, err := ReadAdminCredentials(dir)
+			if err != nil || c == nil || c.AdminToken == "" || c.AdminToken != os.Getenv(adminTokenEnvVar) {
+				t.Errorf("test %d: expected the admin token to be generated, got %v %v", i, c, err)
+			}
+		} else if cc.Authentication.AdminToken != "" {
+			t.Errorf("test %d: unexpected admin token %q", i, cc.Authentication.AdminToken)
+		}
+		if test.mode == oidcAuthMode && cc.Authentication.OIDC.IssuerURL != test.auth.OIDC.IssuerURL {
+			t.Errorf("test %d: expected the OIDC provider to be set, got %+v", i, cc.Authentication.OIDC)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package install
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+const (
+	awsClusterTagPrefix = "kubernetes.io/cluster/"
+	// awsLegacyClusterTag is the tag used by Kubernetes before v1.6
+	awsLegacyClusterTag = "KubernetesCluster"
+	awsMetadataURL      = "http://169.254.169.254/latest/meta-data/"
+)
+
+// IAMPolicy is an AWS IAM policy document
+type IAMPolicy struct {
+	Version   string
+	Statement []IAMPolicyStatement
+}
+
+// IAMPolicyStatement is a statement of an AWS IAM policy document
+type IAMPolicyStatement struct {
+	Effect   string
+	Action   []string
+	Resource []string
+}
+
+// AWSMasterIAMPolicy returns the IAM policy required by the master nodes
+func AWSMasterIAMPolicy() IAMPolicy {
+	return IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []IAMPolicyStatement{
+			{Effect: "Allow", Action: []string{"ec2:*"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"elasticloadbalancing:*"}, Resource: []string{"*"}},
+		},
+	}
+}
+
+// AWSNodeIAMPolicy returns the IAM policy required by the worker, ingress and
+// storage nodes
+func AWSNodeIAMPolicy() IAMPolicy {
+	return IAMPolicy{
+		Version: "2012-10-17",
+		Statement: []IAMPolicyStatement{
+			{Effect: "Allow", Action: []string{"ec2:Describe*"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"ec2:AttachVolume"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"ec2:DetachVolume"}, Resource: []string{"*"}},
+			{
+				Effect: "Allow",
+				Action: []string{
+					"ecr:GetAuthorizationToken",
+					"ecr:BatchCheckLayerAvailability",
+					"ecr:GetDownloadUrlForLayer",
+					"ecr:GetRepositoryPolicy",
+					"ecr:DescribeRepositories",
+					"ecr:ListImages",
+					"ecr:BatchGetImage",
+				},
+				Resource: []string{"*"},
+			},
+		},
+	}
+}
+
+// WriteAWSIAMPolicies writes the IAM policies required by the nodes of the
+// cluster to the directory, and returns the paths of the files
+func WriteAWSIAMPolicies(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("error creating directory %s: %v", dir, err)
+	}
+	policies := []struct {
+		file   string
+		policy IAMPolicy
+	}{
+		{"master-iam-policy.json", AWSMasterIAMPolicy()},
+		{"node-iam-policy.json", AWSNodeIAMPolicy()},
+	}
+	var files []string
+	for _, p := range policies {
+		b, err := json.MarshalIndent(p.policy, "", "    ")
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling IAM policy: %v", err)
+		}
+		file := filepath.Join(dir, p.file)
+		if err := ioutil.WriteFile(file, append(b, '\n'), 0644); err != nil {
+			return nil, fmt.Errorf("error writing IAM policy: %v", err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// awsCredentials are the temporary credentials of the IAM role of an instance
+type awsCredentials struct {
+	Code            string
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+}
+
+// awsNode makes requests to the AWS API from a node, using the credentials of
+// the IAM role of the instance
+type awsNode struct {
+	client     ssh.Client
+	instanceID string
+	region     string
+	creds      awsCredentials
+	// Hook for testing purposes, returns the time used to sign requests
+	now func() time.Time
+}
+
+func metadata(client ssh.Client, path string) (string, error) {
+	out, err := client.Output(true, "curl -sf --max-time 10 "+awsMetadataURL+path)
+	return strings.TrimSpace(out), err
+}
+
+// newAWSNode gets the identity and the credentials of the instance from the
+// instance metadata service
+func newAWSNode(client ssh.Client) (*awsNode, error) {
+	out, err := metadata(client, "iam/security-credentials/")
+	if err != nil || out == "" {
+		return nil, fmt.Errorf("the instance does not have an IAM role")
+	}
+	role := strings.TrimSpace(strings.Split(out, "\n")[0])
+	out, err = metadata(client, "iam/security-credentials/"+role)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the credentials of IAM role %q: %v", role, err)
+	}
+	n := &awsNode{client: client, now: time.Now}
+	if err := json.Unmarshal([]byte(out), &n.creds); err != nil || n.creds.Code != "Success" {
+		return nil, fmt.Errorf("the credentials of IAM role %q are not available", role)
+	}
+	if n.instanceID, err = metadata(client, "instance-id"); err != nil {
+		return nil, fmt.Errorf("error getting the instance ID: %v", err)
+	}
+	az, err := metadata(client, "placement/availability-zone")
+	if err != nil || len(az) < 2 {
+		return nil, fmt.Errorf("error getting the availability zone of the instance: %v", err)
+	}
+	n.region = az[:len(az)-1]
+	return n, nil
+}
+
+func (n *awsNode) endpoint(service string) string {
+	host := fmt.Sprintf("%s.%s.amazonaws.com", service, n.region)
+	if strings.HasPrefix(n.region, "cn-") {
+		host = host + ".cn"
+	}
+	return host
+}
+
+// call makes a request to the query API of the AWS service, and returns the
+// status code and the body of the response
+func (n *awsNode) call(service string, params url.Values) (int, []byte, error) {
+	host := n.endpoint(service)
+	headers := signAWSRequest(host, n.region, service, params, n.creds, n.now())
+	args := []string{"curl", "-s", "--max-time", "30", "-w", `'\n%{http_code}'`}
+	for _, k := range sortedHeaderKeys(headers) {
+		args = append(args, "-H", fmt.Sprintf("'%s: %s'", k, headers[k]))
+	}
+	args = append(args, fmt.Sprintf("'https://%s/?%s'", host, awsQuery(params)))
+	out, err := n.client.Output(false, strings.Join(args, " "))
+	if err != nil {
+		return 0, nil, fmt.Errorf("error calling %s %s: %v", service, params.Get("Action"), err)
+	}
+	out = strings.TrimRight(out, "\r\n")
+	idx := strings.LastIndex(out, "\n")
+	var status int
+	if _, err := fmt.Sscanf(out[idx+1:], "%d", &status); err != nil {
+		return 0, nil, fmt.Errorf("unexpected response calling %s %s: %q", service, params.Get("Action"), out)
+	}
+	if idx < 0 {
+		return status, nil, nil
+	}
+	return status, []byte(out[:idx]), nil
+}
+
+func sortedHeaderKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// signAWSRequest signs a GET request to the query API using AWS Signature
+// Version 4, and returns the headers of the request
+func signAWSRequest(host, region, service string, params url.Values, creds awsCredentials, now time.Time) map[string]string {
+	return signAWSRequestV4("GET", host, "/", awsQuery(params), nil, region, service, creds, now)
+}
+
+// signAWSRequestV4 signs a request using AWS Signature Version 4, and returns
+// the headers of the request. The path must be URI encoded. Requests to S3
+// include the hash of the payload in the x-amz-content-sha256 header.
+func signAWSRequestV4(method, host, path, query string, payload []byte, region, service string, creds awsCredentials, now time.Time) map[string]string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256.Sum256(payload)
+	headers := map[string]string{
+		"host":       host,
+		"x-amz-date": amzDate,
+	}
+	if service == "s3" {
+		headers["x-amz-content-sha256"] = hex.EncodeToString(payloadHash[:])
+	}
+	if creds.Token != "" {
+		headers["x-amz-security-token"] = creds.Token
+	}
+	keys := sortedHeaderKeys(headers)
+	canonicalHeaders := ""
+	for _, k := range keys {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(keys, ";")
+	canonicalRequest := strings.Join([]string{method, path, query, canonicalHeaders, signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(hashedRequest[:])}, "\n")
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	delete(headers, "host")
+	headers["Authorization"] = fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature)
+	return headers
+}
+
+// awsQuery returns the canonical query string of the parameters. The
+// parameters are sorted by key, and spaces are encoded as %20.
+func awsQuery(params url.Values) string {
+	return strings.Replace(params.Encode(), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsPermissionCheck is a request that succeeds when the IAM role has the
+// permission to perform the action
+type awsPermissionCheck struct {
+	permission string
+	service    string
+	params     url.Values
+	// dryRun is true when the action is checked using the DryRun parameter,
+	// which returns an error when the request would have succeeded
+	dryRun bool
+}
+
+func awsPermissionChecks(instanceID string, master bool) []awsPermissionCheck {
+	checks := []awsPermissionCheck{
+		{
+			permission: "ec2:DescribeInstances",
+			service:    "ec2",
+			params:     url.Values{"Action": {"DescribeInstances"}, "Version": {"2016-11-15"}, "InstanceId.1": {instanceID}},
+		},
+		{
+			permission: "ec2:DescribeVolumes",
+			service:    "ec2",
+			params:     url.Values{"Action": {"DescribeVolumes"}, "Version": {"2016-11-15"}, "MaxResults": {"5"}},
+		},
+	}
+	if master {
+		checks = append(checks,
+			awsPermissionCheck{
+				permission: "ec2:CreateTags",
+				service:    "ec2",
+				params:     url.Values{"Action": {"CreateTags"}, "Version": {"2016-11-15"}, "DryRun": {"true"}, "ResourceId.1": {instanceID}, "Tag.1.Key": {"kismatic/permission-check"}},
+				dryRun:     true,
+			},
+			awsPermissionCheck{
+				permission: "elasticloadbalancing:DescribeLoadBalancers",
+				service:    "elasticloadbalancing",
+				params:     url.Values{"Action": {"DescribeLoadBalancers"}, "Version": {"2012-06-01"}, "PageSize": {"1"}},
+			},
+		)
+	}
+	return checks
+}
+
+// checkPermissions verifies that the IAM role of the instance has the
+// permissions required by the Kubernetes components running on the node
+func (n *awsNode) checkPermissions(master bool) []error {
+	var errs []error
+	for _, c := range awsPermissionChecks(n.instanceID, master) {
+		status, body, err := n.call(c.service, c.params)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		allowed := status == 200
+		if c.dryRun {
+			allowed = status == 412 && strings.Contains(string(body), "DryRunOperation")
+		}
+		if !allowed {
+			errs = append(errs, fmt.Errorf("the IAM role of the instance does not allow %s (status code %d)", c.permission, status))
+		}
+	}
+	return errs
+}
+
+type ec2Tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+type ec2DescribeInstancesResponse struct {
+	Reservations []struct {
+		Instances []struct {
+			SubnetID string   `xml:"subnetId"`
+			Tags     []ec2Tag `xml:"tagSet>item"`
+		} `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+}
+
+type ec2DescribeSubnetsResponse struct {
+	Subnets []struct {
+		Tags []ec2Tag `xml:"tagSet>item"`
+	} `xml:"subnetSet>item"`
+}
+
+// awsClusterID returns the ID of the cluster set in the tags, or an empty
+// string if the tags do not include a cluster tag
+func awsClusterID(tags []ec2Tag) string {
+	for _, t := range tags {
+		if strings.HasPrefix(t.Key, awsClusterTagPrefix) {
+			return strings.TrimPrefix(t.Key, awsClusterTagPrefix)
+		}
+	}
+	for _, t := range tags {
+		if t.Key == awsLegacyClusterTag {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// clusterTags returns the cluster ID set in the tags of the instance and of
+// its subnet
+func (n *awsNode) clusterTags() (instanceClusterID string, subnetClusterID string, err error) {
+	status, body, err := n.call("ec2", url.Values{"Action": {"DescribeInstances"}, "Version": {"2016-11-15"}, "InstanceId.1": {n.instanceID}})
+	if err != nil {
+		return "", "", err
+	}
+	if status != 200 {
+		return "", "", fmt.Errorf("error describing instance %s: status code %d", n.instanceID, status)
+	}
+	instances := ec2DescribeInstancesResponse{}
+	if err := xml.Unmarshal(body, &instances); err != nil {
+		return "", "", fmt.Errorf("error parsing the description of instance %s: %v", n.instanceID, err)
+	}
+	if len(instances.Reservations) == 0 || len(instances.Reservations[0].Instances) == 0 {
+		return "", "", fmt.Errorf("instance %s was not found", n.instanceID)
+	}
+	instance := instances.Reservations[0].Instances[0]
+	status, body, err = n.call("ec2", url.Values{"Action": {"DescribeSubnets"}, "Version": {"2016-11-15"}, "SubnetId.1": {instance.SubnetID}})
+	if err != nil {
+		return "", "", err
+	}
+	if status != 200 {
+		return "", "", fmt.Errorf("error describing subnet %s: status code %d", instance.SubnetID, status)
+	}
+	subnets := ec2DescribeSubnetsResponse{}
+	if err := xml.Unmarshal(body, &subnets); err != nil {
+		return "", "", fmt.Errorf("error parsing the description of subnet %s: %v", instance.SubnetID, err)
+	}
+	if len(subnets.Subnets) == 0 {
+		return "", "", fmt.Errorf("subnet %s was not found", instance.SubnetID)
+	}
+	return awsClusterID(instance.Tags), awsClusterID(subnets.Subnets[0].Tags), nil
+}
+
+// validateAWSClusterTags verifies that the instances and subnets of all nodes
+// are tagged with the same cluster ID
+func validateAWSClusterTags(p *Plan, sshClient func(p *Plan, host string) (ssh.Client, error)) []error {
+	var errs []error
+	clusterIDs := map[string][]string{}
+	for _, n := range p.GetUniqueNodes() {
+		client, err := sshClient(p, n.Host)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error getting SSH client for %q: %v", n.Host, err))
+			continue
+		}
+		node, err := newAWSNode(client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("AWS check failed on node %q: %v", n.Host, err))
+			continue
+		}
+		instanceID, subnetID, err := node.clusterTags()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("AWS check failed on node %q: %v", n.Host, err))
+			continue
+		}
+		if instanceID == "" {
+			errs = append(errs, fmt.Errorf("instance %s of node %q is not tagged with the %s<cluster id> tag", node.instanceID, n.Host, awsClusterTagPrefix))
+			continue
+		}
+		if subnetID != instanceID {
+			errs = append(errs, fmt.Errorf("the subnet of node %q is not tagged with the %s%s tag", n.Host, awsClusterTagPrefix, instanceID))
+		}
+		clusterIDs[instanceID] = append(clusterIDs[instanceID], n.Host)
+	}
+	if len(clusterIDs) > 1 {
+		errs = append(errs, fmt.Errorf("the nodes are tagged with different cluster IDs: %v", clusterIDs))
+	}
+	return errs
+}
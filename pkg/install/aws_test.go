@@ -0,0 +1,251 @@
+package install
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+func TestWriteAWSIAMPolicies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aws-policies")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	files, err := WriteAWSIAMPolicies(filepath.Join(dir, "aws"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]IAMPolicy{
+		filepath.Join(dir, "aws", "master-iam-policy.json"): AWSMasterIAMPolicy(),
+		filepath.Join(dir, "aws", "node-iam-policy.json"):   AWSNodeIAMPolicy(),
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, got %v", len(expected), files)
+	}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatalf("error reading policy: %v", err)
+		}
+		var policy IAMPolicy
+		if err := json.Unmarshal(b, &policy); err != nil {
+			t.Fatalf("error unmarshaling policy %s: %v", f, err)
+		}
+		if !reflect.DeepEqual(policy, expected[f]) {
+			t.Errorf("unexpected policy in %s: %s", f, b)
+		}
+	}
+}
+
+func TestAWSSigningKey(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation
+	key := []byte("AWS4wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	for _, s := range []string{"20120215", "us-east-1", "iam", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	expected := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if hex.EncodeToString(key) != expected {
+		t.Errorf("expected signing key %s, got %s", expected, hex.EncodeToString(key))
+	}
+}
+
+func TestSignAWSRequest(t *testing.T) {
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", Token: "token"}
+	now := time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC)
+	params := url.Values{"Action": {"DescribeInstances"}, "Version": {"2016-11-15"}}
+	headers := signAWSRequest("ec2.us-east-1.amazonaws.com", "us-east-1", "ec2", params, creds, now)
+	if headers["x-amz-date"] != "20180601T123000Z" || headers["x-amz-security-token"] != "token" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+	auth := headers["Authorization"]
+	prefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20180601/us-east-1/ec2/aws4_request, SignedHeaders=host;x-amz-date;x-amz-security-token, Signature="
+	if !strings.HasPrefix(auth, prefix) || len(auth) != len(prefix)+64 {
+		t.Errorf("unexpected authorization header: %s", auth)
+	}
+	params.Set("Action", "DescribeSubnets")
+	if other := signAWSRequest("ec2.us-east-1.amazonaws.com", "us-east-1", "ec2", params, creds, now); other["Authorization"] == auth {
+		t.Errorf("expected the signature to depend on the parameters")
+	}
+}
+
+// fakeEC2Instance simulates the instance metadata service and the AWS API of
+// an EC2 instance
+type fakeEC2Instance struct {
+	role         string
+	instanceTags string
+	subnetTags   string
+	// actions that are not allowed by the IAM role
+	denied   []string
+	requests []url.Values
+}
+
+func ec2Tags(tags string) string {
+	if tags == "" {
+		return "<tagSet/>"
+	}
+	return fmt.Sprintf("<tagSet><item><key>%s</key><value></value></item></tagSet>", tags)
+}
+
+func (i *fakeEC2Instance) Output(pty bool, args ...string) (string, error) {
+	cmd := strings.Join(args, " ")
+	if strings.HasPrefix(cmd, "curl -sf --max-time 10 "+awsMetadataURL) {
+		switch strings.TrimPrefix(cmd, "curl -sf --max-time 10 "+awsMetadataURL) {
+		case "iam/security-credentials/":
+			if i.role == "" {
+				return "", errors.New("exit status 22")
+			}
+			return i.role + "\r\n", nil
+		case "iam/security-credentials/" + i.role:
+			return `{"Code": "Success", "AccessKeyId": "AKID", "SecretAccessKey": "secret", "Token": "token"}`, nil
+		case "instance-id":
+			return "i-0123456789", nil
+		case "placement/availability-zone":
+			return "us-east-1a", nil
+		}
+		return "", errors.New("exit status 22")
+	}
+	fields := strings.Fields(cmd)
+	u, err := url.Parse(strings.Trim(fields[len(fields)-1], "'"))
+	if err != nil || !strings.Contains(cmd, "Authorization: AWS4-HMAC-SHA256") {
+		return "", errors.New("unexpected command: " + cmd)
+	}
+	params := u.Query()
+	i.requests = append(i.requests, params)
+	action := params.Get("Action")
+	for _, d := range i.denied {
+		if d == action {
+			return "<Response><Errors><Error><Code>UnauthorizedOperation</Code></Error></Errors></Response>\n403", nil
+		}
+	}
+	switch action {
+	case "DescribeInstances":
+		return fmt.Sprintf("<DescribeInstancesResponse><reservationSet><item><instancesSet><item><instanceId>i-0123456789</instanceId><subnetId>subnet-1</subnetId>%s</item></instancesSet></item></reservationSet></DescribeInstancesResponse>\n200", ec2Tags(i.instanceTags)), nil
+	case "DescribeSubnets":
+		return fmt.Sprintf("<DescribeSubnetsResponse><subnetSet><item><subnetId>subnet-1</subnetId>%s</item></subnetSet></DescribeSubnetsResponse>\n200", ec2Tags(i.subnetTags)), nil
+	case "CreateTags":
+		return "<Response><Errors><Error><Code>DryRunOperation</Code></Error></Errors></Response>\n412", nil
+	}
+	return "<Response/>\n200", nil
+}
+
+func (i *fakeEC2Instance) Shell(pty bool, args ...string) error { return nil }
+
+func TestValidateCloudProviderCredentialsAWS(t *testing.T) {
+	tests := []struct {
+		instance fakeEC2Instance
+		valid    bool
+	}{
+		{instance: fakeEC2Instance{role: "kubernetes"}, valid: true},
+		{instance: fakeEC2Instance{}, valid: false},
+		{instance: fakeEC2Instance{role: "kubernetes", denied: []string{"DescribeVolumes"}}, valid: false},
+		{instance: fakeEC2Instance{role: "kubernetes", denied: []string{"CreateTags"}}, valid: false},
+	}
+	for i, test := range tests {
+		p := loadBalancerTestPlan("lb.example.com")
+		p.Cluster.CloudProvider.Provider = "aws"
+		instances := map[string]*fakeEC2Instance{}
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			instance := test.instance
+			instances[host] = &instance
+			return &instance, nil
+		}
+		ok, errs := validateCloudProviderCredentials(p, sshClient)
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+		if len(instances) != len(p.GetUniqueNodes()) {
+			t.Errorf("test %d: expected every node to be checked, got %v", i, instances)
+		}
+		if !test.valid {
+			continue
+		}
+		// the master permissions are only checked on the masters
+		for host, instance := range instances {
+			checkedLB := false
+			for _, r := range instance.requests {
+				if r.Get("Action") == "DescribeLoadBalancers" {
+					checkedLB = true
+				}
+			}
+			if checkedLB != nodeInList(host, p.Master.Nodes) {
+				t.Errorf("test %d: unexpected load balancer permission check on %s", i, host)
+			}
+		}
+	}
+}
+
+func TestValidateAWSClusterTags(t *testing.T) {
+	tests := []struct {
+		instances map[string]fakeEC2Instance
+		errors    int
+	}{
+		{
+			instances: map[string]fakeEC2Instance{
+				"etcd1":   {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"master1": {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"master2": {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"worker1": {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+			},
+		},
+		{
+			instances: map[string]fakeEC2Instance{
+				"etcd1":   {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"master1": {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"master2": {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: ""},
+				"worker1": {role: "k8s", instanceTags: "", subnetTags: "kubernetes.io/cluster/prod"},
+			},
+			// untagged subnet of master2 and untagged instance of worker1
+			errors: 2,
+		},
+		{
+			instances: map[string]fakeEC2Instance{
+				"etcd1":   {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"master1": {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"master2": {role: "k8s", instanceTags: "kubernetes.io/cluster/prod", subnetTags: "kubernetes.io/cluster/prod"},
+				"worker1": {role: "k8s", instanceTags: "kubernetes.io/cluster/dev", subnetTags: "kubernetes.io/cluster/dev"},
+			},
+			// different cluster IDs
+			errors: 1,
+		},
+	}
+	for i, test := range tests {
+		p := loadBalancerTestPlan("lb.example.com")
+		p.Cluster.CloudProvider.Provider = "aws"
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			instance := test.instances[host]
+			return &instance, nil
+		}
+		ok, errs := validateCloudProviderNodes(p, sshClient)
+		if len(errs) != test.errors || ok != (test.errors == 0) {
+			t.Errorf("test %d: expected %d errors, got %v", i, test.errors, errs)
+		}
+	}
+}
+
+func TestAWSClusterID(t *testing.T) {
+	tests := []struct {
+		tags     []ec2Tag
+		expected string
+	}{
+		{tags: []ec2Tag{{Key: "Name", Value: "worker1"}, {Key: "kubernetes.io/cluster/prod", Value: "owned"}}, expected: "prod"},
+		{tags: []ec2Tag{{Key: "KubernetesCluster", Value: "legacy"}}, expected: "legacy"},
+		{tags: []ec2Tag{{Key: "Name", Value: "worker1"}}, expected: ""},
+	}
+	for i, test := range tests {
+		if id := awsClusterID(test.tags); id != test.expected {
+			t.Errorf("test %d: expected %q, got %q", i, test.expected, id)
+		}
+	}
+}
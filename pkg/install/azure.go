@@ -0,0 +1,505 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// azureEnvironment are the endpoints of an Azure cloud
+type azureEnvironment struct {
+	login           string
+	resourceManager string
+}
+
+// azureEnvironments are the Azure clouds supported by the cloud provider. An
+// empty cloud in the config file is the public cloud.
+var azureEnvironments = map[string]azureEnvironment{
+	"":                       {"https://login.microsoftonline.com", "https://management.azure.com"},
+	"AzurePublicCloud":       {"https://login.microsoftonline.com", "https://management.azure.com"},
+	"AzureChinaCloud":        {"https://login.chinacloudapi.cn", "https://management.chinacloudapi.cn"},
+	"AzureGermanCloud":       {"https://login.microsoftonline.de", "https://management.microsoftazure.de"},
+	"AzureUSGovernmentCloud": {"https://login.microsoftonline.us", "https://management.usgovcloudapi.net"},
+}
+
+const (
+	azureComputeAPIVersion       = "2017-12-01"
+	azureNetworkAPIVersion       = "2018-02-01"
+	azureAuthorizationAPIVersion = "2015-07-01"
+	azureMetadataTokenURL        = "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource="
+)
+
+// azureCloudConfig is the azure.json config file of the cloud provider
+type azureCloudConfig struct {
+	Cloud                       string `yaml:"cloud" json:"cloud"`
+	TenantID                    string `yaml:"tenantId" json:"tenantId"`
+	SubscriptionID              string `yaml:"subscriptionId" json:"subscriptionId"`
+	AADClientID                 string `yaml:"aadClientId" json:"aadClientId,omitempty"`
+	AADClientSecret             string `yaml:"aadClientSecret" json:"aadClientSecret,omitempty"`
+	UseManagedIdentityExtension bool   `yaml:"useManagedIdentityExtension" json:"useManagedIdentityExtension"`
+	ResourceGroup               string `yaml:"resourceGroup" json:"resourceGroup"`
+	Location                    string `yaml:"location" json:"location"`
+	VNetName                    string `yaml:"vnetName" json:"vnetName,omitempty"`
+	VNetResourceGroup           string `yaml:"vnetResourceGroup" json:"vnetResourceGroup,omitempty"`
+	SubnetName                  string `yaml:"subnetName" json:"subnetName,omitempty"`
+	SecurityGroupName           string `yaml:"securityGroupName" json:"securityGroupName,omitempty"`
+	RouteTableName              string `yaml:"routeTableName" json:"routeTableName,omitempty"`
+	VMType                      string `yaml:"vmType" json:"vmType,omitempty"`
+	PrimaryAvailabilitySetName  string `yaml:"primaryAvailabilitySetName" json:"primaryAvailabilitySetName,omitempty"`
+	PrimaryScaleSetName         string `yaml:"primaryScaleSetName" json:"primaryScaleSetName,omitempty"`
+	LoadBalancerSku             string `yaml:"loadBalancerSku" json:"loadBalancerSku,omitempty"`
+}
+
+func parseAzureCloudConfig(b []byte) (*azureCloudConfig, error) {
+	c := &azureCloudConfig{}
+	// The azure config file is JSON, which can be read by the YAML parser
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *azureCloudConfig) validate() []error {
+	var errs []error
+	required := [][2]string{
+		{"tenantId", c.TenantID},
+		{"subscriptionId", c.SubscriptionID},
+		{"resourceGroup", c.ResourceGroup},
+		{"location", c.Location},
+	}
+	if !c.UseManagedIdentityExtension {
+		required = append(required, [2]string{"aadClientId", c.AADClientID}, [2]string{"aadClientSecret", c.AADClientSecret})
+	}
+	for _, r := range required {
+		if r[1] == "" {
+			errs = append(errs, fmt.Errorf("azure cloud config: %s must be set", r[0]))
+		}
+	}
+	if _, ok := azureEnvironments[c.Cloud]; !ok {
+		errs = append(errs, fmt.Errorf("azure cloud config: %q is not a valid cloud", c.Cloud))
+	}
+	return errs
+}
+
+// azureCloudConfigFromPlan returns the cloud config file generated from the
+// azure configuration of the plan
+func azureCloudConfigFromPlan(a *AzureCloudProvider) *azureCloudConfig {
+	return &azureCloudConfig{
+		Cloud:                       a.Cloud,
+		TenantID:                    a.TenantID,
+		SubscriptionID:              a.SubscriptionID,
+		AADClientID:                 a.ClientID,
+		AADClientSecret:             a.ClientSecret,
+		UseManagedIdentityExtension: a.UseManagedIdentity,
+		ResourceGroup:               a.ResourceGroup,
+		Location:                    a.Location,
+		VNetName:                    a.VNetName,
+		VNetResourceGroup:           a.VNetResourceGroup,
+		SubnetName:                  a.SubnetName,
+		SecurityGroupName:           a.SecurityGroupName,
+		RouteTableName:              a.RouteTableName,
+		VMType:                      a.VMType,
+		PrimaryAvailabilitySetName:  a.PrimaryAvailabilitySetName,
+		PrimaryScaleSetName:         a.PrimaryScaleSetName,
+		LoadBalancerSku:             a.LoadBalancerSKU,
+	}
+}
+
+// azureCloudConfigJSON generates the azure.json file used by the Kubernetes
+// components from the azure configuration of the plan
+func azureCloudConfigJSON(a *AzureCloudProvider) ([]byte, error) {
+	b, err := json.MarshalIndent(azureCloudConfigFromPlan(a), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error generating the azure cloud config: %v", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// azureClient makes requests to the Azure Resource Manager API from a node,
+// using the identity of the Kubernetes components
+type azureClient struct {
+	client ssh.Client
+	config *azureCloudConfig
+	env    azureEnvironment
+	token  string
+}
+
+// newAzureClient gets an access token for the service principal or the
+// managed identity that is configured in the cloud config
+func newAzureClient(client ssh.Client, c *azureCloudConfig) (*azureClient, error) {
+	a := &azureClient{client: client, config: c, env: azureEnvironments[c.Cloud]}
+	resource := a.env.resourceManager + "/"
+	var req nodeHTTPRequest
+	if c.UseManagedIdentityExtension {
+		req = nodeHTTPRequest{
+			method:  "GET",
+			url:     azureMetadataTokenURL + url.QueryEscape(resource),
+			headers: map[string]string{"Metadata": "true"},
+		}
+	} else {
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		form.Set("client_id", c.AADClientID)
+		form.Set("client_secret", c.AADClientSecret)
+		form.Set("resource", resource)
+		req = nodeHTTPRequest{
+			method:  "POST",
+			url:     fmt.Sprintf("%s/%s/oauth2/token", a.env.login, url.PathEscape(c.TenantID)),
+			headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:    form.Encode(),
+		}
+	}
+	status, body, err := req.send(client)
+	if err != nil {
+		return nil, err
+	}
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if status != 200 || json.Unmarshal(body, &token) != nil || token.AccessToken == "" {
+		if c.UseManagedIdentityExtension {
+			return nil, fmt.Errorf("error getting a token for the managed identity of the virtual machine (status code %d)", status)
+		}
+		return nil, fmt.Errorf("error getting a token for service principal %q (status code %d)", c.AADClientID, status)
+	}
+	a.token = token.AccessToken
+	return a, nil
+}
+
+// resourceGroupID returns the ID of the resource group of the cluster
+func (a *azureClient) resourceGroupID() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", a.config.SubscriptionID, a.config.ResourceGroup)
+}
+
+// do sends a request to the Resource Manager API. The body is marshaled to
+// JSON, and the response is unmarshaled into out when it is not nil.
+func (a *azureClient) do(method, resourceID, apiVersion string, body interface{}, out interface{}) error {
+	req := nodeHTTPRequest{
+		method:  method,
+		url:     fmt.Sprintf("%s%s?api-version=%s", a.env.resourceManager, resourceID, apiVersion),
+		headers: map[string]string{"Authorization": "Bearer " + a.token},
+	}
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error marshaling request: %v", err)
+		}
+		req.body = string(b)
+		req.headers["Content-Type"] = "application/json"
+	}
+	status, resp, err := req.send(a.client)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		apiErr := struct {
+			Error struct {
+				Code    string
+				Message string
+			}
+		}{}
+		if json.Unmarshal(resp, &apiErr) == nil && apiErr.Error.Code != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, resourceID, apiErr.Error.Code, apiErr.Error.Message)
+		}
+		return fmt.Errorf("%s %s: status code %d", method, resourceID, status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp, out); err != nil {
+		return fmt.Errorf("error parsing response of %s %s: %v", method, resourceID, err)
+	}
+	return nil
+}
+
+// azureRequiredActions returns the actions that the identity of the
+// Kubernetes components must be allowed to perform in the resource group
+func azureRequiredActions(c *azureCloudConfig) []string {
+	actions := []string{
+		"Microsoft.Compute/virtualMachines/read",
+		"Microsoft.Compute/disks/read",
+		"Microsoft.Compute/disks/write",
+		"Microsoft.Network/loadBalancers/read",
+		"Microsoft.Network/loadBalancers/write",
+		"Microsoft.Network/publicIPAddresses/read",
+		"Microsoft.Network/publicIPAddresses/write",
+		"Microsoft.Network/networkSecurityGroups/read",
+		"Microsoft.Network/networkSecurityGroups/write",
+		"Microsoft.Network/networkInterfaces/read",
+		"Microsoft.Network/networkInterfaces/write",
+	}
+	if c.RouteTableName != "" {
+		actions = append(actions, "Microsoft.Network/routeTables/read", "Microsoft.Network/routeTables/write")
+	}
+	if c.VMType == "vmss" {
+		actions = append(actions, "Microsoft.Compute/virtualMachineScaleSets/read", "Microsoft.Compute/virtualMachineScaleSets/write")
+	}
+	return actions
+}
+
+type azurePermission struct {
+	Actions    []string `json:"actions"`
+	NotActions []string `json:"notActions"`
+}
+
+// azureActionMatches returns true if the action matches the pattern of a
+// role definition, which can contain wildcards
+func azureActionMatches(pattern, action string) bool {
+	expr := "(?i)^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+	matched, err := regexp.MatchString(expr, action)
+	return err == nil && matched
+}
+
+func azureActionAllowed(permissions []azurePermission, action string) bool {
+	for _, p := range permissions {
+		allowed := false
+		for _, a := range p.Actions {
+			if azureActionMatches(a, action) {
+				allowed = true
+			}
+		}
+		for _, na := range p.NotActions {
+			if azureActionMatches(na, action) {
+				allowed = false
+			}
+		}
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPermissions verifies that the identity is allowed to perform the
+// actions required by the cloud provider in the resource group
+func (a *azureClient) checkPermissions() []error {
+	resp := struct {
+		Value []azurePermission `json:"value"`
+	}{}
+	if err := a.do("GET", a.resourceGroupID()+"/providers/Microsoft.Authorization/permissions", azureAuthorizationAPIVersion, nil, &resp); err != nil {
+		return []error{fmt.Errorf("error getting the permissions in resource group %q: %v", a.config.ResourceGroup, err)}
+	}
+	var missing []string
+	for _, action := range azureRequiredActions(a.config) {
+		if !azureActionAllowed(resp.Value, action) {
+			missing = append(missing, action)
+		}
+	}
+	if len(missing) > 0 {
+		return []error{fmt.Errorf("the identity of the cloud provider is not allowed to perform %v in resource group %q", missing, a.config.ResourceGroup)}
+	}
+	return nil
+}
+
+func azureClientForPlan(p *Plan, b []byte, sshClient func(p *Plan, host string) (ssh.Client, error)) (*azureClient, error) {
+	c, err := parseAzureCloudConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing azure cloud config: %v", err)
+	}
+	// The requests are made from the first master, which runs the controller
+	// manager, so that the managed identity of the virtual machine is used
+	master := p.Master.Nodes[0].Host
+	client, err := sshClient(p, master)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSH client for %q: %v", master, err)
+	}
+	return newAzureClient(client, c)
+}
+
+func validateAzureCredentials(p *Plan, b []byte, sshClient func(p *Plan, host string) (ssh.Client, error)) []error {
+	a, err := azureClientForPlan(p, b, sshClient)
+	if err != nil {
+		return []error{fmt.Errorf("azure credentials check failed: %v", err)}
+	}
+	return a.checkPermissions()
+}
+
+type azureVirtualMachine struct {
+	ID         string `json:"id"`
+	Properties struct {
+		AvailabilitySet *struct {
+			ID string `json:"id"`
+		} `json:"availabilitySet"`
+		OSProfile struct {
+			ComputerName string `json:"computerName"`
+		} `json:"osProfile"`
+		NetworkProfile struct {
+			NetworkInterfaces []struct {
+				ID         string `json:"id"`
+				Properties struct {
+					Primary bool `json:"primary"`
+				} `json:"properties"`
+			} `json:"networkInterfaces"`
+		} `json:"networkProfile"`
+	} `json:"properties"`
+}
+
+func (a *azureClient) virtualMachine(name string) (*azureVirtualMachine, error) {
+	vm := &azureVirtualMachine{}
+	id := a.resourceGroupID() + "/providers/Microsoft.Compute/virtualMachines/" + name
+	if err := a.do("GET", id, azureComputeAPIVersion, nil, vm); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// validateAzureNodes verifies that the nodes are virtual machines of the
+// resource group, in the primary availability set or scale set. The cloud
+// provider identifies the virtual machine of a node using its hostname.
+func validateAzureNodes(p *Plan, sshClient func(p *Plan, host string) (ssh.Client, error)) []error {
+	b, err := cloudConfig(p)
+	if err != nil {
+		return []error{err}
+	}
+	a, err := azureClientForPlan(p, b, sshClient)
+	if err != nil {
+		return []error{fmt.Errorf("azure check failed: %v", err)}
+	}
+	var errs []error
+	if a.config.VMType == "vmss" {
+		resp := struct {
+			Value []azureVirtualMachine `json:"value"`
+		}{}
+		id := a.resourceGroupID() + "/providers/Microsoft.Compute/virtualMachineScaleSets/" + a.config.PrimaryScaleSetName + "/virtualMachines"
+		if err := a.do("GET", id, azureComputeAPIVersion, nil, &resp); err != nil {
+			return []error{fmt.Errorf("error listing the virtual machines of scale set %q: %v", a.config.PrimaryScaleSetName, err)}
+		}
+		for _, n := range p.GetUniqueNodes() {
+			found := false
+			for _, vm := range resp.Value {
+				if strings.EqualFold(vm.Properties.OSProfile.ComputerName, n.Host) {
+					found = true
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("node %q is not a virtual machine of scale set %q", n.Host, a.config.PrimaryScaleSetName))
+			}
+		}
+		return errs
+	}
+	for _, n := range p.GetUniqueNodes() {
+		vm, err := a.virtualMachine(n.Host)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %q must be the name of a virtual machine in resource group %q: %v", n.Host, a.config.ResourceGroup, err))
+			continue
+		}
+		if a.config.PrimaryAvailabilitySetName == "" {
+			continue
+		}
+		if vm.Properties.AvailabilitySet == nil || !strings.EqualFold(path.Base(vm.Properties.AvailabilitySet.ID), a.config.PrimaryAvailabilitySetName) {
+			errs = append(errs, fmt.Errorf("node %q is not in availability set %q", n.Host, a.config.PrimaryAvailabilitySetName))
+		}
+	}
+	return errs
+}
+
+// RegisterAzureMasters adds the master nodes to the backend pool of the load
+// balancer that fronts the Kubernetes API. Nothing is done when the backend
+// pool is not set in the plan.
+func RegisterAzureMasters(out io.Writer, p *Plan) error {
+	return registerAzureMasters(out, p, (*Plan).GetSSHClient)
+}
+
+func registerAzureMasters(out io.Writer, p *Plan, sshClient func(p *Plan, host string) (ssh.Client, error)) error {
+	if p.Cluster.CloudProvider.Azure == nil || p.Cluster.CloudProvider.Azure.MasterBackendPool == nil {
+		return nil
+	}
+	pool := p.Cluster.CloudProvider.Azure.MasterBackendPool
+	b, err := cloudConfig(p)
+	if err != nil {
+		return err
+	}
+	a, err := azureClientForPlan(p, b, sshClient)
+	if err != nil {
+		return err
+	}
+	lbID := a.resourceGroupID() + "/providers/Microsoft.Network/loadBalancers/" + pool.LoadBalancer
+	lb := struct {
+		Properties struct {
+			BackendAddressPools []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"backendAddressPools"`
+		} `json:"properties"`
+	}{}
+	if err := a.do("GET", lbID, azureNetworkAPIVersion, nil, &lb); err != nil {
+		return fmt.Errorf("error getting load balancer %q: %v", pool.LoadBalancer, err)
+	}
+	var poolID string
+	for _, bp := range lb.Properties.BackendAddressPools {
+		if strings.EqualFold(bp.Name, pool.Name) {
+			poolID = bp.ID
+		}
+	}
+	if poolID == "" {
+		return fmt.Errorf("load balancer %q does not have a backend pool named %q", pool.LoadBalancer, pool.Name)
+	}
+	for _, m := range p.Master.Nodes {
+		util.PrettyPrint(out, "Registering %s with backend pool %s/%s", m.Host, pool.LoadBalancer, pool.Name)
+		if err := a.addToBackendPool(m.Host, poolID); err != nil {
+			util.PrintError(out)
+			return fmt.Errorf("error registering %q with the backend pool: %v", m.Host, err)
+		}
+		util.PrintOkln(out)
+	}
+	return nil
+}
+
+// addToBackendPool adds the primary IP configuration of the primary network
+// interface of the virtual machine to the backend pool
+func (a *azureClient) addToBackendPool(vmName, poolID string) error {
+	vm, err := a.virtualMachine(vmName)
+	if err != nil {
+		return err
+	}
+	nics := vm.Properties.NetworkProfile.NetworkInterfaces
+	if len(nics) == 0 {
+		return fmt.Errorf("virtual machine %q does not have a network interface", vmName)
+	}
+	nicID := nics[0].ID
+	for _, n := range nics {
+		if n.Properties.Primary {
+			nicID = n.ID
+		}
+	}
+	// The network interface is updated as a generic document, so that the
+	// fields that are not known by KET are preserved
+	nic := map[string]interface{}{}
+	if err := a.do("GET", nicID, azureNetworkAPIVersion, nil, &nic); err != nil {
+		return err
+	}
+	props, _ := nic["properties"].(map[string]interface{})
+	ipConfigs, _ := props["ipConfigurations"].([]interface{})
+	if len(ipConfigs) == 0 {
+		return fmt.Errorf("network interface %q does not have an IP configuration", nicID)
+	}
+	ipConfig, _ := ipConfigs[0].(map[string]interface{})
+	for _, c := range ipConfigs {
+		cfg, _ := c.(map[string]interface{})
+		cfgProps, _ := cfg["properties"].(map[string]interface{})
+		if primary, _ := cfgProps["primary"].(bool); primary {
+			ipConfig = cfg
+		}
+	}
+	ipProps, _ := ipConfig["properties"].(map[string]interface{})
+	if ipProps == nil {
+		return fmt.Errorf("network interface %q has an invalid IP configuration", nicID)
+	}
+	pools, _ := ipProps["loadBalancerBackendAddressPools"].([]interface{})
+	for _, bp := range pools {
+		if existing, _ := bp.(map[string]interface{}); existing != nil && strings.EqualFold(fmt.Sprint(existing["id"]), poolID) {
+			// already registered
+			return nil
+		}
+	}
+	ipProps["loadBalancerBackendAddressPools"] = append(pools, map[string]interface{}{"id": poolID})
+	return a.do("PUT", nicID, azureNetworkAPIVersion, nic, nil)
+}
@@ -0,0 +1,282 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// fakeAzure simulates the Azure API, as seen from a node with curl
+type fakeAzure struct {
+	actions         []string
+	availabilitySet string
+	scaleSetVMs     []string
+	backendPools    []string
+	requests        []nodeHTTPRequest
+	updatedNIC      map[string]interface{}
+}
+
+// parseCurlConfig returns the request of a curl command run by nodeHTTPRequest
+func parseCurlConfig(cmd string) (nodeHTTPRequest, error) {
+	r := nodeHTTPRequest{headers: map[string]string{}}
+	lines := strings.Split(cmd, "\n")
+	if !strings.HasPrefix(lines[0], "curl -K -") {
+		return r, errors.New("unexpected command: " + cmd)
+	}
+	for _, l := range lines[1:] {
+		parts := strings.SplitN(l, " = ", 2)
+		if len(parts) != 2 {
+			if l == "insecure" {
+				r.insecure = true
+			}
+			continue
+		}
+		v := parts[1]
+		if strings.HasPrefix(v, `"`) {
+			var err error
+			if v, err = strconv.Unquote(v); err != nil {
+				return r, err
+			}
+		}
+		switch parts[0] {
+		case "url":
+			r.url = v
+		case "request":
+			r.method = v
+		case "data-binary":
+			r.body = v
+		case "header":
+			h := strings.SplitN(v, ": ", 2)
+			r.headers[h[0]] = h[1]
+		}
+	}
+	return r, nil
+}
+
+func (a *fakeAzure) respond(status int, body interface{}) (string, error) {
+	b, _ := json.Marshal(body)
+	return fmt.Sprintf("%s\n%d", b, status), nil
+}
+
+func (a *fakeAzure) Output(pty bool, args ...string) (string, error) {
+	r, err := parseCurlConfig(strings.Join(args, " "))
+	if err != nil {
+		return "", err
+	}
+	a.requests = append(a.requests, r)
+	if strings.Contains(r.url, "/oauth2/token") {
+		return a.respond(200, map[string]string{"access_token": "token"})
+	}
+	if r.headers["Authorization"] != "Bearer token" {
+		return a.respond(401, nil)
+	}
+	path := strings.SplitN(strings.TrimPrefix(r.url, "https://management.azure.com"), "?", 2)[0]
+	rg := "/subscriptions/s/resourceGroups/rg"
+	nicID := rg + "/providers/Microsoft.Network/networkInterfaces/nic"
+	poolID := rg + "/providers/Microsoft.Network/loadBalancers/lb/backendAddressPools/masters"
+	switch {
+	case path == rg+"/providers/Microsoft.Authorization/permissions":
+		return a.respond(200, map[string]interface{}{
+			"value": []map[string]interface{}{{"actions": a.actions, "notActions": []string{"Microsoft.Network/routeTables/*"}}},
+		})
+	case path == rg+"/providers/Microsoft.Compute/virtualMachineScaleSets/ss/virtualMachines":
+		var vms []interface{}
+		for _, n := range a.scaleSetVMs {
+			vms = append(vms, map[string]interface{}{"properties": map[string]interface{}{"osProfile": map[string]string{"computerName": n}}})
+		}
+		return a.respond(200, map[string]interface{}{"value": vms})
+	case strings.HasPrefix(path, rg+"/providers/Microsoft.Compute/virtualMachines/"):
+		props := map[string]interface{}{
+			"networkProfile": map[string]interface{}{
+				"networkInterfaces": []interface{}{map[string]interface{}{"id": nicID, "properties": map[string]bool{"primary": true}}},
+			},
+		}
+		if a.availabilitySet != "" {
+			props["availabilitySet"] = map[string]string{"id": rg + "/providers/Microsoft.Compute/availabilitySets/" + a.availabilitySet}
+		}
+		return a.respond(200, map[string]interface{}{"properties": props})
+	case path == rg+"/providers/Microsoft.Network/loadBalancers/lb":
+		return a.respond(200, map[string]interface{}{
+			"properties": map[string]interface{}{"backendAddressPools": []interface{}{map[string]string{"id": poolID, "name": "masters"}}},
+		})
+	case path == nicID && r.method == "GET":
+		var pools []interface{}
+		for _, p := range a.backendPools {
+			pools = append(pools, map[string]string{"id": p})
+		}
+		return a.respond(200, map[string]interface{}{
+			"name": "nic",
+			"properties": map[string]interface{}{
+				"ipConfigurations": []interface{}{map[string]interface{}{
+					"name":       "ipconfig1",
+					"properties": map[string]interface{}{"primary": true, "loadBalancerBackendAddressPools": pools},
+				}},
+			},
+		})
+	case path == nicID && r.method == "PUT":
+		a.updatedNIC = map[string]interface{}{}
+		if err := json.Unmarshal([]byte(r.body), &a.updatedNIC); err != nil {
+			return a.respond(400, nil)
+		}
+		return a.respond(200, a.updatedNIC)
+	}
+	return a.respond(404, map[string]interface{}{"error": map[string]string{"code": "NotFound", "message": path}})
+}
+
+func (a *fakeAzure) Shell(pty bool, args ...string) error { return nil }
+
+func azureTestPlan() *Plan {
+	p := loadBalancerTestPlan("lb.example.com")
+	p.Cluster.CloudProvider.Provider = "azure"
+	p.Cluster.CloudProvider.Azure = &AzureCloudProvider{
+		TenantID:       "t",
+		SubscriptionID: "s",
+		ClientID:       "id",
+		ClientSecret:   "secret",
+		ResourceGroup:  "rg",
+		Location:       "eastus",
+		VMType:         "standard",
+	}
+	return p
+}
+
+func TestAzureCloudConfigJSON(t *testing.T) {
+	p := azureTestPlan()
+	b, err := azureCloudConfigJSON(p.Cluster.CloudProvider.Azure)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if errs := validateCloudConfig("azure", b); len(errs) != 0 {
+		t.Errorf("expected the generated config to be valid, got %v", errs)
+	}
+	c, err := parseAzureCloudConfig(b)
+	if err != nil {
+		t.Fatalf("unexpected error parsing the generated config: %v", err)
+	}
+	if c.AADClientSecret != "secret" || c.ResourceGroup != "rg" || c.VMType != "standard" {
+		t.Errorf("unexpected generated config: %s", b)
+	}
+}
+
+func TestValidateCloudProviderCredentialsAzure(t *testing.T) {
+	tests := []struct {
+		managedIdentity bool
+		routeTable      string
+		actions         []string
+		valid           bool
+	}{
+		{actions: []string{"*"}, valid: true},
+		{managedIdentity: true, actions: []string{"Microsoft.Compute/*", "Microsoft.Network/*"}, valid: true},
+		{actions: []string{"Microsoft.Compute/*/read", "Microsoft.Network/*"}, valid: false},
+		// route tables are excluded by the notActions
+		{routeTable: "routes", actions: []string{"*"}, valid: false},
+	}
+	for i, test := range tests {
+		p := azureTestPlan()
+		p.Cluster.CloudProvider.Azure.UseManagedIdentity = test.managedIdentity
+		p.Cluster.CloudProvider.Azure.RouteTableName = test.routeTable
+		azure := &fakeAzure{actions: test.actions}
+		var hosts []string
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			hosts = append(hosts, host)
+			return azure, nil
+		}
+		ok, errs := validateCloudProviderCredentials(p, sshClient)
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+		if len(hosts) != 1 || hosts[0] != "master1" {
+			t.Errorf("test %d: expected the check to run on the first master, got %v", i, hosts)
+		}
+		if len(azure.requests) == 0 {
+			t.Fatalf("test %d: expected requests to the azure API", i)
+		}
+		token := azure.requests[0]
+		if test.managedIdentity && (token.method != "GET" || token.headers["Metadata"] != "true" || !strings.HasPrefix(token.url, azureMetadataTokenURL)) {
+			t.Errorf("test %d: expected a managed identity token request, got %+v", i, token)
+		}
+		if !test.managedIdentity && (token.url != "https://login.microsoftonline.com/t/oauth2/token" || !strings.Contains(token.body, "client_secret=secret")) {
+			t.Errorf("test %d: expected a service principal token request, got %+v", i, token)
+		}
+	}
+}
+
+func TestValidateCloudProviderNodesAzure(t *testing.T) {
+	tests := []struct {
+		vmType          string
+		availabilitySet string
+		scaleSetVMs     []string
+		errors          int
+	}{
+		{vmType: "standard", availabilitySet: "AS", errors: 0},
+		{vmType: "standard", availabilitySet: "other", errors: 4},
+		{vmType: "vmss", scaleSetVMs: []string{"etcd1", "master1", "master2", "worker1"}, errors: 0},
+		{vmType: "vmss", scaleSetVMs: []string{"etcd1", "master1"}, errors: 2},
+	}
+	for i, test := range tests {
+		p := azureTestPlan()
+		p.Cluster.CloudProvider.Azure.VMType = test.vmType
+		p.Cluster.CloudProvider.Azure.PrimaryAvailabilitySetName = "as"
+		p.Cluster.CloudProvider.Azure.PrimaryScaleSetName = "ss"
+		azure := &fakeAzure{availabilitySet: test.availabilitySet, scaleSetVMs: test.scaleSetVMs}
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			return azure, nil
+		}
+		ok, errs := validateCloudProviderNodes(p, sshClient)
+		if len(errs) != test.errors || ok != (test.errors == 0) {
+			t.Errorf("test %d: expected %d errors, got %v", i, test.errors, errs)
+		}
+	}
+}
+
+func TestRegisterAzureMasters(t *testing.T) {
+	poolID := "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb/backendAddressPools/masters"
+	tests := []struct {
+		backendPools []string
+		updated      bool
+	}{
+		{updated: true},
+		{backendPools: []string{"/other/pool"}, updated: true},
+		{backendPools: []string{poolID}, updated: false},
+	}
+	for i, test := range tests {
+		p := azureTestPlan()
+		p.Cluster.CloudProvider.Azure.MasterBackendPool = &AzureBackendPool{LoadBalancer: "lb", Name: "masters"}
+		azure := &fakeAzure{backendPools: test.backendPools}
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			return azure, nil
+		}
+		if err := registerAzureMasters(ioutil.Discard, p, sshClient); err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if (azure.updatedNIC != nil) != test.updated {
+			t.Fatalf("test %d: expected update %v, got %v", i, test.updated, azure.updatedNIC)
+		}
+		if !test.updated {
+			continue
+		}
+		// the fields of the network interface are preserved
+		b, _ := json.Marshal(azure.updatedNIC)
+		if !strings.Contains(string(b), `"name":"ipconfig1"`) || !strings.Contains(string(b), poolID) || !strings.Contains(string(b), `"primary":true`) {
+			t.Errorf("test %d: unexpected network interface update: %s", i, b)
+		}
+		if len(test.backendPools) == 1 && !strings.Contains(string(b), test.backendPools[0]) {
+			t.Errorf("test %d: expected the existing backend pools to be kept: %s", i, b)
+		}
+	}
+
+	p := azureTestPlan()
+	p.Cluster.CloudProvider.Azure.MasterBackendPool = &AzureBackendPool{LoadBalancer: "lb", Name: "workers"}
+	sshClient := func(p *Plan, host string) (ssh.Client, error) {
+		return &fakeAzure{}, nil
+	}
+	if err := registerAzureMasters(ioutil.Discard, p, sshClient); err == nil {
+		t.Errorf("expected an error with a backend pool that does not exist")
+	}
+}
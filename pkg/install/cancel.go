@@ -0,0 +1,140 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ContextExecutor runs the operations of the Executor until the context is
+// done. When the context is done, the running playbook is stopped, the run is
+// recorded as aborted in the runs directory, and an AbortedError is returned.
+type ContextExecutor interface {
+	InstallContext(ctx context.Context, plan *Plan, restartServices bool, nodes ...string) (RunSummary, error)
+	PreparePackagesContext(ctx context.Context, plan *Plan, nodes ...string) error
+	ResetContext(ctx context.Context, plan *Plan, opts ResetOptions, nodes ...string) (RunSummary, error)
+	CleanNodesContext(ctx context.Context, plan *Plan, opts NodeCleanOptions, nodes ...string) error
+	RunSmokeTestContext(ctx context.Context, plan *Plan) (RunSummary, error)
+	AddNodeContext(ctx context.Context, plan *Plan, node Node, roles []string, restartServices bool) (*Plan, RunSummary, error)
+	RemoveNodeContext(ctx context.Context, plan *Plan, host string, drain bool) (*Plan, RunSummary, error)
+	DrainContext(ctx context.Context, plan *Plan, host string, opts DrainOptions) error
+	RunPlayContext(ctx context.Context, name string, plan *Plan, restartServices bool, nodes ...string) (RunSummary, error)
+	UpgradeNodesContext(ctx context.Context, plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) (RunSummary, error)
+	UpgradeClusterServicesContext(ctx context.Context, plan Plan) (RunSummary, error)
+}
+
+// AbortedError is returned when a task is aborted because the context of the
+// operation is done
+type AbortedError struct {
+	// Task is the name of the task that was aborted
+	Task string
+	// Reason is the error of the context
+	Reason error
+}
+
+func (e AbortedError) Error() string {
+	return fmt.Sprintf("task %q was aborted: %v", e.Task, e.Reason)
+}
+
+// IsAborted returns true if the error is an AbortedError
+func IsAborted(err error) bool {
+	_, ok := err.(AbortedError)
+	return ok
+}
+
+// cancelWatcher stops the ansible runner when the context is done
+type cancelWatcher struct {
+	mu      sync.Mutex
+	done    chan struct{}
+	aborted bool
+}
+
+func watchCancel(ctx context.Context, stop func() error) *cancelWatcher {
+	w := &cancelWatcher{done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			select {
+			case <-w.done:
+				// the playbook already finished
+				return
+			default:
+			}
+			w.aborted = true
+			stop()
+		case <-w.done:
+		}
+	}()
+	return w
+}
+
+// finish stops watching the context, and returns true if the runner was
+// stopped because the context was done
+func (w *cancelWatcher) finish() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	close(w.done)
+	return w.aborted
+}
+
+// context returns the context of the operations of the executor
+func (ae *ansibleExecutor) context() context.Context {
+	if ae.options.Context != nil {
+		return ae.options.Context
+	}
+	return context.Background()
+}
+
+// withContext returns a copy of the executor whose operations run until the
+// context is done
+func (ae *ansibleExecutor) withContext(ctx context.Context) *ansibleExecutor {
+	c := *ae
+	c.options.Context = ctx
+	return &c
+}
+
+func (ae *ansibleExecutor) InstallContext(ctx context.Context, p *Plan, restartServices bool, nodes ...string) (RunSummary, error) {
+	return ae.withContext(ctx).Install(p, restartServices, nodes...)
+}
+
+func (ae *ansibleExecutor) PreparePackagesContext(ctx context.Context, p *Plan, nodes ...string) error {
+	return ae.withContext(ctx).PreparePackages(p, nodes...)
+}
+
+func (ae *ansibleExecutor) ResetContext(ctx context.Context, p *Plan, opts ResetOptions, nodes ...string) (RunSummary, error) {
+	return ae.withContext(ctx).Reset(p, opts, nodes...)
+}
+
+func (ae *ansibleExecutor) CleanNodesContext(ctx context.Context, p *Plan, opts NodeCleanOptions, nodes ...string) error {
+	return ae.withContext(ctx).CleanNodes(p, opts, nodes...)
+}
+
+func (ae *ansibleExecutor) RunSmokeTestContext(ctx context.Context, p *Plan) (RunSummary, error) {
+	return ae.withContext(ctx).RunSmokeTest(p)
+}
+
+func (ae *ansibleExecutor) AddNodeContext(ctx context.Context, p *Plan, node Node, roles []string, restartServices bool) (*Plan, RunSummary, error) {
+	return ae.withContext(ctx).AddNode(p, node, roles, restartServices)
+}
+
+func (ae *ansibleExecutor) RemoveNodeContext(ctx context.Context, p *Plan, host string, drain bool) (*Plan, RunSummary, error) {
+	return ae.withContext(ctx).RemoveNode(p, host, drain)
+}
+
+func (ae *ansibleExecutor) DrainContext(ctx context.Context, p *Plan, host string, opts DrainOptions) error {
+	return ae.withContext(ctx).Drain(p, host, opts)
+}
+
+func (ae *ansibleExecutor) RunPlayContext(ctx context.Context, name string, p *Plan, restartServices bool, nodes ...string) (RunSummary, error) {
+	return ae.withContext(ctx).RunPlay(name, p, restartServices, nodes...)
+}
+
+func (ae *ansibleExecutor) UpgradeNodesContext(ctx context.Context, p Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) (RunSummary, error) {
+	return ae.withContext(ctx).UpgradeNodes(p, nodesToUpgrade, onlineUpgrade, maxParallelWorkers, restartServices)
+}
+
+func (ae *ansibleExecutor) UpgradeClusterServicesContext(ctx context.Context, p Plan) (RunSummary, error) {
+	return ae.withContext(ctx).UpgradeClusterServices(p)
+}
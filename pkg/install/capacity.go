@@ -0,0 +1,313 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+)
+
+// PodResources are the resources requested by a pod
+type PodResources struct {
+	// MilliCPU is the CPU requested by the pod, in thousandths of a core
+	MilliCPU int64 `json:"milliCPU"`
+	// MemoryBytes is the memory requested by the pod
+	MemoryBytes int64 `json:"memoryBytes"`
+}
+
+// NodeCapacity is the capacity of a node of the cluster, and the resources
+// requested by the pods that run on it
+type NodeCapacity struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+	// Allocatable are the resources of the node that can be requested by pods
+	Allocatable PodResources `json:"allocatable"`
+	// AllocatablePods is the maximum number of pods of the node
+	AllocatablePods int64 `json:"allocatablePods"`
+	// Requests are the sum of the requests of the pods of the node
+	Requests PodResources `json:"requests"`
+	// Limits are the sum of the limits of the pods of the node
+	Limits PodResources `json:"limits"`
+	Pods   int64        `json:"pods"`
+	// Headroom are the allocatable resources that are not requested
+	Headroom PodResources `json:"headroom"`
+	// FittingPods is the number of typical pods that fit in the headroom
+	FittingPods int64 `json:"fittingPods"`
+	Schedulable bool  `json:"schedulable"`
+}
+
+// RoleCapacity is the capacity of the nodes that have a role
+type RoleCapacity struct {
+	Role        string       `json:"role"`
+	Nodes       int          `json:"nodes"`
+	Allocatable PodResources `json:"allocatable"`
+	Requests    PodResources `json:"requests"`
+	Limits      PodResources `json:"limits"`
+	Headroom    PodResources `json:"headroom"`
+	// FittingPods is the number of typical pods that fit in the headroom of
+	// the schedulable nodes
+	FittingPods int64 `json:"fittingPods"`
+}
+
+// CapacityReport is the headroom of the cluster, and a projection of the
+// number of typical pods that can still be scheduled
+type CapacityReport struct {
+	// TypicalPod are the requests of the pods used for the projection
+	TypicalPod PodResources   `json:"typicalPod"`
+	Nodes      []NodeCapacity `json:"nodes"`
+	// Roles are sorted by name. A node that has more than one role is
+	// counted in each of its roles.
+	Roles []RoleCapacity `json:"roles"`
+}
+
+// CapacityScanner reads the allocatable resources of the nodes and the
+// requests and limits of the pods of a cluster, using kubectl
+type CapacityScanner struct {
+	// BinaryPath is the path to the kubectl binary
+	BinaryPath string
+	// Kubeconfig is the path to the kubeconfig file used to access the cluster
+	Kubeconfig string
+
+	// Hook for testing purposes, runs kubectl with the arguments and returns
+	// its output
+	exec func(args ...string) (string, error)
+}
+
+type capacityResourceList map[string]string
+
+type capacityNodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			Unschedulable bool `json:"unschedulable"`
+		} `json:"spec"`
+		Status struct {
+			Allocatable capacityResourceList `json:"allocatable"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type capacityPodList struct {
+	Items []struct {
+		Spec struct {
+			NodeName   string `json:"nodeName"`
+			Containers []struct {
+				Resources struct {
+					Requests capacityResourceList `json:"requests"`
+					Limits   capacityResourceList `json:"limits"`
+				} `json:"resources"`
+			} `json:"containers"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func (s CapacityScanner) run(args ...string) (string, error) {
+	args = append(args, "--kubeconfig", s.Kubeconfig)
+	logging.Debug("running kubectl", "args", args)
+	if s.exec != nil {
+		return s.exec(args...)
+	}
+	out, err := exec.Command(s.BinaryPath, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// Scan the cluster for the headroom of its nodes, and project the number of
+// typical pods that fit in the headroom. The roles of the nodes are read from
+// the plan. The nodes that are not in the plan have the "unknown" role.
+func (s CapacityScanner) Scan(plan Plan, typicalPod PodResources) (*CapacityReport, error) {
+	if typicalPod.MilliCPU <= 0 || typicalPod.MemoryBytes <= 0 {
+		return nil, fmt.Errorf("the CPU and the memory of the typical pod must be greater than 0")
+	}
+	out, err := s.run("get", "nodes", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("error listing nodes: %v", err)
+	}
+	var nodes capacityNodeList
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		return nil, fmt.Errorf("error unmarshaling nodes: %v", err)
+	}
+	out, err = s.run("get", "pods", "--all-namespaces", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %v", err)
+	}
+	var pods capacityPodList
+	if err := json.Unmarshal([]byte(out), &pods); err != nil {
+		return nil, fmt.Errorf("error unmarshaling pods: %v", err)
+	}
+
+	planRoles := map[string][]string{}
+	for _, n := range plan.getAllNodes() {
+		planRoles[n.Host] = plan.GetRolesForIP(n.IP)
+	}
+	report := &CapacityReport{TypicalPod: typicalPod}
+	byName := map[string]int{}
+	for _, item := range nodes.Items {
+		n := NodeCapacity{
+			Name:        item.Metadata.Name,
+			Roles:       planRoles[item.Metadata.Name],
+			Schedulable: !item.Spec.Unschedulable,
+		}
+		if len(n.Roles) == 0 {
+			n.Roles = []string{"unknown"}
+		}
+		if n.Allocatable, err = item.Status.Allocatable.resources(); err != nil {
+			return nil, fmt.Errorf("invalid allocatable resources of node %q: %v", n.Name, err)
+		}
+		if pods, ok := item.Status.Allocatable["pods"]; ok {
+			if n.AllocatablePods, err = strconv.ParseInt(pods, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid allocatable pods %q of node %q", pods, n.Name)
+			}
+		}
+		byName[n.Name] = len(report.Nodes)
+		report.Nodes = append(report.Nodes, n)
+	}
+	for _, pod := range pods.Items {
+		// the pods that completed do not hold their resources
+		if pod.Status.Phase == "Succeeded" || pod.Status.Phase == "Failed" {
+			continue
+		}
+		i, ok := byName[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+		n := &report.Nodes[i]
+		n.Pods++
+		for _, c := range pod.Spec.Containers {
+			requests, err := c.Resources.Requests.resources()
+			if err != nil {
+				return nil, fmt.Errorf("invalid requests of a pod on node %q: %v", n.Name, err)
+			}
+			limits, err := c.Resources.Limits.resources()
+			if err != nil {
+				return nil, fmt.Errorf("invalid limits of a pod on node %q: %v", n.Name, err)
+			}
+			n.Requests.add(requests)
+			n.Limits.add(limits)
+		}
+	}
+
+	roles := map[string]*RoleCapacity{}
+	for i := range report.Nodes {
+		n := &report.Nodes[i]
+		n.Headroom = PodResources{
+			MilliCPU:    max64(n.Allocatable.MilliCPU-n.Requests.MilliCPU, 0),
+			MemoryBytes: max64(n.Allocatable.MemoryBytes-n.Requests.MemoryBytes, 0),
+		}
+		if n.Schedulable {
+			n.FittingPods = min64(n.Headroom.MilliCPU/typicalPod.MilliCPU, n.Headroom.MemoryBytes/typicalPod.MemoryBytes)
+			if n.AllocatablePods > 0 {
+				n.FittingPods = min64(n.FittingPods, max64(n.AllocatablePods-n.Pods, 0))
+			}
+		}
+		for _, role := range n.Roles {
+			r, ok := roles[role]
+			if !ok {
+				r = &RoleCapacity{Role: role}
+				roles[role] = r
+			}
+			r.Nodes++
+			r.Allocatable.add(n.Allocatable)
+			r.Requests.add(n.Requests)
+			r.Limits.add(n.Limits)
+			r.Headroom.add(n.Headroom)
+			r.FittingPods += n.FittingPods
+		}
+	}
+	for _, r := range roles {
+		report.Roles = append(report.Roles, *r)
+	}
+	sort.Slice(report.Roles, func(i, j int) bool { return report.Roles[i].Role < report.Roles[j].Role })
+	return report, nil
+}
+
+func (p *PodResources) add(r PodResources) {
+	p.MilliCPU += r.MilliCPU
+	p.MemoryBytes += r.MemoryBytes
+}
+
+func (l capacityResourceList) resources() (PodResources, error) {
+	var r PodResources
+	var err error
+	if cpu, ok := l["cpu"]; ok {
+		if r.MilliCPU, err = ParseMilliCPU(cpu); err != nil {
+			return r, err
+		}
+	}
+	if memory, ok := l["memory"]; ok {
+		if r.MemoryBytes, err = ParseMemoryBytes(memory); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// ParseMilliCPU parses a Kubernetes CPU quantity, such as "2", "0.5" or
+// "250m", into thousandths of a core
+func ParseMilliCPU(q string) (int64, error) {
+	if strings.HasSuffix(q, "m") {
+		v, err := strconv.ParseInt(strings.TrimSuffix(q, "m"), 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid CPU quantity %q", q)
+		}
+		return v, nil
+	}
+	v, err := strconv.ParseFloat(q, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid CPU quantity %q", q)
+	}
+	return int64(math.Ceil(v * 1000)), nil
+}
+
+var memorySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	// the binary suffixes are matched before their decimal prefixes
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+	{"m", 1e-3},
+}
+
+// ParseMemoryBytes parses a Kubernetes memory quantity, such as "128974848",
+// "129e6", "129M" or "123Mi", into bytes
+func ParseMemoryBytes(q string) (int64, error) {
+	number, multiplier := q, 1.0
+	for _, s := range memorySuffixes {
+		if strings.HasSuffix(q, s.suffix) {
+			number, multiplier = strings.TrimSuffix(q, s.suffix), s.multiplier
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid memory quantity %q", q)
+	}
+	return int64(math.Ceil(v * multiplier)), nil
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,98 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCapacityScannerScan(t *testing.T) {
+	outputs := map[string]string{
+		"nodes": `{"items": [
+			{"metadata": {"name": "master1"}, "status": {"allocatable": {"cpu": "2", "memory": "4Gi", "pods": "110"}}},
+			{"metadata": {"name": "worker1"}, "status": {"allocatable": {"cpu": "4", "memory": "8Gi", "pods": "110"}}},
+			{"metadata": {"name": "worker2"}, "spec": {"unschedulable": true}, "status": {"allocatable": {"cpu": "4", "memory": "8Gi", "pods": "110"}}},
+			{"metadata": {"name": "extra"}, "status": {"allocatable": {"cpu": "1", "memory": "1Gi", "pods": "1"}}}
+		]}`,
+		"pods": `{"items": [
+			{"spec": {"nodeName": "master1", "containers": [{"resources": {"requests": {"cpu": "250m", "memory": "512Mi"}}}]}},
+			{"spec": {"nodeName": "worker1", "containers": [
+				{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}, "limits": {"cpu": "2", "memory": "2Gi"}}},
+				{"resources": {"requests": {"cpu": "500m", "memory": "1Gi"}}}
+			]}},
+			{"spec": {"nodeName": "worker1", "containers": [{"resources": {"requests": {"cpu": "2", "memory": "1Gi"}}}]}, "status": {"phase": "Succeeded"}},
+			{"spec": {"nodeName": "extra", "containers": [{}]}}
+		]}`,
+	}
+	s := CapacityScanner{
+		Kubeconfig: "kubeconfig",
+		exec: func(args ...string) (string, error) {
+			if args[len(args)-1] != "kubeconfig" {
+				t.Errorf("expected the kubeconfig to be used, got args %v", args)
+			}
+			return outputs[args[1]], nil
+		},
+	}
+	plan := Plan{
+		Master: MasterNodeGroup{Nodes: []Node{{Host: "master1", IP: "10.0.0.1"}}},
+		Worker: NodeGroup{Nodes: []Node{{Host: "worker1", IP: "10.0.0.2"}, {Host: "worker2", IP: "10.0.0.3"}}},
+	}
+	report, err := s.Scan(plan, PodResources{MilliCPU: 500, MemoryBytes: 1 << 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	nodes := map[string]NodeCapacity{}
+	for _, n := range report.Nodes {
+		nodes[n.Name] = n
+	}
+	worker1 := nodes["worker1"]
+	if worker1.Requests != (PodResources{MilliCPU: 1500, MemoryBytes: 2 << 30}) || worker1.Limits != (PodResources{MilliCPU: 2000, MemoryBytes: 2 << 30}) {
+		t.Errorf("unexpected requests and limits of worker1 %+v", worker1)
+	}
+	// 2500m of CPU and 6Gi of memory are left
+	if worker1.Headroom != (PodResources{MilliCPU: 2500, MemoryBytes: 6 << 30}) || worker1.FittingPods != 5 || worker1.Pods != 1 {
+		t.Errorf("unexpected headroom of worker1 %+v", worker1)
+	}
+	if nodes["worker2"].FittingPods != 0 {
+		t.Errorf("expected no pods to fit on the unschedulable node, got %d", nodes["worker2"].FittingPods)
+	}
+	// limited by the number of pods of the node
+	if extra := nodes["extra"]; extra.FittingPods != 0 || !reflect.DeepEqual(extra.Roles, []string{"unknown"}) {
+		t.Errorf("unexpected capacity of the node that is not in the plan %+v", extra)
+	}
+
+	var roles []string
+	for _, r := range report.Roles {
+		roles = append(roles, r.Role)
+	}
+	if !reflect.DeepEqual(roles, []string{"master", "unknown", "worker"}) {
+		t.Fatalf("unexpected roles %v", roles)
+	}
+	worker := report.Roles[2]
+	if worker.Nodes != 2 || worker.Allocatable != (PodResources{MilliCPU: 8000, MemoryBytes: 16 << 30}) || worker.FittingPods != 5 {
+		t.Errorf("unexpected capacity of the workers %+v", worker)
+	}
+}
+
+func TestParseQuantities(t *testing.T) {
+	cpus := map[string]int64{"2": 2000, "0.5": 500, "250m": 250, "1.0001": 1001}
+	for q, expected := range cpus {
+		if v, err := ParseMilliCPU(q); err != nil || v != expected {
+			t.Errorf("%q: expected %d, got %d (%v)", q, expected, v, err)
+		}
+	}
+	memories := map[string]int64{"128974848": 128974848, "129e6": 129000000, "129M": 129000000, "123Mi": 123 << 20, "1Gi": 1 << 30, "2k": 2000}
+	for q, expected := range memories {
+		if v, err := ParseMemoryBytes(q); err != nil || v != expected {
+			t.Errorf("%q: expected %d, got %d (%v)", q, expected, v, err)
+		}
+	}
+	for _, q := range []string{"", "abc", "-1", "1x"} {
+		if _, err := ParseMilliCPU(q); err == nil {
+			t.Errorf("%q: expected an error for the CPU", q)
+		}
+		if _, err := ParseMemoryBytes(q); err == nil {
+			t.Errorf("%q: expected an error for the memory", q)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// renderCache memoizes the cluster catalog and inventory rendered for a plan,
+// so that all the tasks run by an executor for the same plan use identical
+// variables, and the plan is not rendered again for each task. A nil cache
+// renders the plan every time.
+type renderCache struct {
+	mu          sync.Mutex
+	catalogs    map[string]ansible.ClusterCatalog
+	inventories map[string]ansible.Inventory
+}
+
+// planHash returns a hash that identifies the contents of the plan. It returns
+// an empty string if the plan cannot be hashed, in which case it is not cached.
+func planHash(p *Plan) string {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// catalog returns a copy of the cluster catalog for the plan, rendering it
+// with the given function if it has not been rendered before. The copy is
+// shallow: callers may set the fields of the catalog, but must not modify its
+// maps or slices.
+func (c *renderCache) catalog(p *Plan, render func(*Plan) (*ansible.ClusterCatalog, error)) (*ansible.ClusterCatalog, error) {
+	key := planHash(p)
+	if c == nil || key == "" {
+		return render(p)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cc, ok := c.catalogs[key]; ok {
+		return &cc, nil
+	}
+	cc, err := render(p)
+	if err != nil {
+		return nil, err
+	}
+	if c.catalogs == nil {
+		c.catalogs = map[string]ansible.ClusterCatalog{}
+	}
+	c.catalogs[key] = *cc
+	copy := *cc
+	return &copy, nil
+}
+
+// inventory returns the inventory for the plan, building it if it has not been
+// built before.
+func (c *renderCache) inventory(p *Plan) ansible.Inventory {
+	key := planHash(p)
+	if c == nil || key == "" {
+		return buildInventoryFromPlan(p)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if inv, ok := c.inventories[key]; ok {
+		return inv
+	}
+	inv := buildInventoryFromPlan(p)
+	if c.inventories == nil {
+		c.inventories = map[string]ansible.Inventory{}
+	}
+	c.inventories[key] = inv
+	return inv
+}
@@ -0,0 +1,61 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestRenderCacheCatalog(t *testing.T) {
+	renders := 0
+	render := func(p *Plan) (*ansible.ClusterCatalog, error) {
+		renders++
+		return &ansible.ClusterCatalog{ClusterName: p.Cluster.Name}, nil
+	}
+	c := renderCache{}
+	p := &Plan{Cluster: Cluster{Name: "test"}}
+
+	first, err := c.catalog(p, render)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first.EnableRestart()
+	second, err := c.catalog(p, render)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if renders != 1 {
+		t.Errorf("expected the catalog to be rendered once, but was rendered %d times", renders)
+	}
+	if second.ForceKubeletRestart {
+		t.Errorf("expected changes to a returned catalog not to affect the cached catalog")
+	}
+
+	changed := *p
+	changed.Cluster.Name = "other"
+	cc, err := c.catalog(&changed, render)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if renders != 2 || cc.ClusterName != "other" {
+		t.Errorf("expected the catalog to be rendered again for a different plan")
+	}
+}
+
+func TestRenderCacheInventory(t *testing.T) {
+	c := renderCache{}
+	p := &Plan{
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01", IP: "10.0.0.1"}},
+		},
+	}
+	inv := c.inventory(p)
+	if !reflect.DeepEqual(inv, buildInventoryFromPlan(p)) {
+		t.Errorf("expected the cached inventory to match the plan's inventory")
+	}
+	p.Master.Nodes = append(p.Master.Nodes, Node{Host: "master02", IP: "10.0.0.2"})
+	if reflect.DeepEqual(c.inventory(p), inv) {
+		t.Errorf("expected the inventory to be built again for a different plan")
+	}
+}
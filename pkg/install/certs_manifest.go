@@ -0,0 +1,191 @@
+package install
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+const certsManifestFilename = "certs-manifest.json"
+
+// CertificatesManifest lists the certificates of the certificates directory.
+// It is rewritten every time the PKI writes a certificate, and does not
+// depend on the time at which it is written, so that it can be compared
+// across runs.
+type CertificatesManifest struct {
+	Certificates []CertificateRecord `json:"certificates"`
+}
+
+// CertificateRecord describes a certificate of the certificates directory
+type CertificateRecord struct {
+	// Name of the certificate, which is the name of its file without the
+	// .pem extension
+	Name                  string    `json:"name"`
+	CommonName            string    `json:"commonName"`
+	Organizations         []string  `json:"organizations,omitempty"`
+	SubjectAlternateNames []string  `json:"subjectAlternateNames,omitempty"`
+	Issuer                string    `json:"issuer"`
+	IsCA                  bool      `json:"isCA,omitempty"`
+	SerialNumber          string    `json:"serialNumber"`
+	SHA256Fingerprint     string    `json:"sha256Fingerprint"`
+	NotBefore             time.Time `json:"notBefore"`
+	NotAfter              time.Time `json:"notAfter"`
+}
+
+// ReadCertificatesManifest returns the manifest of the certificates directory
+func ReadCertificatesManifest(certsDir string) (*CertificatesManifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(certsDir, certsManifestFilename))
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificates manifest: %v", err)
+	}
+	var m CertificatesManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("error unmarshaling certificates manifest: %v", err)
+	}
+	return &m, nil
+}
+
+// writeCertsManifest records the certificates of the certificates directory
+// in the manifest, sorted by name
+func (lp *LocalPKI) writeCertsManifest() error {
+	records, err := readCertificateRecords(lp.GeneratedCertsDirectory)
+	if err != nil {
+		return err
+	}
+	m := CertificatesManifest{Certificates: records}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling certificates manifest: %v", err)
+	}
+	file := filepath.Join(lp.GeneratedCertsDirectory, certsManifestFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing certificates manifest to %s: %v", file, err)
+	}
+	return nil
+}
+
+// ExpiringCertificates returns the certificates of the certificates directory
+// that expire within the given duration, or that already expired, sorted by
+// expiration date. An error is returned when the directory does not contain
+// any certificate.
+func ExpiringCertificates(certsDir string, now time.Time, within time.Duration) ([]CertificateRecord, error) {
+	records, err := readCertificateRecords(certsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no certificates were found in %q", certsDir)
+	}
+	expiring := []CertificateRecord{}
+	for _, r := range records {
+		if r.NotAfter.Before(now.Add(within)) {
+			expiring = append(expiring, r)
+		}
+	}
+	sort.SliceStable(expiring, func(i, j int) bool { return expiring[i].NotAfter.Before(expiring[j].NotAfter) })
+	return expiring, nil
+}
+
+// readCertificateRecords returns the certificates of the directory, sorted
+// by name
+func readCertificateRecords(certsDir string) ([]CertificateRecord, error) {
+	files, err := filepath.Glob(filepath.Join(certsDir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing certificates: %v", err)
+	}
+	records := []CertificateRecord{}
+	for _, f := range files {
+		if strings.HasSuffix(f, "-key.pem") {
+			continue
+		}
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate %q: %v", f, err)
+		}
+		cert, err := helpers.ParseCertificatePEM(b)
+		if err != nil {
+			// not every PEM file of the directory is a certificate, such as
+			// the service account public key
+			continue
+		}
+		records = append(records, certificateRecord(strings.TrimSuffix(filepath.Base(f), ".pem"), cert))
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	return records, nil
+}
+
+func certificateRecord(name string, cert *x509.Certificate) CertificateRecord {
+	r := CertificateRecord{
+		Name:          name,
+		CommonName:    cert.Subject.CommonName,
+		Organizations: cert.Subject.Organization,
+		Issuer:        cert.Issuer.CommonName,
+		IsCA:          cert.IsCA,
+		SerialNumber:  fmt.Sprintf("%X", cert.SerialNumber),
+		NotBefore:     cert.NotBefore.UTC(),
+		NotAfter:      cert.NotAfter.UTC(),
+	}
+	r.SubjectAlternateNames = append(r.SubjectAlternateNames, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		r.SubjectAlternateNames = append(r.SubjectAlternateNames, ip.String())
+	}
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	r.SHA256Fingerprint = strings.Join(hex, ":")
+	return r
+}
+
+// staleSubjectAlternateNames returns the IP addresses of the certificate
+// that are not expected by the plan, such as the previous address of a node
+// whose IP address changed. Additional DNS names are allowed, as operators
+// may add their own.
+func staleSubjectAlternateNames(spec certificateSpec, cert *x509.Certificate) []string {
+	var stale []string
+	for _, ip := range cert.IPAddresses {
+		if !containsIP(ip, spec.subjectAlternateNames) {
+			stale = append(stale, ip.String())
+		}
+	}
+	return stale
+}
+
+// explainStaleSubjectAlternateNames adds the IP addresses of the certificate
+// that are no longer in the plan to its validation warnings, as a node whose
+// IP address changed needs a new certificate
+func (lp *LocalPKI) explainStaleSubjectAlternateNames(spec certificateSpec, warns []error) []error {
+	cert, err := tls.ReadCert(spec.filename, lp.GeneratedCertsDirectory)
+	if err != nil {
+		return warns
+	}
+	stale := staleSubjectAlternateNames(spec, cert)
+	if len(stale) == 0 {
+		return warns
+	}
+	explained := make([]error, len(warns))
+	for i, w := range warns {
+		explained[i] = fmt.Errorf("%v\n    the IP addresses %v are not in the plan file. If the IP address of a node changed, remove the certificate and its key to generate a new one", w, stale)
+	}
+	return explained
+}
+
+func containsIP(ip net.IP, sans []string) bool {
+	for _, s := range sans {
+		if other := net.ParseIP(s); other != nil && other.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
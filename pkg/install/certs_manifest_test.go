@@ -0,0 +1,133 @@
+package install
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCertificatesManifestWrittenWithGeneratedCerts(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+
+	m, err := ReadCertificatesManifest(pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("unexpected error reading the manifest: %v", err)
+	}
+	var names []string
+	for _, c := range m.Certificates {
+		names = append(names, c.Name)
+		if strings.HasSuffix(c.Name, "-key") {
+			t.Errorf("expected keys not to be recorded, but found %q", c.Name)
+		}
+		if c.SerialNumber == "" || len(strings.Split(c.SHA256Fingerprint, ":")) != 32 {
+			t.Errorf("expected a serial number and a fingerprint for %q, but got %+v", c.Name, c)
+		}
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("expected the certificates to be sorted by name, but got %v", names)
+	}
+	for _, n := range []string{"ca", "proxy-client-ca", "etcd01-etcd", "master01-apiserver", "admin"} {
+		if !contains(n, names) {
+			t.Errorf("expected certificate %q in the manifest, but got %v", n, names)
+		}
+	}
+	for _, c := range m.Certificates {
+		if c.Name == "ca" && !c.IsCA {
+			t.Errorf("expected the cluster CA to be recorded as a CA")
+		}
+		if c.Name == "etcd01-etcd" && !contains("99.99.99.99", c.SubjectAlternateNames) {
+			t.Errorf("expected the IP of the node in the SANs of its certificate, but got %v", c.SubjectAlternateNames)
+		}
+	}
+}
+
+func TestExpiringCertificates(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+
+	now := time.Now()
+	expiring, err := ExpiringCertificates(pki.GeneratedCertsDirectory, now, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(expiring) != 0 {
+		t.Errorf("expected no certificates to expire within a minute, but got %v", expiring)
+	}
+	m, err := ReadCertificatesManifest(pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("unexpected error reading the manifest: %v", err)
+	}
+	expiring, err = ExpiringCertificates(pki.GeneratedCertsDirectory, now.AddDate(100, 0, 0), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(expiring) != len(m.Certificates) {
+		t.Errorf("expected the %d certificates to be expired in 100 years, but got %d", len(m.Certificates), len(expiring))
+	}
+	for i := 1; i < len(expiring); i++ {
+		if expiring[i].NotAfter.Before(expiring[i-1].NotAfter) {
+			t.Errorf("expected the certificates to be sorted by expiration date")
+		}
+	}
+	if _, err := ExpiringCertificates(mustGetTempDir(t), now, 0); err == nil {
+		t.Error("expected an error when the directory does not contain certificates")
+	}
+}
+
+func TestValidateClusterCertificatesStaleIPAddress(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+
+	p.Etcd.Nodes[0].IP = "99.99.99.100"
+	warn, _ := pki.ValidateClusterCertificates(p)
+	var found bool
+	for _, w := range warn {
+		if strings.Contains(w.Error(), "etcd01-etcd.pem") && strings.Contains(w.Error(), "[99.99.99.99]") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a warning about the stale IP address of etcd01, but got %v", warn)
+	}
+}
@@ -0,0 +1,135 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+// StaleCertificate is an existing certificate whose subject alternative
+// names no longer match the plan, such as after the IP address of a node, the
+// load balanced FQDN or the service CIDR changed
+type StaleCertificate struct {
+	// Name of the certificate, which is the name of its file without the
+	// .pem extension
+	Name        string
+	Description string
+	// MissingSubjectAlternateNames are the names required by the plan that
+	// the certificate does not have
+	MissingSubjectAlternateNames []string
+	// StaleSubjectAlternateNames are the IP addresses of the certificate that
+	// are no longer in the plan
+	StaleSubjectAlternateNames []string
+}
+
+func (s StaleCertificate) String() string {
+	msg := fmt.Sprintf("%s (%s.pem)", s.Description, s.Name)
+	if len(s.MissingSubjectAlternateNames) > 0 {
+		msg = fmt.Sprintf("%s: missing %v", msg, s.MissingSubjectAlternateNames)
+	}
+	if len(s.StaleSubjectAlternateNames) > 0 {
+		msg = fmt.Sprintf("%s: no longer in the plan %v", msg, s.StaleSubjectAlternateNames)
+	}
+	return msg
+}
+
+// StaleCertificates returns the existing certificates that must be
+// regenerated for the plan, because their subject alternative names changed.
+// The plan must be valid.
+func (lp *LocalPKI) StaleCertificates(p *Plan) ([]StaleCertificate, error) {
+	// the certificates are generated along with the CA
+	exists, err := lp.CertificateAuthorityExists()
+	if err != nil || !exists {
+		return nil, err
+	}
+	manifest, err := p.certSpecs(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var stale []StaleCertificate
+	for _, s := range manifest {
+		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		cert, err := tls.ReadCert(s.filename, lp.GeneratedCertsDirectory)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate %q: %v", s.filename, err)
+		}
+		certSANs := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			certSANs = append(certSANs, ip.String())
+		}
+		var missing []string
+		for _, san := range s.subjectAlternateNames {
+			if !contains(san, certSANs) && !contains(san, missing) {
+				missing = append(missing, san)
+			}
+		}
+		sort.Strings(missing)
+		removed := staleSubjectAlternateNames(s, cert)
+		if len(missing) == 0 && len(removed) == 0 {
+			continue
+		}
+		stale = append(stale, StaleCertificate{
+			Name:                         s.filename,
+			Description:                  s.description,
+			MissingSubjectAlternateNames: missing,
+			StaleSubjectAlternateNames:   removed,
+		})
+	}
+	return stale, nil
+}
+
+// BackUpCertificates renames the certificates and their keys with a .bak
+// extension, so that they are generated again for the plan
+func (lp *LocalPKI) BackUpCertificates(certs []StaleCertificate) error {
+	for _, c := range certs {
+		if err := lp.backUpCertificate(c.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RotateProxyClientCA replaces the proxy-client CA, and the proxy-client
+// certificate that it signs. The previous certificates and keys are kept with
+// a .bak extension.
+func (lp *LocalPKI) RotateProxyClientCA(p *Plan, clusterCA *tls.CA) (*tls.CA, error) {
+	exists, err := tls.CertKeyPairExists("proxy-client-ca", lp.GeneratedCertsDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying proxy-client CA certificate/key: %v", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("the proxy-client CA was not found in %q", lp.GeneratedCertsDirectory)
+	}
+	for _, name := range []string{"proxy-client-ca", proxyClientCertFilename} {
+		if err := lp.backUpCertificate(name); err != nil {
+			return nil, err
+		}
+	}
+	ca, err := lp.GenerateProxyClientCA(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := lp.GenerateClusterCertificates(p, clusterCA, ca); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+func (lp *LocalPKI) backUpCertificate(name string) error {
+	for _, f := range []string{name + ".pem", name + "-key.pem"} {
+		file := filepath.Join(lp.GeneratedCertsDirectory, f)
+		if err := os.Rename(file, file+".bak"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error backing up %q: %v", file, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package install
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/tls"
+)
+
+func TestStaleCertificatesRegenerated(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+	stale, err := pki.StaleCertificates(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stale) != 0 {
+		t.Fatalf("expected no stale certificates for the plan they were generated for, but got %v", stale)
+	}
+
+	p.Etcd.Nodes[0].IP = "99.99.99.100"
+	p.Master.LoadBalancedFQDN = "otherFQDN"
+	stale, err = pki.StaleCertificates(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, s := range stale {
+		names = append(names, s.Name)
+	}
+	for _, n := range []string{"etcd01-etcd", "master01-apiserver", "master02-apiserver"} {
+		if !contains(n, names) {
+			t.Errorf("expected %q to be stale, but got %v", n, names)
+		}
+	}
+	for _, s := range stale {
+		if s.Name == "etcd01-etcd" && (!contains("99.99.99.100", s.MissingSubjectAlternateNames) || !contains("99.99.99.99", s.StaleSubjectAlternateNames)) {
+			t.Errorf("expected the new IP address to be missing and the previous one to be stale, but got %+v", s)
+		}
+	}
+
+	if err := pki.BackUpCertificates(stale); err != nil {
+		t.Fatalf("unexpected error backing up certificates: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("failed to regenerate certs: %v", err)
+	}
+	stale, err = pki.StaleCertificates(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("expected no stale certificates after the regeneration, but got %v", stale)
+	}
+}
+
+func TestRotateProxyClientCA(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("error generating CA for test: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("error generating proxy-client CA for test: %v", err)
+	}
+	if err = pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("failed to generate certs: %v", err)
+	}
+	previous, err := tls.ReadCert("proxy-client-ca", pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("error reading proxy-client CA: %v", err)
+	}
+
+	if _, err := pki.RotateProxyClientCA(p, ca); err != nil {
+		t.Fatalf("unexpected error rotating the proxy-client CA: %v", err)
+	}
+	rotated, err := tls.ReadCert("proxy-client-ca", pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("error reading proxy-client CA: %v", err)
+	}
+	if bytes.Equal(rotated.Raw, previous.Raw) {
+		t.Errorf("expected a new proxy-client CA")
+	}
+	client, err := tls.ReadCert(proxyClientCertFilename, pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("error reading proxy-client certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(rotated)
+	if _, err := client.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("expected the proxy-client certificate to be signed by the new CA: %v", err)
+	}
+	for _, f := range []string{"proxy-client-ca.pem.bak", "proxy-client-ca-key.pem.bak", "proxy-client.pem.bak", "proxy-client-key.pem.bak"} {
+		if _, err := os.Stat(filepath.Join(pki.GeneratedCertsDirectory, f)); err != nil {
+			t.Errorf("expected the previous file %q to be kept: %v", f, err)
+		}
+	}
+}
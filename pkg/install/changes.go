@@ -0,0 +1,80 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// HostChanges lists the tasks that reported changes on a host
+type HostChanges struct {
+	Host string `json:"host"`
+	// Tasks that reported changes, in the order they were run
+	Tasks []string `json:"tasks"`
+}
+
+// changesRecorder records the tasks that report changes on each host
+type changesRecorder struct {
+	mu          sync.Mutex
+	currentTask string
+	changes     map[string][]string
+	finished    bool
+}
+
+func newChangesRecorder() *changesRecorder {
+	return &changesRecorder{changes: map[string][]string{}}
+}
+
+func (r *changesRecorder) observe(e ansible.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+	switch event := e.(type) {
+	case *ansible.TaskStartEvent:
+		r.currentTask = event.Name
+	case *ansible.HandlerTaskStartEvent:
+		r.currentTask = event.Name
+	// Item results are followed by a result for the task as a whole, which
+	// reports a change if any of the items did
+	case *ansible.RunnerOKEvent:
+		if event.Result.Changed {
+			r.changes[event.Host] = append(r.changes[event.Host], r.currentTask)
+		}
+	}
+}
+
+// finish stops recording and returns the changes, sorted by host. Events
+// received after finishing are ignored.
+func (r *changesRecorder) finish() []HostChanges {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished = true
+	changes := []HostChanges{}
+	for h, tasks := range r.changes {
+		changes = append(changes, HostChanges{Host: h, Tasks: tasks})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Host < changes[j].Host })
+	return changes
+}
+
+// printChanges prints the number of tasks that reported changes on each host,
+// along with the names of the tasks
+func printChanges(out io.Writer, changes []HostChanges) {
+	util.PrintHeader(out, "Changes", '=')
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No changes were made to the nodes")
+		return
+	}
+	for _, c := range changes {
+		fmt.Fprintf(out, "%s: %d changed task(s)\n", c.Host, len(c.Tasks))
+		for _, t := range c.Tasks {
+			fmt.Fprintf(out, "  - %s\n", t)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package install
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestChangesRecorder(t *testing.T) {
+	events := `{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"install etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd02","result":{"changed":true}}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{"changed":false}}}
+{"eventType":"TASK_START","eventData":{"name":"copy config"}}
+{"eventType":"RUNNER_ITEM_OK","eventData":{"host":"etcd02","result":{"changed":true,"item":"a"}}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd02","result":{"changed":true}}}
+{"eventType":"HANDLER_TASK_START","eventData":{"name":"restart etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd02","result":{"changed":true}}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{"changed":true}}}
+`
+	r := newChangesRecorder()
+	for _, e := range eventsFromJSONLines(events) {
+		r.observe(e)
+	}
+	changes := r.finish()
+	expected := []HostChanges{
+		{Host: "etcd01", Tasks: []string{"restart etcd"}},
+		{Host: "etcd02", Tasks: []string{"install etcd", "copy config", "restart etcd"}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes %v, but got %v", expected, changes)
+	}
+
+	out := &bytes.Buffer{}
+	printChanges(out, changes)
+	if !strings.Contains(out.String(), "etcd02: 3 changed task(s)\n  - install etcd\n") {
+		t.Errorf("unexpected changes summary:\n%s", out.String())
+	}
+	out.Reset()
+	printChanges(out, []HostChanges{})
+	if !strings.Contains(out.String(), "No changes were made") {
+		t.Errorf("unexpected changes summary:\n%s", out.String())
+	}
+}
+
+func TestExecuteRecordsChangesReceivedAfterExit(t *testing.T) {
+	start := &ansible.TaskStartEvent{}
+	start.Name = "install etcd"
+	changed := &ansible.RunnerOKEvent{}
+	changed.Host = "etcd01"
+	changed.Result.Changed = true
+	runner := &lateRunner{events: [][]ansible.Event{{start, changed, &ansible.PlaybookEndEvent{}}}, errs: []error{nil}}
+	e := retryExecutor(t, runner, RetryPolicy{})
+	if _, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	runs, err := filepath.Glob(filepath.Join(e.options.RunsDirectory, "apply", "*"))
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected a run, got %v %v", runs, err)
+	}
+	r, err := ReadRun(runs[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []HostChanges{{Host: "etcd01", Tasks: []string{"install etcd"}}}
+	if !reflect.DeepEqual(r.Changes, expected) {
+		t.Errorf("expected the changes %v, got %v", expected, r.Changes)
+	}
+}
@@ -0,0 +1,172 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+const (
+	checkpointFilename = "checkpoint.json"
+	// ResumeFromLast resumes the most recent installation in the runs
+	// directory
+	ResumeFromLast = "last"
+)
+
+// ResumableExecutor resumes a failed installation from the point where it
+// failed, instead of running the entire playbook again.
+type ResumableExecutor interface {
+	// ResumeInstall runs the installation, skipping the phases that completed
+	// on each node during the installation recorded in the resumeFrom run
+	// directory. The most recent installation is resumed when resumeFrom is
+	// "last". A full installation is run when resumeFrom is empty.
+	ResumeInstall(p *Plan, restartServices bool, resumeFrom string, nodes ...string) (RunSummary, error)
+}
+
+// Checkpoint records the progress of an installation in its run directory
+type Checkpoint struct {
+	// PlanHash is the hash of the plan that was installed
+	PlanHash string `json:"planHash"`
+	// Completed is true when the installation completed successfully
+	Completed bool `json:"completed"`
+	// LastCompletedPlay is the name of the last play that completed before
+	// the installation failed
+	LastCompletedPlay string `json:"lastCompletedPlay,omitempty"`
+	// LastCompletedPhase is the last phase of the installation that completed
+	// before the installation failed
+	LastCompletedPhase string `json:"lastCompletedPhase,omitempty"`
+	// CompletedPhases are the phases that completed, keyed by host
+	CompletedPhases map[string][]string `json:"completedPhases,omitempty"`
+}
+
+// ReadCheckpoint returns the checkpoint of the installation recorded in the
+// run directory
+func ReadCheckpoint(runDirectory string) (*Checkpoint, error) {
+	file := filepath.Join(runDirectory, checkpointFilename)
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("the run directory %q does not contain a checkpoint of an installation", runDirectory)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint from %s: %v", file, err)
+	}
+	c := &Checkpoint{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("error unmarshaling checkpoint from %s: %v", file, err)
+	}
+	return c, nil
+}
+
+func writeCheckpoint(runDirectory string, c Checkpoint) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling checkpoint: %v", err)
+	}
+	file := filepath.Join(runDirectory, checkpointFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing checkpoint to %s: %v", file, err)
+	}
+	return nil
+}
+
+// lastInstallRun returns the most recent run directory of an installation
+// that recorded a checkpoint
+func lastInstallRun(runsDirectory string) (string, error) {
+	runs, err := filepath.Glob(filepath.Join(runsDirectory, "apply", "*", checkpointFilename))
+	if err != nil {
+		return "", fmt.Errorf("error listing the runs of the installation: %v", err)
+	}
+	if len(runs) == 0 {
+		return "", fmt.Errorf("no installation to resume was found in the runs directory %q", runsDirectory)
+	}
+	// The run directories are named after the time the run started
+	dirs := make([]string, len(runs))
+	for i, r := range runs {
+		dirs[i] = filepath.Dir(r)
+	}
+	sort.Strings(dirs)
+	return dirs[len(dirs)-1], nil
+}
+
+// resumeState returns the state of the installation recorded in the run
+// directory, which must have failed installing the same plan
+func (ae *ansibleExecutor) resumeState(p *Plan, resumeFrom string) (*installState, *Checkpoint, error) {
+	runDirectory := resumeFrom
+	if resumeFrom == ResumeFromLast {
+		dir, err := lastInstallRun(ae.options.RunsDirectory)
+		if err != nil {
+			return nil, nil, err
+		}
+		runDirectory = dir
+	}
+	c, err := ReadCheckpoint(runDirectory)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Completed {
+		return nil, nil, fmt.Errorf("the installation recorded in %q completed, there is nothing to resume", runDirectory)
+	}
+	hash := planHash(p)
+	if hash == "" || c.PlanHash != hash {
+		return nil, nil, fmt.Errorf("the plan changed since the installation recorded in %q, it cannot be resumed", runDirectory)
+	}
+	state := &installState{PlanHash: hash}
+	state.record(c.CompletedPhases)
+	return state, c, nil
+}
+
+// checkpointRecorder records the last play and phase that completed before
+// the playbook failed
+type checkpointRecorder struct {
+	mu                 sync.Mutex
+	failed             bool
+	currentPlay        string
+	lastCompletedPlay  string
+	lastCompletedPhase string
+}
+
+func (r *checkpointRecorder) observe(e ansible.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed {
+		return
+	}
+	switch event := e.(type) {
+	case *ansible.PlayStartEvent:
+		if r.currentPlay != "" && r.currentPlay != phaseMarkerPlayName {
+			r.lastCompletedPlay = r.currentPlay
+		}
+		r.currentPlay = event.Name
+	case *ansible.RunnerOKEvent:
+		if strings.HasPrefix(event.Result.Message, phaseMarkerPrefix) {
+			r.lastCompletedPhase = strings.TrimPrefix(event.Result.Message, phaseMarkerPrefix)
+		}
+	case *ansible.RunnerFailedEvent:
+		r.failed = !event.IgnoreErrors
+	case *ansible.RunnerItemFailedEvent:
+		r.failed = !event.IgnoreErrors
+	case *ansible.RunnerUnreachableEvent:
+		r.failed = true
+	}
+}
+
+// checkpoint returns the checkpoint of the installation with the phases that
+// completed on each node
+func (r *checkpointRecorder) checkpoint(state installState, err error) Checkpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Checkpoint{
+		PlanHash:           state.PlanHash,
+		Completed:          err == nil,
+		LastCompletedPlay:  r.lastCompletedPlay,
+		LastCompletedPhase: r.lastCompletedPhase,
+		CompletedPhases:    state.CompletedPhases,
+	}
+}
@@ -0,0 +1,136 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+func TestResumeInstall(t *testing.T) {
+	runsDir := mustGetTempDir(t)
+	plan := &Plan{
+		Cluster: Cluster{
+			Name:    "test",
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01"}},
+		},
+		Worker: NodeGroup{
+			Nodes: []Node{{Host: "worker01"}},
+		},
+	}
+	newExecutor := func(r *phaseRunner) *ansibleExecutor {
+		return &ansibleExecutor{
+			options: ExecutorOptions{
+				RunsDirectory: runsDir,
+				// the install state is not shared between the runs, so
+				// that the phases are only skipped when resuming
+				GeneratedAssetsDirectory: mustGetTempDir(t),
+			},
+			stdout:              &bytes.Buffer{},
+			consoleOutputFormat: ansible.RawFormat,
+			certsDir:            mustGetTempDir(t),
+			runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+				return r, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+			},
+		}
+	}
+
+	// Nothing to resume before the first installation
+	if _, err := newExecutor(&phaseRunner{}).ResumeInstall(plan, false, ResumeFromLast); err == nil {
+		t.Error("expected an error when there is no installation to resume")
+	}
+
+	// The first installation fails after completing some of the phases
+	completed := map[string][]string{"worker01": {"docker"}}
+	r := &phaseRunner{completed: completed, err: errors.New("playbook failed")}
+	if _, err := newExecutor(r).Install(plan, false); err == nil {
+		t.Fatal("expected the installation to fail")
+	}
+	run, err := lastInstallRun(runsDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c, err := ReadCheckpoint(run)
+	if err != nil {
+		t.Fatalf("unexpected error reading the checkpoint: %v", err)
+	}
+	if c.Completed || c.LastCompletedPhase != "docker" || !reflect.DeepEqual(c.CompletedPhases, completed) {
+		t.Errorf("unexpected checkpoint %+v", c)
+	}
+
+	// The installation is resumed from the checkpoint
+	r = &phaseRunner{}
+	if _, err := newExecutor(r).ResumeInstall(plan, false, run); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(r.skipped, completed) {
+		t.Errorf("expected the completed phases %v to be skipped, but got %v", completed, r.skipped)
+	}
+
+	// The last installation completed, there is nothing to resume
+	if _, err := newExecutor(&phaseRunner{}).ResumeInstall(plan, false, ResumeFromLast); err == nil {
+		t.Error("expected an error when resuming a completed installation")
+	}
+}
+
+func TestResumeInstallRequiresSamePlan(t *testing.T) {
+	dir := mustGetTempDir(t)
+	plan := &Plan{Cluster: Cluster{Name: "test"}}
+	if err := writeCheckpoint(dir, Checkpoint{PlanHash: planHash(plan)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := ansibleExecutor{}
+	if _, _, err := e.resumeState(plan, dir); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	plan.Cluster.Name = "changed"
+	if _, _, err := e.resumeState(plan, dir); err == nil {
+		t.Error("expected an error when the plan changed")
+	}
+}
+
+func TestCheckpointRecorder(t *testing.T) {
+	play := func(name string) ansible.Event {
+		e := &ansible.PlayStartEvent{}
+		e.Name = name
+		return e
+	}
+	phase := func(name string) ansible.Event {
+		e := &ansible.RunnerOKEvent{}
+		e.Result.Message = phaseMarkerPrefix + name
+		return e
+	}
+	ignored := &ansible.RunnerFailedEvent{}
+	ignored.IgnoreErrors = true
+	events := []ansible.Event{
+		play("Install Docker"),
+		ignored,
+		play(phaseMarkerPlayName),
+		phase("docker"),
+		play("Start kubelet"),
+		play(phaseMarkerPlayName),
+		phase("kubelet"),
+		play("Start kube-apiserver"),
+		&ansible.RunnerFailedEvent{},
+		play(phaseMarkerPlayName),
+		phase("kube-apiserver"),
+	}
+	r := &checkpointRecorder{}
+	for _, e := range events {
+		r.observe(e)
+	}
+	c := r.checkpoint(installState{}, errors.New("failed"))
+	if c.LastCompletedPlay != "Start kubelet" || c.LastCompletedPhase != "kubelet" || c.Completed {
+		t.Errorf("unexpected checkpoint %+v", c)
+	}
+}
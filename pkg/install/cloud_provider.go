@@ -0,0 +1,474 @@
+package install
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// cloudProvidersRequiringConfig are the cloud providers that cannot be used
+// without a cloud config file
+func cloudProvidersRequiringConfig() []string {
+	return []string{"azure", "cloudstack", "openstack", "vsphere"}
+}
+
+// cloudConfigSection is a section of a gcfg (INI) cloud config file, such as
+// [Global] or [VirtualCenter "10.0.0.1"]. Section and key names are case
+// insensitive, and are stored in lower case.
+type cloudConfigSection struct {
+	name       string
+	subsection string
+	values     map[string]string
+}
+
+type iniCloudConfig []cloudConfigSection
+
+// get returns the value of the key in the first section with the given name
+func (c iniCloudConfig) get(section, key string) string {
+	for _, s := range c {
+		if s.name == section && s.values[key] != "" {
+			return s.values[key]
+		}
+	}
+	return ""
+}
+
+func (c iniCloudConfig) sections(name string) []cloudConfigSection {
+	var sections []cloudConfigSection
+	for _, s := range c {
+		if s.name == name {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// parseINICloudConfig parses the gcfg format used by most cloud providers
+func parseINICloudConfig(b []byte) (iniCloudConfig, error) {
+	config := iniCloudConfig{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section header %q", i, line)
+			}
+			header := strings.TrimSpace(line[1 : len(line)-1])
+			s := cloudConfigSection{values: map[string]string{}}
+			if idx := strings.IndexAny(header, " \t"); idx > 0 {
+				s.subsection = strings.Trim(strings.TrimSpace(header[idx:]), `"`)
+				header = header[:idx]
+			}
+			s.name = strings.ToLower(header)
+			config = append(config, s)
+			continue
+		}
+		if len(config) == 0 {
+			return nil, fmt.Errorf("line %d: %q is not in a section", i, line)
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected a key = value pair, got %q", i, line)
+		}
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		}
+		config[len(config)-1].values[strings.ToLower(strings.TrimSpace(parts[0]))] = value
+	}
+	return config, scanner.Err()
+}
+
+// validateCloudConfig parses the cloud config file, and verifies that the
+// fields required by the provider are set. The config file of the providers
+// that do not require one is passed to the Kubernetes components as is.
+func validateCloudConfig(provider string, b []byte) []error {
+	if !util.Contains(provider, cloudProvidersRequiringConfig()) {
+		return nil
+	}
+	if provider == "azure" {
+		c, err := parseAzureCloudConfig(b)
+		if err != nil {
+			return []error{fmt.Errorf("error parsing azure cloud config: %v", err)}
+		}
+		return c.validate()
+	}
+	c, err := parseINICloudConfig(b)
+	if err != nil {
+		return []error{fmt.Errorf("error parsing %s cloud config: %v", provider, err)}
+	}
+	var errs []error
+	requireGlobal := func(keys ...string) {
+		for _, k := range keys {
+			alternatives := strings.Split(k, "|")
+			found := false
+			for _, a := range alternatives {
+				if c.get("global", a) != "" {
+					found = true
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("%s cloud config: %s must be set in the [Global] section", provider, strings.Join(alternatives, " or ")))
+			}
+		}
+	}
+	switch provider {
+	case "openstack":
+		requireGlobal("auth-url", "username|user-id", "password", "tenant-id|tenant-name")
+		if authURL := c.get("global", "auth-url"); authURL != "" {
+			if u, err := url.Parse(authURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				errs = append(errs, fmt.Errorf("openstack cloud config: auth-url %q must be an http or https URL", authURL))
+			}
+		}
+	case "cloudstack":
+		requireGlobal("api-url", "api-key", "secret-key")
+	case "vsphere":
+		errs = append(errs, validateVSphereCloudConfig(c)...)
+	}
+	return errs
+}
+
+// vsphereServer is a vCenter server defined in the vsphere cloud config
+type vsphereServer struct {
+	server   string
+	port     string
+	user     string
+	password string
+	insecure bool
+}
+
+// vsphereServers returns the vCenter servers defined in the config. Servers
+// can be defined in [VirtualCenter "server"] sections, or in the [Workspace]
+// or [Global] section. Credentials default to the ones in the [Global] section.
+func vsphereServers(c iniCloudConfig) []vsphereServer {
+	global := vsphereServer{
+		port:     c.get("global", "port"),
+		user:     c.get("global", "user"),
+		password: c.get("global", "password"),
+		insecure: c.get("global", "insecure-flag") == "1" || c.get("global", "insecure-flag") == "true",
+	}
+	if global.port == "" {
+		global.port = "443"
+	}
+	var servers []vsphereServer
+	for _, s := range c.sections("virtualcenter") {
+		vc := global
+		vc.server = s.subsection
+		if s.values["port"] != "" {
+			vc.port = s.values["port"]
+		}
+		if s.values["user"] != "" {
+			vc.user = s.values["user"]
+		}
+		if s.values["password"] != "" {
+			vc.password = s.values["password"]
+		}
+		servers = append(servers, vc)
+	}
+	if len(servers) > 0 {
+		return servers
+	}
+	global.server = c.get("workspace", "server")
+	if global.server == "" {
+		global.server = c.get("global", "server")
+	}
+	return []vsphereServer{global}
+}
+
+func validateVSphereCloudConfig(c iniCloudConfig) []error {
+	var errs []error
+	for _, s := range vsphereServers(c) {
+		if s.server == "" {
+			errs = append(errs, fmt.Errorf("vsphere cloud config: the vCenter server must be set in the [Workspace] or [VirtualCenter] section"))
+			continue
+		}
+		if s.user == "" || s.password == "" {
+			errs = append(errs, fmt.Errorf("vsphere cloud config: the user and password of vCenter server %q must be set", s.server))
+		}
+	}
+	if c.get("workspace", "datacenter") == "" && c.get("global", "datacenter") == "" && c.get("virtualcenter", "datacenters") == "" {
+		errs = append(errs, fmt.Errorf("vsphere cloud config: the datacenter must be set in the [Workspace] section"))
+	}
+	if c.get("workspace", "default-datastore") == "" && c.get("global", "datastore") == "" {
+		errs = append(errs, fmt.Errorf("vsphere cloud config: default-datastore must be set in the [Workspace] section"))
+	}
+	return errs
+}
+
+// gcfgQuote returns the value as a quoted gcfg string
+func gcfgQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// vsphereCloudConfig generates the vsphere.conf file used by the Kubernetes
+// components from the vsphere configuration of the plan
+func vsphereCloudConfig(vs *VSphereCloudProvider) []byte {
+	insecure := "0"
+	if vs.Insecure {
+		insecure = "1"
+	}
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "[Global]\n")
+	fmt.Fprintf(b, "user = %s\n", gcfgQuote(vs.Username))
+	fmt.Fprintf(b, "password = %s\n", gcfgQuote(vs.Password))
+	fmt.Fprintf(b, "port = %s\n", gcfgQuote(strconv.Itoa(vs.Port)))
+	fmt.Fprintf(b, "insecure-flag = %s\n", gcfgQuote(insecure))
+	fmt.Fprintf(b, "datacenters = %s\n", gcfgQuote(vs.Datacenter))
+	fmt.Fprintf(b, "\n[VirtualCenter %s]\n", gcfgQuote(vs.Server))
+	fmt.Fprintf(b, "\n[Workspace]\n")
+	fmt.Fprintf(b, "server = %s\n", gcfgQuote(vs.Server))
+	fmt.Fprintf(b, "datacenter = %s\n", gcfgQuote(vs.Datacenter))
+	fmt.Fprintf(b, "default-datastore = %s\n", gcfgQuote(vs.Datastore))
+	fmt.Fprintf(b, "folder = %s\n", gcfgQuote(vs.Folder))
+	fmt.Fprintf(b, "\n[Disk]\n")
+	fmt.Fprintf(b, "scsicontrollertype = pvscsi\n")
+	return b.Bytes()
+}
+
+// cloudConfig returns the contents of the cloud config file used by the
+// Kubernetes components, or nil if there is none
+func cloudConfig(p *Plan) ([]byte, error) {
+	if p.Cluster.CloudProvider.VSphere != nil {
+		return vsphereCloudConfig(p.Cluster.CloudProvider.VSphere), nil
+	}
+	if p.Cluster.CloudProvider.Azure != nil {
+		return azureCloudConfigJSON(p.Cluster.CloudProvider.Azure)
+	}
+	if p.Cluster.CloudProvider.Config == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(p.Cluster.CloudProvider.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cloud config file: %v", err)
+	}
+	return b, nil
+}
+
+// ValidateCloudProviderCredentials performs a lightweight check of the
+// credentials used by the Kubernetes components to access the cloud provider
+// API. The requests are made from the first master node, which runs the
+// controller manager.
+func ValidateCloudProviderCredentials(p *Plan) (bool, []error) {
+	return validateCloudProviderCredentials(p, (*Plan).GetSSHClient)
+}
+
+func validateCloudProviderCredentials(p *Plan, sshClient func(p *Plan, host string) (ssh.Client, error)) (bool, []error) {
+	v := newValidator()
+	provider := p.Cluster.CloudProvider.Provider
+	if provider == "aws" {
+		// Every node accesses the AWS API using the IAM role of the instance
+		for _, n := range p.GetUniqueNodes() {
+			client, err := sshClient(p, n.Host)
+			if err != nil {
+				v.addError(fmt.Errorf("error getting SSH client for %q: %v", n.Host, err))
+				continue
+			}
+			node, err := newAWSNode(client)
+			if err != nil {
+				v.addError(fmt.Errorf("AWS credentials check failed on node %q: %v", n.Host, err))
+				continue
+			}
+			for _, err := range node.checkPermissions(nodeInList(n.Host, p.Master.Nodes)) {
+				v.addError(fmt.Errorf("AWS credentials check failed on node %q: %v", n.Host, err))
+			}
+		}
+		return v.valid()
+	}
+	b, err := cloudConfig(p)
+	if err != nil {
+		v.addError(err)
+		return v.valid()
+	}
+	if b == nil {
+		return v.valid()
+	}
+	if provider == "azure" {
+		v.addError(validateAzureCredentials(p, b, sshClient)...)
+		return v.valid()
+	}
+	var checks []credentialCheck
+	switch provider {
+	case "openstack":
+		c, err := parseINICloudConfig(b)
+		if err != nil {
+			v.addError(err)
+			return v.valid()
+		}
+		checks = append(checks, openstackCredentialCheck(c))
+	case "vsphere":
+		c, err := parseINICloudConfig(b)
+		if err != nil {
+			v.addError(err)
+			return v.valid()
+		}
+		for _, s := range vsphereServers(c) {
+			checks = append(checks, vsphereCredentialCheck(s))
+		}
+	}
+	if len(checks) == 0 {
+		return v.valid()
+	}
+	master := p.Master.Nodes[0].Host
+	client, err := sshClient(p, master)
+	if err != nil {
+		v.addError(fmt.Errorf("error getting SSH client for %q: %v", master, err))
+		return v.valid()
+	}
+	for _, c := range checks {
+		if err := c.run(client); err != nil {
+			v.addError(fmt.Errorf("%s credentials check failed on node %q: %v", provider, master, err))
+		}
+	}
+	return v.valid()
+}
+
+// credentialCheck is an HTTP request that succeeds with the expected status
+// code when the credentials are valid
+type credentialCheck struct {
+	request nodeHTTPRequest
+	// the status code returned when the credentials are valid
+	expectedStatus int
+}
+
+func (c credentialCheck) run(client ssh.Client) error {
+	status, _, err := c.request.send(client)
+	if err != nil {
+		return err
+	}
+	if status != c.expectedStatus {
+		return fmt.Errorf("%s returned status code %d", c.request.url, status)
+	}
+	return nil
+}
+
+func openstackCredentialCheck(c iniCloudConfig) credentialCheck {
+	authURL := strings.TrimSuffix(c.get("global", "auth-url"), "/")
+	user := c.get("global", "username")
+	password := c.get("global", "password")
+	if strings.HasSuffix(authURL, "/v2.0") {
+		auth := map[string]interface{}{
+			"passwordCredentials": map[string]string{"username": user, "password": password},
+		}
+		if tenantID := c.get("global", "tenant-id"); tenantID != "" {
+			auth["tenantId"] = tenantID
+		} else {
+			auth["tenantName"] = c.get("global", "tenant-name")
+		}
+		body, _ := json.Marshal(map[string]interface{}{"auth": auth})
+		return credentialCheck{
+			request:        nodeHTTPRequest{method: "POST", url: authURL + "/tokens", headers: map[string]string{"Content-Type": "application/json"}, body: string(body)},
+			expectedStatus: 200,
+		}
+	}
+	u := map[string]interface{}{"password": password}
+	if userID := c.get("global", "user-id"); userID != "" {
+		u["id"] = userID
+	} else {
+		u["name"] = user
+		domain := map[string]string{"name": "Default"}
+		if d := c.get("global", "domain-id"); d != "" {
+			domain = map[string]string{"id": d}
+		} else if d := c.get("global", "domain-name"); d != "" {
+			domain = map[string]string{"name": d}
+		}
+		u["domain"] = domain
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods":  []string{"password"},
+				"password": map[string]interface{}{"user": u},
+			},
+		},
+	})
+	if !strings.HasSuffix(authURL, "/v3") {
+		authURL = authURL + "/v3"
+	}
+	return credentialCheck{
+		request:        nodeHTTPRequest{method: "POST", url: authURL + "/auth/tokens", headers: map[string]string{"Content-Type": "application/json"}, body: string(body)},
+		expectedStatus: 201,
+	}
+}
+
+func vsphereCredentialCheck(s vsphereServer) credentialCheck {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:urn="urn:vim25">
+<soapenv:Body><urn:Login><urn:_this type="SessionManager">SessionManager</urn:_this><urn:userName>%s</urn:userName><urn:password>%s</urn:password></urn:Login></soapenv:Body>
+</soapenv:Envelope>`, html.EscapeString(s.user), html.EscapeString(s.password))
+	return credentialCheck{
+		request: nodeHTTPRequest{
+			method:   "POST",
+			url:      fmt.Sprintf("https://%s:%s/sdk", s.server, s.port),
+			headers:  map[string]string{"Content-Type": "text/xml", "SOAPAction": "urn:vim25/6.0"},
+			body:     body,
+			insecure: s.insecure,
+		},
+		expectedStatus: 200,
+	}
+}
+
+// ValidateCloudProviderNodes verifies that the nodes are set up as required
+// by the cloud provider. With the aws provider, the instances and subnets of
+// the nodes must be tagged with the ID of the cluster. With the azure
+// provider, the nodes must be virtual machines of the resource group, in the
+// primary availability set or scale set. With the vsphere
+// provider, the nodes must be VMware virtual machines with the disk.EnableUUID
+// option set, so that the kubelet can identify the volumes that are attached
+// to the node.
+func ValidateCloudProviderNodes(p *Plan) (bool, []error) {
+	return validateCloudProviderNodes(p, (*Plan).GetSSHClient)
+}
+
+func validateCloudProviderNodes(p *Plan, sshClient func(p *Plan, host string) (ssh.Client, error)) (bool, []error) {
+	v := newValidator()
+	if p.Cluster.CloudProvider.Provider == "aws" {
+		v.addError(validateAWSClusterTags(p, sshClient)...)
+		return v.valid()
+	}
+	if p.Cluster.CloudProvider.Provider == "azure" {
+		v.addError(validateAzureNodes(p, sshClient)...)
+		return v.valid()
+	}
+	if p.Cluster.CloudProvider.Provider != "vsphere" {
+		return v.valid()
+	}
+	for _, n := range p.GetUniqueNodes() {
+		client, err := sshClient(p, n.Host)
+		if err != nil {
+			v.addError(fmt.Errorf("error getting SSH client for %q: %v", n.Host, err))
+			continue
+		}
+		if err := checkVSphereDiskUUID(client); err != nil {
+			v.addError(fmt.Errorf("vsphere check failed on node %q: %v", n.Host, err))
+		}
+	}
+	return v.valid()
+}
+
+func checkVSphereDiskUUID(client ssh.Client) error {
+	vendor, err := client.Output(true, "cat /sys/class/dmi/id/sys_vendor")
+	if err != nil {
+		return fmt.Errorf("error getting the system vendor: %v", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(vendor), "VMware") {
+		return fmt.Errorf("the node is not a VMware virtual machine")
+	}
+	// The disks only have a unique ID when disk.EnableUUID is set on the VM
+	disks, err := client.Output(true, "ls /dev/disk/by-id/")
+	if err != nil || !strings.Contains(disks, "wwn-0x6000c29") {
+		return fmt.Errorf("disk UUIDs are not enabled. Set disk.EnableUUID to TRUE in the advanced configuration of the virtual machine")
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+func TestParseINICloudConfig(t *testing.T) {
+	config := `
+; comment
+[Global]
+auth-url = "https://keystone.example.com:5000/v3"
+Username = admin
+
+# comment
+[VirtualCenter "10.0.0.1"]
+user = vc-admin
+`
+	c, err := parseINICloudConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.get("global", "auth-url") != "https://keystone.example.com:5000/v3" {
+		t.Errorf("expected quotes to be removed, got %q", c.get("global", "auth-url"))
+	}
+	if c.get("global", "username") != "admin" {
+		t.Errorf("expected keys to be case insensitive")
+	}
+	vcs := c.sections("virtualcenter")
+	if len(vcs) != 1 || vcs[0].subsection != "10.0.0.1" || vcs[0].values["user"] != "vc-admin" {
+		t.Errorf("unexpected virtual center sections: %+v", vcs)
+	}
+
+	for _, invalid := range []string{"key = value", "[Global\nkey = value", "[Global]\nkey"} {
+		if _, err := parseINICloudConfig([]byte(invalid)); err == nil {
+			t.Errorf("expected an error parsing %q", invalid)
+		}
+	}
+}
+
+func TestValidateCloudConfig(t *testing.T) {
+	tests := []struct {
+		provider string
+		config   string
+		errors   int
+	}{
+		{
+			provider: "openstack",
+			config:   "[Global]\nauth-url=https://keystone:5000/v3\nusername=admin\npassword=secret\ntenant-name=kubernetes",
+		},
+		{
+			provider: "openstack",
+			config:   "[Global]\nauth-url=keystone:5000\nuser-id=abc\ntenant-id=def",
+			// password and URL scheme
+			errors: 2,
+		},
+		{
+			provider: "cloudstack",
+			config:   "[Global]\napi-url=https://cloudstack/client/api\napi-key=key",
+			errors:   1,
+		},
+		{
+			provider: "vsphere",
+			config:   "[Global]\nuser=admin\npassword=secret\n[Workspace]\nserver=vcenter\ndatacenter=dc\ndefault-datastore=ds",
+		},
+		{
+			provider: "vsphere",
+			config:   "[Global]\ndatacenter=dc\ndatastore=ds\n[VirtualCenter \"vc1\"]\nuser=admin\npassword=secret\n[VirtualCenter \"vc2\"]\nuser=admin",
+			errors:   1,
+		},
+		{
+			provider: "vsphere",
+			config:   "[Global]\nuser=admin\npassword=secret",
+			// server, datacenter and datastore
+			errors: 3,
+		},
+		{
+			provider: "azure",
+			config:   `{"tenantId": "t", "subscriptionId": "s", "aadClientId": "id", "aadClientSecret": "secret", "resourceGroup": "rg", "location": "eastus"}`,
+		},
+		{
+			provider: "azure",
+			config:   `{"cloud": "AzureChinaCloud", "tenantId": "t", "subscriptionId": "s", "useManagedIdentityExtension": true, "resourceGroup": "rg", "location": "chinaeast"}`,
+		},
+		{
+			provider: "azure",
+			config:   `{"cloud": "Moon", "tenantId": "t", "subscriptionId": "s", "resourceGroup": "rg", "location": "eastus"}`,
+			// client ID, client secret and cloud
+			errors: 3,
+		},
+		{
+			provider: "azure",
+			config:   `{"tenantId": `,
+			errors:   1,
+		},
+		{
+			// not parsed
+			provider: "gce",
+			config:   "not a cloud config",
+		},
+	}
+	for i, test := range tests {
+		errs := validateCloudConfig(test.provider, []byte(test.config))
+		if len(errs) != test.errors {
+			t.Errorf("test %d: expected %d errors, got %v", i, test.errors, errs)
+		}
+	}
+}
+
+// fakeCurl records the commands run on the node, and returns the status
+// code for the request
+type fakeCurl struct {
+	status   string
+	err      error
+	commands []string
+}
+
+func (c *fakeCurl) Output(pty bool, args ...string) (string, error) {
+	c.commands = append(c.commands, strings.Join(args, " "))
+	return c.status, c.err
+}
+
+func (c *fakeCurl) Shell(pty bool, args ...string) error { return nil }
+
+func writeCloudConfig(t *testing.T, config string) string {
+	f, err := ioutil.TempFile("", "cloud-config")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(config); err != nil {
+		t.Fatalf("error writing cloud config: %v", err)
+	}
+	return f.Name()
+}
+
+func TestValidateCloudProviderCredentials(t *testing.T) {
+	tests := []struct {
+		provider     string
+		config       string
+		status       string
+		valid        bool
+		expectedURLs []string
+	}{
+		{
+			provider:     "openstack",
+			config:       "[Global]\nauth-url=https://keystone:5000/v3/\nusername=admin\npassword=secret\ntenant-name=kubernetes",
+			status:       "201",
+			valid:        true,
+			expectedURLs: []string{`url = "https://keystone:5000/v3/auth/tokens"`},
+		},
+		{
+			provider:     "openstack",
+			config:       "[Global]\nauth-url=https://keystone:5000/v2.0\nusername=admin\npassword=secret\ntenant-name=kubernetes",
+			status:       "401",
+			valid:        false,
+			expectedURLs: []string{`url = "https://keystone:5000/v2.0/tokens"`},
+		},
+		{
+			provider:     "vsphere",
+			config:       "[Global]\nuser=admin\npassword=secret\ninsecure-flag=1\n[VirtualCenter \"vc1\"]\n[VirtualCenter \"vc2\"]\nport=8443",
+			status:       "200",
+			valid:        true,
+			expectedURLs: []string{"insecure", `url = "https://vc1:443/sdk"`, `url = "https://vc2:8443/sdk"`},
+		},
+		{
+			provider: "gce",
+			valid:    true,
+		},
+	}
+	for i, test := range tests {
+		p := loadBalancerTestPlan("lb.example.com")
+		p.Cluster.CloudProvider.Provider = test.provider
+		if test.config != "" {
+			p.Cluster.CloudProvider.Config = writeCloudConfig(t, test.config)
+			defer os.Remove(p.Cluster.CloudProvider.Config)
+		}
+		client := &fakeCurl{status: test.status}
+		var hosts []string
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			hosts = append(hosts, host)
+			return client, nil
+		}
+		ok, errs := validateCloudProviderCredentials(p, sshClient)
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+		if len(test.expectedURLs) == 0 {
+			if len(client.commands) != 0 {
+				t.Errorf("test %d: expected no requests, got %v", i, client.commands)
+			}
+			continue
+		}
+		if len(hosts) != 1 || hosts[0] != "master1" {
+			t.Errorf("test %d: expected the check to run on the first master, got %v", i, hosts)
+		}
+		all := strings.Join(client.commands, "\n")
+		for _, u := range test.expectedURLs {
+			if !strings.Contains(all, u) {
+				t.Errorf("test %d: expected %q in the commands, got %s", i, u, all)
+			}
+		}
+		for _, cmd := range client.commands {
+			firstLine := strings.SplitN(cmd, "\n", 2)[0]
+			if strings.Contains(firstLine, "secret") {
+				t.Errorf("test %d: expected the credentials to be passed through stdin, got %q", i, firstLine)
+			}
+		}
+	}
+}
+
+func TestVSphereCloudConfig(t *testing.T) {
+	vs := &VSphereCloudProvider{
+		Server:     "vcenter.example.com",
+		Port:       443,
+		Username:   `administrator@vsphere.local`,
+		Password:   `pa"ss\word`,
+		Datacenter: "dc1",
+		Datastore:  "datastore1",
+		Folder:     "kubernetes",
+	}
+	b := vsphereCloudConfig(vs)
+	if errs := validateCloudConfig("vsphere", b); len(errs) != 0 {
+		t.Errorf("expected the generated config to be valid, got %v", errs)
+	}
+	c, err := parseINICloudConfig(b)
+	if err != nil {
+		t.Fatalf("unexpected error parsing the generated config: %v", err)
+	}
+	servers := vsphereServers(c)
+	if len(servers) != 1 || servers[0].server != vs.Server || servers[0].password != vs.Password || servers[0].insecure {
+		t.Errorf("unexpected servers in the generated config: %+v", servers)
+	}
+	if c.get("workspace", "folder") != "kubernetes" || c.get("workspace", "default-datastore") != "datastore1" {
+		t.Errorf("unexpected workspace in the generated config:\n%s", b)
+	}
+}
+
+// fakeVM simulates the system information of a node
+type fakeVM struct {
+	vendor string
+	disks  string
+}
+
+func (vm fakeVM) Output(pty bool, args ...string) (string, error) {
+	switch strings.Join(args, " ") {
+	case "cat /sys/class/dmi/id/sys_vendor":
+		return vm.vendor + "\r\n", nil
+	case "ls /dev/disk/by-id/":
+		return vm.disks, nil
+	}
+	return "", errors.New("unexpected command")
+}
+
+func (vm fakeVM) Shell(pty bool, args ...string) error { return nil }
+
+func TestValidateCloudProviderNodesVSphere(t *testing.T) {
+	tests := []struct {
+		vm    fakeVM
+		valid bool
+	}{
+		{vm: fakeVM{vendor: "VMware, Inc.", disks: "wwn-0x6000c29a2c3e5f7b8d9e0f1a2b3c4d5e  wwn-0x6000c29a2c3e5f7b8d9e0f1a2b3c4d5e-part1"}, valid: true},
+		{vm: fakeVM{vendor: "VMware, Inc.", disks: "ata-VMware_Virtual_IDE_CDROM_Drive_00000000000000000001"}, valid: false},
+		{vm: fakeVM{vendor: "QEMU", disks: "wwn-0x6000c29a2c3e5f7b8d9e0f1a2b3c4d5e"}, valid: false},
+	}
+	for i, test := range tests {
+		p := loadBalancerTestPlan("lb.example.com")
+		p.Cluster.CloudProvider.Provider = "vsphere"
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			return test.vm, nil
+		}
+		ok, errs := validateCloudProviderNodes(p, sshClient)
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+		if !ok && len(errs) != len(p.GetUniqueNodes()) {
+			t.Errorf("test %d: expected an error for every node, got %v", i, errs)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ClusterInfoFilename is the name of the cluster information file in the
+// generated assets directory
+const ClusterInfoFilename = "cluster-info.json"
+
+// ClusterInfo describes an installed cluster, for use by the provisioning
+// systems that need to register it
+type ClusterInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// APIServer is the endpoint of the Kubernetes API
+	APIServer string `json:"apiServer"`
+	// CACertificate is the PEM encoded certificate of the cluster CA
+	CACertificate string            `json:"caCertificate"`
+	Nodes         []ClusterInfoNode `json:"nodes"`
+	GeneratedAt   time.Time         `json:"generatedAt"`
+}
+
+// ClusterInfoNode is a node of the cluster, along with its roles
+type ClusterInfoNode struct {
+	Host       string   `json:"host"`
+	IP         string   `json:"ip"`
+	InternalIP string   `json:"internalIP,omitempty"`
+	Roles      []string `json:"roles"`
+}
+
+// NewClusterInfo returns the information of the cluster described by the plan.
+// The CA certificate is read from the generated assets directory.
+func NewClusterInfo(p *Plan, generatedAssetsDir string, now time.Time) (*ClusterInfo, error) {
+	ca, err := ioutil.ReadFile(filepath.Join(generatedAssetsDir, "keys", "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the CA certificate: %v", err)
+	}
+	info := &ClusterInfo{
+		Name:          p.Cluster.Name,
+		Version:       p.Cluster.Version,
+		APIServer:     "https://" + p.Master.LoadBalancedFQDN + ":6443",
+		CACertificate: string(ca),
+		Nodes:         []ClusterInfoNode{},
+		GeneratedAt:   now.UTC(),
+	}
+	for _, n := range p.GetUniqueNodes() {
+		info.Nodes = append(info.Nodes, ClusterInfoNode{
+			Host:       n.Host,
+			IP:         n.IP,
+			InternalIP: n.InternalIP,
+			Roles:      p.GetRolesForIP(n.IP),
+		})
+	}
+	return info, nil
+}
+
+// WriteClusterInfo writes the cluster information file to the generated
+// assets directory, and returns the path of the file
+func WriteClusterInfo(p *Plan, generatedAssetsDir string) (string, error) {
+	info, err := NewClusterInfo(p, generatedAssetsDir, time.Now())
+	if err != nil {
+		return "", err
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling cluster info: %v", err)
+	}
+	file := filepath.Join(generatedAssetsDir, ClusterInfoFilename)
+	if err := ioutil.WriteFile(file, append(b, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("error writing cluster info file: %v", err)
+	}
+	return file, nil
+}
+
+// PublishClusterInfo sends the cluster information file to the publish URL of
+// the plan
+func PublishClusterInfo(p *Plan, file string) error {
+	publisher := clusterInfoPublisher{
+		client: &http.Client{Timeout: 30 * time.Second},
+		getenv: os.Getenv,
+		now:    time.Now,
+	}
+	return publisher.publish(p.Cluster.ClusterInfo, file)
+}
+
+type clusterInfoPublisher struct {
+	client *http.Client
+	getenv func(string) string
+	now    func() time.Time
+	// Hook for testing purposes, overrides the S3 endpoint
+	s3Endpoint string
+}
+
+func (c clusterInfoPublisher) publish(opts ClusterInfoOptions, file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading cluster info file: %v", err)
+	}
+	u, err := url.Parse(opts.PublishURL)
+	if err != nil {
+		return fmt.Errorf("invalid publish URL %q: %v", opts.PublishURL, err)
+	}
+	var req *http.Request
+	switch u.Scheme {
+	case "http", "https":
+		req, err = http.NewRequest("PUT", opts.PublishURL, bytes.NewReader(b))
+	case "s3":
+		req, err = c.s3Request(opts.S3Region, u.Host, strings.TrimPrefix(u.Path, "/"), b)
+	default:
+		return fmt.Errorf("publish URL %q is not supported", opts.PublishURL)
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error publishing cluster info: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("error publishing cluster info: got status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// s3Request returns a signed request that uploads the file to the S3 bucket,
+// using the credentials of the environment
+func (c clusterInfoPublisher) s3Request(region, bucket, key string, body []byte) (*http.Request, error) {
+	creds := awsCredentials{
+		AccessKeyID:     c.getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: c.getenv("AWS_SECRET_ACCESS_KEY"),
+		Token:           c.getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables must be set to publish to S3")
+	}
+	host := fmt.Sprintf("s3.%s.amazonaws.com", region)
+	if strings.HasPrefix(region, "cn-") {
+		host = host + ".cn"
+	}
+	// path-style requests support bucket names that contain dots
+	segments := []string{bucket}
+	for _, s := range strings.Split(key, "/") {
+		segments = append(segments, url.PathEscape(s))
+	}
+	path := "/" + strings.Join(segments, "/")
+	endpoint := "https://" + host
+	if c.s3Endpoint != "" {
+		endpoint = c.s3Endpoint
+	}
+	req, err := http.NewRequest("PUT", endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// the request is signed for the S3 host, even when the endpoint is overridden
+	req.Host = host
+	for k, v := range signAWSRequestV4("PUT", host, path, "", body, region, "s3", creds, c.now()) {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
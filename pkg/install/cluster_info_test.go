@@ -0,0 +1,156 @@
+package install
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func clusterInfoTestPlan() *Plan {
+	p := &Plan{}
+	p.Cluster.Name = "prod"
+	p.Cluster.Version = "v1.10.3"
+	p.Master.LoadBalancedFQDN = "prod.example.com"
+	p.Etcd.Nodes = []Node{{Host: "master1", IP: "10.0.0.1"}}
+	p.Master.Nodes = []Node{{Host: "master1", IP: "10.0.0.1"}}
+	p.Worker.Nodes = []Node{{Host: "worker1", IP: "10.0.0.2", InternalIP: "192.168.0.2"}}
+	return p
+}
+
+func TestWriteClusterInfo(t *testing.T) {
+	dir := createTempDirForRegenerateKubeconfigTests(t)
+	defer os.RemoveAll(dir)
+
+	file, err := WriteClusterInfo(clusterInfoTestPlan(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file != filepath.Join(dir, ClusterInfoFilename) {
+		t.Errorf("unexpected file %q", file)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading the file: %v", err)
+	}
+	info := ClusterInfo{}
+	if err := json.Unmarshal(b, &info); err != nil {
+		t.Fatalf("error unmarshaling the file: %v", err)
+	}
+	if info.Name != "prod" || info.Version != "v1.10.3" || info.APIServer != "https://prod.example.com:6443" || info.CACertificate != "ca.pem" {
+		t.Errorf("unexpected cluster info: %+v", info)
+	}
+	expectedNodes := []ClusterInfoNode{
+		{Host: "master1", IP: "10.0.0.1", Roles: []string{"master", "etcd"}},
+		{Host: "worker1", IP: "10.0.0.2", InternalIP: "192.168.0.2", Roles: []string{"worker"}},
+	}
+	if !reflect.DeepEqual(info.Nodes, expectedNodes) {
+		t.Errorf("expected nodes %+v, got %+v", expectedNodes, info.Nodes)
+	}
+}
+
+func writeTestClusterInfoFile(t *testing.T) string {
+	f, err := ioutil.TempFile("", "cluster-info")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(`{"name": "prod"}`); err != nil {
+		t.Fatalf("error writing temp file: %v", err)
+	}
+	return f.Name()
+}
+
+func TestPublishClusterInfoHTTP(t *testing.T) {
+	file := writeTestClusterInfoFile(t)
+	defer os.Remove(file)
+
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	c := clusterInfoPublisher{client: http.DefaultClient, getenv: func(string) string { return "" }, now: time.Now}
+	if err := c.publish(ClusterInfoOptions{PublishURL: server.URL + "/clusters/prod"}, file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != "PUT" || path != "/clusters/prod" || body != `{"name": "prod"}` {
+		t.Errorf("unexpected request %s %s: %s", method, path, body)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer failing.Close()
+	if err := c.publish(ClusterInfoOptions{PublishURL: failing.URL}, file); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected an error with the status code, got %v", err)
+	}
+}
+
+func TestPublishClusterInfoS3(t *testing.T) {
+	file := writeTestClusterInfoFile(t)
+	defer os.Remove(file)
+
+	var req *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+	}))
+	defer server.Close()
+
+	env := map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret"}
+	c := clusterInfoPublisher{
+		client:     http.DefaultClient,
+		getenv:     func(k string) string { return env[k] },
+		now:        func() time.Time { return time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC) },
+		s3Endpoint: server.URL,
+	}
+	opts := ClusterInfoOptions{PublishURL: "s3://my.bucket/clusters/prod info.json", S3Region: "us-west-2"}
+	if err := c.publish(opts, file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Method != "PUT" || req.URL.EscapedPath() != "/my.bucket/clusters/prod%20info.json" || req.Host != "s3.us-west-2.amazonaws.com" {
+		t.Errorf("unexpected request %s %s to %s", req.Method, req.URL.EscapedPath(), req.Host)
+	}
+	prefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20180601/us-west-2/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, prefix) {
+		t.Errorf("unexpected authorization header: %s", auth)
+	}
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		t.Errorf("expected the payload hash header to be set")
+	}
+
+	// no credentials
+	env = map[string]string{}
+	if err := c.publish(opts, file); err == nil {
+		t.Errorf("expected an error when the AWS credentials are not set")
+	}
+}
+
+func TestValidateClusterInfoOptions(t *testing.T) {
+	tests := []struct {
+		opts  ClusterInfoOptions
+		valid bool
+	}{
+		{opts: ClusterInfoOptions{}, valid: true},
+		{opts: ClusterInfoOptions{PublishURL: "https://registry.example.com/clusters/prod"}, valid: true},
+		{opts: ClusterInfoOptions{PublishURL: "s3://bucket/prod.json", S3Region: "us-east-1"}, valid: true},
+		{opts: ClusterInfoOptions{PublishURL: "s3://bucket/prod.json"}, valid: false},
+		{opts: ClusterInfoOptions{PublishURL: "s3://bucket", S3Region: "us-east-1"}, valid: false},
+		{opts: ClusterInfoOptions{PublishURL: "ftp://example.com/prod.json"}, valid: false},
+		{opts: ClusterInfoOptions{PublishURL: "https:///prod.json"}, valid: false},
+	}
+	for i, test := range tests {
+		if valid, errs := test.opts.validate(); valid != test.valid {
+			t.Errorf("test %d: expected valid %v, got %v: %v", i, test.valid, valid, errs)
+		}
+	}
+}
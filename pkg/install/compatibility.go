@@ -0,0 +1,90 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/blang/semver"
+)
+
+// Operation is a cluster operation that is subject to version compatibility checks
+type Operation string
+
+const (
+	// OperationApply installs or re-applies the plan on the cluster
+	OperationApply Operation = "apply"
+	// OperationAddNode adds a node to an existing cluster
+	OperationAddNode Operation = "add-node"
+	// OperationStep runs a single play against the cluster
+	OperationStep Operation = "step"
+	// OperationUpgrade upgrades the cluster to the version supported by this binary
+	OperationUpgrade Operation = "upgrade"
+)
+
+// IncompatibleVersionErr is returned when a node in the cluster is running
+// versions that cannot be managed by this version of Kismatic
+type IncompatibleVersionErr struct {
+	Host   string
+	Reason string
+	// Guidance on how to proceed
+	Guidance string
+}
+
+func (e IncompatibleVersionErr) Error() string {
+	return fmt.Sprintf("Node %q: %s. %s", e.Host, e.Reason, e.Guidance)
+}
+
+// CheckVersionCompatibility verifies that the operation can be performed against
+// the nodes described in the cluster version using this version of Kismatic.
+// Nodes that have not been installed are expected to be excluded from the
+// cluster version.
+func CheckVersionCompatibility(cv ClusterVersion, op Operation) []error {
+	var errs []error
+	for _, n := range cv.Nodes {
+		if n.Version.GT(KismaticVersion) {
+			errs = append(errs, IncompatibleVersionErr{
+				Host:     n.Node.Host,
+				Reason:   fmt.Sprintf("installed with Kismatic %s, which is newer than this version (%s)", n.Version, KismaticVersion),
+				Guidance: fmt.Sprintf("Use Kismatic %s or later to manage this cluster", n.Version),
+			})
+			continue
+		}
+		// etcd-only nodes don't run Kubernetes components
+		if n.ComponentVersions.Kubernetes == "" {
+			continue
+		}
+		kv, err := parseVersion(n.ComponentVersions.Kubernetes)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Node %q: %v", n.Node.Host, err))
+			continue
+		}
+		if err := checkKubernetesVersion(n.Node.Host, kv, op); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func checkKubernetesVersion(host string, kv semver.Version, op Operation) error {
+	supported := kubernetesVersion
+	switch {
+	case kv.Major != supported.Major || kv.Minor > supported.Minor:
+		return IncompatibleVersionErr{
+			Host:     host,
+			Reason:   fmt.Sprintf("running Kubernetes %s, which is newer than the versions supported by Kismatic %s (%s)", kv, KismaticVersion, kubernetesMinorVersionString),
+			Guidance: "Downgrades are not supported. Use a newer version of Kismatic to manage this cluster",
+		}
+	case kv.Minor < supported.Minor-1:
+		return IncompatibleVersionErr{
+			Host:     host,
+			Reason:   fmt.Sprintf("running Kubernetes %s, which cannot be upgraded directly to %s", kv, kubernetesMinorVersionString),
+			Guidance: fmt.Sprintf("Upgrade the cluster to v%d.%d.x with an earlier version of Kismatic first", supported.Major, supported.Minor-1),
+		}
+	case kv.Minor == supported.Minor-1 && op != OperationUpgrade:
+		return IncompatibleVersionErr{
+			Host:     host,
+			Reason:   fmt.Sprintf("running Kubernetes %s, but %q requires %s", kv, op, kubernetesMinorVersionString),
+			Guidance: "Run \"kismatic upgrade\" to upgrade the cluster before performing this operation",
+		}
+	}
+	return nil
+}
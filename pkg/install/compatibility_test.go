@@ -0,0 +1,46 @@
+package install
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+)
+
+func TestCheckVersionCompatibility(t *testing.T) {
+	defer func(v semver.Version) { KismaticVersion = v }(KismaticVersion)
+	KismaticVersion = mustParseVersion("1.11.0")
+	tests := []struct {
+		ketVersion  string
+		kubeVersion string
+		op          Operation
+		valid       bool
+	}{
+		{ketVersion: "1.11.0", kubeVersion: "v1.10.3", op: OperationApply, valid: true},
+		{ketVersion: "1.10.0", kubeVersion: "v1.10.1", op: OperationAddNode, valid: true},
+		{ketVersion: "1.10.0", kubeVersion: "", op: OperationApply, valid: true},
+		{ketVersion: "1.9.0", kubeVersion: "v1.9.6", op: OperationUpgrade, valid: true},
+		{ketVersion: "1.9.0", kubeVersion: "v1.9.6", op: OperationApply, valid: false},
+		{ketVersion: "1.9.0", kubeVersion: "v1.9.6", op: OperationStep, valid: false},
+		{ketVersion: "1.8.0", kubeVersion: "v1.8.4", op: OperationUpgrade, valid: false},
+		{ketVersion: "1.11.0", kubeVersion: "v1.11.0", op: OperationApply, valid: false},
+		{ketVersion: "1.12.0", kubeVersion: "v1.10.3", op: OperationUpgrade, valid: false},
+	}
+	for i, test := range tests {
+		cv := ClusterVersion{
+			Nodes: []ListableNode{
+				{
+					Node:              Node{Host: "node01"},
+					Version:           mustParseVersion(test.ketVersion),
+					ComponentVersions: ComponentVersions{Kubernetes: test.kubeVersion},
+				},
+			},
+		}
+		errs := CheckVersionCompatibility(cv, test.op)
+		if test.valid && len(errs) != 0 {
+			t.Errorf("test %d: expected no errors, but got %v", i, errs)
+		}
+		if !test.valid && len(errs) == 0 {
+			t.Errorf("test %d: expected an error, but didn't get one", i)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The explanations of how the protected options of the Kubernetes components
+// are configured instead
+const (
+	managedByCloudProvider       = "set cluster.cloud_provider.provider instead"
+	managedByCloudConfig         = "set cluster.cloud_provider.config instead"
+	managedByPodCIDR             = "set cluster.networking.pod_cidr_block instead"
+	managedByServiceCIDR         = "set cluster.networking.service_cidr_block instead"
+	managedByClusterCA           = "it is set to the certificate authority of the cluster, in the generated assets directory"
+	managedByServiceAccountKey   = "it is set to the service account key that is generated by KET"
+	managedByComponentKubeconfig = "it is set to the kubeconfig that is generated by KET for the component"
+	managedByNodeHost            = "it is set to the host of the node"
+)
+
+// protectedOptionErrors returns an error for each of the overrides that sets
+// one of the protected options of the component. The protected options map
+// each option to an explanation of how it is configured instead, such as the
+// field of the plan file that sets it.
+func protectedOptionErrors(component string, overrides map[string]string, protected map[string]string) []error {
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	errs := []error{}
+	for _, k := range keys {
+		// Catch options that were set with the dashes of the flag, such as "--etcd-servers"
+		if explanation, found := protected[strings.TrimLeft(k, "-")]; found {
+			errs = append(errs, fmt.Errorf("%s option %q cannot be overridden, %s", component, k, explanation))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,51 @@
+package install
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProtectedOptionErrors(t *testing.T) {
+	protected := map[string]string{
+		"etcd-servers":  "it is set to the nodes in the etcd section",
+		"tls-cert-file": "it is set by KET",
+	}
+	tests := []struct {
+		overrides map[string]string
+		expected  []error
+	}{
+		{
+			overrides: nil,
+			expected:  []error{},
+		},
+		{
+			overrides: map[string]string{"v": "3"},
+			expected:  []error{},
+		},
+		{
+			overrides: map[string]string{"v": "3", "tls-cert-file": "foo", "etcd-servers": "bar"},
+			expected: []error{
+				errors.New(`Kube ApiServer option "etcd-servers" cannot be overridden, it is set to the nodes in the etcd section`),
+				errors.New(`Kube ApiServer option "tls-cert-file" cannot be overridden, it is set by KET`),
+			},
+		},
+		{
+			overrides: map[string]string{"--etcd-servers": "bar"},
+			expected: []error{
+				errors.New(`Kube ApiServer option "--etcd-servers" cannot be overridden, it is set to the nodes in the etcd section`),
+			},
+		},
+	}
+	for i, test := range tests {
+		errs := protectedOptionErrors("Kube ApiServer", test.overrides, protected)
+		if len(errs) != len(test.expected) {
+			t.Errorf("test %d: expected %d errors, got %v", i, len(test.expected), errs)
+			continue
+		}
+		for j := range errs {
+			if errs[j].Error() != test.expected[j].Error() {
+				t.Errorf("test %d: expected %q, got %q", i, test.expected[j], errs[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package install
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// ConformanceResults summarizes the results of the conformance tests
+type ConformanceResults struct {
+	// Status of the sonobuoy run, as reported by sonobuoy
+	Status  string `json:"status"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+	// FailedTests are the names of the tests that failed, sorted by name
+	FailedTests []string `json:"failedTests,omitempty"`
+	// ResultsFile is the path to the results tarball retrieved from the cluster
+	ResultsFile string `json:"resultsFile"`
+}
+
+// Sonobuoy runs the Kubernetes conformance tests against a cluster using the
+// sonobuoy CLI
+type Sonobuoy struct {
+	// BinaryPath is the path to the sonobuoy binary
+	BinaryPath string
+	// Kubeconfig is the path to the kubeconfig file used to access the cluster
+	Kubeconfig string
+	// Mode is the sonobuoy mode used to run the tests. The sonobuoy default is
+	// used when empty.
+	Mode string
+	// Timeout is the maximum time to wait for the tests to complete
+	Timeout time.Duration
+	// PollInterval is the time between checks of the status of the tests
+	PollInterval time.Duration
+	// KeepResources leaves the sonobuoy resources on the cluster after the
+	// results are retrieved
+	KeepResources bool
+
+	// Hook for testing purposes, runs the sonobuoy binary with the arguments
+	// and returns its output
+	exec func(args ...string) (string, error)
+}
+
+type sonobuoyStatus struct {
+	Status string `json:"status"`
+}
+
+func (s Sonobuoy) run(args ...string) (string, error) {
+	args = append(args, "--kubeconfig", s.Kubeconfig)
+	logging.Debug("running sonobuoy", "args", args)
+	if s.exec != nil {
+		return s.exec(args...)
+	}
+	out, err := exec.Command(s.BinaryPath, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// Run deploys sonobuoy to the cluster, waits for the conformance tests to
+// complete, and retrieves the results into the results directory. A summary
+// of the results is also written to the results directory.
+func (s Sonobuoy) Run(out io.Writer, resultsDir string) (*ConformanceResults, error) {
+	args := []string{"run"}
+	if s.Mode != "" {
+		args = append(args, "--mode", s.Mode)
+	}
+	util.PrettyPrint(out, "Deploying Sonobuoy to the cluster")
+	if _, err := s.run(args...); err != nil {
+		util.PrintError(out)
+		return nil, fmt.Errorf("error running sonobuoy: %v", err)
+	}
+	util.PrintOkln(out)
+	if !s.KeepResources {
+		defer func() {
+			if _, err := s.run("delete"); err != nil {
+				util.PrettyPrintWarn(out, "Could not remove Sonobuoy from the cluster: %v", err)
+			}
+		}()
+	}
+
+	util.PrettyPrint(out, "Waiting for the conformance tests to complete")
+	status, err := s.wait()
+	if err != nil {
+		util.PrintError(out)
+		return nil, err
+	}
+	util.PrintOkln(out)
+
+	util.PrettyPrint(out, "Retrieving the results")
+	file, err := s.retrieve(resultsDir)
+	if err != nil {
+		util.PrintError(out)
+		return nil, err
+	}
+	results, err := ParseConformanceResults(file)
+	if err != nil {
+		util.PrintError(out)
+		return nil, err
+	}
+	util.PrintOkln(out)
+	results.Status = status
+
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling conformance summary: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(resultsDir, "conformance-summary.json"), b, 0644); err != nil {
+		return nil, fmt.Errorf("error writing conformance summary: %v", err)
+	}
+	return results, nil
+}
+
+// wait polls the status of the sonobuoy run until it completes or fails, and
+// returns the final status
+func (s Sonobuoy) wait() (string, error) {
+	start := time.Now()
+	for {
+		raw, err := s.run("status", "--json")
+		if err != nil {
+			// sonobuoy reports an error while its pods are starting up
+			logging.Debug("error getting sonobuoy status", "error", err)
+		} else {
+			var st sonobuoyStatus
+			if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &st); err != nil {
+				return "", fmt.Errorf("error parsing sonobuoy status %q: %v", raw, err)
+			}
+			switch st.Status {
+			case "complete", "failed":
+				return st.Status, nil
+			}
+		}
+		if time.Since(start) >= s.Timeout {
+			return "", fmt.Errorf("timed out after %v waiting for the conformance tests to complete", s.Timeout)
+		}
+		time.Sleep(s.PollInterval)
+	}
+}
+
+// retrieve copies the results tarball into the directory, and returns the
+// path to the tarball
+func (s Sonobuoy) retrieve(dir string) (string, error) {
+	raw, err := s.run("retrieve", dir)
+	if err != nil {
+		return "", fmt.Errorf("error retrieving sonobuoy results: %v", err)
+	}
+	// sonobuoy prints the name of the tarball it retrieved
+	lines := strings.Split(strings.TrimSpace(raw), "\n")
+	if file := strings.TrimSpace(lines[len(lines)-1]); strings.HasSuffix(file, ".tar.gz") {
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.Base(file))); err == nil {
+			return filepath.Join(dir, filepath.Base(file)), nil
+		}
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tar.gz"))
+	if err != nil || len(matches) == 0 {
+		return "", fmt.Errorf("sonobuoy results were not found in %q", dir)
+	}
+	return matches[len(matches)-1], nil
+}
+
+type junitTestSuites struct {
+	Suites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name    string    `xml:"name,attr"`
+	Failure *struct{} `xml:"failure"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+// ParseConformanceResults counts the tests that passed, failed and were
+// skipped in the JUnit reports of the e2e plugin contained in the sonobuoy
+// results tarball
+func ParseConformanceResults(tarball string) (*ConformanceResults, error) {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sonobuoy results: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sonobuoy results %q: %v", tarball, err)
+	}
+	defer gz.Close()
+
+	results := &ConformanceResults{ResultsFile: tarball}
+	found := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading sonobuoy results %q: %v", tarball, err)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if !strings.HasPrefix(name, "plugins/e2e/results/") || path.Ext(name) != ".xml" {
+			continue
+		}
+		found = true
+		if err := countJUnitResults(tr, results); err != nil {
+			return nil, fmt.Errorf("error parsing %q in sonobuoy results: %v", name, err)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("the e2e test results were not found in %q", tarball)
+	}
+	sort.Strings(results.FailedTests)
+	return results, nil
+}
+
+func countJUnitResults(r io.Reader, results *ConformanceResults) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	// Reports contain either a single test suite, or a list of test suites
+	var suites junitTestSuites
+	if err := xml.Unmarshal(b, &suites); err != nil {
+		return err
+	}
+	if len(suites.Suites) == 0 {
+		var suite junitTestSuite
+		if err := xml.Unmarshal(b, &suite); err != nil {
+			return err
+		}
+		suites.Suites = []junitTestSuite{suite}
+	}
+	for _, s := range suites.Suites {
+		for _, tc := range s.TestCases {
+			switch {
+			case tc.Failure != nil:
+				results.Failed++
+				results.FailedTests = append(results.FailedTests, tc.Name)
+			case tc.Skipped != nil:
+				results.Skipped++
+			default:
+				results.Passed++
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite tests="4" failures="1" time="100">
+  <testcase name="[sig-network] DNS should provide DNS for services [Conformance]" classname="Kubernetes e2e suite" time="10"></testcase>
+  <testcase name="[sig-apps] Deployment deployment should support rollover [Conformance]" classname="Kubernetes e2e suite" time="10">
+    <failure type="Failure">timed out</failure>
+  </testcase>
+  <testcase name="[sig-storage] Volumes should be mountable" classname="Kubernetes e2e suite" time="0">
+    <skipped></skipped>
+  </testcase>
+  <testcase name="[sig-node] Pods should be submitted and removed [Conformance]" classname="Kubernetes e2e suite" time="10"></testcase>
+</testsuite>`
+
+func writeResultsTarball(t *testing.T, file string, files map[string]string) {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("error writing tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("error writing tar content: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("error writing tarball: %v", err)
+	}
+}
+
+func TestParseConformanceResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conformance-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "results.tar.gz")
+	writeResultsTarball(t, file, map[string]string{
+		"plugins/e2e/results/junit_01.xml": junitReport,
+		"plugins/e2e/results/e2e.log":      "log",
+		"resources/cluster/Nodes.json":     "[]",
+	})
+
+	results, err := ParseConformanceResults(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &ConformanceResults{
+		Passed:      2,
+		Failed:      1,
+		Skipped:     1,
+		FailedTests: []string{"[sig-apps] Deployment deployment should support rollover [Conformance]"},
+		ResultsFile: file,
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %+v, got %+v", expected, results)
+	}
+}
+
+func TestParseConformanceResultsTestSuites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conformance-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "results.tar.gz")
+	report := `<testsuites><testsuite><testcase name="a"></testcase></testsuite><testsuite><testcase name="b"><failure/></testcase></testsuite></testsuites>`
+	writeResultsTarball(t, file, map[string]string{"./plugins/e2e/results/junit_01.xml": report})
+
+	results, err := ParseConformanceResults(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results.Passed != 1 || results.Failed != 1 {
+		t.Errorf("expected 1 passed and 1 failed test, got %+v", results)
+	}
+}
+
+func TestParseConformanceResultsMissingReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conformance-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "results.tar.gz")
+	writeResultsTarball(t, file, map[string]string{"resources/cluster/Nodes.json": "[]"})
+
+	if _, err := ParseConformanceResults(file); err == nil {
+		t.Errorf("expected an error when the e2e results are missing")
+	}
+}
+
+func TestSonobuoyRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conformance-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var commands []string
+	statuses := []string{"", `{"status":"running"}`, `{"status":"complete"}`}
+	s := Sonobuoy{
+		Kubeconfig:   "generated/kubeconfig",
+		Mode:         "quick",
+		Timeout:      time.Second,
+		PollInterval: time.Millisecond,
+		exec: func(args ...string) (string, error) {
+			commands = append(commands, strings.Join(args, " "))
+			switch args[0] {
+			case "status":
+				st := statuses[0]
+				statuses = statuses[1:]
+				if st == "" {
+					return "", errors.New("sonobuoy is starting")
+				}
+				return st, nil
+			case "retrieve":
+				file := filepath.Join(args[1], "201801010000_sonobuoy.tar.gz")
+				writeResultsTarball(t, file, map[string]string{"plugins/e2e/results/junit_01.xml": junitReport})
+				return file + "\n", nil
+			}
+			return "", nil
+		},
+	}
+	results, err := s.Run(&bytes.Buffer{}, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results.Status != "complete" || results.Passed != 2 || results.Failed != 1 {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	expectedCommands := []string{
+		"run --mode quick --kubeconfig generated/kubeconfig",
+		"status --json --kubeconfig generated/kubeconfig",
+		"status --json --kubeconfig generated/kubeconfig",
+		"status --json --kubeconfig generated/kubeconfig",
+		"retrieve " + dir + " --kubeconfig generated/kubeconfig",
+		"delete --kubeconfig generated/kubeconfig",
+	}
+	if !reflect.DeepEqual(commands, expectedCommands) {
+		t.Errorf("expected commands:\n%s\ngot:\n%s", strings.Join(expectedCommands, "\n"), strings.Join(commands, "\n"))
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "conformance-summary.json"))
+	if err != nil {
+		t.Fatalf("expected a summary to be written: %v", err)
+	}
+	var summary ConformanceResults
+	if err := json.Unmarshal(b, &summary); err != nil {
+		t.Fatalf("error unmarshaling summary: %v", err)
+	}
+	if !reflect.DeepEqual(&summary, results) {
+		t.Errorf("expected summary %+v, got %+v", results, summary)
+	}
+}
+
+func TestSonobuoyRunTimeout(t *testing.T) {
+	var deleted bool
+	s := Sonobuoy{
+		Timeout:      10 * time.Millisecond,
+		PollInterval: time.Millisecond,
+		exec: func(args ...string) (string, error) {
+			switch args[0] {
+			case "status":
+				return `{"status":"running"}`, nil
+			case "delete":
+				deleted = true
+			}
+			return "", nil
+		},
+	}
+	if _, err := s.Run(&bytes.Buffer{}, "results"); err == nil {
+		t.Errorf("expected an error when the tests time out")
+	}
+	if !deleted {
+		t.Errorf("expected sonobuoy to be removed from the cluster")
+	}
+}
@@ -0,0 +1,76 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// consoleOutputFormat returns the format of the ansible output for the
+// console output format
+func consoleOutputFormat(format string) (ansible.OutputFormat, error) {
+	switch format {
+	case "raw":
+		return ansible.RawFormat, nil
+	case "simple", "table", "ci", "json":
+		return ansible.JSONLinesFormat, nil
+	default:
+		return "", fmt.Errorf("Output format %q is not supported", format)
+	}
+}
+
+// consoleOutput returns the writer of the console output of the executor.
+// The output is also sent to the file and the unix socket of the options,
+// when they are set.
+func consoleOutput(stdout io.Writer, options ExecutorOptions) (io.Writer, error) {
+	var outputs []io.Writer
+	if options.OutputFile != "" {
+		f, err := os.OpenFile(options.OutputFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("error opening output file %q: %v", options.OutputFile, err)
+		}
+		outputs = append(outputs, f)
+	}
+	if options.OutputSocket != "" {
+		conn, err := net.Dial("unix", options.OutputSocket)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to output socket %q: %v", options.OutputSocket, err)
+		}
+		outputs = append(outputs, conn)
+	}
+	if len(outputs) == 0 {
+		return stdout, nil
+	}
+	return &teeWriter{console: stdout, outputs: outputs}, nil
+}
+
+// teeWriter writes to the console and to the additional outputs. An output
+// that fails to be written, such as a socket whose reader went away, is
+// dropped without interrupting the console output.
+type teeWriter struct {
+	console io.Writer
+	mu      sync.Mutex
+	outputs []io.Writer
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, err := t.console.Write(p)
+	var ok []io.Writer
+	for _, o := range t.outputs {
+		if _, werr := o.Write(p); werr != nil {
+			if c, isCloser := o.(io.Closer); isCloser {
+				c.Close()
+			}
+			continue
+		}
+		ok = append(ok, o)
+	}
+	t.outputs = ok
+	return n, err
+}
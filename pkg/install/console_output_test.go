@@ -0,0 +1,100 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func TestConsoleOutputNoOutputs(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	w, err := consoleOutput(stdout, ExecutorOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w != stdout {
+		t.Errorf("expected the console output to be returned unchanged")
+	}
+}
+
+func TestConsoleOutputFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "console-output-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "output.log")
+	if err := ioutil.WriteFile(file, []byte("previous\n"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	w, err := consoleOutput(stdout, ExecutorOptions{OutputFile: file})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Write([]byte("current\n"))
+	if stdout.String() != "current\n" {
+		t.Errorf("unexpected console output: %q", stdout.String())
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	if string(b) != "previous\ncurrent\n" {
+		t.Errorf("expected the output to be appended to the file, got %q", string(b))
+	}
+}
+
+func TestConsoleOutputSocketNotListening(t *testing.T) {
+	_, err := consoleOutput(&bytes.Buffer{}, ExecutorOptions{OutputSocket: "/non/existent/socket"})
+	if err == nil {
+		t.Errorf("expected an error when the socket is not listening")
+	}
+}
+
+func TestTeeWriterDropsFailingOutput(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	other := &bytes.Buffer{}
+	failing := &failingWriter{}
+	w := &teeWriter{console: stdout, outputs: []io.Writer{failing, other}}
+
+	w.Write([]byte("one"))
+	n, err := w.Write([]byte("two"))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 bytes written, got %d", n)
+	}
+	if stdout.String() != "onetwo" || other.String() != "onetwo" {
+		t.Errorf("unexpected output: console %q, other %q", stdout.String(), other.String())
+	}
+	if failing.writes != 1 {
+		t.Errorf("expected the failing output to be dropped after the first write, got %d writes", failing.writes)
+	}
+}
+
+func TestConsoleOutputFormat(t *testing.T) {
+	for _, f := range []string{"raw", "simple", "table", "ci"} {
+		if _, err := consoleOutputFormat(f); err != nil {
+			t.Errorf("unexpected error for format %q: %v", f, err)
+		}
+	}
+	if _, err := consoleOutputFormat("xml"); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+}
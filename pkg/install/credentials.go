@@ -0,0 +1,25 @@
+package install
+
+import (
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// runPlanFilename is the copy of the plan file recorded in the run directory
+const runPlanFilename = "kismatic-cluster.yaml"
+
+// runCredentialFiles are the files of the run directory that hold the
+// passwords of the cluster
+var runCredentialFiles = []string{"clustercatalog.yaml", runPlanFilename}
+
+// shredRunCredentials overwrites and removes the files of the run directory
+// that hold the passwords of the cluster
+func shredRunCredentials(runDirectory string) error {
+	for _, f := range runCredentialFiles {
+		if err := util.ShredFile(filepath.Join(runDirectory, f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
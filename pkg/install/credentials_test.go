@@ -0,0 +1,51 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecuteRecordsPlanFileSecurely(t *testing.T) {
+	tests := []struct {
+		shred    bool
+		recorded bool
+	}{
+		{recorded: true},
+		{shred: true},
+	}
+	for _, test := range tests {
+		e := retryExecutor(t, &retryRunner{}, RetryPolicy{})
+		e.options.ShredCredentials = test.shred
+		if _, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		files, err := filepath.Glob(filepath.Join(e.options.RunsDirectory, "apply", "*", runPlanFilename))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !test.recorded {
+			if len(files) != 0 {
+				t.Errorf("shred %v: expected the plan file to be removed, got %v", test.shred, files)
+			}
+			continue
+		}
+		if len(files) != 1 {
+			t.Fatalf("shred %v: expected the plan file to be recorded, got %v", test.shred, files)
+		}
+		info, err := os.Stat(files[0])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("shred %v: expected the plan file to be only readable by the user, got %v", test.shred, info.Mode().Perm())
+		}
+		log, err := os.Stat(filepath.Join(filepath.Dir(files[0]), "ansible.log"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if log.Mode().Perm() != 0600 {
+			t.Errorf("shred %v: expected the ansible log to be only readable by the user, got %v", test.shred, log.Mode().Perm())
+		}
+	}
+}
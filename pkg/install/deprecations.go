@@ -0,0 +1,221 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/blang/semver"
+)
+
+const deprecationReportFilename = "deprecation-report.json"
+
+// lastAppliedConfigAnnotation holds the manifest that was last applied with
+// kubectl, which includes the API version used to create the object
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// DeprecatedAPI is an API version of a kind that is deprecated, and
+// eventually removed, in a given Kubernetes version
+type DeprecatedAPI struct {
+	APIVersion   string
+	Kind         string
+	Resource     string
+	DeprecatedIn semver.Version
+	RemovedIn    semver.Version
+	Replacement  string
+}
+
+func deprecatedAPI(apiVersion, kind, resource string, deprecatedIn, removedIn uint64, replacement string) DeprecatedAPI {
+	return DeprecatedAPI{
+		APIVersion:   apiVersion,
+		Kind:         kind,
+		Resource:     resource,
+		DeprecatedIn: semver.Version{Major: 1, Minor: deprecatedIn},
+		RemovedIn:    semver.Version{Major: 1, Minor: removedIn},
+		Replacement:  replacement,
+	}
+}
+
+// deprecatedAPIs are the known API versions that are deprecated or removed
+var deprecatedAPIs = []DeprecatedAPI{
+	deprecatedAPI("extensions/v1beta1", "Deployment", "deployments", 9, 16, "apps/v1"),
+	deprecatedAPI("apps/v1beta1", "Deployment", "deployments", 9, 16, "apps/v1"),
+	deprecatedAPI("apps/v1beta2", "Deployment", "deployments", 9, 16, "apps/v1"),
+	deprecatedAPI("extensions/v1beta1", "DaemonSet", "daemonsets", 9, 16, "apps/v1"),
+	deprecatedAPI("apps/v1beta2", "DaemonSet", "daemonsets", 9, 16, "apps/v1"),
+	deprecatedAPI("extensions/v1beta1", "ReplicaSet", "replicasets", 9, 16, "apps/v1"),
+	deprecatedAPI("apps/v1beta2", "ReplicaSet", "replicasets", 9, 16, "apps/v1"),
+	deprecatedAPI("apps/v1beta1", "StatefulSet", "statefulsets", 9, 16, "apps/v1"),
+	deprecatedAPI("apps/v1beta2", "StatefulSet", "statefulsets", 9, 16, "apps/v1"),
+	deprecatedAPI("extensions/v1beta1", "NetworkPolicy", "networkpolicies", 9, 16, "networking.k8s.io/v1"),
+	deprecatedAPI("extensions/v1beta1", "PodSecurityPolicy", "podsecuritypolicies", 10, 16, "policy/v1beta1"),
+	deprecatedAPI("extensions/v1beta1", "Ingress", "ingresses", 14, 22, "networking.k8s.io/v1beta1"),
+	deprecatedAPI("batch/v2alpha1", "CronJob", "cronjobs", 8, 21, "batch/v1beta1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1alpha1", "Role", "roles", 8, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1alpha1", "RoleBinding", "rolebindings", 8, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1alpha1", "ClusterRole", "clusterroles", 8, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1alpha1", "ClusterRoleBinding", "clusterrolebindings", 8, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1beta1", "Role", "roles", 17, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1beta1", "RoleBinding", "rolebindings", 17, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1beta1", "ClusterRole", "clusterroles", 17, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", "clusterrolebindings", 17, 22, "rbac.authorization.k8s.io/v1"),
+	deprecatedAPI("apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", "customresourcedefinitions", 16, 22, "apiextensions.k8s.io/v1"),
+}
+
+// DeprecationReport lists the objects on the cluster that were created with
+// API versions that are deprecated or removed in the target Kubernetes version
+type DeprecationReport struct {
+	// TargetVersion is the Kubernetes version the cluster is upgraded to
+	TargetVersion string `json:"targetVersion"`
+	// Findings are the objects that use deprecated or removed APIs
+	Findings []DeprecationFinding `json:"findings"`
+}
+
+// DeprecationFinding is an object that uses a deprecated or removed API
+type DeprecationFinding struct {
+	Kind         string `json:"kind"`
+	Namespace    string `json:"namespace,omitempty"`
+	Name         string `json:"name"`
+	APIVersion   string `json:"apiVersion"`
+	Replacement  string `json:"replacement"`
+	DeprecatedIn string `json:"deprecatedIn"`
+	RemovedIn    string `json:"removedIn"`
+	// Removed is true when the API is no longer served by the target version
+	Removed bool `json:"removed"`
+}
+
+// Removed returns the findings that use APIs removed in the target version
+func (r DeprecationReport) Removed() []DeprecationFinding {
+	var removed []DeprecationFinding
+	for _, f := range r.Findings {
+		if f.Removed {
+			removed = append(removed, f)
+		}
+	}
+	return removed
+}
+
+// Write the report to the directory as JSON, and return the path of the file
+func (r DeprecationReport) Write(dir string) (string, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling deprecation report: %v", err)
+	}
+	file := filepath.Join(dir, deprecationReportFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return "", fmt.Errorf("error writing deprecation report to %s: %v", file, err)
+	}
+	return file, nil
+}
+
+// DeprecationScanner finds the objects on a cluster that were created with
+// deprecated API versions, using kubectl. The API version of an object is
+// obtained from the last configuration applied with kubectl, so objects that
+// were not created with "kubectl apply" are not reported.
+type DeprecationScanner struct {
+	// BinaryPath is the path to the kubectl binary
+	BinaryPath string
+	// Kubeconfig is the path to the kubeconfig file used to access the cluster
+	Kubeconfig string
+
+	// Hook for testing purposes, runs kubectl with the arguments and returns
+	// its output
+	exec func(args ...string) (string, error)
+}
+
+type scannedObjectList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `json:"name"`
+			Namespace   string            `json:"namespace"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+func (s DeprecationScanner) run(args ...string) (string, error) {
+	args = append(args, "--kubeconfig", s.Kubeconfig)
+	logging.Debug("running kubectl", "args", args)
+	if s.exec != nil {
+		return s.exec(args...)
+	}
+	out, err := exec.Command(s.BinaryPath, args...).CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// Scan the cluster for objects that use APIs that are deprecated or removed
+// in the target Kubernetes version
+func (s DeprecationScanner) Scan(targetVersion string) (*DeprecationReport, error) {
+	target, err := parseVersion(targetVersion)
+	if err != nil {
+		return nil, err
+	}
+	// only compare the minor versions
+	target = semver.Version{Major: target.Major, Minor: target.Minor}
+	apis := map[string][]DeprecatedAPI{}
+	var resources []string
+	for _, api := range deprecatedAPIs {
+		if target.LT(api.DeprecatedIn) {
+			continue
+		}
+		if _, ok := apis[api.Resource]; !ok {
+			resources = append(resources, api.Resource)
+		}
+		apis[api.Resource] = append(apis[api.Resource], api)
+	}
+	sort.Strings(resources)
+
+	report := &DeprecationReport{TargetVersion: targetVersion, Findings: []DeprecationFinding{}}
+	for _, resource := range resources {
+		out, err := s.run("get", resource, "--all-namespaces", "-o", "json")
+		if err != nil {
+			if strings.Contains(out, "doesn't have a resource type") {
+				// the resource is not served by the current version of the cluster
+				continue
+			}
+			return nil, fmt.Errorf("error listing %s: %v", resource, err)
+		}
+		var list scannedObjectList
+		if err := json.Unmarshal([]byte(out), &list); err != nil {
+			return nil, fmt.Errorf("error unmarshaling %s: %v", resource, err)
+		}
+		for _, item := range list.Items {
+			lastApplied, ok := item.Metadata.Annotations[lastAppliedConfigAnnotation]
+			if !ok {
+				continue
+			}
+			var typeMeta struct {
+				APIVersion string `json:"apiVersion"`
+				Kind       string `json:"kind"`
+			}
+			if err := json.Unmarshal([]byte(lastApplied), &typeMeta); err != nil {
+				logging.Debug("ignoring invalid last applied configuration", "resource", resource, "name", item.Metadata.Name, "error", err)
+				continue
+			}
+			for _, api := range apis[resource] {
+				if api.APIVersion != typeMeta.APIVersion || api.Kind != typeMeta.Kind {
+					continue
+				}
+				report.Findings = append(report.Findings, DeprecationFinding{
+					Kind:         api.Kind,
+					Namespace:    item.Metadata.Namespace,
+					Name:         item.Metadata.Name,
+					APIVersion:   api.APIVersion,
+					Replacement:  api.Replacement,
+					DeprecatedIn: fmt.Sprintf("v%d.%d", api.DeprecatedIn.Major, api.DeprecatedIn.Minor),
+					RemovedIn:    fmt.Sprintf("v%d.%d", api.RemovedIn.Major, api.RemovedIn.Minor),
+					Removed:      target.GTE(api.RemovedIn),
+				})
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,113 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func objectList(items ...string) string {
+	return `{"items": [` + strings.Join(items, ",") + `]}`
+}
+
+func appliedObject(namespace, name, apiVersion, kind string) string {
+	lastApplied := fmt.Sprintf(`{\"apiVersion\":\"%s\",\"kind\":\"%s\"}`, apiVersion, kind)
+	return fmt.Sprintf(`{"metadata": {"name": %q, "namespace": %q, "annotations": {%q: "%s"}}}`, name, namespace, lastAppliedConfigAnnotation, lastApplied)
+}
+
+func TestDeprecationScannerScan(t *testing.T) {
+	outputs := map[string]string{
+		"deployments": objectList(
+			appliedObject("default", "old", "extensions/v1beta1", "Deployment"),
+			appliedObject("default", "new", "apps/v1", "Deployment"),
+			`{"metadata": {"name": "not-applied", "namespace": "default"}}`,
+		),
+		"ingresses": objectList(appliedObject("web", "frontend", "extensions/v1beta1", "Ingress")),
+	}
+	var listed []string
+	s := DeprecationScanner{
+		Kubeconfig: "kubeconfig",
+		exec: func(args ...string) (string, error) {
+			resource := args[1]
+			listed = append(listed, resource)
+			if args[len(args)-1] != "kubeconfig" {
+				t.Errorf("expected the kubeconfig to be used, got args %v", args)
+			}
+			if out, ok := outputs[resource]; ok {
+				return out, nil
+			}
+			return objectList(), nil
+		},
+	}
+
+	report, err := s.Scan("v1.10.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", report.Findings)
+	}
+	f := report.Findings[0]
+	if f.Kind != "Deployment" || f.Namespace != "default" || f.Name != "old" || f.Replacement != "apps/v1" || f.Removed {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	for _, r := range listed {
+		if r == "ingresses" {
+			t.Errorf("ingresses are not deprecated in v1.10, but were scanned")
+		}
+	}
+
+	report, err = s.Scan("v1.16.0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", report.Findings)
+	}
+	if removed := report.Removed(); len(removed) != 1 || removed[0].Name != "old" {
+		t.Errorf("expected the deployment to use a removed API, got %+v", removed)
+	}
+}
+
+func TestDeprecationScannerScanErrors(t *testing.T) {
+	s := DeprecationScanner{
+		exec: func(args ...string) (string, error) {
+			if args[1] == "podsecuritypolicies" {
+				return `error: the server doesn't have a resource type "podsecuritypolicies"`, errors.New("exit status 1")
+			}
+			return objectList(), nil
+		},
+	}
+	if _, err := s.Scan("v1.10.5"); err != nil {
+		t.Errorf("expected resources that are not served to be ignored, got error %v", err)
+	}
+
+	s.exec = func(args ...string) (string, error) {
+		return "Unable to connect to the server", errors.New("exit status 1")
+	}
+	if _, err := s.Scan("v1.10.5"); err == nil {
+		t.Errorf("expected an error when the cluster is not reachable")
+	}
+	if _, err := s.Scan("foo"); err == nil {
+		t.Errorf("expected an error with an invalid version")
+	}
+}
+
+func TestDeprecationReportWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deprecation-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	r := DeprecationReport{TargetVersion: "v1.10.5"}
+	file, err := r.Write(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("expected the report to be written: %v", err)
+	}
+}
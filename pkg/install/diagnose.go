@@ -0,0 +1,113 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// the tasks whose failures are not diagnosed. The pre-flight checks are
+// expected to fail on nodes that are not ready to be installed, and a failure
+// to collect diagnostics is not diagnosed again.
+var undiagnosedTasks = []string{"diagnose", "preflight", "add-node-preflight", "upgrade-preflight", "copy-inspector"}
+
+// diagnoseFailure collects diagnostics from the hosts after the task failed,
+// and returns the directory where they are stored. The nodes targeted by the
+// task are diagnosed when no hosts are given. A failure to collect the
+// diagnostics is reported, and does not change the outcome of the task.
+func (ae *ansibleExecutor) diagnoseFailure(t task, hosts []string) string {
+	if util.Contains(t.name, undiagnosedTasks) {
+		return ""
+	}
+	if len(hosts) == 0 {
+		hosts = t.limit
+	}
+	util.PrintHeader(ae.stdout, "Collecting Diagnostics", '=')
+	dir, err := ae.diagnoseNodes(t.plan, hosts...)
+	if err != nil {
+		util.PrettyPrintWarn(ae.stdout, "Could not collect diagnostics from all the nodes: %v", err)
+	}
+	// diagnostics may have been collected from some of the nodes
+	if _, serr := os.Stat(dir); serr != nil {
+		return ""
+	}
+	util.PrettyPrintOk(ae.stdout, "Collected diagnostics in %s", dir)
+	return dir
+}
+
+// PerformanceProfile configures the performance collectors of the
+// diagnostics. The collectors sample the etcd metrics, the request latencies
+// of the API server, the CPU, memory and IO usage of the nodes and the
+// conntrack table over the window, so that the snapshot can be used to size
+// the cluster.
+type PerformanceProfile struct {
+	// Window is the duration of the sampling. The collectors are not run
+	// when zero.
+	Window time.Duration
+	// Interval is the time between two samples. Defaults to 5 seconds.
+	Interval time.Duration
+}
+
+func (p *PerformanceProfile) validate() error {
+	if p.Window == 0 {
+		return nil
+	}
+	if p.Window < 0 {
+		return fmt.Errorf("the performance window must be positive, got %s", p.Window)
+	}
+	if p.Interval == 0 {
+		p.Interval = 5 * time.Second
+	}
+	if p.Interval < time.Second {
+		return fmt.Errorf("the performance interval must be at least 1s, got %s", p.Interval)
+	}
+	if p.Window < p.Interval {
+		return fmt.Errorf("the performance window %s is shorter than the interval %s", p.Window, p.Interval)
+	}
+	return nil
+}
+
+// failedHostsRecorder records the hosts on which a task failed, or that were
+// unreachable. Failures that are ignored by the playbook are left out.
+type failedHostsRecorder struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func newFailedHostsRecorder() *failedHostsRecorder {
+	return &failedHostsRecorder{}
+}
+
+func (r *failedHostsRecorder) observe(e ansible.Event) {
+	switch event := e.(type) {
+	case *ansible.RunnerFailedEvent:
+		if !event.IgnoreErrors {
+			r.add(event.Host)
+		}
+	case *ansible.RunnerItemFailedEvent:
+		if !event.IgnoreErrors {
+			r.add(event.Host)
+		}
+	case *ansible.RunnerUnreachableEvent:
+		r.add(event.Host)
+	}
+}
+
+func (r *failedHostsRecorder) add(host string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !util.Contains(host, r.hosts) {
+		r.hosts = append(r.hosts, host)
+	}
+}
+
+// finish returns the hosts that failed, in the order of their first failure
+func (r *failedHostsRecorder) finish() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts
+}
@@ -0,0 +1,203 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+// failingRunner fails the first playbook on a host, and records the limit of
+// the diagnostics playbook that follows
+type failingRunner struct {
+	failHost      string
+	diagnosed     []string
+	diagnosticsIn string
+	catalog       ansible.ClusterCatalog
+	sent          chan struct{}
+	err           error
+}
+
+func (r *failingRunner) StartPlaybook(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog) (<-chan ansible.Event, error) {
+	return r.StartPlaybookOnNode(playbook, inv, cc)
+}
+
+func (r *failingRunner) StartPlaybookOnNode(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog, nodes ...string) (<-chan ansible.Event, error) {
+	r.err = nil
+	r.sent = make(chan struct{})
+	var events []ansible.Event
+	if playbook == "diagnose-nodes.yaml" {
+		r.diagnosed = nodes
+		r.diagnosticsIn = cc.DiagnosticsDirectory
+		r.catalog = cc
+		if err := os.MkdirAll(cc.DiagnosticsDirectory, 0700); err != nil {
+			return nil, err
+		}
+	} else {
+		ignored := &ansible.RunnerFailedEvent{}
+		ignored.Host = "worker2"
+		ignored.IgnoreErrors = true
+		failed := &ansible.RunnerFailedEvent{}
+		failed.Host = r.failHost
+		events = append(events, ignored, failed)
+		r.err = errors.New("exit status 2")
+	}
+	// the end of the playbook is received once the other events were observed
+	events = append(events, &ansible.PlaybookEndEvent{})
+	out := make(chan ansible.Event)
+	go func() {
+		defer close(r.sent)
+		defer close(out)
+		for _, e := range events {
+			out <- e
+		}
+	}()
+	return out, nil
+}
+
+func (r *failingRunner) WaitPlaybook() error {
+	<-r.sent
+	return r.err
+}
+
+func (r *failingRunner) Stop() error { return nil }
+
+func TestExecuteDiagnosesFailedHosts(t *testing.T) {
+	diagnosticsDir := mustGetTempDir(t)
+	runner := &failingRunner{failHost: "worker1"}
+	e := ansibleExecutor{
+		options: ExecutorOptions{
+			RunsDirectory:      mustGetTempDir(t),
+			DiagnosticsDirecty: diagnosticsDir,
+			DiagnoseOnFailure:  true,
+		},
+		stdout:              &bytes.Buffer{},
+		consoleOutputFormat: ansible.RawFormat,
+		certsDir:            mustGetTempDir(t),
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return runner, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+	}
+	plan := Plan{
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01", InternalIP: "10.10.2.20"}},
+		},
+		Cluster: Cluster{
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+	}
+	_, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", plan: plan, explainer: e.defaultExplainer()})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !reflect.DeepEqual(runner.diagnosed, []string{"worker1"}) {
+		t.Errorf("expected the diagnostics to be limited to the failed host, got %v", runner.diagnosed)
+	}
+	if !strings.HasPrefix(runner.diagnosticsIn, diagnosticsDir) || !strings.Contains(err.Error(), runner.diagnosticsIn) {
+		t.Errorf("expected the error to contain the diagnostics directory %q, got %v", runner.diagnosticsIn, err)
+	}
+}
+
+func TestExecuteDoesNotDiagnosePreflight(t *testing.T) {
+	runner := &failingRunner{failHost: "worker1"}
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t), DiagnoseOnFailure: true},
+		stdout:              &bytes.Buffer{},
+		consoleOutputFormat: ansible.RawFormat,
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return runner, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+	}
+	if _, err := e.execute(task{name: "preflight", playbook: "preflight.yaml", explainer: e.defaultExplainer()}); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if runner.diagnosed != nil {
+		t.Errorf("expected the pre-flight checks not to be diagnosed, got %v", runner.diagnosed)
+	}
+}
+
+func TestDiagnoseNodesPerformanceProfile(t *testing.T) {
+	tests := []struct {
+		profile  PerformanceProfile
+		enabled  bool
+		window   int
+		interval int
+	}{
+		{},
+		{
+			profile:  PerformanceProfile{Window: 10 * time.Minute, Interval: 10 * time.Second},
+			enabled:  true,
+			window:   600,
+			interval: 10,
+		},
+	}
+	for _, test := range tests {
+		runner := &failingRunner{}
+		e := ansibleExecutor{
+			options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t), DiagnosticsDirecty: mustGetTempDir(t), PerformanceProfile: test.profile},
+			stdout:              &bytes.Buffer{},
+			consoleOutputFormat: ansible.RawFormat,
+			renderCache:         &renderCache{},
+			certsDir:            mustGetTempDir(t),
+			runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+				return runner, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+			},
+		}
+		plan := Plan{
+			Master: MasterNodeGroup{
+				Nodes: []Node{{Host: "master01", InternalIP: "10.10.2.20"}},
+			},
+			Cluster: Cluster{
+				Version: "v1.10.3",
+				Networking: NetworkConfig{
+					ServiceCIDRBlock: "10.0.0.0/16",
+				},
+			},
+		}
+		if _, err := e.diagnoseNodes(plan); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		perf := runner.catalog.DiagnosticsPerformance
+		if perf.Enabled != test.enabled || perf.WindowSeconds != test.window || perf.IntervalSeconds != test.interval {
+			t.Errorf("%+v: unexpected performance collectors in the catalog %+v", test.profile, perf)
+		}
+	}
+}
+
+func TestPerformanceProfileValidate(t *testing.T) {
+	tests := []struct {
+		profile  PerformanceProfile
+		valid    bool
+		interval time.Duration
+	}{
+		{valid: true},
+		{profile: PerformanceProfile{Window: time.Minute}, valid: true, interval: 5 * time.Second},
+		{profile: PerformanceProfile{Window: time.Minute, Interval: 30 * time.Second}, valid: true, interval: 30 * time.Second},
+		{profile: PerformanceProfile{Window: -time.Minute}},
+		{profile: PerformanceProfile{Window: time.Minute, Interval: 100 * time.Millisecond}},
+		{profile: PerformanceProfile{Window: 10 * time.Second, Interval: time.Minute}},
+	}
+	for _, test := range tests {
+		p := test.profile
+		err := p.validate()
+		if test.valid && err != nil {
+			t.Errorf("%+v: unexpected error: %v", test.profile, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%+v: expected an error", test.profile)
+		}
+		if test.valid && p.Interval != test.interval {
+			t.Errorf("%+v: expected the interval %s, got %s", test.profile, test.interval, p.Interval)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// dnsProbePrefix is the prefix of the lines in the logs of the DNS validation
+// pod that contain the result of a probe
+const dnsProbePrefix = "dns-probe"
+
+// A DNSProbe is a name that is resolved from a pod running on the cluster.
+// The Host can refer to the $POD_NAMESPACE, $POD_IP and $POD_IP_DASHED shell
+// variables, which are set to the namespace and IP of the pod.
+type DNSProbe struct {
+	// Name of the probe, used when reporting the result
+	Name string
+	// Host is the name that is resolved
+	Host string
+}
+
+// DefaultDNSProbes returns the probes that verify the resolution of service,
+// pod and external names. The external name is not resolved in disconnected
+// installations.
+func DefaultDNSProbes(p *Plan) []DNSProbe {
+	probes := []DNSProbe{
+		{Name: "Service name", Host: "kubernetes.default.svc.cluster.local"},
+		{Name: "Pod name", Host: "$POD_IP_DASHED.$POD_NAMESPACE.pod.cluster.local"},
+	}
+	if !p.Cluster.DisconnectedInstallation {
+		probes = append(probes, DNSProbe{Name: "External name", Host: "kubernetes.io"})
+	}
+	return probes
+}
+
+// DNSProbeResult is the result of resolving a DNS probe
+type DNSProbeResult struct {
+	Probe    DNSProbe
+	Resolved bool
+	// Latency is the time it took to resolve the name, or to fail
+	Latency time.Duration
+}
+
+// dnsValidationClient is the Kubernetes client used by the DNS validation
+type dnsValidationClient interface {
+	data.ManifestApplier
+	data.NamespaceDeleter
+	data.PodGetter
+	data.PodLogsGetter
+}
+
+// DNSValidation verifies that the cluster DNS resolves names from a pod
+// running on the cluster, and reports the latency of every lookup.
+type DNSValidation struct {
+	Out io.Writer
+	// Probes are the names that are resolved. Defaults to DefaultDNSProbes.
+	Probes []DNSProbe
+	// MaxLatency is the latency above which a lookup is reported with a
+	// warning. No warnings are reported when zero.
+	MaxLatency time.Duration
+	// Timeout is the maximum time to wait for the validation pod to complete
+	Timeout time.Duration
+	// PollInterval is the time between checks for the completion of the pod
+	PollInterval time.Duration
+
+	// Hooks for testing purposes
+	newClient func(*Plan) (dnsValidationClient, error)
+	now       func() time.Time
+}
+
+// Run resolves the probes from a pod running on the cluster. All the probes
+// are reported, and an error is returned if any of them could not be resolved.
+func (v DNSValidation) Run(p *Plan) ([]DNSProbeResult, error) {
+	probes := v.Probes
+	if len(probes) == 0 {
+		probes = DefaultDNSProbes(p)
+	}
+	client, err := v.client(p)
+	if err != nil {
+		return nil, err
+	}
+	now := v.now
+	if now == nil {
+		now = time.Now
+	}
+
+	// namespaces are deleted asynchronously, so use a new one on every run
+	namespace := fmt.Sprintf("kismatic-dns-validation-%d", now().Unix())
+	defer func() {
+		if err := client.DeleteNamespace(namespace); err != nil {
+			util.PrettyPrintWarn(v.Out, "Could not clean up the DNS validation namespace %q: %v", namespace, err)
+		}
+	}()
+	if err := client.Apply(dnsValidationManifest(namespace, smokeTestImage(p, "busybox:latest"), probes)); err != nil {
+		return nil, err
+	}
+	if err := v.waitForPod(client, namespace, now); err != nil {
+		return nil, err
+	}
+	logs, err := client.GetPodLogs(namespace, "dns-validation")
+	if err != nil {
+		return nil, err
+	}
+	results, err := parseDNSProbeResults(logs, probes)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []string
+	for _, r := range results {
+		switch {
+		case !r.Resolved:
+			failed = append(failed, r.Probe.Name)
+			util.PrettyPrintErr(v.Out, "%s %q could not be resolved (%v)", r.Probe.Name, r.Probe.Host, r.Latency)
+		case v.MaxLatency > 0 && r.Latency > v.MaxLatency:
+			util.PrettyPrintWarn(v.Out, "%s %q resolved in %v, which is above %v", r.Probe.Name, r.Probe.Host, r.Latency, v.MaxLatency)
+		default:
+			util.PrettyPrintOk(v.Out, "%s %q resolved in %v", r.Probe.Name, r.Probe.Host, r.Latency)
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("the cluster DNS could not resolve: %s", strings.Join(failed, ", "))
+	}
+	return results, nil
+}
+
+func (v DNSValidation) client(p *Plan) (dnsValidationClient, error) {
+	if v.newClient != nil {
+		return v.newClient(p)
+	}
+	// Use the first master node for running kubectl
+	client, err := p.GetSSHClient(p.Master.Nodes[0].Host)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSH client: %v", err)
+	}
+	return data.RemoteKubectl{SSHClient: client}, nil
+}
+
+func (v DNSValidation) waitForPod(client dnsValidationClient, namespace string, now func() time.Time) error {
+	deadline := now().Add(v.Timeout)
+	for {
+		pod, err := client.GetPod(namespace, "dns-validation")
+		if err != nil {
+			return err
+		}
+		switch pod.Status.Phase {
+		case data.PodSucceeded:
+			return nil
+		case data.PodFailed:
+			return fmt.Errorf("the DNS validation pod failed: %s", pod.Status.Message)
+		}
+		if !now().Before(deadline) {
+			return fmt.Errorf("timed out after %v waiting for the DNS validation pod to complete", v.Timeout)
+		}
+		time.Sleep(v.PollInterval)
+	}
+}
+
+// dnsValidationManifest returns the manifest of the namespace and the pod
+// that resolve the probes. The pod prints one line per probe, with the index
+// of the probe, whether it was resolved, and the elapsed seconds.
+func dnsValidationManifest(namespace, image string, probes []DNSProbe) string {
+	script := &bytes.Buffer{}
+	fmt.Fprintln(script, "POD_IP_DASHED=$(echo $POD_IP | tr . -)")
+	fmt.Fprintf(script, "probe() { if /bin/time -f %%e -o /tmp/elapsed nslookup \"$2\" >/dev/null 2>&1; then r=ok; else r=failed; fi; echo \"%s $1 $r $(tail -n 1 /tmp/elapsed)\"; }\n", dnsProbePrefix)
+	for i, probe := range probes {
+		fmt.Fprintf(script, "probe %d \"%s\"\n", i, probe.Host)
+	}
+	return fmt.Sprintf(`{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "apiVersion": "v1",
+      "kind": "Namespace",
+      "metadata": {"name": %[1]q}
+    },
+    {
+      "apiVersion": "v1",
+      "kind": "Pod",
+      "metadata": {"name": "dns-validation", "namespace": %[1]q},
+      "spec": {
+        "restartPolicy": "Never",
+        "containers": [{
+          "name": "busybox",
+          "image": %[2]q,
+          "command": ["sh", "-c", %[3]q],
+          "env": [
+            {"name": "POD_NAMESPACE", "valueFrom": {"fieldRef": {"fieldPath": "metadata.namespace"}}},
+            {"name": "POD_IP", "valueFrom": {"fieldRef": {"fieldPath": "status.podIP"}}}
+          ]
+        }]
+      }
+    }
+  ]
+}`, namespace, image, script.String())
+}
+
+// parseDNSProbeResults parses the logs of the DNS validation pod
+func parseDNSProbeResults(logs string, probes []DNSProbe) ([]DNSProbeResult, error) {
+	results := make([]DNSProbeResult, len(probes))
+	found := make([]bool, len(probes))
+	for _, line := range strings.Split(logs, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != dnsProbePrefix {
+			continue
+		}
+		i, err := strconv.Atoi(fields[1])
+		if err != nil || i < 0 || i >= len(probes) {
+			return nil, fmt.Errorf("unexpected probe in the DNS validation output: %q", line)
+		}
+		seconds, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected latency in the DNS validation output: %q", line)
+		}
+		results[i] = DNSProbeResult{
+			Probe:    probes[i],
+			Resolved: fields[2] == "ok",
+			Latency:  time.Duration(seconds * float64(time.Second)),
+		}
+		found[i] = true
+	}
+	for i, ok := range found {
+		if !ok {
+			return nil, fmt.Errorf("the DNS validation output does not contain the result of %q: %s", probes[i].Name, logs)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,107 @@
+package install
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeDNSValidationClient struct {
+	fakeSmokeTestClient
+	logs string
+}
+
+func (c *fakeDNSValidationClient) GetPodLogs(namespace, name string) (string, error) {
+	return c.logs, nil
+}
+
+func testDNSValidation(out *bytes.Buffer, client *fakeDNSValidationClient) DNSValidation {
+	return DNSValidation{
+		Out:          out,
+		MaxLatency:   100 * time.Millisecond,
+		Timeout:      50 * time.Millisecond,
+		PollInterval: time.Millisecond,
+		newClient:    func(*Plan) (dnsValidationClient, error) { return client, nil },
+	}
+}
+
+func TestDNSValidation(t *testing.T) {
+	client := &fakeDNSValidationClient{
+		fakeSmokeTestClient: fakeSmokeTestClient{podPhases: map[string]data.PodPhase{"dns-validation": data.PodSucceeded}},
+		logs:                "dns-probe 0 ok 0.01\ndns-probe 1 ok 0.25\ndns-probe 2 ok 0.00\n",
+	}
+	out := &bytes.Buffer{}
+	results, err := testDNSValidation(out, client).Run(smokeTestPlan())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %+v", results)
+	}
+	if results[0].Probe.Name != "Service name" || !results[0].Resolved || results[0].Latency != 10*time.Millisecond {
+		t.Errorf("unexpected result: %+v", results[0])
+	}
+	if results[1].Latency != 250*time.Millisecond {
+		t.Errorf("unexpected latency: %v", results[1].Latency)
+	}
+	if !strings.Contains(out.String(), "above 100ms") {
+		t.Errorf("expected a warning for the slow lookup, got %s", out.String())
+	}
+	if len(client.applied) != 1 || !strings.Contains(client.applied[0], "nslookup") || !strings.Contains(client.applied[0], "kubernetes.default.svc.cluster.local") {
+		t.Errorf("unexpected manifest: %v", client.applied)
+	}
+	if len(client.deletedNamespaces) != 1 {
+		t.Errorf("expected the namespace to be cleaned up, got %v", client.deletedNamespaces)
+	}
+}
+
+func TestDNSValidationFailedProbe(t *testing.T) {
+	client := &fakeDNSValidationClient{
+		fakeSmokeTestClient: fakeSmokeTestClient{podPhases: map[string]data.PodPhase{"dns-validation": data.PodSucceeded}},
+		logs:                "dns-probe 0 ok 0.01\ndns-probe 1 failed 5.02\n",
+	}
+	v := testDNSValidation(&bytes.Buffer{}, client)
+	v.Probes = []DNSProbe{{Name: "service", Host: "a"}, {Name: "external", Host: "b"}}
+	results, err := v.Run(smokeTestPlan())
+	if err == nil || !strings.Contains(err.Error(), "external") {
+		t.Errorf("expected an error for the failed probe, got %v", err)
+	}
+	if len(results) != 2 || results[1].Resolved {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestDNSValidationPodDoesNotComplete(t *testing.T) {
+	client := &fakeDNSValidationClient{
+		fakeSmokeTestClient: fakeSmokeTestClient{podPhases: map[string]data.PodPhase{"dns-validation": data.PodPending}},
+	}
+	if _, err := testDNSValidation(&bytes.Buffer{}, client).Run(smokeTestPlan()); err == nil {
+		t.Errorf("expected an error when the pod does not complete")
+	}
+	if len(client.deletedNamespaces) != 1 {
+		t.Errorf("expected the namespace to be cleaned up, got %v", client.deletedNamespaces)
+	}
+}
+
+func TestDefaultDNSProbesDisconnected(t *testing.T) {
+	p := &Plan{}
+	if len(DefaultDNSProbes(p)) != 3 {
+		t.Errorf("expected the external name to be resolved")
+	}
+	p.Cluster.DisconnectedInstallation = true
+	for _, probe := range DefaultDNSProbes(p) {
+		if probe.Name == "External name" {
+			t.Errorf("expected the external name to not be resolved in disconnected installations")
+		}
+	}
+}
+
+func TestParseDNSProbeResultsMissing(t *testing.T) {
+	probes := []DNSProbe{{Name: "a"}, {Name: "b"}}
+	if _, err := parseDNSProbeResults("dns-probe 0 ok 0.01\nsome other output\n", probes); err == nil {
+		t.Errorf("expected an error when a probe result is missing")
+	}
+}
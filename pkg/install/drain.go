@@ -0,0 +1,267 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// The policies for draining a node when pod disruption budgets do not allow
+// the eviction of its pods
+const (
+	// DrainPolicyWait waits for the budgets to allow the eviction
+	DrainPolicyWait = "wait"
+	// DrainPolicySkipNode does not upgrade the node
+	DrainPolicySkipNode = "skip-node"
+	// DrainPolicyOverride deletes the pods instead of evicting them
+	DrainPolicyOverride = "override"
+)
+
+// DrainPolicies returns the supported drain policies
+func DrainPolicies() []string {
+	return []string{DrainPolicyWait, DrainPolicySkipNode, DrainPolicyOverride}
+}
+
+type disruptionBudgetClient interface {
+	data.PodLister
+	data.PodDisruptionBudgetLister
+	data.ReplicaSetGetter
+	data.NodeCordoner
+	Delete(kind, namespace, name string) error
+}
+
+// blockedEviction is a pod that cannot be evicted from a node, because a pod
+// disruption budget that selects it does not allow any disruptions
+type blockedEviction struct {
+	node      string
+	namespace string
+	pod       string
+	// owner is the workload that owns the pod
+	owner   string
+	budget  string
+	healthy int32
+	desired int32
+}
+
+func (b blockedEviction) String() string {
+	return fmt.Sprintf("Pod \"%s/%s\" of %s on node %q is protected by PodDisruptionBudget \"%s/%s\", which requires %d healthy pods and has %d",
+		b.namespace, b.pod, b.owner, b.node, b.namespace, b.budget, b.desired, b.healthy)
+}
+
+// disruptionBudgetChecker finds the pods that cannot be evicted when draining
+// nodes during an online upgrade, and applies the drain policy instead of
+// letting the drain hang until it times out.
+type disruptionBudgetChecker struct {
+	out       io.Writer
+	policy    string
+	newClient func(*Plan) (disruptionBudgetClient, error)
+	// timeout for the budgets to allow the eviction, when the policy is to wait
+	timeout      time.Duration
+	pollInterval time.Duration
+	now          func() time.Time
+	sleep        func(time.Duration)
+}
+
+func newDisruptionBudgetChecker(out io.Writer, policy string, timeout time.Duration) *disruptionBudgetChecker {
+	return &disruptionBudgetChecker{
+		out:    out,
+		policy: policy,
+		newClient: func(p *Plan) (disruptionBudgetClient, error) {
+			// Use the first master node for running kubectl
+			client, err := p.GetSSHClient(p.Master.Nodes[0].Host)
+			if err != nil {
+				return nil, fmt.Errorf("error getting SSH client: %v", err)
+			}
+			return data.RemoteKubectl{SSHClient: client}, nil
+		},
+		timeout:      timeout,
+		pollInterval: 10 * time.Second,
+		now:          time.Now,
+		sleep:        time.Sleep,
+	}
+}
+
+// check applies the drain policy to the nodes that are about to be drained,
+// and returns the nodes that should be upgraded
+func (c *disruptionBudgetChecker) check(p *Plan, nodes []ListableNode) ([]ListableNode, error) {
+	var hosts []string
+	for _, n := range nodes {
+		// etcd nodes are not drained
+		if !(len(n.Roles) == 1 && n.Roles[0] == "etcd") {
+			hosts = append(hosts, n.Node.Host)
+		}
+	}
+	if len(hosts) == 0 {
+		return nodes, nil
+	}
+	client, err := c.newClient(p)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := findBlockedEvictions(client, hosts)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocked) == 0 {
+		return nodes, nil
+	}
+	util.PrintHeader(c.out, "Pod Disruption Budgets", '=')
+	for _, b := range blocked {
+		util.PrettyPrintWarn(c.out, "%s", b)
+	}
+
+	switch c.policy {
+	case DrainPolicySkipNode:
+		return c.skipNodes(nodes, blocked)
+	case DrainPolicyOverride:
+		return nodes, c.deletePods(client, blocked)
+	default:
+		return nodes, c.wait(client, hosts)
+	}
+}
+
+// wait until the budgets allow the eviction of the pods on the hosts
+func (c *disruptionBudgetChecker) wait(client disruptionBudgetClient, hosts []string) error {
+	deadline := c.now().Add(c.timeout)
+	for {
+		c.sleep(c.pollInterval)
+		blocked, err := findBlockedEvictions(client, hosts)
+		if err != nil {
+			return err
+		}
+		if len(blocked) == 0 {
+			util.PrettyPrintOk(c.out, "The pod disruption budgets allow the eviction of the pods")
+			return nil
+		}
+		if c.now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for pod disruption budgets to allow the eviction of %d pods. Use the %q or %q drain policy to upgrade the nodes anyway", c.timeout, len(blocked), DrainPolicySkipNode, DrainPolicyOverride)
+		}
+	}
+}
+
+// skipNodes removes the nodes with blocked evictions. Etcd and master nodes
+// cannot be skipped.
+func (c *disruptionBudgetChecker) skipNodes(nodes []ListableNode, blocked []blockedEviction) ([]ListableNode, error) {
+	blockedHosts := map[string]bool{}
+	for _, b := range blocked {
+		blockedHosts[b.node] = true
+	}
+	var toUpgrade []ListableNode
+	for _, n := range nodes {
+		if !blockedHosts[n.Node.Host] {
+			toUpgrade = append(toUpgrade, n)
+			continue
+		}
+		if contains("etcd", n.Roles) || contains("master", n.Roles) {
+			return nil, fmt.Errorf("node %q cannot be skipped, as it is an etcd or master node", n.Node.Host)
+		}
+		util.PrettyPrintWarn(c.out, "Skipping node %q. Run the upgrade again to upgrade it once its pods can be evicted", n.Node.Host)
+	}
+	return toUpgrade, nil
+}
+
+// deletePods cordons the nodes, so that the pods are not rescheduled on them,
+// and deletes the pods that cannot be evicted
+func (c *disruptionBudgetChecker) deletePods(client disruptionBudgetClient, blocked []blockedEviction) error {
+	cordoned := map[string]bool{}
+	for _, b := range blocked {
+		if !cordoned[b.node] {
+			if err := client.Cordon(b.node); err != nil {
+				return err
+			}
+			cordoned[b.node] = true
+		}
+		if err := client.Delete("pod", b.namespace, b.pod); err != nil {
+			return err
+		}
+		util.PrettyPrintWarn(c.out, "Deleted pod \"%s/%s\", overriding PodDisruptionBudget \"%s/%s\"", b.namespace, b.pod, b.namespace, b.budget)
+	}
+	return nil
+}
+
+// findBlockedEvictions returns the pods running on the hosts that are
+// selected by a pod disruption budget that does not allow any disruptions.
+// DaemonSet and static pods are not evicted by the drain, so they are ignored.
+func findBlockedEvictions(client disruptionBudgetClient, hosts []string) ([]blockedEviction, error) {
+	budgets, err := client.ListPodDisruptionBudgets()
+	if err != nil {
+		return nil, err
+	}
+	var exhausted []data.PodDisruptionBudget
+	for _, b := range budgets.Items {
+		if b.Status.PodDisruptionsAllowed < 1 {
+			exhausted = append(exhausted, b)
+		}
+	}
+	if len(exhausted) == 0 {
+		return nil, nil
+	}
+	pods, err := client.ListPods()
+	if err != nil {
+		return nil, err
+	}
+	if pods == nil {
+		return nil, nil
+	}
+	var blocked []blockedEviction
+	for _, pod := range pods.Items {
+		if !util.Contains(pod.Spec.NodeName, hosts) || !evictedByDrain(pod) {
+			continue
+		}
+		for _, b := range exhausted {
+			if b.Namespace != pod.Namespace || !b.Spec.Selector.Matches(pod.Labels) {
+				continue
+			}
+			blocked = append(blocked, blockedEviction{
+				node:      pod.Spec.NodeName,
+				namespace: pod.Namespace,
+				pod:       pod.Name,
+				owner:     podWorkload(client, pod),
+				budget:    b.Name,
+				healthy:   b.Status.CurrentHealthy,
+				desired:   b.Status.DesiredHealthy,
+			})
+		}
+	}
+	sort.Slice(blocked, func(i, j int) bool {
+		return blocked[i].String() < blocked[j].String()
+	})
+	return blocked, nil
+}
+
+// evictedByDrain returns false for the pods that are not evicted by kubectl drain
+func evictedByDrain(pod data.Pod) bool {
+	if _, ok := pod.Annotations["kubernetes.io/config.mirror"]; ok {
+		return false
+	}
+	if pod.Status.Phase == data.PodSucceeded || pod.Status.Phase == data.PodFailed {
+		return false
+	}
+	for _, o := range pod.OwnerReferences {
+		if o.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+// podWorkload returns the workload that owns the pod. The deployment is
+// returned for the pods of a replica set that is owned by a deployment.
+func podWorkload(client data.ReplicaSetGetter, pod data.Pod) string {
+	if len(pod.OwnerReferences) == 0 {
+		return "no workload"
+	}
+	owner := pod.OwnerReferences[0]
+	if owner.Kind == "ReplicaSet" {
+		rs, err := client.GetReplicaSet(pod.Namespace, owner.Name)
+		if err == nil && rs != nil && len(rs.OwnerReferences) > 0 {
+			owner = rs.OwnerReferences[0]
+		}
+	}
+	return fmt.Sprintf("%s %q", strings.ToLower(owner.Kind), owner.Name)
+}
@@ -0,0 +1,193 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeDisruptionBudgetClient struct {
+	pods    *data.PodList
+	budgets []*data.PodDisruptionBudgetList
+	listed  int
+	rs      map[string]*data.ReplicaSet
+
+	cordoned []string
+	deleted  []string
+}
+
+func (c *fakeDisruptionBudgetClient) ListPods() (*data.PodList, error) {
+	return c.pods, nil
+}
+
+// ListPodDisruptionBudgets returns the budgets in order, and the last budgets
+// once they are exhausted
+func (c *fakeDisruptionBudgetClient) ListPodDisruptionBudgets() (*data.PodDisruptionBudgetList, error) {
+	i := c.listed
+	if i >= len(c.budgets) {
+		i = len(c.budgets) - 1
+	}
+	c.listed++
+	return c.budgets[i], nil
+}
+
+func (c *fakeDisruptionBudgetClient) GetReplicaSet(namespace, name string) (*data.ReplicaSet, error) {
+	if rs, ok := c.rs[namespace+"/"+name]; ok {
+		return rs, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (c *fakeDisruptionBudgetClient) Cordon(node string) error {
+	c.cordoned = append(c.cordoned, node)
+	return nil
+}
+
+func (c *fakeDisruptionBudgetClient) Delete(kind, namespace, name string) error {
+	c.deleted = append(c.deleted, namespace+"/"+name)
+	return nil
+}
+
+func budget(allowed int32) *data.PodDisruptionBudgetList {
+	return &data.PodDisruptionBudgetList{
+		Items: []data.PodDisruptionBudget{
+			{
+				ObjectMeta: data.ObjectMeta{Namespace: "default", Name: "web"},
+				Spec:       data.PodDisruptionBudgetSpec{Selector: &data.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+				Status:     data.PodDisruptionBudgetStatus{PodDisruptionsAllowed: allowed, CurrentHealthy: 2, DesiredHealthy: 2},
+			},
+		},
+	}
+}
+
+func webPods() *data.PodList {
+	pod := func(name, node string, owner data.OwnerReference) data.Pod {
+		p := data.Pod{}
+		p.Name = name
+		p.Namespace = "default"
+		p.Labels = map[string]string{"app": "web"}
+		p.OwnerReferences = []data.OwnerReference{owner}
+		p.Spec.NodeName = node
+		return p
+	}
+	return &data.PodList{
+		Items: []data.Pod{
+			pod("web-1", "worker01", data.OwnerReference{Kind: "ReplicaSet", Name: "web-5d8f"}),
+			pod("web-2", "worker02", data.OwnerReference{Kind: "ReplicaSet", Name: "web-5d8f"}),
+			pod("web-ds", "worker01", data.OwnerReference{Kind: "DaemonSet", Name: "web-ds"}),
+		},
+	}
+}
+
+func newTestDisruptionBudgetChecker(policy string, client *fakeDisruptionBudgetClient) (*disruptionBudgetChecker, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	now := time.Now()
+	c := &disruptionBudgetChecker{
+		out:    out,
+		policy: policy,
+		newClient: func(*Plan) (disruptionBudgetClient, error) {
+			return client, nil
+		},
+		timeout:      time.Minute,
+		pollInterval: 10 * time.Second,
+		now:          func() time.Time { return now },
+		sleep:        func(d time.Duration) { now = now.Add(d) },
+	}
+	return c, out
+}
+
+var drainTestNodes = []ListableNode{
+	{Node: Node{Host: "worker01"}, Roles: []string{"worker"}},
+	{Node: Node{Host: "worker03"}, Roles: []string{"worker"}},
+}
+
+func TestFindBlockedEvictions(t *testing.T) {
+	client := &fakeDisruptionBudgetClient{
+		pods:    webPods(),
+		budgets: []*data.PodDisruptionBudgetList{budget(0)},
+		rs: map[string]*data.ReplicaSet{
+			"default/web-5d8f": {ObjectMeta: data.ObjectMeta{OwnerReferences: []data.OwnerReference{{Kind: "Deployment", Name: "web"}}}},
+		},
+	}
+	blocked, err := findBlockedEvictions(client, []string{"worker01"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(blocked) != 1 {
+		t.Fatalf("expected 1 blocked eviction, but got %v", blocked)
+	}
+	if blocked[0].pod != "web-1" || blocked[0].owner != `deployment "web"` {
+		t.Errorf("unexpected blocked eviction: %+v", blocked[0])
+	}
+
+	client.budgets = []*data.PodDisruptionBudgetList{budget(1)}
+	client.listed = 0
+	blocked, err = findBlockedEvictions(client, []string{"worker01"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(blocked) != 0 {
+		t.Errorf("expected no blocked evictions when the budget allows a disruption, but got %v", blocked)
+	}
+}
+
+func TestDisruptionBudgetWaitPolicy(t *testing.T) {
+	client := &fakeDisruptionBudgetClient{pods: webPods(), budgets: []*data.PodDisruptionBudgetList{budget(0), budget(0), budget(1)}}
+	c, out := newTestDisruptionBudgetChecker(DrainPolicyWait, client)
+	nodes, err := c.check(&Plan{}, drainTestNodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("expected all the nodes to be upgraded, but got %v", nodes)
+	}
+	if !strings.Contains(out.String(), `PodDisruptionBudget "default/web"`) {
+		t.Errorf("expected the budget to be reported, but got:\n%s", out.String())
+	}
+
+	// the budget never allows the eviction
+	client = &fakeDisruptionBudgetClient{pods: webPods(), budgets: []*data.PodDisruptionBudgetList{budget(0)}}
+	c, _ = newTestDisruptionBudgetChecker(DrainPolicyWait, client)
+	if _, err := c.check(&Plan{}, drainTestNodes); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, but got %v", err)
+	}
+}
+
+func TestDisruptionBudgetSkipNodePolicy(t *testing.T) {
+	client := &fakeDisruptionBudgetClient{pods: webPods(), budgets: []*data.PodDisruptionBudgetList{budget(0)}}
+	c, _ := newTestDisruptionBudgetChecker(DrainPolicySkipNode, client)
+	nodes, err := c.check(&Plan{}, drainTestNodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Node.Host != "worker03" {
+		t.Errorf("expected only worker03 to be upgraded, but got %v", nodes)
+	}
+
+	master := []ListableNode{{Node: Node{Host: "worker01"}, Roles: []string{"master", "worker"}}}
+	if _, err := c.check(&Plan{}, master); err == nil {
+		t.Error("expected an error when skipping a master node")
+	}
+}
+
+func TestDisruptionBudgetOverridePolicy(t *testing.T) {
+	client := &fakeDisruptionBudgetClient{pods: webPods(), budgets: []*data.PodDisruptionBudgetList{budget(0)}}
+	c, _ := newTestDisruptionBudgetChecker(DrainPolicyOverride, client)
+	nodes, err := c.check(&Plan{}, drainTestNodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Errorf("expected all the nodes to be upgraded, but got %v", nodes)
+	}
+	if len(client.cordoned) != 1 || client.cordoned[0] != "worker01" {
+		t.Errorf("expected worker01 to be cordoned, but got %v", client.cordoned)
+	}
+	if len(client.deleted) != 1 || client.deleted[0] != "default/web-1" {
+		t.Errorf("expected pod web-1 to be deleted, but got %v", client.deleted)
+	}
+}
@@ -0,0 +1,59 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	dryRunInventoryFilename      = "inventory.ini"
+	dryRunClusterCatalogFilename = "clustercatalog.yaml"
+	dryRunPlaysFilename          = "plays.yaml"
+)
+
+// dryRunPlays is the list of plays a task would run
+type dryRunPlays struct {
+	Playbook string         `yaml:"playbook"`
+	Limit    []string       `yaml:"limit,omitempty"`
+	Plays    []resolvedPlay `yaml:"plays"`
+}
+
+// renderDryRun writes the inventory, the cluster catalog and the plays that
+// the task would run to the run directory, so that they can be reviewed
+// without running the playbook
+func (ae *ansibleExecutor) renderDryRun(t task, runDirectory string) error {
+	inventoryFile := filepath.Join(runDirectory, dryRunInventoryFilename)
+	if err := ioutil.WriteFile(inventoryFile, t.inventory.ToINI(), 0600); err != nil {
+		return fmt.Errorf("error writing inventory file to %q: %v", inventoryFile, err)
+	}
+	b, err := t.clusterCatalog.ToYAML()
+	if err != nil {
+		return err
+	}
+	catalogFile := filepath.Join(runDirectory, dryRunClusterCatalogFilename)
+	if err := ioutil.WriteFile(catalogFile, b, 0600); err != nil {
+		return fmt.Errorf("error writing cluster catalog file to %q: %v", catalogFile, err)
+	}
+	plays, err := resolvePlaybook(filepath.Join(ae.ansibleDir, "playbooks"), t.playbook)
+	if err != nil {
+		return err
+	}
+	b, err = yaml.Marshal(dryRunPlays{Playbook: t.playbook, Limit: t.limit, Plays: plays})
+	if err != nil {
+		return fmt.Errorf("error marshaling plays: %v", err)
+	}
+	playsFile := filepath.Join(runDirectory, dryRunPlaysFilename)
+	if err := ioutil.WriteFile(playsFile, b, 0644); err != nil {
+		return fmt.Errorf("error writing plays file to %q: %v", playsFile, err)
+	}
+	out := ae.stdout
+	if t.out != nil {
+		out = t.out
+	}
+	util.PrettyPrintOk(out, "Dry run, the %d plays of %q were rendered in %s", len(plays), t.playbook, runDirectory)
+	return nil
+}
@@ -0,0 +1,96 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestExecuteDryRunRendersTask(t *testing.T) {
+	ansibleDir := mustGetTempDir(t)
+	playbooksDir := filepath.Join(ansibleDir, "playbooks")
+	if err := os.MkdirAll(playbooksDir, 0755); err != nil {
+		t.Fatalf("error creating playbooks directory: %v", err)
+	}
+	playbook := `---
+  - hosts: worker
+    name: "{{ play_name | default('Start Kubelet') }}"
+`
+	if err := ioutil.WriteFile(filepath.Join(playbooksDir, "kubelet.yaml"), []byte(playbook), 0644); err != nil {
+		t.Fatalf("error writing playbook: %v", err)
+	}
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t), DryRun: true},
+		stdout:              &bytes.Buffer{},
+		consoleOutputFormat: ansible.RawFormat,
+		ansibleDir:          ansibleDir,
+		certsDir:            mustGetTempDir(t),
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			t.Errorf("expected the playbook not to be run")
+			return &fakeRunner{}, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+	}
+	inventory := ansible.Inventory{Roles: []ansible.Role{{Name: "worker", Nodes: []ansible.Node{{Host: "worker1", PublicIP: "10.0.0.1", InternalIP: "10.0.0.1"}}}}}
+	t1 := task{
+		name:           "step",
+		playbook:       "kubelet.yaml",
+		inventory:      inventory,
+		clusterCatalog: ansible.ClusterCatalog{ClusterName: "test"},
+		limit:          []string{"worker1"},
+		explainer:      e.defaultExplainer(),
+	}
+	if _, err := e.execute(t1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dirs, err := filepath.Glob(filepath.Join(e.options.RunsDirectory, "step", "*"))
+	if err != nil || len(dirs) != 1 {
+		t.Fatalf("expected a run directory, got %v (%v)", dirs, err)
+	}
+	runDir := dirs[0]
+
+	b, err := ioutil.ReadFile(filepath.Join(runDir, dryRunInventoryFilename))
+	if err != nil {
+		t.Fatalf("error reading inventory: %v", err)
+	}
+	if !strings.Contains(string(b), "worker1") {
+		t.Errorf("expected the inventory to contain the node, got:\n%s", b)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(runDir, dryRunClusterCatalogFilename))
+	if err != nil {
+		t.Fatalf("error reading cluster catalog: %v", err)
+	}
+	if !strings.Contains(string(b), "kubernetes_cluster_name: test") {
+		t.Errorf("expected the cluster catalog to contain the cluster name, got:\n%s", b)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(runDir, dryRunPlaysFilename))
+	if err != nil {
+		t.Fatalf("error reading plays: %v", err)
+	}
+	plays := dryRunPlays{}
+	if err := yaml.Unmarshal(b, &plays); err != nil {
+		t.Fatalf("error parsing plays: %v", err)
+	}
+	if plays.Playbook != "kubelet.yaml" || len(plays.Limit) != 1 || len(plays.Plays) != 1 || plays.Plays[0].Name != "Start Kubelet" {
+		t.Errorf("unexpected plays: %+v", plays)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(runDir, runManifestFilename))
+	if err != nil {
+		t.Fatalf("error reading run manifest: %v", err)
+	}
+	manifest := RunManifest{}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		t.Fatalf("error parsing run manifest: %v", err)
+	}
+	if !manifest.DryRun {
+		t.Errorf("expected the run manifest to be marked as a dry run")
+	}
+}
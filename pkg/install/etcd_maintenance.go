@@ -0,0 +1,40 @@
+package install
+
+import (
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// EtcdMaintenanceExecutor runs the maintenance of the etcd clusters
+type EtcdMaintenanceExecutor interface {
+	// MaintainEtcd backs up the data of the etcd clusters on each etcd node,
+	// one node at a time. The data is also defragmented after the backup when
+	// defrag is true.
+	MaintainEtcd(p *Plan, defrag bool) error
+}
+
+// MaintainEtcd backs up, and optionally defragments, the etcd clusters
+func (ae *ansibleExecutor) MaintainEtcd(p *Plan, defrag bool) error {
+	cc, err := ae.buildClusterCatalog(p)
+	if err != nil {
+		return err
+	}
+	cc.EtcdMaintenance.Defrag = defrag
+	t := task{
+		name:           "etcd-maintenance",
+		playbook:       "etcd-maintenance.yaml",
+		plan:           *p,
+		inventory:      ae.buildInventory(p),
+		clusterCatalog: *cc,
+		explainer:      ae.defaultExplainer(),
+	}
+	header := "Backing Up Etcd"
+	if defrag {
+		header = "Backing Up and Defragmenting Etcd"
+	}
+	util.PrintHeader(ae.stdout, header, '=')
+	if _, err := ae.execute(t); err != nil {
+		return err
+	}
+	util.PrettyPrintOk(ae.stdout, "The etcd data was backed up to the backup directory of each etcd cluster on the etcd nodes")
+	return nil
+}
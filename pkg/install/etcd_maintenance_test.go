@@ -0,0 +1,50 @@
+package install
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+func TestMaintainEtcd(t *testing.T) {
+	plan := &Plan{
+		Cluster: Cluster{
+			Name:    "test",
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+		Etcd: NodeGroup{
+			Nodes: []Node{{Host: "etcd01"}},
+		},
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01"}},
+		},
+	}
+	for _, defrag := range []bool{false, true} {
+		fakeRunner := fakeRunner{}
+		e := ansibleExecutor{
+			certsDir:            mustGetTempDir(t),
+			options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t)},
+			stdout:              ioutil.Discard,
+			consoleOutputFormat: ansible.RawFormat,
+			runnerExplainerFactory: func(explain.AnsibleEventExplainer, io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+				return &fakeRunner, &explain.AnsibleEventStreamExplainer{}, nil
+			},
+		}
+		if err := e.MaintainEtcd(plan, defrag); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(fakeRunner.allNodesPlaybooks, []string{"etcd-maintenance.yaml"}) {
+			t.Errorf("expected the etcd maintenance playbook to run, but got %v", fakeRunner.allNodesPlaybooks)
+		}
+		if fakeRunner.incomingCatalog.EtcdMaintenance.Defrag != defrag {
+			t.Errorf("expected defrag to be %v in the cluster catalog", defrag)
+		}
+	}
+}
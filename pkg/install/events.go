@@ -0,0 +1,209 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/tracing"
+)
+
+// ansibleEventObserver is notified of each event of the ansible event stream
+type ansibleEventObserver interface {
+	observe(e ansible.Event)
+}
+
+// eventsDrainTimeout is how long the events that are still in the stream
+// when ansible exits are waited for. The end of the playbook is never
+// received when ansible was stopped or killed.
+var eventsDrainTimeout = 5 * time.Second
+
+// observeAnsibleEvents notifies the observers of each event of the incoming
+// stream before forwarding it. The returned stream must be consumed for the
+// incoming stream to be drained. The returned channel is closed once the
+// observers were notified of the end of the playbook, or of the last event
+// when the incoming stream is closed.
+func observeAnsibleEvents(in <-chan ansible.Event, observers ...ansibleEventObserver) (<-chan ansible.Event, <-chan struct{}) {
+	out := make(chan ansible.Event)
+	observed := make(chan struct{})
+	go func() {
+		defer close(out)
+		var once sync.Once
+		done := func() { once.Do(func() { close(observed) }) }
+		defer done()
+		for e := range in {
+			for _, o := range observers {
+				o.observe(e)
+			}
+			if _, ok := e.(*ansible.PlaybookEndEvent); ok {
+				done()
+			}
+			out <- e
+		}
+	}()
+	return out, observed
+}
+
+// waitForEvents waits until the events of the playbook were observed, as
+// ansible can exit before they are read from the stream
+func waitForEvents(observed <-chan struct{}, log *logging.Logger) {
+	select {
+	case <-observed:
+	case <-time.After(eventsDrainTimeout):
+		log.Warn("the end of the playbook was not received, the results of the run may be incomplete", "wait", eventsDrainTimeout)
+	}
+}
+
+// eventLogger records the relevant ansible events in the kismatic log
+type eventLogger struct {
+	log *logging.Logger
+}
+
+func (l eventLogger) observe(e ansible.Event) {
+	switch event := e.(type) {
+	case *ansible.PlayStartEvent:
+		l.log.Info("play started", "play", event.Name)
+	case *ansible.TaskStartEvent:
+		l.log.Debug("ansible task started", "ansibleTask", event.Name)
+	case *ansible.RunnerItemRetryEvent:
+		l.log.Warn("retrying ansible task", "host", event.Host, "item", event.Result.Item, "attempt", event.Result.Attempts, "maxRetries", event.Result.MaxRetries)
+	case *ansible.RunnerFailedEvent:
+		l.log.Error("ansible task failed", "host", event.Host, "message", event.Result.Message, "stderr", event.Result.Stderr, "ignoreErrors", event.IgnoreErrors)
+	case *ansible.RunnerItemFailedEvent:
+		l.log.Error("ansible task failed", "host", event.Host, "item", event.Result.Item, "message", event.Result.Message, "stderr", event.Result.Stderr, "ignoreErrors", event.IgnoreErrors)
+	case *ansible.RunnerUnreachableEvent:
+		l.log.Error("host unreachable", "host", event.Host, "message", event.Result.Message)
+	}
+}
+
+// explainerObserver forwards each ansible event to an explainer, such as
+// the JSON explainer of the event stream or the progress tracker
+type explainerObserver struct {
+	explainer explain.AnsibleEventExplainer
+}
+
+func (o explainerObserver) observe(e ansible.Event) {
+	o.explainer.ExplainEvent(e)
+}
+
+// eventTracer records a span for each play, and for each ansible task within
+// the play. The hosts targeted by a play are recorded as span attributes.
+type eventTracer struct {
+	mu       sync.Mutex
+	parent   *tracing.Span
+	play     *tracing.Span
+	task     *tracing.Span
+	hosts    map[string]bool
+	failed   map[string]bool
+	finished bool
+}
+
+func newEventTracer(parent *tracing.Span) *eventTracer {
+	return &eventTracer{parent: parent}
+}
+
+func (t *eventTracer) observe(e ansible.Event) {
+	if t.parent == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return
+	}
+	switch event := e.(type) {
+	case *ansible.PlayStartEvent:
+		t.endPlay()
+		t.play = t.parent.Child("play: "+event.Name, "ansible.play", event.Name)
+		t.hosts = map[string]bool{}
+		t.failed = map[string]bool{}
+	case *ansible.TaskStartEvent:
+		t.startTask(event.Name, false)
+	case *ansible.HandlerTaskStartEvent:
+		t.startTask(event.Name, true)
+	case *ansible.RunnerOKEvent:
+		t.hostResult(event.Host)
+	case *ansible.RunnerItemOKEvent:
+		t.hostResult(event.Host)
+	case *ansible.RunnerSkippedEvent:
+		t.hostResult(event.Host)
+	case *ansible.RunnerItemRetryEvent:
+		t.hostResult(event.Host)
+		t.task.AddEvent("retry", "host", event.Host, "item", event.Result.Item, "attempt", event.Result.Attempts)
+	case *ansible.RunnerFailedEvent:
+		t.hostFailure("failed", event.Host, event.Result.Message, event.IgnoreErrors)
+	case *ansible.RunnerItemFailedEvent:
+		t.hostFailure("failed", event.Host, event.Result.Message, event.IgnoreErrors)
+	case *ansible.RunnerUnreachableEvent:
+		t.hostFailure("unreachable", event.Host, event.Result.Message, false)
+	case *ansible.PlaybookEndEvent:
+		t.endPlay()
+	}
+}
+
+func (t *eventTracer) startTask(name string, handler bool) {
+	t.task.End()
+	parent := t.play
+	if parent == nil {
+		parent = t.parent
+	}
+	t.task = parent.Child("task: "+name, "ansible.task", name, "ansible.handler", handler)
+}
+
+func (t *eventTracer) hostResult(host string) {
+	if t.hosts != nil {
+		t.hosts[host] = true
+	}
+}
+
+func (t *eventTracer) hostFailure(result, host, msg string, ignored bool) {
+	t.hostResult(host)
+	span := t.task
+	if span == nil {
+		span = t.play
+	}
+	span.AddEvent(result, "host", host, "message", msg, "ignored", ignored)
+	if ignored {
+		return
+	}
+	if t.failed != nil {
+		t.failed[host] = true
+	}
+	span.RecordError(fmt.Errorf("%s on %s: %s", result, host, msg))
+}
+
+func (t *eventTracer) endPlay() {
+	t.task.End()
+	t.task = nil
+	if t.play == nil {
+		return
+	}
+	t.play.SetAttributes("ansible.hosts", sortedKeys(t.hosts), "ansible.failed_hosts", sortedKeys(t.failed))
+	if len(t.failed) > 0 {
+		t.play.RecordError(fmt.Errorf("play failed on %d host(s)", len(t.failed)))
+	}
+	t.play.End()
+	t.play = nil
+}
+
+// finish ends the spans that are still open. Events received after
+// finishing are ignored.
+func (t *eventTracer) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.endPlay()
+	t.finished = true
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,174 @@
+package install
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/tracing"
+)
+
+// eventsFromJSONLines decodes ansible events in the JSON lines format
+func eventsFromJSONLines(lines string) []ansible.Event {
+	var events []ansible.Event
+	for e := range ansible.EventStream(strings.NewReader(lines)) {
+		events = append(events, e)
+	}
+	return events
+}
+
+// lateRunner exits before the events of its runs are read from the stream,
+// as ansible does when its last events are still in the named pipe. Like the
+// named pipe, the stream is never closed.
+type lateRunner struct {
+	// events sent by each run, the last ones are sent by the next runs
+	events [][]ansible.Event
+	// errs returned by each run, the last one is returned by the next runs
+	errs      []error
+	runs      [][]string
+	playbooks []string
+	exited    chan struct{}
+}
+
+func (r *lateRunner) StartPlaybook(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog) (<-chan ansible.Event, error) {
+	return r.StartPlaybookOnNode(playbook, inv, cc)
+}
+
+func (r *lateRunner) StartPlaybookOnNode(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog, nodes ...string) (<-chan ansible.Event, error) {
+	events := r.events[len(r.events)-1]
+	if len(r.runs) < len(r.events) {
+		events = r.events[len(r.runs)]
+	}
+	r.runs = append(r.runs, nodes)
+	r.playbooks = append(r.playbooks, playbook)
+	exited := make(chan struct{})
+	r.exited = exited
+	out := make(chan ansible.Event)
+	go func() {
+		<-exited
+		time.Sleep(20 * time.Millisecond)
+		for _, e := range events {
+			out <- e
+		}
+	}()
+	return out, nil
+}
+
+func (r *lateRunner) WaitPlaybook() error {
+	close(r.exited)
+	if len(r.runs) <= len(r.errs) {
+		return r.errs[len(r.runs)-1]
+	}
+	return r.errs[len(r.errs)-1]
+}
+
+func (r *lateRunner) Stop() error { return nil }
+
+const tracedPlaybookEvents = `{"eventType":"PLAYBOOK_START","eventData":{"name":"kubernetes.yaml","count":2}}
+{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"start etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{}}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd02","result":{}}}
+{"eventType":"PLAY_START","eventData":{"name":"master"}}
+{"eventType":"TASK_START","eventData":{"name":"start apiserver"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"master01","result":{}}}
+{"eventType":"RUNNER_FAILED","eventData":{"host":"master02","result":{"msg":"timeout"}}}
+{"eventType":"PLAYBOOK_END","eventData":{"name":"kubernetes.yaml"}}
+`
+
+func TestEventTracer(t *testing.T) {
+	var payload struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Attributes   []struct {
+						Key   string `json:"key"`
+						Value struct {
+							ArrayValue struct {
+								Values []struct {
+									StringValue string `json:"stringValue"`
+								} `json:"values"`
+							} `json:"arrayValue"`
+						} `json:"value"`
+					} `json:"attributes"`
+					Status struct {
+						Code int `json:"code"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &payload); err != nil {
+			t.Errorf("error unmarshaling spans: %v", err)
+		}
+	}))
+	defer server.Close()
+	os.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", server.URL)
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if err := tracing.Init("test"); err != nil {
+		t.Fatalf("error initializing tracing: %v", err)
+	}
+
+	span := tracing.Start("task: apply")
+	tracer := newEventTracer(span)
+	for _, e := range eventsFromJSONLines(tracedPlaybookEvents) {
+		tracer.observe(e)
+	}
+	tracer.finish()
+	span.End()
+	if err := tracing.Shutdown(nil); err != nil {
+		t.Fatalf("error exporting spans: %v", err)
+	}
+
+	spans := payload.ResourceSpans[0].ScopeSpans[0].Spans
+	ids := map[string]string{}
+	for _, s := range spans {
+		ids[s.Name] = s.SpanID
+	}
+	for _, s := range spans {
+		switch s.Name {
+		case "play: etcd", "play: master":
+			if s.ParentSpanID != ids["task: apply"] {
+				t.Errorf("expected %q to be a child of the task span", s.Name)
+			}
+		case "task: start etcd":
+			if s.ParentSpanID != ids["play: etcd"] {
+				t.Errorf("expected %q to be a child of the etcd play", s.Name)
+			}
+		case "task: start apiserver":
+			if s.ParentSpanID != ids["play: master"] {
+				t.Errorf("expected %q to be a child of the master play", s.Name)
+			}
+			if s.Status.Code != 2 {
+				t.Errorf("expected %q to have failed", s.Name)
+			}
+		}
+		if s.Name == "play: etcd" {
+			for _, a := range s.Attributes {
+				if a.Key == "ansible.hosts" && len(a.Value.ArrayValue.Values) != 2 {
+					t.Errorf("expected the etcd play to target 2 hosts, but got %v", a.Value.ArrayValue.Values)
+				}
+			}
+			if s.Status.Code != 1 {
+				t.Errorf("expected the etcd play to succeed")
+			}
+		}
+		if s.Name == "play: master" && s.Status.Code != 2 {
+			t.Errorf("expected the master play to fail")
+		}
+	}
+	if len(spans) != 6 {
+		t.Errorf("expected 6 spans, but got %d", len(spans))
+	}
+}
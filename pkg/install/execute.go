@@ -1,19 +1,26 @@
 package install
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sync"
 	"time"
 
 	"strings"
 
 	"github.com/apprenda/kismatic/pkg/ansible"
 	"github.com/apprenda/kismatic/pkg/install/explain"
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/ssh"
 	"github.com/apprenda/kismatic/pkg/tls"
+	"github.com/apprenda/kismatic/pkg/tracing"
 	"github.com/apprenda/kismatic/pkg/util"
 )
 
@@ -23,22 +30,37 @@ type PreFlightExecutor interface {
 	RunPreFlightCheck(plan *Plan, nodes ...string) error
 	RunNewNodePreFlightCheck(Plan, Node) error
 	RunUpgradePreFlightCheck(*Plan, ListableNode) error
+	// RunUpgradePreFlightChecks runs the upgrade preflight checks against
+	// the nodes, running at most parallelism checks at a time. It returns the
+	// errors of the nodes that failed the checks, keyed by host.
+	RunUpgradePreFlightChecks(p *Plan, nodes []ListableNode, parallelism int) map[string]error
 }
 
-// The Executor will carry out the installation plan
+// The Executor will carry out the installation plan. The operations that
+// report the results of their ansible tasks return a RunSummary, along with
+// the error of the operation when it fails, so that partial failures can be
+// reported.
 type Executor interface {
 	PreFlightExecutor
-	Install(plan *Plan, restartServices bool, nodes ...string) error
-	Reset(plan *Plan, nodes ...string) error
+	Install(plan *Plan, restartServices bool, nodes ...string) (RunSummary, error)
+	PreparePackages(plan *Plan, nodes ...string) error
+	Reset(plan *Plan, opts ResetOptions, nodes ...string) (RunSummary, error)
+	CleanNodes(plan *Plan, opts NodeCleanOptions, nodes ...string) error
 	GenerateCertificates(p *Plan, useExistingCA bool) error
-	RunSmokeTest(*Plan) error
-	AddNode(plan *Plan, node Node, roles []string, restartServices bool) (*Plan, error)
-	RunPlay(name string, plan *Plan, restartServices bool, nodes ...string) error
+	RunSmokeTest(*Plan) (RunSummary, error)
+	RunNodeSmokeTest(p *Plan, host string) error
+	AddNode(plan *Plan, node Node, roles []string, restartServices bool) (*Plan, RunSummary, error)
+	RemoveNode(plan *Plan, host string, drain bool) (*Plan, RunSummary, error)
+	Cordon(plan *Plan, host string) error
+	Uncordon(plan *Plan, host string) error
+	Drain(plan *Plan, host string, opts DrainOptions) error
+	RunPlay(name string, plan *Plan, restartServices bool, nodes ...string) (RunSummary, error)
+	ListPlays() ([]Play, error)
 	AddVolume(*Plan, StorageVolume) error
 	DeleteVolume(*Plan, string) error
-	UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) error
+	UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) (RunSummary, error)
 	ValidateControlPlane(plan Plan) error
-	UpgradeClusterServices(plan Plan) error
+	UpgradeClusterServices(plan Plan) (RunSummary, error)
 }
 
 // DiagnosticsExecutor will run diagnostics on the nodes after an install
@@ -51,7 +73,8 @@ type ExecutorOptions struct {
 	// GeneratedAssetsDirectory is the location where generated assets
 	// are to be stored
 	GeneratedAssetsDirectory string
-	// OutputFormat sets the format of the executor
+	// OutputFormat sets the format of the executor: "simple", "table", "ci",
+	// "json" for a line of JSON per event, or "raw"
 	OutputFormat string
 	// Verbose output from the executor
 	Verbose bool
@@ -59,8 +82,127 @@ type ExecutorOptions struct {
 	RunsDirectory string
 	// DiagnosticsDirecty is where the doDiagnostics information about the cluster will be dumped
 	DiagnosticsDirecty string
-	// DryRun determines if the executor should actually run the task
+	// DryRun determines if the executor should actually run the task. The
+	// inventory, the cluster catalog and the plays of the tasks that would be
+	// run are rendered in their run directories instead.
 	DryRun bool
+	// IgnoreVersionCompatibility is set when the operation is being forced
+	// even though the cluster is running versions that are not supported by
+	// this version of Kismatic. It is recorded in the run manifest.
+	IgnoreVersionCompatibility bool
+	// Timings prints a breakdown of the time spent on each play and host at
+	// the end of each task, and records it in the run manifest.
+	Timings bool
+	// ShowTasks is a regular expression. When set, only the tasks whose name,
+	// or the name of their play, matches the expression are explained.
+	ShowTasks string
+	// HideTasks is a regular expression. When set, the tasks whose name, or
+	// the name of their play, matches the expression are not explained.
+	HideTasks string
+	// SmokeTestEngine is the engine used to run the smoke test. Defaults to
+	// kuberang.
+	SmokeTestEngine string
+	// PreflightParallelism is the maximum number of nodes on which the
+	// pre-flight checks are run in parallel. Defaults to 10.
+	PreflightParallelism int
+	// ForceFullInstall runs all the phases of the installation, even the
+	// phases that completed on the nodes during a previous failed installation.
+	ForceFullInstall bool
+	// RollbackOnFailure resets the nodes that were modified by a failed
+	// installation, so that they are not left half configured
+	RollbackOnFailure bool
+	// RestartComponents are the components that are restarted by the
+	// installation, the upgrade or the play, such as kubelet or etcd, when
+	// not all the services are restarted. Options are listed by
+	// RestartComponents().
+	RestartComponents []string
+	// MaxParallelControlPlane is the maximum number of etcd nodes, and of
+	// master nodes, that are upgraded at the same time. The etcd and master
+	// nodes are upgraded one at a time, unless the cluster has at least 5
+	// etcd nodes and a load balancer in front of the master nodes, in which
+	// case up to (n-1)/2 etcd nodes, so that the quorum is maintained, and all
+	// the master nodes but one are upgraded at the same time. Defaults to 1.
+	MaxParallelControlPlane int
+	// DrainPolicy is applied when pod disruption budgets do not allow the
+	// eviction of the pods of a node that is drained during an online upgrade.
+	// Defaults to waiting for the budgets to allow the eviction.
+	DrainPolicy string
+	// DrainTimeout is the time to wait for the pod disruption budgets to
+	// allow the eviction, when the drain policy is to wait. Defaults to 10 minutes.
+	DrainTimeout time.Duration
+	// OutputFile is a file where the console output of the executor is
+	// appended, in addition to being written to stdout
+	OutputFile string
+	// OutputSocket is a unix socket where the console output of the executor
+	// is sent, in addition to being written to stdout
+	OutputSocket string
+	// LogSink is where the timestamped ansible log of each run is mirrored,
+	// in addition to the ansible.log file of the run directory. It is either
+	// journald, or the address of a syslog server, such as
+	// udp://logs.example.com:514, tcp://logs.example.com:601 or
+	// unix:///dev/log. The log is not mirrored when empty.
+	LogSink string
+	// EventStream receives a line of JSON for each ansible event of the
+	// operations, in the format of explain.JSONExplainer, so that remote user
+	// interfaces can follow their progress
+	EventStream io.Writer
+	// ShowProgress prefixes the name of each play in the console output with
+	// the percentage of the playbook that completed, the elapsed time and an
+	// estimate of the remaining time
+	ShowProgress bool
+	// OnProgress is called with the progress of the running playbook when
+	// the playbook, a play or a task starts, and when the playbook ends, so
+	// that user interfaces can render progress bars
+	OnProgress func(explain.Progress)
+	// Timeout is the maximum duration of each task. The playbook of a task
+	// that exceeds it is terminated. No timeout is enforced when zero.
+	Timeout time.Duration
+	// PlayTimeout is the maximum duration of each play of the playbooks. No
+	// timeout is enforced when zero.
+	PlayTimeout time.Duration
+	// TaskTimeouts are the maximum durations of the pre-flight, apply and
+	// upgrade tasks, which take precedence over Timeout
+	TaskTimeouts TaskTimeouts
+	// Parallelism overrides the parallelism of the plan, such as to run the
+	// tasks on more nodes at the same time when installing a large cluster
+	Parallelism Parallelism
+	// DiagnoseOnTimeout collects diagnostics from the nodes when a task times
+	// out
+	DiagnoseOnTimeout bool
+	// DiagnoseOnFailure collects diagnostics from the nodes on which a task
+	// failed, and adds the directory where they are stored to the error
+	DiagnoseOnFailure bool
+	// PerformanceProfile runs the performance collectors when diagnosing the
+	// nodes, over the window of the profile
+	PerformanceProfile PerformanceProfile
+	// HeapsterMigration migrates the clusters that run Heapster to
+	// metrics-server when the cluster services are upgraded
+	HeapsterMigration HeapsterMigration
+	// Retry is the policy applied when a playbook fails on some hosts, such
+	// as after a transient SSH or package mirror error. The playbooks are not
+	// retried by default.
+	Retry RetryPolicy
+	// ShredCredentials overwrites the files that hold the passwords and the
+	// SSH keys of the cluster before they are removed, once each playbook
+	// exits. The cluster catalog and the plan file are then not kept in the
+	// run directory.
+	ShredCredentials bool
+	// VaultClusterCatalog encrypts the variables passed to ansible with
+	// ansible-vault, using a random password for each playbook, so that they
+	// are not kept in cleartext in the run directory
+	VaultClusterCatalog bool
+	// NotificationWebhook is a URL that is sent a JSON payload when each task
+	// starts, succeeds or fails
+	NotificationWebhook string
+	// Hooks are the commands and the playbooks run before and after the
+	// tasks of the operations
+	Hooks []Hook
+	// HooksFile lists more hooks, which are run after the ones of Hooks
+	HooksFile string
+	// Context of the operations of the executor. The running playbook is
+	// stopped when it is done, and the remaining tasks are not run. Defaults
+	// to a context that is never done.
+	Context context.Context
 }
 
 // NewExecutor returns an executor for performing installations according to the installation plan.
@@ -72,22 +214,82 @@ func NewExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOptions) (E
 	if options.RunsDirectory == "" {
 		options.RunsDirectory = "./runs"
 	}
+	if options.DiagnosticsDirecty == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("Could not get working directory: %v", err)
+		}
+		options.DiagnosticsDirecty = filepath.Join(wd, "diagnostics")
+	}
+	if err := options.PerformanceProfile.validate(); err != nil {
+		return nil, err
+	}
+	if err := options.TaskTimeouts.validate(); err != nil {
+		return nil, err
+	}
+	if ok, errs := options.Parallelism.validate(); !ok {
+		return nil, errs[0]
+	}
 
 	// Setup the console output format
-	var outFormat ansible.OutputFormat
-	switch options.OutputFormat {
-	case "raw":
-		outFormat = ansible.RawFormat
-	case "simple":
-		outFormat = ansible.JSONLinesFormat
-	default:
-		return nil, fmt.Errorf("Output format %q is not supported", options.OutputFormat)
+	outFormat, err := consoleOutputFormat(options.OutputFormat)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err = consoleOutput(stdout, options)
+	if err != nil {
+		return nil, err
 	}
 	certsDir := filepath.Join(options.GeneratedAssetsDirectory, "keys")
 	pki := &LocalPKI{
 		CACsr: filepath.Join(ansibleDir, "playbooks", "tls", "ca-csr.json"),
 		GeneratedCertsDirectory: certsDir,
-		Log: stdout,
+		Log:    stdout,
+		DryRun: options.DryRun,
+	}
+	showTasks, hideTasks, err := compileTaskFilters(options)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := executorLogSink(options)
+	if err != nil {
+		return nil, err
+	}
+	var smokeTester SmokeTester
+	switch options.SmokeTestEngine {
+	case "", KuberangSmokeTestEngine:
+	case NativeSmokeTestEngine:
+		smokeTester = newNativeSmokeTester(stdout)
+	default:
+		return nil, fmt.Errorf("Smoke test engine %q is not supported. Options are %v", options.SmokeTestEngine, SmokeTestEngines())
+	}
+	if options.DrainPolicy == "" {
+		options.DrainPolicy = DrainPolicyWait
+	}
+	if !util.Contains(options.DrainPolicy, DrainPolicies()) {
+		return nil, fmt.Errorf("Drain policy %q is not supported. Options are %v", options.DrainPolicy, DrainPolicies())
+	}
+	if options.DrainTimeout == 0 {
+		options.DrainTimeout = 10 * time.Minute
+	}
+	if err := validateRestartComponents(options.RestartComponents); err != nil {
+		return nil, err
+	}
+	if err := options.Retry.validate(); err != nil {
+		return nil, err
+	}
+	if err := validateHooks(options.Hooks); err != nil {
+		return nil, err
+	}
+	if err := validateNotificationWebhook(options.NotificationWebhook); err != nil {
+		return nil, err
+	}
+	if options.HooksFile != "" {
+		hooks, err := LoadHooks(options.HooksFile)
+		if err != nil {
+			return nil, err
+		}
+		options.Hooks = append(append([]Hook{}, options.Hooks...), hooks...)
 	}
 	return &ansibleExecutor{
 		options:             options,
@@ -96,6 +298,12 @@ func NewExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOptions) (E
 		ansibleDir:          ansibleDir,
 		certsDir:            certsDir,
 		pki:                 pki,
+		renderCache:         &renderCache{},
+		showTasks:           showTasks,
+		hideTasks:           hideTasks,
+		smokeTester:         smokeTester,
+		disruptionBudgets:   newDisruptionBudgetChecker(stdout, options.DrainPolicy, options.DrainTimeout),
+		logSink:             sink,
 	}, nil
 }
 
@@ -106,21 +314,31 @@ func NewPreFlightExecutor(stdout io.Writer, errOut io.Writer, options ExecutorOp
 		options.RunsDirectory = "./runs"
 	}
 	// Setup the console output format
-	var outFormat ansible.OutputFormat
-	switch options.OutputFormat {
-	case "raw":
-		outFormat = ansible.RawFormat
-	case "simple":
-		outFormat = ansible.JSONLinesFormat
-	default:
-		return nil, fmt.Errorf("Output format %q is not supported", options.OutputFormat)
+	outFormat, err := consoleOutputFormat(options.OutputFormat)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err = consoleOutput(stdout, options)
+	if err != nil {
+		return nil, err
 	}
 
+	showTasks, hideTasks, err := compileTaskFilters(options)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := executorLogSink(options)
+	if err != nil {
+		return nil, err
+	}
 	return &ansibleExecutor{
 		options:             options,
 		stdout:              stdout,
 		consoleOutputFormat: outFormat,
 		ansibleDir:          ansibleDir,
+		showTasks:           showTasks,
+		hideTasks:           hideTasks,
+		logSink:             sink,
 	}, nil
 }
 
@@ -139,21 +357,32 @@ func NewDiagnosticsExecutor(stdout io.Writer, errOut io.Writer, options Executor
 	}
 
 	// Setup the console output format
-	var outFormat ansible.OutputFormat
-	switch options.OutputFormat {
-	case "raw":
-		outFormat = ansible.RawFormat
-	case "simple":
-		outFormat = ansible.JSONLinesFormat
-	default:
-		return nil, fmt.Errorf("Output format %q is not supported", options.OutputFormat)
+	outFormat, err := consoleOutputFormat(options.OutputFormat)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err = consoleOutput(stdout, options)
+	if err != nil {
+		return nil, err
 	}
 
+	showTasks, hideTasks, err := compileTaskFilters(options)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := executorLogSink(options)
+	if err != nil {
+		return nil, err
+	}
 	return &ansibleExecutor{
 		options:             options,
 		stdout:              stdout,
 		consoleOutputFormat: outFormat,
 		ansibleDir:          ansibleDir,
+		showTasks:           showTasks,
+		hideTasks:           hideTasks,
+		renderCache:         &renderCache{},
+		logSink:             sink,
 	}, nil
 }
 
@@ -164,9 +393,21 @@ type ansibleExecutor struct {
 	ansibleDir          string
 	certsDir            string
 	pki                 PKI
+	renderCache         *renderCache
+	showTasks           *regexp.Regexp
+	hideTasks           *regexp.Regexp
+	// smokeTester runs the smoke test when an engine other than kuberang is used
+	smokeTester SmokeTester
+	// disruptionBudgets applies the drain policy before nodes are drained
+	// during an online upgrade
+	disruptionBudgets *disruptionBudgetChecker
+	// logSink mirrors the ansible log of the runs, when the LogSink option is set
+	logSink logSink
 
 	// Hook for testing purposes.. default implementation is used at runtime
 	runnerExplainerFactory func(explain.AnsibleEventExplainer, io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error)
+	// Hook for testing purposes.. ListInstalledVersions is used at runtime
+	installedVersions func(*Plan) (ClusterVersion, error)
 }
 
 type task struct {
@@ -184,32 +425,178 @@ type task struct {
 	plan Plan
 	// run the task on specific nodes
 	limit []string
+	// out is where the output of the task is written. Defaults to the
+	// executor's stdout.
+	out io.Writer
+	// runDirectory is where the information about the run is kept. Defaults
+	// to a new directory named after the task in the runs directory.
+	runDirectory string
+	// observers are notified of the ansible events of the task
+	observers []ansibleEventObserver
+	// hook is set for the playbooks of hooks, which do not have hooks
+	// of their own
+	hook bool
 }
 
 // execute will run the given task, and setup all what's needed for us to run ansible.
-func (ae *ansibleExecutor) execute(t task) error {
+// It returns the summary of the results of the ansible tasks, including those
+// of the playbooks of the hooks of the task, whether the task failed or not.
+// The hooks of the task are run before and after it, and the notification
+// webhook is notified when the task starts and ends.
+func (ae *ansibleExecutor) execute(t task) (RunSummary, error) {
+	start := time.Now()
+	ae.notifyTask(t, TaskStarted, start, RunSummary{}, nil)
+	summary, err := ae.executeWithHooks(t)
 	if ae.options.DryRun {
-		return nil
+		summary = RunSummary{}
+	}
+	if err != nil {
+		ae.notifyTask(t, TaskFailed, start, summary, err)
+		return summary, err
+	}
+	ae.notifyTask(t, TaskSucceeded, start, summary, nil)
+	return summary, nil
+}
+
+func (ae *ansibleExecutor) executeWithHooks(t task) (RunSummary, error) {
+	summary, err := ae.runHooks(t, HookBefore)
+	if err != nil {
+		return summary, err
 	}
-	runDirectory, err := ae.createRunDirectory(t.name)
+	taskSummary, err := ae.executeTask(t)
+	summary = summary.Merge(taskSummary)
 	if err != nil {
-		return fmt.Errorf("error creating working directory for %q: %v", t.name, err)
+		return summary, err
 	}
-	// Save the plan file that was used for this execution
+	after, err := ae.runHooks(t, HookAfter)
+	return summary.Merge(after), err
+}
+
+// executeTask runs the task, and returns the summary of the results of its
+// ansible tasks. When the playbook fails on some hosts, it is re-run on those
+// hosts according to the retry policy of the executor.
+// The recorders of the failed hosts and of the results are only read once
+// executeAttempt observed the last events of the playbook.
+func (ae *ansibleExecutor) executeTask(t task) (RunSummary, error) {
+	failed := newFailedHostsRecorder()
+	results := newSummaryRecorder()
+	t.observers = append(append([]ansibleEventObserver{}, t.observers...), results, failed)
+	runDirectory, err := ae.executeAttempt(t)
+	policy := ae.options.Retry
+	for retry := 1; err != nil && retry <= policy.MaxRetries; retry++ {
+		hosts := failed.finish()
+		if !retriable(t, err, hosts) || runDirectory == "" {
+			break
+		}
+		wait := policy.backoff(retry)
+		logging.Warn("retrying failed task", "task", t.name, "error", err, "hosts", hosts, "retry", retry, "retries", policy.MaxRetries, "wait", wait)
+		out := ae.stdout
+		if t.out != nil {
+			out = t.out
+		}
+		util.PrettyPrintWarn(out, "Retrying the task %q on %v in %v (retry %d of %d)", t.name, hosts, wait, retry, policy.MaxRetries)
+		ctx := ae.context()
+		select {
+		case <-ctx.Done():
+			return results.finish(t.playbook, hosts), AbortedError{Task: t.name, Reason: ctx.Err()}
+		case <-time.After(wait):
+		}
+		t.limit = hosts
+		t.runDirectory = filepath.Join(runDirectory, fmt.Sprintf("retry-%d", retry))
+		failed = newFailedHostsRecorder()
+		t.observers[len(t.observers)-1] = failed
+		_, err = ae.executeAttempt(t)
+	}
+	summary := results.finish(t.playbook, failed.finish())
+	if err != nil && !IsAborted(err) && !IsTimeout(err) && ae.options.DiagnoseOnFailure {
+		if dir := ae.diagnoseFailure(t, summary.FailedHosts); dir != "" {
+			err = fmt.Errorf("%v. The diagnostics of the nodes were collected in %s", err, dir)
+		}
+	}
+	return summary, err
+}
+
+// executeAttempt runs the playbook of the task once, and returns the run
+// directory of the attempt
+func (ae *ansibleExecutor) executeAttempt(t task) (string, error) {
+	log := logging.With("task", t.name, "playbook", t.playbook)
+	ctx := ae.context()
+	if ctx.Err() != nil {
+		log.Info("skipping task due to aborted operation")
+		return "", AbortedError{Task: t.name, Reason: ctx.Err()}
+	}
+	start := time.Now()
+	span := tracing.Start("task: "+t.name, "kismatic.task", t.name, "ansible.playbook", t.playbook, "ansible.limit", t.limit)
+	defer span.End()
+	runDirectory := t.runDirectory
+	var err error
+	if runDirectory == "" {
+		runDirectory, err = ae.createRunDirectory(t.name)
+	} else {
+		err = os.MkdirAll(runDirectory, 0777)
+	}
+	if err != nil {
+		log.Error("error creating run directory", "error", err)
+		span.RecordError(err)
+		return "", fmt.Errorf("error creating working directory for %q: %v", t.name, err)
+	}
+	log.Info("running task", "runDirectory", runDirectory, "limit", t.limit)
+	span.SetAttributes("kismatic.run_directory", runDirectory)
+	manifest := RunManifest{
+		Task:                        t.name,
+		Playbook:                    t.playbook,
+		KismaticVersion:             KismaticVersion.String(),
+		StartTime:                   start,
+		IgnoredVersionCompatibility: ae.options.IgnoreVersionCompatibility,
+		DryRun:                      ae.options.DryRun,
+		Status:                      RunRunning,
+		Limit:                       t.limit,
+	}
+	if err = writeRunManifest(runDirectory, manifest); err != nil {
+		return runDirectory, err
+	}
+	// Save the plan file that was used for this execution. The file is
+	// created first, so that the passwords of the plan are only readable by
+	// the current user.
 	fp := FilePlanner{
-		File: filepath.Join(runDirectory, "kismatic-cluster.yaml"),
+		File: filepath.Join(runDirectory, runPlanFilename),
+	}
+	if err = ioutil.WriteFile(fp.File, nil, 0600); err != nil {
+		return runDirectory, fmt.Errorf("error recording plan file to %s: %v", fp.File, err)
 	}
 	if err = fp.Write(&t.plan); err != nil {
-		return fmt.Errorf("error recording plan file to %s: %v", fp.File, err)
+		return runDirectory, fmt.Errorf("error recording plan file to %s: %v", fp.File, err)
+	}
+	if ae.options.DryRun {
+		log.Info("rendering task instead of running it due to dry run")
+		err = ae.renderDryRun(t, runDirectory)
+		manifest.finish(time.Now(), err)
+		if werr := writeRunManifest(runDirectory, manifest); werr != nil {
+			log.Warn("error recording the results of the run", "error", werr)
+		}
+		return runDirectory, err
 	}
 	ansibleLogFilename := filepath.Join(runDirectory, "ansible.log")
-	ansibleLogFile, err := os.Create(ansibleLogFilename)
+	ansibleLogFile, err := os.OpenFile(ansibleLogFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
-		return fmt.Errorf("error creating ansible log file %q: %v", ansibleLogFilename, err)
+		return runDirectory, fmt.Errorf("error creating ansible log file %q: %v", ansibleLogFilename, err)
+	}
+	out := ae.stdout
+	if t.out != nil {
+		out = t.out
+	}
+	progress := ae.progressTracker()
+	taskExplainer := t.explainer
+	if progress != nil && ae.options.ShowProgress && ae.options.OutputFormat != "json" {
+		taskExplainer = explain.ShowProgress(taskExplainer, progress, phaseMarkerPlayName)
+	}
+	var ansibleLog io.Writer = ansibleLogFile
+	if ae.logSink != nil {
+		ansibleLog = io.MultiWriter(ansibleLogFile, newLogSinkWriter(ae.logSink, t.name, runDirectory))
 	}
-	runner, explainer, err := ae.ansibleRunnerWithExplainer(t.explainer, ansibleLogFile, runDirectory)
+	runner, explainer, err := ae.ansibleRunnerWithExplainer(taskExplainer, out, ansibleLog, runDirectory, ae.parallelism(t.plan).Forks)
 	if err != nil {
-		return err
+		return runDirectory, err
 	}
 
 	// Start running ansible with the given playbook
@@ -220,23 +607,107 @@ func (ae *ansibleExecutor) execute(t task) error {
 		eventStream, err = runner.StartPlaybook(t.playbook, t.inventory, t.clusterCatalog)
 	}
 	if err != nil {
-		return fmt.Errorf("error running ansible playbook: %v", err)
+		log.Error("error starting ansible playbook", "error", err)
+		span.RecordError(err)
+		manifest.finish(time.Now(), err)
+		if werr := writeRunManifest(runDirectory, manifest); werr != nil {
+			log.Warn("error recording the results of the run", "error", werr)
+		}
+		return runDirectory, fmt.Errorf("error running ansible playbook: %v", err)
 	}
 	// Ansible blocks until explainer starts reading from stream. Start
 	// explainer in a separate go routine
-	go explainer.Explain(eventStream)
+	tracer := newEventTracer(span)
+	changes := newChangesRecorder()
+	watchdog := newTimeoutWatchdog(ae.options.TaskTimeouts.forTask(t.name, ae.options.Timeout), ae.options.PlayTimeout, runner.Stop)
+	observers := []ansibleEventObserver{eventLogger{log: log}, tracer, changes, watchdog}
+	observers = append(observers, t.observers...)
+	var timer *timingsRecorder
+	if ae.options.Timings {
+		timer = newTimingsRecorder()
+		observers = append(observers, timer)
+	}
+	if ae.options.EventStream != nil {
+		observers = append(observers, explainerObserver{explain.JSONExplainer(ae.options.EventStream)})
+	}
+	if progress != nil {
+		observers = append(observers, explainerObserver{progress})
+	}
+	observedEvents, observed := observeAnsibleEvents(eventStream, observers...)
+	go explainer.Explain(observedEvents)
+	cancel := watchCancel(ctx, runner.Stop)
 
 	// Wait until ansible exits
-	if err = runner.WaitPlaybook(); err != nil {
-		return fmt.Errorf("error running playbook: %v", err)
+	err = runner.WaitPlaybook()
+	if ae.options.ShredCredentials {
+		if serr := shredRunCredentials(runDirectory); serr != nil {
+			log.Warn("error shredding the credentials of the run", "error", serr)
+			util.PrettyPrintWarn(out, "Could not shred the credentials in %s: %v", runDirectory, serr)
+		}
 	}
-	return nil
+	aborted := cancel.finish()
+	timeout := watchdog.finish()
+	// The recorders are only read once they observed the last events
+	waitForEvents(observed, log)
+	tracer.finish()
+	manifest.Changes = changes.finish()
+	printChanges(out, manifest.Changes)
+	if timer != nil {
+		timings := timer.finish()
+		printTimings(out, timings)
+		manifest.Timings = &timings
+	}
+	if timeout != "" {
+		manifest.TimedOut = true
+		manifest.TimeoutReason = timeout
+	}
+	manifest.Aborted = aborted
+	manifest.finish(time.Now(), err)
+	if werr := writeRunManifest(runDirectory, manifest); werr != nil {
+		log.Warn("error recording the results of the run", "error", werr)
+	}
+	if aborted {
+		log.Error("task aborted", "reason", ctx.Err(), "duration", time.Since(start))
+		util.PrettyPrintErr(out, "The task %q was aborted", t.name)
+		aerr := AbortedError{Task: t.name, Reason: ctx.Err()}
+		span.RecordError(aerr)
+		return runDirectory, aerr
+	}
+	if timeout != "" {
+		log.Error("task timed out", "reason", timeout, "duration", time.Since(start))
+		util.PrettyPrintErr(out, "The task %q timed out: %s", t.name, timeout)
+		// keep the output of the playbook until it was terminated
+		if serr := ansibleLogFile.Sync(); serr != nil {
+			log.Warn("error syncing the ansible log", "error", serr)
+		}
+		util.PrettyPrintWarn(out, "The output of the playbook until the timeout was recorded in %s", ansibleLogFilename)
+		terr := TimeoutError{Task: t.name, Reason: timeout, Log: ansibleLogFilename}
+		if ae.options.DiagnoseOnTimeout || ae.options.DiagnoseOnFailure {
+			terr.Diagnostics = ae.diagnoseFailure(t, nil)
+		}
+		span.RecordError(terr)
+		return runDirectory, terr
+	}
+	if err != nil {
+		log.Error("task failed", "error", err, "duration", time.Since(start))
+		span.RecordError(err)
+		return runDirectory, fmt.Errorf("error running playbook: %v", err)
+	}
+	log.Info("task completed", "duration", time.Since(start))
+	return runDirectory, nil
 }
 
 // GenerateCertificatesprivate generates keys and certificates for the cluster, if needed
 func (ae *ansibleExecutor) GenerateCertificates(p *Plan, useExistingCA bool) error {
-	if err := os.MkdirAll(ae.certsDir, 0777); err != nil {
-		return fmt.Errorf("error creating directory %s for storing TLS assets: %v", ae.certsDir, err)
+	span := tracing.Start("generate certificates", "kismatic.use_existing_ca", useExistingCA)
+	defer span.End()
+	if err := VerifyAssetsCluster(p, ae.options.GeneratedAssetsDirectory); err != nil {
+		return err
+	}
+	if !ae.options.DryRun {
+		if err := os.MkdirAll(ae.certsDir, 0777); err != nil {
+			return fmt.Errorf("error creating directory %s for storing TLS assets: %v", ae.certsDir, err)
+		}
 	}
 
 	// Generate cluster Certificate Authority
@@ -275,84 +746,336 @@ func (ae *ansibleExecutor) GenerateCertificates(p *Plan, useExistingCA bool) err
 		return fmt.Errorf("error generating certificates for the cluster: %v", err)
 	}
 
+	if ae.options.DryRun {
+		util.PrettyPrintOk(ae.stdout, "Dry run, no certificates were written to the %q directory", ae.options.GeneratedAssetsDirectory)
+		return nil
+	}
+	if err := writeAssetsManifest(p, ae.options.GeneratedAssetsDirectory); err != nil {
+		return err
+	}
 	util.PrettyPrintOk(ae.stdout, "Cluster certificates can be found in the %q directory", ae.options.GeneratedAssetsDirectory)
 	return nil
 }
 
-// Install the cluster according to the installation plan
-func (ae *ansibleExecutor) Install(p *Plan, restartServices bool, nodes ...string) error {
+// Install the cluster according to the installation plan, and return the
+// summary of the tasks that were run
+func (ae *ansibleExecutor) Install(p *Plan, restartServices bool, nodes ...string) (RunSummary, error) {
+	return ae.ResumeInstall(p, restartServices, "", nodes...)
+}
+
+// ResumeInstall installs the cluster, resuming the installation recorded in
+// the resumeFrom run directory when it is set
+func (ae *ansibleExecutor) ResumeInstall(p *Plan, restartServices bool, resumeFrom string, nodes ...string) (RunSummary, error) {
+	nodes, err := p.ResolveLimit(nodes)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	summary, err := ae.createServiceAccount(p, nodes...)
+	if err != nil {
+		return summary, err
+	}
 	// Build the ansible inventory
 	cc, err := ae.buildClusterCatalog(p)
 	if err != nil {
-		return err
-	}
-	if restartServices {
-		cc.EnableRestart()
+		return summary, err
 	}
+	ae.enableRestart(cc, restartServices)
 	t := task{
 		name:           "apply",
 		playbook:       "kubernetes.yaml",
 		plan:           *p,
-		inventory:      buildInventoryFromPlan(p),
+		inventory:      ae.buildInventory(p),
 		clusterCatalog: *cc,
-		explainer:      ae.defaultExplainer(),
+		explainer:      explain.HidePlay(ae.defaultExplainer(), phaseMarkerPlayName),
 		limit:          nodes,
 	}
+	if ae.options.DryRun {
+		return ae.execute(t)
+	}
+	var state *installState
+	var resumed *Checkpoint
+	if resumeFrom != "" {
+		state, resumed, err = ae.resumeState(p, resumeFrom)
+	} else {
+		state, err = ae.installState(p)
+	}
+	if err != nil {
+		return summary, err
+	}
+	// The nodes that were already installed are never rolled back, so that a
+	// failed installation does not reset a working cluster
+	var installed map[string]bool
+	if ae.options.RollbackOnFailure {
+		if installed, err = ae.installedHosts(p); err != nil {
+			return summary, err
+		}
+	}
+	t.runDirectory, err = ae.createRunDirectory(t.name)
+	if err != nil {
+		return summary, fmt.Errorf("error creating working directory for %q: %v", t.name, err)
+	}
+	t.clusterCatalog.CompletedPhases = state.CompletedPhases
+	phases := newPhaseRecorder()
+	checkpoints := &checkpointRecorder{}
+	touched := newTouchedHostsRecorder()
+	// The recorders are read once execute observed the last events
+	t.observers = []ansibleEventObserver{phases, checkpoints, touched}
 	util.PrintHeader(ae.stdout, "Installing Cluster", '=')
-	return ae.execute(t)
+	switch {
+	case resumed != nil && resumed.LastCompletedPlay != "":
+		util.PrettyPrintWarn(ae.stdout, "Resuming the installation after the play %q, the completed phases are skipped on the nodes", resumed.LastCompletedPlay)
+	case resumed != nil:
+		util.PrettyPrintWarn(ae.stdout, "Resuming the installation, the completed phases are skipped on the nodes")
+	case len(state.CompletedPhases) > 0:
+		util.PrettyPrintWarn(ae.stdout, "Resuming a failed installation, the completed phases are skipped on the nodes (use --force-full to run all the phases)")
+	}
+	for _, s := range state.skippedPhases() {
+		fmt.Fprintf(ae.stdout, "  - %s\n", s)
+	}
+	taskSummary, err := ae.execute(t)
+	summary = summary.Merge(taskSummary)
+	if err != nil {
+		state.record(phases.finish())
+	}
+	if err != nil && ae.options.RollbackOnFailure && !IsAborted(err) {
+		var rolledBack []string
+		var rollbackSummary RunSummary
+		rolledBack, rollbackSummary, err = ae.rollbackInstall(p, touched.touched(), installed, err)
+		summary = summary.Merge(rollbackSummary)
+		if len(rolledBack) > 0 {
+			// The phases are run again on the nodes that were reset
+			state.clear(rolledBack...)
+		}
+	}
+	if werr := writeCheckpoint(t.runDirectory, checkpoints.checkpoint(*state, err)); werr != nil {
+		util.PrettyPrintWarn(ae.stdout, "Could not record the checkpoint of the installation: %v", werr)
+	}
+	if err == nil {
+		state.clear(nodes...)
+	}
+	if werr := state.write(ae.options.GeneratedAssetsDirectory); werr != nil {
+		util.PrettyPrintWarn(ae.stdout, "Could not record the completed phases of the installation: %v", werr)
+	}
+	return summary, err
+}
+
+// installState returns the state of the previous installation, which is
+// discarded when the plan changed or a full installation is forced.
+func (ae *ansibleExecutor) installState(p *Plan) (*installState, error) {
+	hash := planHash(p)
+	state, err := readInstallState(ae.options.GeneratedAssetsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || hash == "" || state.PlanHash != hash || ae.options.ForceFullInstall {
+		state = &installState{PlanHash: hash}
+	}
+	return state, nil
 }
 
-func (ae *ansibleExecutor) Reset(p *Plan, nodes ...string) error {
+// PreparePackages downloads the packages and container images required by the
+// nodes, without installing them
+func (ae *ansibleExecutor) PreparePackages(p *Plan, nodes ...string) error {
+	nodes, err := p.ResolveLimit(nodes)
+	if err != nil {
+		return err
+	}
+	if _, err := ae.createServiceAccount(p, nodes...); err != nil {
+		return err
+	}
 	cc, err := ae.buildClusterCatalog(p)
 	if err != nil {
 		return err
 	}
+	t := task{
+		name:           "prepare",
+		playbook:       "prepare.yaml",
+		plan:           *p,
+		inventory:      ae.buildInventory(p),
+		clusterCatalog: *cc,
+		explainer:      ae.defaultExplainer(),
+		limit:          nodes,
+	}
+	util.PrintHeader(ae.stdout, "Downloading Packages and Images", '=')
+	_, err = ae.execute(t)
+	return err
+}
+
+// Reset removes the cluster from the nodes, and returns the summary of the
+// tasks that were run
+func (ae *ansibleExecutor) Reset(p *Plan, opts ResetOptions, nodes ...string) (RunSummary, error) {
+	if err := opts.Validate(); err != nil {
+		return RunSummary{}, err
+	}
+	nodes, err := p.ResolveLimit(nodes)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	cc, err := ae.buildClusterCatalog(p)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	cc.Reset.Components = opts.Components
+	cc.Reset.Purge = opts.Purge
+	cc.Reset.BackupDir = resetBackupPath(time.Now())
 	t := task{
 		name:           "reset",
 		playbook:       "reset.yaml",
 		explainer:      ae.defaultExplainer(),
 		plan:           *p,
-		inventory:      buildInventoryFromPlan(p),
+		inventory:      ae.buildInventory(p),
 		clusterCatalog: *cc,
 		limit:          nodes,
 	}
 	util.PrintHeader(ae.stdout, "Resetting Nodes in the Cluster", '=')
-	return ae.execute(t)
+	summary, err := ae.execute(t)
+	if err != nil {
+		return summary, err
+	}
+	if opts.backedUp() {
+		util.PrettyPrintOk(ae.stdout, "The Kubernetes and etcd directories were backed up to %s on each node", cc.Reset.BackupDir)
+	}
+	return summary, nil
 }
 
-func (ae *ansibleExecutor) RunSmokeTest(p *Plan) error {
+// RunSmokeTest runs the smoke test using the configured engine, or the
+// kuberang playbook if no other engine was configured. The summary is empty
+// when the smoke test does not run a playbook.
+func (ae *ansibleExecutor) RunSmokeTest(p *Plan) (RunSummary, error) {
+	if ae.smokeTester != nil {
+		util.PrintHeader(ae.stdout, "Running Smoke Test", '=')
+		return RunSummary{}, ae.smokeTester.RunSmokeTest(p)
+	}
 	cc, err := ae.buildClusterCatalog(p)
 	if err != nil {
-		return err
+		return RunSummary{}, err
 	}
 	t := task{
 		name:           "smoketest",
 		playbook:       "smoketest.yaml",
 		explainer:      ae.defaultExplainer(),
 		plan:           *p,
-		inventory:      buildInventoryFromPlan(p),
+		inventory:      ae.buildInventory(p),
 		clusterCatalog: *cc,
 	}
 	util.PrintHeader(ae.stdout, "Running Smoke Test", '=')
 	return ae.execute(t)
 }
 
-// RunPreflightCheck against the nodes defined in the plan
-func (ae *ansibleExecutor) RunPreFlightCheck(p *Plan, nodes ...string) error {
+// RunNodeSmokeTest runs the native smoke test with the workload pinned to the
+// node, regardless of the smoke test engine
+func (ae *ansibleExecutor) RunNodeSmokeTest(p *Plan, host string) error {
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Running Smoke Test: %s", host), '=')
+	if ae.options.DryRun {
+		util.PrettyPrintSkipped(ae.stdout, "Skipping the smoke test due to dry run")
+		return nil
+	}
+	tester, ok := ae.smokeTester.(*nativeSmokeTester)
+	if !ok {
+		tester = newNativeSmokeTester(ae.stdout)
+	}
+	return tester.RunNodeSmokeTest(p, host)
+}
+
+// createServiceAccount creates the service account on the nodes, connecting
+// as the SSH user of the plan. It does nothing when the plan does not have a
+// service account. Creating the account is idempotent.
+func (ae *ansibleExecutor) createServiceAccount(p *Plan, nodes ...string) (RunSummary, error) {
+	if p.Cluster.SSH.ServiceAccount == nil {
+		return RunSummary{}, nil
+	}
 	cc, err := ae.buildClusterCatalog(p)
 	if err != nil {
-		return err
+		return RunSummary{}, err
 	}
 	t := task{
-		name:           "preflight",
-		playbook:       "preflight.yaml",
-		inventory:      buildInventoryFromPlan(p),
-		clusterCatalog: *cc,
-		explainer:      ae.preflightExplainer(),
+		name:           "service-account",
+		playbook:       "service-account.yaml",
 		plan:           *p,
+		inventory:      buildInventoryWithSSHConfig(p, p.Cluster.SSH),
+		clusterCatalog: *cc,
+		explainer:      ae.defaultExplainer(),
 		limit:          nodes,
 	}
-	return ae.execute(t)
+	util.PrintHeader(ae.stdout, "Creating Service Account", '=')
+	summary, err := ae.execute(t)
+	if err != nil {
+		return summary, fmt.Errorf("error creating service account %q: %v", p.Cluster.SSH.ServiceAccount.User, err)
+	}
+	return summary, nil
+}
+
+// RunPreflightCheck against the nodes defined in the plan. The checks are run
+// on the nodes in parallel, and the result of each node is printed as soon as
+// it completes. The results of all the nodes are written to the pre-flight
+// report in the run directory.
+func (ae *ansibleExecutor) RunPreFlightCheck(p *Plan, nodes ...string) error {
+	nodes, err := p.ResolveLimit(nodes)
+	if err != nil {
+		return err
+	}
+	if _, err := ae.createServiceAccount(p, nodes...); err != nil {
+		return err
+	}
+	cc, err := ae.buildClusterCatalog(p)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		for _, n := range p.GetUniqueNodes() {
+			nodes = append(nodes, n.Host)
+		}
+	}
+	parallelism := ae.options.PreflightParallelism
+	if parallelism < 1 {
+		parallelism = defaultPreflightParallelism
+	}
+	util.PrintHeader(ae.stdout, "Running Pre-Flight Checks", '=')
+	if ae.options.DryRun {
+		return nil
+	}
+	runDirectory, err := ae.createRunDirectory("preflight")
+	if err != nil {
+		return fmt.Errorf("error creating working directory for %q: %v", "preflight", err)
+	}
+	report := PreflightReport{StartTime: time.Now()}
+	tasks := make([]task, 0, len(nodes))
+	recorders := make([]*preflightResultsRecorder, 0, len(nodes))
+	for _, n := range nodes {
+		r := &preflightResultsRecorder{}
+		recorders = append(recorders, r)
+		tasks = append(tasks, task{
+			name:           "preflight",
+			playbook:       "preflight.yaml",
+			inventory:      ae.buildInventory(p),
+			clusterCatalog: *cc,
+			plan:           *p,
+			limit:          []string{n},
+			runDirectory:   filepath.Join(runDirectory, n),
+			observers:      []ansibleEventObserver{r},
+		})
+	}
+	done := func(i int, err error) {
+		res := recorders[i].finish(nodes[i], err)
+		res.RunDirectory = tasks[i].runDirectory
+		report.Nodes = append(report.Nodes, res)
+		if res.Success {
+			util.PrettyPrintOk(ae.stdout, "Pre-flight checks passed on %q (%d/%d)", res.Host, len(report.Nodes), len(nodes))
+		} else {
+			util.PrettyPrintErr(ae.stdout, "Pre-flight checks failed on %q (%d/%d)", res.Host, len(report.Nodes), len(nodes))
+		}
+	}
+	ae.executeParallel(tasks, parallelism, ae.preflightExplainerTo, done)
+	report.EndTime = time.Now()
+	file, err := report.write(runDirectory)
+	if err != nil {
+		return err
+	}
+	util.PrettyPrintOk(ae.stdout, "Wrote pre-flight report to %q", file)
+	if failed := report.FailedNodes(); len(failed) > 0 {
+		return fmt.Errorf("pre-flight checks failed on %d node(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
 }
 
 // RunNewNodePreFlightCheck runs the preflight checks against a new node
@@ -364,31 +1087,35 @@ func (ae *ansibleExecutor) RunNewNodePreFlightCheck(p Plan, node Node) error {
 	t := task{
 		name:           "copy-inspector",
 		playbook:       "copy-inspector.yaml",
-		inventory:      buildInventoryFromPlan(&p),
+		inventory:      ae.buildInventory(&p),
 		clusterCatalog: *cc,
 		explainer:      ae.preflightExplainer(),
 		plan:           p,
 	}
-	if err := ae.execute(t); err != nil {
+	if _, err := ae.execute(t); err != nil {
 		return err
 	}
 
 	p.Worker.ExpectedCount++
 	p.Worker.Nodes = append(p.Worker.Nodes, node)
+	if _, err := ae.createServiceAccount(&p, node.Host); err != nil {
+		return err
+	}
 	t = task{
 		name:           "add-node-preflight",
 		playbook:       "preflight.yaml",
-		inventory:      buildInventoryFromPlan(&p),
+		inventory:      ae.buildInventory(&p),
 		clusterCatalog: *cc,
 		explainer:      ae.preflightExplainer(),
 		plan:           p,
 		limit:          []string{node.Host},
 	}
-	return ae.execute(t)
+	_, err = ae.execute(t)
+	return err
 }
 
 func (ae *ansibleExecutor) RunUpgradePreFlightCheck(p *Plan, node ListableNode) error {
-	inventory := buildInventoryFromPlan(p)
+	inventory := ae.buildInventory(p)
 	cc, err := ae.buildClusterCatalog(p)
 	if err != nil {
 		return err
@@ -396,12 +1123,12 @@ func (ae *ansibleExecutor) RunUpgradePreFlightCheck(p *Plan, node ListableNode)
 	t := task{
 		name:           "copy-inspector",
 		playbook:       "copy-inspector.yaml",
-		inventory:      buildInventoryFromPlan(p),
+		inventory:      ae.buildInventory(p),
 		clusterCatalog: *cc,
 		explainer:      ae.preflightExplainer(),
 		plan:           *p,
 	}
-	if err := ae.execute(t); err != nil {
+	if _, err := ae.execute(t); err != nil {
 		return err
 	}
 	t = task{
@@ -413,21 +1140,70 @@ func (ae *ansibleExecutor) RunUpgradePreFlightCheck(p *Plan, node ListableNode)
 		clusterCatalog: *cc,
 		limit:          []string{node.Node.Host},
 	}
-	return ae.execute(t)
+	_, err = ae.execute(t)
+	return err
 }
 
-func (ae *ansibleExecutor) RunPlay(playName string, p *Plan, restartServices bool, nodes ...string) error {
+// RunUpgradePreFlightChecks copies the inspector to the cluster nodes, and
+// then runs the upgrade preflight checks against each node in parallel.
+func (ae *ansibleExecutor) RunUpgradePreFlightChecks(p *Plan, nodes []ListableNode, parallelism int) map[string]error {
+	failed := map[string]error{}
 	cc, err := ae.buildClusterCatalog(p)
 	if err != nil {
-		return err
+		for _, n := range nodes {
+			failed[n.Node.Host] = err
+		}
+		return failed
+	}
+	t := task{
+		name:           "copy-inspector",
+		playbook:       "copy-inspector.yaml",
+		inventory:      ae.buildInventory(p),
+		clusterCatalog: *cc,
+		explainer:      ae.preflightExplainer(),
+		plan:           *p,
 	}
-	if restartServices {
-		cc.EnableRestart()
+	if _, err := ae.execute(t); err != nil {
+		for _, n := range nodes {
+			failed[n.Node.Host] = err
+		}
+		return failed
+	}
+	tasks := make([]task, 0, len(nodes))
+	for _, n := range nodes {
+		tasks = append(tasks, task{
+			name:           "upgrade-preflight",
+			playbook:       "upgrade-preflight.yaml",
+			plan:           *p,
+			inventory:      ae.buildInventory(p),
+			clusterCatalog: *cc,
+			limit:          []string{n.Node.Host},
+		})
 	}
+	for i, err := range ae.executeParallel(tasks, parallelism, ae.preflightExplainerTo, nil) {
+		if err != nil {
+			failed[nodes[i].Node.Host] = err
+		}
+	}
+	return failed
+}
+
+// RunPlay runs the playbook on the nodes, and returns the summary of the tasks
+// that were run
+func (ae *ansibleExecutor) RunPlay(playName string, p *Plan, restartServices bool, nodes ...string) (RunSummary, error) {
+	nodes, err := p.ResolveLimit(nodes)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	cc, err := ae.buildClusterCatalog(p)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	ae.enableRestart(cc, restartServices)
 	t := task{
 		name:           "step",
 		playbook:       playName,
-		inventory:      buildInventoryFromPlan(p),
+		inventory:      ae.buildInventory(p),
 		clusterCatalog: *cc,
 		explainer:      ae.defaultExplainer(),
 		plan:           *p,
@@ -436,11 +1212,25 @@ func (ae *ansibleExecutor) RunPlay(playName string, p *Plan, restartServices boo
 	return ae.execute(t)
 }
 
+// ListPlays returns the playbooks that can be run with RunPlay
+func (ae *ansibleExecutor) ListPlays() ([]Play, error) {
+	return ListPlays(filepath.Join(ae.ansibleDir, "playbooks"))
+}
+
+// AddVolume creates the storage volume. An InvalidStorageVolumeError is
+// returned if the volume is not valid, and an InsufficientStorageError if the
+// storage nodes do not have enough free disk space for the volume.
 func (ae *ansibleExecutor) AddVolume(plan *Plan, volume StorageVolume) error {
+	if ok, errs := volume.validate(); !ok {
+		return InvalidStorageVolumeError{Errs: errs}
+	}
+	if plan.heketiEnabled() {
+		util.PrintHeader(ae.stdout, "Add Persistent Storage Volume", '=')
+		return addHeketiVolume(ae.stdout, plan, volume, (*Plan).GetSSHClient)
+	}
 	// Validate that there are enough storage nodes to satisfy the request
-	nodesRequired := volume.ReplicateCount * volume.DistributionCount
-	if nodesRequired > len(plan.Storage.Nodes) {
-		return fmt.Errorf("the requested volume configuration requires %d storage nodes, but the cluster only has %d.", nodesRequired, len(plan.Storage.Nodes))
+	if err := validateStorageVolumeCapacity(plan, volume, (*Plan).GetSSHClient); err != nil {
+		return err
 	}
 
 	cc, err := ae.buildClusterCatalog(plan)
@@ -479,15 +1269,20 @@ func (ae *ansibleExecutor) AddVolume(plan *Plan, volume StorageVolume) error {
 		name:           "add-volume",
 		playbook:       "volume-add.yaml",
 		plan:           *plan,
-		inventory:      buildInventoryFromPlan(plan),
+		inventory:      ae.buildInventory(plan),
 		clusterCatalog: *cc,
 		explainer:      ae.defaultExplainer(),
 	}
 	util.PrintHeader(ae.stdout, "Add Persistent Storage Volume", '=')
-	return ae.execute(t)
+	_, err = ae.execute(t)
+	return err
 }
 
 func (ae *ansibleExecutor) DeleteVolume(plan *Plan, name string) error {
+	if plan.heketiEnabled() {
+		util.PrintHeader(ae.stdout, "Delete Persistent Storage Volume", '=')
+		return deleteHeketiVolume(ae.stdout, plan, name, (*Plan).GetSSHClient)
+	}
 	cc, err := ae.buildClusterCatalog(plan)
 	if err != nil {
 		return err
@@ -500,12 +1295,13 @@ func (ae *ansibleExecutor) DeleteVolume(plan *Plan, name string) error {
 		name:           "delete-volume",
 		playbook:       "volume-delete.yaml",
 		plan:           *plan,
-		inventory:      buildInventoryFromPlan(plan),
+		inventory:      ae.buildInventory(plan),
 		clusterCatalog: *cc,
 		explainer:      ae.defaultExplainer(),
 	}
 	util.PrintHeader(ae.stdout, "Delete Persistent Storage Volume", '=')
-	return ae.execute(t)
+	_, err = ae.execute(t)
+	return err
 }
 
 // UpgradeNodes upgrades the nodes of the cluster in the following phases:
@@ -516,41 +1312,43 @@ func (ae *ansibleExecutor) DeleteVolume(plan *Plan, name string) error {
 // When a node is being upgraded, all the components of the node are upgraded, regardless of
 // which phase of the upgrade we are in. For example, when upgrading a node that is both an etcd and master,
 // the etcd components and the master components will be upgraded when we are in the upgrade etcd nodes
-// phase.
-func (ae *ansibleExecutor) UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) error {
+// phase. The summary of the tasks run on the nodes that were upgraded so far is returned, even
+// when the upgrade fails.
+func (ae *ansibleExecutor) UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode, onlineUpgrade bool, maxParallelWorkers int, restartServices bool) (RunSummary, error) {
 	// Nodes can have multiple roles. For this reason, we need to keep track of which nodes
 	// have been upgraded to avoid re-upgrading them.
 	upgradedNodes := map[string]bool{}
+	etcdParallelism, masterParallelism := controlPlaneParallelism(plan, ae.options.MaxParallelControlPlane)
 	// Upgrade etcd nodes
+	var etcdNodes []ListableNode
 	for _, nodeToUpgrade := range nodesToUpgrade {
-		for _, role := range nodeToUpgrade.Roles {
-			if role == "etcd" {
-				node := nodeToUpgrade
-				if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, node); err != nil {
-					return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
-				}
-				upgradedNodes[node.Node.IP] = true
-				break
-			}
+		if util.Contains("etcd", nodeToUpgrade.Roles) {
+			etcdNodes = append(etcdNodes, nodeToUpgrade)
 		}
 	}
+	summary, err := ae.upgradeInBatches(plan, upgradeBatches(etcdNodes, etcdParallelism, masterParallelism), onlineUpgrade, restartServices)
+	if err != nil {
+		return summary, err
+	}
+	for _, n := range etcdNodes {
+		upgradedNodes[n.Node.IP] = true
+	}
 
 	// Upgrade master nodes
+	var masterNodes []ListableNode
 	for _, nodeToUpgrade := range nodesToUpgrade {
-		if upgradedNodes[nodeToUpgrade.Node.IP] == true {
-			continue
-		}
-		for _, role := range nodeToUpgrade.Roles {
-			if role == "master" {
-				node := nodeToUpgrade
-				if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, node); err != nil {
-					return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
-				}
-				upgradedNodes[node.Node.IP] = true
-				break
-			}
+		if !upgradedNodes[nodeToUpgrade.Node.IP] && util.Contains("master", nodeToUpgrade.Roles) {
+			masterNodes = append(masterNodes, nodeToUpgrade)
 		}
 	}
+	masterSummary, err := ae.upgradeInBatches(plan, upgradeBatches(masterNodes, masterParallelism, masterParallelism), onlineUpgrade, restartServices)
+	summary = summary.Merge(masterSummary)
+	if err != nil {
+		return summary, err
+	}
+	for _, n := range masterNodes {
+		upgradedNodes[n.Node.IP] = true
+	}
 
 	var limitNodes []ListableNode
 	// Upgrade the rest of the nodes
@@ -564,8 +1362,10 @@ func (ae *ansibleExecutor) UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode
 				limitNodes = append(limitNodes, node)
 				// don't forget to run the remaining nodes if its < maxParallelWorkers
 				if len(limitNodes) == maxParallelWorkers || n == len(nodesToUpgrade)-1 {
-					if err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, limitNodes...); err != nil {
-						return fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
+					workerSummary, err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, limitNodes...)
+					summary = summary.Merge(workerSummary)
+					if err != nil {
+						return summary, fmt.Errorf("error upgrading node %q: %v", node.Node.Host, err)
 					}
 					// empty the slice
 					limitNodes = limitNodes[:0]
@@ -575,19 +1375,46 @@ func (ae *ansibleExecutor) UpgradeNodes(plan Plan, nodesToUpgrade []ListableNode
 			}
 		}
 	}
-	return nil
+	return summary, nil
+}
+
+// upgradeInBatches upgrades the batches of nodes, one batch at a time
+func (ae *ansibleExecutor) upgradeInBatches(plan Plan, batches [][]ListableNode, onlineUpgrade bool, restartServices bool) (RunSummary, error) {
+	summary := RunSummary{}
+	for _, batch := range batches {
+		batchSummary, err := ae.upgradeNodes(plan, onlineUpgrade, restartServices, batch...)
+		summary = summary.Merge(batchSummary)
+		if err != nil {
+			if len(batch) == 1 {
+				return summary, fmt.Errorf("error upgrading node %q: %v", batch[0].Node.Host, err)
+			}
+			var hosts []string
+			for _, n := range batch {
+				hosts = append(hosts, n.Node.Host)
+			}
+			return summary, fmt.Errorf("error upgrading nodes %v: %v", hosts, err)
+		}
+	}
+	return summary, nil
 }
 
-func (ae *ansibleExecutor) upgradeNodes(plan Plan, onlineUpgrade bool, restartServices bool, nodes ...ListableNode) error {
-	inventory := buildInventoryFromPlan(&plan)
+func (ae *ansibleExecutor) upgradeNodes(plan Plan, onlineUpgrade bool, restartServices bool, nodes ...ListableNode) (RunSummary, error) {
+	if onlineUpgrade && !ae.options.DryRun && ae.disruptionBudgets != nil {
+		var err error
+		if nodes, err = ae.disruptionBudgets.check(&plan, nodes); err != nil {
+			return RunSummary{}, err
+		}
+		if len(nodes) == 0 {
+			return RunSummary{}, nil
+		}
+	}
+	inventory := ae.buildInventory(&plan)
 	cc, err := ae.buildClusterCatalog(&plan)
 	if err != nil {
-		return err
+		return RunSummary{}, err
 	}
 	cc.OnlineUpgrade = onlineUpgrade
-	if restartServices {
-		cc.EnableRestart()
-	}
+	ae.enableRestart(cc, restartServices)
 	var limit []string
 	nodeRoles := make(map[string][]string)
 	for _, node := range nodes {
@@ -613,7 +1440,7 @@ func (ae *ansibleExecutor) upgradeNodes(plan Plan, onlineUpgrade bool, restartSe
 }
 
 func (ae *ansibleExecutor) ValidateControlPlane(plan Plan) error {
-	inventory := buildInventoryFromPlan(&plan)
+	inventory := ae.buildInventory(&plan)
 	cc, err := ae.buildClusterCatalog(&plan)
 	if err != nil {
 		return err
@@ -626,20 +1453,53 @@ func (ae *ansibleExecutor) ValidateControlPlane(plan Plan) error {
 		plan:           plan,
 		explainer:      ae.defaultExplainer(),
 	}
-	return ae.execute(t)
+	if _, err := ae.execute(t); err != nil {
+		return err
+	}
+	return ae.validateLoadBalancer(plan)
 }
 
-func (ae *ansibleExecutor) UpgradeClusterServices(plan Plan) error {
-	inventory := buildInventoryFromPlan(&plan)
-	cc, err := ae.buildClusterCatalog(&plan)
+// validateLoadBalancer verifies that the load balanced FQDN routes to the
+// master nodes, and serves the API server certificates
+func (ae *ansibleExecutor) validateLoadBalancer(plan Plan) error {
+	util.PrettyPrint(ae.stdout, "Validating load balancer %q", plan.Master.LoadBalancedFQDN)
+	ca, err := ae.pki.GetClusterCA()
 	if err != nil {
+		util.PrintError(ae.stdout)
 		return err
 	}
+	check := loadBalancerCheck{Timeout: 10 * time.Second}
+	unreached, err := check.Run(&plan, ca.Cert)
+	if err != nil {
+		util.PrintError(ae.stdout)
+		return fmt.Errorf("error validating the load balancer: %v", err)
+	}
+	util.PrintOkln(ae.stdout)
+	if len(unreached) > 0 {
+		util.PrettyPrintWarn(ae.stdout, "The load balancer did not route to the master nodes %v", unreached)
+	}
+	return nil
+}
+
+// UpgradeClusterServices upgrades the services that run on the cluster, and
+// returns the summary of the tasks that were run
+func (ae *ansibleExecutor) UpgradeClusterServices(plan Plan) (RunSummary, error) {
+	inventory := ae.buildInventory(&plan)
+	cc, err := ae.buildClusterCatalog(&plan)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	catalog := *cc
+	if ae.options.HeapsterMigration.Enabled && heapsterEnabled(plan) {
+		catalog.HeapsterMigration.Enabled = true
+		catalog.HeapsterMigration.RemoveData = ae.options.HeapsterMigration.RemoveData
+		catalog.MetricsServer.Enabled = true
+	}
 	t := task{
 		name:           "upgrade-cluster-services",
 		playbook:       "upgrade-cluster-services.yaml",
 		inventory:      inventory,
-		clusterCatalog: *cc,
+		clusterCatalog: catalog,
 		plan:           plan,
 		explainer:      ae.defaultExplainer(),
 	}
@@ -647,15 +1507,27 @@ func (ae *ansibleExecutor) UpgradeClusterServices(plan Plan) error {
 }
 
 func (ae *ansibleExecutor) DiagnoseNodes(plan Plan) error {
-	inventory := buildInventoryFromPlan(&plan)
+	_, err := ae.diagnoseNodes(plan)
+	return err
+}
+
+// diagnoseNodes collects diagnostics from the nodes, or from all the nodes
+// when none are given, and returns the directory where they are stored
+func (ae *ansibleExecutor) diagnoseNodes(plan Plan, nodes ...string) (string, error) {
+	inventory := ae.buildInventory(&plan)
 	cc, err := ae.buildClusterCatalog(&plan)
 	if err != nil {
-		return err
+		return "", err
 	}
 	// dateTime will be appended to the diagnostics directory
 	now := time.Now().Format("2006-01-02-15-04-05")
 	cc.DiagnosticsDirectory = filepath.Join(ae.options.DiagnosticsDirecty, now)
 	cc.DiagnosticsDateTime = now
+	if profile := ae.options.PerformanceProfile; profile.Window > 0 {
+		cc.DiagnosticsPerformance.Enabled = true
+		cc.DiagnosticsPerformance.WindowSeconds = int(profile.Window.Seconds())
+		cc.DiagnosticsPerformance.IntervalSeconds = int(profile.Interval.Seconds())
+	}
 	t := task{
 		name:           "diagnose",
 		playbook:       "diagnose-nodes.yaml",
@@ -663,12 +1535,42 @@ func (ae *ansibleExecutor) DiagnoseNodes(plan Plan) error {
 		clusterCatalog: *cc,
 		plan:           plan,
 		explainer:      ae.defaultExplainer(),
+		limit:          nodes,
 	}
-	return ae.execute(t)
+	_, err = ae.execute(t)
+	return cc.DiagnosticsDirectory, err
 }
 
-// creates the extra vars that are required for the installation playbook.
+// buildClusterCatalog returns the extra vars that are required for the
+// installation playbook. The catalog is rendered once per plan.
 func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog, error) {
+	return ae.renderCache.catalog(p, ae.renderClusterCatalog)
+}
+
+// buildInventory returns the inventory of the plan. The inventory is built once per plan.
+func (ae *ansibleExecutor) buildInventory(p *Plan) ansible.Inventory {
+	return ae.renderCache.inventory(p)
+}
+
+// creates the extra vars that are required for the installation playbook.
+// writeGeneratedFile writes the file to the generated assets directory, and
+// returns its absolute path. The file is only readable by the owner, as it can
+// contain credentials.
+func (ae *ansibleExecutor) writeGeneratedFile(name string, b []byte) (string, error) {
+	if err := os.MkdirAll(ae.options.GeneratedAssetsDirectory, 0777); err != nil {
+		return "", fmt.Errorf("error creating directory %s: %v", ae.options.GeneratedAssetsDirectory, err)
+	}
+	file, err := filepath.Abs(filepath.Join(ae.options.GeneratedAssetsDirectory, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to determine absolute path to %s: %v", name, err)
+	}
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", file, err)
+	}
+	return file, nil
+}
+
+func (ae *ansibleExecutor) renderClusterCatalog(p *Plan) (*ansible.ClusterCatalog, error) {
 	tlsDir, err := filepath.Abs(ae.certsDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine absolute path to %s: %v", ae.certsDir, err)
@@ -681,16 +1583,18 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 
 	cc := ansible.ClusterCatalog{
 		ClusterName:                   p.Cluster.Name,
-		AdminPassword:                 p.Cluster.AdminPassword,
+		AdminPassword:                 adminPassword(p),
 		TLSDirectory:                  tlsDir,
 		ServicesCIDR:                  p.Cluster.Networking.ServiceCIDRBlock,
 		PodCIDR:                       p.Cluster.Networking.PodCIDRBlock,
 		DNSServiceIP:                  dnsIP,
 		EnableModifyHosts:             p.Cluster.Networking.UpdateHostsFiles,
-		EnablePackageInstallation:     !p.Cluster.DisablePackageInstallation,
+		EnablePackageInstallation:     !p.Cluster.DisablePackageInstallation && !p.Cluster.PrebuiltNodeImage,
 		KismaticPreflightCheckerLinux: filepath.Join("inspector", "linux", "amd64", "kismatic-inspector"),
 		KuberangPath:                  filepath.Join("kuberang", "linux", "amd64", "kuberang"),
 		DisconnectedInstallation:      p.Cluster.DisconnectedInstallation,
+		FIPS:                          p.Cluster.FIPS,
+		PrebuiltNodeImage:             p.Cluster.PrebuiltNodeImage,
 		HTTPProxy:                     p.Cluster.Networking.HTTPProxy,
 		HTTPSProxy:                    p.Cluster.Networking.HTTPSProxy,
 		TargetVersion:                 KismaticVersion.String(),
@@ -700,11 +1604,36 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 		KubeProxyOptions:              p.Cluster.KubeProxyOptions.Overrides,
 		KubeletOptions:                p.Cluster.KubeletOptions.Overrides,
 	}
+	if p.Cluster.FIPS {
+		cc.APIServerOptions = withFIPSTLSOptions(cc.APIServerOptions)
+		cc.KubeletOptions = withFIPSTLSOptions(cc.KubeletOptions)
+	}
+
+	artifactsDir := filepath.Join(ae.options.GeneratedAssetsDirectory, "artifacts")
+	cc.KismaticPreflightCheckerLinux, err = resolveArtifact(p.Cluster.Artifacts.Inspector, cc.KismaticPreflightCheckerLinux, artifactsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error getting inspector binary: %v", err)
+	}
+	cc.KuberangPath, err = resolveArtifact(p.Cluster.Artifacts.Kuberang, cc.KuberangPath, artifactsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error getting kuberang binary: %v", err)
+	}
+	cc.ImageCredentialProviders, err = imageCredentialProvidersCatalog(p.Cluster.ImageCredentialProviders, artifactsDir)
+	if err != nil {
+		return nil, err
+	}
 
 	// set versions
+	version, err := parseKubernetesVersion(p.Cluster.Version)
+	if err != nil {
+		return nil, fmt.Errorf("Cluster version %q invalid, %v", p.Cluster.Version, err)
+	}
 	cc.Versions.Kubernetes = p.Cluster.Version
-	cc.Versions.KubernetesYum = p.Cluster.Version[1:] + "-0"
-	cc.Versions.KubernetesDeb = p.Cluster.Version[1:] + "-00"
+	cc.Versions.KubernetesYum, cc.Versions.KubernetesDeb = kubernetesPackageVersions(version)
+
+	if err := ae.setAuthentication(&cc, p, version); err != nil {
+		return nil, err
+	}
 
 	cc.NoProxy = strings.Join(p.AllAddresses(), ",")
 	if p.Cluster.Networking.NoProxy != "" {
@@ -768,10 +1697,44 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 		}
 	}
 
+	cc.Tenants = tenantsCatalog(p.Tenants)
+
+	if sa := p.Cluster.SSH.ServiceAccount; sa != nil {
+		key, err := ssh.AuthorizedKey(p.Cluster.SSH.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the public SSH key of the service account: %v", err)
+		}
+		cc.ServiceAccount.Enabled = true
+		cc.ServiceAccount.User = sa.User
+		cc.ServiceAccount.AuthorizedKey = key
+		cc.ServiceAccount.SudoCommands = sa.SudoCommands
+	}
+
 	cc.EnableGluster = p.Storage.Nodes != nil && len(p.Storage.Nodes) > 0
 
 	cc.CloudProvider = p.Cluster.CloudProvider.Provider
 	cc.CloudConfig = p.Cluster.CloudProvider.Config
+	if vs := p.Cluster.CloudProvider.VSphere; vs != nil {
+		// the cloud config file is generated from the plan
+		cc.CloudConfig, err = ae.writeGeneratedFile("vsphere.conf", vsphereCloudConfig(vs))
+		if err != nil {
+			return nil, err
+		}
+		cc.VSphere.Enabled = true
+		cc.VSphere.StorageClass = vs.StorageClass
+		cc.VSphere.Datastore = vs.Datastore
+		cc.VSphere.DiskFormat = vs.DiskFormat
+	}
+	if az := p.Cluster.CloudProvider.Azure; az != nil {
+		b, err := azureCloudConfigJSON(az)
+		if err != nil {
+			return nil, err
+		}
+		cc.CloudConfig, err = ae.writeGeneratedFile("azure.json", b)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// additional files
 	for _, n := range p.AdditionalFiles {
@@ -781,6 +1744,15 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 			Hosts:       n.Hosts,
 		})
 	}
+	if ad := p.AssetDistribution; ad.Enabled() {
+		cc.AssetDistribution.Enabled = true
+		cc.AssetDistribution.BandwidthLimit = ad.BandwidthLimit
+		cc.AssetDistribution.PeerDistribution = ad.PeerDistribution
+		cc.AssetDistribution.Port = ad.Port
+		if ad.PeerDistribution {
+			cc.AssetDistribution.Seeds = assetDistributionSeeds(p.GetUniqueNodes())
+		}
+	}
 
 	// add_ons
 	cc.RunPodValidation = p.NetworkConfigured()
@@ -806,9 +1778,11 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 	cc.DNS.Enabled = !p.AddOns.DNS.Disable
 	cc.DNS.Provider = p.AddOns.DNS.Provider
 	cc.DNS.Options.Replicas = p.AddOns.DNS.Options.Replicas
+	cc.DNS.Options.UpstreamServers = p.AddOns.DNS.Options.UpstreamServers
+	cc.DNS.Options.BlockExternal = p.AddOns.DNS.Options.BlockExternal
 
 	// heapster
-	if p.AddOns.HeapsterMonitoring != nil && !p.AddOns.HeapsterMonitoring.Disable {
+	if heapsterEnabled(*p) {
 		cc.Heapster.Enabled = true
 		cc.Heapster.Options.Heapster.Replicas = p.AddOns.HeapsterMonitoring.Options.Heapster.Replicas
 		cc.Heapster.Options.Heapster.ServiceType = p.AddOns.HeapsterMonitoring.Options.Heapster.ServiceType
@@ -840,6 +1814,44 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 
 	cc.Rescheduler.Enabled = !p.AddOns.Rescheduler.Disable
 
+	// heketi
+	if p.heketiEnabled() {
+		h := p.AddOns.Heketi
+		cc.Heketi.Enabled = true
+		cc.Heketi.URL = h.URL
+		cc.Heketi.User = h.User
+		cc.Heketi.Secret = h.Secret
+		cc.Heketi.ClusterID = h.ClusterID
+		cc.Heketi.StorageClass = h.StorageClass
+		cc.Heketi.DefaultStorageClass = h.DefaultStorageClass
+	}
+
+	// local volume provisioner
+	if lvp := p.AddOns.LocalVolumeProvisioner; lvp != nil && !lvp.Disable {
+		cc.LocalVolumeProvisioner.Enabled = true
+		for _, sc := range lvp.StorageClasses {
+			cc.LocalVolumeProvisioner.StorageClasses = append(cc.LocalVolumeProvisioner.StorageClasses, ansible.LocalStorageClass{
+				Name:          sc.Name,
+				HostDir:       sc.HostDir,
+				VolumeMode:    sc.VolumeMode,
+				ReclaimPolicy: sc.ReclaimPolicy,
+				Default:       sc.Default,
+			})
+		}
+		cc.LocalVolumeProvisioner.NodeVolumes = make(map[string][]ansible.LocalVolume)
+		for _, n := range p.GetUniqueNodes() {
+			for _, lv := range n.LocalVolumes {
+				sc := p.localStorageClass(lv.StorageClass)
+				cc.LocalVolumeProvisioner.NodeVolumes[n.Host] = append(cc.LocalVolumeProvisioner.NodeVolumes[n.Host], ansible.LocalVolume{
+					Name:    localVolumeName(lv.Path),
+					Path:    lv.Path,
+					HostDir: sc.HostDir,
+					Block:   sc.VolumeMode == "Block",
+				})
+			}
+		}
+	}
+
 	// merge node labels
 	// cannot use inventory file because nodes share roles
 	// set it to a map[host][]key=value
@@ -869,19 +1881,87 @@ func (ae *ansibleExecutor) buildClusterCatalog(p *Plan) (*ansible.ClusterCatalog
 		cc.KubeletNodeOptions[n.Host] = n.KubeletOptions.Overrides
 	}
 
+	parallelism := ae.parallelism(*p)
+	cc.PlaySerial.Default = parallelism.Serial
+	if cc.PlaySerial.Default == "" {
+		cc.PlaySerial.Default = "100%"
+	}
+	cc.PlaySerial.Plays = parallelism.PlaySerial
+
 	return &cc, nil
 }
 
+// localVolumeName returns the name of the entry of a local volume in the
+// discovery directory of its StorageClass, which is derived from the path of
+// the volume on the node. For example, /mnt/disks/ssd0 is mnt-disks-ssd0.
+func localVolumeName(volumePath string) string {
+	return strings.Replace(strings.Trim(path.Clean(volumePath), "/"), "/", "-", -1)
+}
+
 func (ae *ansibleExecutor) createRunDirectory(runName string) (string, error) {
 	start := time.Now()
-	runDirectory := filepath.Join(ae.options.RunsDirectory, runName, start.Format("2006-01-02-15-04-05"))
-	if err := os.MkdirAll(runDirectory, 0777); err != nil {
+	parent := filepath.Join(ae.options.RunsDirectory, runName)
+	if err := os.MkdirAll(parent, 0777); err != nil {
 		return "", fmt.Errorf("error creating directory: %v", err)
 	}
-	return runDirectory, nil
+	// Tasks with the same name that are started within the same second (e.g.
+	// when running in parallel) get a suffix to keep their run directories apart
+	name := start.Format("2006-01-02-15-04-05")
+	for i := 1; ; i++ {
+		runDirectory := filepath.Join(parent, name)
+		err := os.Mkdir(runDirectory, 0777)
+		if err == nil {
+			return runDirectory, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("error creating directory: %v", err)
+		}
+		name = fmt.Sprintf("%s-%d", start.Format("2006-01-02-15-04-05"), i)
+	}
 }
 
-func (ae *ansibleExecutor) ansibleRunnerWithExplainer(explainer explain.AnsibleEventExplainer, ansibleLog io.Writer, runDirectory string) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+// executeParallel runs the tasks, running at most parallelism tasks at a
+// time. The output of each task is prefixed with the nodes it is limited to, or
+// with its name. The explainer of each task is created with newExplainer, so
+// that it writes to the prefixed output. When set, done is called as soon as
+// each task completes, while holding the lock of the output. The returned
+// errors are in the same order as the tasks.
+func (ae *ansibleExecutor) executeParallel(tasks []task, parallelism int, newExplainer func(io.Writer) explain.AnsibleEventExplainer, done func(i int, err error)) []error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	errs := make([]error, len(tasks))
+	mu := &sync.Mutex{}
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t := tasks[i]
+			label := t.name
+			if len(t.limit) > 0 {
+				label = strings.Join(t.limit, ",")
+			}
+			w := util.NewPrefixWriter(ae.stdout, mu, fmt.Sprintf("[%s] ", label))
+			t.out = w
+			t.explainer = newExplainer(w)
+			_, errs[i] = ae.execute(t)
+			w.Flush()
+			if done != nil {
+				mu.Lock()
+				done(i, errs[i])
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func (ae *ansibleExecutor) ansibleRunnerWithExplainer(explainer explain.AnsibleEventExplainer, out io.Writer, ansibleLog io.Writer, runDirectory string, forks int) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
 	if ae.runnerExplainerFactory != nil {
 		return ae.runnerExplainerFactory(explainer, ansibleLog)
 	}
@@ -892,11 +1972,15 @@ func (ae *ansibleExecutor) ansibleRunnerWithExplainer(explainer explain.AnsibleE
 	case ansible.JSONLinesFormat:
 		ansibleOut = timestampWriter(ansibleLog)
 	case ansible.RawFormat:
-		ansibleOut = io.MultiWriter(ae.stdout, timestampWriter(ansibleLog))
+		ansibleOut = io.MultiWriter(out, timestampWriter(ansibleLog))
 	}
 
 	// Send stdout and stderr to ansibleOut
-	runner, err := ansible.NewRunner(ansibleOut, ansibleOut, ae.ansibleDir, runDirectory)
+	runner, err := ansible.NewRunner(ansibleOut, ansibleOut, ae.ansibleDir, runDirectory, ansible.RunnerOptions{
+		ShredCredentials:    ae.options.ShredCredentials,
+		VaultClusterCatalog: ae.options.VaultClusterCatalog,
+		Forks:               forks,
+	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("error creating ansible runner: %v", err)
 	}
@@ -908,6 +1992,38 @@ func (ae *ansibleExecutor) ansibleRunnerWithExplainer(explainer explain.AnsibleE
 	return runner, streamExplainer, nil
 }
 
+// parallelism returns the parallelism of the plan, overridden by the options
+// of the executor that are set
+func (ae *ansibleExecutor) parallelism(p Plan) Parallelism {
+	parallelism := p.Cluster.Parallelism
+	if ae.options.Parallelism.Forks != 0 {
+		parallelism.Forks = ae.options.Parallelism.Forks
+	}
+	if ae.options.Parallelism.Serial != "" {
+		parallelism.Serial = ae.options.Parallelism.Serial
+	}
+	if len(ae.options.Parallelism.PlaySerial) > 0 {
+		plays := map[string]string{}
+		for play, serial := range parallelism.PlaySerial {
+			plays[play] = serial
+		}
+		for play, serial := range ae.options.Parallelism.PlaySerial {
+			plays[play] = serial
+		}
+		parallelism.PlaySerial = plays
+	}
+	return parallelism
+}
+
+// progressTracker returns a tracker of the progress of a playbook, or nil
+// when the progress is neither shown nor reported
+func (ae *ansibleExecutor) progressTracker() *explain.ProgressTracker {
+	if !ae.options.ShowProgress && ae.options.OnProgress == nil {
+		return nil
+	}
+	return explain.NewProgressTracker(ae.options.OnProgress)
+}
+
 func (ae *ansibleExecutor) defaultExplainer() explain.AnsibleEventExplainer {
 	var out io.Writer
 	switch ae.consoleOutputFormat {
@@ -916,43 +2032,102 @@ func (ae *ansibleExecutor) defaultExplainer() explain.AnsibleEventExplainer {
 	case ansible.RawFormat:
 		out = ioutil.Discard
 	}
-	return explain.DefaultExplainer(ae.options.Verbose, out)
+	// Filtering tasks implies explaining them
+	verbose := ae.options.Verbose || ae.filteringTasks()
+	var explainer explain.AnsibleEventExplainer
+	switch ae.options.OutputFormat {
+	case "table":
+		explainer = explain.TableExplainer(verbose, out)
+	case "ci":
+		explainer = explain.CIExplainer(out)
+	case "json":
+		explainer = explain.JSONExplainer(out)
+	default:
+		explainer = explain.DefaultExplainer(verbose, out)
+	}
+	return ae.filterTasks(explainer)
 }
 
 func (ae *ansibleExecutor) preflightExplainer() explain.AnsibleEventExplainer {
+	return ae.preflightExplainerTo(ae.stdout)
+}
+
+func (ae *ansibleExecutor) preflightExplainerTo(w io.Writer) explain.AnsibleEventExplainer {
 	var out io.Writer
 	switch ae.consoleOutputFormat {
 	case ansible.JSONLinesFormat:
-		out = ae.stdout
+		out = w
 	case ansible.RawFormat:
 		out = ioutil.Discard
 	}
-	return explain.PreflightExplainer(ae.options.Verbose, out)
+	if ae.options.OutputFormat == "ci" {
+		return ae.filterTasks(explain.CIPreflightExplainer(out))
+	}
+	if ae.options.OutputFormat == "json" {
+		return ae.filterTasks(explain.JSONExplainer(out))
+	}
+	verbose := ae.options.Verbose || ae.filteringTasks()
+	return ae.filterTasks(explain.PreflightExplainer(verbose, out))
+}
+
+func (ae *ansibleExecutor) filteringTasks() bool {
+	return ae.showTasks != nil || ae.hideTasks != nil
+}
+
+// filterTasks wraps the explainer so that it only explains the tasks selected
+// with the ShowTasks and HideTasks options
+func (ae *ansibleExecutor) filterTasks(explainer explain.AnsibleEventExplainer) explain.AnsibleEventExplainer {
+	if !ae.filteringTasks() {
+		return explainer
+	}
+	return explain.FilterTasks(explainer, ae.showTasks, ae.hideTasks)
+}
+
+// compileTaskFilters compiles the expressions used for selecting the tasks
+// that are explained
+func compileTaskFilters(options ExecutorOptions) (show *regexp.Regexp, hide *regexp.Regexp, err error) {
+	if options.ShowTasks != "" {
+		if show, err = regexp.Compile(options.ShowTasks); err != nil {
+			return nil, nil, fmt.Errorf("invalid expression for showing tasks %q: %v", options.ShowTasks, err)
+		}
+	}
+	if options.HideTasks != "" {
+		if hide, err = regexp.Compile(options.HideTasks); err != nil {
+			return nil, nil, fmt.Errorf("invalid expression for hiding tasks %q: %v", options.HideTasks, err)
+		}
+	}
+	return show, hide, nil
 }
 
 func buildInventoryFromPlan(p *Plan) ansible.Inventory {
+	return buildInventoryWithSSHConfig(p, p.Cluster.SSH.operationConfig())
+}
+
+// buildInventoryWithSSHConfig returns the inventory of the plan, with the
+// nodes accessed using the given SSH configuration
+func buildInventoryWithSSHConfig(p *Plan, sshConfig SSHConfig) ansible.Inventory {
 	etcdNodes := []ansible.Node{}
 	for _, n := range p.Etcd.Nodes {
-		etcdNodes = append(etcdNodes, installNodeToAnsibleNode(&n, &p.Cluster.SSH))
+		etcdNodes = append(etcdNodes, installNodeToAnsibleNode(&n, &sshConfig))
 	}
 	masterNodes := []ansible.Node{}
 	for _, n := range p.Master.Nodes {
-		masterNodes = append(masterNodes, installNodeToAnsibleNode(&n, &p.Cluster.SSH))
+		masterNodes = append(masterNodes, installNodeToAnsibleNode(&n, &sshConfig))
 	}
 	workerNodes := []ansible.Node{}
 	for _, n := range p.Worker.Nodes {
-		workerNodes = append(workerNodes, installNodeToAnsibleNode(&n, &p.Cluster.SSH))
+		workerNodes = append(workerNodes, installNodeToAnsibleNode(&n, &sshConfig))
 	}
 	ingressNodes := []ansible.Node{}
 	if p.Ingress.Nodes != nil {
 		for _, n := range p.Ingress.Nodes {
-			ingressNodes = append(ingressNodes, installNodeToAnsibleNode(&n, &p.Cluster.SSH))
+			ingressNodes = append(ingressNodes, installNodeToAnsibleNode(&n, &sshConfig))
 		}
 	}
 	storageNodes := []ansible.Node{}
 	if p.Storage.Nodes != nil {
 		for _, n := range p.Storage.Nodes {
-			storageNodes = append(storageNodes, installNodeToAnsibleNode(&n, &p.Cluster.SSH))
+			storageNodes = append(storageNodes, installNodeToAnsibleNode(&n, &sshConfig))
 		}
 	}
 
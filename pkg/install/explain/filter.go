@@ -0,0 +1,127 @@
+package explain
+
+import (
+	"regexp"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// FilterTasks returns an explainer that only forwards the events of the tasks
+// that should be shown to the given explainer. A task is shown when show is nil
+// or matches the task, and hide is nil or does not match the task. An
+// expression matches a task if it matches the name of the task, or the name of
+// the play that contains it.
+//
+// Play events are always forwarded. The failures of hidden tasks are also
+// forwarded, preceded by the start of the task that failed.
+func FilterTasks(explainer AnsibleEventExplainer, show, hide *regexp.Regexp) AnsibleEventExplainer {
+	return &taskFilter{
+		explainer: explainer,
+		show:      show,
+		hide:      hide,
+	}
+}
+
+type taskFilter struct {
+	explainer AnsibleEventExplainer
+	show      *regexp.Regexp
+	hide      *regexp.Regexp
+
+	currentPlayName string
+	hidden          bool
+	// the start of the hidden task, forwarded if the task fails
+	hiddenTaskStart ansible.Event
+}
+
+func (f *taskFilter) ExplainEvent(ansibleEvent ansible.Event) {
+	switch event := ansibleEvent.(type) {
+	case *ansible.PlaybookStartEvent, *ansible.PlaybookEndEvent:
+		f.explainer.ExplainEvent(ansibleEvent)
+		return
+	case *ansible.PlayStartEvent:
+		f.currentPlayName = event.Name
+		f.hidden = false
+		f.hiddenTaskStart = nil
+	case *ansible.TaskStartEvent:
+		f.startTask(event.Name, event)
+	case *ansible.HandlerTaskStartEvent:
+		f.startTask(event.Name, event)
+	case *ansible.RunnerFailedEvent:
+		if !event.IgnoreErrors {
+			f.reveal()
+		}
+	case *ansible.RunnerItemFailedEvent:
+		if !event.IgnoreErrors {
+			f.reveal()
+		}
+	case *ansible.RunnerUnreachableEvent:
+		f.reveal()
+	}
+	if f.hidden {
+		return
+	}
+	f.explainer.ExplainEvent(ansibleEvent)
+}
+
+func (f *taskFilter) startTask(name string, event ansible.Event) {
+	f.hidden = !f.shown(name)
+	f.hiddenTaskStart = nil
+	if f.hidden {
+		f.hiddenTaskStart = event
+	}
+}
+
+func (f *taskFilter) shown(task string) bool {
+	matches := func(re *regexp.Regexp) bool {
+		return re.MatchString(task) || re.MatchString(f.currentPlayName)
+	}
+	if f.show != nil && !matches(f.show) {
+		return false
+	}
+	if f.hide != nil && matches(f.hide) {
+		return false
+	}
+	return true
+}
+
+// reveal forwards the start of the hidden task, so that its failures are
+// explained
+func (f *taskFilter) reveal() {
+	if !f.hidden {
+		return
+	}
+	if f.hiddenTaskStart != nil {
+		f.explainer.ExplainEvent(f.hiddenTaskStart)
+	}
+	f.hidden = false
+	f.hiddenTaskStart = nil
+}
+
+// HidePlay returns an explainer that does not forward the events of the plays
+// with the given name to the given explainer. It is used for plays that are
+// run for bookkeeping purposes, and are not meaningful to the user.
+func HidePlay(explainer AnsibleEventExplainer, name string) AnsibleEventExplainer {
+	return &playFilter{
+		explainer: explainer,
+		name:      name,
+	}
+}
+
+type playFilter struct {
+	explainer AnsibleEventExplainer
+	name      string
+	hidden    bool
+}
+
+func (f *playFilter) ExplainEvent(ansibleEvent ansible.Event) {
+	switch event := ansibleEvent.(type) {
+	case *ansible.PlayStartEvent:
+		f.hidden = event.Name == f.name
+	case *ansible.PlaybookEndEvent:
+		f.hidden = false
+	}
+	if f.hidden {
+		return
+	}
+	f.explainer.ExplainEvent(ansibleEvent)
+}
@@ -0,0 +1,106 @@
+package explain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+type recordingExplainer struct {
+	events []string
+}
+
+func (r *recordingExplainer) ExplainEvent(e ansible.Event) {
+	switch event := e.(type) {
+	case *ansible.PlayStartEvent:
+		r.events = append(r.events, "play "+event.Name)
+	case *ansible.TaskStartEvent:
+		r.events = append(r.events, "task "+event.Name)
+	case *ansible.RunnerOKEvent:
+		r.events = append(r.events, "ok "+event.Host)
+	case *ansible.RunnerFailedEvent:
+		r.events = append(r.events, "failed "+event.Host)
+	default:
+		r.events = append(r.events, fmt.Sprintf("%T", e))
+	}
+}
+
+const filteredEvents = `{"eventType":"PLAYBOOK_START","eventData":{"name":"kubernetes.yaml"}}
+{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"install etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{}}}
+{"eventType":"PLAY_START","eventData":{"name":"master"}}
+{"eventType":"TASK_START","eventData":{"name":"install apiserver"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"master01","result":{}}}
+{"eventType":"TASK_START","eventData":{"name":"check etcd health"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"master01","result":{}}}
+{"eventType":"TASK_START","eventData":{"name":"start apiserver"}}
+{"eventType":"RUNNER_FAILED","eventData":{"host":"master01","result":{"msg":"timeout"}}}
+{"eventType":"PLAYBOOK_END","eventData":{"name":"kubernetes.yaml"}}
+`
+
+func TestFilterTasks(t *testing.T) {
+	tests := []struct {
+		show     string
+		hide     string
+		expected []string
+	}{
+		{
+			show: "etcd",
+			expected: []string{
+				"*ansible.PlaybookStartEvent",
+				"play etcd", "task install etcd", "ok etcd01",
+				"play master", "task check etcd health", "ok master01",
+				// failures of hidden tasks are always shown
+				"task start apiserver", "failed master01",
+				"*ansible.PlaybookEndEvent",
+			},
+		},
+		{
+			hide: "^install",
+			expected: []string{
+				"*ansible.PlaybookStartEvent",
+				"play etcd",
+				"play master", "task check etcd health", "ok master01",
+				"task start apiserver", "failed master01",
+				"*ansible.PlaybookEndEvent",
+			},
+		},
+	}
+	for i, test := range tests {
+		var show, hide *regexp.Regexp
+		if test.show != "" {
+			show = regexp.MustCompile(test.show)
+		}
+		if test.hide != "" {
+			hide = regexp.MustCompile(test.hide)
+		}
+		r := &recordingExplainer{}
+		e := FilterTasks(r, show, hide)
+		for ev := range ansible.EventStream(strings.NewReader(filteredEvents)) {
+			e.ExplainEvent(ev)
+		}
+		if strings.Join(r.events, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("test %d: expected events:\n%s\nbut got:\n%s", i, strings.Join(test.expected, "\n"), strings.Join(r.events, "\n"))
+		}
+	}
+}
+
+func TestHidePlay(t *testing.T) {
+	r := &recordingExplainer{}
+	e := HidePlay(r, "master")
+	for ev := range ansible.EventStream(strings.NewReader(filteredEvents)) {
+		e.ExplainEvent(ev)
+	}
+	expected := []string{
+		"*ansible.PlaybookStartEvent",
+		"play etcd", "task install etcd", "ok etcd01",
+		"*ansible.PlaybookEndEvent",
+	}
+	if strings.Join(r.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected events:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(r.events, "\n"))
+	}
+}
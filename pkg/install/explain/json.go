@@ -0,0 +1,153 @@
+package explain
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// Types of the JSON events
+const (
+	JSONPlaybookStart = "playbook_start"
+	JSONPlaybookEnd   = "playbook_end"
+	JSONPlayStart     = "play_start"
+	JSONPlayEnd       = "play_end"
+	JSONTaskStart     = "task_start"
+	JSONTaskResult    = "task_result"
+)
+
+// JSONEvent is the progress of a playbook, written as a line of JSON
+type JSONEvent struct {
+	Time time.Time `json:"time"`
+	// Type of the event
+	Type     string `json:"type"`
+	Playbook string `json:"playbook,omitempty"`
+	Play     string `json:"play,omitempty"`
+	Task     string `json:"task,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Item     string `json:"item,omitempty"`
+	// Status of the task on the host: ok, changed, failed, ignored, skipped,
+	// unreachable or retrying. The status of a play is ok or failed.
+	Status string `json:"status,omitempty"`
+	// Duration since the start of the task, or of the play, in seconds
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	Stdout   string  `json:"stdout,omitempty"`
+	Stderr   string  `json:"stderr,omitempty"`
+}
+
+// JSONExplainer returns an explainer that writes each event as a line of
+// JSON, for the CI systems and the user interfaces that follow the progress
+// of the operations
+func JSONExplainer(out io.Writer) AnsibleEventExplainer {
+	return &jsonExplainer{encoder: json.NewEncoder(out), now: time.Now}
+}
+
+type jsonExplainer struct {
+	encoder    *json.Encoder
+	now        func() time.Time
+	playbook   string
+	play       string
+	playStart  time.Time
+	playFailed bool
+	task       string
+	taskStart  time.Time
+}
+
+func (e *jsonExplainer) ExplainEvent(ansibleEvent ansible.Event) {
+	now := e.now()
+	switch event := ansibleEvent.(type) {
+	case *ansible.PlaybookStartEvent:
+		e.playbook = event.Name
+		e.write(JSONEvent{Time: now, Type: JSONPlaybookStart})
+	case *ansible.PlaybookEndEvent:
+		e.endPlay(now)
+		e.write(JSONEvent{Time: now, Type: JSONPlaybookEnd})
+	case *ansible.PlayStartEvent:
+		e.endPlay(now)
+		e.play, e.playStart, e.playFailed = event.Name, now, false
+		e.task = ""
+		e.write(JSONEvent{Time: now, Type: JSONPlayStart})
+	case *ansible.TaskStartEvent:
+		e.startTask(now, event.Name)
+	case *ansible.HandlerTaskStartEvent:
+		e.startTask(now, event.Name)
+	case *ansible.RunnerOKEvent:
+		status := "ok"
+		if event.Result.Changed {
+			status = "changed"
+		}
+		e.result(now, event.Host, event.Result.Item, status, nil)
+	case *ansible.RunnerItemOKEvent:
+		status := "ok"
+		if event.Result.Changed {
+			status = "changed"
+		}
+		e.result(now, event.Host, event.Result.Item, status, nil)
+	case *ansible.RunnerSkippedEvent:
+		e.result(now, event.Host, event.Result.Item, "skipped", nil)
+	case *ansible.RunnerItemRetryEvent:
+		e.result(now, event.Host, event.Result.Item, "retrying", nil)
+	case *ansible.RunnerFailedEvent:
+		e.failed(now, event.Host, event.IgnoreErrors, JSONEvent{Item: event.Result.Item, Error: event.Result.Message, Stdout: event.Result.Stdout, Stderr: event.Result.Stderr})
+	case *ansible.RunnerItemFailedEvent:
+		e.failed(now, event.Host, event.IgnoreErrors, JSONEvent{Item: event.Result.Item, Error: event.Result.Message, Stdout: event.Result.Stdout, Stderr: event.Result.Stderr})
+	case *ansible.RunnerUnreachableEvent:
+		e.playFailed = true
+		e.result(now, event.Host, "", "unreachable", &JSONEvent{Error: event.Result.Message})
+	}
+}
+
+func (e *jsonExplainer) startTask(now time.Time, name string) {
+	e.task, e.taskStart = name, now
+	e.write(JSONEvent{Time: now, Type: JSONTaskStart})
+}
+
+func (e *jsonExplainer) failed(now time.Time, host string, ignored bool, details JSONEvent) {
+	if ignored {
+		e.result(now, host, details.Item, "ignored", &details)
+		return
+	}
+	e.playFailed = true
+	e.result(now, host, details.Item, "failed", &details)
+}
+
+func (e *jsonExplainer) result(now time.Time, host, item, status string, details *JSONEvent) {
+	ev := JSONEvent{Time: now, Type: JSONTaskResult, Host: host, Item: item, Status: status}
+	if !e.taskStart.IsZero() {
+		ev.Duration = now.Sub(e.taskStart).Seconds()
+	}
+	if details != nil {
+		ev.Error, ev.Stdout, ev.Stderr = details.Error, details.Stdout, details.Stderr
+	}
+	e.write(ev)
+}
+
+// endPlay writes the end of the current play, if any
+func (e *jsonExplainer) endPlay(now time.Time) {
+	if e.play == "" {
+		return
+	}
+	status := "ok"
+	if e.playFailed {
+		status = "failed"
+	}
+	e.task = ""
+	e.write(JSONEvent{Time: now, Type: JSONPlayEnd, Status: status, Duration: now.Sub(e.playStart).Seconds()})
+	e.play = ""
+}
+
+// write the event with the current playbook, play and task
+func (e *jsonExplainer) write(ev JSONEvent) {
+	ev.Playbook = e.playbook
+	if ev.Play == "" {
+		ev.Play = e.play
+	}
+	if ev.Task == "" {
+		ev.Task = e.task
+	}
+	// Errors writing the output are ignored, as with the other explainers
+	e.encoder.Encode(ev)
+}
@@ -0,0 +1,59 @@
+package explain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestJSONExplainerWritesEventPerLine(t *testing.T) {
+	out := &bytes.Buffer{}
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	e := &jsonExplainer{encoder: json.NewEncoder(out), now: func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}}
+	events := `{"eventType":"PLAYBOOK_START","eventData":{"name":"kubernetes.yaml"}}
+{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"install etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{"changed":true}}}
+{"eventType":"RUNNER_FAILED","eventData":{"host":"etcd02","result":{"msg":"package not found","stderr":"no package"}}}
+{"eventType":"PLAYBOOK_END","eventData":{}}
+`
+	for ev := range ansible.EventStream(strings.NewReader(events)) {
+		e.ExplainEvent(ev)
+	}
+	var got []JSONEvent
+	s := bufio.NewScanner(out)
+	for s.Scan() {
+		var ev JSONEvent
+		if err := json.Unmarshal(s.Bytes(), &ev); err != nil {
+			t.Fatalf("expected a JSON event per line, but got %q: %v", s.Text(), err)
+		}
+		got = append(got, ev)
+	}
+	expected := []JSONEvent{
+		{Type: JSONPlaybookStart, Playbook: "kubernetes.yaml"},
+		{Type: JSONPlayStart, Playbook: "kubernetes.yaml", Play: "etcd"},
+		{Type: JSONTaskStart, Playbook: "kubernetes.yaml", Play: "etcd", Task: "install etcd"},
+		{Type: JSONTaskResult, Playbook: "kubernetes.yaml", Play: "etcd", Task: "install etcd", Host: "etcd01", Status: "changed", Duration: 1},
+		{Type: JSONTaskResult, Playbook: "kubernetes.yaml", Play: "etcd", Task: "install etcd", Host: "etcd02", Status: "failed", Duration: 2, Error: "package not found", Stderr: "no package"},
+		{Type: JSONPlayEnd, Playbook: "kubernetes.yaml", Play: "etcd", Status: "failed", Duration: 4},
+		{Type: JSONPlaybookEnd, Playbook: "kubernetes.yaml"},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d events, but got %d:\n%s", len(expected), len(got), out.String())
+	}
+	for i := range expected {
+		got[i].Time = time.Time{}
+		if got[i] != expected[i] {
+			t.Errorf("event %d: expected %+v, but got %+v", i, expected[i], got[i])
+		}
+	}
+}
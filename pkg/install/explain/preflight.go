@@ -29,6 +29,15 @@ func PreflightExplainer(verbose bool, out io.Writer) AnsibleEventExplainer {
 	}
 }
 
+// CIPreflightExplainer is an explainer to be used when running preflight checks
+// in CI systems. It only writes complete lines.
+func CIPreflightExplainer(out io.Writer) AnsibleEventExplainer {
+	return &verbosePreflightExplainer{
+		out:       out,
+		explainer: verboseExplainer{out: out, completeLines: true},
+	}
+}
+
 type updatingPreflightExplainer struct {
 	out       *uilive.Writer
 	explainer updatingExplainer
@@ -0,0 +1,171 @@
+package explain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// Progress of a playbook
+type Progress struct {
+	Playbook string
+	// Plays is the number of plays of the playbook
+	Plays int
+	// CompletedPlays is the number of plays that completed
+	CompletedPlays int
+	// Play and Task are the play and the task that are running
+	Play string
+	Task string
+	// Tasks is the number of tasks that started
+	Tasks int
+	// Percent of the plays of the playbook that completed
+	Percent int
+	// Failed is true when a task failed on a node
+	Failed bool
+	// Elapsed is the time since the playbook started
+	Elapsed time.Duration
+	// Remaining is the estimated time until the playbook completes, based
+	// on the average duration of the plays that completed. It is zero until
+	// a play completes.
+	Remaining time.Duration
+}
+
+// String returns the percentage, the elapsed time and the estimated
+// remaining time
+func (p Progress) String() string {
+	s := fmt.Sprintf("%d%% | %s elapsed", p.Percent, p.Elapsed.Round(time.Second))
+	if p.Remaining > 0 {
+		s += fmt.Sprintf(" | about %s remaining", p.Remaining.Round(time.Second))
+	}
+	return s
+}
+
+// ProgressTracker tracks the progress of a playbook from its events. The
+// number of plays of the playbook is known when it starts, while the number
+// of tasks of each play is not, so the progress is the share of the plays
+// that completed.
+type ProgressTracker struct {
+	mu         sync.Mutex
+	now        func() time.Time
+	onProgress func(Progress)
+	progress   Progress
+	start      time.Time
+	played     int
+}
+
+// NewProgressTracker returns a tracker that calls onProgress when the
+// playbook, a play or a task starts, and when the playbook ends. onProgress
+// may be nil.
+func NewProgressTracker(onProgress func(Progress)) *ProgressTracker {
+	return &ProgressTracker{now: time.Now, onProgress: onProgress}
+}
+
+// ExplainEvent updates the progress with the event
+func (t *ProgressTracker) ExplainEvent(ansibleEvent ansible.Event) {
+	t.mu.Lock()
+	now := t.now()
+	notify := true
+	switch event := ansibleEvent.(type) {
+	case *ansible.PlaybookStartEvent:
+		t.start, t.played = now, 0
+		t.progress = Progress{Playbook: event.Name, Plays: event.Count}
+	case *ansible.PlayStartEvent:
+		if t.played > 0 {
+			t.progress.CompletedPlays = t.played
+		}
+		t.played++
+		t.progress.Play, t.progress.Task = event.Name, ""
+	case *ansible.TaskStartEvent:
+		t.progress.Task = event.Name
+		t.progress.Tasks++
+	case *ansible.HandlerTaskStartEvent:
+		t.progress.Task = event.Name
+		t.progress.Tasks++
+	case *ansible.RunnerFailedEvent:
+		t.progress.Failed = t.progress.Failed || !event.IgnoreErrors
+		notify = false
+	case *ansible.RunnerItemFailedEvent:
+		t.progress.Failed = t.progress.Failed || !event.IgnoreErrors
+		notify = false
+	case *ansible.RunnerUnreachableEvent:
+		t.progress.Failed = true
+		notify = false
+	case *ansible.PlaybookEndEvent:
+		if !t.progress.Failed {
+			t.progress.CompletedPlays = t.played
+			t.progress.Play, t.progress.Task = "", ""
+		}
+	default:
+		notify = false
+	}
+	t.update(now)
+	p := t.progress
+	t.mu.Unlock()
+	if notify && t.onProgress != nil {
+		t.onProgress(p)
+	}
+}
+
+// update the percentage and the times of the progress
+func (t *ProgressTracker) update(now time.Time) {
+	p := &t.progress
+	if t.start.IsZero() {
+		return
+	}
+	p.Elapsed = now.Sub(t.start)
+	if p.CompletedPlays > p.Plays {
+		// The count of the plays was not known
+		p.Plays = p.CompletedPlays
+	}
+	p.Percent, p.Remaining = 0, 0
+	if p.Plays > 0 {
+		p.Percent = p.CompletedPlays * 100 / p.Plays
+	}
+	if p.CompletedPlays > 0 && p.CompletedPlays < p.Plays {
+		perPlay := p.Elapsed / time.Duration(p.CompletedPlays)
+		p.Remaining = perPlay * time.Duration(p.Plays-p.CompletedPlays)
+	}
+}
+
+// Progress returns the current progress of the playbook
+func (t *ProgressTracker) Progress() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update(t.now())
+	return t.progress
+}
+
+// ShowProgress returns an explainer that prefixes the name of each play with
+// the progress tracked by the tracker before forwarding it to the given
+// explainer. The tracker must have observed the event before it is
+// explained. The plays with the excluded names are forwarded unchanged, so
+// that they can be matched by name.
+func ShowProgress(explainer AnsibleEventExplainer, tracker *ProgressTracker, exclude ...string) AnsibleEventExplainer {
+	return &progressExplainer{explainer: explainer, tracker: tracker, exclude: exclude}
+}
+
+type progressExplainer struct {
+	explainer AnsibleEventExplainer
+	tracker   *ProgressTracker
+	exclude   []string
+}
+
+func (e *progressExplainer) ExplainEvent(ansibleEvent ansible.Event) {
+	if event, ok := ansibleEvent.(*ansible.PlayStartEvent); ok && !e.excluded(event.Name) {
+		named := *event
+		named.Name = fmt.Sprintf("[%s] %s", e.tracker.Progress(), event.Name)
+		ansibleEvent = &named
+	}
+	e.explainer.ExplainEvent(ansibleEvent)
+}
+
+func (e *progressExplainer) excluded(name string) bool {
+	for _, n := range e.exclude {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
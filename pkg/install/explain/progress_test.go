@@ -0,0 +1,95 @@
+package explain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestProgressTracker(t *testing.T) {
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	var got []Progress
+	tracker := NewProgressTracker(func(p Progress) { got = append(got, p) })
+	tracker.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	events := `{"eventType":"PLAYBOOK_START","eventData":{"name":"kubernetes.yaml","count":4}}
+{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"install etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{"changed":true}}}
+{"eventType":"PLAY_START","eventData":{"name":"master"}}
+{"eventType":"TASK_START","eventData":{"name":"install apiserver"}}
+{"eventType":"PLAY_START","eventData":{"name":"worker"}}
+{"eventType":"PLAY_START","eventData":{"name":"smoke test"}}
+{"eventType":"PLAYBOOK_END","eventData":{}}
+`
+	for ev := range ansible.EventStream(strings.NewReader(events)) {
+		tracker.ExplainEvent(ev)
+	}
+	expected := []Progress{
+		{Playbook: "kubernetes.yaml", Plays: 4},
+		{Playbook: "kubernetes.yaml", Plays: 4, Play: "etcd", Elapsed: time.Minute},
+		{Playbook: "kubernetes.yaml", Plays: 4, Play: "etcd", Task: "install etcd", Tasks: 1, Elapsed: 2 * time.Minute},
+		{Playbook: "kubernetes.yaml", Plays: 4, CompletedPlays: 1, Percent: 25, Play: "master", Tasks: 1, Elapsed: 4 * time.Minute, Remaining: 12 * time.Minute},
+		{Playbook: "kubernetes.yaml", Plays: 4, CompletedPlays: 1, Percent: 25, Play: "master", Task: "install apiserver", Tasks: 2, Elapsed: 5 * time.Minute, Remaining: 15 * time.Minute},
+		{Playbook: "kubernetes.yaml", Plays: 4, CompletedPlays: 2, Percent: 50, Play: "worker", Tasks: 2, Elapsed: 6 * time.Minute, Remaining: 6 * time.Minute},
+		{Playbook: "kubernetes.yaml", Plays: 4, CompletedPlays: 3, Percent: 75, Play: "smoke test", Tasks: 2, Elapsed: 7 * time.Minute, Remaining: 7 * time.Minute / 3},
+		{Playbook: "kubernetes.yaml", Plays: 4, CompletedPlays: 4, Percent: 100, Tasks: 2, Elapsed: 8 * time.Minute},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d progress updates, got %d: %+v", len(expected), len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("update %d: expected %+v, got %+v", i, expected[i], got[i])
+		}
+	}
+}
+
+func TestProgressTrackerFailedPlaybook(t *testing.T) {
+	tracker := NewProgressTracker(nil)
+	events := `{"eventType":"PLAYBOOK_START","eventData":{"name":"kubernetes.yaml","count":2}}
+{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"install etcd"}}
+{"eventType":"RUNNER_FAILED","eventData":{"host":"etcd01","result":{"msg":"failed"}}}
+{"eventType":"PLAYBOOK_END","eventData":{}}
+`
+	for ev := range ansible.EventStream(strings.NewReader(events)) {
+		tracker.ExplainEvent(ev)
+	}
+	p := tracker.Progress()
+	if !p.Failed || p.Percent != 0 || p.Play != "etcd" {
+		t.Errorf("expected the failed play not to complete, got %+v", p)
+	}
+}
+
+func TestShowProgress(t *testing.T) {
+	now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewProgressTracker(nil)
+	tracker.now = func() time.Time {
+		now = now.Add(30 * time.Second)
+		return now
+	}
+	out := &bytes.Buffer{}
+	e := ShowProgress(CIExplainer(out), tracker, "hidden")
+	events := `{"eventType":"PLAYBOOK_START","eventData":{"name":"kubernetes.yaml","count":3}}
+{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"PLAY_START","eventData":{"name":"hidden"}}
+{"eventType":"PLAY_START","eventData":{"name":"master"}}
+{"eventType":"PLAYBOOK_END","eventData":{}}
+`
+	for ev := range ansible.EventStream(strings.NewReader(events)) {
+		// The tracker observes the events before they are explained
+		tracker.ExplainEvent(ev)
+		e.ExplainEvent(ev)
+	}
+	for _, expected := range []string{"[0% | 1m0s elapsed] etcd\n", "hidden\n", "[66% | 2m30s elapsed | about 1m15s remaining] master\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package explain
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+	"github.com/gosuri/uilive"
+)
+
+// TableExplainer returns an explainer that renders a live-updating table with
+// the task that is running on each host, and the number of ok, changed, failed
+// and skipped results of each host. The line-based verbose explainer is
+// returned when verbose output is requested, or when out is not a terminal.
+func TableExplainer(verbose bool, out io.Writer) AnsibleEventExplainer {
+	if verbose || !isTerminal(out) {
+		return &verboseExplainer{out: out}
+	}
+	w := uilive.New()
+	w.Out = out
+	return newTableExplainer(w)
+}
+
+// liveWriter redraws the buffered output when flushed, and writes the output
+// of the bypass writer above it.
+type liveWriter interface {
+	io.Writer
+	Flush() error
+	Bypass() io.Writer
+}
+
+type hostStatus struct {
+	task    string
+	state   string
+	ok      int
+	changed int
+	failed  int
+	skipped int
+	inPlay  bool
+}
+
+type tableExplainer struct {
+	out             liveWriter
+	currentPlayName string
+	currentTask     string
+	playFailed      bool
+	hosts           map[string]*hostStatus
+	// hosts in the order they were first seen
+	hostOrder []string
+}
+
+func newTableExplainer(out liveWriter) *tableExplainer {
+	return &tableExplainer{
+		out:   out,
+		hosts: map[string]*hostStatus{},
+	}
+}
+
+func (e *tableExplainer) ExplainEvent(ansibleEvent ansible.Event) {
+	switch event := ansibleEvent.(type) {
+	case *ansible.PlayStartEvent:
+		e.endPlay()
+		e.currentPlayName = event.Name
+		e.currentTask = ""
+		e.playFailed = false
+		for _, h := range e.hosts {
+			h.inPlay = false
+		}
+
+	case *ansible.PlaybookEndEvent:
+		e.endPlay()
+		e.currentPlayName = ""
+
+	case *ansible.TaskStartEvent:
+		e.startTask(event.Name)
+
+	case *ansible.HandlerTaskStartEvent:
+		e.startTask(event.Name)
+
+	case *ansible.RunnerOKEvent:
+		h := e.host(event.Host)
+		h.ok++
+		h.state = "ok"
+		if event.Result.Changed {
+			h.changed++
+			h.state = "changed"
+		}
+
+	case *ansible.RunnerItemOKEvent:
+		// The result of the task is counted once all items are done
+		e.host(event.Host).state = "running"
+
+	case *ansible.RunnerItemRetryEvent:
+		e.host(event.Host).state = fmt.Sprintf("retrying (%d/%d)", event.Result.Attempts, event.Result.MaxRetries-1)
+
+	case *ansible.RunnerSkippedEvent:
+		h := e.host(event.Host)
+		h.skipped++
+		h.state = "skipped"
+
+	case *ansible.RunnerFailedEvent:
+		e.hostFailed(event.Host, "", event.Result.Message, event.Result.Stdout, event.Result.Stderr, event.IgnoreErrors)
+
+	case *ansible.RunnerItemFailedEvent:
+		e.hostFailed(event.Host, event.Result.Item, event.Result.Message, event.Result.Stdout, event.Result.Stderr, event.IgnoreErrors)
+
+	case *ansible.RunnerUnreachableEvent:
+		h := e.host(event.Host)
+		h.failed++
+		h.state = "unreachable"
+		e.playFailed = true
+		util.PrettyPrintUnreachable(e.out.Bypass(), "  %s", event.Host)
+	}
+	e.render()
+}
+
+func (e *tableExplainer) host(name string) *hostStatus {
+	h, ok := e.hosts[name]
+	if !ok {
+		h = &hostStatus{}
+		e.hosts[name] = h
+		e.hostOrder = append(e.hostOrder, name)
+	}
+	h.inPlay = true
+	h.task = e.currentTask
+	return h
+}
+
+func (e *tableExplainer) startTask(name string) {
+	e.currentTask = name
+	// Hosts that have been targeted by the play are expected to run the task
+	for _, h := range e.hosts {
+		if h.inPlay && h.state != "failed" && h.state != "unreachable" {
+			h.task = name
+			h.state = "running"
+		}
+	}
+}
+
+func (e *tableExplainer) hostFailed(host, item, msg, stdout, stderr string, ignored bool) {
+	h := e.host(host)
+	buf := &bytes.Buffer{}
+	if !e.playFailed && !ignored {
+		util.PrettyPrintErr(buf, "%s", e.currentPlayName)
+		fmt.Fprintln(buf, "- Task: "+e.currentTask)
+	}
+	desc := fmt.Sprintf("  %s", host)
+	if item != "" {
+		desc = desc + fmt.Sprintf(" with %q", item)
+	}
+	if ignored {
+		h.state = "ignored"
+		util.PrettyPrintErrorIgnored(buf, desc)
+		fmt.Fprint(e.out.Bypass(), buf.String())
+		return
+	}
+	h.failed++
+	h.state = "failed"
+	e.playFailed = true
+	util.PrettyPrintErr(buf, "%s: %s", desc, msg)
+	if stdout != "" {
+		util.PrintColor(buf, util.Red, "---- STDOUT ----\n%s\n", stdout)
+	}
+	if stderr != "" {
+		util.PrintColor(buf, util.Red, "---- STDERR ----\n%s\n", stderr)
+	}
+	if stdout != "" || stderr != "" {
+		util.PrintColor(buf, util.Red, "---------------\n")
+	}
+	fmt.Fprint(e.out.Bypass(), buf.String())
+}
+
+// endPlay prints the status of the play that is running, if any
+func (e *tableExplainer) endPlay() {
+	if e.currentPlayName == "" || e.playFailed {
+		return
+	}
+	util.PrettyPrintOk(e.out.Bypass(), "%s", e.currentPlayName)
+}
+
+// render redraws the table with the status of the hosts
+func (e *tableExplainer) render() {
+	buf := &bytes.Buffer{}
+	if e.currentPlayName != "" {
+		fmt.Fprintln(buf, e.currentPlayName)
+		if e.currentTask != "" {
+			fmt.Fprintln(buf, "- Task:", e.currentTask)
+		}
+	}
+	if len(e.hostOrder) > 0 {
+		w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tTASK\tSTATUS\tOK\tCHANGED\tFAILED\tSKIPPED")
+		for _, name := range e.hostOrder {
+			h := e.hosts[name]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\n", name, h.task, h.state, h.ok, h.changed, h.failed, h.skipped)
+		}
+		w.Flush()
+	}
+	e.out.Write(buf.Bytes())
+	e.out.Flush()
+}
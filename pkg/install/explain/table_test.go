@@ -0,0 +1,70 @@
+package explain
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// fakeLiveWriter keeps the last frame that was drawn, and the output that was
+// written above it
+type fakeLiveWriter struct {
+	buf    bytes.Buffer
+	frame  string
+	bypass bytes.Buffer
+}
+
+func (w *fakeLiveWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+func (w *fakeLiveWriter) Bypass() io.Writer          { return &w.bypass }
+func (w *fakeLiveWriter) Flush() error {
+	w.frame = w.buf.String()
+	w.buf.Reset()
+	return nil
+}
+
+func TestTableExplainer(t *testing.T) {
+	w := &fakeLiveWriter{}
+	e := newTableExplainer(w)
+	events := `{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"install etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{"changed":true}}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd02","result":{"changed":false}}}
+{"eventType":"TASK_START","eventData":{"name":"start etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{}}}
+`
+	for ev := range ansible.EventStream(strings.NewReader(events)) {
+		e.ExplainEvent(ev)
+	}
+	lines := strings.Split(strings.TrimSpace(w.frame), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected a frame with 5 lines, but got:\n%s", w.frame)
+	}
+	if lines[1] != "- Task: start etcd" {
+		t.Errorf("expected the current task to be shown, but got %q", lines[1])
+	}
+	expected := [][]string{
+		{"etcd01", "start", "etcd", "ok", "2", "1", "0", "0"},
+		{"etcd02", "start", "etcd", "running", "1", "0", "0", "0"},
+	}
+	for i, exp := range expected {
+		if got := strings.Fields(lines[i+3]); strings.Join(got, " ") != strings.Join(exp, " ") {
+			t.Errorf("expected row %v, but got %v", exp, got)
+		}
+	}
+
+	failure := `{"eventType":"RUNNER_FAILED","eventData":{"host":"etcd02","result":{"msg":"timed out"}}}
+{"eventType":"PLAYBOOK_END","eventData":{"name":"kubernetes.yaml"}}
+`
+	for ev := range ansible.EventStream(strings.NewReader(failure)) {
+		e.ExplainEvent(ev)
+	}
+	if !strings.Contains(w.bypass.String(), "etcd02: timed out") {
+		t.Errorf("expected the failure to be printed above the table, but got:\n%s", w.bypass.String())
+	}
+	if !strings.Contains(w.frame, "failed") {
+		t.Errorf("expected the host to be shown as failed, but got:\n%s", w.frame)
+	}
+}
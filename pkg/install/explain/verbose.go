@@ -8,12 +8,33 @@ import (
 	"github.com/apprenda/kismatic/pkg/util"
 )
 
+// CIExplainer returns an explainer that only writes complete lines, which
+// is suitable for the logs of CI systems
+func CIExplainer(out io.Writer) AnsibleEventExplainer {
+	return &verboseExplainer{out: out, completeLines: true}
+}
+
 type verboseExplainer struct {
 	out              io.Writer
 	printPlayMessage bool
 	printPlayStatus  bool
 	lastPlay         string
 	currentTask      string
+	// completeLines is set when the play name must be written on its own
+	// line, instead of being followed by the play status
+	completeLines bool
+}
+
+// endPlayLine ends the line of the play name before the first task status is
+// written
+func (explainer *verboseExplainer) endPlayLine(out io.Writer) {
+	if explainer.printPlayStatus {
+		if !explainer.completeLines {
+			fmt.Fprintln(out)
+		}
+		// Dont print play success status on error
+		explainer.printPlayStatus = false
+	}
 }
 
 func (explainer *verboseExplainer) writePlayStatus(buf io.Writer) {
@@ -22,7 +43,9 @@ func (explainer *verboseExplainer) writePlayStatus(buf io.Writer) {
 		// No tasks were printed, no nodes match the selector
 		// This is OK and a valid scenario
 		if explainer.printPlayStatus {
-			fmt.Fprintln(buf)
+			if !explainer.completeLines {
+				fmt.Fprintln(buf)
+			}
 			util.PrintColor(buf, util.Green, "%s Finished With No Tasks\n", explainer.lastPlay)
 		} else {
 			util.PrintColor(buf, util.Green, "%s Finished\n", explainer.lastPlay)
@@ -39,17 +62,16 @@ func (explainer *verboseExplainer) ExplainEvent(e ansible.Event) {
 		// Print a success status, but only when there were no errors
 		explainer.writePlayStatus(out)
 		fmt.Fprintf(out, "%s", event.Name)
+		if explainer.completeLines {
+			fmt.Fprintln(out)
+		}
 		// Set default state for the play
 		explainer.lastPlay = event.Name
 		explainer.printPlayStatus = true
 		explainer.printPlayMessage = true
 	case *ansible.RunnerFailedEvent:
 		// Print newline before first task status
-		if explainer.printPlayStatus {
-			fmt.Fprintln(out)
-			// Dont print play success status on error
-			explainer.printPlayStatus = false
-		}
+		explainer.endPlayLine(out)
 		// Tasks only print at verbose level, on ERROR also print task name
 		if event.IgnoreErrors {
 			util.PrettyPrintErrorIgnored(out, "  %s", event.Host)
@@ -68,29 +90,17 @@ func (explainer *verboseExplainer) ExplainEvent(e ansible.Event) {
 	case *ansible.RunnerUnreachableEvent:
 		// Host is unreachable
 		// Print newline before first task
-		if explainer.printPlayStatus {
-			fmt.Fprintln(out)
-			// Dont print play success status on error
-			explainer.printPlayStatus = false
-		}
+		explainer.endPlayLine(out)
 		util.PrettyPrintUnreachable(out, "  %s", event.Host)
 	case *ansible.TaskStartEvent:
 		// Print newline before first task status
-		if explainer.printPlayStatus {
-			fmt.Fprintln(out)
-			// Dont print play success status on error
-			explainer.printPlayStatus = false
-		}
+		explainer.endPlayLine(out)
 		fmt.Fprintf(out, "- Running task: %s\n", event.Name)
 		// Set current task name
 		explainer.currentTask = event.Name
 	case *ansible.HandlerTaskStartEvent:
 		// Print newline before first task
-		if explainer.printPlayStatus {
-			fmt.Fprintln(out)
-			// Dont print play success status on error
-			explainer.printPlayStatus = false
-		}
+		explainer.endPlayLine(out)
 		fmt.Fprintf(out, "- Running task: %s\n", event.Name)
 		// Set current task name
 		explainer.currentTask = event.Name
@@ -113,11 +123,7 @@ func (explainer *verboseExplainer) ExplainEvent(e ansible.Event) {
 			msg = msg + fmt.Sprintf(" with %q", event.Result.Item)
 		}
 		// Print newline before first task status
-		if explainer.printPlayStatus {
-			fmt.Fprintln(out)
-			// Dont print play success status on error
-			explainer.printPlayStatus = false
-		}
+		explainer.endPlayLine(out)
 		// Tasks only print at verbose level, on ERROR also print task name
 		if event.IgnoreErrors {
 			util.PrettyPrintErrorIgnored(out, msg)
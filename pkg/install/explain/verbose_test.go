@@ -0,0 +1,38 @@
+package explain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/fatih/color"
+)
+
+func TestCIExplainerWritesCompleteLines(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+	out := &bytes.Buffer{}
+	e := CIExplainer(out)
+	events := `{"eventType":"PLAY_START","eventData":{"name":"etcd"}}
+{"eventType":"TASK_START","eventData":{"name":"install etcd"}}
+{"eventType":"RUNNER_OK","eventData":{"host":"etcd01","result":{}}}
+{"eventType":"PLAY_START","eventData":{"name":"master"}}
+`
+	for ev := range ansible.EventStream(strings.NewReader(events)) {
+		e.ExplainEvent(ev)
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			t.Fatalf("expected only complete lines after %T, but got %q", ev, out.String())
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	expected := []string{"etcd", "- Running task: install etcd", "etcd01 [OK]", "etcd Finished", "master"}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, but got:\n%s", len(expected), out.String())
+	}
+	for i, l := range lines {
+		if strings.Join(strings.Fields(l), " ") != expected[i] {
+			t.Errorf("expected line %q, but got %q", expected[i], l)
+		}
+	}
+}
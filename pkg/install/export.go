@@ -0,0 +1,319 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Package versions and repositories installed by the packages roles
+const (
+	dockerYumVersion    = "17.03.2.ce-1.el7.centos"
+	dockerDebVersion    = "17.03.2~ce-0~ubuntu-xenial"
+	glusterfsYumVersion = "3.8.15-2.el7"
+	glusterfsDebVersion = "3.8.15-ubuntu1~xenial1"
+
+	dockerYumRepositoryURL     = "https://download.docker.com/linux/centos/7/x86_64/stable/"
+	dockerYumGPGKeyURL         = "https://download.docker.com/linux/centos/gpg"
+	dockerDebRepositoryURL     = "https://download.docker.com/linux/ubuntu"
+	dockerDebGPGKeyURL         = "https://download.docker.com/linux/ubuntu/gpg"
+	kubernetesYumRepositoryURL = "https://packages.cloud.google.com/yum/repos/kubernetes-el7-x86_64"
+	kubernetesYumGPGKeyURLs    = "https://packages.cloud.google.com/yum/doc/yum-key.gpg https://packages.cloud.google.com/yum/doc/rpm-package-key.gpg"
+	kubernetesDebRepositoryURL = "https://packages.cloud.google.com/apt/"
+	kubernetesDebGPGKeyURL     = "https://packages.cloud.google.com/apt/doc/apt-key.gpg"
+	glusterYumRepositoryURL    = "http://buildlogs.centos.org/centos/7/storage/x86_64/gluster-3.8/"
+	glusterYumGPGKeyURL        = "https://download.gluster.org/pub/gluster/glusterfs/3.8/3.8.7/rsa.pub"
+)
+
+// ExportOperatingSystems are the operating systems supported by the node
+// artifact generators
+var ExportOperatingSystems = []string{"centos", "rhel", "ubuntu"}
+
+// nodeSysctls are the kernel parameters required by kube-proxy and the pod
+// network on all kubernetes nodes
+var nodeSysctls = map[string]string{
+	"net.ipv4.ip_forward":                 "1",
+	"net.bridge.bridge-nf-call-iptables":  "1",
+	"net.bridge.bridge-nf-call-ip6tables": "1",
+}
+
+// NodeRequirements are the packages, users and kernel parameters that a node
+// must have before it is installed by KET
+type NodeRequirements struct {
+	Host          string
+	IP            string
+	Roles         []string
+	OS            string
+	SSHUser       string
+	AuthorizedKey string
+	// Repositories are set up unless the installation is disconnected, in
+	// which case the nodes are expected to use local mirrors
+	Repositories []PackageRepository
+	Packages     []string
+	Sysctls      []Sysctl
+}
+
+// PackageRepository is a yum or apt repository
+type PackageRepository struct {
+	Name    string
+	BaseURL string
+	GPGKeys []string
+	// Source is the apt source line, or the PPA, of the repository
+	Source string
+	PPA    bool
+}
+
+// Sysctl is a kernel parameter
+type Sysctl struct {
+	Name  string
+	Value string
+}
+
+// Yum returns true when the packages of the node are installed with yum
+func (r NodeRequirements) Yum() bool {
+	return r.OS == "centos" || r.OS == "rhel"
+}
+
+func (r NodeRequirements) hasRole(role string) bool {
+	return contains(role, r.Roles)
+}
+
+// NodeRequirementsForPlan returns the requirements of every node in the plan,
+// for the given operating system
+func NodeRequirementsForPlan(p Plan, os, authorizedKey string) ([]NodeRequirements, error) {
+	if !contains(os, ExportOperatingSystems) {
+		return nil, fmt.Errorf("operating system %q is not supported, must be one of %v", os, ExportOperatingSystems)
+	}
+	if p.Cluster.Version == "" {
+		p.Cluster.Version = kubernetesVersionString
+	}
+	var reqs []NodeRequirements
+	for _, n := range p.GetUniqueNodes() {
+		r := NodeRequirements{
+			Host:          n.Host,
+			IP:            n.IP,
+			Roles:         p.GetRolesForIP(n.IP),
+			OS:            os,
+			SSHUser:       p.Cluster.SSH.User,
+			AuthorizedKey: authorizedKey,
+		}
+		r.Packages = nodePackages(p, r)
+		if !p.Cluster.DisconnectedInstallation {
+			r.Repositories = nodeRepositories(p, r)
+		}
+		if r.hasRole("master") || r.hasRole("worker") || r.hasRole("ingress") || r.hasRole("storage") {
+			for name, value := range nodeSysctls {
+				r.Sysctls = append(r.Sysctls, Sysctl{Name: name, Value: value})
+			}
+			sort.Slice(r.Sysctls, func(i, j int) bool { return r.Sysctls[i].Name < r.Sysctls[j].Name })
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs, nil
+}
+
+func nodePackages(p Plan, r NodeRequirements) []string {
+	pkg := func(name, version string) string {
+		if r.Yum() {
+			return name + "-" + version
+		}
+		return name + "=" + version
+	}
+	var packages []string
+	if !p.Docker.Disable {
+		if r.Yum() {
+			packages = append(packages, pkg("docker-ce", dockerYumVersion))
+		} else {
+			packages = append(packages, pkg("docker-ce", dockerDebVersion))
+		}
+	}
+	if r.hasRole("master") || r.hasRole("worker") || r.hasRole("ingress") || r.hasRole("storage") {
+		if r.Yum() {
+			version := p.Cluster.Version[1:] + "-0"
+			packages = append(packages, "nfs-utils", pkg("kubelet", version), pkg("kubectl", version))
+		} else {
+			version := p.Cluster.Version[1:] + "-00"
+			packages = append(packages, "nfs-common", pkg("kubelet", version), pkg("kubectl", version))
+		}
+	}
+	if r.hasRole("storage") {
+		if r.Yum() {
+			packages = append(packages, pkg("glusterfs-server", glusterfsYumVersion))
+		} else {
+			packages = append(packages, pkg("glusterfs-server", glusterfsDebVersion))
+		}
+	}
+	return packages
+}
+
+func nodeRepositories(p Plan, r NodeRequirements) []PackageRepository {
+	var repos []PackageRepository
+	if r.Yum() {
+		if !p.Docker.Disable {
+			repos = append(repos, PackageRepository{Name: "docker", BaseURL: dockerYumRepositoryURL, GPGKeys: []string{dockerYumGPGKeyURL}})
+		}
+		repos = append(repos, PackageRepository{Name: "kubernetes", BaseURL: kubernetesYumRepositoryURL, GPGKeys: strings.Fields(kubernetesYumGPGKeyURLs)})
+		if r.hasRole("storage") {
+			repos = append(repos, PackageRepository{Name: "gluster", BaseURL: glusterYumRepositoryURL, GPGKeys: []string{glusterYumGPGKeyURL}})
+		}
+		return repos
+	}
+	if !p.Docker.Disable {
+		repos = append(repos, PackageRepository{Name: "docker", GPGKeys: []string{dockerDebGPGKeyURL}, Source: "deb [arch=amd64] " + dockerDebRepositoryURL + " xenial stable"})
+	}
+	repos = append(repos, PackageRepository{Name: "kubernetes", GPGKeys: []string{kubernetesDebGPGKeyURL}, Source: "deb " + kubernetesDebRepositoryURL + " kubernetes-xenial main"})
+	if r.hasRole("storage") {
+		repos = append(repos, PackageRepository{Name: "gluster", Source: "ppa:gluster/glusterfs-3.8", PPA: true})
+	}
+	return repos
+}
+
+var exportFuncs = template.FuncMap{"join": strings.Join}
+
+var cloudInitTemplate = template.Must(template.New("cloud-init").Funcs(exportFuncs).Parse(`#cloud-config
+# Generated by kismatic for {{ .Host }} ({{ join .Roles ", " }})
+hostname: {{ .Host }}
+{{- if eq .SSHUser "root" }}
+disable_root: false
+{{- end }}
+users:
+  - default
+  - name: {{ .SSHUser }}
+{{- if ne .SSHUser "root" }}
+    shell: /bin/bash
+    sudo: "ALL=(ALL) NOPASSWD:ALL"
+{{- end }}
+{{- if .AuthorizedKey }}
+    ssh_authorized_keys:
+      - {{ .AuthorizedKey }}
+{{- end }}
+{{- if and .Repositories .Yum }}
+yum_repos:
+{{- range .Repositories }}
+  {{ .Name }}:
+    name: {{ .Name }}
+    baseurl: {{ .BaseURL }}
+    gpgkey: {{ join .GPGKeys " " }}
+    gpgcheck: true
+    enabled: true
+{{- end }}
+{{- end }}
+{{- if .Sysctls }}
+write_files:
+  - path: /etc/modules-load.d/kismatic.conf
+    permissions: "0644"
+    content: |
+      br_netfilter
+  - path: /etc/sysctl.d/90-kismatic.conf
+    permissions: "0644"
+    content: |
+{{- range .Sysctls }}
+      {{ .Name }} = {{ .Value }}
+{{- end }}
+{{- end }}
+runcmd:
+{{- if .Sysctls }}
+  - modprobe br_netfilter
+  - sysctl --system
+{{- end }}
+{{- if .Yum }}
+{{- if .Packages }}
+  - yum install -y --setopt=obsoletes=0 {{ join .Packages " " }}
+{{- end }}
+{{- else }}
+{{- if .Repositories }}
+  - apt-get update
+  - DEBIAN_FRONTEND=noninteractive apt-get install -y apt-transport-https curl software-properties-common
+{{- end }}
+{{- range .Repositories }}
+{{- range .GPGKeys }}
+  - curl -fsSL {{ . }} | apt-key add -
+{{- end }}
+{{- if .PPA }}
+  - add-apt-repository -y {{ .Source }}
+{{- else }}
+  - echo '{{ .Source }}' > /etc/apt/sources.list.d/{{ .Name }}.list
+{{- end }}
+{{- end }}
+{{- if .Packages }}
+  - apt-get update
+  - DEBIAN_FRONTEND=noninteractive apt-get install -y {{ join .Packages " " }}
+{{- end }}
+{{- end }}
+`))
+
+// CloudInitUserData returns the cloud-init user-data that prepares the node
+// for the installation
+func (r NodeRequirements) CloudInitUserData() (string, error) {
+	var b bytes.Buffer
+	if err := cloudInitTemplate.Execute(&b, r); err != nil {
+		return "", fmt.Errorf("error rendering cloud-init user-data for %q: %v", r.Host, err)
+	}
+	return b.String(), nil
+}
+
+var terraformTemplate = template.Must(template.New("terraform").Funcs(exportFuncs).Parse(`# Generated by kismatic from the plan of the {{ .Cluster }} cluster.
+# Use the locals to provision instances that match the nodes of the plan, e.g.
+#   user_data  = "${local.kismatic_user_data["{{ .FirstHost }}"]}"
+#   private_ip = "${local.kismatic_private_ips["{{ .FirstHost }}"]}"
+locals {
+  kismatic_private_ips = {
+{{- range .Nodes }}
+    "{{ .Host }}" = "{{ .IP }}"
+{{- end }}
+  }
+
+  kismatic_roles = {
+{{- range .Nodes }}
+    "{{ .Host }}" = "{{ join .Roles "," }}"
+{{- end }}
+  }
+
+  kismatic_user_data = {
+{{- range .Nodes }}
+    "{{ .Host }}" = <<EOF
+{{ .UserData }}EOF
+{{- end }}
+  }
+}
+`))
+
+type terraformNode struct {
+	Host     string
+	IP       string
+	Roles    []string
+	UserData string
+}
+
+// Terraform returns a Terraform snippet that defines the private IP, roles
+// and cloud-init user-data of each node as locals
+func Terraform(cluster string, reqs []NodeRequirements) (string, error) {
+	data := struct {
+		Cluster   string
+		FirstHost string
+		Nodes     []terraformNode
+	}{Cluster: cluster}
+	for _, r := range reqs {
+		userData, err := r.CloudInitUserData()
+		if err != nil {
+			return "", err
+		}
+		data.Nodes = append(data.Nodes, terraformNode{
+			Host:  r.Host,
+			IP:    r.IP,
+			Roles: r.Roles,
+			// escape the interpolation sequences of terraform
+			UserData: strings.Replace(userData, "${", "$${", -1),
+		})
+	}
+	if len(data.Nodes) > 0 {
+		data.FirstHost = data.Nodes[0].Host
+	}
+	var b bytes.Buffer
+	if err := terraformTemplate.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering terraform: %v", err)
+	}
+	return b.String(), nil
+}
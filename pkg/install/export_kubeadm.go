@@ -0,0 +1,205 @@
+package install
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudflare/cfssl/helpers"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	kubeadmConfigAPIVersion = "kubeadm.k8s.io/v1alpha1"
+	etcdK8sClientPort       = 2379
+	apiServerSecurePort     = 6443
+)
+
+// the options that KET sets on the control plane components, and that differ
+// from the kubeadm defaults
+var (
+	kubeadmAPIServerDefaults = map[string]string{
+		"enable-admission-plugins": "NamespaceLifecycle,LimitRanger,ServiceAccount,NodeRestriction,PersistentVolumeLabel,DefaultStorageClass,DefaultTolerationSeconds,MutatingAdmissionWebhook,ValidatingAdmissionWebhook,ResourceQuota",
+		"profiling":                "false",
+		"repair-malformed-updates": "false",
+		"runtime-config":           "extensions/v1beta1=true,extensions/v1beta1/networkpolicies=true,authentication.k8s.io/v1beta1=true",
+	}
+	kubeadmControllerManagerDefaults = map[string]string{
+		"profiling": "false",
+	}
+	kubeadmSchedulerDefaults = map[string]string{
+		"profiling": "false",
+	}
+)
+
+// kubeadmMasterConfiguration is the v1alpha1 configuration of kubeadm init,
+// which is used by the Kubernetes 1.10 releases of kubeadm
+type kubeadmMasterConfiguration struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	API        struct {
+		AdvertiseAddress     string `yaml:"advertiseAddress"`
+		BindPort             int    `yaml:"bindPort"`
+		ControlPlaneEndpoint string `yaml:"controlPlaneEndpoint,omitempty"`
+	} `yaml:"api"`
+	Etcd struct {
+		Endpoints []string `yaml:"endpoints"`
+		CAFile    string   `yaml:"caFile"`
+		CertFile  string   `yaml:"certFile"`
+		KeyFile   string   `yaml:"keyFile"`
+	} `yaml:"etcd"`
+	Networking struct {
+		DNSDomain     string `yaml:"dnsDomain"`
+		PodSubnet     string `yaml:"podSubnet"`
+		ServiceSubnet string `yaml:"serviceSubnet"`
+	} `yaml:"networking"`
+	KubernetesVersion          string            `yaml:"kubernetesVersion"`
+	CloudProvider              string            `yaml:"cloudProvider,omitempty"`
+	NodeName                   string            `yaml:"nodeName"`
+	APIServerExtraArgs         map[string]string `yaml:"apiServerExtraArgs,omitempty"`
+	ControllerManagerExtraArgs map[string]string `yaml:"controllerManagerExtraArgs,omitempty"`
+	SchedulerExtraArgs         map[string]string `yaml:"schedulerExtraArgs,omitempty"`
+	APIServerCertSANs          []string          `yaml:"apiServerCertSANs,omitempty"`
+	FeatureGates               map[string]bool   `yaml:"featureGates,omitempty"`
+}
+
+// KubeadmMasterConfig returns the kubeadm init configuration of the master,
+// which runs a control plane equivalent to the one installed by KET. The
+// etcd cluster installed by KET is used as an external etcd cluster.
+func KubeadmMasterConfig(p Plan, master Node) (string, error) {
+	if !hasIP(&p.Master.Nodes, master.IP) {
+		return "", fmt.Errorf("node %q is not a master", master.Host)
+	}
+	c := kubeadmMasterConfiguration{
+		APIVersion:        kubeadmConfigAPIVersion,
+		Kind:              "MasterConfiguration",
+		KubernetesVersion: p.Cluster.Version,
+		CloudProvider:     p.Cluster.CloudProvider.Provider,
+		NodeName:          master.Host,
+	}
+	c.API.AdvertiseAddress = master.IP
+	if master.InternalIP != "" {
+		c.API.AdvertiseAddress = master.InternalIP
+	}
+	c.API.BindPort = apiServerSecurePort
+	c.API.ControlPlaneEndpoint = p.Master.LoadBalancedFQDN
+
+	for _, n := range p.Etcd.Nodes {
+		c.Etcd.Endpoints = append(c.Etcd.Endpoints, fmt.Sprintf("https://%s:%d", n.IP, etcdK8sClientPort))
+	}
+	c.Etcd.CAFile = "/etc/kubernetes/pki/ca.crt"
+	c.Etcd.CertFile = "/etc/kubernetes/pki/apiserver-etcd-client.crt"
+	c.Etcd.KeyFile = "/etc/kubernetes/pki/apiserver-etcd-client.key"
+
+	c.Networking.DNSDomain = "cluster.local"
+	c.Networking.PodSubnet = p.Cluster.Networking.PodCIDRBlock
+	c.Networking.ServiceSubnet = p.Cluster.Networking.ServiceCIDRBlock
+
+	c.APIServerExtraArgs = mergeOptions(kubeadmAPIServerDefaults, p.Cluster.APIServerOptions.Overrides)
+	c.ControllerManagerExtraArgs = mergeOptions(kubeadmControllerManagerDefaults, p.Cluster.KubeControllerManagerOptions.Overrides)
+	if _, ok := c.ControllerManagerExtraArgs["cluster-name"]; !ok {
+		c.ControllerManagerExtraArgs["cluster-name"] = p.Cluster.Name
+	}
+	c.SchedulerExtraArgs = mergeOptions(kubeadmSchedulerDefaults, p.Cluster.KubeSchedulerOptions.Overrides)
+
+	sans := []string{p.Master.LoadBalancedFQDN, p.Master.LoadBalancedShortName}
+	for _, n := range p.Master.Nodes {
+		sans = append(sans, n.Host, n.IP)
+		if n.InternalIP != "" {
+			sans = append(sans, n.InternalIP)
+		}
+	}
+	for _, s := range sans {
+		if s != "" && !contains(s, c.APIServerCertSANs) {
+			c.APIServerCertSANs = append(c.APIServerCertSANs, s)
+		}
+	}
+	if p.AddOns.DNS.Provider == "coredns" {
+		c.FeatureGates = map[string]bool{"CoreDNS": true}
+	}
+
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling kubeadm configuration: %v", err)
+	}
+	return string(b), nil
+}
+
+// mergeOptions returns the defaults updated with the overrides. Options that
+// are empty are left out.
+func mergeOptions(defaults, overrides map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	for k, v := range merged {
+		if v == "" {
+			delete(merged, k)
+		}
+	}
+	return merged
+}
+
+// the certificates generated by KET, and their location in the kubeadm
+// certificates directory
+var kubeadmPKIFiles = []struct {
+	ket     string
+	kubeadm string
+}{
+	{ket: "ca.pem", kubeadm: "ca.crt"},
+	{ket: "ca-key.pem", kubeadm: "ca.key"},
+	{ket: "proxy-client-ca.pem", kubeadm: "front-proxy-ca.crt"},
+	{ket: "proxy-client-ca-key.pem", kubeadm: "front-proxy-ca.key"},
+	{ket: "service-account-key.pem", kubeadm: "sa.key"},
+	{ket: "etcd-client.pem", kubeadm: "apiserver-etcd-client.crt"},
+	{ket: "etcd-client-key.pem", kubeadm: "apiserver-etcd-client.key"},
+}
+
+// ExportKubeadmPKI copies the certificate authorities, the service account
+// signing key and the etcd client certificate generated by KET to the
+// directory, using the layout of the kubeadm certificates directory. The
+// certificates that are not copied are generated by kubeadm, and signed by
+// the cluster CA.
+func ExportKubeadmPKI(certsDir, dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("error creating directory %q: %v", dir, err)
+	}
+	for _, f := range kubeadmPKIFiles {
+		b, err := ioutil.ReadFile(filepath.Join(certsDir, f.ket))
+		if err != nil {
+			return fmt.Errorf("error reading %q: %v", f.ket, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f.kubeadm), b, 0600); err != nil {
+			return fmt.Errorf("error writing %q: %v", f.kubeadm, err)
+		}
+	}
+	// kubeadm expects the public key of the service account signing key
+	key, err := ioutil.ReadFile(filepath.Join(certsDir, "service-account-key.pem"))
+	if err != nil {
+		return fmt.Errorf("error reading service account key: %v", err)
+	}
+	priv, err := helpers.ParsePrivateKeyPEM(key)
+	if err != nil {
+		return fmt.Errorf("error parsing service account key: %v", err)
+	}
+	rsaKey, ok := priv.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("the service account key is not an RSA key")
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		return fmt.Errorf("error marshalling service account public key: %v", err)
+	}
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(dir, "sa.pub"), pub, 0600); err != nil {
+		return fmt.Errorf("error writing %q: %v", "sa.pub", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package install
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestKubeadmMasterConfig(t *testing.T) {
+	p := Plan{
+		Cluster: Cluster{
+			Name:    "prod",
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				PodCIDRBlock:     "172.16.0.0/16",
+				ServiceCIDRBlock: "172.20.0.0/16",
+			},
+			APIServerOptions:             APIServerOptions{Overrides: map[string]string{"profiling": "true", "runtime-config": ""}},
+			KubeControllerManagerOptions: KubeControllerManagerOptions{Overrides: map[string]string{"node-monitor-grace-period": "20s"}},
+		},
+		Etcd: NodeGroup{Nodes: []Node{{Host: "etcd01", IP: "10.0.0.1"}, {Host: "etcd02", IP: "10.0.0.2"}}},
+		Master: MasterNodeGroup{
+			LoadBalancedFQDN:      "k8s.example.com",
+			LoadBalancedShortName: "k8s",
+			Nodes:                 []Node{{Host: "master01", IP: "10.0.1.1", InternalIP: "192.168.0.1"}, {Host: "master02", IP: "10.0.1.2"}},
+		},
+		AddOns: AddOns{DNS: DNS{Provider: "coredns"}},
+	}
+	raw, err := KubeadmMasterConfig(p, p.Master.Nodes[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var c kubeadmMasterConfiguration
+	if err := yaml.Unmarshal([]byte(raw), &c); err != nil {
+		t.Fatalf("error unmarshalling configuration: %v", err)
+	}
+	if c.APIVersion != kubeadmConfigAPIVersion || c.NodeName != "master01" || c.API.AdvertiseAddress != "192.168.0.1" || c.API.ControlPlaneEndpoint != "k8s.example.com" {
+		t.Errorf("unexpected configuration:\n%s", raw)
+	}
+	expectedEtcd := []string{"https://10.0.0.1:2379", "https://10.0.0.2:2379"}
+	if !reflect.DeepEqual(c.Etcd.Endpoints, expectedEtcd) {
+		t.Errorf("expected etcd endpoints %v, but got %v", expectedEtcd, c.Etcd.Endpoints)
+	}
+	if c.Networking.PodSubnet != "172.16.0.0/16" || c.Networking.ServiceSubnet != "172.20.0.0/16" {
+		t.Errorf("unexpected networking %+v", c.Networking)
+	}
+	if c.APIServerExtraArgs["profiling"] != "true" {
+		t.Errorf("expected the API server overrides to be used, but got %v", c.APIServerExtraArgs)
+	}
+	if _, ok := c.APIServerExtraArgs["runtime-config"]; ok {
+		t.Errorf("expected the empty options to be left out, but got %v", c.APIServerExtraArgs)
+	}
+	if c.ControllerManagerExtraArgs["cluster-name"] != "prod" || c.ControllerManagerExtraArgs["node-monitor-grace-period"] != "20s" {
+		t.Errorf("unexpected controller manager options %v", c.ControllerManagerExtraArgs)
+	}
+	expectedSANs := []string{"k8s.example.com", "k8s", "master01", "10.0.1.1", "192.168.0.1", "master02", "10.0.1.2"}
+	if !reflect.DeepEqual(c.APIServerCertSANs, expectedSANs) {
+		t.Errorf("expected SANs %v, but got %v", expectedSANs, c.APIServerCertSANs)
+	}
+	if !c.FeatureGates["CoreDNS"] {
+		t.Error("expected the CoreDNS feature gate to be enabled")
+	}
+
+	if _, err := KubeadmMasterConfig(p, p.Etcd.Nodes[0]); err == nil {
+		t.Error("expected an error when the node is not a master")
+	}
+}
+
+func TestExportKubeadmPKI(t *testing.T) {
+	certsDir, err := ioutil.TempDir("", "export-kubeadm-pki")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(certsDir)
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	for _, f := range kubeadmPKIFiles {
+		b := []byte(f.ket)
+		if f.ket == "service-account-key.pem" {
+			b = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+		}
+		if err := ioutil.WriteFile(filepath.Join(certsDir, f.ket), b, 0600); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+	}
+	dir := filepath.Join(certsDir, "pki")
+	if err := ExportKubeadmPKI(certsDir, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "front-proxy-ca.crt"))
+	if err != nil || string(b) != "proxy-client-ca.pem" {
+		t.Errorf("expected the proxy client CA to be copied to front-proxy-ca.crt: %v", err)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "sa.pub"))
+	if err != nil {
+		t.Fatalf("error reading sa.pub: %v", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatal("expected sa.pub to be PEM encoded")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("error parsing public key: %v", err)
+	}
+	if rsaPub, ok := pub.(*rsa.PublicKey); !ok || rsaPub.N.Cmp(priv.N) != 0 {
+		t.Error("expected sa.pub to be the public key of the service account key")
+	}
+
+	os.Remove(filepath.Join(certsDir, "etcd-client.pem"))
+	if err := ExportKubeadmPKI(certsDir, dir); err == nil {
+		t.Error("expected an error when a certificate is missing")
+	}
+}
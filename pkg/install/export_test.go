@@ -0,0 +1,140 @@
+package install
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func exportTestPlan() Plan {
+	p := Plan{}
+	p.Cluster.Name = "test"
+	p.Cluster.Version = "v1.10.3"
+	p.Cluster.SSH.User = "kismaticuser"
+	p.Etcd.Nodes = []Node{{Host: "etcd01", IP: "10.0.0.1"}}
+	p.Master.Nodes = []Node{{Host: "master01", IP: "10.0.0.2"}}
+	p.Worker.Nodes = []Node{{Host: "worker01", IP: "10.0.0.3"}}
+	p.Storage.Nodes = []Node{{Host: "worker01", IP: "10.0.0.3"}}
+	return p
+}
+
+func findRequirements(reqs []NodeRequirements, host string) NodeRequirements {
+	for _, r := range reqs {
+		if r.Host == host {
+			return r
+		}
+	}
+	return NodeRequirements{}
+}
+
+func TestNodeRequirementsForPlan(t *testing.T) {
+	reqs, err := NodeRequirementsForPlan(exportTestPlan(), "centos", "ssh-rsa AAAA")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reqs) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(reqs))
+	}
+
+	etcd := findRequirements(reqs, "etcd01")
+	if len(etcd.Packages) != 1 || etcd.Packages[0] != "docker-ce-"+dockerYumVersion {
+		t.Errorf("unexpected etcd packages %v", etcd.Packages)
+	}
+	if len(etcd.Sysctls) != 0 {
+		t.Errorf("expected no sysctls on etcd nodes, got %v", etcd.Sysctls)
+	}
+
+	worker := findRequirements(reqs, "worker01")
+	for _, pkg := range []string{"kubelet-1.10.3-0", "kubectl-1.10.3-0", "nfs-utils", "glusterfs-server-" + glusterfsYumVersion} {
+		if !contains(pkg, worker.Packages) {
+			t.Errorf("expected %q in the packages of the worker, got %v", pkg, worker.Packages)
+		}
+	}
+	if len(worker.Repositories) != 3 {
+		t.Errorf("expected the docker, kubernetes and gluster repositories, got %v", worker.Repositories)
+	}
+	if len(worker.Sysctls) != len(nodeSysctls) {
+		t.Errorf("expected %d sysctls, got %v", len(nodeSysctls), worker.Sysctls)
+	}
+}
+
+func TestNodeRequirementsForPlanOptions(t *testing.T) {
+	p := exportTestPlan()
+	p.Cluster.DisconnectedInstallation = true
+	p.Docker.Disable = true
+	reqs, err := NodeRequirementsForPlan(p, "ubuntu", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	master := findRequirements(reqs, "master01")
+	if len(master.Repositories) != 0 {
+		t.Errorf("expected no repositories in a disconnected installation, got %v", master.Repositories)
+	}
+	for _, pkg := range master.Packages {
+		if strings.HasPrefix(pkg, "docker-ce") {
+			t.Errorf("expected docker not to be installed when disabled")
+		}
+	}
+	if !contains("kubelet=1.10.3-00", master.Packages) {
+		t.Errorf("expected the deb kubelet package, got %v", master.Packages)
+	}
+
+	if _, err := NodeRequirementsForPlan(p, "windows", ""); err == nil {
+		t.Errorf("expected an error with an unsupported operating system")
+	}
+}
+
+func TestCloudInitUserData(t *testing.T) {
+	for _, os := range ExportOperatingSystems {
+		reqs, err := NodeRequirementsForPlan(exportTestPlan(), os, "ssh-rsa AAAA")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		userData, err := findRequirements(reqs, "worker01").CloudInitUserData()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(userData, "#cloud-config\n") {
+			t.Errorf("%s: expected the cloud-config header, got:\n%s", os, userData)
+		}
+		var config struct {
+			Hostname string
+			Users    []interface{}
+			RunCmd   []string `yaml:"runcmd"`
+		}
+		if err := yaml.Unmarshal([]byte(userData), &config); err != nil {
+			t.Fatalf("%s: user-data is not valid YAML: %v\n%s", os, err, userData)
+		}
+		if config.Hostname != "worker01" {
+			t.Errorf("%s: expected hostname worker01, got %q", os, config.Hostname)
+		}
+		if len(config.Users) != 2 {
+			t.Errorf("%s: expected the default and SSH users, got %v", os, config.Users)
+		}
+		if !strings.Contains(userData, "ssh-rsa AAAA") {
+			t.Errorf("%s: expected the authorized key in the user-data", os)
+		}
+		install := config.RunCmd[len(config.RunCmd)-1]
+		if !strings.Contains(install, "kubelet") {
+			t.Errorf("%s: expected the packages to be installed last, got %q", os, install)
+		}
+	}
+}
+
+func TestTerraform(t *testing.T) {
+	reqs, err := NodeRequirementsForPlan(exportTestPlan(), "ubuntu", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reqs[0].Packages = append(reqs[0].Packages, "${foo}")
+	tf, err := Terraform("test", reqs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, s := range []string{`"etcd01" = "10.0.0.1"`, `"worker01" = "worker,storage"`, `"master01" = <<EOF`, "$${foo}"} {
+		if !strings.Contains(tf, s) {
+			t.Errorf("expected %q in the terraform snippet:\n%s", s, tf)
+		}
+	}
+}
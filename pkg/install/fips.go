@@ -0,0 +1,59 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// fipsTLSMinVersion is the minimum TLS version of the components in FIPS mode
+const fipsTLSMinVersion = "VersionTLS12"
+
+// fipsCipherSuites are the TLS cipher suites that are approved for FIPS 140-2,
+// and supported by the Kubernetes components
+func fipsCipherSuites() []string {
+	return []string{
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+		"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	}
+}
+
+// withFIPSTLSOptions returns a copy of the component option overrides, with
+// the TLS version and cipher suites restricted for FIPS mode. The overrides
+// set in the plan take precedence.
+func withFIPSTLSOptions(overrides map[string]string) map[string]string {
+	options := map[string]string{
+		"tls-min-version":   fipsTLSMinVersion,
+		"tls-cipher-suites": strings.Join(fipsCipherSuites(), ","),
+	}
+	for k, v := range overrides {
+		options[k] = v
+	}
+	return options
+}
+
+// validateFIPSTLSOptions returns an error for every option override that
+// would allow TLS versions or cipher suites that are not allowed in FIPS mode
+func validateFIPSTLSOptions(component string, overrides map[string]string) []error {
+	var errs []error
+	if v, ok := overrides["tls-min-version"]; ok && v != fipsTLSMinVersion {
+		errs = append(errs, fmt.Errorf("%s option tls-min-version %q is not allowed in FIPS mode, must be %q", component, v, fipsTLSMinVersion))
+	}
+	if v, ok := overrides["tls-cipher-suites"]; ok {
+		var invalid []string
+		for _, suite := range strings.Split(v, ",") {
+			if !util.Contains(strings.TrimSpace(suite), fipsCipherSuites()) {
+				invalid = append(invalid, strings.TrimSpace(suite))
+			}
+		}
+		if len(invalid) > 0 {
+			sort.Strings(invalid)
+			errs = append(errs, fmt.Errorf("%s cipher suites %v are not allowed in FIPS mode, options are %v", component, invalid, fipsCipherSuites()))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,86 @@
+package install
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithFIPSTLSOptions(t *testing.T) {
+	overrides := map[string]string{"v": "3", "tls-cipher-suites": "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+	expected := map[string]string{
+		"v":                 "3",
+		"tls-min-version":   "VersionTLS12",
+		"tls-cipher-suites": "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	}
+	if got := withFIPSTLSOptions(overrides); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if len(overrides) != 2 {
+		t.Errorf("the overrides were modified: %v", overrides)
+	}
+	if got := withFIPSTLSOptions(nil); got["tls-cipher-suites"] != strings.Join(fipsCipherSuites(), ",") {
+		t.Errorf("expected the FIPS cipher suites, got %q", got["tls-cipher-suites"])
+	}
+}
+
+func TestValidateFIPSTLSOptions(t *testing.T) {
+	tests := []struct {
+		overrides map[string]string
+		errs      int
+	}{
+		{overrides: nil},
+		{overrides: map[string]string{"tls-min-version": "VersionTLS12"}},
+		{overrides: map[string]string{"tls-cipher-suites": "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+		{overrides: map[string]string{"tls-min-version": "VersionTLS10"}, errs: 1},
+		{overrides: map[string]string{"tls-cipher-suites": "TLS_RSA_WITH_RC4_128_SHA,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, errs: 1},
+		{overrides: map[string]string{"tls-min-version": "VersionTLS11", "tls-cipher-suites": "TLS_RSA_WITH_3DES_EDE_CBC_SHA"}, errs: 2},
+	}
+	for i, test := range tests {
+		if errs := validateFIPSTLSOptions("Kubelet", test.overrides); len(errs) != test.errs {
+			t.Errorf("test %d: expected %d errors, got %v", i, test.errs, errs)
+		}
+	}
+}
+
+func TestValidatePlanFIPS(t *testing.T) {
+	p := validPlan()
+	p.Cluster.FIPS = true
+	if valid, errs := ValidatePlan(&p); !valid {
+		t.Errorf("expected the plan to be valid, got %v", errs)
+	}
+
+	p.Cluster.APIServerOptions.Overrides = map[string]string{"tls-min-version": "VersionTLS10"}
+	assertInvalidPlan(t, p)
+
+	p = validPlan()
+	p.Cluster.FIPS = true
+	p.Worker.Nodes[0].KubeletOptions.Overrides = map[string]string{"tls-cipher-suites": "TLS_RSA_WITH_RC4_128_SHA"}
+	assertInvalidPlan(t, p)
+}
+
+func TestValidateFIPSKey(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	b, err := x509.MarshalECPrivateKey(k)
+	if err != nil {
+		t.Fatalf("error marshaling key: %v", err)
+	}
+	key := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})
+
+	p := &Plan{}
+	if err := validateFIPSKey(p, "cluster CA", key); err != nil {
+		t.Errorf("unexpected error when not in FIPS mode: %v", err)
+	}
+	p.Cluster.FIPS = true
+	if err := validateFIPSKey(p, "cluster CA", key); err == nil {
+		t.Errorf("expected an error with an ECDSA key in FIPS mode")
+	}
+}
@@ -0,0 +1,32 @@
+package install
+
+// HeapsterMigration configures the migration of a cluster from the Heapster
+// add-on to metrics-server. metrics-server is installed, and the metrics of
+// the nodes must be served through kubectl top before Heapster and InfluxDB
+// are removed from the cluster.
+type HeapsterMigration struct {
+	// Enabled migrates the cluster when its services are upgraded. It has no
+	// effect on the clusters that do not run Heapster.
+	Enabled bool
+	// RemoveData also deletes the persistent volume claim of InfluxDB, when
+	// the plan sets one
+	RemoveData bool
+}
+
+func heapsterEnabled(p Plan) bool {
+	return p.AddOns.HeapsterMonitoring != nil && !p.AddOns.HeapsterMonitoring.Disable
+}
+
+// MigrateHeapsterInPlan returns a copy of the plan in which the Heapster
+// add-on is disabled and metrics-server is enabled, as they are once the
+// cluster was migrated. The options of Heapster are kept.
+func MigrateHeapsterInPlan(p Plan) Plan {
+	if !heapsterEnabled(p) {
+		return p
+	}
+	heapster := *p.AddOns.HeapsterMonitoring
+	heapster.Disable = true
+	p.AddOns.HeapsterMonitoring = &heapster
+	p.AddOns.MetricsServer.Disable = false
+	return p
+}
@@ -0,0 +1,71 @@
+package install
+
+import "testing"
+
+func TestUpgradeClusterServicesHeapsterMigration(t *testing.T) {
+	tests := []struct {
+		name      string
+		heapster  *HeapsterMonitoring
+		migration HeapsterMigration
+		migrated  bool
+	}{
+		{
+			name:      "heapster is migrated",
+			heapster:  &HeapsterMonitoring{},
+			migration: HeapsterMigration{Enabled: true, RemoveData: true},
+			migrated:  true,
+		},
+		{
+			name:     "migration is not enabled",
+			heapster: &HeapsterMonitoring{},
+		},
+		{
+			name:      "heapster is disabled",
+			heapster:  &HeapsterMonitoring{Disable: true},
+			migration: HeapsterMigration{Enabled: true},
+		},
+		{
+			name:      "heapster is not in the plan",
+			migration: HeapsterMigration{Enabled: true},
+		},
+	}
+	for _, test := range tests {
+		runner := &retryRunner{}
+		e := retryExecutor(t, runner, RetryPolicy{})
+		e.renderCache = &renderCache{}
+		e.options.HeapsterMigration = test.migration
+		plan := removeNodeTestPlan()
+		plan.AddOns.HeapsterMonitoring = test.heapster
+		plan.AddOns.MetricsServer.Disable = true
+		if _, err := e.UpgradeClusterServices(plan); err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if len(runner.catalogs) != 1 {
+			t.Fatalf("%s: expected 1 playbook to be run, got %d", test.name, len(runner.catalogs))
+		}
+		cc := runner.catalogs[0]
+		if cc.HeapsterMigration.Enabled != test.migrated || cc.MetricsServer.Enabled != test.migrated {
+			t.Errorf("%s: expected the migration and metrics-server to be enabled: %v, got %+v and %+v", test.name, test.migrated, cc.HeapsterMigration, cc.MetricsServer)
+		}
+		if cc.HeapsterMigration.RemoveData != (test.migrated && test.migration.RemoveData) {
+			t.Errorf("%s: unexpected removal of the data %+v", test.name, cc.HeapsterMigration)
+		}
+	}
+}
+
+func TestMigrateHeapsterInPlan(t *testing.T) {
+	plan := Plan{}
+	plan.AddOns.HeapsterMonitoring = &HeapsterMonitoring{}
+	plan.AddOns.HeapsterMonitoring.Options.InfluxDB.PVCName = "influxdb"
+	plan.AddOns.MetricsServer.Disable = true
+	migrated := MigrateHeapsterInPlan(plan)
+	if !migrated.AddOns.HeapsterMonitoring.Disable || migrated.AddOns.MetricsServer.Disable {
+		t.Errorf("expected heapster to be disabled and metrics-server to be enabled, got %+v and %+v", migrated.AddOns.HeapsterMonitoring, migrated.AddOns.MetricsServer)
+	}
+	if migrated.AddOns.HeapsterMonitoring.Options.InfluxDB.PVCName != "influxdb" {
+		t.Errorf("expected the options of heapster to be kept, got %+v", migrated.AddOns.HeapsterMonitoring.Options)
+	}
+	if plan.AddOns.HeapsterMonitoring.Disable {
+		t.Errorf("expected the plan not to be modified")
+	}
+}
@@ -0,0 +1,393 @@
+package install
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+const (
+	// heketiOperationTimeout is the maximum time to wait for a volume to be
+	// created or deleted by Heketi
+	heketiOperationTimeout = 10 * time.Minute
+	// heketiEndpointsNamespace is the namespace of the endpoints of the volumes
+	// that are created through the Heketi API
+	heketiEndpointsNamespace = "default"
+)
+
+// heketiPollInterval is the time between checks of an asynchronous Heketi operation
+var heketiPollInterval = 2 * time.Second
+
+// heketiClient is a client of the Heketi REST API that sends the requests
+// from a node of the cluster
+type heketiClient struct {
+	config *Heketi
+	client ssh.Client
+	now    func() time.Time
+}
+
+type heketiReplicate struct {
+	Replica int `json:"replica"`
+}
+
+type heketiDurability struct {
+	Type      string           `json:"type"`
+	Replicate *heketiReplicate `json:"replicate,omitempty"`
+}
+
+type heketiVolumeCreateRequest struct {
+	Size       int              `json:"size"`
+	Name       string           `json:"name"`
+	Clusters   []string         `json:"clusters,omitempty"`
+	Durability heketiDurability `json:"durability"`
+}
+
+type heketiVolumeInfo struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Size  int    `json:"size"`
+	Mount struct {
+		GlusterFS struct {
+			Hosts  []string `json:"hosts"`
+			Device string   `json:"device"`
+		} `json:"glusterfs"`
+	} `json:"mount"`
+}
+
+func newHeketiClient(p *Plan, sshClient func(p *Plan, host string) (ssh.Client, error)) (*heketiClient, error) {
+	if !p.heketiEnabled() {
+		return nil, errors.New("the Heketi add-on is not enabled")
+	}
+	if len(p.Master.Nodes) == 0 {
+		return nil, errors.New("the plan does not have master nodes")
+	}
+	host := p.Master.Nodes[0].Host
+	client, err := sshClient(p, host)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSH client for %q: %v", host, err)
+	}
+	return &heketiClient{
+		config: p.AddOns.Heketi,
+		client: client,
+		now:    time.Now,
+	}, nil
+}
+
+// token returns the JWT that authenticates the request with Heketi. The token
+// is bound to the method and path of the request.
+func (h *heketiClient) token(method, path string) string {
+	enc := base64.RawURLEncoding
+	qsh := sha256.Sum256([]byte(method + "&" + path))
+	now := h.now().Unix()
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss": h.config.User,
+		"iat": now,
+		"exp": now + 600,
+		"qsh": hex.EncodeToString(qsh[:]),
+	})
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	mac := hmac.New(sha256.New, []byte(h.config.Secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// do sends the request to Heketi, and returns the status code, the Location
+// header and the body of the response. An error is returned when the status
+// code is not one of the expected codes.
+func (h *heketiClient) do(method, path string, body interface{}, expected ...int) (int, string, []byte, error) {
+	req := nodeHTTPRequest{
+		method: method,
+		url:    strings.TrimSuffix(h.config.URL, "/") + path,
+		headers: map[string]string{
+			"Authorization": "bearer " + h.token(method, path),
+		},
+	}
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("error marshaling request: %v", err)
+		}
+		req.body = string(b)
+		req.headers["Content-Type"] = "application/json"
+	}
+	status, header, resp, err := req.sendWithHeader(h.client)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	for _, e := range expected {
+		if status == e {
+			return status, header.Get("Location"), resp, nil
+		}
+	}
+	// heketi returns errors as plain text
+	return status, "", nil, fmt.Errorf("%s %s: status code %d: %s", method, path, status, strings.TrimSpace(string(resp)))
+}
+
+// wait waits for the asynchronous operation at the location to complete, and
+// returns the location of the result of the operation, if any
+func (h *heketiClient) wait(location string) (string, error) {
+	if u, err := url.Parse(location); err == nil && u.IsAbs() {
+		location = u.RequestURI()
+	}
+	deadline := h.now().Add(heketiOperationTimeout)
+	for {
+		status, result, _, err := h.do("GET", location, nil, 200, 204, 303)
+		if err != nil {
+			return "", err
+		}
+		switch status {
+		case 204:
+			return "", nil
+		case 303:
+			if u, err := url.Parse(result); err == nil && u.IsAbs() {
+				result = u.RequestURI()
+			}
+			return result, nil
+		}
+		// the operation is pending
+		if !h.now().Before(deadline) {
+			return "", fmt.Errorf("timed out after %v waiting for Heketi operation %s", heketiOperationTimeout, location)
+		}
+		time.Sleep(heketiPollInterval)
+	}
+}
+
+func (h *heketiClient) volume(id string) (*heketiVolumeInfo, error) {
+	_, _, resp, err := h.do("GET", "/volumes/"+id, nil, 200)
+	if err != nil {
+		return nil, err
+	}
+	v := &heketiVolumeInfo{}
+	if err := json.Unmarshal(resp, v); err != nil {
+		return nil, fmt.Errorf("error parsing volume %s: %v", id, err)
+	}
+	return v, nil
+}
+
+// volumeByName returns the volume with the given name, or nil if Heketi does
+// not have a volume with the name
+func (h *heketiClient) volumeByName(name string) (*heketiVolumeInfo, error) {
+	_, _, resp, err := h.do("GET", "/volumes", nil, 200)
+	if err != nil {
+		return nil, err
+	}
+	list := struct {
+		Volumes []string `json:"volumes"`
+	}{}
+	if err := json.Unmarshal(resp, &list); err != nil {
+		return nil, fmt.Errorf("error parsing volume list: %v", err)
+	}
+	for _, id := range list.Volumes {
+		v, err := h.volume(id)
+		if err != nil {
+			return nil, err
+		}
+		if v.Name == name {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *heketiClient) createVolume(req heketiVolumeCreateRequest) (*heketiVolumeInfo, error) {
+	_, location, _, err := h.do("POST", "/volumes", req, 202)
+	if err != nil {
+		return nil, err
+	}
+	result, err := h.wait(location)
+	if err != nil {
+		return nil, err
+	}
+	return h.volume(strings.TrimPrefix(result, "/volumes/"))
+}
+
+func (h *heketiClient) deleteVolume(id string) error {
+	_, location, _, err := h.do("DELETE", "/volumes/"+id, nil, 202)
+	if err != nil {
+		return err
+	}
+	_, err = h.wait(location)
+	return err
+}
+
+// heketiVolumeCreateRequestFor returns the Heketi request that creates the
+// storage volume. Heketi places the bricks of the volumes, so only the replica
+// count of the volume is used.
+func heketiVolumeCreateRequestFor(h *Heketi, sv StorageVolume) (heketiVolumeCreateRequest, error) {
+	if sv.DistributionCount > 1 {
+		return heketiVolumeCreateRequest{}, errors.New("the distribution of volumes is managed by Heketi, the distribution count must be 1")
+	}
+	if len(sv.AllowAddresses) > 0 {
+		return heketiVolumeCreateRequest{}, errors.New("allowed addresses are not supported for volumes created through Heketi")
+	}
+	req := heketiVolumeCreateRequest{
+		Size:       sv.SizeGB,
+		Name:       sv.Name,
+		Durability: heketiDurability{Type: "none"},
+	}
+	if h.ClusterID != "" {
+		req.Clusters = []string{h.ClusterID}
+	}
+	if sv.ReplicateCount > 1 {
+		req.Durability.Type = "replicate"
+		req.Durability.Replicate = &heketiReplicate{Replica: sv.ReplicateCount}
+	}
+	return req, nil
+}
+
+// heketiEndpointsName returns the name of the endpoints and service that
+// expose the GlusterFS hosts of the volume
+func heketiEndpointsName(volume string) string {
+	return "glusterfs-" + volume
+}
+
+// heketiVolumeManifest returns the manifest of the PersistentVolume of the
+// Heketi volume, and of the endpoints of the GlusterFS hosts it is mounted from
+func heketiVolumeManifest(sv StorageVolume, v *heketiVolumeInfo) (string, error) {
+	name := heketiEndpointsName(sv.Name)
+	var addresses []map[string]string
+	for _, h := range v.Mount.GlusterFS.Hosts {
+		addresses = append(addresses, map[string]string{"ip": h})
+	}
+	// the path of the volume is the GlusterFS volume name in the host:name device
+	path := v.Name
+	if parts := strings.SplitN(v.Mount.GlusterFS.Device, ":", 2); len(parts) == 2 {
+		path = parts[1]
+	}
+	list := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items": []interface{}{
+			map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Endpoints",
+				"metadata":   map[string]interface{}{"name": name, "namespace": heketiEndpointsNamespace},
+				"subsets": []interface{}{map[string]interface{}{
+					"addresses": addresses,
+					"ports":     []interface{}{map[string]int{"port": 1}},
+				}},
+			},
+			map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Service",
+				"metadata":   map[string]interface{}{"name": name, "namespace": heketiEndpointsNamespace},
+				"spec": map[string]interface{}{
+					"ports": []interface{}{map[string]int{"port": 1}},
+				},
+			},
+			map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolume",
+				"metadata": map[string]interface{}{
+					"name": sv.Name,
+					"annotations": map[string]string{
+						"volume.beta.kubernetes.io/storage-class": sv.StorageClass,
+						"kismatic/heketi-volume-id":               v.ID,
+					},
+				},
+				"spec": map[string]interface{}{
+					"capacity":                      map[string]string{"storage": fmt.Sprintf("%dGi", sv.SizeGB)},
+					"accessModes":                   sv.AccessModes,
+					"persistentVolumeReclaimPolicy": sv.ReclaimPolicy,
+					"glusterfs":                     map[string]string{"endpoints": name, "path": path},
+				},
+			},
+		},
+	}
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling the persistent volume: %v", err)
+	}
+	return string(b), nil
+}
+
+// addHeketiVolume creates the volume through the Heketi API, and creates the
+// PersistentVolume of the volume
+func addHeketiVolume(out io.Writer, p *Plan, sv StorageVolume, sshClient func(p *Plan, host string) (ssh.Client, error)) error {
+	req, err := heketiVolumeCreateRequestFor(p.AddOns.Heketi, sv)
+	if err != nil {
+		return err
+	}
+	h, err := newHeketiClient(p, sshClient)
+	if err != nil {
+		return err
+	}
+	existing, err := h.volumeByName(sv.Name)
+	if err != nil {
+		return fmt.Errorf("error listing Heketi volumes: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("a Heketi volume named %q already exists", sv.Name)
+	}
+	util.PrettyPrint(out, "Creating Heketi volume %s", sv.Name)
+	v, err := h.createVolume(req)
+	if err != nil {
+		util.PrintError(out)
+		return fmt.Errorf("error creating Heketi volume: %v", err)
+	}
+	util.PrintOkln(out)
+
+	manifest, err := heketiVolumeManifest(sv, v)
+	if err != nil {
+		return err
+	}
+	util.PrettyPrint(out, "Creating Kubernetes persistent volume %s", sv.Name)
+	if err := (data.RemoteKubectl{SSHClient: h.client}).Apply(manifest); err != nil {
+		util.PrintError(out)
+		return err
+	}
+	util.PrintOkln(out)
+	return nil
+}
+
+// deleteHeketiVolume deletes the PersistentVolume of the volume, and deletes
+// the volume through the Heketi API
+func deleteHeketiVolume(out io.Writer, p *Plan, name string, sshClient func(p *Plan, host string) (ssh.Client, error)) error {
+	h, err := newHeketiClient(p, sshClient)
+	if err != nil {
+		return err
+	}
+	v, err := h.volumeByName(name)
+	if err != nil {
+		return fmt.Errorf("error listing Heketi volumes: %v", err)
+	}
+	if v == nil {
+		return fmt.Errorf("a Heketi volume named %q does not exist", name)
+	}
+
+	util.PrettyPrint(out, "Deleting Kubernetes persistent volume %s", name)
+	kubectl := data.RemoteKubectl{SSHClient: h.client}
+	if err := kubectl.Delete("pv", "", name); err != nil {
+		util.PrintError(out)
+		return err
+	}
+	if err := kubectl.Delete("service", heketiEndpointsNamespace, heketiEndpointsName(name)); err != nil {
+		util.PrintError(out)
+		return err
+	}
+	if err := kubectl.Delete("endpoints", heketiEndpointsNamespace, heketiEndpointsName(name)); err != nil {
+		util.PrintError(out)
+		return err
+	}
+	util.PrintOkln(out)
+
+	util.PrettyPrint(out, "Deleting Heketi volume %s", name)
+	if err := h.deleteVolume(v.ID); err != nil {
+		util.PrintError(out)
+		return fmt.Errorf("error deleting Heketi volume: %v", err)
+	}
+	util.PrintOkln(out)
+	return nil
+}
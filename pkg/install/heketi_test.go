@@ -0,0 +1,248 @@
+package install
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// fakeHeketi simulates the Heketi API and kubectl, as seen from a node
+type fakeHeketi struct {
+	volumes   map[string]heketiVolumeInfo
+	pending   int
+	requests  []nodeHTTPRequest
+	created   []heketiVolumeCreateRequest
+	kubectl   []string
+	queueDone string
+}
+
+func (h *fakeHeketi) respond(status int, header string, body interface{}) (string, error) {
+	var b []byte
+	switch v := body.(type) {
+	case nil:
+	case string:
+		b = []byte(v)
+	default:
+		b, _ = json.Marshal(v)
+	}
+	resp := fmt.Sprintf("HTTP/1.1 %d Status\r\nContent-Type: application/json\r\n%s\r\n%s", status, header, b)
+	return fmt.Sprintf("%s\n%d", resp, status), nil
+}
+
+func (h *fakeHeketi) Output(pty bool, args ...string) (string, error) {
+	cmd := strings.Join(args, " ")
+	if strings.HasPrefix(cmd, "sudo kubectl") {
+		h.kubectl = append(h.kubectl, cmd)
+		return "", nil
+	}
+	r, err := parseCurlConfig(cmd)
+	if err != nil {
+		return "", err
+	}
+	h.requests = append(h.requests, r)
+	if !strings.HasPrefix(r.headers["Authorization"], "bearer ") {
+		return h.respond(401, "", "missing token")
+	}
+	path := strings.TrimPrefix(r.url, "http://heketi:8080")
+	switch {
+	case path == "/volumes" && r.method == "GET":
+		var ids []string
+		for id := range h.volumes {
+			ids = append(ids, id)
+		}
+		return h.respond(200, "", map[string][]string{"volumes": ids})
+	case path == "/volumes" && r.method == "POST":
+		req := heketiVolumeCreateRequest{}
+		if err := json.Unmarshal([]byte(r.body), &req); err != nil {
+			return h.respond(400, "", err.Error())
+		}
+		h.created = append(h.created, req)
+		v := heketiVolumeInfo{ID: "new", Name: req.Name, Size: req.Size}
+		v.Mount.GlusterFS.Hosts = []string{"10.0.1.1", "10.0.1.2"}
+		v.Mount.GlusterFS.Device = "10.0.1.1:" + req.Name
+		h.volumes["new"] = v
+		h.queueDone = "Location: /volumes/new\r\n"
+		return h.respond(202, "Location: /queue/op\r\n", nil)
+	case strings.HasPrefix(path, "/volumes/") && r.method == "GET":
+		v, ok := h.volumes[strings.TrimPrefix(path, "/volumes/")]
+		if !ok {
+			return h.respond(404, "", "Id not found")
+		}
+		return h.respond(200, "", v)
+	case strings.HasPrefix(path, "/volumes/") && r.method == "DELETE":
+		delete(h.volumes, strings.TrimPrefix(path, "/volumes/"))
+		h.queueDone = ""
+		return h.respond(202, "Location: http://heketi:8080/queue/op\r\n", nil)
+	case path == "/queue/op":
+		if h.pending > 0 {
+			h.pending--
+			return h.respond(200, "X-Pending: true\r\n", nil)
+		}
+		if h.queueDone == "" {
+			return h.respond(204, "", nil)
+		}
+		return h.respond(303, h.queueDone, nil)
+	}
+	return h.respond(404, "", "not found")
+}
+
+func (h *fakeHeketi) Shell(pty bool, args ...string) error { return nil }
+
+func init() {
+	heketiPollInterval = time.Millisecond
+}
+
+func heketiTestPlan() *Plan {
+	p := &Plan{}
+	p.Master.Nodes = []Node{{Host: "master1", IP: "10.0.0.1"}}
+	p.AddOns.Heketi = &Heketi{URL: "http://heketi:8080", User: "admin", Secret: "secret", ClusterID: "cluster"}
+	return p
+}
+
+func heketiTestSSHClient(h *fakeHeketi, hosts *[]string) func(p *Plan, host string) (ssh.Client, error) {
+	return func(p *Plan, host string) (ssh.Client, error) {
+		*hosts = append(*hosts, host)
+		return h, nil
+	}
+}
+
+func TestAddHeketiVolume(t *testing.T) {
+	h := &fakeHeketi{volumes: map[string]heketiVolumeInfo{}, pending: 2}
+	var hosts []string
+	sv := StorageVolume{
+		Name:              "storage01",
+		SizeGB:            10,
+		ReplicateCount:    3,
+		DistributionCount: 1,
+		StorageClass:      "durable",
+		ReclaimPolicy:     "Retain",
+		AccessModes:       []string{"ReadWriteMany"},
+	}
+	if err := addHeketiVolume(ioutil.Discard, heketiTestPlan(), sv, heketiTestSSHClient(h, &hosts)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0] != "master1" {
+		t.Errorf("expected the requests to be sent from the first master, got %v", hosts)
+	}
+	if len(h.created) != 1 {
+		t.Fatalf("expected one volume to be created, got %v", h.created)
+	}
+	req := h.created[0]
+	if req.Size != 10 || req.Name != "storage01" || req.Durability.Type != "replicate" || req.Durability.Replicate.Replica != 3 || len(req.Clusters) != 1 || req.Clusters[0] != "cluster" {
+		t.Errorf("unexpected volume create request: %+v", req)
+	}
+	if len(h.kubectl) != 1 {
+		t.Fatalf("expected the persistent volume to be applied, got %v", h.kubectl)
+	}
+	for _, s := range []string{`"kind": "PersistentVolume"`, `"endpoints": "glusterfs-storage01"`, `"path": "storage01"`, `"ip": "10.0.1.2"`, `"storage": "10Gi"`, `"volume.beta.kubernetes.io/storage-class": "durable"`} {
+		if !strings.Contains(h.kubectl[0], s) {
+			t.Errorf("expected the manifest to contain %s, got %s", s, h.kubectl[0])
+		}
+	}
+
+	// the volume exists
+	if err := addHeketiVolume(ioutil.Discard, heketiTestPlan(), sv, heketiTestSSHClient(h, &hosts)); err == nil {
+		t.Errorf("expected an error when the volume already exists")
+	}
+}
+
+func TestAddHeketiVolumeInvalid(t *testing.T) {
+	tests := []StorageVolume{
+		{Name: "v", SizeGB: 1, ReplicateCount: 2, DistributionCount: 2},
+		{Name: "v", SizeGB: 1, ReplicateCount: 2, DistributionCount: 1, AllowAddresses: []string{"10.10.*.*"}},
+	}
+	for i, sv := range tests {
+		h := &fakeHeketi{volumes: map[string]heketiVolumeInfo{}}
+		var hosts []string
+		if err := addHeketiVolume(ioutil.Discard, heketiTestPlan(), sv, heketiTestSSHClient(h, &hosts)); err == nil {
+			t.Errorf("test %d: expected an error", i)
+		}
+		if len(h.requests) != 0 {
+			t.Errorf("test %d: expected no requests to Heketi, got %v", i, h.requests)
+		}
+	}
+}
+
+func TestDeleteHeketiVolume(t *testing.T) {
+	h := &fakeHeketi{
+		volumes: map[string]heketiVolumeInfo{
+			"a": {ID: "a", Name: "other"},
+			"b": {ID: "b", Name: "storage01"},
+		},
+		pending: 1,
+	}
+	var hosts []string
+	if err := deleteHeketiVolume(ioutil.Discard, heketiTestPlan(), "storage01", heketiTestSSHClient(h, &hosts)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := h.volumes["b"]; ok {
+		t.Errorf("expected the volume to be deleted")
+	}
+	if _, ok := h.volumes["a"]; !ok {
+		t.Errorf("expected the other volume to not be deleted")
+	}
+	if len(h.kubectl) != 3 || !strings.Contains(h.kubectl[0], "delete pv storage01") || !strings.Contains(h.kubectl[2], "delete endpoints glusterfs-storage01 --ignore-not-found -n default") {
+		t.Errorf("unexpected kubectl commands: %v", h.kubectl)
+	}
+
+	if err := deleteHeketiVolume(ioutil.Discard, heketiTestPlan(), "storage01", heketiTestSSHClient(h, &hosts)); err == nil {
+		t.Errorf("expected an error when the volume does not exist")
+	}
+}
+
+func TestHeketiToken(t *testing.T) {
+	h := &heketiClient{
+		config: &Heketi{User: "admin", Secret: "secret"},
+		now:    func() time.Time { return time.Unix(1500000000, 0) },
+	}
+	token := h.token("GET", "/volumes")
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a JWT with 3 parts, got %q", token)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("error decoding the claims: %v", err)
+	}
+	claims := struct {
+		Iss string
+		Iat int64
+		Exp int64
+		Qsh string
+	}{}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		t.Fatalf("error parsing the claims: %v", err)
+	}
+	qsh := sha256.Sum256([]byte("GET&/volumes"))
+	if claims.Iss != "admin" || claims.Iat != 1500000000 || claims.Exp != 1500000600 || claims.Qsh != hex.EncodeToString(qsh[:]) {
+		t.Errorf("unexpected claims: %s", b)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if parts[2] != base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected signature of the token %q", token)
+	}
+}
+
+func TestSplitResponseHeader(t *testing.T) {
+	out := "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 202 Accepted\r\nLocation: /queue/op\r\nContent-Length: 0\r\n\r\nbody"
+	header, body, err := splitResponseHeader(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if header.Get("Location") != "/queue/op" || body != "body" {
+		t.Errorf("unexpected header %v and body %q", header, body)
+	}
+	if _, _, err := splitResponseHeader("body"); err == nil {
+		t.Errorf("expected an error when the response does not have a header")
+	}
+}
@@ -0,0 +1,182 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/util"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// HookBefore runs the hook before the task
+	HookBefore = "before"
+	// HookAfter runs the hook after the task succeeded
+	HookAfter = "after"
+)
+
+// Hook is a local command or an extra playbook that is run before or after
+// a task of the executor, such as registering the nodes in an inventory
+// system once they are installed
+type Hook struct {
+	// Task is the name of the task, such as preflight, apply, upgrade-nodes
+	// or reset
+	Task string `yaml:"task"`
+	// When the hook is run, before or after the task. The hooks that run after
+	// a task are only run when the task succeeded.
+	When string `yaml:"when"`
+	// Command is run with sh on the machine running kismatic, with the
+	// KISMATIC_TASK, KISMATIC_HOOK, KISMATIC_CLUSTER and KISMATIC_NODES
+	// environment variables
+	Command string `yaml:"command,omitempty"`
+	// Playbook is an ansible playbook that is run against the nodes of the
+	// task, with the inventory and the cluster catalog of the task
+	Playbook string `yaml:"playbook,omitempty"`
+	// IgnoreErrors continues the operation when the hook fails
+	IgnoreErrors bool `yaml:"ignore_errors,omitempty"`
+}
+
+type hooksFile struct {
+	Hooks []Hook `yaml:"hooks"`
+}
+
+// LoadHooks reads the hooks listed in the file. The paths of the playbooks
+// are relative to the directory of the file.
+func LoadHooks(file string) ([]Hook, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading hooks file %q: %v", file, err)
+	}
+	f := hooksFile{}
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("error parsing hooks file %q: %v", file, err)
+	}
+	for i, h := range f.Hooks {
+		if h.Playbook != "" && !filepath.IsAbs(h.Playbook) {
+			f.Hooks[i].Playbook = filepath.Join(filepath.Dir(file), h.Playbook)
+		}
+	}
+	if err := validateHooks(f.Hooks); err != nil {
+		return nil, fmt.Errorf("invalid hooks file %q: %v", file, err)
+	}
+	return f.Hooks, nil
+}
+
+func validateHooks(hooks []Hook) error {
+	for i, h := range hooks {
+		if h.Task == "" {
+			return fmt.Errorf("the task of hook %d is required", i+1)
+		}
+		if h.When != HookBefore && h.When != HookAfter {
+			return fmt.Errorf("hook %d of the %q task: when %q is not supported. Options are %v", i+1, h.Task, h.When, []string{HookBefore, HookAfter})
+		}
+		if (h.Command == "") == (h.Playbook == "") {
+			return fmt.Errorf("hook %d of the %q task: either a command or a playbook is required", i+1, h.Task)
+		}
+	}
+	return nil
+}
+
+// runHooks runs the hooks of the task, in the order they are declared, and
+// returns the summary of their playbooks. The hooks are not run for dry runs,
+// nor for the playbooks of other hooks.
+func (ae *ansibleExecutor) runHooks(t task, when string) (RunSummary, error) {
+	summary := RunSummary{}
+	if t.hook {
+		return summary, nil
+	}
+	out := ae.stdout
+	if t.out != nil {
+		out = t.out
+	}
+	for _, h := range ae.options.Hooks {
+		if h.Task != t.name || h.When != when {
+			continue
+		}
+		log := logging.With("task", t.name, "hook", when, "command", h.Command, "playbook", h.Playbook)
+		if ae.options.DryRun {
+			log.Info("skipping hook due to dry run")
+			continue
+		}
+		log.Info("running hook")
+		var err error
+		if h.Command != "" {
+			util.PrettyPrint(out, "Running the %s hook of %q", when, t.name)
+			if err = ae.runHookCommand(t, h); err != nil {
+				util.PrintError(out)
+				fmt.Fprintln(out)
+			} else {
+				util.PrintOkln(out)
+			}
+		} else {
+			var s RunSummary
+			s, err = ae.runHookPlaybook(t, h)
+			summary = summary.Merge(s)
+		}
+		if err == nil {
+			continue
+		}
+		if h.IgnoreErrors {
+			log.Warn("hook failed, continuing", "error", err)
+			util.PrettyPrintErrorIgnored(out, "The %s hook of %q failed: %v", when, t.name, err)
+			continue
+		}
+		log.Error("hook failed", "error", err)
+		return summary, fmt.Errorf("error running the %s hook of the %q task: %v", when, t.name, err)
+	}
+	return summary, nil
+}
+
+func (ae *ansibleExecutor) runHookCommand(t task, h Hook) error {
+	cmd := exec.CommandContext(ae.context(), "sh", "-c", h.Command)
+	cmd.Env = append(os.Environ(),
+		"KISMATIC_TASK="+t.name,
+		"KISMATIC_HOOK="+h.When,
+		"KISMATIC_CLUSTER="+t.plan.Cluster.Name,
+		"KISMATIC_NODES="+strings.Join(taskHosts(t), ","),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running %q: %v: %s", h.Command, err, out)
+	}
+	return nil
+}
+
+func (ae *ansibleExecutor) runHookPlaybook(t task, h Hook) (RunSummary, error) {
+	playbook, err := filepath.Abs(h.Playbook)
+	if err != nil {
+		return RunSummary{}, fmt.Errorf("error getting the path of playbook %q: %v", h.Playbook, err)
+	}
+	return ae.execute(task{
+		name:           fmt.Sprintf("%s-%s-hook", t.name, h.When),
+		playbook:       playbook,
+		plan:           t.plan,
+		inventory:      t.inventory,
+		clusterCatalog: t.clusterCatalog,
+		explainer:      ae.defaultExplainer(),
+		limit:          t.limit,
+		out:            t.out,
+		hook:           true,
+	})
+}
+
+// taskHosts returns the hosts targeted by the task, in the order of the
+// inventory
+func taskHosts(t task) []string {
+	var hosts []string
+	for _, r := range t.inventory.Roles {
+		for _, n := range r.Nodes {
+			if len(t.limit) > 0 && !util.Contains(n.Host, t.limit) {
+				continue
+			}
+			if !util.Contains(n.Host, hosts) {
+				hosts = append(hosts, n.Host)
+			}
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,161 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func hookTestTask(e *ansibleExecutor) task {
+	return task{
+		name:     "apply",
+		playbook: "kubernetes.yaml",
+		plan:     Plan{Cluster: Cluster{Name: "prod"}},
+		inventory: ansible.Inventory{Roles: []ansible.Role{
+			{Name: "master", Nodes: []ansible.Node{{Host: "master1"}}},
+			{Name: "worker", Nodes: []ansible.Node{{Host: "worker1"}, {Host: "worker2"}}},
+		}},
+		limit:     []string{"master1", "worker2"},
+		explainer: e.defaultExplainer(),
+	}
+}
+
+func TestExecuteRunsHooks(t *testing.T) {
+	record := filepath.Join(mustGetTempDir(t), "hooks")
+	command := fmt.Sprintf(`echo "$KISMATIC_HOOK $KISMATIC_TASK $KISMATIC_CLUSTER $KISMATIC_NODES" >> %s`, record)
+	runner := &retryRunner{}
+	e := retryExecutor(t, runner, RetryPolicy{})
+	e.options.Hooks = []Hook{
+		{Task: "apply", When: HookAfter, Command: command},
+		{Task: "apply", When: HookBefore, Command: command},
+		{Task: "reset", When: HookBefore, Command: command},
+		{Task: "apply", When: HookAfter, Playbook: "/hooks/cmdb.yaml"},
+	}
+	if _, err := e.execute(hookTestTask(e)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(record)
+	if err != nil {
+		t.Fatalf("error reading the hooks that were run: %v", err)
+	}
+	expected := "before apply prod master1,worker2\nafter apply prod master1,worker2\n"
+	if string(b) != expected {
+		t.Errorf("expected the hooks\n%s\ngot\n%s", expected, b)
+	}
+	// the task and the playbook of the hook
+	if len(runner.runs) != 2 {
+		t.Errorf("expected 2 playbooks to be run, got %d", len(runner.runs))
+	}
+	if runs, _ := filepath.Glob(filepath.Join(e.options.RunsDirectory, "apply-after-hook", "*")); len(runs) != 1 {
+		t.Errorf("expected the playbook of the hook to be recorded in the runs directory, got %v", runs)
+	}
+}
+
+func TestExecuteFailedHooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		hook     Hook
+		failures int
+		runs     int
+		valid    bool
+	}{
+		{
+			name: "failed before hook",
+			hook: Hook{Task: "apply", When: HookBefore, Command: "exit 1"},
+		},
+		{
+			name:  "ignored errors",
+			hook:  Hook{Task: "apply", When: HookBefore, Command: "exit 1", IgnoreErrors: true},
+			runs:  1,
+			valid: true,
+		},
+		{
+			name: "failed after hook",
+			hook: Hook{Task: "apply", When: HookAfter, Command: "exit 1"},
+			runs: 1,
+		},
+		{
+			name:     "failed task",
+			hook:     Hook{Task: "apply", When: HookAfter, Playbook: "/hooks/cmdb.yaml"},
+			failures: 1,
+			runs:     1,
+		},
+	}
+	for _, test := range tests {
+		runner := &retryRunner{failing: []string{"worker2"}, failures: test.failures}
+		e := retryExecutor(t, runner, RetryPolicy{})
+		e.options.Hooks = []Hook{test.hook}
+		_, err := e.execute(hookTestTask(e))
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if len(runner.runs) != test.runs {
+			t.Errorf("%s: expected %d playbooks to be run, got %d", test.name, test.runs, len(runner.runs))
+		}
+	}
+}
+
+func TestExecuteDryRunSkipsHooks(t *testing.T) {
+	e := retryExecutor(t, &retryRunner{}, RetryPolicy{})
+	e.options.DryRun = true
+	e.options.Hooks = []Hook{{Task: "apply", When: HookBefore, Command: "exit 1"}}
+	if _, err := e.runHooks(hookTestTask(e), HookBefore); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadHooks(t *testing.T) {
+	dir := mustGetTempDir(t)
+	file := filepath.Join(dir, "hooks.yaml")
+	content := `hooks:
+- task: preflight
+  when: before
+  command: ./check-change-window.sh
+- task: apply
+  when: after
+  playbook: playbooks/register-nodes.yaml
+  ignore_errors: true
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("error writing hooks file: %v", err)
+	}
+	hooks, err := LoadHooks(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hooks) != 2 {
+		t.Fatalf("expected 2 hooks, got %d", len(hooks))
+	}
+	if hooks[0].Task != "preflight" || hooks[0].When != HookBefore || hooks[0].Command != "./check-change-window.sh" {
+		t.Errorf("unexpected hook %+v", hooks[0])
+	}
+	if hooks[1].Playbook != filepath.Join(dir, "playbooks", "register-nodes.yaml") || !hooks[1].IgnoreErrors {
+		t.Errorf("expected the playbook to be relative to the hooks file, got %+v", hooks[1])
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	tests := []Hook{
+		{When: HookBefore, Command: "true"},
+		{Task: "apply", When: "during", Command: "true"},
+		{Task: "apply", When: HookAfter},
+		{Task: "apply", When: HookAfter, Command: "true", Playbook: "cmdb.yaml"},
+	}
+	for _, h := range tests {
+		err := validateHooks([]Hook{h})
+		if err == nil {
+			t.Errorf("%+v: expected an error", h)
+			continue
+		}
+		if !strings.Contains(err.Error(), "hook 1") {
+			t.Errorf("%+v: expected the error to name the hook, got %v", h, err)
+		}
+	}
+}
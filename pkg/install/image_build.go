@@ -0,0 +1,182 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+)
+
+// NodeImageMarkerFile is written to the machine images built by KET, and
+// describes the versions preloaded on the image
+const NodeImageMarkerFile = "/etc/kismatic/node-image.json"
+
+// NodeImage is the content of the marker file of a machine image built by KET
+type NodeImage struct {
+	// Kubernetes is the version of the packages and images preloaded on the image
+	Kubernetes string `json:"kubernetes"`
+	// Kismatic is the version of KET that built the image
+	Kismatic string `json:"kismatic"`
+	// Images are the container images preloaded on the image
+	Images []string `json:"images"`
+}
+
+// NodeImageBuild is a machine image with the packages and container images
+// required by the nodes of a cluster preloaded
+type NodeImageBuild struct {
+	NodeRequirements
+	Images []string
+	// NodeImage is the content of the marker file
+	NodeImage string
+}
+
+// NodeImageBuildForPlan returns the image build for the plan and operating
+// system. The image includes the packages of all the roles, and the container
+// images, as they are pulled by the nodes of the cluster.
+func NodeImageBuildForPlan(p Plan, os string, images []string) (*NodeImageBuild, error) {
+	if !contains(os, ExportOperatingSystems) {
+		return nil, fmt.Errorf("operating system %q is not supported, must be one of %v", os, ExportOperatingSystems)
+	}
+	if p.Cluster.Version == "" {
+		p.Cluster.Version = kubernetesVersionString
+	}
+	r := NodeRequirements{
+		OS:    os,
+		Roles: []string{"etcd", "master", "worker", "ingress"},
+	}
+	if len(p.Storage.Nodes) > 0 {
+		r.Roles = append(r.Roles, "storage")
+	}
+	r.Packages = nodePackages(p, r)
+	if !p.Cluster.DisconnectedInstallation {
+		r.Repositories = nodeRepositories(p, r)
+	}
+	for name, value := range nodeSysctls {
+		r.Sysctls = append(r.Sysctls, Sysctl{Name: name, Value: value})
+	}
+	sort.Slice(r.Sysctls, func(i, j int) bool { return r.Sysctls[i].Name < r.Sysctls[j].Name })
+
+	build := &NodeImageBuild{NodeRequirements: r}
+	for _, img := range images {
+		// images are pulled from the private registry in disconnected installations
+		if p.PrivateRegistryProvided() && p.Cluster.DisconnectedInstallation {
+			img = p.DockerRegistry.Server + "/" + img
+		}
+		build.Images = append(build.Images, img)
+	}
+	sort.Strings(build.Images)
+	b, err := json.MarshalIndent(NodeImage{
+		Kubernetes: p.Cluster.Version,
+		Kismatic:   KismaticVersion.String(),
+		Images:     build.Images,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling node image: %v", err)
+	}
+	build.NodeImage = string(b)
+	return build, nil
+}
+
+var nodeImageScriptTemplate = template.Must(template.New("node-image").Funcs(exportFuncs).Parse(`#!/bin/sh
+# Generated by kismatic: preloads the packages and container images required
+# by the nodes of the cluster
+set -e
+{{ if .Yum }}
+{{- range .Repositories }}
+cat > /etc/yum.repos.d/{{ .Name }}.repo <<'EOF'
+[{{ .Name }}]
+name={{ .Name }}
+baseurl={{ .BaseURL }}
+gpgkey={{ join .GPGKeys " " }}
+gpgcheck=1
+enabled=1
+EOF
+{{- end }}
+{{- if .Packages }}
+yum install -y --setopt=obsoletes=0 {{ join .Packages " " }}
+{{- end }}
+yum clean all
+{{- else }}
+export DEBIAN_FRONTEND=noninteractive
+{{- if .Repositories }}
+apt-get update
+apt-get install -y apt-transport-https curl software-properties-common
+{{- end }}
+{{- range .Repositories }}
+{{- range .GPGKeys }}
+curl -fsSL {{ . }} | apt-key add -
+{{- end }}
+{{- if .PPA }}
+add-apt-repository -y {{ .Source }}
+{{- else }}
+echo '{{ .Source }}' > /etc/apt/sources.list.d/{{ .Name }}.list
+{{- end }}
+{{- end }}
+{{- if .Packages }}
+apt-get update
+apt-get install -y {{ join .Packages " " }}
+{{- end }}
+apt-get clean
+{{- end }}
+
+mkdir -p /etc/modules-load.d /etc/sysctl.d
+echo br_netfilter > /etc/modules-load.d/kismatic.conf
+cat > /etc/sysctl.d/90-kismatic.conf <<'EOF'
+{{- range .Sysctls }}
+{{ .Name }} = {{ .Value }}
+{{- end }}
+EOF
+
+systemctl enable docker
+systemctl start docker
+{{- range .Images }}
+docker pull {{ . }}
+{{- end }}
+
+mkdir -p /etc/kismatic
+cat > {{ .MarkerFile }} <<'EOF'
+{{ .NodeImage }}
+EOF
+`))
+
+// MarkerFile returns the path of the marker file of the image
+func (b NodeImageBuild) MarkerFile() string {
+	return NodeImageMarkerFile
+}
+
+// Script returns the shell script that provisions the image
+func (b NodeImageBuild) Script() (string, error) {
+	var buf bytes.Buffer
+	if err := nodeImageScriptTemplate.Execute(&buf, b); err != nil {
+		return "", fmt.Errorf("error rendering node image script: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// PackerTemplate returns a packer template that builds the image using the
+// packer builder, and provisions it with the script
+func PackerTemplate(builder []byte, scriptPath string) ([]byte, error) {
+	var b map[string]interface{}
+	if err := json.Unmarshal(builder, &b); err != nil {
+		return nil, fmt.Errorf("error unmarshaling packer builder: %v", err)
+	}
+	if _, ok := b["type"]; !ok {
+		return nil, fmt.Errorf("the packer builder must have a type")
+	}
+	t := map[string]interface{}{
+		"builders": []interface{}{b},
+		"provisioners": []interface{}{
+			map[string]interface{}{
+				"type":            "shell",
+				"script":          scriptPath,
+				"execute_command": "chmod +x {{ .Path }}; sudo sh -c '{{ .Vars }} {{ .Path }}'",
+			},
+		},
+	}
+	out, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling packer template: %v", err)
+	}
+	return out, nil
+}
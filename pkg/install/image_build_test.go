@@ -0,0 +1,90 @@
+package install
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNodeImageBuildForPlan(t *testing.T) {
+	p := exportTestPlan()
+	build, err := NodeImageBuildForPlan(p, "centos", []string{"quay.io/coreos/etcd:v3.1.13", "calico/node:v2.6.10"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, pkg := range []string{"docker-ce-" + dockerYumVersion, "kubelet-1.10.3-0", "glusterfs-server-" + glusterfsYumVersion} {
+		if !contains(pkg, build.Packages) {
+			t.Errorf("expected %q in the packages of the image, got %v", pkg, build.Packages)
+		}
+	}
+	if build.Images[0] != "calico/node:v2.6.10" {
+		t.Errorf("expected the images to be sorted, got %v", build.Images)
+	}
+	var marker NodeImage
+	if err := json.Unmarshal([]byte(build.NodeImage), &marker); err != nil {
+		t.Fatalf("invalid node image marker: %v", err)
+	}
+	if marker.Kubernetes != "v1.10.3" || len(marker.Images) != 2 {
+		t.Errorf("unexpected node image marker %+v", marker)
+	}
+
+	script, err := build.Script()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, s := range []string{"#!/bin/sh\n", "/etc/yum.repos.d/kubernetes.repo", "yum install -y --setopt=obsoletes=0", "docker pull quay.io/coreos/etcd:v3.1.13", "net.ipv4.ip_forward = 1", "cat > " + NodeImageMarkerFile} {
+		if !strings.Contains(script, s) {
+			t.Errorf("expected %q in the script:\n%s", s, script)
+		}
+	}
+}
+
+func TestNodeImageBuildForPlanPrivateRegistry(t *testing.T) {
+	p := exportTestPlan()
+	p.DockerRegistry.Server = "registry.local:5000"
+	p.Cluster.DisconnectedInstallation = true
+	build, err := NodeImageBuildForPlan(p, "ubuntu", []string{"quay.io/coreos/etcd:v3.1.13"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if build.Images[0] != "registry.local:5000/quay.io/coreos/etcd:v3.1.13" {
+		t.Errorf("expected the image to be pulled from the private registry, got %v", build.Images)
+	}
+	if len(build.Repositories) != 0 {
+		t.Errorf("expected no repositories in a disconnected installation, got %v", build.Repositories)
+	}
+	script, err := build.Script()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(script, "apt-get install -y docker-ce="+dockerDebVersion) {
+		t.Errorf("expected the deb packages to be installed:\n%s", script)
+	}
+}
+
+func TestPackerTemplate(t *testing.T) {
+	b, err := PackerTemplate([]byte(`{"type": "amazon-ebs", "region": "us-east-1"}`), "script.sh")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var tmpl struct {
+		Builders     []map[string]interface{}
+		Provisioners []map[string]interface{}
+	}
+	if err := json.Unmarshal(b, &tmpl); err != nil {
+		t.Fatalf("invalid packer template: %v", err)
+	}
+	if len(tmpl.Builders) != 1 || tmpl.Builders[0]["region"] != "us-east-1" {
+		t.Errorf("unexpected builders %v", tmpl.Builders)
+	}
+	if len(tmpl.Provisioners) != 1 || tmpl.Provisioners[0]["script"] != "script.sh" {
+		t.Errorf("unexpected provisioners %v", tmpl.Provisioners)
+	}
+
+	if _, err := PackerTemplate([]byte(`{"region": "us-east-1"}`), "script.sh"); err == nil {
+		t.Errorf("expected an error with a builder without a type")
+	}
+	if _, err := PackerTemplate([]byte(`not json`), "script.sh"); err == nil {
+		t.Errorf("expected an error with an invalid builder")
+	}
+}
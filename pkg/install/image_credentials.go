@@ -0,0 +1,50 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// imageCredentialHelpers are the docker credential helpers of each type of
+// image credential provider
+var imageCredentialHelpers = map[string]string{
+	"ecr": "docker-credential-ecr-login",
+	"gcr": "docker-credential-gcr",
+	"acr": "docker-credential-acr-env",
+}
+
+func imageCredentialProviderTypes() []string {
+	var types []string
+	for t := range imageCredentialHelpers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// imageCredentialProvidersCatalog resolves the helper binaries of the
+// providers, which are downloaded into the artifacts directory when hosted on
+// an HTTP(S) server
+func imageCredentialProvidersCatalog(providers []ImageCredentialProvider, artifactsDir string) ([]ansible.ImageCredentialProvider, error) {
+	var cc []ansible.ImageCredentialProvider
+	for _, icp := range providers {
+		helperPath, err := resolveArtifact(icp.Helper, "", artifactsDir)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving %s credential helper: %v", icp.Type, err)
+		}
+		interval, err := time.ParseDuration(icp.RefreshInterval)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s credential provider refresh interval: %v", icp.Type, err)
+		}
+		cc = append(cc, ansible.ImageCredentialProvider{
+			Helper:                 imageCredentialHelpers[icp.Type],
+			HelperPath:             helperPath,
+			Registries:             icp.Registries,
+			RefreshIntervalSeconds: int(interval.Seconds()),
+		})
+	}
+	return cc, nil
+}
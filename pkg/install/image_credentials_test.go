@@ -0,0 +1,32 @@
+package install
+
+import "testing"
+
+func TestImageCredentialProvidersCatalog(t *testing.T) {
+	providers := []ImageCredentialProvider{
+		{Type: "ecr", Registries: []string{"123456789012.dkr.ecr.us-east-1.amazonaws.com"}, RefreshInterval: "1h"},
+		{Type: "acr", Registries: []string{"myregistry.azurecr.io"}, RefreshInterval: "30m"},
+	}
+	cc, err := imageCredentialProvidersCatalog(providers, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cc) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(cc))
+	}
+	if cc[0].Helper != "docker-credential-ecr-login" || cc[0].HelperPath != "" || cc[0].RefreshIntervalSeconds != 3600 {
+		t.Errorf("unexpected ecr provider %+v", cc[0])
+	}
+	if cc[1].Helper != "docker-credential-acr-env" || cc[1].RefreshIntervalSeconds != 1800 {
+		t.Errorf("unexpected acr provider %+v", cc[1])
+	}
+}
+
+func TestImageCredentialProvidersDefaults(t *testing.T) {
+	p := Plan{}
+	p.Cluster.ImageCredentialProviders = []ImageCredentialProvider{{Type: "gcr", Registries: []string{"gcr.io"}}}
+	setDefaults(&p)
+	if p.Cluster.ImageCredentialProviders[0].RefreshInterval != "30m" {
+		t.Errorf("expected the default refresh interval, got %q", p.Cluster.ImageCredentialProviders[0].RefreshInterval)
+	}
+}
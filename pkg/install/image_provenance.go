@@ -0,0 +1,141 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var imageDigestRE = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ImageVerification is the result of verifying the provenance of a container
+// image before it is pushed to the private registry
+type ImageVerification struct {
+	// Image is the reference of the image, including the tag
+	Image string `json:"image"`
+	// Digest is the expected SHA256 digest of the image
+	Digest string `json:"digest,omitempty"`
+	// DigestVerified is true when the pulled image matches the expected digest
+	DigestVerified bool `json:"digestVerified"`
+	// SignatureVerified is true when the signature of the image was verified with cosign
+	SignatureVerified bool `json:"signatureVerified"`
+	// Error is set when the verification failed
+	Error string `json:"error,omitempty"`
+}
+
+// ReadImageDigests reads the expected digests of the images from a YAML file
+// that maps image references, such as quay.io/coreos/etcd:v3.1.13, to their
+// SHA256 digest
+func ReadImageDigests(file string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image digests file: %v", err)
+	}
+	digests := map[string]string{}
+	if err := yaml.Unmarshal(b, &digests); err != nil {
+		return nil, fmt.Errorf("error unmarshaling image digests file: %v", err)
+	}
+	for image, digest := range digests {
+		if !imageDigestRE.MatchString(digest) {
+			return nil, fmt.Errorf("digest %q of image %q is not valid, must be in the sha256:<hex> format", digest, image)
+		}
+	}
+	return digests, nil
+}
+
+// ImageVerifier verifies the provenance of images that were pulled to the
+// local docker daemon
+type ImageVerifier struct {
+	// Digests maps image references to their expected digest
+	Digests map[string]string
+	// RequireDigests fails the verification of images without an expected digest
+	RequireDigests bool
+	// CosignKey is the path of the public key used for verifying the cosign
+	// signatures of the images. Signatures are not verified when empty.
+	CosignKey string
+
+	// Hook for testing purposes
+	run func(name string, args ...string) (string, error)
+}
+
+// Verify the provenance of the image. Images without an expected digest are
+// not verified, unless digests are required.
+func (v ImageVerifier) Verify(image string) ImageVerification {
+	result := ImageVerification{Image: image}
+	digest, ok := v.Digests[image]
+	if !ok {
+		if v.RequireDigests {
+			result.Error = fmt.Sprintf("the digest of %q is unknown", image)
+		}
+		return result
+	}
+	result.Digest = digest
+	repository := imageRepository(image)
+	out, err := v.runCmd("docker", "inspect", "--format", "{{range .RepoDigests}}{{println .}}{{end}}", image)
+	if err != nil {
+		result.Error = fmt.Sprintf("error getting the digest of %q: %v", image, err)
+		return result
+	}
+	for _, repoDigest := range strings.Fields(out) {
+		if repoDigest == repository+"@"+digest {
+			result.DigestVerified = true
+		}
+	}
+	if !result.DigestVerified {
+		result.Error = fmt.Sprintf("the digest of %q does not match the expected digest %s, found %v", image, digest, strings.Fields(out))
+		return result
+	}
+	if v.CosignKey != "" {
+		if _, err := v.runCmd("cosign", "verify", "--key", v.CosignKey, repository+"@"+digest); err != nil {
+			result.Error = fmt.Sprintf("error verifying the signature of %q: %v", image, err)
+			return result
+		}
+		result.SignatureVerified = true
+	}
+	return result
+}
+
+func (v ImageVerifier) runCmd(name string, args ...string) (string, error) {
+	if v.run != nil {
+		return v.run(name, args...)
+	}
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// imageRepository returns the repository of the image reference, without the tag
+func imageRepository(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
+
+// WriteImageVerifications records the results of the image verifications in
+// the run manifest of a new seed-registry run, and returns the run directory
+func WriteImageVerifications(runsDir string, start time.Time, verifications []ImageVerification) (string, error) {
+	runDirectory := filepath.Join(runsDir, "seed-registry", start.Format("2006-01-02-15-04-05"))
+	if err := os.MkdirAll(runDirectory, 0777); err != nil {
+		return "", fmt.Errorf("error creating directory: %v", err)
+	}
+	manifest := RunManifest{
+		Task:               "seed-registry",
+		KismaticVersion:    KismaticVersion.String(),
+		StartTime:          start,
+		ImageVerifications: verifications,
+	}
+	if err := writeRunManifest(runDirectory, manifest); err != nil {
+		return "", err
+	}
+	return runDirectory, nil
+}
@@ -0,0 +1,190 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testDigest      = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	otherTestDigest = "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+)
+
+func TestReadImageDigests(t *testing.T) {
+	tests := []struct {
+		contents string
+		valid    bool
+	}{
+		{
+			contents: "quay.io/coreos/etcd:v3.1.13: " + testDigest,
+			valid:    true,
+		},
+		{
+			contents: "quay.io/coreos/etcd:v3.1.13: sha256:abc",
+			valid:    false,
+		},
+		{
+			contents: "quay.io/coreos/etcd:v3.1.13: " + strings.TrimPrefix(testDigest, "sha256:"),
+			valid:    false,
+		},
+		{
+			contents: "- not a map",
+			valid:    false,
+		},
+	}
+	dir, err := ioutil.TempDir("", "image-digests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i, test := range tests {
+		file := filepath.Join(dir, "digests.yaml")
+		if err := ioutil.WriteFile(file, []byte(test.contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		digests, err := ReadImageDigests(file)
+		if (err == nil) != test.valid {
+			t.Errorf("%d: expected valid %v, but got error %v", i, test.valid, err)
+		}
+		if test.valid && digests["quay.io/coreos/etcd:v3.1.13"] != testDigest {
+			t.Errorf("%d: unexpected digests %v", i, digests)
+		}
+	}
+}
+
+func TestImageVerifierVerify(t *testing.T) {
+	image := "quay.io/coreos/etcd:v3.1.13"
+	tests := []struct {
+		name              string
+		digests           map[string]string
+		requireDigests    bool
+		cosignKey         string
+		repoDigests       string
+		cosignErr         error
+		expectErr         bool
+		digestVerified    bool
+		signatureVerified bool
+	}{
+		{
+			name: "no digest",
+		},
+		{
+			name:           "no digest, required",
+			requireDigests: true,
+			expectErr:      true,
+		},
+		{
+			name:           "matching digest",
+			digests:        map[string]string{image: testDigest},
+			repoDigests:    "quay.io/coreos/etcd@" + otherTestDigest + "\nquay.io/coreos/etcd@" + testDigest + "\n",
+			digestVerified: true,
+		},
+		{
+			name:        "mismatched digest",
+			digests:     map[string]string{image: testDigest},
+			repoDigests: "quay.io/coreos/etcd@" + otherTestDigest + "\n",
+			expectErr:   true,
+		},
+		{
+			name:        "digest of another repository",
+			digests:     map[string]string{image: testDigest},
+			repoDigests: "docker.io/coreos/etcd@" + testDigest + "\n",
+			expectErr:   true,
+		},
+		{
+			name:              "valid signature",
+			digests:           map[string]string{image: testDigest},
+			cosignKey:         "cosign.pub",
+			repoDigests:       "quay.io/coreos/etcd@" + testDigest + "\n",
+			digestVerified:    true,
+			signatureVerified: true,
+		},
+		{
+			name:           "invalid signature",
+			digests:        map[string]string{image: testDigest},
+			cosignKey:      "cosign.pub",
+			repoDigests:    "quay.io/coreos/etcd@" + testDigest + "\n",
+			cosignErr:      errors.New("no matching signatures"),
+			expectErr:      true,
+			digestVerified: true,
+		},
+	}
+	for _, test := range tests {
+		var commands []string
+		v := ImageVerifier{
+			Digests:        test.digests,
+			RequireDigests: test.requireDigests,
+			CosignKey:      test.cosignKey,
+			run: func(name string, args ...string) (string, error) {
+				commands = append(commands, name+" "+strings.Join(args, " "))
+				if name == "cosign" {
+					return "", test.cosignErr
+				}
+				return test.repoDigests, nil
+			},
+		}
+		result := v.Verify(image)
+		if (result.Error != "") != test.expectErr {
+			t.Errorf("%s: expected error %v, but got %q", test.name, test.expectErr, result.Error)
+		}
+		if result.DigestVerified != test.digestVerified {
+			t.Errorf("%s: expected digest verified %v, but got %v", test.name, test.digestVerified, result.DigestVerified)
+		}
+		if result.SignatureVerified != test.signatureVerified {
+			t.Errorf("%s: expected signature verified %v, but got %v", test.name, test.signatureVerified, result.SignatureVerified)
+		}
+		if test.cosignKey != "" && test.digestVerified {
+			expected := "cosign verify --key cosign.pub quay.io/coreos/etcd@" + testDigest
+			if commands[len(commands)-1] != expected {
+				t.Errorf("%s: expected command %q, but got %q", test.name, expected, commands[len(commands)-1])
+			}
+		}
+	}
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"quay.io/coreos/etcd:v3.1.13":    "quay.io/coreos/etcd",
+		"localhost:5000/nginx:1.13":      "localhost:5000/nginx",
+		"localhost:5000/nginx":           "localhost:5000/nginx",
+		"gcr.io/google_containers/pause": "gcr.io/google_containers/pause",
+	}
+	for image, expected := range tests {
+		if repo := imageRepository(image); repo != expected {
+			t.Errorf("expected %q for %q, but got %q", expected, image, repo)
+		}
+	}
+}
+
+func TestWriteImageVerifications(t *testing.T) {
+	dir, err := ioutil.TempDir("", "image-verifications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	verifications := []ImageVerification{{Image: "quay.io/coreos/etcd:v3.1.13", Digest: testDigest, DigestVerified: true}}
+	runDir, err := WriteImageVerifications(dir, time.Now(), verifications)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(runDir, runManifestFilename))
+	if err != nil {
+		t.Fatalf("error reading run manifest: %v", err)
+	}
+	var m RunManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("error unmarshaling run manifest: %v", err)
+	}
+	if m.Task != "seed-registry" {
+		t.Errorf("expected task seed-registry, but got %q", m.Task)
+	}
+	if len(m.ImageVerifications) != 1 || m.ImageVerifications[0] != verifications[0] {
+		t.Errorf("unexpected image verifications %+v", m.ImageVerifications)
+	}
+}
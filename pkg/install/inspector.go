@@ -0,0 +1,21 @@
+package install
+
+import "fmt"
+
+// InspectorRuleVariables returns the variables used to template the inspector
+// rules for the Kubernetes version. The version supported by KET is used when
+// the version is empty.
+func InspectorRuleVariables(version string) (map[string]string, error) {
+	if version == "" {
+		version = kubernetesVersionString
+	}
+	v, err := parseKubernetesVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("Kubernetes version %q is not supported: %v", version, err)
+	}
+	yum, deb := kubernetesPackageVersions(v)
+	return map[string]string{
+		"kubernetes_yum_version": yum,
+		"kubernetes_deb_version": deb,
+	}, nil
+}
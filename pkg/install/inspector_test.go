@@ -0,0 +1,33 @@
+package install
+
+import "testing"
+
+func TestInspectorRuleVariables(t *testing.T) {
+	tests := []struct {
+		version string
+		yum     string
+		deb     string
+		valid   bool
+	}{
+		{version: "", yum: "1.10.3-0", deb: "1.10.3-00", valid: true},
+		{version: "v1.10.5", yum: "1.10.5-0", deb: "1.10.5-00", valid: true},
+		{version: "1.10.5"},
+		{version: "v1.9.8"},
+	}
+	for _, test := range tests {
+		vars, err := InspectorRuleVariables(test.version)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("expected an error for version %q", test.version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for version %q: %v", test.version, err)
+			continue
+		}
+		if vars["kubernetes_yum_version"] != test.yum || vars["kubernetes_deb_version"] != test.deb {
+			t.Errorf("unexpected variables for version %q: %v", test.version, vars)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+const (
+	installStateFilename = "install-state.json"
+	// phaseMarkerPlayName is the name of the play that records the completion
+	// of a phase of the installation
+	phaseMarkerPlayName = "Record Completed Phase"
+	// phaseMarkerPrefix prefixes the message of the task that records the
+	// completion of a phase on a node
+	phaseMarkerPrefix = "kismatic phase completed: "
+)
+
+// installState records the phases of a failed installation that completed on
+// each node, so that they are skipped when the installation is run again with
+// the same plan.
+type installState struct {
+	// PlanHash is the hash of the plan that was installed
+	PlanHash string `json:"planHash"`
+	// CompletedPhases are the phases that completed, keyed by host
+	CompletedPhases map[string][]string `json:"completedPhases"`
+}
+
+// readInstallState returns the state of the previous installation. It returns
+// nil when there is no state.
+func readInstallState(dir string) (*installState, error) {
+	file := filepath.Join(dir, installStateFilename)
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading install state from %s: %v", file, err)
+	}
+	s := &installState{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("error unmarshaling install state from %s: %v", file, err)
+	}
+	return s, nil
+}
+
+// write the state to the directory. The state is removed when no phases are
+// recorded.
+func (s installState) write(dir string) error {
+	file := filepath.Join(dir, installStateFilename)
+	if len(s.CompletedPhases) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing install state %s: %v", file, err)
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling install state: %v", err)
+	}
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing install state to %s: %v", file, err)
+	}
+	return nil
+}
+
+// record adds the phases that completed on each node
+func (s *installState) record(completed map[string][]string) {
+	if s.CompletedPhases == nil {
+		s.CompletedPhases = map[string][]string{}
+	}
+	for host, phases := range completed {
+		s.CompletedPhases[host] = uniqueStrings(append(s.CompletedPhases[host], phases...))
+	}
+}
+
+// clear removes the phases of the hosts. All the phases are removed when no
+// hosts are given.
+func (s *installState) clear(hosts ...string) {
+	if len(hosts) == 0 {
+		s.CompletedPhases = nil
+		return
+	}
+	for _, h := range hosts {
+		delete(s.CompletedPhases, h)
+	}
+}
+
+// skippedPhases returns a description of the phases that are skipped on each
+// node, sorted by host
+func (s installState) skippedPhases() []string {
+	desc := []string{}
+	for host, phases := range s.CompletedPhases {
+		desc = append(desc, fmt.Sprintf("%s: %s", host, strings.Join(phases, ", ")))
+	}
+	sort.Strings(desc)
+	return desc
+}
+
+// phaseRecorder records the phases that completed on each node, as reported
+// by the phase marker plays
+type phaseRecorder struct {
+	mu        sync.Mutex
+	completed map[string][]string
+}
+
+func newPhaseRecorder() *phaseRecorder {
+	return &phaseRecorder{completed: map[string][]string{}}
+}
+
+func (r *phaseRecorder) observe(e ansible.Event) {
+	event, ok := e.(*ansible.RunnerOKEvent)
+	if !ok || !strings.HasPrefix(event.Result.Message, phaseMarkerPrefix) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	phase := strings.TrimPrefix(event.Result.Message, phaseMarkerPrefix)
+	r.completed[event.Host] = append(r.completed[event.Host], phase)
+}
+
+func (r *phaseRecorder) finish() map[string][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.completed
+}
@@ -0,0 +1,172 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+// phaseRunner records the phases that are skipped by the playbook, and reports
+// the completion of the phases on the hosts before returning the error
+type phaseRunner struct {
+	completed map[string][]string
+	err       error
+	skipped   map[string][]string
+	sent      chan struct{}
+}
+
+func (r *phaseRunner) StartPlaybook(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog) (<-chan ansible.Event, error) {
+	return r.StartPlaybookOnNode(playbook, inv, cc)
+}
+
+func (r *phaseRunner) StartPlaybookOnNode(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog, nodes ...string) (<-chan ansible.Event, error) {
+	r.skipped = map[string][]string{}
+	for host, phases := range cc.CompletedPhases {
+		r.skipped[host] = append([]string{}, phases...)
+	}
+	events := make(chan ansible.Event)
+	r.sent = make(chan struct{})
+	go func() {
+		defer close(r.sent)
+		defer close(events)
+		for host, phases := range r.completed {
+			for _, phase := range phases {
+				e := &ansible.RunnerOKEvent{}
+				e.Host = host
+				e.Result.Message = phaseMarkerPrefix + phase
+				events <- e
+			}
+		}
+		events <- &ansible.PlaybookEndEvent{}
+	}()
+	return events, nil
+}
+
+func (r *phaseRunner) WaitPlaybook() error {
+	<-r.sent
+	return r.err
+}
+
+func (r *phaseRunner) Stop() error {
+	return nil
+}
+
+func TestInstallSkipsCompletedPhases(t *testing.T) {
+	assetsDir := mustGetTempDir(t)
+	plan := &Plan{
+		Cluster: Cluster{
+			Name:    "test",
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01"}},
+		},
+		Worker: NodeGroup{
+			Nodes: []Node{{Host: "worker01"}, {Host: "worker02"}},
+		},
+	}
+	newExecutor := func(r *phaseRunner, forceFull bool) *ansibleExecutor {
+		return &ansibleExecutor{
+			options: ExecutorOptions{
+				RunsDirectory:            mustGetTempDir(t),
+				GeneratedAssetsDirectory: assetsDir,
+				ForceFullInstall:         forceFull,
+			},
+			stdout:              &bytes.Buffer{},
+			consoleOutputFormat: ansible.RawFormat,
+			certsDir:            mustGetTempDir(t),
+			runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+				return r, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+			},
+		}
+	}
+
+	// The first installation fails after completing some of the phases
+	completed := map[string][]string{
+		"worker01": {"docker", "kubelet"},
+		"worker02": {"docker"},
+	}
+	r := &phaseRunner{completed: completed, err: errors.New("playbook failed")}
+	if _, err := newExecutor(r, false).Install(plan, false); err == nil {
+		t.Fatal("expected the installation to fail")
+	}
+	if len(r.skipped) != 0 {
+		t.Errorf("expected no phases to be skipped, but got %v", r.skipped)
+	}
+
+	// The completed phases are skipped when the installation is run again
+	r = &phaseRunner{completed: map[string][]string{"worker02": {"kubelet"}}, err: errors.New("playbook failed")}
+	if _, err := newExecutor(r, false).Install(plan, false); err == nil {
+		t.Fatal("expected the installation to fail")
+	}
+	if !reflect.DeepEqual(r.skipped, completed) {
+		t.Errorf("expected the completed phases %v to be skipped, but got %v", completed, r.skipped)
+	}
+
+	// A full installation does not skip any phases
+	r = &phaseRunner{}
+	if _, err := newExecutor(r, true).Install(plan, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(r.skipped) != 0 {
+		t.Errorf("expected no phases to be skipped, but got %v", r.skipped)
+	}
+	// The state is removed after a successful installation
+	if _, err := os.Stat(filepath.Join(assetsDir, installStateFilename)); !os.IsNotExist(err) {
+		t.Errorf("expected the install state to be removed, but got %v", err)
+	}
+}
+
+func TestInstallStateIsDiscardedWhenPlanChanges(t *testing.T) {
+	dir := mustGetTempDir(t)
+	plan := &Plan{Cluster: Cluster{Name: "test"}}
+	s := installState{PlanHash: planHash(plan)}
+	s.record(map[string][]string{"worker01": {"docker"}})
+	if err := s.write(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e := ansibleExecutor{options: ExecutorOptions{GeneratedAssetsDirectory: dir}}
+	state, err := e.installState(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(state.CompletedPhases) != 1 {
+		t.Errorf("expected the completed phases to be kept, but got %v", state.CompletedPhases)
+	}
+	plan.Cluster.Name = "changed"
+	state, err = e.installState(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(state.CompletedPhases) != 0 {
+		t.Errorf("expected the completed phases to be discarded, but got %v", state.CompletedPhases)
+	}
+}
+
+func TestInstallStateClearLimitedHosts(t *testing.T) {
+	s := installState{}
+	s.record(map[string][]string{"worker01": {"docker"}, "worker02": {"docker", "kubelet"}})
+	s.record(map[string][]string{"worker01": {"docker", "kubelet"}})
+	expected := map[string][]string{"worker01": {"docker", "kubelet"}, "worker02": {"docker", "kubelet"}}
+	if !reflect.DeepEqual(s.CompletedPhases, expected) {
+		t.Errorf("expected %v, but got %v", expected, s.CompletedPhases)
+	}
+	s.clear("worker01")
+	if _, ok := s.CompletedPhases["worker01"]; ok || len(s.CompletedPhases) != 1 {
+		t.Errorf("expected only the phases of worker01 to be cleared, but got %v", s.CompletedPhases)
+	}
+	s.clear()
+	if len(s.CompletedPhases) != 0 {
+		t.Errorf("expected all the phases to be cleared, but got %v", s.CompletedPhases)
+	}
+}
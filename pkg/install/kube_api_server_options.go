@@ -1,39 +1,28 @@
 package install
 
-import (
-	"fmt"
-	"strings"
-)
-
-var kubeAPIServerProtectedOptions = []string{
-	"advertise-address",
-	"apiserver-count",
-	"client-ca-file",
-	"cloud-provider",
-	"cloud-config",
-	"etcd-cafile",
-	"etcd-certfile",
-	"etcd-keyfile",
-	"etcd-servers",
-	"secure-port",
-	"service-account-key-file",
-	"service-cluster-ip-range",
-	"tls-cert-file",
-	"tls-private-key-file",
+// kubeAPIServerProtectedOptions are the options that cannot be overridden, and how each of them
+// is configured instead
+var kubeAPIServerProtectedOptions = map[string]string{
+	"advertise-address":        "it is set to the IP address of each node in the master section",
+	"apiserver-count":          "it is set to the number of nodes in the master section",
+	"client-ca-file":           managedByClusterCA,
+	"cloud-provider":           managedByCloudProvider,
+	"cloud-config":             managedByCloudConfig,
+	"etcd-cafile":              managedByClusterCA,
+	"etcd-certfile":            "it is set to the etcd client certificate that is generated by KET",
+	"etcd-keyfile":             "it is set to the etcd client certificate that is generated by KET",
+	"etcd-servers":             "it is set to the nodes in the etcd section",
+	"secure-port":              "the API server is always exposed on port 6443",
+	"service-account-key-file": managedByServiceAccountKey,
+	"service-cluster-ip-range": managedByServiceCIDR,
+	"tls-cert-file":            "it is set to the API server certificate that is generated by KET, set master.load_balanced_fqdn and master.load_balanced_short_name to add names to it",
+	"tls-private-key-file":     "it is set to the API server certificate that is generated by KET, set master.load_balanced_fqdn and master.load_balanced_short_name to add names to it",
 }
 
 func (options *APIServerOptions) validate() (bool, []error) {
 	v := newValidator()
-	overrides := make([]string, 0)
-	for _, protectedOption := range kubeAPIServerProtectedOptions {
-		_, found := options.Overrides[protectedOption]
-		if found {
-			overrides = append(overrides, protectedOption)
-		}
-	}
-
-	if len(overrides) > 0 {
-		v.addError(fmt.Errorf("Kube ApiServer Option(s) [%v] cannot be overridden", strings.Join(overrides, ", ")))
+	for _, err := range protectedOptionErrors("Kube ApiServer", options.Overrides, kubeAPIServerProtectedOptions) {
+		v.addError(err)
 	}
 
 	return v.valid()
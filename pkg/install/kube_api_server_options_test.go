@@ -3,7 +3,6 @@ package install
 import (
 	"fmt"
 	"reflect"
-	"strings"
 	"testing"
 )
 
@@ -60,7 +59,11 @@ func TestValidateKubeApiServerOptions(t *testing.T) {
 		ok, err := test.opts.validate()
 		assertEqual(t, ok, test.valid)
 		if !test.valid {
-			assertEqual(t, err, []error{fmt.Errorf("Kube ApiServer Option(s) [%v] cannot be overridden", strings.Join(test.protectedFields, ", "))})
+			var expected []error
+			for _, f := range test.protectedFields {
+				expected = append(expected, fmt.Errorf("Kube ApiServer option %q cannot be overridden, %s", f, kubeAPIServerProtectedOptions[f]))
+			}
+			assertEqual(t, err, expected)
 		}
 	}
 }
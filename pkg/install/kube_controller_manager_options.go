@@ -1,33 +1,22 @@
 package install
 
-import (
-	"fmt"
-	"strings"
-)
-
-var kubeControllerManagerProtectedOptions = []string{
-	"cloud-provider",
-	"cloud-config",
-	"cluster-cidr",
-	"cluster-name",
-	"kubeconfig",
-	"root-ca-file",
-	"service-account-private-key-file",
-	"service-cluster-ip-range",
+// kubeControllerManagerProtectedOptions are the options that cannot be overridden, and how each of them
+// is configured instead
+var kubeControllerManagerProtectedOptions = map[string]string{
+	"cloud-provider":                   managedByCloudProvider,
+	"cloud-config":                     managedByCloudConfig,
+	"cluster-cidr":                     managedByPodCIDR,
+	"cluster-name":                     "set cluster.name instead",
+	"kubeconfig":                       managedByComponentKubeconfig,
+	"root-ca-file":                     managedByClusterCA,
+	"service-account-private-key-file": managedByServiceAccountKey,
+	"service-cluster-ip-range":         managedByServiceCIDR,
 }
 
 func (options *KubeControllerManagerOptions) validate() (bool, []error) {
 	v := newValidator()
-	overrides := make([]string, 0)
-	for _, protectedOption := range kubeControllerManagerProtectedOptions {
-		_, found := options.Overrides[protectedOption]
-		if found {
-			overrides = append(overrides, protectedOption)
-		}
-	}
-
-	if len(overrides) > 0 {
-		v.addError(fmt.Errorf("Kube Controller Manager Option(s) [%v] cannot be overridden", strings.Join(overrides, ", ")))
+	for _, err := range protectedOptionErrors("Kube Controller Manager", options.Overrides, kubeControllerManagerProtectedOptions) {
+		v.addError(err)
 	}
 
 	return v.valid()
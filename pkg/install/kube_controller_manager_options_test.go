@@ -2,7 +2,6 @@ package install
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 )
 
@@ -59,7 +58,11 @@ func TestValidateKubeControllerManagerOptions(t *testing.T) {
 		ok, err := test.opts.validate()
 		assertEqual(t, ok, test.valid)
 		if !test.valid {
-			assertEqual(t, err, []error{fmt.Errorf("Kube Controller Manager Option(s) [%v] cannot be overridden", strings.Join(test.protectedFields, ", "))})
+			var expected []error
+			for _, f := range test.protectedFields {
+				expected = append(expected, fmt.Errorf("Kube Controller Manager option %q cannot be overridden, %s", f, kubeControllerManagerProtectedOptions[f]))
+			}
+			assertEqual(t, err, expected)
 		}
 	}
 }
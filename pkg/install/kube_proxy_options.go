@@ -1,27 +1,16 @@
 package install
 
-import (
-	"fmt"
-	"strings"
-)
-
-var kubeProxyProtectedOptions = []string{
-	"cluster-cidr",
-	"hostname-override",
+// kubeProxyProtectedOptions are the options that cannot be overridden, and how each of them
+// is configured instead
+var kubeProxyProtectedOptions = map[string]string{
+	"cluster-cidr":      managedByPodCIDR,
+	"hostname-override": managedByNodeHost,
 }
 
 func (options *KubeProxyOptions) validate() (bool, []error) {
 	v := newValidator()
-	overrides := make([]string, 0)
-	for _, protectedOption := range kubeProxyProtectedOptions {
-		_, found := options.Overrides[protectedOption]
-		if found {
-			overrides = append(overrides, protectedOption)
-		}
-	}
-
-	if len(overrides) > 0 {
-		v.addError(fmt.Errorf("Kube Proxy Option(s) [%v] cannot be overridden", strings.Join(overrides, ", ")))
+	for _, err := range protectedOptionErrors("Kube Proxy", options.Overrides, kubeProxyProtectedOptions) {
+		v.addError(err)
 	}
 
 	return v.valid()
@@ -2,7 +2,6 @@ package install
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 )
 
@@ -57,7 +56,11 @@ func TestValidateKubeProxyOptions(t *testing.T) {
 		ok, err := test.opts.validate()
 		assertEqual(t, ok, test.valid)
 		if !test.valid {
-			assertEqual(t, err, []error{fmt.Errorf("Kube Proxy Option(s) [%v] cannot be overridden", strings.Join(test.protectedFields, ", "))})
+			var expected []error
+			for _, f := range test.protectedFields {
+				expected = append(expected, fmt.Errorf("Kube Proxy option %q cannot be overridden, %s", f, kubeProxyProtectedOptions[f]))
+			}
+			assertEqual(t, err, expected)
 		}
 	}
 }
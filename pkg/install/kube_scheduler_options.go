@@ -1,26 +1,15 @@
 package install
 
-import (
-	"fmt"
-	"strings"
-)
-
-var kubeSchedulerProtectedOptions = []string{
-	"kubeconfig",
+// kubeSchedulerProtectedOptions are the options that cannot be overridden, and how each of them
+// is configured instead
+var kubeSchedulerProtectedOptions = map[string]string{
+	"kubeconfig": managedByComponentKubeconfig,
 }
 
 func (options *KubeSchedulerOptions) validate() (bool, []error) {
 	v := newValidator()
-	overrides := make([]string, 0)
-	for _, protectedOption := range kubeSchedulerProtectedOptions {
-		_, found := options.Overrides[protectedOption]
-		if found {
-			overrides = append(overrides, protectedOption)
-		}
-	}
-
-	if len(overrides) > 0 {
-		v.addError(fmt.Errorf("Kube Scheduler Option(s) [%v] cannot be overridden", strings.Join(overrides, ", ")))
+	for _, err := range protectedOptionErrors("Kube Scheduler", options.Overrides, kubeSchedulerProtectedOptions) {
+		v.addError(err)
 	}
 
 	return v.valid()
@@ -2,7 +2,6 @@ package install
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 )
 
@@ -48,7 +47,11 @@ func TestValidateKubeSchedulerOptions(t *testing.T) {
 		ok, err := test.opts.validate()
 		assertEqual(t, ok, test.valid)
 		if !test.valid {
-			assertEqual(t, err, []error{fmt.Errorf("Kube Scheduler Option(s) [%v] cannot be overridden", strings.Join(test.protectedFields, ", "))})
+			var expected []error
+			for _, f := range test.protectedFields {
+				expected = append(expected, fmt.Errorf("Kube Scheduler option %q cannot be overridden, %s", f, kubeSchedulerProtectedOptions[f]))
+			}
+			assertEqual(t, err, expected)
 		}
 	}
 }
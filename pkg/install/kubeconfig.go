@@ -51,7 +51,17 @@ users:
 
 // GenerateKubeconfig generate a kubeconfig file for a specific user
 func GenerateKubeconfig(p *Plan, generatedAssetsDir string) error {
-	user := "admin"
+	return writeCertKubeconfig(p, generatedAssetsDir, "admin", filepath.Join(generatedAssetsDir, kubeconfigFilename))
+}
+
+// GenerateUserKubeconfig generates a kubeconfig file that authenticates with
+// the certificate named after the user, and returns the path of the file
+func GenerateUserKubeconfig(p *Plan, generatedAssetsDir string, user string) (string, error) {
+	file := filepath.Join(generatedAssetsDir, user+"-"+kubeconfigFilename)
+	return file, writeCertKubeconfig(p, generatedAssetsDir, user, file)
+}
+
+func writeCertKubeconfig(p *Plan, generatedAssetsDir string, user string, file string) error {
 	server := "https://" + p.Master.LoadBalancedFQDN + ":6443"
 	cluster := p.Cluster.Name
 	context := p.Cluster.Name + "-" + user
@@ -76,7 +86,7 @@ func GenerateKubeconfig(p *Plan, generatedAssetsDir string) error {
 
 	configOptions := ConfigOptions{caEncoded, server, cluster, user, context, certEncoded, keyEncoded, ""}
 
-	return writeTemplate(configOptions, filepath.Join(generatedAssetsDir, kubeconfigFilename))
+	return writeTemplate(configOptions, file)
 }
 
 func GenerateDashboardAdminKubeconfig(base64token string, p *Plan, generatedAssetsDir string) error {
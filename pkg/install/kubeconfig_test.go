@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -104,3 +105,34 @@ func TestRegenerateKubeconfigSameIsNotBackedUp(t *testing.T) {
 		t.Error("did not find expected kubeconfig file")
 	}
 }
+
+func TestGenerateUserKubeconfig(t *testing.T) {
+	path := createTempDirForRegenerateKubeconfigTests(t)
+	defer os.RemoveAll(path)
+	for _, f := range []string{"monitoring.pem", "monitoring-key.pem"} {
+		if err := ioutil.WriteFile(filepath.Join(path, "keys", f), []byte(f), 0644); err != nil {
+			t.Fatalf("error setting up test: error creating file: %v", err)
+		}
+	}
+
+	p := &Plan{}
+	p.Cluster.Name = "test"
+	p.Master.LoadBalancedFQDN = "test"
+
+	file, err := GenerateUserKubeconfig(p, path, "monitoring")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if file != filepath.Join(path, "monitoring-kubeconfig") {
+		t.Errorf("unexpected kubeconfig file %q", file)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("error reading kubeconfig file: %v", err)
+	}
+	for _, s := range []string{"name: monitoring", "current-context: test-monitoring", "server: https://test:6443"} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("expected %q in the kubeconfig file:\n%s", s, b)
+		}
+	}
+}
@@ -2,40 +2,260 @@ package install
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/blang/semver"
 )
 
-var kubeletProtectedOptions = []string{
-	"cloud-provider",
-	"cloud-config",
-	"cluster-dns",
-	"container-runtime",
-	"cni-bin-dir",
-	"cni-conf-dir",
-	"network-plugin",
-	"docker",
-	"hostname-override",
-	"kubeconfig",
-	"node-labels",
-	"node-ip",
-	"pod-manifest-path",
-	"tls-cert-file",
-	"tls-private-key-file",
+// kubeletProtectedOptions are the options that cannot be overridden, and how each of them
+// is configured instead
+var kubeletProtectedOptions = map[string]string{
+	"cloud-provider":       managedByCloudProvider,
+	"cloud-config":         managedByCloudConfig,
+	"cluster-dns":          "it is set to the DNS service of the cluster, configured with add_ons.dns",
+	"container-runtime":    "the container runtime is installed by KET, and configured with the docker section",
+	"cni-bin-dir":          "the pod network is configured with add_ons.cni",
+	"cni-conf-dir":         "the pod network is configured with add_ons.cni",
+	"network-plugin":       "the pod network is configured with add_ons.cni",
+	"docker":               "the Docker daemon is configured with the docker section",
+	"hostname-override":    managedByNodeHost,
+	"kubeconfig":           managedByComponentKubeconfig,
+	"node-labels":          "set the labels of the node instead",
+	"node-ip":              "it is set to the internalip of the node, or to its ip when the internalip is not set",
+	"pod-manifest-path":    "it is set to the directory of the static pod manifests that are managed by KET",
+	"tls-cert-file":        "it is set to the kubelet certificate that is generated by KET for each node",
+	"tls-private-key-file": "it is set to the kubelet certificate that is generated by KET for each node",
 }
 
 func (options *KubeletOptions) validate() (bool, []error) {
 	v := newValidator()
-	overrides := make([]string, 0)
-	for _, protectedOption := range kubeletProtectedOptions {
-		_, found := options.Overrides[protectedOption]
-		if found {
-			overrides = append(overrides, protectedOption)
-		}
-	}
-
-	if len(overrides) > 0 {
-		v.addError(fmt.Errorf("Kubelet Option(s) [%v] cannot be overridden", strings.Join(overrides, ", ")))
+	for _, err := range protectedOptionErrors("Kubelet", options.Overrides, kubeletProtectedOptions) {
+		v.addError(err)
 	}
 
 	return v.valid()
 }
+
+// kubeletFlag is a flag of the kubelet, and the minor versions of Kubernetes
+// in which it was added and removed. A version of zero is unbounded.
+type kubeletFlag struct {
+	addedIn   uint64
+	removedIn uint64
+}
+
+// kubeletFlags are the flags of the kubelet that can be set with the kubelet
+// options of the plan
+var kubeletFlags = map[string]kubeletFlag{
+	"address":                                      {},
+	"allow-privileged":                             {},
+	"allowed-unsafe-sysctls":                       {addedIn: 11},
+	"alsologtostderr":                              {},
+	"anonymous-auth":                               {},
+	"api-servers":                                  {removedIn: 8},
+	"application-metrics-count-limit":              {},
+	"authentication-token-webhook":                 {},
+	"authentication-token-webhook-cache-ttl":       {},
+	"authorization-mode":                           {},
+	"authorization-webhook-cache-authorized-ttl":   {},
+	"authorization-webhook-cache-unauthorized-ttl": {},
+	"azure-container-registry-config":              {},
+	"boot-id-file":                                 {},
+	"bootstrap-checkpoint-path":                    {addedIn: 10},
+	"bootstrap-kubeconfig":                         {},
+	"cadvisor-port":                                {removedIn: 12},
+	"cert-dir":                                     {},
+	"cgroup-driver":                                {},
+	"cgroup-root":                                  {},
+	"cgroups-per-qos":                              {},
+	"chaos-chance":                                 {},
+	"client-ca-file":                               {},
+	"cloud-config":                                 {},
+	"cloud-provider":                               {},
+	"cloud-provider-gce-lb-src-cidrs":              {},
+	"cluster-dns":                                  {},
+	"cluster-domain":                               {},
+	"cni-bin-dir":                                  {},
+	"cni-conf-dir":                                 {},
+	"config":                                       {addedIn: 10},
+	"container-hints":                              {},
+	"container-runtime":                            {},
+	"container-runtime-endpoint":                   {},
+	"containerd":                                   {},
+	"containerized":                                {},
+	"contention-profiling":                         {},
+	"cpu-cfs-quota":                                {},
+	"cpu-cfs-quota-period":                         {addedIn: 12},
+	"cpu-manager-policy":                           {addedIn: 8},
+	"cpu-manager-reconcile-period":                 {addedIn: 8},
+	"docker":                                       {},
+	"docker-disable-shared-pid":                    {},
+	"docker-endpoint":                              {},
+	"docker-env-metadata-whitelist":                {},
+	"docker-only":                                  {},
+	"docker-root":                                  {},
+	"docker-tls":                                   {},
+	"docker-tls-ca":                                {},
+	"docker-tls-cert":                              {},
+	"docker-tls-key":                               {},
+	"dynamic-config-dir":                           {addedIn: 10},
+	"enable-controller-attach-detach":              {},
+	"enable-custom-metrics":                        {removedIn: 10},
+	"enable-debugging-handlers":                    {},
+	"enable-load-reader":                           {},
+	"enable-server":                                {},
+	"enforce-node-allocatable":                     {},
+	"event-burst":                                  {},
+	"event-qps":                                    {},
+	"event-storage-age-limit":                      {},
+	"event-storage-event-limit":                    {},
+	"eviction-hard":                                {},
+	"eviction-max-pod-grace-period":                {},
+	"eviction-minimum-reclaim":                     {},
+	"eviction-pressure-transition-period":          {},
+	"eviction-soft":                                {},
+	"eviction-soft-grace-period":                   {},
+	"exit-on-lock-contention":                      {},
+	"experimental-allocatable-ignore-eviction":     {},
+	"experimental-allowed-unsafe-sysctls":          {removedIn: 11},
+	"experimental-bootstrap-kubeconfig":            {},
+	"experimental-check-node-capabilities-before-mount": {},
+	"experimental-fail-swap-on":                         {removedIn: 10},
+	"experimental-kernel-memcg-notification":            {},
+	"experimental-mounter-path":                         {},
+	"experimental-qos-reserved":                         {},
+	"fail-swap-on":                                      {addedIn: 8},
+	"feature-gates":                                     {},
+	"file-check-frequency":                              {},
+	"global-housekeeping-interval":                      {},
+	"google-json-key":                                   {},
+	"hairpin-mode":                                      {},
+	"healthz-bind-address":                              {},
+	"healthz-port":                                      {},
+	"host-ipc-sources":                                  {},
+	"host-network-sources":                              {},
+	"host-pid-sources":                                  {},
+	"hostname-override":                                 {},
+	"housekeeping-interval":                             {},
+	"http-check-frequency":                              {},
+	"image-gc-high-threshold":                           {},
+	"image-gc-low-threshold":                            {},
+	"image-pull-progress-deadline":                      {},
+	"image-service-endpoint":                            {},
+	"init-config-dir":                                   {removedIn: 10},
+	"iptables-drop-bit":                                 {},
+	"iptables-masquerade-bit":                           {},
+	"keep-terminated-pod-volumes":                       {},
+	"kube-api-burst":                                    {},
+	"kube-api-content-type":                             {},
+	"kube-api-qps":                                      {},
+	"kube-reserved":                                     {},
+	"kube-reserved-cgroup":                              {},
+	"kubeconfig":                                        {},
+	"kubelet-cgroups":                                   {},
+	"lock-file":                                         {},
+	"log-backtrace-at":                                  {},
+	"log-cadvisor-usage":                                {},
+	"log-dir":                                           {},
+	"log-file":                                          {addedIn: 13},
+	"log-flush-frequency":                               {},
+	"logtostderr":                                       {},
+	"machine-id-file":                                   {},
+	"make-iptables-util-chains":                         {},
+	"manifest-url":                                      {},
+	"manifest-url-header":                               {},
+	"master-service-namespace":                          {},
+	"max-open-files":                                    {},
+	"max-pods":                                          {},
+	"maximum-dead-containers":                           {},
+	"maximum-dead-containers-per-container":             {},
+	"minimum-container-ttl-duration":                    {},
+	"minimum-image-ttl-duration":                        {},
+	"network-plugin":                                    {},
+	"network-plugin-mtu":                                {},
+	"node-ip":                                           {},
+	"node-labels":                                       {},
+	"node-status-max-images":                            {addedIn: 12},
+	"node-status-update-frequency":                      {},
+	"non-masquerade-cidr":                               {},
+	"oom-score-adj":                                     {},
+	"pod-cidr":                                          {},
+	"pod-infra-container-image":                         {},
+	"pod-manifest-path":                                 {},
+	"pod-max-pids":                                      {addedIn: 10},
+	"pods-per-core":                                     {},
+	"port":                                              {},
+	"protect-kernel-defaults":                           {},
+	"provider-id":                                       {},
+	"read-only-port":                                    {},
+	"really-crash-for-testing":                          {},
+	"redirect-container-streaming":                      {addedIn: 11},
+	"register-node":                                     {},
+	"register-schedulable":                              {},
+	"register-with-taints":                              {},
+	"registry-burst":                                    {},
+	"registry-qps":                                      {},
+	"require-kubeconfig":                                {removedIn: 10},
+	"resolv-conf":                                       {},
+	"rkt-api-endpoint":                                  {},
+	"rkt-path":                                          {},
+	"rkt-stage1-image":                                  {},
+	"root-dir":                                          {},
+	"rotate-certificates":                               {},
+	"rotate-server-certificates":                        {},
+	"runonce":                                           {},
+	"runtime-cgroups":                                   {},
+	"runtime-request-timeout":                           {},
+	"seccomp-profile-root":                              {},
+	"serialize-image-pulls":                             {},
+	"stderrthreshold":                                   {},
+	"storage-driver-buffer-duration":                    {},
+	"storage-driver-db":                                 {},
+	"storage-driver-host":                               {},
+	"storage-driver-password":                           {},
+	"storage-driver-secure":                             {},
+	"storage-driver-table":                              {},
+	"storage-driver-user":                               {},
+	"streaming-connection-idle-timeout":                 {},
+	"sync-frequency":                                    {},
+	"system-cgroups":                                    {},
+	"system-reserved":                                   {},
+	"system-reserved-cgroup":                            {},
+	"tls-cert-file":                                     {},
+	"tls-cipher-suites":                                 {},
+	"tls-min-version":                                   {},
+	"tls-private-key-file":                              {},
+	"v":                                                 {},
+	"vmodule":                                           {},
+	"volume-plugin-dir":                                 {},
+	"volume-stats-agg-period":                           {},
+}
+
+// validateKubeletFlags returns an error for each option that is not a flag of
+// the kubelet of the Kubernetes version, so that the plan fails validation
+// instead of the kubelet failing to start on every node
+func validateKubeletFlags(prefix string, overrides map[string]string, version semver.Version) []error {
+	var names []string
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		// Protected options are reported by protectedOptionErrors, for the
+		// options of the cluster and for those of each node
+		if _, protected := kubeletProtectedOptions[strings.TrimLeft(name, "-")]; protected {
+			continue
+		}
+		flag, ok := kubeletFlags[name]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("%s option %q is not a flag of the kubelet", prefix, name))
+		case flag.addedIn != 0 && version.Minor < flag.addedIn:
+			errs = append(errs, fmt.Errorf("%s option %q requires Kubernetes v%d.%d or later, the cluster version is v%s", prefix, name, version.Major, flag.addedIn, version))
+		case flag.removedIn != 0 && version.Minor >= flag.removedIn:
+			errs = append(errs, fmt.Errorf("%s option %q was removed from the kubelet in Kubernetes v%d.%d, the cluster version is v%s", prefix, name, version.Major, flag.removedIn, version))
+		}
+	}
+	return errs
+}
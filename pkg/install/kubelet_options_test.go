@@ -0,0 +1,84 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+)
+
+func TestValidateKubeletFlags(t *testing.T) {
+	tests := []struct {
+		overrides map[string]string
+		version   string
+		errs      []error
+	}{
+		{
+			overrides: map[string]string{"max-pods": "200", "eviction-hard": "memory.available<100Mi"},
+			version:   "1.10.3",
+		},
+		{
+			overrides: map[string]string{"pod-max-pids": "1000", "max-pod": "200"},
+			version:   "1.10.3",
+			errs:      []error{fmt.Errorf("Kubelet option %q is not a flag of the kubelet", "max-pod")},
+		},
+		{
+			overrides: map[string]string{"pod-max-pids": "1000"},
+			version:   "1.9.7",
+			errs:      []error{fmt.Errorf("Kubelet option %q requires Kubernetes v1.10 or later, the cluster version is v1.9.7", "pod-max-pids")},
+		},
+		{
+			overrides: map[string]string{"require-kubeconfig": "true", "cadvisor-port": "0"},
+			version:   "1.10.3",
+			errs:      []error{fmt.Errorf("Kubelet option %q was removed from the kubelet in Kubernetes v1.10, the cluster version is v1.10.3", "require-kubeconfig")},
+		},
+		{
+			overrides: map[string]string{"--node-ip": "10.0.0.1", "node-labels": "foo=bar"},
+			version:   "1.10.3",
+		},
+	}
+	for _, test := range tests {
+		errs := validateKubeletFlags("Kubelet", test.overrides, semver.MustParse(test.version))
+		assertEqual(t, errs, test.errs)
+	}
+}
+
+func TestValidatePlanKubeletFlags(t *testing.T) {
+	p := validPlan()
+	p.Cluster.KubeletOptions.Overrides = map[string]string{"max-pods": "200"}
+	p.Worker.Nodes[0].KubeletOptions.Overrides = map[string]string{"unknown-flag": "true"}
+	ok, errs := ValidatePlan(&p)
+	if ok {
+		t.Fatalf("expected the unknown flag of the node to be invalid")
+	}
+	expected := fmt.Sprintf("Node %q kubelet option %q is not a flag of the kubelet", p.Worker.Nodes[0].Host, "unknown-flag")
+	found := false
+	for _, err := range errs {
+		if err.Error() == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the error %q, got %v", expected, errs)
+	}
+}
+
+func TestValidatePlanNodeKubeletProtectedOptions(t *testing.T) {
+	p := validPlan()
+	p.Worker.Nodes[0].KubeletOptions.Overrides = map[string]string{"tls-cert-file": "/tmp/cert.pem", "max-pods": "200"}
+	ok, errs := ValidatePlan(&p)
+	if ok {
+		t.Fatalf("expected the protected option of the node to be invalid")
+	}
+	prefix := fmt.Sprintf("Node %q kubelet option %q cannot be overridden", p.Worker.Nodes[0].Host, "tls-cert-file")
+	found := false
+	for _, err := range errs {
+		if strings.HasPrefix(err.Error(), prefix) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected an error starting with %q, got %v", prefix, errs)
+	}
+}
@@ -0,0 +1,103 @@
+package install
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResolveLimit returns the hosts of the nodes that match the limit
+// expressions. An expression is a host name, a role (etcd, master, worker,
+// ingress or storage), or a label selector of the form key=value or
+// key!=value, which is matched against the labels of the node in the plan.
+// An expression prefixed with ! excludes the nodes it matches, as in the
+// host patterns of ansible. When the limit only has exclusions, they are
+// excluded from all the nodes.
+// The hosts are returned once, in the order of the plan. An empty limit
+// resolves to an empty list, which runs the operation on all the nodes.
+func (p *Plan) ResolveLimit(limit []string) ([]string, error) {
+	if len(limit) == 0 {
+		return nil, nil
+	}
+	selected := map[string]bool{}
+	excluded := map[string]bool{}
+	onlyExclusions := true
+	for _, expr := range limit {
+		expr = strings.TrimSpace(expr)
+		matches := selected
+		if strings.HasPrefix(expr, limitExclusionPrefix) {
+			expr = strings.TrimSpace(strings.TrimPrefix(expr, limitExclusionPrefix))
+			matches = excluded
+		} else {
+			onlyExclusions = false
+		}
+		matched := false
+		for _, n := range p.GetUniqueNodes() {
+			if p.matchesLimit(n, expr) {
+				matches[n.Host] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%q in the limit does not match any host, role or label of the nodes in the plan file", expr)
+		}
+	}
+	var hosts []string
+	for _, n := range p.GetUniqueNodes() {
+		if (onlyExclusions || selected[n.Host]) && !excluded[n.Host] && !contains(n.Host, hosts) {
+			hosts = append(hosts, n.Host)
+		}
+	}
+	// an empty list would run the operation on all the nodes
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("the limit %v excludes all the nodes in the plan file", limit)
+	}
+	return hosts, nil
+}
+
+const limitExclusionPrefix = "!"
+
+// ExcludeFromLimit returns the limit expressions that exclude the nodes
+// matched by the given expressions, to be appended to a limit
+func ExcludeFromLimit(exprs ...string) []string {
+	var limit []string
+	for _, e := range exprs {
+		limit = append(limit, limitExclusionPrefix+strings.TrimSpace(e))
+	}
+	return limit
+}
+
+// matchesLimit returns true if the node matches the limit expression. Host
+// names take precedence over roles, so that a node named after a role can
+// still be selected on its own.
+func (p *Plan) matchesLimit(n Node, expr string) bool {
+	if p.HostExists(expr) {
+		return n.Host == expr
+	}
+	if p.ValidRole(expr) {
+		return contains(expr, p.GetRolesForIP(n.IP))
+	}
+	if i := strings.Index(expr, "!="); i > 0 {
+		v, ok := p.nodeLabels(n)[expr[:i]]
+		return !ok || v != expr[i+2:]
+	}
+	if i := strings.Index(expr, "="); i > 0 {
+		v, ok := p.nodeLabels(n)[expr[:i]]
+		return ok && v == expr[i+1:]
+	}
+	return false
+}
+
+// nodeLabels returns the labels of the node, merged across the roles of the
+// node in the same order as the labels applied to the cluster
+func (p *Plan) nodeLabels(n Node) map[string]string {
+	labels := map[string]string{}
+	for _, other := range p.getAllNodes() {
+		if other.IP != n.IP {
+			continue
+		}
+		for k, v := range other.Labels {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -0,0 +1,120 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func limitTestPlan() *Plan {
+	p := &Plan{}
+	p.Etcd.Nodes = []Node{{Host: "etcd1", IP: "10.0.0.1"}}
+	p.Master.Nodes = []Node{{Host: "master1", IP: "10.0.0.2"}}
+	p.Worker.Nodes = []Node{
+		{Host: "worker1", IP: "10.0.0.3", Labels: map[string]string{"zone": "a"}},
+		{Host: "worker2", IP: "10.0.0.4", Labels: map[string]string{"zone": "b"}},
+		{Host: "worker", IP: "10.0.0.5"},
+	}
+	p.Ingress.Nodes = []Node{{Host: "worker2", IP: "10.0.0.4", Labels: map[string]string{"ingress": "true"}}}
+	return p
+}
+
+func TestResolveLimit(t *testing.T) {
+	tests := []struct {
+		limit    []string
+		expected []string
+		valid    bool
+	}{
+		{
+			limit: []string{},
+			valid: true,
+		},
+		{
+			limit:    []string{"master1", "etcd1"},
+			expected: []string{"etcd1", "master1"},
+			valid:    true,
+		},
+		{
+			limit:    []string{"etcd", "ingress"},
+			expected: []string{"etcd1", "worker2"},
+			valid:    true,
+		},
+		{
+			// the host name takes precedence over the role
+			limit:    []string{"worker"},
+			expected: []string{"worker"},
+			valid:    true,
+		},
+		{
+			limit:    []string{"zone=a"},
+			expected: []string{"worker1"},
+			valid:    true,
+		},
+		{
+			// the labels are merged across the roles of the node
+			limit:    []string{"ingress=true", "worker1"},
+			expected: []string{"worker1", "worker2"},
+			valid:    true,
+		},
+		{
+			limit:    []string{"zone!=a"},
+			expected: []string{"etcd1", "master1", "worker2", "worker"},
+			valid:    true,
+		},
+		{
+			limit:    []string{"!worker1", "!master"},
+			expected: []string{"etcd1", "worker2", "worker"},
+			valid:    true,
+		},
+		{
+			limit:    []string{"worker1", "worker2", "!ingress"},
+			expected: []string{"worker1"},
+			valid:    true,
+		},
+		{
+			limit:    []string{"! zone=a"},
+			expected: []string{"etcd1", "master1", "worker2", "worker"},
+			valid:    true,
+		},
+		{
+			limit: []string{"worker1", "!zone=a"},
+		},
+		{
+			limit: []string{"!worker3"},
+		},
+		{
+			limit: []string{"worker3"},
+		},
+		{
+			limit: []string{"zone=c"},
+		},
+	}
+	for _, test := range tests {
+		hosts, err := limitTestPlan().ResolveLimit(test.limit)
+		if err != nil && test.valid {
+			t.Errorf("limit %v: unexpected error: %v", test.limit, err)
+			continue
+		}
+		if err == nil && !test.valid {
+			t.Errorf("limit %v: expected an error, but did not get one", test.limit)
+			continue
+		}
+		if !reflect.DeepEqual(hosts, test.expected) {
+			t.Errorf("limit %v: expected %v, got %v", test.limit, test.expected, hosts)
+		}
+	}
+}
+
+func TestExcludeFromLimit(t *testing.T) {
+	limit := append([]string{"worker"}, ExcludeFromLimit("worker1", " zone=b")...)
+	expected := []string{"worker", "!worker1", "!zone=b"}
+	if !reflect.DeepEqual(limit, expected) {
+		t.Errorf("expected %v, got %v", expected, limit)
+	}
+	hosts, err := limitTestPlan().ResolveLimit(limit)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(hosts, []string{"worker"}) {
+		t.Errorf("expected [worker], got %v", hosts)
+	}
+}
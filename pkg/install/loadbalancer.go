@@ -0,0 +1,136 @@
+package install
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+const apiServerPort = 6443
+
+// ValidateLoadBalancedFQDN verifies that the load balanced FQDN of the master
+// nodes can be resolved on every node of the cluster. The validation is
+// skipped when the FQDN is an IP address.
+func ValidateLoadBalancedFQDN(p *Plan) (bool, []error) {
+	return validateLoadBalancedFQDN(p, (*Plan).GetSSHClient)
+}
+
+func validateLoadBalancedFQDN(p *Plan, sshClient func(p *Plan, host string) (ssh.Client, error)) (bool, []error) {
+	v := newValidator()
+	fqdn := p.Master.LoadBalancedFQDN
+	if net.ParseIP(fqdn) != nil {
+		return v.valid()
+	}
+	nodes := p.GetUniqueNodes()
+	errQueue := make(chan error, len(nodes))
+	var wg sync.WaitGroup
+	wg.Add(len(nodes))
+	for _, node := range nodes {
+		go func(host string) {
+			defer wg.Done()
+			client, err := sshClient(p, host)
+			if err != nil {
+				errQueue <- fmt.Errorf("error getting SSH client for %q: %v", host, err)
+				return
+			}
+			// getent uses the same resolution order as the cluster components
+			out, err := client.Output(true, "getent hosts "+fqdn)
+			if err != nil || strings.TrimSpace(out) == "" {
+				errQueue <- fmt.Errorf("load balanced FQDN %q cannot be resolved on node %q", fqdn, host)
+				return
+			}
+			errQueue <- nil
+		}(node.Host)
+	}
+	wg.Wait()
+	close(errQueue)
+	for err := range errQueue {
+		if err != nil {
+			v.addError(err)
+		}
+	}
+	return v.valid()
+}
+
+// loadBalancerCheck verifies that the load balanced FQDN routes to the API
+// servers running on the master nodes, and that the certificate they serve is
+// signed by the cluster CA and valid for the FQDN.
+type loadBalancerCheck struct {
+	// Attempts is the number of connections made through the load balancer
+	Attempts int
+	// Timeout of each connection
+	Timeout time.Duration
+
+	// Hook for testing purposes, returns the address dialed for the FQDN
+	address func(fqdn string) string
+}
+
+// Run returns an error if any connection through the load balancer fails, or
+// does not reach a master node. The hosts of the master nodes that were never
+// reached are returned, as load balancers can pin the installer to a subset of
+// the masters.
+func (c loadBalancerCheck) Run(p *Plan, caCert []byte) ([]string, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("error parsing the cluster CA certificate")
+	}
+	fqdn := p.Master.LoadBalancedFQDN
+	addr := net.JoinHostPort(fqdn, strconv.Itoa(apiServerPort))
+	if c.address != nil {
+		addr = c.address(fqdn)
+	}
+	config := &tls.Config{
+		RootCAs:    pool,
+		ServerName: fqdn,
+	}
+	attempts := c.Attempts
+	if attempts == 0 {
+		attempts = 2 * len(p.Master.Nodes)
+	}
+	reached := map[string]bool{}
+	for i := 0; i < attempts; i++ {
+		host, err := c.dial(addr, config, p.Master.Nodes)
+		if err != nil {
+			return nil, err
+		}
+		reached[host] = true
+	}
+	unreached := []string{}
+	for _, n := range p.Master.Nodes {
+		if !reached[n.Host] {
+			unreached = append(unreached, n.Host)
+		}
+	}
+	if len(unreached) == len(p.Master.Nodes) {
+		return nil, fmt.Errorf("load balancer %q did not route to any of the master nodes", addr)
+	}
+	return unreached, nil
+}
+
+// dial connects to the address and returns the host of the master node that
+// served the connection. The API server certificate of each master uses the
+// host of the master as its common name.
+func (c loadBalancerCheck) dial(addr string, config *tls.Config, masters []Node) (string, error) {
+	dialer := &net.Dialer{Timeout: c.Timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, config)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to %q: %v", addr, err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", fmt.Errorf("%q did not serve a certificate", addr)
+	}
+	cn := certs[0].Subject.CommonName
+	if !nodeInList(cn, masters) {
+		return "", fmt.Errorf("%q served a certificate for %q, which is not a master node", addr, cn)
+	}
+	return cn, nil
+}
@@ -0,0 +1,182 @@
+package install
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+	kisTLS "github.com/apprenda/kismatic/pkg/tls"
+	"github.com/cloudflare/cfssl/csr"
+)
+
+type fakeResolver struct {
+	resolves bool
+}
+
+func (r fakeResolver) Output(pty bool, args ...string) (string, error) {
+	if !r.resolves {
+		return "", errors.New("exit status 2")
+	}
+	return "10.0.0.10       lb.example.com\n", nil
+}
+
+func (r fakeResolver) Shell(pty bool, args ...string) error { return nil }
+
+func loadBalancerTestPlan(fqdn string) *Plan {
+	return &Plan{
+		Etcd:   NodeGroup{Nodes: []Node{{Host: "etcd1", IP: "10.0.0.1"}}},
+		Master: MasterNodeGroup{LoadBalancedFQDN: fqdn, Nodes: []Node{{Host: "master1", IP: "10.0.0.2"}, {Host: "master2", IP: "10.0.0.3"}}},
+		Worker: NodeGroup{Nodes: []Node{{Host: "worker1", IP: "10.0.0.4"}}},
+	}
+}
+
+func TestValidateLoadBalancedFQDN(t *testing.T) {
+	tests := []struct {
+		fqdn       string
+		unresolved string
+		valid      bool
+	}{
+		{fqdn: "lb.example.com", valid: true},
+		{fqdn: "lb.example.com", unresolved: "worker1", valid: false},
+		{fqdn: "10.0.0.10", unresolved: "worker1", valid: true},
+	}
+	for i, test := range tests {
+		var mu sync.Mutex
+		var connected []string
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			mu.Lock()
+			connected = append(connected, host)
+			mu.Unlock()
+			return fakeResolver{resolves: host != test.unresolved}, nil
+		}
+		ok, errs := validateLoadBalancedFQDN(loadBalancerTestPlan(test.fqdn), sshClient)
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+		if !ok && !strings.Contains(errs[0].Error(), test.unresolved) {
+			t.Errorf("test %d: expected the error to reference %q, got %v", i, test.unresolved, errs[0])
+		}
+		if net.ParseIP(test.fqdn) != nil && len(connected) != 0 {
+			t.Errorf("test %d: expected no nodes to be checked when the FQDN is an IP", i)
+		}
+	}
+}
+
+// startAPIServers starts a TLS listener that serves the certificates of the
+// given masters in turn, similar to a round-robin load balancer
+func startAPIServers(t *testing.T, ca *kisTLS.CA, fqdn string, masters ...string) net.Listener {
+	certs := []tls.Certificate{}
+	for _, m := range masters {
+		req := csr.CertificateRequest{
+			CN:         m,
+			KeyRequest: &csr.BasicKeyRequest{A: "rsa", S: 2048},
+			Hosts:      []string{fqdn, m},
+		}
+		key, cert, err := kisTLS.NewCert(ca, req, time.Hour)
+		if err != nil {
+			t.Fatalf("error generating certificate: %v", err)
+		}
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			t.Fatalf("error loading certificate: %v", err)
+		}
+		certs = append(certs, pair)
+	}
+	var mu sync.Mutex
+	next := 0
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c := certs[next%len(certs)]
+			next++
+			return &c, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	return l
+}
+
+func newTestCA(t *testing.T) *kisTLS.CA {
+	key, cert, err := kisTLS.NewCACert("test/ca-csr.json", "kismatic", "1h")
+	if err != nil {
+		t.Fatalf("error generating CA: %v", err)
+	}
+	return &kisTLS.CA{Key: key, Cert: cert}
+}
+
+func TestLoadBalancerCheck(t *testing.T) {
+	ca := newTestCA(t)
+	tests := []struct {
+		servedBy  []string
+		unreached []string
+		valid     bool
+	}{
+		{servedBy: []string{"master1", "master2"}, unreached: []string{}, valid: true},
+		{servedBy: []string{"master1"}, unreached: []string{"master2"}, valid: true},
+		{servedBy: []string{"master1", "worker1"}, valid: false},
+		{servedBy: []string{"worker1"}, valid: false},
+	}
+	for i, test := range tests {
+		l := startAPIServers(t, ca, "lb.example.com", test.servedBy...)
+		c := loadBalancerCheck{
+			Timeout: time.Second,
+			address: func(string) string { return l.Addr().String() },
+		}
+		unreached, err := c.Run(loadBalancerTestPlan("lb.example.com"), ca.Cert)
+		l.Close()
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid to be %v, got error: %v", i, test.valid, err)
+			continue
+		}
+		if test.valid && !reflect.DeepEqual(unreached, test.unreached) {
+			t.Errorf("test %d: expected unreached masters %v, got %v", i, test.unreached, unreached)
+		}
+	}
+}
+
+func TestLoadBalancerCheckVerifiesCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	tests := []struct {
+		certFQDN string
+		caCert   []byte
+	}{
+		// certificate is not valid for the load balanced FQDN
+		{certFQDN: "other.example.com", caCert: ca.Cert},
+		// certificate is not signed by the cluster CA
+		{certFQDN: "lb.example.com", caCert: otherCA.Cert},
+	}
+	for i, test := range tests {
+		l := startAPIServers(t, ca, test.certFQDN, "master1", "master2")
+		c := loadBalancerCheck{
+			Timeout: time.Second,
+			address: func(string) string { return l.Addr().String() },
+		}
+		_, err := c.Run(loadBalancerTestPlan("lb.example.com"), test.caCert)
+		l.Close()
+		if err == nil {
+			t.Errorf("test %d: expected an error", i)
+		}
+	}
+}
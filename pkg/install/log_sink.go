@@ -0,0 +1,136 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+)
+
+const (
+	// journaldLogSink is the log sink that sends the ansible log to the
+	// journal of the local machine
+	journaldLogSink = "journald"
+	// journaldSocket is the socket of the native protocol of journald
+	journaldSocket = "/run/systemd/journal/socket"
+	// logSinkIdentifier identifies the messages of the log sink in the
+	// collector
+	logSinkIdentifier = "kismatic"
+)
+
+// logSink mirrors the ansible log of the runs to a log collector
+type logSink interface {
+	// send sends a line of the ansible log of the task
+	send(task, runDirectory, line string) error
+}
+
+// executorLogSink returns the log sink of the options, or nil when the ansible
+// log is not mirrored
+func executorLogSink(options ExecutorOptions) (logSink, error) {
+	if options.LogSink == "" {
+		return nil, nil
+	}
+	return openLogSink(options.LogSink)
+}
+
+// openLogSink connects to the log sink, which is either journald, or the
+// address of a syslog server, such as udp://logs.example.com:514,
+// tcp://logs.example.com:601 or unix:///dev/log
+func openLogSink(sink string) (logSink, error) {
+	if sink == journaldLogSink {
+		return openJournaldSink(journaldSocket)
+	}
+	u, err := url.Parse(sink)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log sink %q: %v", sink, err)
+	}
+	var addr string
+	switch u.Scheme {
+	case "udp", "tcp":
+		addr = u.Host
+	case "unix", "unixgram":
+		addr = u.Path
+	default:
+		return nil, fmt.Errorf("invalid log sink %q, it must be %q or the address of a syslog server, such as udp://logs.example.com:514", sink, journaldLogSink)
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("invalid log sink %q, the address of the syslog server is missing", sink)
+	}
+	w, err := syslog.Dial(u.Scheme, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, logSinkIdentifier)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to syslog server %q: %v", sink, err)
+	}
+	return syslogSink{w: w}, nil
+}
+
+// syslogSink sends the lines to a syslog server, prefixed with the task
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func (s syslogSink) send(task, runDirectory, line string) error {
+	return s.w.Info(fmt.Sprintf("[%s] %s", task, line))
+}
+
+// journaldSink sends the lines to journald with its native protocol, with
+// the task and the run directory as fields of each entry
+type journaldSink struct {
+	conn net.Conn
+}
+
+func openJournaldSink(socket string) (logSink, error) {
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to journald: %v", err)
+	}
+	return journaldSink{conn: conn}, nil
+}
+
+func (s journaldSink) send(task, runDirectory, line string) error {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "MESSAGE=%s\n", line)
+	fmt.Fprintf(b, "PRIORITY=%d\n", syslog.LOG_INFO)
+	fmt.Fprintf(b, "SYSLOG_IDENTIFIER=%s\n", logSinkIdentifier)
+	fmt.Fprintf(b, "KISMATIC_TASK=%s\n", task)
+	fmt.Fprintf(b, "KISMATIC_RUN_DIRECTORY=%s\n", runDirectory)
+	_, err := s.conn.Write(b.Bytes())
+	return err
+}
+
+// logSinkWriter sends each line written to it to the log sink. Once the sink
+// fails, the lines are dropped, so that an unreachable collector does not
+// interrupt the run or its ansible.log file.
+type logSinkWriter struct {
+	sink         logSink
+	task         string
+	runDirectory string
+
+	mu     sync.Mutex
+	failed bool
+}
+
+func newLogSinkWriter(sink logSink, task, runDirectory string) io.Writer {
+	return &logSinkWriter{sink: sink, task: task, runDirectory: runDirectory}
+}
+
+func (w *logSinkWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failed {
+		return len(p), nil
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if err := w.sink.send(w.task, w.runDirectory, line); err != nil {
+			logging.Warn("error sending the ansible log to the log sink, the rest of the log of the run is not sent", "task", w.task, "error", err)
+			w.failed = true
+			break
+		}
+	}
+	return len(p), nil
+}
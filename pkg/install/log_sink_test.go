@@ -0,0 +1,124 @@
+package install
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	lines []string
+	fail  bool
+}
+
+func (s *recordingSink) send(task, runDirectory, line string) error {
+	if s.fail {
+		return errors.New("connection refused")
+	}
+	s.lines = append(s.lines, task+" "+line)
+	return nil
+}
+
+func TestOpenLogSinkInvalid(t *testing.T) {
+	tests := []string{
+		"logs.example.com:514",
+		"http://logs.example.com",
+		"udp://",
+		"unix://",
+	}
+	for _, sink := range tests {
+		if _, err := openLogSink(sink); err == nil {
+			t.Errorf("expected an error for log sink %q", sink)
+		}
+	}
+}
+
+func TestExecutorLogSinkNotSet(t *testing.T) {
+	sink, err := executorLogSink(ExecutorOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sink != nil {
+		t.Errorf("expected no log sink, got %v", sink)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer conn.Close()
+	sink, err := openLogSink("udp://" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.send("apply", "runs/apply/1", "TASK [etcd]"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("error reading the message: %v", err)
+	}
+	msg := string(b[:n])
+	if !strings.Contains(msg, logSinkIdentifier) || !strings.Contains(msg, "[apply] TASK [etcd]") {
+		t.Errorf("unexpected syslog message: %q", msg)
+	}
+}
+
+func TestJournaldSink(t *testing.T) {
+	socket := filepath.Join(mustGetTempDir(t), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	defer conn.Close()
+	sink, err := openJournaldSink(socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sink.send("apply", "runs/apply/1", "TASK [etcd]"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1024)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatalf("error reading the entry: %v", err)
+	}
+	entry := string(b[:n])
+	for _, field := range []string{"MESSAGE=TASK [etcd]\n", "SYSLOG_IDENTIFIER=kismatic\n", "KISMATIC_TASK=apply\n", "KISMATIC_RUN_DIRECTORY=runs/apply/1\n"} {
+		if !strings.Contains(entry, field) {
+			t.Errorf("expected the entry to contain %q, got %q", field, entry)
+		}
+	}
+}
+
+func TestLogSinkWriterSplitsLines(t *testing.T) {
+	sink := &recordingSink{}
+	w := newLogSinkWriter(sink, "apply", "runs/apply/1")
+	p := []byte("first\nsecond\n")
+	n, err := w.Write(p)
+	if err != nil || n != len(p) {
+		t.Fatalf("unexpected write result: %d, %v", n, err)
+	}
+	assertEqual(t, sink.lines, []string{"apply first", "apply second"})
+}
+
+func TestLogSinkWriterStopsAfterFailure(t *testing.T) {
+	sink := &recordingSink{fail: true}
+	w := newLogSinkWriter(sink, "apply", "runs/apply/1")
+	if n, err := w.Write([]byte("first\n")); err != nil || n != 6 {
+		t.Fatalf("expected the failure of the sink to be ignored, got %d, %v", n, err)
+	}
+	sink.fail = false
+	w.Write([]byte("second\n"))
+	if len(sink.lines) != 0 {
+		t.Errorf("expected no lines to be sent after the sink failed, got %v", sink.lines)
+	}
+}
@@ -0,0 +1,137 @@
+package install
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// the sizes are given to find and journalctl, which both accept M and G suffixes
+var cleanSizeRegexp = regexp.MustCompile(`^[0-9]+[MG]$`)
+
+// NodeCleanOptions select what is removed from the nodes when they are cleaned.
+// The stopped containers and the dangling images are always removed.
+type NodeCleanOptions struct {
+	// AllImages removes all the images that are not used by a container,
+	// instead of only the dangling images
+	AllImages bool
+	// Volumes removes the docker volumes that are not used by a container
+	Volumes bool
+	// ContainerLogMaxSize truncates the container logs that are larger than
+	// the size, such as 100M. The logs are not truncated when empty.
+	ContainerLogMaxSize string
+	// JournalMaxSize reduces the systemd journal to the size, such as 500M.
+	// The journal is not reduced when empty.
+	JournalMaxSize string
+}
+
+// Validate the options
+func (o NodeCleanOptions) Validate() error {
+	if o.ContainerLogMaxSize != "" && !cleanSizeRegexp.MatchString(o.ContainerLogMaxSize) {
+		return fmt.Errorf("invalid container log size %q: must be a number of megabytes or gigabytes, such as 100M or 1G", o.ContainerLogMaxSize)
+	}
+	if o.JournalMaxSize != "" && !cleanSizeRegexp.MatchString(o.JournalMaxSize) {
+		return fmt.Errorf("invalid journal size %q: must be a number of megabytes or gigabytes, such as 500M or 1G", o.JournalMaxSize)
+	}
+	return nil
+}
+
+// CleanNodes removes the unused container images and volumes, and truncates
+// the logs on the nodes, to free disk space
+func (ae *ansibleExecutor) CleanNodes(p *Plan, opts NodeCleanOptions, nodes ...string) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	nodes, err := p.ResolveLimit(nodes)
+	if err != nil {
+		return err
+	}
+	cc, err := ae.buildClusterCatalog(p)
+	if err != nil {
+		return err
+	}
+	cc.NodeClean.AllImages = opts.AllImages
+	cc.NodeClean.Volumes = opts.Volumes
+	cc.NodeClean.ContainerLogMaxSize = opts.ContainerLogMaxSize
+	cc.NodeClean.JournalMaxSize = opts.JournalMaxSize
+	t := task{
+		name:           "clean-nodes",
+		playbook:       "clean-nodes.yaml",
+		explainer:      ae.defaultExplainer(),
+		plan:           *p,
+		inventory:      ae.buildInventory(p),
+		clusterCatalog: *cc,
+		limit:          nodes,
+	}
+	util.PrintHeader(ae.stdout, "Cleaning Nodes", '=')
+	_, err = ae.execute(t)
+	return err
+}
+
+// DiskUsage is the usage of a filesystem of a node
+type DiskUsage struct {
+	Mount       string
+	SizeKB      uint64
+	UsedKB      uint64
+	AvailableKB uint64
+}
+
+// UsedPercent returns the percentage of the filesystem that is used
+func (d DiskUsage) UsedPercent() float64 {
+	if d.SizeKB == 0 {
+		return 0
+	}
+	return float64(d.UsedKB) / float64(d.SizeKB) * 100
+}
+
+// NodeDiskUsage returns the usage of the filesystems that hold the root,
+// docker and log directories of the node
+func NodeDiskUsage(p *Plan, host string) ([]DiskUsage, error) {
+	client, err := p.GetSSHClient(host)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSH client: %v", err)
+	}
+	// the directories that do not exist are ignored
+	out, err := client.Output(true, "sudo df -P -k / /var/lib/docker /var/log 2>/dev/null; true")
+	if err != nil {
+		return nil, fmt.Errorf("error getting disk usage of node %q: %v", host, err)
+	}
+	return parseDiskUsage(out)
+}
+
+// parseDiskUsage parses the POSIX output of df. Filesystems that are listed
+// more than once are returned once.
+func parseDiskUsage(out string) ([]DiskUsage, error) {
+	var usage []DiskUsage
+	seen := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[0] == "Filesystem" {
+			continue
+		}
+		mount := fields[5]
+		if seen[mount] {
+			continue
+		}
+		seen[mount] = true
+		d := DiskUsage{Mount: mount}
+		var err error
+		if d.SizeKB, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("error parsing disk usage %q: %v", line, err)
+		}
+		if d.UsedKB, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+			return nil, fmt.Errorf("error parsing disk usage %q: %v", line, err)
+		}
+		if d.AvailableKB, err = strconv.ParseUint(fields[3], 10, 64); err != nil {
+			return nil, fmt.Errorf("error parsing disk usage %q: %v", line, err)
+		}
+		usage = append(usage, d)
+	}
+	if len(usage) == 0 {
+		return nil, fmt.Errorf("no disk usage found in %q", out)
+	}
+	return usage, nil
+}
@@ -0,0 +1,45 @@
+package install
+
+import "testing"
+
+func TestParseDiskUsage(t *testing.T) {
+	out := `Filesystem     1024-blocks     Used Available Capacity Mounted on
+/dev/sda1         41152736 30123456  11029280      74% /
+/dev/sdb1        103081248 92773123  10308125      90% /var/lib/docker
+/dev/sda1         41152736 30123456  11029280      74% /
+`
+	usage, err := parseDiskUsage(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("expected 2 filesystems, but got %v", usage)
+	}
+	if usage[1].Mount != "/var/lib/docker" || usage[1].UsedKB != 92773123 || usage[1].AvailableKB != 10308125 {
+		t.Errorf("unexpected disk usage: %+v", usage[1])
+	}
+	if p := usage[1].UsedPercent(); p < 89 || p > 91 {
+		t.Errorf("expected 90%% usage, but got %.1f", p)
+	}
+
+	if _, err := parseDiskUsage("df: /var/lib/docker: No such file or directory"); err == nil {
+		t.Error("expected an error when no filesystems are listed")
+	}
+}
+
+func TestNodeCleanOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts  NodeCleanOptions
+		valid bool
+	}{
+		{NodeCleanOptions{}, true},
+		{NodeCleanOptions{ContainerLogMaxSize: "100M", JournalMaxSize: "1G"}, true},
+		{NodeCleanOptions{ContainerLogMaxSize: "100"}, false},
+		{NodeCleanOptions{JournalMaxSize: "500MB"}, false},
+	}
+	for i, test := range tests {
+		if err := test.opts.Validate(); (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid to be %v, but got %v", i, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package install
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// nodeHTTPRequest is an HTTP request that is sent from a node using curl
+type nodeHTTPRequest struct {
+	method   string
+	url      string
+	headers  map[string]string
+	body     string
+	insecure bool
+}
+
+// curlQuote returns the value as a quoted string of a curl config file
+func curlQuote(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// send sends the request from the node, and returns the status code and the
+// body of the response. The request is passed to curl as a config file through
+// stdin, so that the credentials in the headers and body are not visible in
+// the process list of the node.
+func (r nodeHTTPRequest) send(client ssh.Client) (int, []byte, error) {
+	status, _, body, err := r.do(client, false)
+	return status, body, err
+}
+
+// sendWithHeader sends the request from the node like send, and also returns
+// the header of the response
+func (r nodeHTTPRequest) sendWithHeader(client ssh.Client) (int, http.Header, []byte, error) {
+	return r.do(client, true)
+}
+
+func (r nodeHTTPRequest) do(client ssh.Client, includeHeader bool) (int, http.Header, []byte, error) {
+	config := []string{
+		"silent",
+		"max-time = 30",
+		"url = " + curlQuote(r.url),
+		"request = " + curlQuote(r.method),
+		"write-out = " + curlQuote("\n%{http_code}"),
+	}
+	if r.insecure {
+		config = append(config, "insecure")
+	}
+	if includeHeader {
+		config = append(config, "include")
+	}
+	for _, k := range sortedHeaderKeys(r.headers) {
+		config = append(config, "header = "+curlQuote(k+": "+r.headers[k]))
+	}
+	if r.body != "" {
+		config = append(config, "data-binary = "+curlQuote(r.body))
+	}
+	cmd := fmt.Sprintf("curl -K - <<'KISMATIC_EOF'\n%s\nKISMATIC_EOF", strings.Join(config, "\n"))
+	out, err := client.Output(false, cmd)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("error connecting to %s: %v: %s", r.url, err, strings.TrimSpace(out))
+	}
+	out = strings.TrimRight(out, "\r\n")
+	idx := strings.LastIndex(out, "\n")
+	var status int
+	if _, err := fmt.Sscanf(out[idx+1:], "%d", &status); err != nil {
+		return 0, nil, nil, fmt.Errorf("unexpected response from %s: %q", r.url, out)
+	}
+	if idx < 0 {
+		return status, nil, nil, nil
+	}
+	body := out[:idx]
+	if !includeHeader {
+		return status, nil, []byte(body), nil
+	}
+	header, body, err := splitResponseHeader(body)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("unexpected response from %s: %v", r.url, err)
+	}
+	return status, header, []byte(body), nil
+}
+
+// splitResponseHeader splits the output of curl with the header included into
+// the header of the final response and its body. Informational responses, such
+// as 100 Continue, are skipped.
+func splitResponseHeader(out string) (http.Header, string, error) {
+	var block string
+	for strings.HasPrefix(out, "HTTP/") {
+		idx := strings.Index(out, "\r\n\r\n")
+		if idx < 0 {
+			block, out = out, ""
+			break
+		}
+		block, out = out[:idx], out[idx+4:]
+	}
+	if block == "" {
+		return nil, "", fmt.Errorf("no header in response %q", out)
+	}
+	tp := textproto.NewReader(bufio.NewReader(strings.NewReader(block + "\r\n\r\n")))
+	if _, err := tp.ReadLine(); err != nil {
+		return nil, "", err
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, "", err
+	}
+	return http.Header(header), out, nil
+}
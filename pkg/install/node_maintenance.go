@@ -0,0 +1,107 @@
+package install
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// DrainOptions configure the eviction of the pods of a node that is drained
+type DrainOptions struct {
+	// Timeout is the maximum duration of the drain. Defaults to 5 minutes.
+	Timeout time.Duration
+	// Force evicts the pods that are not managed by a controller, such as a
+	// replica set or a stateful set. These pods are not recreated.
+	Force bool
+	// DeleteLocalData evicts the pods that use emptyDir volumes, whose data
+	// is lost
+	DeleteLocalData bool
+}
+
+// Validate the options
+func (o DrainOptions) Validate() error {
+	if o.Timeout < 0 {
+		return fmt.Errorf("the drain timeout must be positive, got %s", o.Timeout)
+	}
+	if o.Timeout > 0 && o.Timeout < time.Second {
+		return fmt.Errorf("the drain timeout must be at least 1s, got %s", o.Timeout)
+	}
+	return nil
+}
+
+// Cordon marks the node as unschedulable, using kubectl on a master node
+func (ae *ansibleExecutor) Cordon(p *Plan, host string) error {
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Cordoning Node %s", host), '=')
+	return ae.maintainNode(p, host, "cordon-node", "cordon-node.yaml", nil)
+}
+
+// Uncordon marks the node as schedulable, using kubectl on a master node
+func (ae *ansibleExecutor) Uncordon(p *Plan, host string) error {
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Uncordoning Node %s", host), '=')
+	return ae.maintainNode(p, host, "uncordon-node", "uncordon-node.yaml", nil)
+}
+
+// Drain cordons the node and evicts its pods, using kubectl on a master node.
+// The pods of the daemon sets are not evicted.
+func (ae *ansibleExecutor) Drain(p *Plan, host string, opts DrainOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	util.PrintHeader(ae.stdout, fmt.Sprintf("Draining Node %s", host), '=')
+	return ae.maintainNode(p, host, "drain-node", "drain-node.yaml", func(cc *ansible.ClusterCatalog) {
+		cc.NodeMaintenance.Drain.TimeoutSeconds = int(opts.Timeout.Seconds())
+		cc.NodeMaintenance.Drain.Force = opts.Force
+		cc.NodeMaintenance.Drain.DeleteLocalData = opts.DeleteLocalData
+	})
+}
+
+// maintainNode runs the playbook of a maintenance operation of the node. The
+// playbook runs on a master node, so kubectl is not required on the machine
+// running kismatic.
+func (ae *ansibleExecutor) maintainNode(p *Plan, host string, name string, playbook string, configure func(*ansible.ClusterCatalog)) error {
+	if err := validateMaintainedNode(p, host); err != nil {
+		return err
+	}
+	cc, err := ae.buildClusterCatalog(p)
+	if err != nil {
+		return err
+	}
+	// the catalog is shared with the other tasks of the plan
+	catalog := *cc
+	catalog.NodeMaintenance.Host = host
+	if configure != nil {
+		configure(&catalog)
+	}
+	t := task{
+		name:           name,
+		playbook:       playbook,
+		explainer:      ae.defaultExplainer(),
+		plan:           *p,
+		inventory:      ae.buildInventory(p),
+		clusterCatalog: catalog,
+	}
+	_, err = ae.execute(t)
+	return err
+}
+
+// validateMaintainedNode returns an error if the host is not a node of the
+// plan that is registered in Kubernetes. The nodes that are only etcd nodes
+// do not run a kubelet.
+func validateMaintainedNode(p *Plan, host string) error {
+	for _, n := range p.GetUniqueNodes() {
+		if n.Host != host {
+			continue
+		}
+		roles := p.GetRolesForIP(n.IP)
+		if len(roles) == 1 && roles[0] == "etcd" {
+			return fmt.Errorf("node %q is an etcd node, which is not registered in Kubernetes", host)
+		}
+		return nil
+	}
+	return fmt.Errorf("node %q was not found in the plan", host)
+}
@@ -0,0 +1,47 @@
+package install
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	runner := &retryRunner{}
+	e := retryExecutor(t, runner, RetryPolicy{})
+	e.renderCache = &renderCache{}
+	plan := removeNodeTestPlan()
+	if err := e.Drain(&plan, "worker1", DrainOptions{Force: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runner.catalogs) != 1 {
+		t.Fatalf("expected 1 playbook to be run, got %d", len(runner.catalogs))
+	}
+	m := runner.catalogs[0].NodeMaintenance
+	if m.Host != "worker1" || m.Drain.TimeoutSeconds != 300 || !m.Drain.Force || m.Drain.DeleteLocalData {
+		t.Errorf("unexpected node maintenance in the catalog %+v", m)
+	}
+	// the catalog of the plan is not modified
+	cc, _ := e.buildClusterCatalog(&plan)
+	if cc.NodeMaintenance.Host != "" {
+		t.Errorf("expected the catalog of the plan not to be modified, got %+v", cc.NodeMaintenance)
+	}
+}
+
+func TestNodeMaintenanceInvalidNode(t *testing.T) {
+	runner := &retryRunner{}
+	e := retryExecutor(t, runner, RetryPolicy{})
+	e.renderCache = &renderCache{}
+	plan := removeNodeTestPlan()
+	if err := e.Cordon(&plan, "etcd1"); err == nil {
+		t.Errorf("expected an error when cordoning an etcd node")
+	}
+	if err := e.Uncordon(&plan, "unknown"); err == nil {
+		t.Errorf("expected an error when uncordoning a node that is not in the plan")
+	}
+	if err := e.Drain(&plan, "worker1", DrainOptions{Timeout: -time.Minute}); err == nil {
+		t.Errorf("expected an error when the timeout is negative")
+	}
+	if len(runner.runs) != 0 {
+		t.Errorf("expected no playbooks to be run, got %v", runner.runs)
+	}
+}
@@ -0,0 +1,216 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+// NodeStatus is the status of a node of the plan, or of a node registered in
+// the cluster that is not in the plan
+type NodeStatus struct {
+	Host  string   `json:"host"`
+	IP    string   `json:"ip,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+	// InPlan is false for the nodes registered in the cluster that are not
+	// in the plan
+	InPlan bool `json:"inPlan"`
+	// Reachable is true when the node accepts SSH connections. It is only
+	// checked for the nodes of the plan.
+	Reachable bool   `json:"reachable"`
+	SSHError  string `json:"sshError,omitempty"`
+	// KubeletExpected is true when the node runs a kubelet, which is the case
+	// of all the nodes except the nodes that are only etcd nodes
+	KubeletExpected bool `json:"kubeletExpected"`
+	// Registered is true when the kubelet of the node registered the node in
+	// the cluster
+	Registered bool `json:"registered"`
+	// Ready is true when the node is registered and ready
+	Ready bool `json:"ready"`
+}
+
+// Healthy returns true when the node is in the plan, reachable, and
+// registered and ready when it runs a kubelet
+func (s NodeStatus) Healthy() bool {
+	if !s.InPlan || !s.Reachable {
+		return false
+	}
+	return !s.KubeletExpected || (s.Registered && s.Ready)
+}
+
+// NodesReport is the status of the nodes of the plan and of the cluster
+type NodesReport struct {
+	Nodes []NodeStatus `json:"nodes"`
+	// ClusterError is set when the nodes registered in the cluster could not
+	// be listed, in which case the registration of the nodes is unknown
+	ClusterError string `json:"clusterError,omitempty"`
+}
+
+// NodesStatus checks that the nodes of the plan accept SSH connections and
+// are registered in the cluster, and reports the nodes registered in the
+// cluster that are not in the plan. The nodes of the cluster are listed with
+// kubectl on the first master node that is reachable.
+func NodesStatus(p *Plan) NodesReport {
+	sshCheck := func(host string) error {
+		client, err := p.GetSSHClient(host)
+		if err != nil {
+			return err
+		}
+		_, err = client.Output(true, "true")
+		return err
+	}
+	listNodes := func(host string) (*data.NodeList, error) {
+		client, err := p.GetSSHClient(host)
+		if err != nil {
+			return nil, fmt.Errorf("error getting SSH client: %v", err)
+		}
+		return data.RemoteKubectl{SSHClient: client}.ListNodes()
+	}
+	return nodesStatus(p, sshCheck, listNodes)
+}
+
+func nodesStatus(p *Plan, sshCheck func(host string) error, listNodes func(host string) (*data.NodeList, error)) NodesReport {
+	report := NodesReport{Nodes: []NodeStatus{}}
+	reachable := map[string]bool{}
+	planNodes := p.GetUniqueNodes()
+	for _, n := range planNodes {
+		roles := p.GetRolesForIP(n.IP)
+		s := NodeStatus{
+			Host:            n.Host,
+			IP:              n.IP,
+			Roles:           roles,
+			InPlan:          true,
+			KubeletExpected: !(len(roles) == 1 && roles[0] == "etcd"),
+		}
+		if err := sshCheck(n.Host); err != nil {
+			s.SSHError = err.Error()
+		} else {
+			s.Reachable = true
+			reachable[n.Host] = true
+		}
+		report.Nodes = append(report.Nodes, s)
+	}
+
+	var cluster *data.NodeList
+	var errs []string
+	for _, m := range p.Master.Nodes {
+		if !reachable[m.Host] {
+			continue
+		}
+		l, err := listNodes(m.Host)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", m.Host, err))
+			continue
+		}
+		cluster = l
+		break
+	}
+	if cluster == nil {
+		if len(errs) == 0 {
+			errs = append(errs, "no master node is reachable")
+		}
+		report.ClusterError = fmt.Sprintf("error listing the nodes of the cluster: %s", strings.Join(errs, "; "))
+		return report
+	}
+
+	matched := make([]bool, len(cluster.Items))
+	for i := range report.Nodes {
+		s, n := &report.Nodes[i], planNodes[i]
+		for j, cn := range cluster.Items {
+			if matched[j] {
+				continue
+			}
+			if cn.Name == n.Host || cn.HasAddress(n.IP) || (n.InternalIP != "" && cn.HasAddress(n.InternalIP)) {
+				matched[j] = true
+				s.Registered = true
+				s.Ready = cn.Ready()
+				break
+			}
+		}
+	}
+	var extra []NodeStatus
+	for j, cn := range cluster.Items {
+		if matched[j] {
+			continue
+		}
+		extra = append(extra, NodeStatus{
+			Host:            cn.Name,
+			IP:              nodeInternalIP(cn),
+			KubeletExpected: true,
+			Registered:      true,
+			Ready:           cn.Ready(),
+		})
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i].Host < extra[j].Host })
+	report.Nodes = append(report.Nodes, extra...)
+	return report
+}
+
+func nodeInternalIP(n data.Node) string {
+	for _, a := range n.Status.Addresses {
+		if a.Type == "InternalIP" {
+			return a.Address
+		}
+	}
+	return ""
+}
+
+// PatchOperation is an operation of a JSON patch (RFC 6902) to the plan
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// PlanPatch returns the JSON patch that reconciles the plan with the nodes
+// registered in the cluster. The nodes of the cluster that are not in the
+// plan are added as worker nodes, and the worker, ingress and storage nodes
+// of the plan that are not registered are removed. The master and etcd nodes
+// are never removed. The patch is empty when the registration of the nodes is
+// unknown.
+func (r NodesReport) PlanPatch(p *Plan) []PatchOperation {
+	ops := []PatchOperation{}
+	if r.ClusterError != "" {
+		return ops
+	}
+	unregistered := map[string]bool{}
+	var missing []NodeStatus
+	for _, s := range r.Nodes {
+		if !s.InPlan {
+			missing = append(missing, s)
+		} else if s.KubeletExpected && !s.Registered {
+			unregistered[s.Host] = true
+		}
+	}
+	groups := []struct {
+		name  string
+		nodes []Node
+	}{
+		{"worker", p.Worker.Nodes},
+		{"ingress", p.Ingress.Nodes},
+		{"storage", p.Storage.Nodes},
+	}
+	for _, g := range groups {
+		count := len(g.nodes)
+		// Remove from the end, so that the indexes of the remaining nodes
+		// do not change
+		for i := len(g.nodes) - 1; i >= 0; i-- {
+			if unregistered[g.nodes[i].Host] {
+				ops = append(ops, PatchOperation{Op: "remove", Path: fmt.Sprintf("/%s/nodes/%d", g.name, i)})
+				count--
+			}
+		}
+		if g.name == "worker" {
+			for _, s := range missing {
+				ops = append(ops, PatchOperation{Op: "add", Path: "/worker/nodes/-", Value: map[string]string{"host": s.Host, "ip": s.IP}})
+				count++
+			}
+		}
+		if count != len(g.nodes) {
+			ops = append(ops, PatchOperation{Op: "replace", Path: fmt.Sprintf("/%s/expected_count", g.name), Value: count})
+		}
+	}
+	return ops
+}
@@ -0,0 +1,126 @@
+package install
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+func nodeStatusTestPlan() *Plan {
+	return &Plan{
+		Etcd:   NodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "etcd01", IP: "10.0.0.1"}}},
+		Master: MasterNodeGroup{ExpectedCount: 2, Nodes: []Node{{Host: "master01", IP: "10.0.0.2"}, {Host: "master02", IP: "10.0.0.3"}}},
+		Worker: NodeGroup{ExpectedCount: 3, Nodes: []Node{
+			{Host: "worker01", IP: "10.0.0.4"},
+			{Host: "worker02", IP: "192.168.0.5", InternalIP: "10.0.0.5"},
+			{Host: "worker03", IP: "10.0.0.6"},
+		}},
+	}
+}
+
+func clusterNode(name, ip string, ready bool) data.Node {
+	n := data.Node{}
+	n.Name = name
+	n.Status.Addresses = []data.NodeAddress{{Type: "InternalIP", Address: ip}}
+	status := "False"
+	if ready {
+		status = "True"
+	}
+	n.Status.Conditions = []data.NodeCondition{{Type: "Ready", Status: status}}
+	return n
+}
+
+func TestNodesStatus(t *testing.T) {
+	p := nodeStatusTestPlan()
+	var listedOn []string
+	report := nodesStatus(p,
+		func(host string) error {
+			if host == "master01" || host == "worker03" {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+		func(host string) (*data.NodeList, error) {
+			listedOn = append(listedOn, host)
+			return &data.NodeList{Items: []data.Node{
+				clusterNode("master01", "10.0.0.2", true),
+				clusterNode("master02", "10.0.0.3", true),
+				clusterNode("worker01", "10.0.0.4", false),
+				// registered with another name, matched by its internal IP
+				clusterNode("ip-10-0-0-5", "10.0.0.5", true),
+				clusterNode("worker09", "10.0.0.9", true),
+			}}, nil
+		})
+	if report.ClusterError != "" {
+		t.Fatalf("unexpected cluster error: %s", report.ClusterError)
+	}
+	if !reflect.DeepEqual(listedOn, []string{"master02"}) {
+		t.Errorf("expected the nodes to be listed on the first reachable master, got %v", listedOn)
+	}
+	expected := []NodeStatus{
+		{Host: "etcd01", IP: "10.0.0.1", Roles: []string{"etcd"}, InPlan: true, Reachable: true},
+		{Host: "master01", IP: "10.0.0.2", Roles: []string{"master"}, InPlan: true, SSHError: "connection refused", KubeletExpected: true, Registered: true, Ready: true},
+		{Host: "master02", IP: "10.0.0.3", Roles: []string{"master"}, InPlan: true, Reachable: true, KubeletExpected: true, Registered: true, Ready: true},
+		{Host: "worker01", IP: "10.0.0.4", Roles: []string{"worker"}, InPlan: true, Reachable: true, KubeletExpected: true, Registered: true},
+		{Host: "worker02", IP: "192.168.0.5", Roles: []string{"worker"}, InPlan: true, Reachable: true, KubeletExpected: true, Registered: true, Ready: true},
+		{Host: "worker03", IP: "10.0.0.6", Roles: []string{"worker"}, InPlan: true, SSHError: "connection refused", KubeletExpected: true},
+		{Host: "worker09", IP: "10.0.0.9", KubeletExpected: true, Registered: true, Ready: true},
+	}
+	if !reflect.DeepEqual(report.Nodes, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, report.Nodes)
+	}
+	var healthy []string
+	for _, s := range report.Nodes {
+		if s.Healthy() {
+			healthy = append(healthy, s.Host)
+		}
+	}
+	if !reflect.DeepEqual(healthy, []string{"etcd01", "master02", "worker02"}) {
+		t.Errorf("unexpected healthy nodes %v", healthy)
+	}
+
+	patch := report.PlanPatch(p)
+	expectedPatch := []PatchOperation{
+		{Op: "remove", Path: "/worker/nodes/2"},
+		{Op: "add", Path: "/worker/nodes/-", Value: map[string]string{"host": "worker09", "ip": "10.0.0.9"}},
+	}
+	if !reflect.DeepEqual(patch, expectedPatch) {
+		t.Errorf("expected patch %+v, got %+v", expectedPatch, patch)
+	}
+}
+
+func TestNodesStatusClusterUnavailable(t *testing.T) {
+	p := nodeStatusTestPlan()
+	report := nodesStatus(p,
+		func(host string) error { return nil },
+		func(host string) (*data.NodeList, error) { return nil, errors.New("kubectl failed") })
+	if report.ClusterError == "" {
+		t.Fatal("expected a cluster error")
+	}
+	for _, s := range report.Nodes {
+		if s.Registered || !s.InPlan {
+			t.Errorf("expected only the nodes of the plan, not registered, got %+v", s)
+		}
+	}
+	if patch := report.PlanPatch(p); len(patch) != 0 {
+		t.Errorf("expected an empty patch, got %+v", patch)
+	}
+}
+
+func TestPlanPatchUpdatesExpectedCount(t *testing.T) {
+	p := nodeStatusTestPlan()
+	report := NodesReport{Nodes: []NodeStatus{
+		{Host: "worker01", InPlan: true, KubeletExpected: true},
+		{Host: "worker03", InPlan: true, KubeletExpected: true},
+	}}
+	expected := []PatchOperation{
+		{Op: "remove", Path: "/worker/nodes/2"},
+		{Op: "remove", Path: "/worker/nodes/0"},
+		{Op: "replace", Path: "/worker/expected_count", Value: 1},
+	}
+	if patch := report.PlanPatch(p); !reflect.DeepEqual(patch, expected) {
+		t.Errorf("expected patch %+v, got %+v", expected, patch)
+	}
+}
@@ -0,0 +1,195 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/inspector/rule"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+// nodeRunner fails the playbook when it is limited to the failing node. The
+// events of the node, if any, are sent to the event stream before the playbook
+// completes.
+type nodeRunner struct {
+	failNode string
+	events   map[string][]ansible.Event
+	sent     chan struct{}
+	err      error
+}
+
+func (r *nodeRunner) StartPlaybook(string, ansible.Inventory, ansible.ClusterCatalog) (<-chan ansible.Event, error) {
+	return r.StartPlaybookOnNode("", ansible.Inventory{}, ansible.ClusterCatalog{})
+}
+
+func (r *nodeRunner) StartPlaybookOnNode(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog, nodes ...string) (<-chan ansible.Event, error) {
+	if len(nodes) == 1 && nodes[0] == r.failNode {
+		r.err = errors.New("preflight failed")
+	}
+	events := make(chan ansible.Event)
+	var nodeEvents []ansible.Event
+	if len(nodes) == 1 {
+		nodeEvents = r.events[nodes[0]]
+	}
+	r.sent = make(chan struct{})
+	go func() {
+		defer close(r.sent)
+		defer close(events)
+		for _, e := range nodeEvents {
+			events <- e
+		}
+	}()
+	return events, nil
+}
+
+func (r *nodeRunner) WaitPlaybook() error {
+	<-r.sent
+	return r.err
+}
+
+func (r *nodeRunner) Stop() error {
+	return nil
+}
+
+func TestRunUpgradePreFlightChecksInParallel(t *testing.T) {
+	runsDir := mustGetTempDir(t)
+	out := &bytes.Buffer{}
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: runsDir, Timings: true},
+		stdout:              out,
+		consoleOutputFormat: ansible.RawFormat,
+		certsDir:            mustGetTempDir(t),
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return &nodeRunner{failNode: "worker02"}, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+	}
+	nodes := []ListableNode{
+		{Node: Node{Host: "worker01"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "worker02"}, Roles: []string{"worker"}},
+		{Node: Node{Host: "worker03"}, Roles: []string{"worker"}},
+	}
+	plan := &Plan{
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01", InternalIP: "10.10.2.20"}},
+		},
+		Cluster: Cluster{
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+	}
+
+	failed := e.RunUpgradePreFlightChecks(plan, nodes, 2)
+	if len(failed) != 1 || failed["worker02"] == nil {
+		t.Errorf("expected only worker02 to fail, but got %v", failed)
+	}
+	runs, err := ioutil.ReadDir(filepath.Join(runsDir, "upgrade-preflight"))
+	if err != nil {
+		t.Fatalf("error reading runs directory: %v", err)
+	}
+	if len(runs) != len(nodes) {
+		t.Errorf("expected a run directory for each node, but got %d", len(runs))
+	}
+	for _, n := range nodes {
+		if !strings.Contains(out.String(), "["+n.Node.Host+"] ") {
+			t.Errorf("expected the output to contain lines prefixed with %q, but got:\n%s", n.Node.Host, out.String())
+		}
+	}
+}
+
+func TestRunPreFlightCheckInParallel(t *testing.T) {
+	runsDir := mustGetTempDir(t)
+	out := &bytes.Buffer{}
+	checks, err := json.Marshal([]rule.Result{
+		{Name: "Port 6443 is available", Success: false, Error: "port in use"},
+		{Name: "Docker is installed", Success: true},
+	})
+	if err != nil {
+		t.Fatalf("error marshaling checks: %v", err)
+	}
+	failed := &ansible.RunnerFailedEvent{}
+	failed.Host = "worker02"
+	failed.Result.Stdout = string(checks)
+	events := map[string][]ansible.Event{
+		"worker02": {
+			&ansible.TaskStartEvent{},
+			failed,
+			// the failure is recorded by the time the last event is received
+			&ansible.PlaybookEndEvent{},
+		},
+	}
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: runsDir, PreflightParallelism: 2},
+		stdout:              out,
+		consoleOutputFormat: ansible.RawFormat,
+		certsDir:            mustGetTempDir(t),
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return &nodeRunner{failNode: "worker02", events: events}, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+	}
+	plan := &Plan{
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01", InternalIP: "10.10.2.20"}},
+		},
+		Worker: NodeGroup{
+			Nodes: []Node{{Host: "worker01"}, {Host: "worker02"}},
+		},
+		Cluster: Cluster{
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+	}
+
+	err = e.RunPreFlightCheck(plan)
+	if err == nil || !strings.Contains(err.Error(), "worker02") {
+		t.Errorf("expected an error about worker02, but got %v", err)
+	}
+	runs, err := ioutil.ReadDir(filepath.Join(runsDir, "preflight"))
+	if err != nil {
+		t.Fatalf("error reading runs directory: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected a single run directory, but got %d", len(runs))
+	}
+	b, err := ioutil.ReadFile(filepath.Join(runsDir, "preflight", runs[0].Name(), preflightReportFilename))
+	if err != nil {
+		t.Fatalf("error reading pre-flight report: %v", err)
+	}
+	var report PreflightReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatalf("error unmarshaling pre-flight report: %v", err)
+	}
+	if len(report.Nodes) != 3 {
+		t.Fatalf("expected a result for each node, but got %d", len(report.Nodes))
+	}
+	for _, n := range report.Nodes {
+		if _, err := ioutil.ReadDir(n.RunDirectory); err != nil {
+			t.Errorf("expected the run directory of %q to exist: %v", n.Host, err)
+		}
+		if n.Host != "worker02" {
+			if !n.Success {
+				t.Errorf("expected %q to succeed", n.Host)
+			}
+			continue
+		}
+		if n.Success || len(n.Checks) != 2 || n.Checks[0].Error != "port in use" {
+			t.Errorf("expected worker02 to fail with the inspector checks, but got %+v", n)
+		}
+	}
+	if failed := report.FailedNodes(); len(failed) != 1 || failed[0] != "worker02" {
+		t.Errorf("expected only worker02 to fail, but got %v", failed)
+	}
+	if !strings.Contains(out.String(), "Pre-flight checks failed on \"worker02\"") {
+		t.Errorf("expected the failure of worker02 to be printed, but got:\n%s", out.String())
+	}
+}
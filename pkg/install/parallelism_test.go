@@ -0,0 +1,39 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParallelismCatalog(t *testing.T) {
+	e := retryExecutor(t, &retryRunner{}, RetryPolicy{})
+	e.renderCache = &renderCache{}
+	plan := removeNodeTestPlan()
+	cc, err := e.buildClusterCatalog(&plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cc.PlaySerial.Default != "100%" || len(cc.PlaySerial.Plays) != 0 {
+		t.Errorf("expected all the nodes to be configured at the same time, got %+v", cc.PlaySerial)
+	}
+
+	// the options of the executor take precedence over the plan
+	e = retryExecutor(t, &retryRunner{}, RetryPolicy{})
+	e.renderCache = &renderCache{}
+	e.options.Parallelism = Parallelism{Forks: 200, PlaySerial: map[string]string{"_docker.yaml": "10"}}
+	plan.Cluster.Parallelism = Parallelism{Forks: 100, Serial: "25%", PlaySerial: map[string]string{"_docker.yaml": "5", "_kubelet.yaml": "50%"}}
+	if forks := e.parallelism(plan).Forks; forks != 200 {
+		t.Errorf("expected 200 forks, got %d", forks)
+	}
+	cc, err = e.buildClusterCatalog(&plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"_docker.yaml": "10", "_kubelet.yaml": "50%"}
+	if cc.PlaySerial.Default != "25%" || !reflect.DeepEqual(cc.PlaySerial.Plays, expected) {
+		t.Errorf("unexpected serial of the plays %+v", cc.PlaySerial)
+	}
+	if plan.Cluster.Parallelism.PlaySerial["_docker.yaml"] != "5" {
+		t.Errorf("expected the plan not to be modified")
+	}
+}
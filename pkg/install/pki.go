@@ -53,6 +53,9 @@ type LocalPKI struct {
 	CACsr                   string
 	GeneratedCertsDirectory string
 	Log                     io.Writer
+	// DryRun reports the certificate authorities and certificates that would
+	// be generated, without writing anything to the certificates directory
+	DryRun bool
 }
 
 type certificateSpec struct {
@@ -120,18 +123,35 @@ func (lp *LocalPKI) GenerateClusterCA(p *Plan) (*tls.CA, error) {
 		return nil, fmt.Errorf("error verifying CA certificate/key: %v", err)
 	}
 	if exists {
-		return lp.GetClusterCA()
+		ca, err := lp.GetClusterCA()
+		if err != nil {
+			return nil, err
+		}
+		if err := validateFIPSKey(p, "cluster CA", ca.Key); err != nil {
+			return nil, err
+		}
+		return ca, nil
 	}
 
 	// CA keypair doesn't exist, generate one
+	if lp.DryRun {
+		util.PrettyPrintOk(lp.Log, "Would generate cluster Certificate Authority: CN=%q, %s", p.Cluster.Name, lp.certPath("ca"))
+		return nil, nil
+	}
 	util.PrettyPrintOk(lp.Log, "Generating cluster Certificate Authority")
 	key, cert, err := tls.NewCACert(lp.CACsr, p.Cluster.Name, p.Cluster.Certificates.CAExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CA Cert: %v", err)
 	}
+	if err := validateFIPSKey(p, "cluster CA", key); err != nil {
+		return nil, err
+	}
 	if err = tls.WriteCert(key, cert, "ca", lp.GeneratedCertsDirectory); err != nil {
 		return nil, fmt.Errorf("error writing CA files: %v", err)
 	}
+	if err = lp.writeCertsManifest(); err != nil {
+		return nil, err
+	}
 	return &tls.CA{
 		Cert: cert,
 		Key:  key,
@@ -157,18 +177,35 @@ func (lp *LocalPKI) GenerateProxyClientCA(p *Plan) (*tls.CA, error) {
 		return nil, fmt.Errorf("error verifying proxy-client CA certificate/key: %v", err)
 	}
 	if exists {
-		return lp.GetProxyClientCA()
+		ca, err := lp.GetProxyClientCA()
+		if err != nil {
+			return nil, err
+		}
+		if err := validateFIPSKey(p, "proxy-client CA", ca.Key); err != nil {
+			return nil, err
+		}
+		return ca, nil
 	}
 
 	// CA keypair doesn't exist, generate one
+	if lp.DryRun {
+		util.PrettyPrintOk(lp.Log, "Would generate proxy-client Certificate Authority: CN=%q, %s", proxyClientCACommonName, lp.certPath("proxy-client-ca"))
+		return nil, nil
+	}
 	util.PrettyPrintOk(lp.Log, "Generating proxy-client Certificate Authority")
 	key, cert, err := tls.NewCACert(lp.CACsr, proxyClientCACommonName, p.Cluster.Certificates.CAExpiry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy-client CA Cert: %v", err)
 	}
+	if err := validateFIPSKey(p, "proxy-client CA", key); err != nil {
+		return nil, err
+	}
 	if err = tls.WriteCert(key, cert, "proxy-client-ca", lp.GeneratedCertsDirectory); err != nil {
 		return nil, fmt.Errorf("error writing proxy-client CA files: %v", err)
 	}
+	if err = lp.writeCertsManifest(); err != nil {
+		return nil, err
+	}
 	return &tls.CA{
 		Cert: cert,
 		Key:  key,
@@ -199,6 +236,7 @@ func (lp *LocalPKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, prox
 		return err
 	}
 
+	var written bool
 	for _, s := range manifest {
 		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
 		if err != nil {
@@ -208,7 +246,7 @@ func (lp *LocalPKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, prox
 		// Pre-existing admin certificates from KET < 1.3.3 are not valid
 		// due to changes required for RBAC. Rename it if necessary.
 		if exists && s.filename == adminCertFilenameKETPre133 {
-			ok, err := renamePre133AdminCert(s.filename, lp.GeneratedCertsDirectory)
+			ok, err := renamePre133AdminCert(s.filename, lp.GeneratedCertsDirectory, lp.DryRun)
 			if err != nil {
 				return err
 			}
@@ -216,6 +254,7 @@ func (lp *LocalPKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, prox
 			if ok {
 				util.PrettyPrintWarn(lp.Log, "Existing admin certificate is invalid. Backing up and regenerating.")
 				exists = false
+				written = true
 			}
 		}
 
@@ -229,23 +268,37 @@ func (lp *LocalPKI) GenerateClusterCertificates(p *Plan, clusterCA *tls.CA, prox
 				util.PrintValidationErrors(lp.Log, warnings)
 				return fmt.Errorf("invalid certificate found for %q", s.description)
 			}
+			if p.Cluster.FIPS {
+				if err := tls.KeyFIPSCompliant(s.filename, lp.GeneratedCertsDirectory); err != nil {
+					return fmt.Errorf("the certificate for %q cannot be used in FIPS mode: %v", s.description, err)
+				}
+			}
 			// This cert is valid, move onto the next certificate
 			util.PrettyPrintOk(lp.Log, "Found valid certificate for %s", s.description)
 			continue
 		}
 
 		// Cert doesn't exist. Generate it
+		if lp.DryRun {
+			lp.reportDryRun(s, p.Cluster.Certificates.Expiry)
+			continue
+		}
 		if err := generateCert(lp.GeneratedCertsDirectory, s, p.Cluster.Certificates.Expiry); err != nil {
 			return err
 		}
+		written = true
 		util.PrettyPrintOk(lp.Log, "Generated certificate for %s", s.description)
 	}
+	if written {
+		return lp.writeCertsManifest()
+	}
 	return nil
 }
 
 // Validates that the certificate was generated by us. If so, renames it
-// to make a backup and returns true. Otherwise returns false.
-func renamePre133AdminCert(filename, dir string) (bool, error) {
+// to make a backup and returns true. Otherwise returns false. The certificate
+// is not renamed during a dry run.
+func renamePre133AdminCert(filename, dir string, dryRun bool) (bool, error) {
 	cert, err := tls.ReadCert(filename, dir)
 
 	if err != nil {
@@ -258,6 +311,9 @@ func renamePre133AdminCert(filename, dir string) (bool, error) {
 		len(cert.Subject.Province) == 1 && cert.Subject.Province[0] == "NY" &&
 		len(cert.Subject.Locality) == 1 && cert.Subject.Locality[0] == "Troy" {
 
+		if dryRun {
+			return true, nil
+		}
 		certFile := filepath.Join(dir, filename+".pem")
 		if err = os.Rename(certFile, certFile+".bak"); err != nil {
 			return false, fmt.Errorf("error backing up existing admin certificate: %v", err)
@@ -291,12 +347,44 @@ func (lp *LocalPKI) ValidateClusterCertificates(p *Plan) (warns []error, errs []
 			errs = append(errs, err)
 		}
 		if len(warn) > 0 {
-			warns = append(warns, warn...)
+			warns = append(warns, lp.explainStaleSubjectAlternateNames(s, warn)...)
+		}
+		if p.Cluster.FIPS {
+			if err := tls.KeyFIPSCompliant(s.filename, lp.GeneratedCertsDirectory); err != nil {
+				errs = append(errs, fmt.Errorf("the certificate for %q cannot be used in FIPS mode: %v", s.description, err))
+			}
+		}
+	}
+	if p.Cluster.FIPS {
+		for _, ca := range []string{"ca", "proxy-client-ca"} {
+			exists, err := tls.CertKeyPairExists(ca, lp.GeneratedCertsDirectory)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !exists {
+				continue
+			}
+			if err := tls.KeyFIPSCompliant(ca, lp.GeneratedCertsDirectory); err != nil {
+				errs = append(errs, fmt.Errorf("the %q certificate authority cannot be used in FIPS mode: %v", ca, err))
+			}
 		}
 	}
 	return warns, errs
 }
 
+// validateFIPSKey returns an error if the plan is in FIPS mode, and the key
+// is not allowed in FIPS mode
+func validateFIPSKey(p *Plan, description string, key []byte) error {
+	if !p.Cluster.FIPS {
+		return nil
+	}
+	if err := tls.FIPSCompliantKey(key); err != nil {
+		return fmt.Errorf("the %s cannot be used in FIPS mode: %v", description, err)
+	}
+	return nil
+}
+
 // NodeCertificateExists returns true if the node's key and certificate exist
 func (lp *LocalPKI) NodeCertificateExists(node Node) (bool, error) {
 	return tls.CertKeyPairExists(node.Host, lp.GeneratedCertsDirectory)
@@ -308,6 +396,7 @@ func (lp *LocalPKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) e
 	if err != nil {
 		return err
 	}
+	var written bool
 	for _, s := range m {
 		exists, err := tls.CertKeyPairExists(s.filename, lp.GeneratedCertsDirectory)
 		if err != nil {
@@ -323,16 +412,29 @@ func (lp *LocalPKI) GenerateNodeCertificate(plan *Plan, node Node, ca *tls.CA) e
 				util.PrintValidationErrors(lp.Log, warn)
 				return fmt.Errorf("invalid certificate found for %q", s.description)
 			}
+			if plan.Cluster.FIPS {
+				if err := tls.KeyFIPSCompliant(s.filename, lp.GeneratedCertsDirectory); err != nil {
+					return fmt.Errorf("the certificate for %q cannot be used in FIPS mode: %v", s.description, err)
+				}
+			}
 			// This cert is valid, move on
 			util.PrettyPrintOk(lp.Log, "Found valid certificate for %s", s.description)
 			continue
 		}
 		// Cert doesn't exist. Generate it
+		if lp.DryRun {
+			lp.reportDryRun(s, plan.Cluster.Certificates.Expiry)
+			continue
+		}
 		if err := generateCert(lp.GeneratedCertsDirectory, s, plan.Cluster.Certificates.Expiry); err != nil {
 			return err
 		}
+		written = true
 		util.PrettyPrintOk(lp.Log, "Generated certificate for %s", s.description)
 	}
+	if written {
+		return lp.writeCertsManifest()
+	}
 	return nil
 }
 
@@ -368,11 +470,26 @@ func (lp *LocalPKI) GenerateCertificate(name string, validityPeriod string, comm
 		ca:                    ca,
 	}
 
+	if lp.DryRun {
+		lp.reportDryRun(spec, validityPeriod)
+		return exists, nil
+	}
 	if err := generateCert(lp.GeneratedCertsDirectory, spec, validityPeriod); err != nil {
 		return exists, fmt.Errorf("could not generate certificate %s: %v", name, err)
 	}
 
-	return exists, nil
+	return exists, lp.writeCertsManifest()
+}
+
+// certPath returns the path of the certificate with the given name
+func (lp *LocalPKI) certPath(name string) string {
+	return filepath.Join(lp.GeneratedCertsDirectory, name+".pem")
+}
+
+// reportDryRun prints the certificate that would be generated during a dry run
+func (lp *LocalPKI) reportDryRun(spec certificateSpec, expiry string) {
+	util.PrettyPrintOk(lp.Log, "Would generate certificate for %s: CN=%q, SANs=%v, O=%v, valid for %s, %s",
+		spec.description, spec.commonName, spec.subjectAlternateNames, spec.organizations, expiry, lp.certPath(spec.filename))
 }
 
 func generateCert(certDir string, spec certificateSpec, expiryStr string) error {
@@ -1,6 +1,7 @@
 package install
 
 import (
+	"bytes"
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
@@ -1148,3 +1149,50 @@ func TestGenerateCertificate(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateCertificatesDryRun(t *testing.T) {
+	pki := getPKI(t)
+	defer cleanup(pki.GeneratedCertsDirectory, t)
+	out := &bytes.Buffer{}
+	pki.Log = out
+	pki.DryRun = true
+
+	p := getPlan()
+	ca, err := pki.GenerateClusterCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxyClientCA, err := pki.GenerateProxyClientCA(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pki.GenerateClusterCertificates(p, ca, proxyClientCA); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := pki.GenerateNodeCertificate(p, p.Worker.Nodes[0], ca); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := pki.GenerateCertificate("user", "8760h", "user", nil, nil, &tls.CA{}, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	files, err := ioutil.ReadDir(pki.GeneratedCertsDirectory)
+	if err != nil {
+		t.Fatalf("error listing files in generated certs dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files to be written during a dry run, but found %d", len(files))
+	}
+	expected := []string{
+		"Would generate cluster Certificate Authority",
+		"Would generate proxy-client Certificate Authority",
+		fmt.Sprintf("Would generate certificate for %s", p.Worker.Nodes[0].Host),
+		filepath.Join(pki.GeneratedCertsDirectory, "user.pem"),
+		"SANs=[kubernetes kubernetes.default",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("expected the output to contain %q, but got:\n%s", e, out.String())
+		}
+	}
+}
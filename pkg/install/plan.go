@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"regexp"
 	"strings"
 	"sync"
@@ -56,9 +57,13 @@ func (fp *FilePlanner) Read() (*Plan, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not read file: %v", err)
 	}
+	return ParsePlan(d)
+}
 
+// ParsePlan returns the plan described by the YAML document
+func ParsePlan(d []byte) (*Plan, error) {
 	p := &Plan{}
-	if err = yaml.Unmarshal(d, p); err != nil {
+	if err := yaml.Unmarshal(d, p); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal plan: %v", err)
 	}
 
@@ -68,6 +73,11 @@ func (fp *FilePlanner) Read() (*Plan, error) {
 	// set nil values to defaults
 	setDefaults(p)
 
+	// fetch the credentials that are stored in secrets managers
+	if err := ResolveSecrets(p); err != nil {
+		return nil, err
+	}
+
 	return p, nil
 }
 
@@ -176,6 +186,30 @@ func setDefaults(p *Plan) {
 		p.AddOns.CNI.Options.Calico.IPAutodetectionMethod = "first-found"
 	}
 
+	if p.Cluster.CloudProvider.Azure != nil {
+		if p.Cluster.CloudProvider.Azure.Cloud == "" {
+			p.Cluster.CloudProvider.Azure.Cloud = "AzurePublicCloud"
+		}
+		if p.Cluster.CloudProvider.Azure.VMType == "" {
+			p.Cluster.CloudProvider.Azure.VMType = "standard"
+		}
+		if p.Cluster.CloudProvider.Azure.LoadBalancerSKU == "" {
+			p.Cluster.CloudProvider.Azure.LoadBalancerSKU = "basic"
+		}
+	}
+
+	if p.Cluster.CloudProvider.VSphere != nil {
+		if p.Cluster.CloudProvider.VSphere.Port == 0 {
+			p.Cluster.CloudProvider.VSphere.Port = 443
+		}
+		if p.Cluster.CloudProvider.VSphere.StorageClass == "" {
+			p.Cluster.CloudProvider.VSphere.StorageClass = "vsphere"
+		}
+		if p.Cluster.CloudProvider.VSphere.DiskFormat == "" {
+			p.Cluster.CloudProvider.VSphere.DiskFormat = "thin"
+		}
+	}
+
 	if p.AddOns.DNS.Provider == "" {
 		p.AddOns.DNS.Provider = "kubedns"
 	}
@@ -218,6 +252,44 @@ func setDefaults(p *Plan) {
 	if p.AddOns.PackageManager.Options.Helm.Namespace == "" {
 		p.AddOns.PackageManager.Options.Helm.Namespace = "kube-system"
 	}
+
+	if sa := p.Cluster.SSH.ServiceAccount; sa != nil {
+		if sa.User == "" {
+			sa.User = "kismatic"
+		}
+		if len(sa.SudoCommands) == 0 {
+			sa.SudoCommands = []string{"ALL"}
+		}
+	}
+
+	for i := range p.Cluster.ImageCredentialProviders {
+		if p.Cluster.ImageCredentialProviders[i].RefreshInterval == "" {
+			p.Cluster.ImageCredentialProviders[i].RefreshInterval = "30m"
+		}
+	}
+
+	if p.AssetDistribution.PeerDistribution && p.AssetDistribution.Port == 0 {
+		p.AssetDistribution.Port = 8730
+	}
+
+	if p.AddOns.Heketi != nil && p.AddOns.Heketi.User == "" {
+		p.AddOns.Heketi.User = "admin"
+	}
+
+	if p.AddOns.LocalVolumeProvisioner != nil {
+		for i := range p.AddOns.LocalVolumeProvisioner.StorageClasses {
+			sc := &p.AddOns.LocalVolumeProvisioner.StorageClasses[i]
+			if sc.HostDir == "" {
+				sc.HostDir = path.Join("/mnt/local-storage", sc.Name)
+			}
+			if sc.VolumeMode == "" {
+				sc.VolumeMode = "Filesystem"
+			}
+			if sc.ReclaimPolicy == "" {
+				sc.ReclaimPolicy = "Delete"
+			}
+		}
+	}
 }
 
 var yamlKeyRE = regexp.MustCompile(`[^a-zA-Z]*([a-z_\-\/A-Z.\d]+)[ ]*:`)
@@ -45,6 +45,26 @@ func serviceTypes() []string {
 	return []string{"ClusterIP", "NodePort", "LoadBalancer", "ExternalName"}
 }
 
+func azureVMTypes() []string {
+	return []string{"standard", "vmss"}
+}
+
+func azureLoadBalancerSKUs() []string {
+	return []string{"basic", "standard"}
+}
+
+func vsphereDiskFormats() []string {
+	return []string{"thin", "zeroedthick", "eagerzeroedthick"}
+}
+
+func localVolumeModes() []string {
+	return []string{"Filesystem", "Block"}
+}
+
+func localVolumeReclaimPolicies() []string {
+	return []string{"Delete", "Retain"}
+}
+
 func cloudProviders() []string {
 	return []string{"aws", "azure", "cloudstack", "fake", "gce", "mesos", "openstack", "ovirt", "photon", "rackspace", "vsphere"}
 }
@@ -68,6 +88,8 @@ type Plan struct {
 	DockerRegistry DockerRegistry `yaml:"docker_registry"`
 	// A set of files or directories to copy from the local machine to any of the nodes in the cluster.
 	AdditionalFiles []AdditionalFile `yaml:"additional_files"`
+	// Configuration for copying the additional files to the nodes over constrained links.
+	AssetDistribution AssetDistribution `yaml:"asset_distribution,omitempty"`
 	// Add on configuration
 	AddOns AddOns `yaml:"add_ons"`
 	// Feature configuration
@@ -88,6 +110,9 @@ type Plan struct {
 	Storage OptionalNodeGroup
 	// NFS volumes of the cluster.
 	NFS *NFS `yaml:"nfs,omitempty"`
+	// Namespaces that are created during the installation, along with their
+	// resource quotas, limit ranges and role bindings.
+	Tenants []Tenant `yaml:"tenants,omitempty"`
 }
 
 // Cluster describes a Kubernetes cluster
@@ -99,6 +124,8 @@ type Cluster struct {
 	// The Kubernetes version to install.
 	// If left blank will be set to the latest tested version.
 	// Only a single Minor version is supported with.
+	// The version must start with "v", and be a release without pre-release
+	// or build metadata, such as v1.10.3.
 	// +default=v1.10.3
 	Version string
 	// The password for the admin user.
@@ -106,6 +133,19 @@ type Cluster struct {
 	// This field will be removed completely in a future release.
 	// +deprecated
 	AdminPassword string `yaml:"admin_password,omitempty"`
+	// A reference to the password for the admin user in a secrets manager,
+	// used instead of admin_password. The password is fetched when KET runs,
+	// and is never written to disk.
+	// +deprecated
+	AdminPasswordSecret *SecretReference `yaml:"admin_password_secret,omitempty"`
+	// Whether KET should generate the password for the admin user, instead of
+	// reading it from the plan file. The password is stored in the generated
+	// assets directory, encrypted with the key set in the
+	// `KISMATIC_CREDENTIALS_KEY` environment variable, and is displayed with
+	// `kismatic credentials show`.
+	GenerateAdminPassword bool `yaml:"generate_admin_password,omitempty"`
+	// The authentication of the users of the cluster.
+	Authentication Authentication `yaml:"authentication,omitempty"`
 	// Whether KET should install the packages on the cluster nodes.
 	// When true, KET will not install the required packages.
 	// Instead, it will verify that the packages have been installed by the operator.
@@ -119,6 +159,18 @@ type Cluster struct {
 	// registry are required for installation.
 	// +default=false
 	DisconnectedInstallation bool `yaml:"disconnected_installation"`
+	// Whether the cluster uses FIPS 140-2 compliant cryptography.
+	// When set to `true`, KET only accepts and generates RSA keys, restricts
+	// the TLS versions and cipher suites of the Kubernetes components, and
+	// verifies that the nodes are running in FIPS mode.
+	// +default=false
+	FIPS bool `yaml:"fips,omitempty"`
+	// Whether the nodes were provisioned from a machine image built with
+	// `kismatic image build`. When set to `true`, KET does not install the
+	// packages, and verifies that the nodes were built for the Kubernetes
+	// version of the cluster.
+	// +default=false
+	PrebuiltNodeImage bool `yaml:"prebuilt_node_image,omitempty"`
 	// The Networking configuration for the cluster.
 	Networking NetworkConfig
 	// The Certificates configuration for the cluster.
@@ -137,6 +189,72 @@ type Cluster struct {
 	KubeletOptions KubeletOptions `yaml:"kubelet"`
 	// The CloudProvider configuration for the cluster.
 	CloudProvider CloudProvider `yaml:"cloud_provider"`
+	// Alternate locations of the binaries that KET copies to the cluster nodes.
+	// Useful for hosting the binaries on an internal mirror.
+	Artifacts Artifacts `yaml:"artifacts,omitempty"`
+	// The cluster information file that is written to the generated assets
+	// directory after the installation, for use by external tooling.
+	ClusterInfo ClusterInfoOptions `yaml:"cluster_info,omitempty"`
+	// The credential providers used by the kubelet to pull images from private
+	// cloud registries, such as ECR, GCR and ACR, without image pull secrets.
+	ImageCredentialProviders []ImageCredentialProvider `yaml:"image_credential_providers,omitempty"`
+	// The number of nodes that are configured at the same time. Useful for
+	// speeding up the installation of large clusters.
+	Parallelism Parallelism `yaml:"parallelism,omitempty"`
+}
+
+// Parallelism configures the number of nodes on which the playbooks run at
+// the same time
+type Parallelism struct {
+	// The number of nodes on which each task of the playbooks runs at the same time.
+	// +default=50
+	Forks int `yaml:"forks,omitempty"`
+	// The number of nodes, or the percentage of the nodes such as "25%", that each
+	// play configures before moving on to the next nodes.
+	// The plays that must configure one node at a time, such as the upgrade of
+	// the etcd nodes, are not affected.
+	// +default=100%
+	Serial string `yaml:"serial,omitempty"`
+	// The number of nodes, or the percentage of the nodes, that specific plays
+	// configure at the same time, by the file name of the play, such as _docker.yaml.
+	// Takes precedence over serial.
+	PlaySerial map[string]string `yaml:"play_serial,omitempty"`
+}
+
+// ImageCredentialProvider configures a docker credential helper that provides
+// the kubelet with the credentials of private cloud registries. The credentials
+// are periodically refreshed on each node, and written to the docker config
+// file of the kubelet.
+type ImageCredentialProvider struct {
+	// The type of the registries. The docker-credential-ecr-login, docker-credential-gcr
+	// and docker-credential-acr-env helpers are used for ecr, gcr and acr respectively.
+	// +required
+	// +options=ecr,gcr,acr
+	Type string
+	// The registries to get credentials for, such as 123456789012.dkr.ecr.us-east-1.amazonaws.com.
+	// +required
+	Registries []string
+	// The credential helper binary that is copied to the nodes.
+	// When not set, the helper must be installed in the PATH of the nodes.
+	Helper Artifact `yaml:"helper,omitempty"`
+	// How often the credentials are refreshed. Must be shorter than the
+	// lifetime of the registry tokens.
+	// +default=30m
+	RefreshInterval string `yaml:"refresh_interval,omitempty"`
+}
+
+// ClusterInfoOptions configures the publishing of the cluster information file
+type ClusterInfoOptions struct {
+	// The location where the cluster information file is published after the installation.
+	// When set to an http or https URL, the file is sent in a PUT request.
+	// When set to an S3 path, such as s3://bucket/clusters/prod.json, the file is uploaded
+	// using the AWS credentials set in the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+	// AWS_SESSION_TOKEN environment variables of the machine running KET.
+	// The file is not published when left blank.
+	PublishURL string `yaml:"publish_url,omitempty"`
+	// The AWS region of the S3 bucket.
+	// Required when publishing to an S3 path.
+	S3Region string `yaml:"s3_region,omitempty"`
 }
 
 type APIServerOptions struct {
@@ -217,15 +335,93 @@ type CertsConfig struct {
 type SSHConfig struct {
 	// The user for accessing the cluster nodes via SSH.
 	// This user requires sudo elevation privileges on the cluster nodes.
+	// When a service account is set, this user is only used for creating the
+	// service account on the nodes.
 	// +required
 	User string
 	// The absolute path of the SSH key that should be used for accessing the
 	// cluster nodes via SSH.
-	// +required
+	// Required unless ssh_key_secret is set.
 	Key string `yaml:"ssh_key"`
+	// A reference to the SSH private key in a secrets manager, used instead of
+	// ssh_key. The key is fetched when KET runs, and is only held in memory by
+	// an SSH agent running in the KET process.
+	KeySecret *SecretReference `yaml:"ssh_key_secret,omitempty"`
 	// The port number on which cluster nodes are listening for SSH connections.
 	// +required
 	Port int `yaml:"ssh_port"`
+	// A dedicated account that is created on the nodes, and used instead of
+	// the SSH user for all operations once it exists.
+	ServiceAccount *SSHServiceAccount `yaml:"service_account,omitempty"`
+}
+
+// SSHServiceAccount is a dedicated account for KET on the cluster nodes. The
+// account can only log in with the SSH key, and can only run commands as root
+// through the sudo rules created for it.
+type SSHServiceAccount struct {
+	// The name of the account.
+	// +default=kismatic
+	User string
+	// The commands that the account may run as root with sudo. Ansible runs
+	// all of its modules with sudo, so restricting the commands will prevent
+	// KET from operating on the nodes.
+	// +default=['ALL']
+	SudoCommands []string `yaml:"sudo_commands,omitempty"`
+}
+
+// Authentication configures how the users of the cluster authenticate with
+// the API server. Client certificates are always accepted.
+type Authentication struct {
+	// The authentication mode of the users of the cluster.
+	// When not set, basic authentication is used if an admin password is
+	// set, and the Kubernetes version supports it. Otherwise, only client
+	// certificates are accepted.
+	// The token mode generates a token for the admin user, that is displayed
+	// with `kismatic credentials show`.
+	// +options=cert,token,oidc
+	Mode string `yaml:"mode,omitempty"`
+	// The OpenID Connect provider of the users. Required when the mode is oidc.
+	OIDC OIDC `yaml:"oidc,omitempty"`
+}
+
+// OIDC is an OpenID Connect provider that issues the ID tokens of the users
+type OIDC struct {
+	// The URL of the provider, which must use https.
+	// +required
+	IssuerURL string `yaml:"issuer_url"`
+	// The client ID of the cluster with the provider.
+	// +required
+	ClientID string `yaml:"client_id"`
+	// The claim of the ID token that is used as the user name.
+	// +default=sub
+	UsernameClaim string `yaml:"username_claim,omitempty"`
+	// The claim of the ID token that is used as the groups of the user.
+	GroupsClaim string `yaml:"groups_claim,omitempty"`
+}
+
+// SecretReference is a reference to a secret stored in a secrets manager.
+// The credentials for accessing the secrets manager are read from the
+// environment of the machine running KET.
+type SecretReference struct {
+	// The secrets manager that stores the secret.
+	// AWS Secrets Manager uses the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+	// Vault uses the VAULT_ADDR and VAULT_TOKEN environment variables.
+	// Azure Key Vault uses the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET environment variables.
+	// +required
+	// +options=aws_secrets_manager,vault,azure_key_vault
+	Provider string
+	// The name of the secret.
+	// For AWS Secrets Manager, the name or ARN of the secret.
+	// For Vault, the path of the secret, such as secret/data/kismatic.
+	// For Azure Key Vault, the URL of the secret, such as https://myvault.vault.azure.net/secrets/ssh-key.
+	// +required
+	Name string
+	// The field of the secret that holds the value.
+	// Required for Vault. For AWS Secrets Manager, the secret is parsed as a JSON object when set.
+	Field string `yaml:"field,omitempty"`
+	// The AWS region of the secret.
+	// Required for AWS Secrets Manager.
+	Region string `yaml:"region,omitempty"`
 }
 
 // CloudProvider controls the Kubernetes cloud providers feature
@@ -233,8 +429,143 @@ type CloudProvider struct {
 	// The cloud provider that should be set in the Kubernetes components
 	// +options=aws,azure,cloudstack,fake,gce,mesos,openstack,ovirt,photon,rackspace,vsphere
 	Provider string
-	// Path to the cloud provider config file. This will be copied to all the machines in the cluster
+	// Path to the cloud provider config file. This will be copied to all the machines in the cluster.
+	// Required when the provider is azure, cloudstack, openstack or vsphere, unless the azure or
+	// vsphere configuration is set.
 	Config string
+	// The configuration of the Azure cloud provider. When set, the cloud provider config file is
+	// generated from these fields. Cannot be set together with the config file.
+	Azure *AzureCloudProvider `yaml:"azure,omitempty"`
+	// The configuration of the vSphere cloud provider. When set, the cloud provider config file is
+	// generated from these fields, and a default StorageClass is created on the datastore.
+	// Cannot be set together with the config file.
+	VSphere *VSphereCloudProvider `yaml:"vsphere,omitempty"`
+}
+
+// AzureCloudProvider is the configuration of the Azure cloud provider
+type AzureCloudProvider struct {
+	// The Azure cloud of the cluster.
+	// +default=AzurePublicCloud
+	// +options=AzurePublicCloud,AzureChinaCloud,AzureGermanCloud,AzureUSGovernmentCloud
+	Cloud string `yaml:"cloud,omitempty"`
+	// The ID of the Azure Active Directory tenant.
+	// +required
+	TenantID string `yaml:"tenant_id"`
+	// The ID of the subscription of the cluster.
+	// +required
+	SubscriptionID string `yaml:"subscription_id"`
+	// The client ID of the service principal used by the Kubernetes components.
+	// Required unless use_managed_identity is set.
+	ClientID string `yaml:"client_id,omitempty"`
+	// The secret of the service principal.
+	// Required unless use_managed_identity is set.
+	ClientSecret string `yaml:"client_secret,omitempty"`
+	// Whether the Kubernetes components use the managed identity of the virtual machines
+	// instead of a service principal.
+	// +default=false
+	UseManagedIdentity bool `yaml:"use_managed_identity,omitempty"`
+	// The resource group of the cluster nodes.
+	// +required
+	ResourceGroup string `yaml:"resource_group"`
+	// The location of the resource group.
+	// +required
+	Location string
+	// The name of the virtual network of the nodes.
+	// +required
+	VNetName string `yaml:"vnet_name"`
+	// The resource group of the virtual network, when it is not the resource group of the cluster.
+	VNetResourceGroup string `yaml:"vnet_resource_group,omitempty"`
+	// The name of the subnet of the nodes.
+	// +required
+	SubnetName string `yaml:"subnet_name"`
+	// The name of the network security group of the nodes.
+	// +required
+	SecurityGroupName string `yaml:"security_group_name"`
+	// The name of the route table of the subnet.
+	RouteTableName string `yaml:"route_table_name,omitempty"`
+	// The type of the virtual machines of the cluster.
+	// +default=standard
+	// +options=standard,vmss
+	VMType string `yaml:"vm_type,omitempty"`
+	// The name of the availability set of the nodes. Load balancers created by Kubernetes route
+	// to the nodes of this availability set. Only used when vm_type is standard.
+	PrimaryAvailabilitySetName string `yaml:"primary_availability_set_name,omitempty"`
+	// The name of the virtual machine scale set of the nodes. Required when vm_type is vmss.
+	PrimaryScaleSetName string `yaml:"primary_scale_set_name,omitempty"`
+	// The SKU of the load balancers created by Kubernetes.
+	// +default=basic
+	// +options=basic,standard
+	LoadBalancerSKU string `yaml:"load_balancer_sku,omitempty"`
+	// The backend pool of an existing load balancer that fronts the Kubernetes API. The master
+	// nodes are registered with the backend pool before the cluster is installed. Only
+	// supported when vm_type is standard.
+	MasterBackendPool *AzureBackendPool `yaml:"master_backend_pool,omitempty"`
+}
+
+// AzureBackendPool is the backend pool of an Azure load balancer
+type AzureBackendPool struct {
+	// The name of the load balancer, in the resource group of the cluster.
+	// +required
+	LoadBalancer string `yaml:"load_balancer"`
+	// The name of the backend pool.
+	// +required
+	Name string
+}
+
+// VSphereCloudProvider is the configuration of the vSphere cloud provider
+type VSphereCloudProvider struct {
+	// Hostname or IP address of the vCenter server.
+	// +required
+	Server string
+	// Port of the vCenter server.
+	// +default=443
+	Port int `yaml:"port,omitempty"`
+	// The user used by the Kubernetes components to access vCenter.
+	// +required
+	Username string
+	// The password of the user.
+	// +required
+	Password string
+	// Whether to skip the verification of the vCenter server certificate.
+	// +default=false
+	Insecure bool `yaml:"insecure,omitempty"`
+	// The datacenter of the cluster nodes.
+	// +required
+	Datacenter string
+	// The datastore where dynamically provisioned volumes are created.
+	// +required
+	Datastore string
+	// The VM folder of the cluster nodes.
+	// +required
+	Folder string
+	// The name of the default StorageClass.
+	// +default=vsphere
+	StorageClass string `yaml:"storage_class,omitempty"`
+	// The disk format of the volumes provisioned by the default StorageClass.
+	// +default=thin
+	// +options=thin,zeroedthick,eagerzeroedthick
+	DiskFormat string `yaml:"disk_format,omitempty"`
+}
+
+// Artifacts are the binaries that KET copies to the cluster nodes
+type Artifacts struct {
+	// The Kismatic Inspector binary for Linux (amd64), used to run the pre-flight checks on the nodes.
+	// When not set, the binary shipped with KET is used.
+	Inspector Artifact `yaml:"inspector,omitempty"`
+	// The Kuberang binary for Linux (amd64), used to run the smoke test on the cluster.
+	// When not set, the binary shipped with KET is used.
+	Kuberang Artifact `yaml:"kuberang,omitempty"`
+}
+
+// Artifact is the location of a binary that is copied to the cluster nodes
+type Artifact struct {
+	// Path to the binary on the local machine, or an HTTP(S) URL to download it from.
+	// Downloaded binaries are cached in the generated assets directory.
+	Source string `yaml:"source,omitempty"`
+	// The SHA256 checksum of the binary, hex encoded.
+	// When set, the binary is validated against the checksum before it is used.
+	// Recommended when the source is a URL.
+	SHA256 string `yaml:"sha256,omitempty"`
 }
 
 // Docker includes the configuration for the docker installation owned by KET.
@@ -323,6 +654,27 @@ type AdditionalFile struct {
 	SkipValidation bool `yaml:"skip_validation"`
 }
 
+// AssetDistribution is the configuration for copying the additional files to
+// the nodes. When the bandwidth is limited or peer distribution is enabled, the
+// files are copied with rsync, which must be installed on the local machine.
+type AssetDistribution struct {
+	// The maximum bandwidth, in KiB per second, used by each copy of the additional files.
+	// When not set, the bandwidth is not limited.
+	BandwidthLimit int `yaml:"bandwidth_limit,omitempty"`
+	// Set to true to copy the additional files from the local machine to a single node of each site,
+	// which then copies them to the other nodes of the site.
+	// The nodes of a site must be able to reach the first node of the site on the asset distribution port.
+	PeerDistribution bool `yaml:"peer_distribution,omitempty"`
+	// The port used by the first node of each site to copy the additional files to the other nodes of the site.
+	// +default=8730
+	Port int `yaml:"port,omitempty"`
+}
+
+// Enabled returns true when the additional files are copied with rsync
+func (a AssetDistribution) Enabled() bool {
+	return a.BandwidthLimit > 0 || a.PeerDistribution
+}
+
 // DockerRegistry details for docker registry, either confgiured by the cli or customer provided
 type DockerRegistry struct {
 	// The hostname or IP address and port of a private container image registry.
@@ -375,6 +727,14 @@ type AddOns struct {
 	// Because the Rescheduler does not have leader election and therefore can only run as a single instance in a cluster, it will be deployed as a static pod on the first master.
 	// More information about the Rescheduler can be found here: https://kubernetes.io/docs/tasks/administer-cluster/guaranteed-scheduling-critical-addon-pods/
 	Rescheduler Rescheduler `yaml:"rescheduler"`
+	// The local volume provisioner add-on configuration.
+	// The provisioner creates a PersistentVolume for every local volume declared on the nodes,
+	// which makes the local disks of bare metal nodes available to workloads through StorageClasses.
+	LocalVolumeProvisioner *LocalVolumeProvisioner `yaml:"local_volume_provisioner,omitempty"`
+	// The Heketi add-on configuration.
+	// Points the cluster at an existing GlusterFS cluster that is managed by Heketi,
+	// instead of storage nodes that are managed by KET.
+	Heketi *Heketi `yaml:"heketi,omitempty"`
 }
 
 // Features configuration
@@ -463,6 +823,15 @@ type DNSOptions struct {
 	// Number of cluster DNS replicas that should be scheduled on the cluster.
 	// +default=2
 	Replicas int
+	// The DNS servers, as IP or IP:port, that the queries for the names outside of the cluster are forwarded to,
+	// instead of the resolvers in /etc/resolv.conf of the nodes. Use internal resolvers in disconnected installations.
+	// Up to 3 servers are supported by kubedns.
+	UpstreamServers []string `yaml:"upstream_servers,omitempty"`
+	// Whether the queries for the names outside of the cluster are never forwarded to the resolvers in /etc/resolv.conf
+	// of the nodes. The names outside of the cluster are only resolved by the upstream servers, if any.
+	// Only supported by coredns.
+	// +default=false
+	BlockExternal bool `yaml:"block_external,omitempty"`
 }
 
 // The HeapsterMonitoring add-on configuration
@@ -574,6 +943,79 @@ type Rescheduler struct {
 	Disable bool
 }
 
+// LocalVolumeProvisioner add-on configuration
+type LocalVolumeProvisioner struct {
+	// Whether the local volume provisioner add-on should be disabled.
+	// When set to true, the local volume provisioner will not be installed on the cluster.
+	// +default=false
+	Disable bool
+	// The StorageClasses of the local volumes. The local volumes of the nodes
+	// are assigned to one of these StorageClasses.
+	// +required
+	StorageClasses []LocalStorageClass `yaml:"storage_classes"`
+}
+
+// LocalStorageClass is a StorageClass of local volumes
+type LocalStorageClass struct {
+	// The name of the StorageClass.
+	// +required
+	Name string
+	// The directory on the nodes where the provisioner discovers the local volumes of the StorageClass.
+	// KET mounts (or links, for block devices) the local volumes of the nodes in this directory.
+	// +default=/mnt/local-storage/<name>
+	HostDir string `yaml:"host_dir"`
+	// Whether the local volumes of the StorageClass are mounted filesystems or raw block devices.
+	// Block volumes require the BlockVolume feature gate to be enabled on the cluster.
+	// +default=Filesystem
+	// +options=Filesystem,Block
+	VolumeMode string `yaml:"volume_mode"`
+	// The reclaim policy of the PersistentVolumes. When set to Delete, the content
+	// of a volume is removed when it is released.
+	// +default=Delete
+	// +options=Delete,Retain
+	ReclaimPolicy string `yaml:"reclaim_policy"`
+	// Whether this StorageClass should be the default StorageClass of the cluster.
+	// +default=false
+	Default bool
+}
+
+// LocalVolume is a local disk of a node that is made available through a StorageClass
+type LocalVolume struct {
+	// The name of the local StorageClass the volume belongs to.
+	// +required
+	StorageClass string `yaml:"storage_class"`
+	// The absolute path to the volume on the node. For Filesystem StorageClasses,
+	// the path is a directory, usually the mount point of a local disk.
+	// For Block StorageClasses, the path is a block device, such as /dev/nvme0n1.
+	// +required
+	Path string
+}
+
+// Heketi add-on configuration
+type Heketi struct {
+	// Whether the Heketi add-on should be disabled.
+	// When set to true, the cluster will not be configured to use the external GlusterFS cluster.
+	// +default=false
+	Disable bool
+	// The URL of the Heketi REST API, reachable from the master nodes.
+	// +required
+	URL string
+	// The Heketi user used to create and delete volumes.
+	// +default=admin
+	User string
+	// The secret key of the Heketi user. Leave empty when Heketi authentication is disabled.
+	Secret string
+	// The ID of the Heketi cluster where volumes are created.
+	// Leave empty to let Heketi pick one of its clusters.
+	ClusterID string `yaml:"cluster_id"`
+	// The name of the StorageClass that dynamically provisions GlusterFS volumes through Heketi.
+	// Leave empty to not create the StorageClass.
+	StorageClass string `yaml:"storage_class"`
+	// Whether the StorageClass should be the default StorageClass of the cluster.
+	// +default=false
+	DefaultStorageClass bool `yaml:"default_storage_class"`
+}
+
 type DeprecatedPackageManager struct {
 	// Whether the package manager add-on should be enabled.
 	// +deprecated
@@ -638,6 +1080,13 @@ type Node struct {
 	// Kubelet configuration applied to this node.
 	// If a node is repeated for multiple roles, the overrides cannot be different.
 	KubeletOptions KubeletOptions `yaml:"kubelet,omitempty"`
+	// Local volumes of the node that are managed by the local volume provisioner add-on.
+	// If a node is repeated for multiple roles, the local volumes cannot be different.
+	LocalVolumes []LocalVolume `yaml:"local_volumes,omitempty"`
+	// The site, such as the datacenter, of the node.
+	// When peer distribution of the additional files is enabled, the files are copied
+	// from the local machine to a single node of each site. The nodes without a site belong to the same site.
+	Site string `yaml:"site,omitempty"`
 }
 
 // Taint for nodes
@@ -688,6 +1137,51 @@ type NFSVolume struct {
 	Path string `yaml:"mount_path"`
 }
 
+// Tenant is a namespace that is handed over to a team, along with the
+// resources it may use and the users that may access it
+type Tenant struct {
+	// The name of the namespace of the tenant.
+	// +required
+	Namespace string
+	// Labels that are added to the namespace.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// The hard limits of the ResourceQuota of the namespace, such as
+	// requests.cpu, limits.memory or pods.
+	// No ResourceQuota is created when empty.
+	Quota map[string]string `yaml:"quota,omitempty"`
+	// The LimitRange of the containers in the namespace.
+	// No LimitRange is created when not set.
+	LimitRange *TenantLimitRange `yaml:"limit_range,omitempty"`
+	// The RoleBindings that grant access to the namespace.
+	RoleBindings []TenantRoleBinding `yaml:"role_bindings,omitempty"`
+}
+
+// TenantLimitRange is the LimitRange of the containers in a tenant namespace
+type TenantLimitRange struct {
+	// The resource limits of containers that do not set them, such as cpu or memory.
+	Default map[string]string `yaml:"default,omitempty"`
+	// The resource requests of containers that do not set them.
+	DefaultRequest map[string]string `yaml:"default_request,omitempty"`
+	// The maximum resources of a container.
+	Max map[string]string `yaml:"max,omitempty"`
+	// The minimum resources of a container.
+	Min map[string]string `yaml:"min,omitempty"`
+}
+
+// TenantRoleBinding grants a ClusterRole in a tenant namespace
+type TenantRoleBinding struct {
+	// The ClusterRole that is granted in the namespace, such as admin, edit or view.
+	// +required
+	ClusterRole string `yaml:"cluster_role"`
+	// The users that are granted the role.
+	Users []string `yaml:"users,omitempty"`
+	// The groups that are granted the role.
+	Groups []string `yaml:"groups,omitempty"`
+	// The service accounts that are granted the role, in the namespace:name format.
+	// Service accounts without a namespace are in the tenant namespace.
+	ServiceAccounts []string `yaml:"service_accounts,omitempty"`
+}
+
 // StorageVolume managed by Kismatic
 type StorageVolume struct {
 	// Name of the storage volume
@@ -733,6 +1227,25 @@ func (p *Plan) GetUniqueNodes() []Node {
 	return nodes
 }
 
+// heketiEnabled returns true when the volumes of the cluster are managed by an external Heketi
+func (p *Plan) heketiEnabled() bool {
+	return p.AddOns.Heketi != nil && !p.AddOns.Heketi.Disable
+}
+
+// localStorageClass returns the local StorageClass with the given name, or nil
+// if the local volume provisioner does not define it
+func (p *Plan) localStorageClass(name string) *LocalStorageClass {
+	if p.AddOns.LocalVolumeProvisioner == nil {
+		return nil
+	}
+	for i, sc := range p.AddOns.LocalVolumeProvisioner.StorageClasses {
+		if sc.Name == name {
+			return &p.AddOns.LocalVolumeProvisioner.StorageClasses[i]
+		}
+	}
+	return nil
+}
+
 func (p *Plan) getAllNodes() []Node {
 	nodes := []Node{}
 	nodes = append(nodes, p.Etcd.Nodes...)
@@ -833,7 +1346,18 @@ func (p *Plan) GetSSHConnection(host string) (*SSHConnection, error) {
 		return nil, notFoundErr
 	}
 
-	return &SSHConnection{&p.Cluster.SSH, foundNode}, nil
+	sshConfig := p.Cluster.SSH.operationConfig()
+	return &SSHConnection{&sshConfig, foundNode}, nil
+}
+
+// operationConfig returns the SSH configuration used for operating on the
+// nodes. KET connects as the service account when one is set, and only uses
+// the SSH user for creating the service account.
+func (s SSHConfig) operationConfig() SSHConfig {
+	if s.ServiceAccount != nil {
+		s.User = s.ServiceAccount.User
+	}
+	return s
 }
 
 // GetSSHClient is a convience method that calls GetSSHConnection and returns an SSH client with the result
@@ -13,3 +13,27 @@ func TestCanReadAPIServerOverrides(t *testing.T) {
 
 	assertEqual(t, p.Cluster.APIServerOptions.Overrides["runtime-config"], "beta/v2api=true,alpha/v1api=true")
 }
+
+func TestSSHServiceAccountIsUsedForOperations(t *testing.T) {
+	p := validPlan()
+	p.Cluster.SSH.User = "ubuntu"
+	p.Cluster.SSH.ServiceAccount = &SSHServiceAccount{User: "kismatic", SudoCommands: []string{"ALL"}}
+
+	con, err := p.GetSSHConnection("master")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertEqual(t, con.SSHConfig.User, "kismatic")
+	assertEqual(t, p.Cluster.SSH.User, "ubuntu")
+
+	for _, role := range buildInventoryFromPlan(&p).Roles {
+		for _, n := range role.Nodes {
+			assertEqual(t, n.SSHUser, "kismatic")
+		}
+	}
+	for _, role := range buildInventoryWithSSHConfig(&p, p.Cluster.SSH).Roles {
+		for _, n := range role.Nodes {
+			assertEqual(t, n.SSHUser, "ubuntu")
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// resolvedPlay is a play of a playbook, once the includes that lead to it
+// are resolved
+type resolvedPlay struct {
+	// Name of the play, with the play_name variable of the includes applied
+	Name string `yaml:"name"`
+	// Hosts is the host pattern the play runs on
+	Hosts string `yaml:"hosts"`
+	// File is the playbook file that defines the play
+	File string `yaml:"file"`
+	// When are the conditions of the includes that lead to the play. The play
+	// is skipped on the hosts where any of them is false.
+	When []string `yaml:"when,omitempty"`
+	// Vars are the variables set by the includes that lead to the play
+	Vars map[string]string `yaml:"vars,omitempty"`
+	// Tags of the play, and of the includes that lead to it
+	Tags []string `yaml:"tags,omitempty"`
+}
+
+// Play is a playbook that can be run on its own with RunPlay
+type Play struct {
+	// Name of the playbook, such as _docker.yaml
+	Name string `json:"name"`
+	// Description of the playbook, from the comment at the top of the file,
+	// or the name of its first play
+	Description string `json:"description"`
+	// Plays are the names of the plays of the playbook, in the order they
+	// are run
+	Plays []string `json:"plays"`
+	// Tags are the tags of the plays of the playbook, which select the plays
+	// that are run
+	Tags []string `json:"tags,omitempty"`
+}
+
+// ListPlays returns the playbooks of the directory, sorted by name
+func ListPlays(dir string) ([]Play, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading playbooks directory: %v", err)
+	}
+	var plays []Play
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".yaml" {
+			continue
+		}
+		resolved, err := resolvePlaybook(dir, f.Name())
+		if err != nil {
+			return nil, err
+		}
+		play := Play{Name: f.Name()}
+		tags := map[string]bool{}
+		for _, r := range resolved {
+			play.Plays = append(play.Plays, r.Name)
+			for _, t := range r.Tags {
+				if !tags[t] {
+					tags[t] = true
+					play.Tags = append(play.Tags, t)
+				}
+			}
+		}
+		sort.Strings(play.Tags)
+		if play.Description, err = playbookComment(filepath.Join(dir, f.Name())); err != nil {
+			return nil, err
+		}
+		if play.Description == "" && len(play.Plays) > 0 {
+			play.Description = play.Plays[0]
+		}
+		plays = append(plays, play)
+	}
+	return plays, nil
+}
+
+// playbookComment returns the comment at the top of the playbook, before its
+// first entry
+func playbookComment(file string) (string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading playbook %q: %v", file, err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "---" || line == "" && len(lines) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+	}
+	return strings.Join(lines, " "), nil
+}
+
+// playNameDefault matches the play names that can be overridden with the
+// play_name variable
+var playNameDefault = regexp.MustCompile(`^\{\{\s*play_name\s*\|\s*default\(['"](.*)['"]\)\s*\}\}$`)
+
+// resolvePlaybook returns the plays of the playbook in the directory, in the
+// order they are run, following the includes of other playbooks
+func resolvePlaybook(dir, playbook string) ([]resolvedPlay, error) {
+	return resolveIncludedPlaybook(dir, playbook, nil, nil, map[string]string{}, 0)
+}
+
+func resolveIncludedPlaybook(dir, playbook string, when, tags []string, vars map[string]string, depth int) ([]resolvedPlay, error) {
+	// guards against includes that loop
+	if depth > 10 {
+		return nil, fmt.Errorf("the playbook %q is included more than 10 levels deep", playbook)
+	}
+	file := filepath.Join(dir, playbook)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading playbook %q: %v", file, err)
+	}
+	var entries []map[string]interface{}
+	if err := yaml.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing playbook %q: %v", file, err)
+	}
+	var plays []resolvedPlay
+	for _, e := range entries {
+		entryWhen := when
+		if cond, ok := e["when"]; ok {
+			entryWhen = append(append([]string{}, when...), fmt.Sprintf("%v", cond))
+		}
+		entryTags := tags
+		if t, ok := e["tags"]; ok {
+			entryTags = append(append([]string{}, tags...), parseTags(t)...)
+		}
+		if include, ok := e["include"]; ok {
+			included, includeVars, err := parseInclude(fmt.Sprintf("%v", include))
+			if err != nil {
+				return nil, fmt.Errorf("error parsing playbook %q: %v", file, err)
+			}
+			merged := map[string]string{}
+			for k, v := range vars {
+				merged[k] = v
+			}
+			for k, v := range includeVars {
+				merged[k] = v
+			}
+			p, err := resolveIncludedPlaybook(dir, included, entryWhen, entryTags, merged, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			plays = append(plays, p...)
+			continue
+		}
+		hosts, ok := e["hosts"]
+		if !ok {
+			continue
+		}
+		play := resolvedPlay{
+			Name:  fmt.Sprintf("%v", e["name"]),
+			Hosts: fmt.Sprintf("%v", hosts),
+			File:  playbook,
+			When:  entryWhen,
+			Tags:  entryTags,
+		}
+		if m := playNameDefault.FindStringSubmatch(play.Name); m != nil {
+			play.Name = m[1]
+			if name, ok := vars["play_name"]; ok {
+				play.Name = name
+			}
+		}
+		if len(vars) > 0 {
+			play.Vars = vars
+		}
+		plays = append(plays, play)
+	}
+	return plays, nil
+}
+
+// parseTags returns the tags of an entry, which are either a list or a
+// comma-separated string
+func parseTags(v interface{}) []string {
+	var tags []string
+	switch t := v.(type) {
+	case []interface{}:
+		for _, tag := range t {
+			tags = append(tags, fmt.Sprintf("%v", tag))
+		}
+	default:
+		for _, tag := range strings.Split(fmt.Sprintf("%v", t), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// parseInclude returns the playbook and the variables of an include, such as
+// `_weave.yaml play_name="Upgrade Weave" serial_count="1"`
+func parseInclude(include string) (string, map[string]string, error) {
+	fields, err := splitQuoted(include)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(fields) == 0 {
+		return "", nil, fmt.Errorf("empty include")
+	}
+	vars := map[string]string{}
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return "", nil, fmt.Errorf("invalid variable %q in include %q", f, include)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	return fields[0], vars, nil
+}
+
+// splitQuoted splits the string on spaces, except for the spaces within
+// double or single quotes. The quotes are removed.
+func splitQuoted(s string) ([]string, error) {
+	var fields []string
+	var current []rune
+	var quote rune
+	inField := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current = append(current, r)
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, string(current))
+				current = nil
+				inField = false
+			}
+		default:
+			current = append(current, r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inField {
+		fields = append(fields, string(current))
+	}
+	return fields, nil
+}
@@ -0,0 +1,178 @@
+package install
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writePlaybooks(t *testing.T, playbooks map[string]string) string {
+	dir := mustGetTempDir(t)
+	for name, content := range playbooks {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("error writing playbook: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestResolvePlaybook(t *testing.T) {
+	dir := writePlaybooks(t, map[string]string{
+		"main.yaml": `---
+  - include: _all.yaml
+  - include: _docker.yaml play_name="Upgrade Docker" upgrading=true
+    when: docker.enabled|bool == true
+  - hosts: master
+    name: Validate Control Plane
+`,
+		"_all.yaml": `---
+  - hosts: all
+    name: Gather Facts
+`,
+		"_docker.yaml": `---
+  - hosts: master:worker
+    name: "{{ play_name | default('Install Docker') }}"
+    roles:
+      - docker
+`,
+	})
+	plays, err := resolvePlaybook(dir, "main.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []resolvedPlay{
+		{Name: "Gather Facts", Hosts: "all", File: "_all.yaml"},
+		{
+			Name:  "Upgrade Docker",
+			Hosts: "master:worker",
+			File:  "_docker.yaml",
+			When:  []string{"docker.enabled|bool == true"},
+			Vars:  map[string]string{"play_name": "Upgrade Docker", "upgrading": "true"},
+		},
+		{Name: "Validate Control Plane", Hosts: "master", File: "main.yaml"},
+	}
+	if !reflect.DeepEqual(plays, expected) {
+		t.Errorf("expected %+v, got %+v", expected, plays)
+	}
+}
+
+func TestResolvePlaybookDefaultPlayName(t *testing.T) {
+	dir := writePlaybooks(t, map[string]string{
+		"main.yaml": `---
+  - include: _docker.yaml
+`,
+		"_docker.yaml": `---
+  - hosts: worker
+    name: "{{ play_name | default('Install Docker') }}"
+`,
+	})
+	plays, err := resolvePlaybook(dir, "main.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plays) != 1 || plays[0].Name != "Install Docker" {
+		t.Errorf("expected the default play name, got %+v", plays)
+	}
+}
+
+func TestResolvePlaybookErrors(t *testing.T) {
+	dir := writePlaybooks(t, map[string]string{
+		"missing.yaml": `---
+  - include: _missing.yaml
+`,
+		"loop.yaml": `---
+  - include: loop.yaml
+`,
+		"quote.yaml": `---
+  - include: _docker.yaml play_name="Upgrade Docker
+`,
+	})
+	for _, playbook := range []string{"missing.yaml", "loop.yaml", "quote.yaml", "none.yaml"} {
+		if _, err := resolvePlaybook(dir, playbook); err == nil {
+			t.Errorf("%s: expected an error", playbook)
+		}
+	}
+}
+
+func TestResolveKismaticPlaybooks(t *testing.T) {
+	for _, playbook := range []string{"kubernetes.yaml", "upgrade-nodes.yaml", "reset.yaml"} {
+		plays, err := resolvePlaybook(filepath.Join("..", "..", "ansible"), playbook)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", playbook, err)
+			continue
+		}
+		if len(plays) == 0 {
+			t.Errorf("%s: expected plays", playbook)
+		}
+	}
+}
+
+func TestListPlays(t *testing.T) {
+	dir := writePlaybooks(t, map[string]string{
+		"main.yaml": `---
+  # Installs docker
+  # on all the nodes
+  - include: _docker.yaml play_name="Upgrade Docker"
+    tags: upgrade
+`,
+		"_docker.yaml": `---
+  - hosts: master:worker
+    name: "{{ play_name | default('Install Docker') }}"
+    tags:
+      - docker
+      - packages
+`,
+		"ansible.cfg": "[defaults]",
+	})
+	plays, err := ListPlays(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Play{
+		{
+			Name:        "_docker.yaml",
+			Description: "Install Docker",
+			Plays:       []string{"Install Docker"},
+			Tags:        []string{"docker", "packages"},
+		},
+		{
+			Name:        "main.yaml",
+			Description: "Installs docker on all the nodes",
+			Plays:       []string{"Upgrade Docker"},
+			Tags:        []string{"docker", "packages", "upgrade"},
+		},
+	}
+	if !reflect.DeepEqual(plays, expected) {
+		t.Errorf("expected the plays %+v, got %+v", expected, plays)
+	}
+}
+
+func TestListKismaticPlays(t *testing.T) {
+	plays, err := ListPlays(filepath.Join("..", "..", "ansible"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range plays {
+		if p.Description == "" {
+			t.Errorf("%s: expected a description", p.Name)
+		}
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	playbook, vars, err := parseInclude(`_weave.yaml play_name="Upgrade Weave Cluster Network" serial_count='1'`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if playbook != "_weave.yaml" {
+		t.Errorf("expected _weave.yaml, got %q", playbook)
+	}
+	expected := map[string]string{"play_name": "Upgrade Weave Cluster Network", "serial_count": "1"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("expected %v, got %v", expected, vars)
+	}
+	if _, _, err := parseInclude("_weave.yaml upgrading"); err == nil {
+		t.Errorf("expected an error for a variable without a value")
+	}
+}
@@ -0,0 +1,137 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/inspector/rule"
+)
+
+const (
+	preflightReportFilename     = "preflight-report.json"
+	defaultPreflightParallelism = 10
+)
+
+// PreflightReport is the consolidated result of running the pre-flight
+// checks on the nodes of the cluster
+type PreflightReport struct {
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	// Nodes are the results of each node, in the order they completed
+	Nodes []PreflightNodeResult `json:"nodes"`
+}
+
+// PreflightNodeResult is the result of running the pre-flight checks on a node
+type PreflightNodeResult struct {
+	Host    string `json:"host"`
+	Success bool   `json:"success"`
+	// Error is the reason the node failed the pre-flight checks
+	Error string `json:"error,omitempty"`
+	// EndTime is when the checks completed on the node
+	EndTime time.Time `json:"endTime"`
+	// Checks are the results of the checks run by the inspector on the node
+	Checks []rule.Result `json:"checks,omitempty"`
+	// FailedTasks are the names of the pre-flight tasks that failed on the node
+	FailedTasks []string `json:"failedTasks,omitempty"`
+	// RunDirectory is where the ansible log of the node is kept
+	RunDirectory string `json:"runDirectory"`
+}
+
+// FailedNodes returns the hosts of the nodes that failed the pre-flight checks
+func (r PreflightReport) FailedNodes() []string {
+	failed := []string{}
+	for _, n := range r.Nodes {
+		if !n.Success {
+			failed = append(failed, n.Host)
+		}
+	}
+	return failed
+}
+
+func (r PreflightReport) write(dir string) (string, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling pre-flight report: %v", err)
+	}
+	file := filepath.Join(dir, preflightReportFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return "", fmt.Errorf("error writing pre-flight report to %s: %v", file, err)
+	}
+	return file, nil
+}
+
+// preflightResultsRecorder records the results of the inspector checks, and
+// the pre-flight tasks that failed on a node
+type preflightResultsRecorder struct {
+	mu          sync.Mutex
+	currentTask string
+	checks      []rule.Result
+	failedTasks []string
+}
+
+func (r *preflightResultsRecorder) observe(e ansible.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch event := e.(type) {
+	case *ansible.TaskStartEvent:
+		r.currentTask = event.Name
+	case *ansible.RunnerOKEvent:
+		r.recordChecks(event.Result.Stdout)
+	case *ansible.RunnerFailedEvent:
+		r.recordChecks(event.Result.Stdout)
+		if !event.IgnoreErrors {
+			r.failedTasks = append(r.failedTasks, r.currentTask)
+		}
+	case *ansible.RunnerItemFailedEvent:
+		if !event.IgnoreErrors {
+			r.failedTasks = append(r.failedTasks, r.currentTask)
+		}
+	case *ansible.RunnerUnreachableEvent:
+		r.failedTasks = append(r.failedTasks, r.currentTask)
+	}
+}
+
+// recordChecks records the checks when the output is the JSON output of the
+// inspector
+func (r *preflightResultsRecorder) recordChecks(stdout string) {
+	results := []rule.Result{}
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil || len(results) == 0 {
+		return
+	}
+	r.checks = results
+}
+
+// finish returns the result of the node, given the error returned by the
+// pre-flight task
+func (r *preflightResultsRecorder) finish(host string, err error) PreflightNodeResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := PreflightNodeResult{
+		Host:        host,
+		Success:     err == nil,
+		EndTime:     time.Now(),
+		Checks:      r.checks,
+		FailedTasks: uniqueStrings(r.failedTasks),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func uniqueStrings(s []string) []string {
+	var u []string
+	seen := map[string]bool{}
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			u = append(u, v)
+		}
+	}
+	return u
+}
@@ -0,0 +1,95 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// the roles of the nodes that can be removed from the cluster
+var removableRoles = []string{"worker", "ingress"}
+
+// RemoveNode removes a worker node from the cluster described in the plan.
+// The node is cordoned, and drained when requested, before it is deleted from
+// the Kubernetes API and reset. If successful, the updated plan is returned.
+// The summary of the tasks that were run is returned whether the node was
+// removed or not.
+func (ae *ansibleExecutor) RemoveNode(plan *Plan, host string, drain bool) (*Plan, RunSummary, error) {
+	updatedPlan, err := RemoveNodeFromPlan(*plan, host)
+	if err != nil {
+		return nil, RunSummary{}, err
+	}
+	cc, err := ae.buildClusterCatalog(plan)
+	if err != nil {
+		return nil, RunSummary{}, err
+	}
+	// the catalog is shared with the other tasks of the plan
+	catalog := *cc
+	catalog.RemoveNode.Host = host
+	catalog.RemoveNode.Drain = drain
+	t := task{
+		name:           "remove-node",
+		playbook:       "remove-node.yaml",
+		plan:           *plan,
+		inventory:      ae.buildInventory(plan),
+		clusterCatalog: catalog,
+		explainer:      ae.defaultExplainer(),
+	}
+	util.PrintHeader(ae.stdout, "Removing Node From the Cluster", '=')
+	summary, err := ae.execute(t)
+	if err != nil {
+		return nil, summary, fmt.Errorf("error removing node %q from the cluster: %v", host, err)
+	}
+	resetSummary, err := ae.Reset(plan, ResetOptions{}, host)
+	summary = summary.Merge(resetSummary)
+	if err != nil {
+		return nil, summary, fmt.Errorf("error resetting node %q: %v", host, err)
+	}
+	return &updatedPlan, summary, nil
+}
+
+// RemoveNodeFromPlan returns the plan without the node. Only the nodes that
+// are workers, or workers and ingress nodes, can be removed, and the last
+// worker of the cluster cannot be removed.
+func RemoveNodeFromPlan(plan Plan, host string) (Plan, error) {
+	var found bool
+	for _, n := range plan.GetUniqueNodes() {
+		if n.Host != host {
+			continue
+		}
+		found = true
+		for _, role := range plan.GetRolesForIP(n.IP) {
+			if !util.Contains(role, removableRoles) {
+				return plan, fmt.Errorf("node %q cannot be removed, as it is a %s node. Only the nodes with the roles %v can be removed", host, role, removableRoles)
+			}
+		}
+	}
+	if !found {
+		return plan, fmt.Errorf("node %q was not found in the plan", host)
+	}
+	workers := removeNode(plan.Worker.Nodes, host)
+	if len(workers) == 0 {
+		return plan, fmt.Errorf("node %q cannot be removed, as it is the last worker node of the cluster", host)
+	}
+	if len(workers) != len(plan.Worker.Nodes) {
+		plan.Worker.ExpectedCount--
+		plan.Worker.Nodes = workers
+	}
+	ingress := removeNode(plan.Ingress.Nodes, host)
+	if len(ingress) != len(plan.Ingress.Nodes) {
+		plan.Ingress.ExpectedCount--
+		plan.Ingress.Nodes = ingress
+	}
+	return plan, nil
+}
+
+// removeNode returns a copy of the nodes without the host
+func removeNode(nodes []Node, host string) []Node {
+	remaining := []Node{}
+	for _, n := range nodes {
+		if n.Host != host {
+			remaining = append(remaining, n)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,77 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+)
+
+func removeNodeTestPlan() Plan {
+	return Plan{
+		Cluster: Cluster{
+			Version:    "v1.10.3",
+			Networking: NetworkConfig{ServiceCIDRBlock: "10.0.0.0/16"},
+		},
+		Etcd:    NodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "etcd1", IP: "10.0.0.1"}}},
+		Master:  MasterNodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "master1", IP: "10.0.0.2"}}},
+		Worker:  NodeGroup{ExpectedCount: 2, Nodes: []Node{{Host: "worker1", IP: "10.0.0.3"}, {Host: "worker2", IP: "10.0.0.4"}}},
+		Ingress: OptionalNodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "worker2", IP: "10.0.0.4"}}},
+	}
+}
+
+func TestRemoveNodeFromPlan(t *testing.T) {
+	plan, err := RemoveNodeFromPlan(removeNodeTestPlan(), "worker2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Worker.ExpectedCount != 1 || !reflect.DeepEqual(plan.Worker.Nodes, []Node{{Host: "worker1", IP: "10.0.0.3"}}) {
+		t.Errorf("expected the node to be removed from the workers, got %+v", plan.Worker)
+	}
+	if plan.Ingress.ExpectedCount != 0 || len(plan.Ingress.Nodes) != 0 {
+		t.Errorf("expected the node to be removed from the ingress nodes, got %+v", plan.Ingress)
+	}
+
+	for _, host := range []string{"master1", "etcd1", "unknown"} {
+		if _, err := RemoveNodeFromPlan(removeNodeTestPlan(), host); err == nil {
+			t.Errorf("%s: expected an error", host)
+		}
+	}
+	// the last worker cannot be removed
+	if _, err := RemoveNodeFromPlan(plan, "worker1"); err == nil {
+		t.Errorf("expected an error when removing the last worker")
+	}
+}
+
+func TestRemoveNode(t *testing.T) {
+	runner := &retryRunner{}
+	e := retryExecutor(t, runner, RetryPolicy{})
+	e.renderCache = &renderCache{}
+	plan := removeNodeTestPlan()
+	updated, _, err := e.RemoveNode(&plan, "worker1", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the node is removed from the cluster, then reset
+	expected := [][]string{nil, {"worker1"}}
+	if !reflect.DeepEqual(runner.runs, expected) {
+		t.Errorf("expected the runs %v, got %v", expected, runner.runs)
+	}
+	if len(updated.Worker.Nodes) != 1 || updated.Worker.Nodes[0].Host != "worker2" {
+		t.Errorf("expected the node to be removed from the plan, got %+v", updated.Worker)
+	}
+	if len(plan.Worker.Nodes) != 2 {
+		t.Errorf("expected the original plan not to be modified, got %+v", plan.Worker)
+	}
+}
+
+func TestRemoveNodeFailure(t *testing.T) {
+	runner := &retryRunner{failing: []string{"master1"}, failures: 1}
+	e := retryExecutor(t, runner, RetryPolicy{})
+	e.renderCache = &renderCache{}
+	plan := removeNodeTestPlan()
+	if _, _, err := e.RemoveNode(&plan, "worker1", false); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if len(runner.runs) != 1 {
+		t.Errorf("expected the node not to be reset when it could not be removed, got the runs %v", runner.runs)
+	}
+}
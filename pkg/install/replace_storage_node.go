@@ -0,0 +1,254 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// glusterBrickClient manages the bricks of the Gluster volumes
+type glusterBrickClient interface {
+	ListVolumes() (*data.GlusterVolumeInfoCliOutput, error)
+	ReplaceBrick(volume, oldBrick, newBrick string) error
+	HealFull(volume string) error
+	HealInfo(volume string) (*data.GlusterHealInfoCliOutput, error)
+	DetachPeer(host string) error
+}
+
+// BrickReplacement moves the Gluster bricks of a failed storage node to a new
+// storage node, and restores their data from the other replicas. The new node
+// must already be a peer of the trusted storage pool.
+type BrickReplacement struct {
+	Out io.Writer
+	// HealTimeout is the maximum time to wait for the volumes to heal
+	HealTimeout time.Duration
+	// PollInterval is the time between checks of the heal progress
+	PollInterval time.Duration
+
+	// Hook for testing purposes, returns a gluster client that runs the
+	// gluster CLI on the host
+	glusterClient func(p *Plan, host string) (glusterBrickClient, error)
+}
+
+// replacedBrick is a brick of a volume that is moved to the new node
+type replacedBrick struct {
+	volume   string
+	oldBrick string
+	newBrick string
+}
+
+// Run replaces the bricks of the old node with bricks on the new node, waits
+// for the volumes to heal, and removes the old node from the trusted storage
+// pool. Bricks that were replaced by a previous run are not replaced again, so
+// that the replacement can be resumed after a failure.
+func (r BrickReplacement) Run(p *Plan, oldNode, newNode Node) error {
+	// the gluster CLI is run on the new node, which is known to be healthy
+	gluster, err := r.client(p, newNode.Host)
+	if err != nil {
+		return err
+	}
+	volumes, err := gluster.ListVolumes()
+	if err != nil {
+		return err
+	}
+	replaced, err := bricksToReplace(volumes, oldNode.Host, newNode.Host)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range replaced {
+		util.PrettyPrint(r.Out, "Replacing brick %s of volume %s", b.oldBrick, b.volume)
+		if err := gluster.ReplaceBrick(b.volume, b.oldBrick, b.newBrick); err != nil {
+			util.PrintError(r.Out)
+			return err
+		}
+		util.PrintOkln(r.Out)
+	}
+
+	// heal all the volumes that have a brick on the new node, including the
+	// volumes whose bricks were replaced by a previous run
+	healed := volumesWithBricksOn(volumes, newNode.Host)
+	for _, b := range replaced {
+		if !util.Contains(b.volume, healed) {
+			healed = append(healed, b.volume)
+		}
+	}
+	for _, v := range healed {
+		util.PrettyPrint(r.Out, "Starting heal of volume %s", v)
+		if err := gluster.HealFull(v); err != nil {
+			util.PrintError(r.Out)
+			return err
+		}
+		util.PrintOkln(r.Out)
+	}
+	for _, v := range healed {
+		if err := r.waitForHeal(gluster, v); err != nil {
+			return err
+		}
+	}
+
+	util.PrettyPrint(r.Out, "Removing %s from the trusted storage pool", oldNode.Host)
+	if err := gluster.DetachPeer(oldNode.Host); err != nil {
+		util.PrintError(r.Out)
+		return err
+	}
+	util.PrintOkln(r.Out)
+	return nil
+}
+
+func (r BrickReplacement) client(p *Plan, host string) (glusterBrickClient, error) {
+	if r.glusterClient != nil {
+		return r.glusterClient(p, host)
+	}
+	client, err := p.GetSSHClient(host)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSH client for %q: %v", host, err)
+	}
+	return data.RemoteGlusterCLI{SSHClient: client}, nil
+}
+
+// bricksToReplace returns the bricks of the old node, and the bricks on the new
+// node that replace them. An error is returned if a volume is not replicated,
+// because its data cannot be restored.
+func bricksToReplace(volumes *data.GlusterVolumeInfoCliOutput, oldHost, newHost string) ([]replacedBrick, error) {
+	var replaced []replacedBrick
+	var unreplicated []string
+	for _, v := range glusterVolumes(volumes) {
+		for _, b := range glusterBricks(v) {
+			host, brickPath := splitBrick(b)
+			if host != oldHost {
+				continue
+			}
+			if v.ReplicaCount < 2 {
+				unreplicated = append(unreplicated, v.Name)
+				break
+			}
+			replaced = append(replaced, replacedBrick{
+				volume:   v.Name,
+				oldBrick: b,
+				newBrick: newHost + ":" + brickPath,
+			})
+		}
+	}
+	if len(unreplicated) > 0 {
+		return nil, fmt.Errorf("volumes %v are not replicated, the data of their bricks on %q cannot be restored", unreplicated, oldHost)
+	}
+	return replaced, nil
+}
+
+// volumesWithBricksOn returns the names of the volumes that have a brick on the host
+func volumesWithBricksOn(volumes *data.GlusterVolumeInfoCliOutput, host string) []string {
+	var names []string
+	for _, v := range glusterVolumes(volumes) {
+		for _, b := range glusterBricks(v) {
+			if h, _ := splitBrick(b); h == host {
+				names = append(names, v.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func glusterVolumes(volumes *data.GlusterVolumeInfoCliOutput) []*data.GlusterVolume {
+	if volumes == nil || volumes.VolumeInfo == nil || volumes.VolumeInfo.Volumes == nil {
+		return nil
+	}
+	return volumes.VolumeInfo.Volumes.Volume
+}
+
+func glusterBricks(v *data.GlusterVolume) []string {
+	var bricks []string
+	if v.Bricks == nil {
+		return bricks
+	}
+	for _, b := range v.Bricks.Brick {
+		bricks = append(bricks, strings.TrimSpace(b.Text))
+	}
+	return bricks
+}
+
+// splitBrick returns the host and the path of a brick in the host:path format
+func splitBrick(brick string) (string, string) {
+	parts := strings.SplitN(brick, ":", 2)
+	if len(parts) != 2 {
+		return "", brick
+	}
+	return parts[0], parts[1]
+}
+
+// waitForHeal waits until no entries of the volume are pending heal, and
+// prints the progress of the heal
+func (r BrickReplacement) waitForHeal(gluster glusterBrickClient, volume string) error {
+	deadline := time.Now().Add(r.HealTimeout)
+	lastPending := -1
+	for {
+		pending, err := pendingHealEntries(gluster, volume)
+		if err == nil && pending == 0 {
+			util.PrettyPrintOk(r.Out, "Volume %s is healed", volume)
+			return nil
+		}
+		if err == nil && pending != lastPending {
+			fmt.Fprintf(r.Out, "Volume %s has %d entries pending heal\n", volume, pending)
+			lastPending = pending
+		}
+		if !time.Now().Before(deadline) {
+			util.PrettyPrintErr(r.Out, "Volume %s did not heal", volume)
+			if err != nil {
+				return fmt.Errorf("timed out after %v waiting for volume %s to heal: %v", r.HealTimeout, volume, err)
+			}
+			return fmt.Errorf("timed out after %v waiting for volume %s to heal, %d entries are pending heal", r.HealTimeout, volume, pending)
+		}
+		time.Sleep(r.PollInterval)
+	}
+}
+
+// pendingHealEntries returns the number of entries of the volume that are
+// pending heal on all its bricks. An error is returned if a brick is not
+// connected, as its entries are unknown.
+func pendingHealEntries(gluster glusterBrickClient, volume string) (int, error) {
+	info, err := gluster.HealInfo(volume)
+	if err != nil {
+		return 0, err
+	}
+	if info == nil || info.HealInfo == nil || info.HealInfo.Bricks == nil {
+		return 0, fmt.Errorf("no heal info returned for volume %s", volume)
+	}
+	pending := 0
+	for _, b := range info.HealInfo.Bricks.Brick {
+		n, err := strconv.Atoi(strings.TrimSpace(b.NumberOfEntries))
+		if err != nil {
+			return 0, fmt.Errorf("brick %s is not connected: %s", b.Name, b.Status)
+		}
+		pending += n
+	}
+	return pending, nil
+}
+
+// ReplaceStorageNodeInPlan returns the plan with the old storage node replaced
+// by the new node. The old node is only removed from the storage role.
+func ReplaceStorageNodeInPlan(p Plan, oldHost string, newNode Node) Plan {
+	p = RemoveStorageNodeFromPlan(p, oldHost)
+	if !nodeInList(newNode.Host, p.Storage.Nodes) {
+		p = AddNodeToPlan(p, newNode, []string{"storage"})
+	}
+	return p
+}
+
+// RemoveStorageNodeFromPlan returns the plan without the storage node
+func RemoveStorageNodeFromPlan(p Plan, host string) Plan {
+	nodes := []Node{}
+	for _, n := range p.Storage.Nodes {
+		if n.Host != host {
+			nodes = append(nodes, n)
+		}
+	}
+	p.Storage.ExpectedCount -= len(p.Storage.Nodes) - len(nodes)
+	p.Storage.Nodes = nodes
+	return p
+}
@@ -0,0 +1,199 @@
+package install
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+// fakeGluster simulates the gluster CLI of a trusted storage pool
+type fakeGluster struct {
+	volumes  []*data.GlusterVolume
+	pending  []int
+	replaced []string
+	healed   []string
+	detached []string
+}
+
+func (g *fakeGluster) ListVolumes() (*data.GlusterVolumeInfoCliOutput, error) {
+	return &data.GlusterVolumeInfoCliOutput{
+		VolumeInfo: &data.GlusterVolumeInfo{
+			Volumes: &data.GlusterVolumes{Volume: g.volumes},
+		},
+	}, nil
+}
+
+func (g *fakeGluster) ReplaceBrick(volume, oldBrick, newBrick string) error {
+	g.replaced = append(g.replaced, volume+" "+oldBrick+" "+newBrick)
+	return nil
+}
+
+func (g *fakeGluster) HealFull(volume string) error {
+	g.healed = append(g.healed, volume)
+	return nil
+}
+
+// HealInfo returns the next number of pending entries, and keeps returning the
+// last one once all have been returned
+func (g *fakeGluster) HealInfo(volume string) (*data.GlusterHealInfoCliOutput, error) {
+	if len(g.pending) == 0 {
+		return nil, errors.New("no heal info")
+	}
+	n := g.pending[0]
+	if len(g.pending) > 1 {
+		g.pending = g.pending[1:]
+	}
+	entries := strconv.Itoa(n)
+	if n < 0 {
+		entries = "-"
+	}
+	return &data.GlusterHealInfoCliOutput{
+		HealInfo: &data.GlusterHealInfo{
+			Bricks: &data.GlusterHealInfoBricks{
+				Brick: []*data.GlusterHealInfoBrick{{Name: "storage2:/data/" + volume, Status: "Connected", NumberOfEntries: entries}},
+			},
+		},
+	}, nil
+}
+
+func (g *fakeGluster) DetachPeer(host string) error {
+	g.detached = append(g.detached, host)
+	return nil
+}
+
+func glusterTestVolume(name string, replicaCount uint, bricks ...string) *data.GlusterVolume {
+	v := &data.GlusterVolume{Name: name, ReplicaCount: replicaCount, Bricks: &data.GlusterBricks{}}
+	for _, b := range bricks {
+		v.Bricks.Brick = append(v.Bricks.Brick, &data.GlusterBrick{Text: b})
+	}
+	return v
+}
+
+func testBrickReplacement(g *fakeGluster) (BrickReplacement, *[]string) {
+	var hosts []string
+	return BrickReplacement{
+		Out:          ioutil.Discard,
+		HealTimeout:  time.Second,
+		PollInterval: time.Millisecond,
+		glusterClient: func(p *Plan, host string) (glusterBrickClient, error) {
+			hosts = append(hosts, host)
+			return g, nil
+		},
+	}, &hosts
+}
+
+func TestBrickReplacement(t *testing.T) {
+	g := &fakeGluster{
+		volumes: []*data.GlusterVolume{
+			glusterTestVolume("one", 2, "storage1:/data/one", "storage2:/data/one"),
+			glusterTestVolume("two", 2, "storage2:/data/two", "storage3:/data/two"),
+		},
+		pending: []int{5, 2, 0},
+	}
+	r, hosts := testBrickReplacement(g)
+	if err := r.Run(&Plan{}, Node{Host: "storage1"}, Node{Host: "storage4"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(*hosts, []string{"storage4"}) {
+		t.Errorf("expected the gluster CLI to run on the new node, got %v", *hosts)
+	}
+	if !reflect.DeepEqual(g.replaced, []string{"one storage1:/data/one storage4:/data/one"}) {
+		t.Errorf("unexpected replaced bricks: %v", g.replaced)
+	}
+	if !reflect.DeepEqual(g.healed, []string{"one"}) {
+		t.Errorf("unexpected healed volumes: %v", g.healed)
+	}
+	if !reflect.DeepEqual(g.detached, []string{"storage1"}) {
+		t.Errorf("expected the old node to be detached, got %v", g.detached)
+	}
+}
+
+func TestBrickReplacementResumed(t *testing.T) {
+	// the brick of volume one was replaced by a previous run
+	g := &fakeGluster{
+		volumes: []*data.GlusterVolume{
+			glusterTestVolume("one", 2, "storage4:/data/one", "storage2:/data/one"),
+			glusterTestVolume("two", 2, "storage1:/data/two", "storage3:/data/two"),
+		},
+		pending: []int{0},
+	}
+	r, _ := testBrickReplacement(g)
+	if err := r.Run(&Plan{}, Node{Host: "storage1"}, Node{Host: "storage4"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(g.replaced, []string{"two storage1:/data/two storage4:/data/two"}) {
+		t.Errorf("unexpected replaced bricks: %v", g.replaced)
+	}
+	if !reflect.DeepEqual(g.healed, []string{"one", "two"}) {
+		t.Errorf("unexpected healed volumes: %v", g.healed)
+	}
+}
+
+func TestBrickReplacementUnreplicatedVolume(t *testing.T) {
+	g := &fakeGluster{
+		volumes: []*data.GlusterVolume{
+			glusterTestVolume("one", 2, "storage1:/data/one", "storage2:/data/one"),
+			glusterTestVolume("two", 1, "storage1:/data/two"),
+		},
+	}
+	r, _ := testBrickReplacement(g)
+	if err := r.Run(&Plan{}, Node{Host: "storage1"}, Node{Host: "storage4"}); err == nil {
+		t.Fatalf("expected an error with a volume that is not replicated")
+	}
+	if len(g.replaced) != 0 || len(g.detached) != 0 {
+		t.Errorf("expected no changes to the volumes, got replaced %v and detached %v", g.replaced, g.detached)
+	}
+}
+
+func TestBrickReplacementHealTimeout(t *testing.T) {
+	tests := []struct {
+		pending []int
+	}{
+		{pending: []int{3}},
+		// a brick that is not connected
+		{pending: []int{-1}},
+	}
+	for i, test := range tests {
+		g := &fakeGluster{
+			volumes: []*data.GlusterVolume{glusterTestVolume("one", 2, "storage1:/data/one", "storage2:/data/one")},
+			pending: test.pending,
+		}
+		r, _ := testBrickReplacement(g)
+		r.HealTimeout = 10 * time.Millisecond
+		if err := r.Run(&Plan{}, Node{Host: "storage1"}, Node{Host: "storage4"}); err == nil {
+			t.Errorf("test %d: expected a heal timeout error", i)
+		}
+		if len(g.detached) != 0 {
+			t.Errorf("test %d: expected the old node to not be detached, got %v", i, g.detached)
+		}
+	}
+}
+
+func TestReplaceStorageNodeInPlan(t *testing.T) {
+	p := Plan{}
+	p.Storage.ExpectedCount = 2
+	p.Storage.Nodes = []Node{{Host: "storage1", IP: "10.0.0.1"}, {Host: "storage2", IP: "10.0.0.2"}}
+
+	removed := RemoveStorageNodeFromPlan(p, "storage1")
+	if removed.Storage.ExpectedCount != 1 || len(removed.Storage.Nodes) != 1 || removed.Storage.Nodes[0].Host != "storage2" {
+		t.Errorf("unexpected storage nodes after removing the node: %+v", removed.Storage)
+	}
+
+	newNode := Node{Host: "storage3", IP: "10.0.0.3"}
+	replaced := ReplaceStorageNodeInPlan(p, "storage1", newNode)
+	if replaced.Storage.ExpectedCount != 2 || !nodeInList("storage3", replaced.Storage.Nodes) || nodeInList("storage1", replaced.Storage.Nodes) {
+		t.Errorf("unexpected storage nodes after replacing the node: %+v", replaced.Storage)
+	}
+
+	// the new node was added to the plan by a previous run
+	withNew := AddNodeToPlan(p, newNode, []string{"storage"})
+	replaced = ReplaceStorageNodeInPlan(withNew, "storage1", newNode)
+	if replaced.Storage.ExpectedCount != 2 || len(replaced.Storage.Nodes) != 2 {
+		t.Errorf("unexpected storage nodes after resuming the replacement: %+v", replaced.Storage)
+	}
+}
@@ -0,0 +1,50 @@
+package install
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// resetBackupDir is the directory of the nodes where the backups taken before
+// resetting the nodes are kept
+const resetBackupDir = "/var/lib/kismatic/backups"
+
+// ResetComponents returns the components that can be reset on their own
+func ResetComponents() []string {
+	return []string{"cni", "kubelet", "kubernetes", "docker", "etcd", "storage"}
+}
+
+// ResetOptions select what is removed from the nodes when they are reset
+type ResetOptions struct {
+	// Components are the components that are reset. All the components, along
+	// with the files installed by KET, are reset when empty.
+	Components []string
+	// Purge removes the Kubernetes and etcd data without leaving a backup
+	Purge bool
+}
+
+// Validate the options
+func (o ResetOptions) Validate() error {
+	for _, c := range o.Components {
+		if !contains(c, ResetComponents()) {
+			return fmt.Errorf("component %q cannot be reset. Options are %v", c, ResetComponents())
+		}
+	}
+	return nil
+}
+
+// backedUp returns true if the Kubernetes or etcd directories are backed up
+// before they are removed
+func (o ResetOptions) backedUp() bool {
+	if o.Purge {
+		return false
+	}
+	return len(o.Components) == 0 || contains("kubernetes", o.Components) || contains("etcd", o.Components)
+}
+
+// resetBackupPath returns the directory of the nodes where the backup of the
+// reset is kept
+func resetBackupPath(now time.Time) string {
+	return path.Join(resetBackupDir, "reset-"+now.Format("20060102150405"))
+}
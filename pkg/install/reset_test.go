@@ -0,0 +1,46 @@
+package install
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResetOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts  ResetOptions
+		valid bool
+	}{
+		{ResetOptions{}, true},
+		{ResetOptions{Components: []string{"kubelet", "cni"}}, true},
+		{ResetOptions{Components: []string{"kubelet", "dashboard"}}, false},
+	}
+	for i, test := range tests {
+		if err := test.opts.Validate(); (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid to be %v, but got %v", i, test.valid, err)
+		}
+	}
+}
+
+func TestResetOptionsBackedUp(t *testing.T) {
+	tests := []struct {
+		opts     ResetOptions
+		backedUp bool
+	}{
+		{ResetOptions{}, true},
+		{ResetOptions{Purge: true}, false},
+		{ResetOptions{Components: []string{"etcd"}}, true},
+		{ResetOptions{Components: []string{"kubelet", "cni"}}, false},
+	}
+	for i, test := range tests {
+		if b := test.opts.backedUp(); b != test.backedUp {
+			t.Errorf("test %d: expected backedUp to be %v, but got %v", i, test.backedUp, b)
+		}
+	}
+}
+
+func TestResetBackupPath(t *testing.T) {
+	now := time.Date(2018, 10, 18, 12, 30, 5, 0, time.UTC)
+	if p := resetBackupPath(now); p != "/var/lib/kismatic/backups/reset-20181018123005" {
+		t.Errorf("unexpected backup path %q", p)
+	}
+}
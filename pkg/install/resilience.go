@@ -0,0 +1,246 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/ssh"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// controlPlaneComponents are the components that run as static pods on the
+// master nodes
+var controlPlaneComponents = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+
+// ResilienceTest disrupts the cluster by killing one replica of each control
+// plane component, and optionally rebooting a worker node, and verifies that
+// the cluster recovers on its own.
+type ResilienceTest struct {
+	Out io.Writer
+	// RebootWorker is the host of the worker node that is rebooted. No node
+	// is rebooted when empty.
+	RebootWorker string
+	// Timeout is the maximum time to wait for each disruption to recover
+	Timeout time.Duration
+	// PollInterval is the time between checks for recovery
+	PollInterval time.Duration
+
+	// Hook for testing purposes, returns an SSH client for the host
+	sshClient func(p *Plan, host string) (ssh.Client, error)
+}
+
+type disruption struct {
+	name string
+	run  func() error
+}
+
+// Run disrupts the cluster and waits for it to recover. An error is returned
+// for the first disruption the cluster does not recover from.
+func (r ResilienceTest) Run(p *Plan) error {
+	if r.RebootWorker != "" && !nodeInList(r.RebootWorker, p.Worker.Nodes) {
+		return fmt.Errorf("%q is not a worker node", r.RebootWorker)
+	}
+	if len(p.Master.Nodes) == 1 {
+		util.PrettyPrintWarn(r.Out, "The cluster has a single master node, the Kubernetes API will be unavailable while it recovers")
+	}
+	// kubectl is run on the first master, and the last master is disrupted,
+	// so that the API remains available in clusters with multiple masters
+	kubectl, err := r.client(p, p.Master.Nodes[0].Host)
+	if err != nil {
+		return err
+	}
+	master := p.Master.Nodes[len(p.Master.Nodes)-1]
+	masterClient, err := r.client(p, master.Host)
+	if err != nil {
+		return err
+	}
+
+	disruptions := []disruption{}
+	for _, c := range controlPlaneComponents {
+		component := c
+		disruptions = append(disruptions, disruption{
+			name: fmt.Sprintf("Killing %s on %s", component, master.Host),
+			run: func() error {
+				return r.killStaticPod(kubectl, masterClient, master.Host, component)
+			},
+		})
+	}
+	etcd := p.Etcd.Nodes[0]
+	disruptions = append(disruptions, disruption{
+		name: fmt.Sprintf("Killing etcd on %s", etcd.Host),
+		run: func() error {
+			etcdClient, err := r.client(p, etcd.Host)
+			if err != nil {
+				return err
+			}
+			return r.killEtcd(kubectl, etcdClient)
+		},
+	})
+	if r.RebootWorker != "" {
+		disruptions = append(disruptions, disruption{
+			name: fmt.Sprintf("Rebooting %s", r.RebootWorker),
+			run: func() error {
+				workerClient, err := r.client(p, r.RebootWorker)
+				if err != nil {
+					return err
+				}
+				return r.rebootNode(kubectl, workerClient, r.RebootWorker)
+			},
+		})
+	}
+
+	for _, d := range disruptions {
+		util.PrettyPrint(r.Out, "%s", d.name)
+		if err := d.run(); err != nil {
+			util.PrintError(r.Out)
+			return fmt.Errorf("%s: %v", strings.ToLower(d.name[:1])+d.name[1:], err)
+		}
+		util.PrintOkln(r.Out)
+	}
+	return nil
+}
+
+func (r ResilienceTest) client(p *Plan, host string) (ssh.Client, error) {
+	if r.sshClient != nil {
+		return r.sshClient(p, host)
+	}
+	client, err := p.GetSSHClient(host)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSH client: %v", err)
+	}
+	return client, nil
+}
+
+func remoteOutput(client ssh.Client, cmd string) (string, error) {
+	out, err := client.Output(true, cmd)
+	return strings.TrimSpace(out), err
+}
+
+// waitFor polls the condition until it returns true, or the timeout expires.
+// Errors are expected while the cluster recovers, so they are retried.
+func (r ResilienceTest) waitFor(what string, cond func() (bool, error)) error {
+	deadline := time.Now().Add(r.Timeout)
+	var lastErr error
+	for {
+		done, err := cond()
+		if err == nil && done {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+			logging.Debug("waiting for recovery", "what", what, "error", err)
+		}
+		if !time.Now().Before(deadline) {
+			if lastErr != nil {
+				return fmt.Errorf("timed out after %v waiting for %s: %v", r.Timeout, what, lastErr)
+			}
+			return fmt.Errorf("timed out after %v waiting for %s", r.Timeout, what)
+		}
+		time.Sleep(r.PollInterval)
+	}
+}
+
+// killStaticPod kills the container of the component on the master node, and
+// waits for the kubelet to start a new container and for the pod to be running
+func (r ResilienceTest) killStaticPod(kubectl, master ssh.Client, host, component string) error {
+	containerCmd := fmt.Sprintf("sudo docker ps -q --filter label=io.kubernetes.container.name=%s --filter status=running", component)
+	id, err := remoteOutput(master, containerCmd)
+	if err != nil {
+		return fmt.Errorf("error getting container: %v", err)
+	}
+	if id == "" {
+		return fmt.Errorf("%s is not running", component)
+	}
+	if _, err := remoteOutput(master, "sudo docker kill "+id); err != nil {
+		return fmt.Errorf("error killing container %s: %v", id, err)
+	}
+	err = r.waitFor("a new container to start", func() (bool, error) {
+		newID, err := remoteOutput(master, containerCmd)
+		return newID != "" && newID != id, err
+	})
+	if err != nil {
+		return err
+	}
+	podCmd := fmt.Sprintf("sudo kubectl --kubeconfig /root/.kube/config get pods --namespace kube-system --selector component=%s,kismatic/host=%s -o jsonpath='{.items[0].status.phase}'", component, host)
+	return r.waitFor("the pod to be running", func() (bool, error) {
+		phase, err := remoteOutput(kubectl, podCmd)
+		return phase == "Running", err
+	})
+}
+
+// killEtcd kills the etcd process on the etcd node, and waits for systemd to
+// restart it and for the Kubernetes API to be healthy
+func (r ResilienceTest) killEtcd(kubectl, etcd ssh.Client) error {
+	pid, err := etcdPID(etcd)
+	if err != nil {
+		return fmt.Errorf("error getting etcd process: %v", err)
+	}
+	if pid == "" || pid == "0" {
+		return fmt.Errorf("etcd is not running")
+	}
+	if _, err := remoteOutput(etcd, "sudo systemctl kill --signal=SIGKILL etcd"); err != nil {
+		return fmt.Errorf("error killing etcd: %v", err)
+	}
+	err = r.waitFor("etcd to be restarted", func() (bool, error) {
+		active, err := remoteOutput(etcd, "sudo systemctl is-active etcd")
+		if err != nil || active != "active" {
+			return false, err
+		}
+		newPID, err := etcdPID(etcd)
+		return newPID != "" && newPID != "0" && newPID != pid, err
+	})
+	if err != nil {
+		return err
+	}
+	return r.waitFor("the Kubernetes API to be healthy", func() (bool, error) {
+		health, err := remoteOutput(kubectl, "sudo kubectl --kubeconfig /root/.kube/config get --raw /healthz")
+		return health == "ok", err
+	})
+}
+
+// etcdPID returns the ID of the main process of the etcd service, or "0" if
+// the service is not running
+func etcdPID(etcd ssh.Client) (string, error) {
+	out, err := remoteOutput(etcd, "sudo systemctl show --property MainPID etcd")
+	return strings.TrimPrefix(out, "MainPID="), err
+}
+
+// rebootNode reboots the node, and waits for it to come back up and be ready
+func (r ResilienceTest) rebootNode(kubectl, node ssh.Client, host string) error {
+	bootIDCmd := "cat /proc/sys/kernel/random/boot_id"
+	bootID, err := remoteOutput(node, bootIDCmd)
+	if err != nil {
+		return fmt.Errorf("error getting boot ID: %v", err)
+	}
+	// The connection is closed by the node as it reboots, so the error is ignored
+	if _, err := remoteOutput(node, "sudo systemctl reboot"); err != nil {
+		logging.Debug("reboot command returned an error", "host", host, "error", err)
+	}
+	var bootIDAfterReboot string
+	err = r.waitFor("the node to reboot", func() (bool, error) {
+		newBootID, err := remoteOutput(node, bootIDCmd)
+		bootIDAfterReboot = newBootID
+		return newBootID != "" && newBootID != bootID, err
+	})
+	if err != nil {
+		return err
+	}
+	// The boot ID reported by the kubelet ensures the status is not stale
+	readyCmd := fmt.Sprintf(`sudo kubectl --kubeconfig /root/.kube/config get node %s -o jsonpath='{.status.nodeInfo.bootID} {.status.conditions[?(@.type=="Ready")].status}'`, host)
+	return r.waitFor("the node to be ready", func() (bool, error) {
+		status, err := remoteOutput(kubectl, readyCmd)
+		return status == bootIDAfterReboot+" True", err
+	})
+}
+
+func nodeInList(host string, nodes []Node) bool {
+	for _, n := range nodes {
+		if n.Host == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// fakeNode simulates a node that recovers from disruptions
+type fakeNode struct {
+	host       string
+	containers map[string]string
+	etcdPID    string
+	bootID     string
+	// when true, killed containers and processes are not restarted
+	noRecovery bool
+	commands   []string
+}
+
+func (n *fakeNode) Output(pty bool, args ...string) (string, error) {
+	cmd := strings.Join(args, " ")
+	n.commands = append(n.commands, cmd)
+	switch {
+	case strings.HasPrefix(cmd, "sudo docker ps"):
+		for c, id := range n.containers {
+			if strings.Contains(cmd, "container.name="+c+" ") {
+				return id + "\n", nil
+			}
+		}
+		return "", nil
+	case strings.HasPrefix(cmd, "sudo docker kill "):
+		for c, id := range n.containers {
+			if strings.HasSuffix(cmd, " "+id) {
+				n.containers[c] = ""
+				if !n.noRecovery {
+					n.containers[c] = id + "-restarted"
+				}
+			}
+		}
+		return "", nil
+	case cmd == "sudo systemctl show --property MainPID etcd":
+		return "MainPID=" + n.etcdPID + "\r\n", nil
+	case cmd == "sudo systemctl kill --signal=SIGKILL etcd":
+		n.etcdPID = "0"
+		if !n.noRecovery {
+			n.etcdPID = "4321"
+		}
+		return "", nil
+	case cmd == "sudo systemctl is-active etcd":
+		if n.etcdPID == "0" {
+			return "activating", nil
+		}
+		return "active", nil
+	case cmd == "cat /proc/sys/kernel/random/boot_id":
+		return n.bootID, nil
+	case cmd == "sudo systemctl reboot":
+		n.bootID = "rebooted"
+		return "", errors.New("connection closed")
+	case strings.Contains(cmd, "get pods"):
+		return "Running", nil
+	case strings.Contains(cmd, "get --raw /healthz"):
+		return "ok", nil
+	case strings.Contains(cmd, "get node worker1"):
+		return "rebooted True", nil
+	}
+	return "", errors.New("unexpected command: " + cmd)
+}
+
+func (n *fakeNode) Shell(pty bool, args ...string) error { return nil }
+
+func resilienceTestPlan() *Plan {
+	return &Plan{
+		Etcd:   NodeGroup{Nodes: []Node{{Host: "etcd1"}}},
+		Master: MasterNodeGroup{Nodes: []Node{{Host: "master1"}, {Host: "master2"}}},
+		Worker: NodeGroup{Nodes: []Node{{Host: "worker1"}}},
+	}
+}
+
+func fakeResilienceNodes() map[string]*fakeNode {
+	return map[string]*fakeNode{
+		"master1": {host: "master1"},
+		"master2": {
+			host: "master2",
+			containers: map[string]string{
+				"kube-apiserver":          "a1",
+				"kube-controller-manager": "c1",
+				"kube-scheduler":          "s1",
+			},
+		},
+		"etcd1":   {host: "etcd1", etcdPID: "1234"},
+		"worker1": {host: "worker1", bootID: "original"},
+	}
+}
+
+func testResilienceTest(out *bytes.Buffer, nodes map[string]*fakeNode) ResilienceTest {
+	return ResilienceTest{
+		Out:          out,
+		Timeout:      20 * time.Millisecond,
+		PollInterval: time.Millisecond,
+		sshClient: func(p *Plan, host string) (ssh.Client, error) {
+			return nodes[host], nil
+		},
+	}
+}
+
+func TestResilienceTestRecovers(t *testing.T) {
+	nodes := fakeResilienceNodes()
+	out := &bytes.Buffer{}
+	r := testResilienceTest(out, nodes)
+	r.RebootWorker = "worker1"
+	if err := r.Run(resilienceTestPlan()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for c, id := range nodes["master2"].containers {
+		if !strings.HasSuffix(id, "-restarted") {
+			t.Errorf("expected %s to be killed on the last master, got container %q", c, id)
+		}
+	}
+	if nodes["etcd1"].etcdPID != "4321" {
+		t.Errorf("expected etcd to be killed")
+	}
+	if nodes["worker1"].bootID != "rebooted" {
+		t.Errorf("expected worker1 to be rebooted")
+	}
+	for _, cmd := range nodes["master1"].commands {
+		if !strings.Contains(cmd, "kubectl") {
+			t.Errorf("expected only kubectl to be run on the first master, got %q", cmd)
+		}
+	}
+}
+
+func TestResilienceTestDoesNotRebootWithoutWorker(t *testing.T) {
+	nodes := fakeResilienceNodes()
+	if err := testResilienceTest(&bytes.Buffer{}, nodes).Run(resilienceTestPlan()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes["worker1"].commands) != 0 {
+		t.Errorf("expected no commands to be run on the worker, got %v", nodes["worker1"].commands)
+	}
+}
+
+func TestResilienceTestFailsWhenComponentDoesNotRecover(t *testing.T) {
+	nodes := fakeResilienceNodes()
+	nodes["master2"].noRecovery = true
+	err := testResilienceTest(&bytes.Buffer{}, nodes).Run(resilienceTestPlan())
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !strings.Contains(err.Error(), "killing kube-apiserver on master2") || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResilienceTestFailsWhenEtcdDoesNotRecover(t *testing.T) {
+	nodes := fakeResilienceNodes()
+	nodes["etcd1"].noRecovery = true
+	err := testResilienceTest(&bytes.Buffer{}, nodes).Run(resilienceTestPlan())
+	if err == nil || !strings.Contains(err.Error(), "killing etcd on etcd1") {
+		t.Errorf("expected etcd to fail to recover, got: %v", err)
+	}
+}
+
+func TestResilienceTestRejectsNonWorker(t *testing.T) {
+	r := testResilienceTest(&bytes.Buffer{}, fakeResilienceNodes())
+	r.RebootWorker = "master1"
+	if err := r.Run(resilienceTestPlan()); err == nil {
+		t.Errorf("expected an error when rebooting a node that is not a worker")
+	}
+}
@@ -0,0 +1,53 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// RestartComponents returns the components that can be restarted on their own
+func RestartComponents() []string {
+	return []string{"etcd", "docker", "kubelet", "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kube-proxy", "cni"}
+}
+
+// validateRestartComponents returns an error if a component cannot be
+// restarted on its own
+func validateRestartComponents(components []string) error {
+	for _, c := range components {
+		if !contains(c, RestartComponents()) {
+			return fmt.Errorf("component %q cannot be restarted. Options are %v", c, RestartComponents())
+		}
+	}
+	return nil
+}
+
+// enableRestart sets the components that are restarted by the task. All the
+// components are restarted when restartServices is true, otherwise only the
+// components of the RestartComponents option are.
+func (ae *ansibleExecutor) enableRestart(cc *ansible.ClusterCatalog, restartServices bool) {
+	if restartServices {
+		cc.EnableRestart()
+		return
+	}
+	for _, c := range ae.options.RestartComponents {
+		switch c {
+		case "etcd":
+			cc.ForceEtcdRestart = true
+		case "docker":
+			cc.ForceDockerRestart = true
+		case "kubelet":
+			cc.ForceKubeletRestart = true
+		case "kube-apiserver":
+			cc.ForceAPIServerRestart = true
+		case "kube-controller-manager":
+			cc.ForceControllerManagerRestart = true
+		case "kube-scheduler":
+			cc.ForceSchedulerRestart = true
+		case "kube-proxy":
+			cc.ForceProxyRestart = true
+		case "cni":
+			cc.ForceCalicoNodeRestart = true
+		}
+	}
+}
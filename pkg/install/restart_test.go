@@ -0,0 +1,75 @@
+package install
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestNewExecutorRestartComponents(t *testing.T) {
+	opts := ExecutorOptions{GeneratedAssetsDirectory: "generated", OutputFormat: "simple", RestartComponents: []string{"kubelet", "flannel"}}
+	if _, err := NewExecutor(&bytes.Buffer{}, &bytes.Buffer{}, opts); err == nil {
+		t.Errorf("expected an error for a component that cannot be restarted")
+	}
+	opts.RestartComponents = RestartComponents()
+	if _, err := NewExecutor(&bytes.Buffer{}, &bytes.Buffer{}, opts); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEnableRestart(t *testing.T) {
+	tests := []struct {
+		name            string
+		components      []string
+		restartServices bool
+		expected        ansible.ClusterCatalog
+	}{
+		{
+			name:     "nothing restarted",
+			expected: ansible.ClusterCatalog{},
+		},
+		{
+			name:       "selected components",
+			components: []string{"kubelet", "kube-apiserver", "cni"},
+			expected: ansible.ClusterCatalog{
+				ForceKubeletRestart:    true,
+				ForceAPIServerRestart:  true,
+				ForceCalicoNodeRestart: true,
+			},
+		},
+		{
+			name:       "control plane",
+			components: []string{"etcd", "kube-controller-manager", "kube-scheduler"},
+			expected: ansible.ClusterCatalog{
+				ForceEtcdRestart:              true,
+				ForceControllerManagerRestart: true,
+				ForceSchedulerRestart:         true,
+			},
+		},
+		{
+			name:            "restart services restarts everything",
+			components:      []string{"docker"},
+			restartServices: true,
+			expected: ansible.ClusterCatalog{
+				ForceEtcdRestart:              true,
+				ForceAPIServerRestart:         true,
+				ForceControllerManagerRestart: true,
+				ForceSchedulerRestart:         true,
+				ForceProxyRestart:             true,
+				ForceKubeletRestart:           true,
+				ForceCalicoNodeRestart:        true,
+				ForceDockerRestart:            true,
+			},
+		},
+	}
+	for _, test := range tests {
+		ae := &ansibleExecutor{options: ExecutorOptions{RestartComponents: test.components}}
+		cc := &ansible.ClusterCatalog{}
+		ae.enableRestart(cc, test.restartServices)
+		if !reflect.DeepEqual(*cc, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, *cc)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package install
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// defaultRetryBackoff is the time to wait before the first retry of a failed
+// playbook when the retry policy does not set one
+const defaultRetryBackoff = 10 * time.Second
+
+// unretriedTasks are not retried, as their failures are the outcome of
+// checks rather than transient errors
+var unretriedTasks = []string{"preflight", "add-node-preflight", "upgrade-preflight"}
+
+// RetryPolicy configures how the playbooks that fail on some hosts are
+// retried. The failed playbook is re-run, limited to the hosts that failed,
+// until it succeeds or the retries are exhausted. Playbooks that are aborted
+// or that time out are not retried.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of times a failed playbook is re-run.
+	// Playbooks are not retried when zero.
+	MaxRetries int
+	// Backoff is the time to wait before the first retry. It is doubled
+	// before each of the following retries. Defaults to 10 seconds.
+	Backoff time.Duration
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("the maximum number of retries must be greater or equal to 0, got: %d", p.MaxRetries)
+	}
+	if p.Backoff < 0 {
+		return fmt.Errorf("the retry backoff must be greater or equal to 0, got: %v", p.Backoff)
+	}
+	return nil
+}
+
+// retriable returns true if the task that failed with the error on the hosts
+// can be retried on those hosts
+func retriable(t task, err error, hosts []string) bool {
+	if IsAborted(err) || IsTimeout(err) || len(hosts) == 0 {
+		return false
+	}
+	return !util.Contains(t.name, unretriedTasks)
+}
+
+// backoff returns the time to wait before the given retry, starting at 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	b := p.Backoff
+	if b == 0 {
+		b = defaultRetryBackoff
+	}
+	return b << uint(retry-1)
+}
@@ -0,0 +1,178 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+// retryRunner fails the playbook on the failing hosts the first failures
+// times it is run, and records the nodes each run was limited to
+type retryRunner struct {
+	failing  []string
+	failures int
+	runs     [][]string
+	catalogs []ansible.ClusterCatalog
+	sent     chan struct{}
+	err      error
+}
+
+func (r *retryRunner) StartPlaybook(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog) (<-chan ansible.Event, error) {
+	return r.StartPlaybookOnNode(playbook, inv, cc)
+}
+
+func (r *retryRunner) StartPlaybookOnNode(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog, nodes ...string) (<-chan ansible.Event, error) {
+	r.err = nil
+	r.sent = make(chan struct{})
+	var events []ansible.Event
+	if len(r.runs) < r.failures {
+		for _, h := range r.failing {
+			e := &ansible.RunnerUnreachableEvent{}
+			e.Host = h
+			events = append(events, e)
+		}
+		r.err = errors.New("exit status 3")
+	}
+	r.runs = append(r.runs, nodes)
+	r.catalogs = append(r.catalogs, cc)
+	events = append(events, &ansible.PlaybookEndEvent{})
+	out := make(chan ansible.Event)
+	go func() {
+		defer close(r.sent)
+		defer close(out)
+		for _, e := range events {
+			out <- e
+		}
+	}()
+	return out, nil
+}
+
+func (r *retryRunner) WaitPlaybook() error {
+	<-r.sent
+	return r.err
+}
+
+func (r *retryRunner) Stop() error { return nil }
+
+func retryExecutor(t *testing.T, runner ansible.Runner, policy RetryPolicy) *ansibleExecutor {
+	return &ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: mustGetTempDir(t), Retry: policy},
+		stdout:              &bytes.Buffer{},
+		consoleOutputFormat: ansible.RawFormat,
+		certsDir:            mustGetTempDir(t),
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return runner, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+	}
+}
+
+func TestExecuteRetriesFailedHosts(t *testing.T) {
+	runner := &retryRunner{failing: []string{"worker1", "worker2"}, failures: 2}
+	e := retryExecutor(t, runner, RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond})
+	if _, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := [][]string{nil, {"worker1", "worker2"}, {"worker1", "worker2"}}
+	if !reflect.DeepEqual(runner.runs, expected) {
+		t.Errorf("expected the runs %v, got %v", expected, runner.runs)
+	}
+	runs, err := filepath.Glob(filepath.Join(e.options.RunsDirectory, "apply", "*", "retry-*", "ansible.log"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Errorf("expected the retries to be recorded in the run directory, got %v", runs)
+	}
+}
+
+func TestExecuteGivesUpAfterRetries(t *testing.T) {
+	runner := &retryRunner{failing: []string{"worker1"}, failures: 3}
+	e := retryExecutor(t, runner, RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond})
+	if _, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()}); err == nil {
+		t.Errorf("expected an error")
+	}
+	if len(runner.runs) != 3 {
+		t.Errorf("expected the playbook to be run 3 times, got %d", len(runner.runs))
+	}
+}
+
+func TestExecuteDoesNotRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		task   string
+		policy RetryPolicy
+	}{
+		{
+			name: "no retries",
+			task: "apply",
+		},
+		{
+			name:   "pre-flight checks",
+			task:   "preflight",
+			policy: RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond},
+		},
+	}
+	for _, test := range tests {
+		runner := &retryRunner{failing: []string{"worker1"}, failures: 1}
+		e := retryExecutor(t, runner, test.policy)
+		if _, err := e.execute(task{name: test.task, playbook: "kubernetes.yaml", explainer: e.defaultExplainer()}); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if len(runner.runs) != 1 {
+			t.Errorf("%s: expected the playbook to be run once, got %d", test.name, len(runner.runs))
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 3}
+	if b := p.backoff(1); b != defaultRetryBackoff {
+		t.Errorf("expected the default backoff, got %v", b)
+	}
+	p.Backoff = time.Second
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+	for i, e := range expected {
+		if b := p.backoff(i + 1); b != e {
+			t.Errorf("retry %d: expected %v, got %v", i+1, e, b)
+		}
+	}
+}
+
+func TestNewExecutorRetryPolicy(t *testing.T) {
+	opts := ExecutorOptions{GeneratedAssetsDirectory: "generated", OutputFormat: "simple", Retry: RetryPolicy{MaxRetries: -1}}
+	if _, err := NewExecutor(&bytes.Buffer{}, &bytes.Buffer{}, opts); err == nil {
+		t.Errorf("expected an error for a negative number of retries")
+	}
+	opts.Retry = RetryPolicy{MaxRetries: 1, Backoff: -time.Second}
+	if _, err := NewExecutor(&bytes.Buffer{}, &bytes.Buffer{}, opts); err == nil {
+		t.Errorf("expected an error for a negative backoff")
+	}
+}
+
+func TestExecuteRetriesHostsThatFailedAfterExit(t *testing.T) {
+	unreachable := &ansible.RunnerUnreachableEvent{}
+	unreachable.Host = "worker1"
+	runner := &lateRunner{
+		events: [][]ansible.Event{{unreachable, &ansible.PlaybookEndEvent{}}},
+		errs:   []error{errors.New("exit status 3")},
+	}
+	e := retryExecutor(t, runner, RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond})
+	summary, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	expected := [][]string{nil, {"worker1"}}
+	if !reflect.DeepEqual(runner.runs, expected) {
+		t.Errorf("expected the runs %v, got %v", expected, runner.runs)
+	}
+	if !reflect.DeepEqual(summary.FailedHosts, []string{"worker1"}) {
+		t.Errorf("expected the failed hosts to be reported, got %v", summary.FailedHosts)
+	}
+}
@@ -0,0 +1,104 @@
+package install
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// touchedHostsRecorder records the hosts that were modified by a playbook,
+// which are the hosts where a task reported changes or failed
+type touchedHostsRecorder struct {
+	mu    sync.Mutex
+	hosts map[string]bool
+}
+
+func newTouchedHostsRecorder() *touchedHostsRecorder {
+	return &touchedHostsRecorder{hosts: map[string]bool{}}
+}
+
+func (r *touchedHostsRecorder) observe(e ansible.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch event := e.(type) {
+	case *ansible.RunnerOKEvent:
+		if event.Result.Changed {
+			r.hosts[event.Host] = true
+		}
+	case *ansible.RunnerItemOKEvent:
+		if event.Result.Changed {
+			r.hosts[event.Host] = true
+		}
+	// A failed task might have partially modified the host
+	case *ansible.RunnerFailedEvent:
+		if !event.IgnoreErrors {
+			r.hosts[event.Host] = true
+		}
+	case *ansible.RunnerItemFailedEvent:
+		if !event.IgnoreErrors {
+			r.hosts[event.Host] = true
+		}
+	}
+}
+
+// touched returns the hosts that were modified, sorted by name
+func (r *touchedHostsRecorder) touched() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hosts := make([]string, 0, len(r.hosts))
+	for h := range r.hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// installedHosts returns the hosts of the plan on which KET had already
+// installed a version of the cluster
+func (ae *ansibleExecutor) installedHosts(p *Plan) (map[string]bool, error) {
+	list := ListInstalledVersions
+	if ae.installedVersions != nil {
+		list = ae.installedVersions
+	}
+	cv, err := list(p)
+	if err != nil {
+		return nil, fmt.Errorf("error determining the nodes that are already installed, which must not be rolled back: %v", err)
+	}
+	hosts := map[string]bool{}
+	for _, n := range cv.Nodes {
+		hosts[n.Node.Host] = true
+	}
+	return hosts, nil
+}
+
+// rollbackInstall resets the hosts that were modified by a failed
+// installation, so that they are not left half configured. The hosts that
+// were installed before the installation are left as they are. It returns
+// the hosts that were reset, the summary of the reset, and an error that
+// describes the installation error and the outcome of the rollback.
+func (ae *ansibleExecutor) rollbackInstall(p *Plan, touched []string, installed map[string]bool, installErr error) ([]string, RunSummary, error) {
+	var hosts, kept []string
+	for _, h := range touched {
+		if installed[h] {
+			kept = append(kept, h)
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	if len(kept) > 0 {
+		util.PrettyPrintWarn(ae.stdout, "The nodes %v were installed before the failed installation, they are not rolled back", kept)
+	}
+	if len(hosts) == 0 {
+		util.PrettyPrintWarn(ae.stdout, "The failed installation did not modify any new node, there is nothing to roll back")
+		return nil, RunSummary{}, installErr
+	}
+	util.PrettyPrintWarn(ae.stdout, "Rolling back the nodes modified by the failed installation: %v", hosts)
+	summary, err := ae.Reset(p, ResetOptions{}, hosts...)
+	if err != nil {
+		return nil, summary, fmt.Errorf("%v. The rollback of the nodes %v failed: %v", installErr, hosts, err)
+	}
+	return hosts, summary, fmt.Errorf("%v. The nodes %v were rolled back", installErr, hosts)
+}
@@ -0,0 +1,220 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+// rollbackRunner fails the installation after changing the changed hosts,
+// and records the nodes each playbook was run on
+type rollbackRunner struct {
+	changed []string
+	runs    map[string][]string
+	err     error
+	sent    chan struct{}
+}
+
+func (r *rollbackRunner) StartPlaybook(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog) (<-chan ansible.Event, error) {
+	return r.StartPlaybookOnNode(playbook, inv, cc)
+}
+
+func (r *rollbackRunner) StartPlaybookOnNode(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog, nodes ...string) (<-chan ansible.Event, error) {
+	r.runs[playbook] = nodes
+	r.err = nil
+	events := make(chan ansible.Event)
+	r.sent = make(chan struct{})
+	var results []ansible.Event
+	if playbook == "kubernetes.yaml" {
+		r.err = errors.New("playbook failed")
+		for _, h := range r.changed {
+			e := &ansible.RunnerOKEvent{}
+			e.Host = h
+			e.Result.Changed = true
+			results = append(results, e)
+		}
+		unchanged := &ansible.RunnerOKEvent{}
+		unchanged.Host = "master01"
+		results = append(results, unchanged)
+	}
+	go func() {
+		defer close(r.sent)
+		defer close(events)
+		for _, e := range results {
+			events <- e
+		}
+		events <- &ansible.PlaybookEndEvent{}
+	}()
+	return events, nil
+}
+
+func (r *rollbackRunner) WaitPlaybook() error {
+	<-r.sent
+	return r.err
+}
+
+func (r *rollbackRunner) Stop() error {
+	return nil
+}
+
+func TestInstallRollbackOnFailure(t *testing.T) {
+	plan := &Plan{
+		Cluster: Cluster{
+			Name:    "test",
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01"}},
+		},
+		Worker: NodeGroup{
+			Nodes: []Node{{Host: "worker01"}, {Host: "worker02"}},
+		},
+	}
+	tests := []struct {
+		rollback         bool
+		changed          []string
+		installed        []string
+		expectedRollback []string
+	}{
+		{rollback: false, changed: []string{"worker01"}},
+		{rollback: true, changed: []string{"worker02", "worker01"}, expectedRollback: []string{"worker01", "worker02"}},
+		{rollback: true},
+		// the nodes of an existing cluster are not rolled back
+		{rollback: true, changed: []string{"master01", "worker02", "worker01"}, installed: []string{"master01", "worker01"}, expectedRollback: []string{"worker02"}},
+		{rollback: true, changed: []string{"master01", "worker01"}, installed: []string{"master01", "worker01", "worker02"}},
+	}
+	for i, test := range tests {
+		r := &rollbackRunner{changed: test.changed, runs: map[string][]string{}}
+		installed := test.installed
+		ae := &ansibleExecutor{
+			options: ExecutorOptions{
+				RunsDirectory:            mustGetTempDir(t),
+				GeneratedAssetsDirectory: mustGetTempDir(t),
+				RollbackOnFailure:        test.rollback,
+			},
+			stdout:              &bytes.Buffer{},
+			consoleOutputFormat: ansible.RawFormat,
+			certsDir:            mustGetTempDir(t),
+			runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+				return r, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+			},
+			installedVersions: func(p *Plan) (ClusterVersion, error) {
+				cv := ClusterVersion{}
+				for _, h := range installed {
+					cv.Nodes = append(cv.Nodes, ListableNode{Node: Node{Host: h}})
+				}
+				return cv, nil
+			},
+		}
+		if _, err := ae.Install(plan, false); err == nil {
+			t.Errorf("test %d: expected the installation to fail", i)
+		}
+		nodes, reset := r.runs["reset.yaml"]
+		if reset != (test.expectedRollback != nil) {
+			t.Errorf("test %d: expected rollback to be %v, but reset ran on %v", i, test.expectedRollback != nil, r.runs)
+			continue
+		}
+		if reset && !reflect.DeepEqual(nodes, test.expectedRollback) {
+			t.Errorf("test %d: expected the nodes %v to be rolled back, but got %v", i, test.expectedRollback, nodes)
+		}
+	}
+}
+
+func TestInstallRollbackOfFailureReceivedAfterExit(t *testing.T) {
+	plan := &Plan{
+		Cluster: Cluster{
+			Name:    "test",
+			Version: "v1.10.3",
+			Networking: NetworkConfig{
+				ServiceCIDRBlock: "10.0.0.0/16",
+			},
+		},
+		Master: MasterNodeGroup{
+			Nodes: []Node{{Host: "master01"}},
+		},
+		Worker: NodeGroup{
+			Nodes: []Node{{Host: "worker01"}},
+		},
+	}
+	play := func(name string) ansible.Event {
+		e := &ansible.PlayStartEvent{}
+		e.Name = name
+		return e
+	}
+	docker := &ansible.RunnerOKEvent{}
+	docker.Host = "master01"
+	docker.Result.Message = phaseMarkerPrefix + "docker"
+	// the only task that touched worker01 failed
+	failed := &ansible.RunnerFailedEvent{}
+	failed.Host = "worker01"
+	r := &lateRunner{
+		events: [][]ansible.Event{
+			{play("docker"), docker, play("kubelet"), failed, &ansible.PlaybookEndEvent{}},
+			{&ansible.PlaybookEndEvent{}},
+		},
+		errs: []error{errors.New("playbook failed"), nil},
+	}
+	runsDir := mustGetTempDir(t)
+	ae := &ansibleExecutor{
+		options: ExecutorOptions{
+			RunsDirectory:            runsDir,
+			GeneratedAssetsDirectory: mustGetTempDir(t),
+			RollbackOnFailure:        true,
+		},
+		stdout:              &bytes.Buffer{},
+		consoleOutputFormat: ansible.RawFormat,
+		certsDir:            mustGetTempDir(t),
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return r, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+		installedVersions: func(p *Plan) (ClusterVersion, error) {
+			return ClusterVersion{}, nil
+		},
+	}
+	if _, err := ae.Install(plan, false); err == nil {
+		t.Fatal("expected the installation to fail")
+	}
+	if !reflect.DeepEqual(r.playbooks, []string{"kubernetes.yaml", "reset.yaml"}) || !reflect.DeepEqual(r.runs[1], []string{"worker01"}) {
+		t.Errorf("expected worker01 to be rolled back, got the playbooks %v on %v", r.playbooks, r.runs)
+	}
+	run, err := lastInstallRun(runsDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c, err := ReadCheckpoint(run)
+	if err != nil {
+		t.Fatalf("unexpected error reading the checkpoint: %v", err)
+	}
+	if c.LastCompletedPlay != "docker" || c.LastCompletedPhase != "docker" || !reflect.DeepEqual(c.CompletedPhases, map[string][]string{"master01": {"docker"}}) {
+		t.Errorf("unexpected checkpoint %+v", c)
+	}
+}
+
+func TestTouchedHostsRecorder(t *testing.T) {
+	r := newTouchedHostsRecorder()
+	changed := &ansible.RunnerOKEvent{}
+	changed.Host = "worker02"
+	changed.Result.Changed = true
+	unchanged := &ansible.RunnerOKEvent{}
+	unchanged.Host = "master01"
+	failed := &ansible.RunnerFailedEvent{}
+	failed.Host = "worker01"
+	ignored := &ansible.RunnerFailedEvent{}
+	ignored.Host = "etcd01"
+	ignored.IgnoreErrors = true
+	for _, e := range []ansible.Event{changed, unchanged, failed, ignored, changed} {
+		r.observe(e)
+	}
+	expected := []string{"worker01", "worker02"}
+	if got := r.touched(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected the touched hosts %v, but got %v", expected, got)
+	}
+}
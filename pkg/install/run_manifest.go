@@ -0,0 +1,147 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const runManifestFilename = "run-manifest.json"
+
+// RunStatus is the status of a task execution
+type RunStatus string
+
+// The statuses of a task execution
+const (
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunAborted   RunStatus = "aborted"
+	RunTimedOut  RunStatus = "timedOut"
+)
+
+// RunManifest is a machine-readable summary of a task execution. It is stored
+// in the run directory alongside the plan and the ansible log.
+type RunManifest struct {
+	// Task is the name of the task that was run
+	Task string `json:"task"`
+	// Playbook that was executed
+	Playbook string `json:"playbook"`
+	// KismaticVersion is the version of the binary that executed the task
+	KismaticVersion string `json:"kismaticVersion"`
+	// StartTime of the execution
+	StartTime time.Time `json:"startTime"`
+	// EndTime of the execution. It is not set while the task is running.
+	EndTime *time.Time `json:"endTime,omitempty"`
+	// Status of the execution
+	Status RunStatus `json:"status,omitempty"`
+	// Error that made the execution fail
+	Error string `json:"error,omitempty"`
+	// Limit is the hosts that the task was limited to. The task ran on all
+	// the hosts of the inventory when it is empty.
+	Limit []string `json:"limit,omitempty"`
+	// IgnoredVersionCompatibility is true when the execution was forced even though
+	// the cluster is running versions that are not supported by this version of Kismatic
+	IgnoredVersionCompatibility bool `json:"ignoredVersionCompatibility,omitempty"`
+	// Timings is the breakdown of the time spent on each play and host. It is
+	// only recorded when the task is run with timings enabled.
+	Timings *Timings `json:"timings,omitempty"`
+	// Changes lists the tasks that reported changes on each host
+	Changes []HostChanges `json:"changes,omitempty"`
+	// ImageVerifications are the results of verifying the provenance of the
+	// images pushed to the private registry
+	ImageVerifications []ImageVerification `json:"imageVerifications,omitempty"`
+	// TimedOut is true when the playbook was terminated because the task, or
+	// one of its plays, exceeded its timeout
+	TimedOut bool `json:"timedOut,omitempty"`
+	// TimeoutReason describes the timeout that was exceeded
+	TimeoutReason string `json:"timeoutReason,omitempty"`
+	// Aborted is true when the playbook was stopped because the operation
+	// was cancelled, such as with Ctrl-C
+	Aborted bool `json:"aborted,omitempty"`
+	// DryRun is true when the playbook was not run, and its inventory, cluster
+	// catalog and plays were rendered in the run directory instead
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// finish records the end of the execution, and its status according to the
+// error returned by the task
+func (m *RunManifest) finish(end time.Time, err error) {
+	m.EndTime = &end
+	switch {
+	case m.Aborted:
+		m.Status = RunAborted
+	case m.TimedOut:
+		m.Status = RunTimedOut
+	case err != nil:
+		m.Status = RunFailed
+	default:
+		m.Status = RunSucceeded
+	}
+	if err != nil {
+		m.Error = err.Error()
+	}
+}
+
+func writeRunManifest(runDirectory string, m RunManifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling run manifest: %v", err)
+	}
+	file := filepath.Join(runDirectory, runManifestFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing run manifest to %s: %v", file, err)
+	}
+	return nil
+}
+
+// Run is a task execution recorded in the runs directory
+type Run struct {
+	// Directory of the run, which has the plan file, the ansible log and the
+	// manifest of the run
+	Directory string `json:"directory"`
+	RunManifest
+}
+
+// ReadRun returns the run recorded in the run directory
+func ReadRun(runDirectory string) (*Run, error) {
+	file := filepath.Join(runDirectory, runManifestFilename)
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s is not the directory of a run, it does not have a %s file", runDirectory, runManifestFilename)
+		}
+		return nil, fmt.Errorf("error reading run manifest %s: %v", file, err)
+	}
+	r := &Run{Directory: runDirectory}
+	if err := json.Unmarshal(b, &r.RunManifest); err != nil {
+		return nil, fmt.Errorf("error unmarshaling run manifest %s: %v", file, err)
+	}
+	return r, nil
+}
+
+// ListRuns returns the runs recorded in the runs directory, the most recent
+// first. The runs directory has a directory for each task, with a directory
+// for each of its runs.
+func ListRuns(runsDirectory string) ([]Run, error) {
+	manifests, err := filepath.Glob(filepath.Join(runsDirectory, "*", "*", runManifestFilename))
+	if err != nil {
+		return nil, fmt.Errorf("error listing runs: %v", err)
+	}
+	runs := []Run{}
+	for _, f := range manifests {
+		r, err := ReadRun(filepath.Dir(f))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *r)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartTime.After(runs[j].StartTime)
+	})
+	return runs, nil
+}
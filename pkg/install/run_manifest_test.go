@@ -0,0 +1,105 @@
+package install
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExecuteRecordsRunResult(t *testing.T) {
+	tests := []struct {
+		failures int
+		status   RunStatus
+	}{
+		{failures: 0, status: RunSucceeded},
+		{failures: 1, status: RunFailed},
+	}
+	for _, test := range tests {
+		runner := &retryRunner{failing: []string{"worker1"}, failures: test.failures}
+		e := retryExecutor(t, runner, RetryPolicy{})
+		e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer(), limit: []string{"worker1"}})
+		runs, err := ListRuns(e.options.RunsDirectory)
+		if err != nil {
+			t.Fatalf("unexpected error listing runs: %v", err)
+		}
+		if len(runs) != 1 {
+			t.Fatalf("expected one run, got %+v", runs)
+		}
+		r := runs[0]
+		if r.Task != "apply" || r.Playbook != "kubernetes.yaml" || r.KismaticVersion != KismaticVersion.String() {
+			t.Errorf("unexpected run: %+v", r)
+		}
+		if r.Status != test.status {
+			t.Errorf("expected the status %q, got %q", test.status, r.Status)
+		}
+		if (r.Error != "") != (test.status == RunFailed) {
+			t.Errorf("unexpected error of a %s run: %q", r.Status, r.Error)
+		}
+		if r.EndTime == nil || r.EndTime.Before(r.StartTime) {
+			t.Errorf("expected the end time to be recorded after the start time, got %v", r.EndTime)
+		}
+		if !reflect.DeepEqual(r.Limit, []string{"worker1"}) {
+			t.Errorf("expected the limit to be recorded, got %v", r.Limit)
+		}
+	}
+}
+
+func TestListRuns(t *testing.T) {
+	dir := mustGetTempDir(t)
+	start := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	manifests := map[string]RunManifest{
+		"apply/2018-06-01-10-00-00":     {Task: "apply", StartTime: start, Status: RunSucceeded},
+		"preflight/2018-06-01-09-00-00": {Task: "preflight", StartTime: start.Add(-time.Hour), Status: RunFailed},
+		"apply/2018-06-01-11-00-00":     {Task: "apply", StartTime: start.Add(time.Hour), Status: RunRunning},
+	}
+	for d, m := range manifests {
+		runDir := filepath.Join(dir, d)
+		if err := os.MkdirAll(runDir, 0755); err != nil {
+			t.Fatalf("error creating run directory: %v", err)
+		}
+		if err := writeRunManifest(runDir, m); err != nil {
+			t.Fatalf("error writing manifest: %v", err)
+		}
+	}
+	// directories without a manifest are not runs
+	os.MkdirAll(filepath.Join(dir, "apply", "2018-06-01-12-00-00"), 0755)
+	runs, err := ListRuns(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, r := range runs {
+		rel, _ := filepath.Rel(dir, r.Directory)
+		got = append(got, rel+" "+string(r.Status))
+	}
+	expected := []string{
+		filepath.Join("apply", "2018-06-01-11-00-00") + " running",
+		filepath.Join("apply", "2018-06-01-10-00-00") + " succeeded",
+		filepath.Join("preflight", "2018-06-01-09-00-00") + " failed",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestReadRun(t *testing.T) {
+	dir := mustGetTempDir(t)
+	if _, err := ReadRun(dir); err == nil {
+		t.Errorf("expected an error reading a directory without a run manifest")
+	}
+	b, _ := json.Marshal(RunManifest{Task: "upgrade-nodes", Status: RunTimedOut})
+	if err := ioutil.WriteFile(filepath.Join(dir, runManifestFilename), b, 0644); err != nil {
+		t.Fatalf("error writing manifest: %v", err)
+	}
+	r, err := ReadRun(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Directory != dir || r.Task != "upgrade-nodes" || r.Status != RunTimedOut {
+		t.Errorf("unexpected run: %+v", r)
+	}
+}
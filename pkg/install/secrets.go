@@ -0,0 +1,332 @@
+package install
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+const (
+	awsSecretsManagerProvider   = "aws_secrets_manager"
+	vaultSecretProvider         = "vault"
+	azureKeyVaultSecretProvider = "azure_key_vault"
+
+	// adminPasswordEnvVar is the environment variable that holds the admin
+	// password fetched from a secrets manager, or generated by KET. Ansible
+	// reads the password from the environment, so that it is not written to
+	// the cluster catalog.
+	adminPasswordEnvVar = "KISMATIC_ADMIN_PASSWORD"
+)
+
+func secretProviders() []string {
+	return []string{awsSecretsManagerProvider, vaultSecretProvider, azureKeyVaultSecretProvider}
+}
+
+// azureKeyVaultCloud is the Azure cloud of a key vault
+type azureKeyVaultCloud struct {
+	// dnsSuffix of the key vaults of the cloud
+	dnsSuffix string
+	// loginURL is the Azure AD endpoint of the cloud
+	loginURL string
+	// resource is the resource of the access tokens to the key vaults
+	resource string
+}
+
+// azureKeyVaultClouds are the clouds whose key vaults the Azure AD token is
+// sent to, the public cloud and the sovereign clouds
+var azureKeyVaultClouds = []azureKeyVaultCloud{
+	{dnsSuffix: ".vault.azure.net", loginURL: "https://login.microsoftonline.com", resource: "https://vault.azure.net"},
+	{dnsSuffix: ".vault.azure.cn", loginURL: "https://login.chinacloudapi.cn", resource: "https://vault.azure.cn"},
+	{dnsSuffix: ".vault.usgovcloudapi.net", loginURL: "https://login.microsoftonline.us", resource: "https://vault.usgovcloudapi.net"},
+	{dnsSuffix: ".vault.microsoftazure.de", loginURL: "https://login.microsoftonline.de", resource: "https://vault.microsoftazure.de"},
+}
+
+// azureKeyVaultCloudOf returns the cloud of the key vault of the secret URL,
+// or an error if the URL is not the https URL of a key vault
+func azureKeyVaultCloudOf(secretURL string) (*azureKeyVaultCloud, error) {
+	u, err := url.Parse(secretURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("secret name %q must be the https URL of the Azure Key Vault secret", secretURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, c := range azureKeyVaultClouds {
+		if strings.HasSuffix(host, c.dnsSuffix) && len(host) > len(c.dnsSuffix) {
+			return &c, nil
+		}
+	}
+	suffixes := []string{}
+	for _, c := range azureKeyVaultClouds {
+		suffixes = append(suffixes, "*"+c.dnsSuffix)
+	}
+	return nil, fmt.Errorf("secret name %q must be the URL of an Azure Key Vault, whose host is one of %s", secretURL, strings.Join(suffixes, ", "))
+}
+
+// secretCache holds the secrets fetched by the current process, so that each
+// secret is fetched once. Secrets are never written to disk.
+var secretCache = struct {
+	sync.Mutex
+	values map[SecretReference]string
+}{values: map[SecretReference]string{}}
+
+// ResolveSecrets fetches the secrets referenced by the plan. The SSH key is
+// added to an SSH agent running in the current process, and the admin
+// password is made available to ansible through the environment.
+func ResolveSecrets(p *Plan) error {
+	r := secretResolver{
+		client: &http.Client{Timeout: 30 * time.Second},
+		getenv: os.Getenv,
+		now:    time.Now,
+	}
+	return r.resolve(p)
+}
+
+// adminPassword returns the admin password that is passed to ansible
+func adminPassword(p *Plan) string {
+	if p.Cluster.AdminPasswordSecret != nil || p.Cluster.GenerateAdminPassword {
+		return fmt.Sprintf("{{ lookup('env', '%s') }}", adminPasswordEnvVar)
+	}
+	return p.Cluster.AdminPassword
+}
+
+type secretResolver struct {
+	client *http.Client
+	getenv func(string) string
+	now    func() time.Time
+
+	// Hooks for testing purposes
+	awsEndpoint   string
+	azureLoginURL string
+	addKeyToAgent func([]byte) error
+	setenv        func(key, value string) error
+}
+
+func (r secretResolver) resolve(p *Plan) error {
+	if ref := p.Cluster.SSH.KeySecret; ref != nil {
+		key, err := r.secret(*ref)
+		if err != nil {
+			return fmt.Errorf("error fetching SSH key secret %q: %v", ref.Name, err)
+		}
+		addKey := ssh.AddKeyToAgent
+		if r.addKeyToAgent != nil {
+			addKey = r.addKeyToAgent
+		}
+		if err := addKey([]byte(key)); err != nil {
+			return fmt.Errorf("error using SSH key secret %q: %v", ref.Name, err)
+		}
+	}
+	if ref := p.Cluster.AdminPasswordSecret; ref != nil {
+		password, err := r.secret(*ref)
+		if err != nil {
+			return fmt.Errorf("error fetching admin password secret %q: %v", ref.Name, err)
+		}
+		setenv := os.Setenv
+		if r.setenv != nil {
+			setenv = r.setenv
+		}
+		if err := setenv(adminPasswordEnvVar, password); err != nil {
+			return fmt.Errorf("error setting admin password: %v", err)
+		}
+	}
+	return nil
+}
+
+// secret returns the value of the secret, from the cache if it was already
+// fetched by this process
+func (r secretResolver) secret(ref SecretReference) (string, error) {
+	secretCache.Lock()
+	defer secretCache.Unlock()
+	if v, ok := secretCache.values[ref]; ok {
+		return v, nil
+	}
+	var v string
+	var err error
+	switch ref.Provider {
+	case awsSecretsManagerProvider:
+		v, err = r.awsSecret(ref)
+	case vaultSecretProvider:
+		v, err = r.vaultSecret(ref)
+	case azureKeyVaultSecretProvider:
+		v, err = r.azureSecret(ref)
+	default:
+		err = fmt.Errorf("secrets manager %q is not supported", ref.Provider)
+	}
+	if err != nil {
+		return "", err
+	}
+	secretCache.values[ref] = v
+	return v, nil
+}
+
+// do sends the request, and decodes the JSON response into v
+func (r secretResolver) do(req *http.Request, v interface{}) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error unmarshaling response: %v", err)
+	}
+	return nil
+}
+
+// secretField returns the field of a secret that is a JSON object
+func secretField(secret, field string) (string, error) {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("the secret is not a JSON object: %v", err)
+	}
+	v, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("the secret does not have a %q string field", field)
+	}
+	return v, nil
+}
+
+func (r secretResolver) awsSecret(ref SecretReference) (string, error) {
+	creds := awsCredentials{
+		AccessKeyID:     r.getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: r.getenv("AWS_SECRET_ACCESS_KEY"),
+		Token:           r.getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return "", fmt.Errorf("the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables must be set")
+	}
+	host := fmt.Sprintf("secretsmanager.%s.amazonaws.com", ref.Region)
+	if strings.HasPrefix(ref.Region, "cn-") {
+		host = host + ".cn"
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": ref.Name})
+	if err != nil {
+		return "", err
+	}
+	endpoint := "https://" + host + "/"
+	if r.awsEndpoint != "" {
+		endpoint = r.awsEndpoint + "/"
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Host = host
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	for k, v := range signAWSRequestV4("POST", host, "/", "", body, ref.Region, "secretsmanager", creds, r.now()) {
+		req.Header.Set(k, v)
+	}
+	resp := struct {
+		SecretString string
+		SecretBinary string
+	}{}
+	if err := r.do(req, &resp); err != nil {
+		return "", err
+	}
+	secret := resp.SecretString
+	if secret == "" && resp.SecretBinary != "" {
+		b, err := base64.StdEncoding.DecodeString(resp.SecretBinary)
+		if err != nil {
+			return "", fmt.Errorf("error decoding binary secret: %v", err)
+		}
+		secret = string(b)
+	}
+	if ref.Field == "" {
+		return secret, nil
+	}
+	return secretField(secret, ref.Field)
+}
+
+func (r secretResolver) vaultSecret(ref SecretReference) (string, error) {
+	addr, token := r.getenv("VAULT_ADDR"), r.getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("the VAULT_ADDR and VAULT_TOKEN environment variables must be set")
+	}
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(ref.Name, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp := struct {
+		Data map[string]interface{}
+	}{}
+	if err := r.do(req, &resp); err != nil {
+		return "", err
+	}
+	data := resp.Data
+	// version 2 of the key/value secrets engine nests the secret, along with
+	// its metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	v, ok := data[ref.Field].(string)
+	if !ok {
+		return "", fmt.Errorf("the secret does not have a %q string field", ref.Field)
+	}
+	return v, nil
+}
+
+func (r secretResolver) azureSecret(ref SecretReference) (string, error) {
+	tenant, clientID, clientSecret := r.getenv("AZURE_TENANT_ID"), r.getenv("AZURE_CLIENT_ID"), r.getenv("AZURE_CLIENT_SECRET")
+	if tenant == "" || clientID == "" || clientSecret == "" {
+		return "", fmt.Errorf("the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET environment variables must be set")
+	}
+	// The access token is only sent to the key vaults of the Azure clouds
+	cloud, err := azureKeyVaultCloudOf(ref.Name)
+	if err != nil {
+		return "", err
+	}
+	loginURL := cloud.loginURL
+	if r.azureLoginURL != "" {
+		loginURL = r.azureLoginURL
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"resource":      {cloud.resource},
+	}
+	req, err := http.NewRequest("POST", loginURL+"/"+tenant+"/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := r.do(req, &token); err != nil {
+		return "", fmt.Errorf("error getting Azure access token: %v", err)
+	}
+	req, err = http.NewRequest("GET", ref.Name+"?api-version=7.0", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	secret := struct {
+		Value string
+	}{}
+	if err := r.do(req, &secret); err != nil {
+		return "", err
+	}
+	if ref.Field == "" {
+		return secret.Value, nil
+	}
+	return secretField(secret.Value, ref.Field)
+}
@@ -0,0 +1,269 @@
+package install
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func resetSecretCache() {
+	secretCache.Lock()
+	secretCache.values = map[SecretReference]string{}
+	secretCache.Unlock()
+}
+
+func testSecretResolver(env map[string]string) secretResolver {
+	return secretResolver{
+		client: http.DefaultClient,
+		getenv: func(k string) string { return env[k] },
+		now:    func() time.Time { return time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC) },
+	}
+}
+
+func TestAWSSecret(t *testing.T) {
+	resetSecretCache()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" || r.Host != "secretsmanager.us-east-1.amazonaws.com" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20180601/us-east-1/secretsmanager/aws4_request") {
+			http.Error(w, "unexpected authorization", http.StatusForbidden)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		req := map[string]string{}
+		json.Unmarshal(body, &req)
+		secrets := map[string]string{
+			"ssh-key":     "KEY",
+			"credentials": `{"password": "PASSWORD"}`,
+		}
+		secret, ok := secrets[req["SecretId"]]
+		if !ok {
+			http.Error(w, `{"__type": "ResourceNotFoundException"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": secret})
+	}))
+	defer server.Close()
+
+	r := testSecretResolver(map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret"})
+	r.awsEndpoint = server.URL
+	tests := []struct {
+		ref      SecretReference
+		expected string
+		valid    bool
+	}{
+		{ref: SecretReference{Provider: "aws_secrets_manager", Name: "ssh-key", Region: "us-east-1"}, expected: "KEY", valid: true},
+		{ref: SecretReference{Provider: "aws_secrets_manager", Name: "credentials", Field: "password", Region: "us-east-1"}, expected: "PASSWORD", valid: true},
+		{ref: SecretReference{Provider: "aws_secrets_manager", Name: "credentials", Field: "other", Region: "us-east-1"}, valid: false},
+		{ref: SecretReference{Provider: "aws_secrets_manager", Name: "missing", Region: "us-east-1"}, valid: false},
+	}
+	for i, test := range tests {
+		v, err := r.secret(test.ref)
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid %v, got error %v", i, test.valid, err)
+		}
+		if v != test.expected {
+			t.Errorf("test %d: expected %q, got %q", i, test.expected, v)
+		}
+	}
+
+	// the secrets are cached
+	before := requests
+	if _, err := r.secret(tests[0].ref); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != before {
+		t.Errorf("expected the secret to be read from the cache")
+	}
+
+	r.getenv = func(string) string { return "" }
+	if _, err := r.secret(SecretReference{Provider: "aws_secrets_manager", Name: "other", Region: "us-east-1"}); err == nil {
+		t.Errorf("expected an error when the AWS credentials are not set")
+	}
+}
+
+func TestVaultSecret(t *testing.T) {
+	resetSecretCache()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, `{"errors": ["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/kismatic":
+			w.Write([]byte(`{"data": {"ssh_key": "KEY"}}`))
+		case "/v1/secret/data/kismatic":
+			w.Write([]byte(`{"data": {"data": {"ssh_key": "KEY2"}, "metadata": {"version": 1}}}`))
+		default:
+			http.Error(w, `{"errors": []}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r := testSecretResolver(map[string]string{"VAULT_ADDR": server.URL + "/", "VAULT_TOKEN": "token"})
+	tests := []struct {
+		ref      SecretReference
+		expected string
+		valid    bool
+	}{
+		{ref: SecretReference{Provider: "vault", Name: "secret/kismatic", Field: "ssh_key"}, expected: "KEY", valid: true},
+		{ref: SecretReference{Provider: "vault", Name: "secret/data/kismatic", Field: "ssh_key"}, expected: "KEY2", valid: true},
+		{ref: SecretReference{Provider: "vault", Name: "secret/kismatic", Field: "password"}, valid: false},
+		{ref: SecretReference{Provider: "vault", Name: "secret/missing", Field: "ssh_key"}, valid: false},
+	}
+	for i, test := range tests {
+		v, err := r.secret(test.ref)
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid %v, got error %v", i, test.valid, err)
+		}
+		if v != test.expected {
+			t.Errorf("test %d: expected %q, got %q", i, test.expected, v)
+		}
+	}
+}
+
+func TestAzureKeyVaultSecret(t *testing.T) {
+	resetSecretCache()
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/tenant/oauth2/token":
+			r.ParseForm()
+			if r.Form.Get("client_id") != "client" || r.Form.Get("client_secret") != "secret" || r.Form.Get("resource") != "https://vault.azure.net" {
+				http.Error(w, "invalid client", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "token"}`))
+		case r.URL.Path == "/secrets/ssh-key" && r.URL.Query().Get("api-version") != "":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"value": "KEY"}`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r := testSecretResolver(map[string]string{"AZURE_TENANT_ID": "tenant", "AZURE_CLIENT_ID": "client", "AZURE_CLIENT_SECRET": "secret"})
+	r.azureLoginURL = server.URL
+	// The requests to the key vault are sent to the test server
+	r.client = &http.Client{Transport: &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial(network, server.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	v, err := r.secret(SecretReference{Provider: "azure_key_vault", Name: "https://myvault.vault.azure.net/secrets/ssh-key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != "KEY" {
+		t.Errorf("expected %q, got %q", "KEY", v)
+	}
+	if _, err := r.secret(SecretReference{Provider: "azure_key_vault", Name: "https://myvault.vault.azure.net/secrets/missing"}); err == nil {
+		t.Errorf("expected an error when the secret does not exist")
+	}
+
+	// The access token is not sent to other hosts
+	requests = 0
+	if _, err := r.secret(SecretReference{Provider: "azure_key_vault", Name: server.URL + "/secrets/ssh-key"}); err == nil {
+		t.Errorf("expected an error when the secret is not in an Azure Key Vault")
+	}
+	if requests != 0 {
+		t.Errorf("expected no request when the secret is not in an Azure Key Vault, got %d", requests)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	resetSecretCache()
+	keyRef := SecretReference{Provider: "vault", Name: "secret/kismatic", Field: "ssh_key"}
+	passwordRef := SecretReference{Provider: "vault", Name: "secret/kismatic", Field: "password"}
+	secretCache.values[keyRef] = "KEY"
+	secretCache.values[passwordRef] = "PASSWORD"
+
+	var agentKeys []string
+	env := map[string]string{}
+	r := testSecretResolver(nil)
+	r.addKeyToAgent = func(key []byte) error {
+		agentKeys = append(agentKeys, string(key))
+		return nil
+	}
+	r.setenv = func(k, v string) error {
+		env[k] = v
+		return nil
+	}
+	p := &Plan{}
+	p.Cluster.SSH.KeySecret = &keyRef
+	p.Cluster.AdminPasswordSecret = &passwordRef
+	if err := r.resolve(p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(agentKeys) != 1 || agentKeys[0] != "KEY" {
+		t.Errorf("expected the key to be added to the agent, got %v", agentKeys)
+	}
+	if env[adminPasswordEnvVar] != "PASSWORD" {
+		t.Errorf("expected the admin password to be set in the environment, got %v", env)
+	}
+	if p.Cluster.SSH.Key != "" || p.Cluster.AdminPassword != "" {
+		t.Errorf("expected the secrets to not be set in the plan")
+	}
+	if adminPassword(p) != "{{ lookup('env', 'KISMATIC_ADMIN_PASSWORD') }}" {
+		t.Errorf("unexpected admin password for ansible: %s", adminPassword(p))
+	}
+}
+
+func TestValidateSecretReference(t *testing.T) {
+	tests := []struct {
+		ref   SecretReference
+		valid bool
+	}{
+		{ref: SecretReference{Provider: "aws_secrets_manager", Name: "ssh-key", Region: "us-east-1"}, valid: true},
+		{ref: SecretReference{Provider: "aws_secrets_manager", Name: "ssh-key"}, valid: false},
+		{ref: SecretReference{Provider: "vault", Name: "secret/kismatic", Field: "ssh_key"}, valid: true},
+		{ref: SecretReference{Provider: "vault", Name: "secret/kismatic"}, valid: false},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "https://myvault.vault.azure.net/secrets/ssh-key"}, valid: true},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "ssh-key"}, valid: false},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "https://myvault.vault.azure.cn/secrets/ssh-key"}, valid: true},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "https://myvault.vault.usgovcloudapi.net/secrets/ssh-key"}, valid: true},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "https://myvault.vault.microsoftazure.de/secrets/ssh-key"}, valid: true},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "http://myvault.vault.azure.net/secrets/ssh-key"}, valid: false},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "https://evil.example.com/secrets/ssh-key"}, valid: false},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "https://myvault.vault.azure.net.example.com/secrets/ssh-key"}, valid: false},
+		{ref: SecretReference{Provider: "azure_key_vault", Name: "https://vault.azure.net/secrets/ssh-key"}, valid: false},
+		{ref: SecretReference{Provider: "keychain", Name: "ssh-key"}, valid: false},
+		{ref: SecretReference{Provider: "vault", Field: "ssh_key"}, valid: false},
+	}
+	for i, test := range tests {
+		if valid, errs := test.ref.validate(); valid != test.valid {
+			t.Errorf("test %d: expected valid %v, got %v: %v", i, test.valid, valid, errs)
+		}
+	}
+}
+
+func TestValidateSSHConfigKeySecret(t *testing.T) {
+	s := SSHConfig{
+		User:      "root",
+		Port:      22,
+		KeySecret: &SecretReference{Provider: "vault", Name: "secret/kismatic", Field: "ssh_key"},
+	}
+	if valid, errs := s.validate(); !valid {
+		t.Errorf("expected the SSH config to be valid, got %v", errs)
+	}
+	s.Key = "/bin/sh"
+	if valid, _ := s.validate(); valid {
+		t.Errorf("expected an error when both the key and the key secret are set")
+	}
+}
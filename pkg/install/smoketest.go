@@ -0,0 +1,328 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+const (
+	// KuberangSmokeTestEngine runs the smoke test using the kuberang binary on a master node
+	KuberangSmokeTestEngine = "kuberang"
+	// NativeSmokeTestEngine runs the smoke test using kubectl on a master node,
+	// without requiring any additional binaries
+	NativeSmokeTestEngine = "native"
+)
+
+// SmokeTestEngines returns the engines that can be used to run the smoke test
+func SmokeTestEngines() []string {
+	return []string{KuberangSmokeTestEngine, NativeSmokeTestEngine}
+}
+
+// A SmokeTester verifies that the cluster described by the plan is working
+// as expected
+type SmokeTester interface {
+	RunSmokeTest(*Plan) error
+}
+
+// smokeTestClient is the Kubernetes client used by the native smoke test
+type smokeTestClient interface {
+	data.ManifestApplier
+	data.NamespaceDeleter
+	data.DeploymentGetter
+	data.PodGetter
+	data.ServiceGetter
+	data.PersistentVolumeClaimGetter
+	data.StorageClassLister
+}
+
+// nativeSmokeTester deploys a workload on the cluster and verifies that it is
+// rolled out, that it is reachable through a service, that the service can be
+// resolved using the cluster DNS, and that persistent volume claims are bound.
+type nativeSmokeTester struct {
+	out       io.Writer
+	newClient func(*Plan) (smokeTestClient, error)
+	// timeout for each check
+	timeout      time.Duration
+	pollInterval time.Duration
+	now          func() time.Time
+}
+
+func newNativeSmokeTester(out io.Writer) *nativeSmokeTester {
+	return &nativeSmokeTester{
+		out: out,
+		newClient: func(p *Plan) (smokeTestClient, error) {
+			// Use the first master node for running kubectl
+			client, err := p.GetSSHClient(p.Master.Nodes[0].Host)
+			if err != nil {
+				return nil, fmt.Errorf("error getting SSH client: %v", err)
+			}
+			return data.RemoteKubectl{SSHClient: client}, nil
+		},
+		timeout:      5 * time.Minute,
+		pollInterval: 2 * time.Second,
+		now:          time.Now,
+	}
+}
+
+// smokeTestRun holds the state of a single smoke test run
+type smokeTestRun struct {
+	*nativeSmokeTester
+	client    smokeTestClient
+	namespace string
+	nginx     string
+	busybox   string
+	replicas  int
+	// node is the host that the workload is pinned to. The workload is spread
+	// across the nodes of the cluster when empty.
+	node string
+}
+
+type smokeTestCheck struct {
+	name string
+	run  func() error
+	// skip returns the reason for skipping the check, if it should be skipped
+	skip func() string
+}
+
+func (t *nativeSmokeTester) RunSmokeTest(p *Plan) error {
+	return t.run(p, "")
+}
+
+// RunNodeSmokeTest runs the smoke test with the workload pinned to the node.
+// The checks that do not depend on the node are skipped.
+func (t *nativeSmokeTester) RunNodeSmokeTest(p *Plan, host string) error {
+	return t.run(p, host)
+}
+
+func (t *nativeSmokeTester) run(p *Plan, node string) error {
+	client, err := t.newClient(p)
+	if err != nil {
+		return err
+	}
+	run := &smokeTestRun{
+		nativeSmokeTester: t,
+		client:            client,
+		// namespaces are deleted asynchronously, so use a new one on every run
+		namespace: fmt.Sprintf("kismatic-smoketest-%d", t.now().Unix()),
+		nginx:     smokeTestImage(p, "nginx:stable-alpine"),
+		busybox:   smokeTestImage(p, "busybox:latest"),
+		replicas:  len(p.Worker.Nodes),
+		node:      node,
+	}
+	if run.replicas == 0 || node != "" {
+		run.replicas = 1
+	}
+	defer func() {
+		if err := client.DeleteNamespace(run.namespace); err != nil {
+			util.PrettyPrintWarn(t.out, "Could not clean up the smoke test namespace %q: %v", run.namespace, err)
+		}
+	}()
+
+	checks := []smokeTestCheck{
+		{name: "Deployment rollout", run: run.deploymentRollout},
+		{name: "Service reachability", run: run.serviceReachability},
+		{
+			name: "DNS resolution",
+			run:  run.dnsResolution,
+			skip: func() string {
+				if p.AddOns.DNS.Disable {
+					return "the DNS add-on is disabled"
+				}
+				return ""
+			},
+		},
+		{name: "Persistent volume claim binding", run: run.claimBinding, skip: run.skipClaimBinding},
+	}
+	if node != "" {
+		fmt.Fprintf(t.out, "Running the smoke test on node %q\n", node)
+		checks[len(checks)-1].skip = func() string { return "the check does not depend on the node" }
+	}
+	for _, c := range checks {
+		if c.skip != nil {
+			if reason := c.skip(); reason != "" {
+				util.PrettyPrintSkipped(t.out, "%s (%s)", c.name, reason)
+				continue
+			}
+		}
+		util.PrettyPrint(t.out, "%s", c.name)
+		if err := c.run(); err != nil {
+			util.PrintError(t.out)
+			return fmt.Errorf("smoke test check %q failed: %v", c.name, err)
+		}
+		util.PrintOkln(t.out)
+	}
+	return nil
+}
+
+// smokeTestImage returns the image that should be used by the smoke test,
+// taking into account the private registry of disconnected installations.
+func smokeTestImage(p *Plan, image string) string {
+	if p.Cluster.DisconnectedInstallation && p.PrivateRegistryProvided() {
+		return p.DockerRegistry.Server + "/" + image
+	}
+	return image
+}
+
+// waitFor polls the condition until it returns true, an error, or the check
+// times out
+func (r *smokeTestRun) waitFor(what string, cond func() (bool, error)) error {
+	deadline := r.now().Add(r.timeout)
+	for {
+		done, err := cond()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if !r.now().Before(deadline) {
+			return fmt.Errorf("timed out after %v waiting for %s", r.timeout, what)
+		}
+		time.Sleep(r.pollInterval)
+	}
+}
+
+func (r *smokeTestRun) deploymentRollout() error {
+	manifest := fmt.Sprintf(`{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {
+      "apiVersion": "v1",
+      "kind": "Namespace",
+      "metadata": {"name": %[1]q}
+    },
+    {
+      "apiVersion": "apps/v1",
+      "kind": "Deployment",
+      "metadata": {"name": "nginx", "namespace": %[1]q},
+      "spec": {
+        "replicas": %[2]d,
+        "selector": {"matchLabels": {"app": "nginx"}},
+        "template": {
+          "metadata": {"labels": {"app": "nginx"}},
+          "spec": {%[4]s
+            "affinity": {
+              "podAntiAffinity": {
+                "preferredDuringSchedulingIgnoredDuringExecution": [{
+                  "weight": 100,
+                  "podAffinityTerm": {"labelSelector": {"matchLabels": {"app": "nginx"}}, "topologyKey": "kubernetes.io/hostname"}
+                }]
+              }
+            },
+            "containers": [{"name": "nginx", "image": %[3]q, "ports": [{"containerPort": 80}]}]
+          }
+        }
+      }
+    },
+    {
+      "apiVersion": "v1",
+      "kind": "Service",
+      "metadata": {"name": "nginx", "namespace": %[1]q},
+      "spec": {"selector": {"app": "nginx"}, "ports": [{"port": 80}]}
+    }
+  ]
+}`, r.namespace, r.replicas, r.nginx, r.nodeName())
+	if err := r.client.Apply(manifest); err != nil {
+		return err
+	}
+	return r.waitFor("the deployment to be available", func() (bool, error) {
+		d, err := r.client.GetDeployment(r.namespace, "nginx")
+		if err != nil {
+			return false, err
+		}
+		return d.Status.UpdatedReplicas == int32(r.replicas) && d.Status.AvailableReplicas == int32(r.replicas), nil
+	})
+}
+
+func (r *smokeTestRun) serviceReachability() error {
+	svc, err := r.client.GetService(r.namespace, "nginx")
+	if err != nil {
+		return err
+	}
+	if svc.Spec.ClusterIP == "" {
+		return fmt.Errorf("service %s/nginx was not assigned a cluster IP", r.namespace)
+	}
+	return r.runPod("service-reachability", "wget -q -T 5 -O /dev/null http://"+svc.Spec.ClusterIP)
+}
+
+func (r *smokeTestRun) dnsResolution() error {
+	return r.runPod("dns-resolution", fmt.Sprintf("nslookup nginx.%s.svc.cluster.local", r.namespace))
+}
+
+// skipClaimBinding returns the reason for skipping the claim binding check
+// if the cluster has no default storage class to provision the volume
+func (r *smokeTestRun) skipClaimBinding() string {
+	classes, err := r.client.ListStorageClasses()
+	if err != nil {
+		return fmt.Sprintf("could not list storage classes: %v", err)
+	}
+	for _, c := range classes.Items {
+		if c.IsDefault() {
+			return ""
+		}
+	}
+	return "the cluster has no default storage class"
+}
+
+func (r *smokeTestRun) claimBinding() error {
+	manifest := fmt.Sprintf(`{
+  "apiVersion": "v1",
+  "kind": "PersistentVolumeClaim",
+  "metadata": {"name": "smoketest", "namespace": %q},
+  "spec": {"accessModes": ["ReadWriteOnce"], "resources": {"requests": {"storage": "1Mi"}}}
+}`, r.namespace)
+	if err := r.client.Apply(manifest); err != nil {
+		return err
+	}
+	return r.waitFor("the persistent volume claim to be bound", func() (bool, error) {
+		pvc, err := r.client.GetPersistentVolumeClaim(r.namespace, "smoketest")
+		if err != nil {
+			return false, err
+		}
+		return pvc.Status.Phase == data.ClaimBound, nil
+	})
+}
+
+// runPod runs the shell command in a busybox pod, and returns an error if the
+// command does not succeed
+func (r *smokeTestRun) runPod(name, command string) error {
+	manifest := fmt.Sprintf(`{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {"name": %[1]q, "namespace": %[2]q},
+  "spec": {%[5]s
+    "restartPolicy": "Never",
+    "containers": [{"name": "busybox", "image": %[3]q, "command": ["sh", "-c", %[4]q]}]
+  }
+}`, name, r.namespace, r.busybox, command, r.nodeName())
+	if err := r.client.Apply(manifest); err != nil {
+		return err
+	}
+	return r.waitFor(fmt.Sprintf("pod %q to complete", name), func() (bool, error) {
+		pod, err := r.client.GetPod(r.namespace, name)
+		if err != nil {
+			return false, err
+		}
+		switch pod.Status.Phase {
+		case data.PodSucceeded:
+			return true, nil
+		case data.PodFailed:
+			return false, fmt.Errorf("command %q failed in pod %q: %s", command, name, pod.Status.Message)
+		}
+		return false, nil
+	})
+}
+
+// nodeName returns the field of the pod spec that pins the pod to the node of
+// the run, if any
+func (r *smokeTestRun) nodeName() string {
+	if r.node == "" {
+		return ""
+	}
+	return fmt.Sprintf(`"nodeName": %q,`, r.node)
+}
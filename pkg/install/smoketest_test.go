@@ -0,0 +1,219 @@
+package install
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/data"
+)
+
+type fakeSmokeTestClient struct {
+	applied           []string
+	deletedNamespaces []string
+	deployment        data.Deployment
+	service           data.Service
+	podPhases         map[string]data.PodPhase
+	claimPhase        data.PersistentVolumeClaimPhase
+	storageClasses    []data.StorageClass
+	applyErr          error
+}
+
+func (c *fakeSmokeTestClient) Apply(manifest string) error {
+	c.applied = append(c.applied, manifest)
+	return c.applyErr
+}
+
+func (c *fakeSmokeTestClient) DeleteNamespace(name string) error {
+	c.deletedNamespaces = append(c.deletedNamespaces, name)
+	return nil
+}
+
+func (c *fakeSmokeTestClient) GetDeployment(namespace, name string) (*data.Deployment, error) {
+	return &c.deployment, nil
+}
+
+func (c *fakeSmokeTestClient) GetPod(namespace, name string) (*data.Pod, error) {
+	return &data.Pod{Status: data.PodStatus{Phase: c.podPhases[name]}}, nil
+}
+
+func (c *fakeSmokeTestClient) GetService(namespace, name string) (*data.Service, error) {
+	return &c.service, nil
+}
+
+func (c *fakeSmokeTestClient) GetPersistentVolumeClaim(namespace, name string) (*data.PersistentVolumeClaim, error) {
+	return &data.PersistentVolumeClaim{Status: data.PersistentVolumeClaimStatus{Phase: c.claimPhase}}, nil
+}
+
+func (c *fakeSmokeTestClient) ListStorageClasses() (*data.StorageClassList, error) {
+	return &data.StorageClassList{Items: c.storageClasses}, nil
+}
+
+func healthySmokeTestClient() *fakeSmokeTestClient {
+	return &fakeSmokeTestClient{
+		deployment: data.Deployment{Status: data.DeploymentStatus{UpdatedReplicas: 2, AvailableReplicas: 2}},
+		service:    data.Service{Spec: data.ServiceSpec{ClusterIP: "172.20.0.10"}},
+		podPhases: map[string]data.PodPhase{
+			"service-reachability": data.PodSucceeded,
+			"dns-resolution":       data.PodSucceeded,
+		},
+		claimPhase: data.ClaimBound,
+	}
+}
+
+func smokeTestPlan() *Plan {
+	return &Plan{
+		Master: MasterNodeGroup{Nodes: []Node{{Host: "master"}}},
+		Worker: NodeGroup{Nodes: []Node{{Host: "worker1"}, {Host: "worker2"}}},
+	}
+}
+
+func testSmokeTester(out *bytes.Buffer, client *fakeSmokeTestClient) *nativeSmokeTester {
+	return &nativeSmokeTester{
+		out:          out,
+		newClient:    func(*Plan) (smokeTestClient, error) { return client, nil },
+		timeout:      50 * time.Millisecond,
+		pollInterval: time.Millisecond,
+		now:          time.Now,
+	}
+}
+
+func TestNativeSmokeTestSucceeds(t *testing.T) {
+	out := &bytes.Buffer{}
+	client := healthySmokeTestClient()
+	client.storageClasses = []data.StorageClass{
+		{ObjectMeta: data.ObjectMeta{Name: "standard", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}}},
+	}
+	if err := testSmokeTester(out, client).RunSmokeTest(smokeTestPlan()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// deployment, reachability pod, DNS pod and claim
+	if len(client.applied) != 4 {
+		t.Errorf("expected 4 manifests to be applied, got %d", len(client.applied))
+	}
+	if !strings.Contains(client.applied[0], `"replicas": 2`) {
+		t.Errorf("expected a replica per worker node, got manifest:\n%s", client.applied[0])
+	}
+	if !strings.Contains(client.applied[1], "http://172.20.0.10") {
+		t.Errorf("expected the service to be reached through its cluster IP, got manifest:\n%s", client.applied[1])
+	}
+	if len(client.deletedNamespaces) != 1 {
+		t.Errorf("expected the smoke test namespace to be deleted, got %v", client.deletedNamespaces)
+	}
+	if strings.Contains(out.String(), "Skipped") {
+		t.Errorf("expected no checks to be skipped, got:\n%s", out.String())
+	}
+}
+
+func TestNativeSmokeTestSkipsChecks(t *testing.T) {
+	out := &bytes.Buffer{}
+	client := healthySmokeTestClient()
+	delete(client.podPhases, "dns-resolution")
+	p := smokeTestPlan()
+	p.AddOns.DNS.Disable = true
+	if err := testSmokeTester(out, client).RunSmokeTest(p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.applied) != 2 {
+		t.Errorf("expected 2 manifests to be applied, got %d", len(client.applied))
+	}
+	for _, reason := range []string{"the DNS add-on is disabled", "the cluster has no default storage class"} {
+		if !strings.Contains(out.String(), reason) {
+			t.Errorf("expected output to contain %q, got:\n%s", reason, out.String())
+		}
+	}
+}
+
+func TestNativeSmokeTestFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		modify        func(*fakeSmokeTestClient)
+		expectedCheck string
+	}{
+		{
+			name:          "deployment never becomes available",
+			modify:        func(c *fakeSmokeTestClient) { c.deployment.Status.AvailableReplicas = 1 },
+			expectedCheck: "Deployment rollout",
+		},
+		{
+			name:          "manifest cannot be applied",
+			modify:        func(c *fakeSmokeTestClient) { c.applyErr = errors.New("forbidden") },
+			expectedCheck: "Deployment rollout",
+		},
+		{
+			name:          "service is not reachable",
+			modify:        func(c *fakeSmokeTestClient) { c.podPhases["service-reachability"] = data.PodFailed },
+			expectedCheck: "Service reachability",
+		},
+		{
+			name:          "service name does not resolve",
+			modify:        func(c *fakeSmokeTestClient) { c.podPhases["dns-resolution"] = data.PodFailed },
+			expectedCheck: "DNS resolution",
+		},
+	}
+	for _, test := range tests {
+		client := healthySmokeTestClient()
+		test.modify(client)
+		err := testSmokeTester(&bytes.Buffer{}, client).RunSmokeTest(smokeTestPlan())
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expectedCheck) {
+			t.Errorf("%s: expected check %q to fail, got: %v", test.name, test.expectedCheck, err)
+		}
+		if len(client.deletedNamespaces) != 1 {
+			t.Errorf("%s: expected the smoke test namespace to be deleted", test.name)
+		}
+	}
+}
+
+func TestSmokeTestImage(t *testing.T) {
+	p := smokeTestPlan()
+	if img := smokeTestImage(p, "busybox:latest"); img != "busybox:latest" {
+		t.Errorf("expected upstream image, got %q", img)
+	}
+	p.Cluster.DisconnectedInstallation = true
+	p.DockerRegistry.Server = "registry.local:5000"
+	if img := smokeTestImage(p, "busybox:latest"); img != "registry.local:5000/busybox:latest" {
+		t.Errorf("expected image from the private registry, got %q", img)
+	}
+}
+
+func TestNewExecutorSmokeTestEngine(t *testing.T) {
+	opts := ExecutorOptions{GeneratedAssetsDirectory: "generated", OutputFormat: "simple", SmokeTestEngine: "sonobuoy"}
+	if _, err := NewExecutor(&bytes.Buffer{}, &bytes.Buffer{}, opts); err == nil {
+		t.Errorf("expected an error for an unsupported smoke test engine")
+	}
+	opts.SmokeTestEngine = NativeSmokeTestEngine
+	e, err := NewExecutor(&bytes.Buffer{}, &bytes.Buffer{}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.(*ansibleExecutor).smokeTester == nil {
+		t.Errorf("expected the native smoke tester to be used")
+	}
+}
+
+func TestNativeNodeSmokeTest(t *testing.T) {
+	out := &bytes.Buffer{}
+	client := healthySmokeTestClient()
+	client.deployment.Status = data.DeploymentStatus{UpdatedReplicas: 1, AvailableReplicas: 1}
+	if err := testSmokeTester(out, client).RunNodeSmokeTest(smokeTestPlan(), "worker2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// deployment, reachability pod and DNS pod
+	if len(client.applied) != 3 {
+		t.Fatalf("expected 3 manifests to be applied, got %d", len(client.applied))
+	}
+	if !strings.Contains(client.applied[0], `"replicas": 1`) {
+		t.Errorf("expected a single replica, got manifest:\n%s", client.applied[0])
+	}
+	for _, m := range client.applied {
+		if !strings.Contains(m, `"nodeName": "worker2"`) {
+			t.Errorf("expected the workload to be pinned to the node, got manifest:\n%s", m)
+		}
+	}
+}
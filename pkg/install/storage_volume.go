@@ -0,0 +1,68 @@
+package install
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// InsufficientStorageError is returned when the storage cluster does not have
+// enough nodes with the free disk space required by the bricks of a volume
+type InsufficientStorageError struct {
+	// Required is the number of storage nodes required by the volume
+	Required int
+	// Available is the number of storage nodes that have enough free disk space
+	Available int
+	// SizeGB is the size of the volume
+	SizeGB int
+}
+
+func (e InsufficientStorageError) Error() string {
+	return fmt.Sprintf("the requested volume configuration requires %d storage nodes with more than %dGB of free disk space, but the cluster only has %d", e.Required, e.SizeGB, e.Available)
+}
+
+// validateStorageVolumeCapacity verifies that there are enough storage nodes
+// with the free disk space required by the bricks of the volume. Every brick is
+// created on a different node, in the root filesystem, and can hold up to the
+// size of the volume.
+func validateStorageVolumeCapacity(p *Plan, sv StorageVolume, sshClient func(p *Plan, host string) (ssh.Client, error)) error {
+	required := sv.ReplicateCount * sv.DistributionCount
+	if required > len(p.Storage.Nodes) {
+		return InsufficientStorageError{Required: required, Available: len(p.Storage.Nodes), SizeGB: sv.SizeGB}
+	}
+	brickBytes := uint64(sv.SizeGB) * (1 << (10 * 3))
+	available := 0
+	for _, n := range p.Storage.Nodes {
+		client, err := sshClient(p, n.Host)
+		if err != nil {
+			return fmt.Errorf("error getting SSH client for %q: %v", n.Host, err)
+		}
+		free, err := freeDiskSpaceBytes(client, "/")
+		if err != nil {
+			return fmt.Errorf("error getting free disk space of %q: %v", n.Host, err)
+		}
+		if free > brickBytes {
+			available++
+		}
+	}
+	if available < required {
+		return InsufficientStorageError{Required: required, Available: available, SizeGB: sv.SizeGB}
+	}
+	return nil
+}
+
+// freeDiskSpaceBytes returns the free space of the filesystem mounted at the path
+func freeDiskSpaceBytes(client ssh.Client, path string) (uint64, error) {
+	out, err := client.Output(true, "df --output=avail -B1 "+path)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %s", err, strings.TrimSpace(out))
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	free, err := strconv.ParseUint(strings.TrimSpace(lines[len(lines)-1]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output of df: %q", out)
+	}
+	return free, nil
+}
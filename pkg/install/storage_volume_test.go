@@ -0,0 +1,77 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ssh"
+)
+
+// fakeDiskSpace reports the free disk space of the nodes, in GB
+type fakeDiskSpace struct {
+	freeGB int
+	err    error
+}
+
+func (d fakeDiskSpace) Output(pty bool, args ...string) (string, error) {
+	if d.err != nil {
+		return "", d.err
+	}
+	return fmt.Sprintf("   Avail\n%d\n", uint64(d.freeGB)*(1<<30)), nil
+}
+
+func (d fakeDiskSpace) Shell(pty bool, args ...string) error { return nil }
+
+func TestValidateStorageVolumeCapacity(t *testing.T) {
+	tests := []struct {
+		freeGB      map[string]int
+		replicas    int
+		distributed int
+		available   int
+		valid       bool
+	}{
+		{freeGB: map[string]int{"s1": 20, "s2": 20}, replicas: 2, distributed: 1, valid: true},
+		{freeGB: map[string]int{"s1": 20, "s2": 5, "s3": 20}, replicas: 2, distributed: 1, valid: true},
+		// not enough nodes
+		{freeGB: map[string]int{"s1": 20, "s2": 20}, replicas: 2, distributed: 2, available: 2, valid: false},
+		// not enough nodes with free disk space
+		{freeGB: map[string]int{"s1": 20, "s2": 5, "s3": 10}, replicas: 2, distributed: 1, available: 1, valid: false},
+	}
+	for i, test := range tests {
+		p := &Plan{}
+		for h := range test.freeGB {
+			p.Storage.Nodes = append(p.Storage.Nodes, Node{Host: h})
+		}
+		sshClient := func(p *Plan, host string) (ssh.Client, error) {
+			return fakeDiskSpace{freeGB: test.freeGB[host]}, nil
+		}
+		sv := StorageVolume{Name: "v", SizeGB: 10, ReplicateCount: test.replicas, DistributionCount: test.distributed}
+		err := validateStorageVolumeCapacity(p, sv, sshClient)
+		if (err == nil) != test.valid {
+			t.Errorf("test %d: expected valid %v, got %v", i, test.valid, err)
+			continue
+		}
+		if test.valid {
+			continue
+		}
+		capErr, ok := err.(InsufficientStorageError)
+		if !ok {
+			t.Errorf("test %d: expected an InsufficientStorageError, got %T", i, err)
+			continue
+		}
+		if capErr.Required != test.replicas*test.distributed || capErr.Available != test.available {
+			t.Errorf("test %d: unexpected error %+v", i, capErr)
+		}
+	}
+
+	p := &Plan{}
+	p.Storage.Nodes = []Node{{Host: "s1"}}
+	sshClient := func(p *Plan, host string) (ssh.Client, error) {
+		return fakeDiskSpace{err: errors.New("connection refused")}, nil
+	}
+	err := validateStorageVolumeCapacity(p, StorageVolume{SizeGB: 1, ReplicateCount: 1, DistributionCount: 1}, sshClient)
+	if _, ok := err.(InsufficientStorageError); err == nil || ok {
+		t.Errorf("expected an error getting the disk space, got %v", err)
+	}
+}
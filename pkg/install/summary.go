@@ -0,0 +1,178 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// HostSummary counts the results of the ansible tasks on a host
+type HostSummary struct {
+	Host string `json:"host"`
+	// Ok is the number of tasks that succeeded, including the ones that
+	// reported changes
+	Ok int `json:"ok"`
+	// Changed is the number of tasks that succeeded and reported changes
+	Changed int `json:"changed"`
+	// Skipped is the number of tasks that were skipped
+	Skipped int `json:"skipped"`
+	// Failed is the number of tasks that failed
+	Failed int `json:"failed"`
+	// Ignored is the number of tasks that failed, and whose errors were
+	// ignored by the playbook
+	Ignored int `json:"ignored"`
+	// Unreachable is the number of tasks that could not reach the host
+	Unreachable int `json:"unreachable"`
+}
+
+// RunSummary is the outcome of the ansible tasks run by one or more
+// playbooks. The counts include all the attempts of the playbooks that were
+// retried.
+type RunSummary struct {
+	// Playbooks that were run, in the order they were run
+	Playbooks []string `json:"playbooks"`
+	// Hosts are the results of the tasks on each host, sorted by host
+	Hosts []HostSummary `json:"hosts"`
+	// FailedHosts are the hosts on which a playbook failed, or that were
+	// unreachable, once the retries were exhausted
+	FailedHosts []string `json:"failedHosts,omitempty"`
+}
+
+// Totals returns the sum of the results of all the hosts
+func (s RunSummary) Totals() HostSummary {
+	t := HostSummary{}
+	for _, h := range s.Hosts {
+		t.Ok += h.Ok
+		t.Changed += h.Changed
+		t.Skipped += h.Skipped
+		t.Failed += h.Failed
+		t.Ignored += h.Ignored
+		t.Unreachable += h.Unreachable
+	}
+	return t
+}
+
+// Succeeded returns true if none of the playbooks failed
+func (s RunSummary) Succeeded() bool {
+	return len(s.FailedHosts) == 0
+}
+
+// Merge returns the summary of both runs
+func (s RunSummary) Merge(o RunSummary) RunSummary {
+	hosts := map[string]HostSummary{}
+	for _, list := range [][]HostSummary{s.Hosts, o.Hosts} {
+		for _, h := range list {
+			m := hosts[h.Host]
+			m.Host = h.Host
+			m.Ok += h.Ok
+			m.Changed += h.Changed
+			m.Skipped += h.Skipped
+			m.Failed += h.Failed
+			m.Ignored += h.Ignored
+			m.Unreachable += h.Unreachable
+			hosts[h.Host] = m
+		}
+	}
+	merged := RunSummary{
+		Playbooks: append(append([]string{}, s.Playbooks...), o.Playbooks...),
+		Hosts:     sortedHostSummaries(hosts),
+	}
+	for _, h := range append(append([]string{}, s.FailedHosts...), o.FailedHosts...) {
+		if !util.Contains(h, merged.FailedHosts) {
+			merged.FailedHosts = append(merged.FailedHosts, h)
+		}
+	}
+	return merged
+}
+
+func sortedHostSummaries(hosts map[string]HostSummary) []HostSummary {
+	list := []HostSummary{}
+	for _, h := range hosts {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Host < list[j].Host })
+	return list
+}
+
+// summaryRecorder counts the results of the ansible tasks on each host.
+// Item results are followed by a result for the task as a whole, so only the
+// latter are counted.
+type summaryRecorder struct {
+	mu    sync.Mutex
+	hosts map[string]HostSummary
+}
+
+func newSummaryRecorder() *summaryRecorder {
+	return &summaryRecorder{hosts: map[string]HostSummary{}}
+}
+
+func (r *summaryRecorder) observe(e ansible.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch event := e.(type) {
+	case *ansible.RunnerOKEvent:
+		h := r.host(event.Host)
+		h.Ok++
+		if event.Result.Changed {
+			h.Changed++
+		}
+		r.hosts[event.Host] = h
+	case *ansible.RunnerSkippedEvent:
+		h := r.host(event.Host)
+		h.Skipped++
+		r.hosts[event.Host] = h
+	case *ansible.RunnerFailedEvent:
+		h := r.host(event.Host)
+		if event.IgnoreErrors {
+			h.Ignored++
+		} else {
+			h.Failed++
+		}
+		r.hosts[event.Host] = h
+	case *ansible.RunnerUnreachableEvent:
+		h := r.host(event.Host)
+		h.Unreachable++
+		r.hosts[event.Host] = h
+	}
+}
+
+func (r *summaryRecorder) host(name string) HostSummary {
+	h := r.hosts[name]
+	h.Host = name
+	return h
+}
+
+// finish returns the summary of the playbook, with the hosts that failed
+func (r *summaryRecorder) finish(playbook string, failedHosts []string) RunSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := RunSummary{Playbooks: []string{playbook}, Hosts: sortedHostSummaries(r.hosts)}
+	if len(failedHosts) > 0 {
+		s.FailedHosts = append([]string{}, failedHosts...)
+	}
+	return s
+}
+
+// PrintRunSummary prints the results of the tasks on each host, followed by
+// the hosts that failed
+func PrintRunSummary(out io.Writer, s RunSummary) {
+	util.PrintHeader(out, "Summary", '=')
+	if len(s.Hosts) == 0 {
+		fmt.Fprintln(out, "No tasks were run on the nodes")
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tOK\tCHANGED\tSKIPPED\tFAILED\tIGNORED\tUNREACHABLE")
+	for _, h := range s.Hosts {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", h.Host, h.Ok, h.Changed, h.Skipped, h.Failed, h.Ignored, h.Unreachable)
+	}
+	w.Flush()
+	if !s.Succeeded() {
+		fmt.Fprintf(out, "Failed on %d of %d nodes: %v\n", len(s.FailedHosts), len(s.Hosts), s.FailedHosts)
+	}
+}
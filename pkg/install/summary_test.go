@@ -0,0 +1,103 @@
+package install
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestSummaryRecorder(t *testing.T) {
+	r := newSummaryRecorder()
+	changed := &ansible.RunnerOKEvent{}
+	changed.Host = "worker1"
+	changed.Result.Changed = true
+	ok := &ansible.RunnerOKEvent{}
+	ok.Host = "worker1"
+	skipped := &ansible.RunnerSkippedEvent{}
+	skipped.Host = "worker2"
+	ignored := &ansible.RunnerFailedEvent{}
+	ignored.Host = "worker2"
+	ignored.IgnoreErrors = true
+	failed := &ansible.RunnerFailedEvent{}
+	failed.Host = "worker2"
+	// item results are not counted, as the task as a whole reports a result
+	item := &ansible.RunnerItemOKEvent{}
+	item.Host = "worker1"
+	unreachable := &ansible.RunnerUnreachableEvent{}
+	unreachable.Host = "worker3"
+	for _, e := range []ansible.Event{changed, ok, skipped, ignored, failed, item, unreachable} {
+		r.observe(e)
+	}
+	s := r.finish("kubernetes.yaml", []string{"worker2", "worker3"})
+	expected := RunSummary{
+		Playbooks: []string{"kubernetes.yaml"},
+		Hosts: []HostSummary{
+			{Host: "worker1", Ok: 2, Changed: 1},
+			{Host: "worker2", Skipped: 1, Failed: 1, Ignored: 1},
+			{Host: "worker3", Unreachable: 1},
+		},
+		FailedHosts: []string{"worker2", "worker3"},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+	if s.Succeeded() {
+		t.Errorf("expected the summary not to succeed")
+	}
+	if totals := s.Totals(); totals != (HostSummary{Ok: 2, Changed: 1, Skipped: 1, Failed: 1, Ignored: 1, Unreachable: 1}) {
+		t.Errorf("unexpected totals: %+v", totals)
+	}
+}
+
+func TestRunSummaryMerge(t *testing.T) {
+	a := RunSummary{
+		Playbooks:   []string{"a.yaml"},
+		Hosts:       []HostSummary{{Host: "worker2", Ok: 1}, {Host: "worker1", Ok: 2, Changed: 1}},
+		FailedHosts: []string{"worker2"},
+	}
+	b := RunSummary{
+		Playbooks:   []string{"b.yaml"},
+		Hosts:       []HostSummary{{Host: "worker1", Ok: 1, Failed: 1}},
+		FailedHosts: []string{"worker1", "worker2"},
+	}
+	expected := RunSummary{
+		Playbooks:   []string{"a.yaml", "b.yaml"},
+		Hosts:       []HostSummary{{Host: "worker1", Ok: 3, Changed: 1, Failed: 1}, {Host: "worker2", Ok: 1}},
+		FailedHosts: []string{"worker2", "worker1"},
+	}
+	if m := a.Merge(b); !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %+v, got %+v", expected, m)
+	}
+}
+
+func TestExecuteRunSummary(t *testing.T) {
+	runner := &retryRunner{failing: []string{"worker1"}, failures: 2}
+	e := retryExecutor(t, runner, RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond})
+	s, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if !reflect.DeepEqual(s.FailedHosts, []string{"worker1"}) {
+		t.Errorf("expected the failed hosts to be recorded, got %v", s.FailedHosts)
+	}
+	if len(s.Hosts) != 1 || s.Hosts[0].Unreachable != 2 {
+		t.Errorf("expected the results of both attempts to be counted, got %+v", s.Hosts)
+	}
+}
+
+func TestPrintRunSummary(t *testing.T) {
+	out := &bytes.Buffer{}
+	PrintRunSummary(out, RunSummary{
+		Hosts:       []HostSummary{{Host: "worker1", Ok: 12, Changed: 3}, {Host: "worker2", Ok: 4, Failed: 1}},
+		FailedHosts: []string{"worker2"},
+	})
+	for _, s := range []string{"worker1  12", "Failed on 1 of 2 nodes: [worker2]"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expected the output to contain %q, got:\n%s", s, out.String())
+		}
+	}
+}
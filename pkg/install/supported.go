@@ -0,0 +1,63 @@
+package install
+
+import "fmt"
+
+// SupportMatrix lists the Kubernetes versions, operating systems, add-ons and
+// package versions supported by this build of Kismatic, so that tooling can
+// validate plans before they are applied
+type SupportMatrix struct {
+	KismaticVersion  string              `json:"kismaticVersion"`
+	Kubernetes       SupportedKubernetes `json:"kubernetes"`
+	OperatingSystems []string            `json:"operatingSystems"`
+	CNIProviders     []string            `json:"cniProviders"`
+	DNSProviders     []string            `json:"dnsProviders"`
+	CloudProviders   []string            `json:"cloudProviders"`
+	// Packages are the versions of the packages installed on the nodes, by
+	// package name and operating system family
+	Packages map[string]map[string]string `json:"packages"`
+	// Images are the versions of the container images deployed on the
+	// cluster, by component. They are empty when the versions manifest was
+	// not found.
+	Images map[string]string `json:"images,omitempty"`
+}
+
+// SupportedKubernetes are the Kubernetes versions supported by this build of
+// Kismatic
+type SupportedKubernetes struct {
+	// Default is the version installed when the plan does not set one
+	Default string `json:"default"`
+	// Versions are the versions that can be installed, such as v1.10.x
+	Versions string `json:"versions"`
+	// UpgradeFrom are the versions that can be upgraded to Versions
+	UpgradeFrom string `json:"upgradeFrom"`
+}
+
+// SupportedVersions returns the support matrix of this build. The image
+// versions are read from the versions manifest, when it is not nil.
+func SupportedVersions(m *VersionsManifest) SupportMatrix {
+	kubeYum, kubeDeb := kubernetesPackageVersions(kubernetesVersion)
+	s := SupportMatrix{
+		KismaticVersion: KismaticVersion.String(),
+		Kubernetes: SupportedKubernetes{
+			Default:     kubernetesVersionString,
+			Versions:    kubernetesMinorVersionString,
+			UpgradeFrom: fmt.Sprintf("v%d.%d.x", kubernetesVersion.Major, kubernetesVersion.Minor-1),
+		},
+		OperatingSystems: ExportOperatingSystems,
+		CNIProviders:     cniProviders(),
+		DNSProviders:     dnsProviders(),
+		CloudProviders:   cloudProviders(),
+		Packages: map[string]map[string]string{
+			"kubernetes": {"yum": kubeYum, "apt": kubeDeb},
+			"docker-ce":  {"yum": dockerYumVersion, "apt": dockerDebVersion},
+			"glusterfs":  {"yum": glusterfsYumVersion, "apt": glusterfsDebVersion},
+		},
+	}
+	if m != nil {
+		s.Images = map[string]string{}
+		for k, img := range m.Images {
+			s.Images[k] = img.Version
+		}
+	}
+	return s
+}
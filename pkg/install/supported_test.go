@@ -0,0 +1,35 @@
+package install
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+)
+
+func TestSupportedVersions(t *testing.T) {
+	defer func(v semver.Version) { KismaticVersion = v }(KismaticVersion)
+	KismaticVersion = mustParseVersion("1.11.0")
+
+	s := SupportedVersions(nil)
+	if s.KismaticVersion != "1.11.0" || s.Kubernetes.Default != kubernetesVersionString || s.Kubernetes.Versions != "v1.10.x" || s.Kubernetes.UpgradeFrom != "v1.9.x" {
+		t.Errorf("unexpected versions %+v", s)
+	}
+	if !contains(cniProviderCalico, s.CNIProviders) || !contains("ubuntu", s.OperatingSystems) {
+		t.Errorf("unexpected support matrix %+v", s)
+	}
+	if s.Images != nil {
+		t.Errorf("expected no images without a versions manifest, but got %v", s.Images)
+	}
+
+	m := &VersionsManifest{Images: map[string]ManifestImage{"etcd": {Name: "quay.io/coreos/etcd", Version: "v3.1.13"}}}
+	s = SupportedVersions(m)
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(b), `"images":{"etcd":"v3.1.13"}`) || !strings.Contains(string(b), `"docker-ce":{"apt":"17.03.2~ce-0~ubuntu-xenial"`) {
+		t.Errorf("unexpected JSON %s", b)
+	}
+}
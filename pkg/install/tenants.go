@@ -0,0 +1,57 @@
+package install
+
+import (
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// tenantRoleBindingName returns the name of the RoleBinding that grants the
+// ClusterRole in a tenant namespace
+func tenantRoleBindingName(clusterRole string) string {
+	return "tenant-" + clusterRole
+}
+
+// tenantServiceAccount returns the namespace and name of a service account in
+// the namespace:name format. Service accounts without a namespace are in the
+// tenant namespace.
+func tenantServiceAccount(tenantNamespace, sa string) (string, string) {
+	if i := strings.Index(sa, ":"); i >= 0 {
+		return sa[:i], sa[i+1:]
+	}
+	return tenantNamespace, sa
+}
+
+func tenantsCatalog(tenants []Tenant) []ansible.Tenant {
+	var cc []ansible.Tenant
+	for _, t := range tenants {
+		tenant := ansible.Tenant{
+			Namespace: t.Namespace,
+			Labels:    t.Labels,
+			Quota:     t.Quota,
+		}
+		if lr := t.LimitRange; lr != nil {
+			tenant.LimitRange = &ansible.TenantLimitRange{
+				Default:        lr.Default,
+				DefaultRequest: lr.DefaultRequest,
+				Max:            lr.Max,
+				Min:            lr.Min,
+			}
+		}
+		for _, rb := range t.RoleBindings {
+			binding := ansible.TenantRoleBinding{
+				Name:        tenantRoleBindingName(rb.ClusterRole),
+				ClusterRole: rb.ClusterRole,
+				Users:       rb.Users,
+				Groups:      rb.Groups,
+			}
+			for _, sa := range rb.ServiceAccounts {
+				namespace, name := tenantServiceAccount(t.Namespace, sa)
+				binding.ServiceAccounts = append(binding.ServiceAccounts, ansible.TenantServiceAccount{Namespace: namespace, Name: name})
+			}
+			tenant.RoleBindings = append(tenant.RoleBindings, binding)
+		}
+		cc = append(cc, tenant)
+	}
+	return cc
+}
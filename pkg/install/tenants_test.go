@@ -0,0 +1,63 @@
+package install
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+func TestTenantServiceAccount(t *testing.T) {
+	tests := []struct {
+		sa        string
+		namespace string
+		name      string
+	}{
+		{sa: "deployer", namespace: "team-a", name: "deployer"},
+		{sa: "monitoring:prometheus", namespace: "monitoring", name: "prometheus"},
+	}
+	for _, test := range tests {
+		namespace, name := tenantServiceAccount("team-a", test.sa)
+		if namespace != test.namespace || name != test.name {
+			t.Errorf("%q: expected %s/%s, got %s/%s", test.sa, test.namespace, test.name, namespace, name)
+		}
+	}
+}
+
+func TestTenantsCatalog(t *testing.T) {
+	tenants := []Tenant{
+		{
+			Namespace:  "team-a",
+			Labels:     map[string]string{"cost-center": "1234"},
+			Quota:      map[string]string{"pods": "20"},
+			LimitRange: &TenantLimitRange{Default: map[string]string{"cpu": "500m"}},
+			RoleBindings: []TenantRoleBinding{
+				{ClusterRole: "edit", Users: []string{"alice"}, ServiceAccounts: []string{"deployer", "ci:builder"}},
+			},
+		},
+		{Namespace: "team-b"},
+	}
+	expected := []ansible.Tenant{
+		{
+			Namespace:  "team-a",
+			Labels:     map[string]string{"cost-center": "1234"},
+			Quota:      map[string]string{"pods": "20"},
+			LimitRange: &ansible.TenantLimitRange{Default: map[string]string{"cpu": "500m"}},
+			RoleBindings: []ansible.TenantRoleBinding{
+				{
+					Name:        "tenant-edit",
+					ClusterRole: "edit",
+					Users:       []string{"alice"},
+					ServiceAccounts: []ansible.TenantServiceAccount{
+						{Namespace: "team-a", Name: "deployer"},
+						{Namespace: "ci", Name: "builder"},
+					},
+				},
+			},
+		},
+		{Namespace: "team-b"},
+	}
+	if got := tenantsCatalog(tenants); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
@@ -0,0 +1,146 @@
+package install
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+)
+
+// TimeoutError is returned when a task, or one of its plays, runs for longer
+// than its timeout, and is terminated
+type TimeoutError struct {
+	// Task is the name of the task that timed out
+	Task string
+	// Reason describes the timeout that was exceeded
+	Reason string
+	// Diagnostics is the directory where the diagnostics of the nodes were
+	// collected after the timeout, if they were
+	Diagnostics string
+	// Log is the ansible log of the task, which holds the output of the
+	// playbook until it was terminated
+	Log string
+}
+
+func (e TimeoutError) Error() string {
+	if e.Diagnostics != "" {
+		return fmt.Sprintf("task %q timed out: %s. The diagnostics of the nodes were collected in %s", e.Task, e.Reason, e.Diagnostics)
+	}
+	return fmt.Sprintf("task %q timed out: %s", e.Task, e.Reason)
+}
+
+// IsTimeout returns true if the error is a TimeoutError
+func IsTimeout(err error) bool {
+	_, ok := err.(TimeoutError)
+	return ok
+}
+
+// TaskTimeouts are the maximum durations of the tasks of each type. A
+// timeout of zero falls back to the timeout of all the tasks.
+type TaskTimeouts struct {
+	// Preflight is the timeout of the pre-flight checks of the
+	// installation, of the upgrade and of the new nodes
+	Preflight time.Duration
+	// Apply is the timeout of the installation of the cluster, and of the
+	// installation of new nodes
+	Apply time.Duration
+	// Upgrade is the timeout of the upgrade of the nodes, and of the upgrade
+	// of the cluster services
+	Upgrade time.Duration
+}
+
+func (t TaskTimeouts) validate() error {
+	if t.Preflight < 0 || t.Apply < 0 || t.Upgrade < 0 {
+		return fmt.Errorf("the timeouts of the tasks cannot be negative")
+	}
+	return nil
+}
+
+// forTask returns the timeout of the task, or the fallback when the type of
+// the task has no timeout
+func (t TaskTimeouts) forTask(name string, fallback time.Duration) time.Duration {
+	var timeout time.Duration
+	switch name {
+	case "preflight", "upgrade-preflight", "add-node-preflight":
+		timeout = t.Preflight
+	case "apply", "add-node":
+		timeout = t.Apply
+	case "upgrade-nodes", "upgrade-cluster-services":
+		timeout = t.Upgrade
+	}
+	if timeout == 0 {
+		return fallback
+	}
+	return timeout
+}
+
+// timeoutWatchdog stops the ansible runner when the task, or one of its
+// plays, runs for longer than its timeout. A timeout of zero is disabled.
+type timeoutWatchdog struct {
+	mu          sync.Mutex
+	stop        func() error
+	playTimeout time.Duration
+	taskTimer   *time.Timer
+	playTimer   *time.Timer
+	finished    bool
+	reason      string
+}
+
+func newTimeoutWatchdog(timeout, playTimeout time.Duration, stop func() error) *timeoutWatchdog {
+	w := &timeoutWatchdog{stop: stop, playTimeout: playTimeout}
+	if timeout > 0 {
+		w.taskTimer = time.AfterFunc(timeout, func() {
+			w.expire(fmt.Sprintf("the task exceeded the timeout of %s", timeout))
+		})
+	}
+	return w
+}
+
+// observe restarts the play timer at the beginning of each play
+func (w *timeoutWatchdog) observe(e ansible.Event) {
+	play, ok := e.(*ansible.PlayStartEvent)
+	if !ok || w.playTimeout <= 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished {
+		return
+	}
+	if w.playTimer != nil {
+		w.playTimer.Stop()
+	}
+	name, timeout := play.Name, w.playTimeout
+	w.playTimer = time.AfterFunc(timeout, func() {
+		w.expire(fmt.Sprintf("the play %q exceeded the timeout of %s", name, timeout))
+	})
+}
+
+// expire stops the runner, unless the task finished or already timed out
+func (w *timeoutWatchdog) expire(reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished || w.reason != "" {
+		return
+	}
+	w.reason = reason
+	if err := w.stop(); err != nil {
+		w.reason = fmt.Sprintf("%s, and the playbook could not be stopped: %v", reason, err)
+	}
+}
+
+// finish stops the timers, and returns the reason of the timeout. The reason
+// is empty when the task did not time out.
+func (w *timeoutWatchdog) finish() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.finished = true
+	if w.taskTimer != nil {
+		w.taskTimer.Stop()
+	}
+	if w.playTimer != nil {
+		w.playTimer.Stop()
+	}
+	return w.reason
+}
@@ -0,0 +1,183 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+// blockingRunner runs a play that does not complete until the runner is
+// stopped
+type blockingRunner struct {
+	stopped chan struct{}
+}
+
+func (r *blockingRunner) StartPlaybook(string, ansible.Inventory, ansible.ClusterCatalog) (<-chan ansible.Event, error) {
+	events := make(chan ansible.Event, 1)
+	e := &ansible.PlayStartEvent{}
+	e.Name = "Start etcd"
+	events <- e
+	return events, nil
+}
+
+func (r *blockingRunner) StartPlaybookOnNode(playbook string, inv ansible.Inventory, cc ansible.ClusterCatalog, nodes ...string) (<-chan ansible.Event, error) {
+	return r.StartPlaybook(playbook, inv, cc)
+}
+
+func (r *blockingRunner) WaitPlaybook() error {
+	<-r.stopped
+	return errors.New("interrupted")
+}
+
+func (r *blockingRunner) Stop() error {
+	close(r.stopped)
+	return nil
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	tests := []struct {
+		options ExecutorOptions
+		reason  string
+	}{
+		{
+			options: ExecutorOptions{Timeout: 10 * time.Millisecond},
+			reason:  "the task exceeded the timeout of 10ms",
+		},
+		{
+			options: ExecutorOptions{PlayTimeout: 10 * time.Millisecond},
+			reason:  `the play "Start etcd" exceeded the timeout of 10ms`,
+		},
+		{
+			options: ExecutorOptions{Timeout: time.Hour, TaskTimeouts: TaskTimeouts{Apply: 10 * time.Millisecond}},
+			reason:  "the task exceeded the timeout of 10ms",
+		},
+	}
+	// the end of the playbook is never received when ansible is stopped
+	defer func(d time.Duration) { eventsDrainTimeout = d }(eventsDrainTimeout)
+	eventsDrainTimeout = 10 * time.Millisecond
+	for _, test := range tests {
+		runsDir := mustGetTempDir(t)
+		test.options.RunsDirectory = runsDir
+		e := ansibleExecutor{
+			options:             test.options,
+			stdout:              &bytes.Buffer{},
+			consoleOutputFormat: ansible.RawFormat,
+			runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+				return &blockingRunner{stopped: make(chan struct{})}, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+			},
+		}
+		_, err := e.execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()})
+		if !IsTimeout(err) {
+			t.Errorf("expected a timeout error, got %v", err)
+			continue
+		}
+		if err.(TimeoutError).Reason != test.reason {
+			t.Errorf("expected the reason %q, got %q", test.reason, err.(TimeoutError).Reason)
+		}
+		if log := err.(TimeoutError).Log; filepath.Base(log) != "ansible.log" || !strings.HasPrefix(log, runsDir) {
+			t.Errorf("expected the ansible log of the run, got %q", log)
+		}
+		runs, err := filepath.Glob(filepath.Join(runsDir, "apply", "*", runManifestFilename))
+		if err != nil || len(runs) != 1 {
+			t.Fatalf("expected a run manifest, got %v %v", runs, err)
+		}
+		b, err := ioutil.ReadFile(runs[0])
+		if err != nil {
+			t.Fatalf("error reading run manifest: %v", err)
+		}
+		var m RunManifest
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("error unmarshaling run manifest: %v", err)
+		}
+		if !m.TimedOut || m.TimeoutReason != test.reason {
+			t.Errorf("expected the run to be marked as timed out, got %+v", m)
+		}
+	}
+}
+
+func TestExecuteAborted(t *testing.T) {
+	defer func(d time.Duration) { eventsDrainTimeout = d }(eventsDrainTimeout)
+	eventsDrainTimeout = 10 * time.Millisecond
+	runsDir := mustGetTempDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	e := ansibleExecutor{
+		options:             ExecutorOptions{RunsDirectory: runsDir},
+		stdout:              &bytes.Buffer{},
+		consoleOutputFormat: ansible.RawFormat,
+		runnerExplainerFactory: func(explainer explain.AnsibleEventExplainer, _ io.Writer) (ansible.Runner, *explain.AnsibleEventStreamExplainer, error) {
+			return &blockingRunner{stopped: make(chan struct{})}, &explain.AnsibleEventStreamExplainer{EventExplainer: explainer}, nil
+		},
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := e.withContext(ctx).execute(task{name: "apply", playbook: "kubernetes.yaml", explainer: e.defaultExplainer()})
+	if !IsAborted(err) {
+		t.Fatalf("expected an aborted error, got %v", err)
+	}
+	runs, err := filepath.Glob(filepath.Join(runsDir, "apply", "*", runManifestFilename))
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected a run manifest, got %v %v", runs, err)
+	}
+	b, err := ioutil.ReadFile(runs[0])
+	if err != nil {
+		t.Fatalf("error reading run manifest: %v", err)
+	}
+	var m RunManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("error unmarshaling run manifest: %v", err)
+	}
+	if !m.Aborted || m.Status != RunAborted {
+		t.Errorf("expected the run to be marked as aborted, got %+v", m)
+	}
+
+	// Tasks are not started once the context is done
+	_, err = e.withContext(ctx).execute(task{name: "smoketest", playbook: "smoketest.yaml", explainer: e.defaultExplainer()})
+	if !IsAborted(err) {
+		t.Errorf("expected an aborted error, got %v", err)
+	}
+}
+
+func TestTaskTimeoutsForTask(t *testing.T) {
+	timeouts := TaskTimeouts{Preflight: time.Minute, Upgrade: time.Hour}
+	tests := map[string]time.Duration{
+		"preflight":                time.Minute,
+		"add-node-preflight":       time.Minute,
+		"upgrade-nodes":            time.Hour,
+		"upgrade-cluster-services": time.Hour,
+		// the apply timeout is not set
+		"apply":     2 * time.Hour,
+		"smoketest": 2 * time.Hour,
+	}
+	for task, expected := range tests {
+		if timeout := timeouts.forTask(task, 2*time.Hour); timeout != expected {
+			t.Errorf("%s: expected the timeout %v, got %v", task, expected, timeout)
+		}
+	}
+	if err := (TaskTimeouts{Apply: -time.Minute}).validate(); err == nil {
+		t.Errorf("expected an error when a timeout is negative")
+	}
+}
+
+func TestTimeoutWatchdogFinished(t *testing.T) {
+	stopped := false
+	w := newTimeoutWatchdog(10*time.Millisecond, 0, func() error {
+		stopped = true
+		return nil
+	})
+	if reason := w.finish(); reason != "" {
+		t.Errorf("expected no timeout, got %q", reason)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if stopped {
+		t.Errorf("expected the runner not to be stopped after the task finished")
+	}
+}
@@ -0,0 +1,172 @@
+package install
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/ansible"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// Timings is a breakdown of the time spent running a playbook
+type Timings struct {
+	// DurationSeconds is the total time spent running the playbook
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Plays in the order they were run
+	Plays []PlayTiming `json:"plays"`
+	// Hosts sorted by the total time spent running tasks on them, slowest first
+	Hosts []HostTiming `json:"hosts"`
+}
+
+// PlayTiming is the time spent running a play
+type PlayTiming struct {
+	Name            string  `json:"name"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Hosts sorted by the time spent running the tasks of the play on them, slowest first
+	Hosts []HostTiming `json:"hosts"`
+}
+
+// HostTiming is the time spent running tasks on a host. Tasks run on
+// multiple hosts in parallel, so the time spent on each host overlaps.
+type HostTiming struct {
+	Host            string  `json:"host"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// timingsRecorder measures the duration of plays and tasks as the ansible
+// events are received.
+type timingsRecorder struct {
+	mu         sync.Mutex
+	now        func() time.Time
+	start      time.Time
+	plays      []PlayTiming
+	play       *PlayTiming
+	playStart  time.Time
+	playHosts  map[string]time.Duration
+	taskStart  time.Time
+	hostTotals map[string]time.Duration
+	finished   bool
+}
+
+func newTimingsRecorder() *timingsRecorder {
+	return newTimingsRecorderWithClock(time.Now)
+}
+
+func newTimingsRecorderWithClock(now func() time.Time) *timingsRecorder {
+	return &timingsRecorder{
+		now:        now,
+		start:      now(),
+		hostTotals: map[string]time.Duration{},
+	}
+}
+
+func (r *timingsRecorder) observe(e ansible.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+	now := r.now()
+	switch event := e.(type) {
+	case *ansible.PlayStartEvent:
+		r.endPlay(now)
+		r.play = &PlayTiming{Name: event.Name}
+		r.playStart = now
+		r.playHosts = map[string]time.Duration{}
+		r.taskStart = now
+	case *ansible.TaskStartEvent, *ansible.HandlerTaskStartEvent:
+		r.taskStart = now
+	// Item events are followed by a result for the task as a whole, so they
+	// are not measured
+	case *ansible.RunnerOKEvent:
+		r.hostResult(event.Host, now)
+	case *ansible.RunnerFailedEvent:
+		r.hostResult(event.Host, now)
+	case *ansible.RunnerSkippedEvent:
+		r.hostResult(event.Host, now)
+	case *ansible.RunnerUnreachableEvent:
+		r.hostResult(event.Host, now)
+	case *ansible.PlaybookEndEvent:
+		r.endPlay(now)
+	}
+}
+
+func (r *timingsRecorder) hostResult(host string, now time.Time) {
+	d := now.Sub(r.taskStart)
+	if r.playHosts != nil {
+		r.playHosts[host] += d
+	}
+	r.hostTotals[host] += d
+}
+
+func (r *timingsRecorder) endPlay(now time.Time) {
+	if r.play == nil {
+		return
+	}
+	r.play.DurationSeconds = now.Sub(r.playStart).Seconds()
+	r.play.Hosts = hostTimings(r.playHosts)
+	r.plays = append(r.plays, *r.play)
+	r.play = nil
+	r.playHosts = nil
+}
+
+// finish stops recording and returns the timings. Events received after
+// finishing are ignored.
+func (r *timingsRecorder) finish() Timings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.endPlay(now)
+	r.finished = true
+	return Timings{
+		DurationSeconds: now.Sub(r.start).Seconds(),
+		Plays:           r.plays,
+		Hosts:           hostTimings(r.hostTotals),
+	}
+}
+
+func hostTimings(m map[string]time.Duration) []HostTiming {
+	timings := []HostTiming{}
+	for h, d := range m {
+		timings = append(timings, HostTiming{Host: h, DurationSeconds: d.Seconds()})
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].DurationSeconds == timings[j].DurationSeconds {
+			return timings[i].Host < timings[j].Host
+		}
+		return timings[i].DurationSeconds > timings[j].DurationSeconds
+	})
+	return timings
+}
+
+// printTimings prints the breakdown of the time spent on each play and host
+func printTimings(out io.Writer, t Timings) {
+	util.PrintHeader(out, "Timings", '=')
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PLAY\tDURATION\tSLOWEST HOST")
+	for _, p := range t.Plays {
+		slowest := ""
+		if len(p.Hosts) > 0 {
+			slowest = fmt.Sprintf("%s (%s)", p.Hosts[0].Host, seconds(p.Hosts[0].DurationSeconds))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, seconds(p.DurationSeconds), slowest)
+	}
+	fmt.Fprintf(w, "TOTAL\t%s\t\n", seconds(t.DurationSeconds))
+	w.Flush()
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "HOST\tTASK TIME")
+	for _, h := range t.Hosts {
+		fmt.Fprintf(w, "%s\t%s\n", h.Host, seconds(h.DurationSeconds))
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+}
+
+func seconds(s float64) time.Duration {
+	return (time.Duration(s * float64(time.Second))).Round(100 * time.Millisecond)
+}
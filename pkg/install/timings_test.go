@@ -0,0 +1,59 @@
+package install
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimingsRecorder(t *testing.T) {
+	// Every event is received one second after the previous one
+	now := time.Now()
+	clock := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	r := newTimingsRecorderWithClock(clock)
+	for _, e := range eventsFromJSONLines(tracedPlaybookEvents) {
+		r.observe(e)
+	}
+	timings := r.finish()
+
+	if len(timings.Plays) != 2 {
+		t.Fatalf("expected 2 plays, but got %d", len(timings.Plays))
+	}
+	etcd := timings.Plays[0]
+	if etcd.Name != "etcd" || etcd.DurationSeconds != 4 {
+		t.Errorf("expected the etcd play to take 4 seconds, but got %+v", etcd)
+	}
+	expectedHosts := []HostTiming{{Host: "etcd02", DurationSeconds: 2}, {Host: "etcd01", DurationSeconds: 1}}
+	if len(etcd.Hosts) != 2 || etcd.Hosts[0] != expectedHosts[0] || etcd.Hosts[1] != expectedHosts[1] {
+		t.Errorf("expected etcd play hosts %v, but got %v", expectedHosts, etcd.Hosts)
+	}
+	master := timings.Plays[1]
+	if master.Name != "master" || master.DurationSeconds != 4 {
+		t.Errorf("expected the master play to take 4 seconds, but got %+v", master)
+	}
+	if len(timings.Hosts) != 4 || timings.Hosts[0].Host != "etcd02" && timings.Hosts[0].Host != "master02" {
+		t.Errorf("expected the slowest hosts first, but got %v", timings.Hosts)
+	}
+	// the PLAYBOOK_START event is the 1st, so the recorder started 1 tick before it
+	if timings.DurationSeconds != 11 {
+		t.Errorf("expected the playbook to take 11 seconds, but got %v", timings.DurationSeconds)
+	}
+
+	// Events after finishing are ignored
+	r.observe(eventsFromJSONLines(tracedPlaybookEvents)[1])
+	if again := r.finish(); len(again.Plays) != 2 {
+		t.Errorf("expected events after finishing to be ignored")
+	}
+
+	out := &bytes.Buffer{}
+	printTimings(out, timings)
+	for _, s := range []string{"etcd", "master02 (2s)", "TOTAL", "11s"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expected the timings output to contain %q, but got:\n%s", s, out.String())
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/apprenda/kismatic/pkg/data"
+	"github.com/apprenda/kismatic/pkg/util"
 )
 
 type upgradeKubeInfoClient interface {
@@ -130,6 +131,78 @@ func (e podRunningJobErr) Error() string {
 	return fmt.Sprintf(`Pod that belongs to job "%s/%s" is running on this node.`, e.name, e.namespace)
 }
 
+// controlPlaneParallelism returns the number of etcd nodes, and of master
+// nodes, that can be upgraded at the same time, up to max. Upgrading up to
+// (n-1)/2 of the n etcd nodes at the same time maintains the quorum of the
+// etcd cluster, which is only allowed when the cluster has at least 5 etcd
+// nodes and the master nodes are load balanced. All the master nodes but one
+// can be upgraded at the same time when they are load balanced.
+func controlPlaneParallelism(plan Plan, max int) (etcd int, master int) {
+	etcd, master = 1, 1
+	if max <= 1 || !masterLoadBalanced(plan) {
+		return etcd, master
+	}
+	master = len(plan.Master.Nodes) - 1
+	if master > max {
+		master = max
+	}
+	if n := len(plan.Etcd.Nodes); n >= 5 {
+		etcd = (n - 1) / 2
+		if etcd > max {
+			etcd = max
+		}
+	}
+	return etcd, master
+}
+
+// upgradeBatches splits the nodes in batches of up to size nodes. Batches
+// that contain a master node are capped at masterSize nodes, so that
+// upgrading etcd nodes that are also master nodes does not take down more
+// API servers than the master parallelism allows.
+func upgradeBatches(nodes []ListableNode, size int, masterSize int) [][]ListableNode {
+	if size < 1 {
+		size = 1
+	}
+	if masterSize < 1 {
+		masterSize = 1
+	}
+	var batches [][]ListableNode
+	var batch []ListableNode
+	var hasMaster bool
+	for _, n := range nodes {
+		isMaster := util.Contains("master", n.Roles)
+		limit := size
+		if (hasMaster || isMaster) && masterSize < limit {
+			limit = masterSize
+		}
+		if len(batch) > 0 && len(batch)+1 > limit {
+			batches = append(batches, batch)
+			batch, hasMaster = nil, false
+		}
+		batch = append(batch, n)
+		hasMaster = hasMaster || isMaster
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// masterLoadBalanced returns true when the cluster has multiple master nodes
+// behind a load balancer that is not one of the master nodes
+func masterLoadBalanced(plan Plan) bool {
+	fqdn := plan.Master.LoadBalancedFQDN
+	if fqdn == "" || len(plan.Master.Nodes) < 2 {
+		return false
+	}
+	for _, n := range plan.Master.Nodes {
+		if fqdn == n.Host || fqdn == n.IP || fqdn == n.InternalIP {
+			return false
+		}
+	}
+	return true
+}
+
 // DetectNodeUpgradeSafety determines whether it's safe to upgrade a specific node
 // listed in the plan file. If any condition that could result in data or availability
 // loss is detected, the upgrade is deemed unsafe, and the conditions are returned as errors.
@@ -0,0 +1,314 @@
+package install
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const upgradeImpactReportFilename = "upgrade-impact.json"
+
+// The kinds of components that are changed by an upgrade
+const (
+	ComponentKindPackage = "package"
+	ComponentKindBinary  = "binary"
+	ComponentKindImage   = "image"
+)
+
+// estimated time a node of each role is unavailable while it is upgraded
+var roleDowntimeEstimates = map[string]time.Duration{
+	"etcd":    30 * time.Second,
+	"master":  2 * time.Minute,
+	"worker":  3 * time.Minute,
+	"ingress": 3 * time.Minute,
+	"storage": 3 * time.Minute,
+}
+
+// VersionsManifest contains the versions of the components that are installed
+// by this version of KET, as defined in the group variables of the playbooks.
+type VersionsManifest struct {
+	// KubernetesYumVersion and KubernetesAptVersion are the versions of the
+	// Kubernetes packages for the version of the plan. They are empty when
+	// the plan does not have a valid version.
+	KubernetesYumVersion   string
+	KubernetesAptVersion   string
+	DockerYumVersion       string
+	DockerAptVersion       string
+	GlusterFSRHELVersion   string
+	GlusterFSUbuntuVersion string
+	// Images are the container images, keyed by the name used in the playbooks
+	Images map[string]ManifestImage
+}
+
+// ManifestImage is a container image of the versions manifest
+type ManifestImage struct {
+	Name    string
+	Version string
+}
+
+// ReadVersionsManifest reads the versions of the components from the group
+// variables directory of the playbooks. The versions of the Kubernetes
+// components are set to the version of the plan.
+func ReadVersionsManifest(groupVarsDir string, p Plan) (*VersionsManifest, error) {
+	all := struct {
+		DockerYumVersion       string `yaml:"docker_ce_yum_version"`
+		DockerAptVersion       string `yaml:"docker_ce_apt_version"`
+		GlusterFSRHELVersion   string `yaml:"glusterfs_server_version_rhel"`
+		GlusterFSUbuntuVersion string `yaml:"glusterfs_server_version_ubuntu"`
+	}{}
+	if err := readYAMLFile(filepath.Join(groupVarsDir, "all.yaml"), &all); err != nil {
+		return nil, err
+	}
+	images := struct {
+		OfficialImages map[string]ManifestImage `yaml:"official_images"`
+	}{}
+	if err := readYAMLFile(filepath.Join(groupVarsDir, "container_images.yaml"), &images); err != nil {
+		return nil, err
+	}
+	m := &VersionsManifest{
+		DockerYumVersion:       all.DockerYumVersion,
+		DockerAptVersion:       all.DockerAptVersion,
+		GlusterFSRHELVersion:   all.GlusterFSRHELVersion,
+		GlusterFSUbuntuVersion: all.GlusterFSUbuntuVersion,
+		Images:                 map[string]ManifestImage{},
+	}
+	if v, err := parseKubernetesVersion(p.Cluster.Version); err == nil {
+		m.KubernetesYumVersion, m.KubernetesAptVersion = kubernetesPackageVersions(v)
+	}
+	versions := p.Versions()
+	for k, img := range images.OfficialImages {
+		if v, ok := versions[k]; ok {
+			img.Version = v
+		}
+		m.Images[k] = img
+	}
+	return m, nil
+}
+
+func readYAMLFile(file string, out interface{}) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", file, err)
+	}
+	if err := yaml.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("error unmarshaling %s: %v", file, err)
+	}
+	return nil
+}
+
+// ComponentChange is a package, binary or container image that is changed on
+// a node by the upgrade
+type ComponentChange struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// From is the version installed on the node. Only the Kubernetes version
+	// is recorded on the nodes, so the installed version of other components is
+	// described by the KET version that installed them.
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// NodeUpgradeImpact describes the changes made to a node by the upgrade
+type NodeUpgradeImpact struct {
+	Host              string            `json:"host"`
+	Roles             []string          `json:"roles"`
+	Changes           []ComponentChange `json:"changes"`
+	RestartedServices []string          `json:"restartedServices"`
+	// Drained is true when the workloads are drained from the node before it
+	// is upgraded
+	Drained           bool   `json:"drained"`
+	EstimatedDowntime string `json:"estimatedDowntime"`
+}
+
+// RoleUpgradeImpact describes the disruption caused by upgrading a node of a
+// given role
+type RoleUpgradeImpact struct {
+	Role              string `json:"role"`
+	Nodes             int    `json:"nodes"`
+	EstimatedDowntime string `json:"estimatedDowntime"`
+	Impact            string `json:"impact"`
+}
+
+// UpgradeImpactReport describes what an upgrade would change on each node,
+// without making any changes to the cluster
+type UpgradeImpactReport struct {
+	KismaticVersion   string              `json:"kismaticVersion"`
+	KubernetesVersion string              `json:"kubernetesVersion"`
+	Online            bool                `json:"online"`
+	Nodes             []NodeUpgradeImpact `json:"nodes"`
+	Roles             []RoleUpgradeImpact `json:"roles"`
+}
+
+// Write the report as JSON to the directory, and return the path of the file
+func (r UpgradeImpactReport) Write(dir string) (string, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling upgrade impact report: %v", err)
+	}
+	file := filepath.Join(dir, upgradeImpactReportFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return "", fmt.Errorf("error writing upgrade impact report to %s: %v", file, err)
+	}
+	return file, nil
+}
+
+// BuildUpgradeImpactReport returns the changes that upgrading the nodes to the
+// plan would make, based on the versions manifest and on the versions
+// recorded on the nodes. The online upgrade drains the Kubernetes nodes before
+// upgrading them.
+func BuildUpgradeImpactReport(p Plan, nodes []ListableNode, m VersionsManifest, online bool) UpgradeImpactReport {
+	r := UpgradeImpactReport{
+		KismaticVersion:   KismaticVersion.String(),
+		KubernetesVersion: p.Cluster.Version,
+		Online:            online,
+	}
+	roleCount := map[string]int{}
+	for _, n := range nodes {
+		impact := nodeUpgradeImpact(p, n, m, online)
+		r.Nodes = append(r.Nodes, impact)
+		for _, role := range n.Roles {
+			roleCount[role]++
+		}
+	}
+	roles := []string{}
+	for role := range roleCount {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		r.Roles = append(r.Roles, RoleUpgradeImpact{
+			Role:              role,
+			Nodes:             roleCount[role],
+			EstimatedDowntime: roleDowntimeEstimates[role].String(),
+			Impact:            roleImpact(p, role, online),
+		})
+	}
+	return r
+}
+
+func nodeUpgradeImpact(p Plan, n ListableNode, m VersionsManifest, online bool) NodeUpgradeImpact {
+	impact := NodeUpgradeImpact{
+		Host:  n.Node.Host,
+		Roles: n.Roles,
+	}
+	has := func(role string) bool { return contains(role, n.Roles) }
+	isK8sNode := has("master") || has("worker") || has("ingress") || has("storage")
+	// components that are not versioned with Kubernetes are only reinstalled
+	// when the node was installed by an older version of KET
+	ketChanged := IsOlderVersion(n.Version)
+	installedBy := fmt.Sprintf("installed by KET %s", n.Version)
+	k8sChanged := isK8sNode && n.ComponentVersions.Kubernetes != p.Cluster.Version
+
+	add := func(kind, name, from, to string) {
+		impact.Changes = append(impact.Changes, ComponentChange{Kind: kind, Name: name, From: from, To: to})
+	}
+	addImages := func(keys ...string) {
+		for _, k := range keys {
+			if img, ok := m.Images[k]; ok {
+				add(ComponentKindImage, img.Name, installedBy, img.Version)
+			}
+		}
+	}
+	addK8sImages := func(keys ...string) {
+		for _, k := range keys {
+			if img, ok := m.Images[k]; ok {
+				add(ComponentKindImage, img.Name, n.ComponentVersions.Kubernetes, img.Version)
+			}
+		}
+	}
+	restart := func(services ...string) {
+		impact.RestartedServices = append(impact.RestartedServices, services...)
+	}
+
+	dockerChanged := ketChanged && !p.Docker.Disable
+	if dockerChanged {
+		add(ComponentKindPackage, "docker-ce", installedBy, fmt.Sprintf("%s (yum), %s (apt)", m.DockerYumVersion, m.DockerAptVersion))
+		restart("docker")
+	}
+	if has("etcd") && ketChanged {
+		addImages("etcd")
+		restart("etcd_k8s")
+		if cniProvider(p) == cniProviderCalico {
+			restart("etcd_networking")
+		}
+	}
+	if k8sChanged {
+		add(ComponentKindPackage, "kubelet", n.ComponentVersions.Kubernetes, p.Cluster.Version)
+		add(ComponentKindPackage, "kubectl", n.ComponentVersions.Kubernetes, p.Cluster.Version)
+	}
+	if isK8sNode && (k8sChanged || dockerChanged) {
+		restart("kubelet")
+	}
+	if has("master") && k8sChanged {
+		addK8sImages("kube_apiserver", "kube_controller_manager", "kube_scheduler")
+		restart("kube-apiserver", "kube-controller-manager", "kube-scheduler")
+	}
+	if k8sChanged {
+		addK8sImages("kube_proxy")
+		restart("kube-proxy")
+	}
+	if isK8sNode && ketChanged && p.NetworkConfigured() {
+		switch cniProvider(p) {
+		case cniProviderCalico:
+			addImages("calico_node")
+			if img, ok := m.Images["calico_cni"]; ok {
+				add(ComponentKindBinary, "calico CNI plugins", installedBy, img.Version)
+			}
+			restart("calico-node")
+		case cniProviderWeave:
+			addImages("weave", "weave_npc")
+			restart("weave-net")
+		}
+	}
+	if has("ingress") && ketChanged {
+		addImages("nginx_ingress_controller", "defaultbackend")
+		restart("ingress")
+	}
+	if has("storage") && ketChanged {
+		add(ComponentKindPackage, "glusterfs-server", installedBy, fmt.Sprintf("%s (yum), %s (apt)", m.GlusterFSRHELVersion, m.GlusterFSUbuntuVersion))
+	}
+	impact.Drained = online && isK8sNode
+
+	var downtime time.Duration
+	for _, role := range n.Roles {
+		if d := roleDowntimeEstimates[role]; d > downtime {
+			downtime = d
+		}
+	}
+	impact.EstimatedDowntime = downtime.String()
+	return impact
+}
+
+// cniProvider returns the CNI provider of the plan. Plans that do not
+// configure the CNI add-on use calico.
+func cniProvider(p Plan) string {
+	if p.AddOns.CNI == nil {
+		return cniProviderCalico
+	}
+	return p.AddOns.CNI.Provider
+}
+
+func roleImpact(p Plan, role string, online bool) string {
+	switch role {
+	case "etcd":
+		if len(p.Etcd.Nodes) < 3 {
+			return "the etcd cluster loses quorum, and the Kubernetes API is unavailable, while the node is upgraded"
+		}
+		return "the etcd member is restarted; the etcd cluster remains available"
+	case "master":
+		if len(p.Master.Nodes) < 2 {
+			return "the Kubernetes API is unavailable while the node is upgraded"
+		}
+		return "the API server of the node is unavailable; requests are served by the other master nodes"
+	default:
+		if online {
+			return "the node is cordoned and drained; its pods are rescheduled on other nodes before the upgrade"
+		}
+		return "the node is not drained; its pods are unavailable while docker and the kubelet are restarted"
+	}
+}
@@ -0,0 +1,124 @@
+package install
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+)
+
+func TestReadVersionsManifest(t *testing.T) {
+	p := Plan{Cluster: Cluster{Version: "v1.10.5"}}
+	m, err := ReadVersionsManifest("../../ansible/group_vars", p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.DockerYumVersion == "" || m.DockerAptVersion == "" {
+		t.Errorf("expected the docker versions to be read, but got %+v", m)
+	}
+	if img := m.Images["kube_apiserver"]; img.Version != "v1.10.5" {
+		t.Errorf("expected the kube_apiserver version to be set to the plan version, but got %q", img.Version)
+	}
+	if img := m.Images["etcd"]; img.Name == "" || img.Version == "" {
+		t.Errorf("expected the etcd image to be read, but got %+v", img)
+	}
+}
+
+func TestBuildUpgradeImpactReport(t *testing.T) {
+	defer func(v semver.Version) { KismaticVersion = v }(KismaticVersion)
+	KismaticVersion = mustParseVersion("1.11.0")
+	p := Plan{
+		Cluster: Cluster{Version: "v1.10.5"},
+		Etcd:    NodeGroup{Nodes: []Node{{Host: "etcd01"}}},
+		Master:  MasterNodeGroup{Nodes: []Node{{Host: "master01"}, {Host: "master02"}}},
+		Worker:  NodeGroup{Nodes: []Node{{Host: "worker01"}, {Host: "worker02"}}},
+		AddOns:  AddOns{CNI: &CNI{Provider: cniProviderCalico}},
+	}
+	m := VersionsManifest{
+		DockerYumVersion: "17.03.2.ce-1.el7.centos",
+		DockerAptVersion: "17.03.2~ce-0~ubuntu-xenial",
+		Images: map[string]ManifestImage{
+			"etcd":                    {Name: "etcd", Version: "v3.1.13"},
+			"kube_apiserver":          {Name: "kube-apiserver", Version: "v1.10.5"},
+			"kube_controller_manager": {Name: "kube-controller-manager", Version: "v1.10.5"},
+			"kube_scheduler":          {Name: "kube-scheduler", Version: "v1.10.5"},
+			"kube_proxy":              {Name: "kube-proxy", Version: "v1.10.5"},
+			"calico_node":             {Name: "calico/node", Version: "v2.6.10"},
+			"calico_cni":              {Name: "calico/cni", Version: "v1.11.6"},
+		},
+	}
+	older := mustParseVersion("1.10.0")
+	nodes := []ListableNode{
+		{Node: Node{Host: "etcd01"}, Roles: []string{"etcd"}, Version: older},
+		{Node: Node{Host: "master01"}, Roles: []string{"master"}, Version: KismaticVersion, ComponentVersions: ComponentVersions{Kubernetes: "v1.10.3"}},
+		{Node: Node{Host: "worker01"}, Roles: []string{"worker"}, Version: older, ComponentVersions: ComponentVersions{Kubernetes: "v1.10.3"}},
+	}
+	r := BuildUpgradeImpactReport(p, nodes, m, true)
+	if len(r.Nodes) != 3 {
+		t.Fatalf("expected 3 nodes, but got %d", len(r.Nodes))
+	}
+
+	etcd := r.Nodes[0]
+	if !reflect.DeepEqual(etcd.RestartedServices, []string{"docker", "etcd_k8s", "etcd_networking"}) {
+		t.Errorf("unexpected services restarted on etcd node: %v", etcd.RestartedServices)
+	}
+	if etcd.Drained {
+		t.Error("expected the etcd node not to be drained")
+	}
+
+	// The master node is at the current KET version, so only the Kubernetes
+	// components are changed
+	master := r.Nodes[1]
+	expected := []ComponentChange{
+		{Kind: ComponentKindPackage, Name: "kubelet", From: "v1.10.3", To: "v1.10.5"},
+		{Kind: ComponentKindPackage, Name: "kubectl", From: "v1.10.3", To: "v1.10.5"},
+		{Kind: ComponentKindImage, Name: "kube-apiserver", From: "v1.10.3", To: "v1.10.5"},
+		{Kind: ComponentKindImage, Name: "kube-controller-manager", From: "v1.10.3", To: "v1.10.5"},
+		{Kind: ComponentKindImage, Name: "kube-scheduler", From: "v1.10.3", To: "v1.10.5"},
+		{Kind: ComponentKindImage, Name: "kube-proxy", From: "v1.10.3", To: "v1.10.5"},
+	}
+	if !reflect.DeepEqual(master.Changes, expected) {
+		t.Errorf("unexpected changes on master node:\nexpected %+v\ngot      %+v", expected, master.Changes)
+	}
+	if !master.Drained {
+		t.Error("expected the master node to be drained in an online upgrade")
+	}
+
+	worker := r.Nodes[2]
+	var calicoCNI bool
+	for _, c := range worker.Changes {
+		if c.Kind == ComponentKindBinary && c.To == "v1.11.6" {
+			calicoCNI = true
+		}
+	}
+	if !calicoCNI {
+		t.Errorf("expected the calico CNI plugins to be changed on the worker node, but got %+v", worker.Changes)
+	}
+	if worker.EstimatedDowntime != "3m0s" {
+		t.Errorf("expected the worker downtime to be 3m0s, but got %s", worker.EstimatedDowntime)
+	}
+
+	expectedRoles := []string{"etcd", "master", "worker"}
+	for i, role := range r.Roles {
+		if role.Role != expectedRoles[i] || role.Nodes != 1 {
+			t.Errorf("unexpected role impact: %+v", role)
+		}
+	}
+	// a single etcd node loses quorum
+	if !strings.Contains(r.Roles[0].Impact, "quorum") {
+		t.Errorf("unexpected etcd impact: %s", r.Roles[0].Impact)
+	}
+}
+
+func TestUpgradeImpactOfflineWorkerIsNotDrained(t *testing.T) {
+	p := Plan{Cluster: Cluster{Version: "v1.10.5"}}
+	n := ListableNode{Node: Node{Host: "worker01"}, Roles: []string{"worker"}, Version: KismaticVersion, ComponentVersions: ComponentVersions{Kubernetes: "v1.10.3"}}
+	r := BuildUpgradeImpactReport(p, []ListableNode{n}, VersionsManifest{}, false)
+	if r.Nodes[0].Drained {
+		t.Error("expected the worker node not to be drained in an offline upgrade")
+	}
+	if !strings.Contains(r.Roles[0].Impact, "not drained") {
+		t.Errorf("unexpected worker impact: %s", r.Roles[0].Impact)
+	}
+}
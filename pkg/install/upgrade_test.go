@@ -3,6 +3,7 @@ package install
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -815,3 +816,111 @@ func TestDetectNodeUpgradeSafetyAllReplicaControllerPodsSameNode(t *testing.T) {
 		t.Errorf("expected replicasOnSingleNodeErr, but got %T", errs[0])
 	}
 }
+
+func TestControlPlaneParallelism(t *testing.T) {
+	nodes := func(n int) []Node {
+		var nodes []Node
+		for i := 0; i < n; i++ {
+			nodes = append(nodes, Node{Host: fmt.Sprintf("node%d", i), IP: fmt.Sprintf("10.0.0.%d", i)})
+		}
+		return nodes
+	}
+	tests := []struct {
+		name           string
+		etcdCount      int
+		masterCount    int
+		fqdn           string
+		max            int
+		expectedEtcd   int
+		expectedMaster int
+	}{
+		{name: "no parallelism requested", etcdCount: 7, masterCount: 3, fqdn: "lb", max: 1, expectedEtcd: 1, expectedMaster: 1},
+		{name: "three etcd nodes", etcdCount: 3, masterCount: 3, fqdn: "lb", max: 3, expectedEtcd: 1, expectedMaster: 2},
+		{name: "five etcd nodes", etcdCount: 5, masterCount: 3, fqdn: "lb", max: 3, expectedEtcd: 2, expectedMaster: 2},
+		{name: "seven etcd nodes", etcdCount: 7, masterCount: 3, fqdn: "lb", max: 5, expectedEtcd: 3, expectedMaster: 2},
+		{name: "limited by max", etcdCount: 7, masterCount: 5, fqdn: "lb", max: 2, expectedEtcd: 2, expectedMaster: 2},
+		{name: "masters not load balanced", etcdCount: 5, masterCount: 3, fqdn: "node0", max: 3, expectedEtcd: 1, expectedMaster: 1},
+		{name: "no load balancer", etcdCount: 5, masterCount: 3, max: 3, expectedEtcd: 1, expectedMaster: 1},
+		{name: "single master", etcdCount: 5, masterCount: 1, fqdn: "lb", max: 3, expectedEtcd: 1, expectedMaster: 1},
+	}
+	for _, test := range tests {
+		plan := Plan{
+			Etcd:   NodeGroup{Nodes: nodes(test.etcdCount)},
+			Master: MasterNodeGroup{LoadBalancedFQDN: test.fqdn, Nodes: nodes(test.masterCount)},
+		}
+		etcd, master := controlPlaneParallelism(plan, test.max)
+		if etcd != test.expectedEtcd || master != test.expectedMaster {
+			t.Errorf("%s: expected %d etcd and %d master nodes in parallel, got %d and %d", test.name, test.expectedEtcd, test.expectedMaster, etcd, master)
+		}
+	}
+}
+
+func TestUpgradeBatchesEtcdNodesThatAreMasters(t *testing.T) {
+	node := func(name string, roles ...string) ListableNode {
+		return ListableNode{Node: Node{Host: name, IP: name}, Roles: roles}
+	}
+	nodes := []ListableNode{
+		node("etcd0", "etcd", "master"),
+		node("etcd1", "etcd", "master"),
+		node("etcd2", "etcd", "master"),
+		node("etcd3", "etcd"),
+		node("etcd4", "etcd"),
+		node("etcd5", "etcd"),
+		node("etcd6", "etcd"),
+	}
+	tests := []struct {
+		name       string
+		size       int
+		masterSize int
+		expected   [][]string
+	}{
+		{
+			name:       "masters upgraded one at a time",
+			size:       3,
+			masterSize: 1,
+			expected:   [][]string{{"etcd0"}, {"etcd1"}, {"etcd2"}, {"etcd3", "etcd4", "etcd5"}, {"etcd6"}},
+		},
+		{
+			name:       "masters upgraded two at a time",
+			size:       3,
+			masterSize: 2,
+			expected:   [][]string{{"etcd0", "etcd1"}, {"etcd2", "etcd3"}, {"etcd4", "etcd5", "etcd6"}},
+		},
+		{
+			name:       "no parallelism",
+			size:       1,
+			masterSize: 1,
+			expected:   [][]string{{"etcd0"}, {"etcd1"}, {"etcd2"}, {"etcd3"}, {"etcd4"}, {"etcd5"}, {"etcd6"}},
+		},
+	}
+	for _, test := range tests {
+		var got [][]string
+		for _, batch := range upgradeBatches(nodes, test.size, test.masterSize) {
+			var hosts []string
+			for _, n := range batch {
+				hosts = append(hosts, n.Node.Host)
+			}
+			got = append(got, hosts)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected batches %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestControlPlaneParallelismEtcdOnMasters(t *testing.T) {
+	var nodes []Node
+	for i := 0; i < 5; i++ {
+		nodes = append(nodes, Node{Host: fmt.Sprintf("node%d", i), IP: fmt.Sprintf("10.0.0.%d", i)})
+	}
+	// the load balanced FQDN points to one of the masters, which are also
+	// the etcd nodes
+	plan := Plan{
+		Etcd:   NodeGroup{Nodes: nodes},
+		Master: MasterNodeGroup{LoadBalancedFQDN: "node0", Nodes: nodes},
+	}
+	etcd, master := controlPlaneParallelism(plan, 3)
+	if etcd != 1 || master != 1 {
+		t.Errorf("expected the etcd and master nodes to be upgraded one at a time, got %d and %d", etcd, master)
+	}
+}
@@ -1,13 +1,19 @@
 package install
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -145,13 +151,31 @@ func (p *Plan) validate() (bool, []error) {
 
 	v.validateWithErrPrefix("Docker", p.Docker)
 	v.validate(&additionalFilesGroup{AdditionalFiles: p.AdditionalFiles, Plan: p})
+	v.validateWithErrPrefix("Asset distribution", p.AssetDistribution)
 	v.validate(&p.AddOns)
 	v.validate(nodeList{Nodes: p.getAllNodes()})
+	v.validate(&localVolumeGroup{Plan: p})
+	v.validate(&heketiGroup{Plan: p})
 	v.validateWithErrPrefix("Etcd nodes", &p.Etcd)
 	v.validateWithErrPrefix("Master nodes", &p.Master)
 	v.validateWithErrPrefix("Worker nodes", &p.Worker)
 	v.validateWithErrPrefix("Ingress nodes", &p.Ingress)
 	v.validate(p.NFS)
+	v.validate(&tenantGroup{Tenants: p.Tenants})
+	if p.Cluster.FIPS {
+		for _, n := range p.GetUniqueNodes() {
+			v.addError(validateFIPSTLSOptions(fmt.Sprintf("Node %q kubelet", n.Host), n.KubeletOptions.Overrides)...)
+		}
+	}
+	for _, n := range p.GetUniqueNodes() {
+		v.addError(protectedOptionErrors(fmt.Sprintf("Node %q kubelet", n.Host), n.KubeletOptions.Overrides, kubeletProtectedOptions)...)
+	}
+	// the version of the cluster is validated with the cluster
+	if version, err := parseKubernetesVersion(p.Cluster.Version); err == nil {
+		for _, n := range p.GetUniqueNodes() {
+			v.addError(validateKubeletFlags(fmt.Sprintf("Node %q kubelet", n.Host), n.KubeletOptions.Overrides, version)...)
+		}
+	}
 	v.validateWithErrPrefix("Storage nodes", &p.Storage)
 
 	return v.valid()
@@ -163,25 +187,33 @@ func (c *Cluster) validate() (bool, []error) {
 		v.addError(errors.New("Cluster name cannot be empty"))
 	}
 	// must be a valid semver, start with "v" and be a "suppored" version
-	if !kubernetesVersionValid(c.Version) {
-		v.addError(fmt.Errorf("Cluster version %q invalid, must be a valid %q version, ie %q", c.Version, kubernetesMinorVersionString, kubernetesVersionString))
+	if version, err := parseKubernetesVersion(c.Version); err != nil {
+		v.addError(fmt.Errorf("Cluster version %q invalid, %v", c.Version, err))
 	} else {
 		// only go out and get latest version if not disconnected install
 		if !c.DisconnectedInstallation {
-			// should not fail here as its a valid regex
-			version, err := parseVersion(c.Version)
-			// TODO print a warning
+			latestSemver, latest, err := kubernetesLatestStableVersion() // will always return some version
 			if err == nil {
-				latestSemver, latest, err := kubernetesLatestStableVersion() // will always return some version
-				if err == nil {
-					if version.GT(latestSemver) {
-						v.addError(fmt.Errorf("Cluster version %q invalid, the latest stable version is %q", c.Version, latest))
-					}
+				if version.GT(latestSemver) {
+					v.addError(fmt.Errorf("Cluster version %q invalid, the latest stable version is %q", c.Version, latest))
 				}
-				// continue with the installation if an error occurs getting the latest version
 			}
+			// continue with the installation if an error occurs getting the latest version
 		}
+		v.addError(validateKubeletFlags("Kubelet", c.KubeletOptions.Overrides, version)...)
+		v.addError(validateAuthentication(*c, version)...)
+	}
+
+	if c.AdminPasswordSecret != nil {
+		if c.AdminPassword != "" {
+			v.addError(errors.New("Admin password and admin password secret cannot be set at the same time"))
+		}
+		v.validateWithErrPrefix("Admin password secret", c.AdminPasswordSecret)
+	}
+	if c.GenerateAdminPassword && (c.AdminPassword != "" || c.AdminPasswordSecret != nil) {
+		v.addError(errors.New("Admin password cannot be generated when the admin password or admin password secret is set"))
 	}
+	v.validateWithErrPrefix("Authentication", &c.Authentication)
 
 	v.validate(&c.Networking)
 	v.validate(&c.Certificates)
@@ -192,10 +224,82 @@ func (c *Cluster) validate() (bool, []error) {
 	v.validate(&c.KubeSchedulerOptions)
 	v.validate(&c.KubeletOptions)
 	v.validate(&c.CloudProvider)
+	v.validate(&c.Artifacts)
+	v.validate(&c.ClusterInfo)
+	v.validate(&c.Parallelism)
+
+	credentialProviders := map[string]bool{}
+	for i := range c.ImageCredentialProviders {
+		icp := &c.ImageCredentialProviders[i]
+		if credentialProviders[icp.Type] {
+			v.addError(fmt.Errorf("Image credential provider %q is defined more than once", icp.Type))
+		}
+		credentialProviders[icp.Type] = true
+		v.validate(icp)
+	}
+
+	if c.FIPS {
+		v.addError(validateFIPSTLSOptions("Kubernetes API Server", c.APIServerOptions.Overrides)...)
+		v.addError(validateFIPSTLSOptions("Kubelet", c.KubeletOptions.Overrides)...)
+	}
 
 	return v.valid()
 }
 
+func (icp *ImageCredentialProvider) validate() (bool, []error) {
+	v := newValidator()
+	if _, ok := imageCredentialHelpers[icp.Type]; !ok {
+		v.addError(fmt.Errorf("Image credential provider type %q is not valid. Options are %v", icp.Type, imageCredentialProviderTypes()))
+		return v.valid()
+	}
+	if len(icp.Registries) == 0 {
+		v.addError(fmt.Errorf("Image credential provider %q must have at least one registry", icp.Type))
+	}
+	for _, r := range icp.Registries {
+		if r == "" || strings.ContainsAny(r, "/ ") {
+			v.addError(fmt.Errorf("Image credential provider %q registry %q is not valid, must be the host of the registry without the protocol", icp.Type, r))
+		}
+	}
+	v.addError(icp.Helper.validate(icp.Type + " credential helper")...)
+	if icp.RefreshInterval != "" {
+		d, err := time.ParseDuration(icp.RefreshInterval)
+		if err != nil {
+			v.addError(fmt.Errorf("Image credential provider %q refresh interval %q is not a valid duration: %v", icp.Type, icp.RefreshInterval, err))
+		} else if d < time.Minute {
+			v.addError(fmt.Errorf("Image credential provider %q refresh interval must be at least 1m, got %s", icp.Type, icp.RefreshInterval))
+		}
+	}
+	return v.valid()
+}
+
+func (c *ClusterInfoOptions) validate() (bool, []error) {
+	v := newValidator()
+	if c.PublishURL == "" {
+		return v.valid()
+	}
+	u, err := url.Parse(c.PublishURL)
+	if err != nil {
+		v.addError(fmt.Errorf("Cluster info publish URL %q is not a valid URL: %v", c.PublishURL, err))
+		return v.valid()
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			v.addError(fmt.Errorf("Cluster info publish URL %q must include a host", c.PublishURL))
+		}
+	case "s3":
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			v.addError(fmt.Errorf("Cluster info publish URL %q must include a bucket and a key, ie s3://bucket/cluster-info.json", c.PublishURL))
+		}
+		if c.S3Region == "" {
+			v.addError(errors.New("Cluster info S3 region must be set when publishing to S3"))
+		}
+	default:
+		v.addError(fmt.Errorf("Cluster info publish URL %q is invalid, only http, https and s3 URLs are supported", c.PublishURL))
+	}
+	return v.valid()
+}
+
 func (n *NetworkConfig) validate() (bool, []error) {
 	v := newValidator()
 	if n.PodCIDRBlock == "" {
@@ -230,18 +334,82 @@ func (s *SSHConfig) validate() (bool, []error) {
 	if s.User == "" {
 		v.addError(errors.New("SSH user field is required"))
 	}
-	if s.Key == "" {
-		v.addError(errors.New("SSH key field is required"))
-	}
-	if _, err := os.Stat(s.Key); os.IsNotExist(err) {
-		v.addError(fmt.Errorf("SSH Key file was not found at %q", s.Key))
-	}
-	if !filepath.IsAbs(s.Key) {
-		v.addError(errors.New("SSH Key field must be an absolute path"))
+	if s.KeySecret != nil {
+		if s.Key != "" {
+			v.addError(errors.New("SSH key and SSH key secret cannot be set at the same time"))
+		}
+		v.validateWithErrPrefix("SSH key secret", s.KeySecret)
+	} else {
+		if s.Key == "" {
+			v.addError(errors.New("SSH key field is required"))
+		}
+		if _, err := os.Stat(s.Key); os.IsNotExist(err) {
+			v.addError(fmt.Errorf("SSH Key file was not found at %q", s.Key))
+		}
+		if !filepath.IsAbs(s.Key) {
+			v.addError(errors.New("SSH Key field must be an absolute path"))
+		}
 	}
 	if s.Port < 1 || s.Port > 65535 {
 		v.addError(fmt.Errorf("SSH port %d is invalid. Port must be in the range 1-65535", s.Port))
 	}
+	if s.ServiceAccount != nil {
+		v.validateWithErrPrefix("SSH service account", s.ServiceAccount)
+		if s.ServiceAccount.User == s.User {
+			v.addError(fmt.Errorf("SSH service account %q must be different from the SSH user", s.User))
+		}
+	}
+	return v.valid()
+}
+
+// unixUserRE matches the names of the accounts that can be created on the nodes
+var unixUserRE = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+func (sa *SSHServiceAccount) validate() (bool, []error) {
+	v := newValidator()
+	if !unixUserRE.MatchString(sa.User) {
+		v.addError(fmt.Errorf("user %q is not valid, must start with a lowercase letter or underscore, and contain at most 32 lowercase letters, digits, underscores or dashes", sa.User))
+	} else if sa.User == "root" {
+		v.addError(errors.New("user cannot be root"))
+	}
+	if len(sa.SudoCommands) == 0 {
+		v.addError(errors.New("at least one sudo command is required"))
+	}
+	for _, cmd := range sa.SudoCommands {
+		if fields := strings.Fields(cmd); cmd != "ALL" && (len(fields) == 0 || !path.IsAbs(fields[0])) {
+			v.addError(fmt.Errorf("sudo command %q must be ALL, or start with an absolute path", cmd))
+		}
+		if strings.ContainsAny(cmd, ",\n") {
+			v.addError(fmt.Errorf("sudo command %q cannot contain commas or newlines", cmd))
+		}
+	}
+	return v.valid()
+}
+
+func (s *SecretReference) validate() (bool, []error) {
+	v := newValidator()
+	if !util.Contains(s.Provider, secretProviders()) {
+		v.addError(fmt.Errorf("%q is not a valid secrets manager. Options are %v", s.Provider, secretProviders()))
+	}
+	if s.Name == "" {
+		v.addError(errors.New("secret name is required"))
+	}
+	switch s.Provider {
+	case awsSecretsManagerProvider:
+		if s.Region == "" {
+			v.addError(errors.New("region is required for AWS Secrets Manager secrets"))
+		}
+	case vaultSecretProvider:
+		if s.Field == "" {
+			v.addError(errors.New("field is required for Vault secrets"))
+		}
+	case azureKeyVaultSecretProvider:
+		if s.Name != "" {
+			if _, err := azureKeyVaultCloudOf(s.Name); err != nil {
+				v.addError(err)
+			}
+		}
+	}
 	return v.valid()
 }
 
@@ -252,19 +420,269 @@ func (c *CloudProvider) validate() (bool, []error) {
 			v.addError(fmt.Errorf("%q is not a valid cloud provider. Options are %v", c.Provider, cloudProviders()))
 		}
 		if c.Config != "" {
-			if _, err := os.Stat(c.Config); os.IsNotExist(err) {
+			b, err := ioutil.ReadFile(c.Config)
+			if os.IsNotExist(err) {
 				v.addError(fmt.Errorf("cloud config file was not found at %q", c.Config))
+			} else if err != nil {
+				v.addError(fmt.Errorf("error reading cloud config file %q: %v", c.Config, err))
+			} else {
+				v.addError(validateCloudConfig(c.Provider, b)...)
+			}
+		} else if util.Contains(c.Provider, cloudProvidersRequiringConfig()) && c.VSphere == nil && c.Azure == nil {
+			v.addError(fmt.Errorf("a cloud config file is required for the %q cloud provider", c.Provider))
+		}
+		if c.Azure != nil {
+			if c.Provider != "azure" {
+				v.addError(fmt.Errorf("the azure configuration cannot be set when the cloud provider is %q", c.Provider))
+			}
+			if c.Config != "" {
+				v.addError(fmt.Errorf("the cloud config file and the azure configuration cannot be set together"))
 			}
+			v.validate(c.Azure)
+		}
+		if c.VSphere != nil {
+			if c.Provider != "vsphere" {
+				v.addError(fmt.Errorf("the vsphere configuration cannot be set when the cloud provider is %q", c.Provider))
+			}
+			if c.Config != "" {
+				v.addError(fmt.Errorf("the cloud config file and the vsphere configuration cannot be set together"))
+			}
+			v.validate(c.VSphere)
+		}
+	}
+	return v.valid()
+}
+
+func (a *AzureCloudProvider) validate() (bool, []error) {
+	v := newValidator()
+	required := [][2]string{
+		{"tenant_id", a.TenantID},
+		{"subscription_id", a.SubscriptionID},
+		{"resource_group", a.ResourceGroup},
+		{"location", a.Location},
+		{"vnet_name", a.VNetName},
+		{"subnet_name", a.SubnetName},
+		{"security_group_name", a.SecurityGroupName},
+	}
+	if !a.UseManagedIdentity {
+		required = append(required, [2]string{"client_id", a.ClientID}, [2]string{"client_secret", a.ClientSecret})
+	}
+	for _, r := range required {
+		if r[1] == "" {
+			v.addError(fmt.Errorf("azure %s cannot be empty", r[0]))
+		}
+	}
+	if _, ok := azureEnvironments[a.Cloud]; !ok || a.Cloud == "" {
+		v.addError(fmt.Errorf("%q is not a valid azure cloud", a.Cloud))
+	}
+	if !util.Contains(a.VMType, azureVMTypes()) {
+		v.addError(fmt.Errorf("%q is not a valid azure VM type. Options are %v", a.VMType, azureVMTypes()))
+	}
+	if a.VMType == "vmss" && a.PrimaryScaleSetName == "" {
+		v.addError(fmt.Errorf("azure primary_scale_set_name cannot be empty when the VM type is vmss"))
+	}
+	if !util.Contains(a.LoadBalancerSKU, azureLoadBalancerSKUs()) {
+		v.addError(fmt.Errorf("%q is not a valid azure load balancer SKU. Options are %v", a.LoadBalancerSKU, azureLoadBalancerSKUs()))
+	}
+	if a.MasterBackendPool != nil {
+		if a.VMType != "standard" {
+			v.addError(fmt.Errorf("azure master_backend_pool is only supported when the VM type is standard"))
+		}
+		if a.MasterBackendPool.LoadBalancer == "" || a.MasterBackendPool.Name == "" {
+			v.addError(fmt.Errorf("azure master_backend_pool requires the load balancer and the name of the backend pool"))
+		}
+	}
+	return v.valid()
+}
+
+func (vs *VSphereCloudProvider) validate() (bool, []error) {
+	v := newValidator()
+	required := [][2]string{
+		{"server", vs.Server},
+		{"username", vs.Username},
+		{"password", vs.Password},
+		{"datacenter", vs.Datacenter},
+		{"datastore", vs.Datastore},
+		{"folder", vs.Folder},
+	}
+	for _, r := range required {
+		if r[1] == "" {
+			v.addError(fmt.Errorf("vsphere %s cannot be empty", r[0]))
+		}
+	}
+	if vs.Port < 1 || vs.Port > 65535 {
+		v.addError(fmt.Errorf("vsphere port %d is invalid. Port must be in the range 1-65535", vs.Port))
+	}
+	if vs.StorageClass == "" {
+		v.addError(fmt.Errorf("vsphere storage class cannot be empty"))
+	}
+	if !util.Contains(vs.DiskFormat, vsphereDiskFormats()) {
+		v.addError(fmt.Errorf("%q is not a valid vsphere disk format. Options are %v", vs.DiskFormat, vsphereDiskFormats()))
+	}
+	return v.valid()
+}
+
+func (a *Artifacts) validate() (bool, []error) {
+	v := newValidator()
+	v.addError(a.Inspector.validate("inspector")...)
+	v.addError(a.Kuberang.validate("kuberang")...)
+	return v.valid()
+}
+
+var (
+	serialRegexp   = regexp.MustCompile(`^[1-9][0-9]*%?$`)
+	playFileRegexp = regexp.MustCompile(`^_[a-z0-9-]+\.yaml$`)
+)
+
+func (p *Parallelism) validate() (bool, []error) {
+	v := newValidator()
+	if p.Forks < 0 {
+		v.addError(fmt.Errorf("Parallelism forks %d cannot be negative", p.Forks))
+	}
+	if p.Serial != "" {
+		v.addError(validateSerial("Parallelism serial", p.Serial)...)
+	}
+	for play, serial := range p.PlaySerial {
+		if !playFileRegexp.MatchString(play) {
+			v.addError(fmt.Errorf("Parallelism play %q is invalid, must be the file name of a play, such as _docker.yaml", play))
 		}
+		v.addError(validateSerial(fmt.Sprintf("Parallelism serial of play %q", play), serial)...)
 	}
 	return v.valid()
 }
 
+func validateSerial(name, serial string) []error {
+	if !serialRegexp.MatchString(serial) {
+		return []error{fmt.Errorf("%s %q is invalid, must be a number of nodes or a percentage, such as 10 or 25%%", name, serial)}
+	}
+	if strings.HasSuffix(serial, "%") {
+		if percent, _ := strconv.Atoi(strings.TrimSuffix(serial, "%")); percent > 100 {
+			return []error{fmt.Errorf("%s %q cannot be greater than 100%%", name, serial)}
+		}
+	}
+	return nil
+}
+
+func (a Artifact) validate(name string) []error {
+	var errs []error
+	if a.Source == "" {
+		if a.SHA256 != "" {
+			errs = append(errs, fmt.Errorf("%s artifact source must be set when a SHA256 checksum is provided", name))
+		}
+		return errs
+	}
+	if a.SHA256 != "" {
+		if b, err := hex.DecodeString(a.SHA256); err != nil || len(b) != sha256.Size {
+			errs = append(errs, fmt.Errorf("%s artifact SHA256 checksum %q is invalid, must be 64 hexadecimal characters", name, a.SHA256))
+		}
+	}
+	if strings.Contains(a.Source, "://") {
+		if !isArtifactURL(a.Source) {
+			errs = append(errs, fmt.Errorf("%s artifact source %q is invalid, only http and https URLs are supported", name, a.Source))
+		} else if _, err := url.Parse(a.Source); err != nil {
+			errs = append(errs, fmt.Errorf("%s artifact source %q is not a valid URL: %v", name, a.Source, err))
+		}
+		return errs
+	}
+	if _, err := os.Stat(a.Source); os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("%s artifact was not found at %q", name, a.Source))
+	}
+	return errs
+}
+
 type additionalFilesGroup struct {
 	AdditionalFiles []AdditionalFile
 	Plan            *Plan
 }
 
+// localVolumeGroup validates the local volumes of the nodes against the
+// StorageClasses of the local volume provisioner
+type localVolumeGroup struct {
+	Plan *Plan
+}
+
+func (g *localVolumeGroup) validate() (bool, []error) {
+	v := newValidator()
+	p := g.Plan
+	lvp := p.AddOns.LocalVolumeProvisioner
+	enabled := lvp != nil && !lvp.Disable
+	if enabled && p.Cluster.CloudProvider.VSphere != nil {
+		for _, sc := range lvp.StorageClasses {
+			if sc.Default {
+				v.addError(fmt.Errorf("Local StorageClass %q cannot be the default StorageClass, the vsphere StorageClass is the default", sc.Name))
+			}
+		}
+	}
+	for _, n := range p.GetUniqueNodes() {
+		if len(n.LocalVolumes) == 0 {
+			continue
+		}
+		if !enabled {
+			v.addError(fmt.Errorf("Node %q has local volumes, but the local volume provisioner add-on is not enabled", n.Host))
+			continue
+		}
+		if roles := p.GetRolesForIP(n.IP); len(roles) == 1 && roles[0] == "etcd" {
+			v.addError(fmt.Errorf("Node %q has local volumes, but it is not a Kubernetes node", n.Host))
+		}
+		// volumes are exposed to the provisioner with a name derived from
+		// their path, which must be unique on the node
+		names := map[string]bool{}
+		for _, lv := range n.LocalVolumes {
+			if !path.IsAbs(lv.Path) || localVolumeName(lv.Path) == "" {
+				v.addError(fmt.Errorf("Local volume %q of node %q must be an absolute path", lv.Path, n.Host))
+				continue
+			}
+			if names[localVolumeName(lv.Path)] {
+				v.addError(fmt.Errorf("Local volume %q of node %q is defined more than once", lv.Path, n.Host))
+			}
+			names[localVolumeName(lv.Path)] = true
+			sc := p.localStorageClass(lv.StorageClass)
+			if sc == nil {
+				v.addError(fmt.Errorf("Local volume %q of node %q references StorageClass %q, which is not defined in the local volume provisioner", lv.Path, n.Host, lv.StorageClass))
+				continue
+			}
+			isDevice := strings.HasPrefix(path.Clean(lv.Path), "/dev/")
+			if sc.VolumeMode == "Block" && !isDevice {
+				v.addError(fmt.Errorf("Local volume %q of node %q must be a block device in /dev, StorageClass %q has the Block volume mode", lv.Path, n.Host, sc.Name))
+			}
+			if sc.VolumeMode == "Filesystem" && isDevice {
+				v.addError(fmt.Errorf("Local volume %q of node %q must be a directory, StorageClass %q has the Filesystem volume mode", lv.Path, n.Host, sc.Name))
+			}
+		}
+	}
+	return v.valid()
+}
+
+// heketiGroup validates the Heketi add-on against the storage of the cluster
+type heketiGroup struct {
+	Plan *Plan
+}
+
+func (g *heketiGroup) validate() (bool, []error) {
+	v := newValidator()
+	p := g.Plan
+	if !p.heketiEnabled() {
+		return v.valid()
+	}
+	if len(p.Storage.Nodes) > 0 {
+		v.addError(errors.New("Storage nodes cannot be used with the Heketi add-on, volumes are created in the external GlusterFS cluster"))
+	}
+	if !p.AddOns.Heketi.DefaultStorageClass {
+		return v.valid()
+	}
+	if p.Cluster.CloudProvider.VSphere != nil {
+		v.addError(fmt.Errorf("Heketi StorageClass %q cannot be the default StorageClass, the vsphere StorageClass is the default", p.AddOns.Heketi.StorageClass))
+	}
+	if lvp := p.AddOns.LocalVolumeProvisioner; lvp != nil && !lvp.Disable {
+		for _, sc := range lvp.StorageClasses {
+			if sc.Default {
+				v.addError(fmt.Errorf("Heketi StorageClass %q cannot be the default StorageClass, local StorageClass %q is the default", p.AddOns.Heketi.StorageClass, sc.Name))
+			}
+		}
+	}
+	return v.valid()
+}
+
 func (fg *additionalFilesGroup) validate() (bool, []error) {
 	v := newValidator()
 	for _, f := range fg.AdditionalFiles {
@@ -298,6 +716,8 @@ func (f *AddOns) validate() (bool, []error) {
 	v.validate(f.HeapsterMonitoring)
 	v.validate(f.Dashboard)
 	v.validate(&f.PackageManager)
+	v.validate(f.LocalVolumeProvisioner)
+	v.validate(f.Heketi)
 	return v.valid()
 }
 
@@ -325,10 +745,34 @@ func (n DNS) validate() (bool, []error) {
 		if !util.Contains(n.Provider, dnsProviders()) {
 			v.addError(fmt.Errorf("%q is not a valid DNS provider. Optins are %v", n.Provider, dnsProviders()))
 		}
+		for _, s := range n.Options.UpstreamServers {
+			if !validDNSServer(s) {
+				v.addError(fmt.Errorf("DNS upstream server %q is not valid, must be an IP or an IP:port", s))
+			}
+		}
+		if n.Provider == dnsProviderKubedns && len(n.Options.UpstreamServers) > 3 {
+			v.addError(fmt.Errorf("kubedns supports up to 3 DNS upstream servers, got %d", len(n.Options.UpstreamServers)))
+		}
+		if n.Provider == dnsProviderKubedns && n.Options.BlockExternal {
+			v.addError(fmt.Errorf("blocking the external DNS resolvers is only supported by %s", dnsProviderCoredns))
+		}
 	}
 	return v.valid()
 }
 
+// validDNSServer returns true if the server is an IP, or an IP and a port
+func validDNSServer(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || net.ParseIP(host) == nil {
+		return false
+	}
+	p, err := strconv.Atoi(port)
+	return err == nil && p > 0 && p <= 65535
+}
+
 func (h *HeapsterMonitoring) validate() (bool, []error) {
 	v := newValidator()
 	if h != nil && !h.Disable {
@@ -352,6 +796,65 @@ func (d *Dashboard) validate() (bool, []error) {
 	return v.valid()
 }
 
+// storageClassNameRE matches the names of Kubernetes objects (RFC 1123 subdomains)
+var storageClassNameRE = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+func (h *Heketi) validate() (bool, []error) {
+	v := newValidator()
+	if h != nil && !h.Disable {
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.addError(fmt.Errorf("Heketi URL %q is not valid, must be an http or https URL", h.URL))
+		}
+		if h.StorageClass != "" && !storageClassNameRE.MatchString(h.StorageClass) {
+			v.addError(fmt.Errorf("Heketi StorageClass name %q is not valid, must be a lowercase RFC 1123 subdomain", h.StorageClass))
+		}
+		if h.DefaultStorageClass && h.StorageClass == "" {
+			v.addError(errors.New("Heketi StorageClass name is required when it is the default StorageClass"))
+		}
+	}
+	return v.valid()
+}
+
+func (l *LocalVolumeProvisioner) validate() (bool, []error) {
+	v := newValidator()
+	if l != nil && !l.Disable {
+		if len(l.StorageClasses) == 0 {
+			v.addError(errors.New("At least one StorageClass is required for the local volume provisioner"))
+		}
+		names := map[string]bool{}
+		hostDirs := map[string]bool{}
+		defaults := 0
+		for _, sc := range l.StorageClasses {
+			if !storageClassNameRE.MatchString(sc.Name) {
+				v.addError(fmt.Errorf("Local StorageClass name %q is not valid, must be a lowercase RFC 1123 subdomain", sc.Name))
+			}
+			if names[sc.Name] {
+				v.addError(fmt.Errorf("Local StorageClass %q is defined more than once", sc.Name))
+			}
+			names[sc.Name] = true
+			if !path.IsAbs(sc.HostDir) {
+				v.addError(fmt.Errorf("Local StorageClass %q host directory %q must be an absolute path", sc.Name, sc.HostDir))
+			} else if hostDirs[path.Clean(sc.HostDir)] {
+				v.addError(fmt.Errorf("Local StorageClass %q host directory %q is used by another StorageClass", sc.Name, sc.HostDir))
+			}
+			hostDirs[path.Clean(sc.HostDir)] = true
+			if !util.Contains(sc.VolumeMode, localVolumeModes()) {
+				v.addError(fmt.Errorf("Local StorageClass %q volume mode %q is not a valid option %v", sc.Name, sc.VolumeMode, localVolumeModes()))
+			}
+			if !util.Contains(sc.ReclaimPolicy, localVolumeReclaimPolicies()) {
+				v.addError(fmt.Errorf("Local StorageClass %q reclaim policy %q is not a valid option %v", sc.Name, sc.ReclaimPolicy, localVolumeReclaimPolicies()))
+			}
+			if sc.Default {
+				defaults++
+			}
+		}
+		if defaults > 1 {
+			v.addError(errors.New("Only one local StorageClass can be the default StorageClass"))
+		}
+	}
+	return v.valid()
+}
+
 func (p *PackageManager) validate() (bool, []error) {
 	v := newValidator()
 	if !p.Disable {
@@ -366,7 +869,11 @@ func (p *PackageManager) validate() (bool, []error) {
 func (s sshConnectionSet) validate() (bool, []error) {
 	v := newValidator()
 
-	err := ssh.ValidUnencryptedPrivateKey(s.SSHConfig.Key)
+	// keys fetched from a secrets manager are validated when added to the agent
+	var err error
+	if s.SSHConfig.Key != "" {
+		err = ssh.ValidUnencryptedPrivateKey(s.SSHConfig.Key)
+	}
 	if err != nil {
 		v.addError(fmt.Errorf("SSH key validation error: %v", err))
 	} else {
@@ -412,6 +919,8 @@ func (nl nodeList) validate() (bool, []error) {
 	v := newValidator()
 	v.addError(validateNoDuplicateNodeInfo(nl.Nodes)...)
 	v.addError(validateKubeletOptionsDefinedOnce(nl.Nodes)...)
+	v.addError(validateLocalVolumesDefinedOnce(nl.Nodes)...)
+	v.addError(validateSiteDefinedOnce(nl.Nodes)...)
 	return v.valid()
 }
 
@@ -456,6 +965,43 @@ func validateKubeletOptionsDefinedOnce(nodes []Node) []error {
 	return errs
 }
 
+func validateLocalVolumesDefinedOnce(nodes []Node) []error {
+	errs := []error{}
+	seenNodes := map[string][]LocalVolume{}
+	for _, n := range nodes {
+		if val, ok := seenNodes[n.HashCode()]; ok && !reflect.DeepEqual(val, n.LocalVolumes) {
+			errs = append(errs, fmt.Errorf("Cannot redefine local volumes for node %q", n.Host))
+		} else {
+			seenNodes[n.HashCode()] = n.LocalVolumes
+		}
+	}
+	return errs
+}
+
+func validateSiteDefinedOnce(nodes []Node) []error {
+	errs := []error{}
+	seenNodes := map[string]string{}
+	for _, n := range nodes {
+		if val, ok := seenNodes[n.HashCode()]; ok && val != n.Site {
+			errs = append(errs, fmt.Errorf("Cannot redefine the site of node %q", n.Host))
+		} else {
+			seenNodes[n.HashCode()] = n.Site
+		}
+	}
+	return errs
+}
+
+func (ad AssetDistribution) validate() (bool, []error) {
+	v := newValidator()
+	if ad.BandwidthLimit < 0 {
+		v.addError(fmt.Errorf("Bandwidth limit %d cannot be negative", ad.BandwidthLimit))
+	}
+	if ad.Port < 0 || ad.Port > 65535 {
+		v.addError(fmt.Errorf("Port %d is not a valid port number", ad.Port))
+	}
+	return v.valid()
+}
+
 func (ng *NodeGroup) validate() (bool, []error) {
 	v := newValidator()
 	if ng == nil || len(ng.Nodes) <= 0 {
@@ -651,40 +1197,183 @@ func (nfsVol NFSVolume) validate() (bool, []error) {
 	return v.valid()
 }
 
+// namespaceNameRE matches the names of Kubernetes namespaces (RFC 1123 labels)
+var namespaceNameRE = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// quantityRE matches Kubernetes resource quantities, such as 500m, 2 or 4Gi
+var quantityRE = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|Ki|M|Mi|G|Gi|T|Ti|P|Pi|E|Ei)?$`)
+
+// reservedNamespaces cannot be used as tenant namespaces
+func reservedNamespaces() []string {
+	return []string{"default", "kube-system", "kube-public"}
+}
+
+type tenantGroup struct {
+	Tenants []Tenant
+}
+
+func (tg *tenantGroup) validate() (bool, []error) {
+	v := newValidator()
+	namespaces := map[string]bool{}
+	for _, t := range tg.Tenants {
+		v.validate(t)
+		if namespaces[t.Namespace] {
+			v.addError(fmt.Errorf("Tenant namespace %q is defined more than once", t.Namespace))
+		}
+		namespaces[t.Namespace] = true
+	}
+	return v.valid()
+}
+
+func (t Tenant) validate() (bool, []error) {
+	v := newValidator()
+	if len(t.Namespace) > 63 || !namespaceNameRE.MatchString(t.Namespace) {
+		v.addError(fmt.Errorf("Tenant namespace %q is not valid, must be a lowercase RFC 1123 label", t.Namespace))
+	} else if util.Contains(t.Namespace, reservedNamespaces()) {
+		v.addError(fmt.Errorf("Tenant namespace %q is reserved, cannot be one of %v", t.Namespace, reservedNamespaces()))
+	}
+	for _, err := range validateQuantities(t.Quota) {
+		v.addError(fmt.Errorf("Tenant %q quota %v", t.Namespace, err))
+	}
+	if lr := t.LimitRange; lr != nil {
+		limits := map[string]map[string]string{"default": lr.Default, "default_request": lr.DefaultRequest, "max": lr.Max, "min": lr.Min}
+		for _, name := range []string{"default", "default_request", "max", "min"} {
+			for _, err := range validateQuantities(limits[name]) {
+				v.addError(fmt.Errorf("Tenant %q limit range %s %v", t.Namespace, name, err))
+			}
+		}
+	}
+	clusterRoles := map[string]bool{}
+	for _, rb := range t.RoleBindings {
+		if rb.ClusterRole == "" {
+			v.addError(fmt.Errorf("Tenant %q role binding ClusterRole cannot be empty", t.Namespace))
+			continue
+		}
+		if !storageClassNameRE.MatchString(rb.ClusterRole) {
+			v.addError(fmt.Errorf("Tenant %q role binding ClusterRole %q is not valid", t.Namespace, rb.ClusterRole))
+		}
+		if clusterRoles[rb.ClusterRole] {
+			v.addError(fmt.Errorf("Tenant %q ClusterRole %q is bound more than once", t.Namespace, rb.ClusterRole))
+		}
+		clusterRoles[rb.ClusterRole] = true
+		if len(rb.Users)+len(rb.Groups)+len(rb.ServiceAccounts) == 0 {
+			v.addError(fmt.Errorf("Tenant %q ClusterRole %q must be bound to at least one user, group or service account", t.Namespace, rb.ClusterRole))
+		}
+		for _, sa := range rb.ServiceAccounts {
+			namespace, name := tenantServiceAccount(t.Namespace, sa)
+			if !namespaceNameRE.MatchString(namespace) || !storageClassNameRE.MatchString(name) {
+				v.addError(fmt.Errorf("Tenant %q service account %q is not valid, must be in the namespace:name format", t.Namespace, sa))
+			}
+		}
+	}
+	return v.valid()
+}
+
+// validateQuantities returns an error for every resource that is not set to a
+// valid Kubernetes quantity
+func validateQuantities(resources map[string]string) []error {
+	var names []string
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		if name == "" {
+			errs = append(errs, errors.New("resource name cannot be empty"))
+			continue
+		}
+		if !quantityRE.MatchString(resources[name]) {
+			errs = append(errs, fmt.Errorf("%q value %q is not a valid quantity", name, resources[name]))
+		}
+	}
+	return errs
+}
+
+// StorageVolumeFieldError is a validation error of an attribute of a storage volume
+type StorageVolumeFieldError struct {
+	// Field is the attribute of the storage volume that is not valid
+	Field   string
+	Message string
+}
+
+func (e StorageVolumeFieldError) Error() string {
+	return e.Message
+}
+
+func storageVolumeFieldError(field string, format string, args ...interface{}) StorageVolumeFieldError {
+	return StorageVolumeFieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// InvalidStorageVolumeError is returned when a storage volume fails validation.
+// Errs contains a StorageVolumeFieldError for every attribute that is not valid.
+type InvalidStorageVolumeError struct {
+	Errs []error
+}
+
+func (e InvalidStorageVolumeError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("the storage volume is not valid: %s", strings.Join(msgs, "; "))
+}
+
+// storageVolumeReclaimPolicies returns the reclaim policies of persistent volumes.
+// The Kubernetes API is case-sensitive.
+func storageVolumeReclaimPolicies() []string {
+	return []string{"Retain", "Recycle", "Delete"}
+}
+
+// storageVolumeAccessModes returns the access modes of persistent volumes.
+// The Kubernetes API is case-sensitive.
+func storageVolumeAccessModes() []string {
+	return []string{"ReadWriteOnce", "ReadOnlyMany", "ReadWriteMany"}
+}
+
 func (sv StorageVolume) validate() (bool, []error) {
 	v := newValidator()
 	notAllowed := ": / \\ & < > |"
-	if strings.ContainsAny(sv.Name, notAllowed) {
-		v.addError(fmt.Errorf("Volume name may not contain spaces or any of the following characters: %q", notAllowed))
+	if sv.Name == "" {
+		v.addError(storageVolumeFieldError("Name", "Volume name cannot be empty"))
+	} else if strings.ContainsAny(sv.Name, notAllowed) {
+		v.addError(storageVolumeFieldError("Name", "Volume name may not contain spaces or any of the following characters: %q", notAllowed))
+	} else if !storageClassNameRE.MatchString(sv.Name) {
+		// the volume name is also the name of the persistent volume
+		v.addError(storageVolumeFieldError("Name", "Volume name %q is not valid, must be a lowercase RFC 1123 subdomain", sv.Name))
 	}
 	if sv.SizeGB < 1 {
-		v.addError(errors.New("Volume size must be 1GB or larger"))
+		v.addError(storageVolumeFieldError("SizeGB", "Volume size must be 1GB or larger"))
 	}
 	if sv.DistributionCount < 1 {
-		v.addError(errors.New("Distribution count must be greater than zero"))
+		v.addError(storageVolumeFieldError("DistributionCount", "Distribution count must be greater than zero"))
 	}
 	if sv.ReplicateCount < 1 {
-		v.addError(errors.New("Replication count must be greater than zero"))
+		v.addError(storageVolumeFieldError("ReplicateCount", "Replication count must be greater than zero"))
+	}
+	if sv.StorageClass != "" && !storageClassNameRE.MatchString(sv.StorageClass) {
+		v.addError(storageVolumeFieldError("StorageClass", "StorageClass name %q is not valid, must be a lowercase RFC 1123 subdomain", sv.StorageClass))
 	}
 	for _, a := range sv.AllowAddresses {
 		if ok := validateAllowedAddress(a); !ok {
-			v.addError(fmt.Errorf("Invalid address %q in the list of allowed addresses", a))
+			v.addError(storageVolumeFieldError("AllowAddresses", "Invalid address %q in the list of allowed addresses", a))
 		}
 	}
-	reclaimPolicies := []string{"Retain", "Recycle", "Delete"} // API is case-sensitive
-	if !util.Contains(sv.ReclaimPolicy, reclaimPolicies) {
-		v.addError(fmt.Errorf("%q is not a valid reclaim policy. Valid reclaim policies are: %v", sv.ReclaimPolicy, reclaimPolicies))
+	if !util.Contains(sv.ReclaimPolicy, storageVolumeReclaimPolicies()) {
+		v.addError(storageVolumeFieldError("ReclaimPolicy", "%q is not a valid reclaim policy. Valid reclaim policies are: %v", sv.ReclaimPolicy, storageVolumeReclaimPolicies()))
 	}
 
 	if len(sv.AccessModes) < 1 {
-		v.addError(errors.New("Access mode was not provided"))
+		v.addError(storageVolumeFieldError("AccessModes", "Access mode was not provided"))
 	}
-
-	accessModes := []string{"ReadWriteOnce", "ReadOnlyMany", "ReadWriteMany"} // API is case-sensitive
+	seen := map[string]bool{}
 	for _, m := range sv.AccessModes {
-		if !util.Contains(m, accessModes) {
-			v.addError(fmt.Errorf("%q is not a valid access mode. Valid access modes are: %v", m, accessModes))
+		if !util.Contains(m, storageVolumeAccessModes()) {
+			v.addError(storageVolumeFieldError("AccessModes", "%q is not a valid access mode. Valid access modes are: %v", m, storageVolumeAccessModes()))
+		} else if seen[m] {
+			v.addError(storageVolumeFieldError("AccessModes", "Access mode %q was provided more than once", m))
 		}
+		seen[m] = true
 	}
 	return v.valid()
 }
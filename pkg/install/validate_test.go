@@ -2,6 +2,7 @@ package install
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -365,6 +366,34 @@ func TestValidatePlanNegativeSSHPort(t *testing.T) {
 	assertInvalidPlan(t, p)
 }
 
+func TestValidateSSHServiceAccount(t *testing.T) {
+	tests := []struct {
+		sa    SSHServiceAccount
+		valid bool
+	}{
+		{sa: SSHServiceAccount{User: "kismatic", SudoCommands: []string{"ALL"}}, valid: true},
+		{sa: SSHServiceAccount{User: "kismatic", SudoCommands: []string{"/bin/sh", "/usr/bin/python -c *"}}, valid: true},
+		{sa: SSHServiceAccount{User: "", SudoCommands: []string{"ALL"}}, valid: false},
+		{sa: SSHServiceAccount{User: "Kismatic", SudoCommands: []string{"ALL"}}, valid: false},
+		{sa: SSHServiceAccount{User: "root", SudoCommands: []string{"ALL"}}, valid: false},
+		{sa: SSHServiceAccount{User: "ubuntu", SudoCommands: []string{"ALL"}}, valid: false},
+		{sa: SSHServiceAccount{User: "kismatic"}, valid: false},
+		{sa: SSHServiceAccount{User: "kismatic", SudoCommands: []string{"sh"}}, valid: false},
+		{sa: SSHServiceAccount{User: "kismatic", SudoCommands: []string{""}}, valid: false},
+		{sa: SSHServiceAccount{User: "kismatic", SudoCommands: []string{"/bin/sh, ALL"}}, valid: false},
+	}
+	for i, test := range tests {
+		p := validPlan()
+		p.Cluster.SSH.User = "ubuntu"
+		sa := test.sa
+		p.Cluster.SSH.ServiceAccount = &sa
+		ok, errs := p.Cluster.SSH.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
 func TestValidatePlanEmptyLoadBalancedFQDN(t *testing.T) {
 	p := validPlan()
 	p.Master.LoadBalancedFQDN = ""
@@ -566,7 +595,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            0,
 				DistributionCount: 2,
 				ReplicateCount:    2,
@@ -577,7 +606,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            -1,
 				DistributionCount: 2,
 				ReplicateCount:    2,
@@ -588,7 +617,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: -1,
 				ReplicateCount:    2,
@@ -599,7 +628,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 2,
 				ReplicateCount:    -1,
@@ -614,7 +643,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -625,7 +654,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -636,7 +665,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -647,7 +676,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -658,7 +687,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -669,7 +698,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -680,7 +709,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -691,7 +720,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -702,7 +731,7 @@ func TestValidateStorageVolume(t *testing.T) {
 		},
 		{
 			sv: StorageVolume{
-				Name:              "goodName",
+				Name:              "good-name",
 				SizeGB:            100,
 				DistributionCount: 1,
 				ReplicateCount:    1,
@@ -711,6 +740,62 @@ func TestValidateStorageVolume(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			// the volume name is the name of the persistent volume
+			sv: StorageVolume{
+				Name:              "goodName",
+				SizeGB:            100,
+				DistributionCount: 1,
+				ReplicateCount:    1,
+				ReclaimPolicy:     "Delete",
+				AccessModes:       []string{"ReadWriteMany"},
+			},
+			valid: false,
+		},
+		{
+			sv: StorageVolume{
+				SizeGB:            100,
+				DistributionCount: 1,
+				ReplicateCount:    1,
+				ReclaimPolicy:     "Delete",
+				AccessModes:       []string{"ReadWriteMany"},
+			},
+			valid: false,
+		},
+		{
+			sv: StorageVolume{
+				Name:              "good-name",
+				SizeGB:            100,
+				DistributionCount: 1,
+				ReplicateCount:    1,
+				StorageClass:      "Bad_Class",
+				ReclaimPolicy:     "Delete",
+				AccessModes:       []string{"ReadWriteMany"},
+			},
+			valid: false,
+		},
+		{
+			sv: StorageVolume{
+				Name:              "good-name",
+				SizeGB:            100,
+				DistributionCount: 1,
+				ReplicateCount:    1,
+				ReclaimPolicy:     "Delete",
+				AccessModes:       []string{"ReadWriteMany", "ReadWriteMany"},
+			},
+			valid: false,
+		},
+		{
+			sv: StorageVolume{
+				Name:              "good-name",
+				SizeGB:            100,
+				DistributionCount: 1,
+				ReplicateCount:    1,
+				ReclaimPolicy:     "Delete",
+				AccessModes:       []string{"ReadWriteMany", ""},
+			},
+			valid: false,
+		},
 	}
 	for _, test := range tests {
 		if valid, _ := test.sv.validate(); valid != test.valid {
@@ -719,6 +804,29 @@ func TestValidateStorageVolume(t *testing.T) {
 	}
 }
 
+func TestValidateStorageVolumeFieldErrors(t *testing.T) {
+	sv := StorageVolume{
+		Name:              "good-name",
+		SizeGB:            100,
+		DistributionCount: 1,
+		ReplicateCount:    1,
+		ReclaimPolicy:     "retain",
+		AccessModes:       []string{"ReadWriteMany", "readwriteonce"},
+	}
+	_, errs := sv.validate()
+	var fields []string
+	for _, err := range errs {
+		fieldErr, ok := err.(StorageVolumeFieldError)
+		if !ok {
+			t.Fatalf("expected a StorageVolumeFieldError, got %T: %v", err, err)
+		}
+		fields = append(fields, fieldErr.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"ReclaimPolicy", "AccessModes"}) {
+		t.Errorf("unexpected fields with errors: %v", fields)
+	}
+}
+
 func TestValidateAllowAddress(t *testing.T) {
 	tests := []struct {
 		address string
@@ -1411,6 +1519,48 @@ func TestDNSProvider(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			d: DNS{
+				Provider: "coredns",
+				Options:  DNSOptions{UpstreamServers: []string{"10.0.0.2", "10.0.0.3:5353", "fd00::2"}, BlockExternal: true},
+			},
+			valid: true,
+		},
+		{
+			d: DNS{
+				Provider: "coredns",
+				Options:  DNSOptions{BlockExternal: true},
+			},
+			valid: true,
+		},
+		{
+			d: DNS{
+				Provider: "coredns",
+				Options:  DNSOptions{UpstreamServers: []string{"dns.internal"}},
+			},
+			valid: false,
+		},
+		{
+			d: DNS{
+				Provider: "coredns",
+				Options:  DNSOptions{UpstreamServers: []string{"10.0.0.2:0"}},
+			},
+			valid: false,
+		},
+		{
+			d: DNS{
+				Provider: "kubedns",
+				Options:  DNSOptions{UpstreamServers: []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}},
+			},
+			valid: false,
+		},
+		{
+			d: DNS{
+				Provider: "kubedns",
+				Options:  DNSOptions{UpstreamServers: []string{"10.0.0.2"}, BlockExternal: true},
+			},
+			valid: false,
+		},
 	}
 	for i, test := range tests {
 		ok, _ := test.d.validate()
@@ -1601,6 +1751,63 @@ func TestCloudProvider(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			c: CloudProvider{
+				Provider: "openstack",
+			},
+			valid: false,
+		},
+		{
+			c: CloudProvider{
+				Provider: "openstack",
+				Config:   "/bin/sh",
+			},
+			valid: false,
+		},
+		{
+			c: CloudProvider{
+				Provider: "vsphere",
+				VSphere:  validVSphere(),
+			},
+			valid: true,
+		},
+		{
+			c: CloudProvider{
+				Provider: "openstack",
+				VSphere:  validVSphere(),
+			},
+			valid: false,
+		},
+		{
+			c: CloudProvider{
+				Provider: "vsphere",
+				Config:   "/bin/sh",
+				VSphere:  validVSphere(),
+			},
+			valid: false,
+		},
+		{
+			c: CloudProvider{
+				Provider: "azure",
+				Azure:    validAzure(),
+			},
+			valid: true,
+		},
+		{
+			c: CloudProvider{
+				Provider: "aws",
+				Azure:    validAzure(),
+			},
+			valid: false,
+		},
+		{
+			c: CloudProvider{
+				Provider: "azure",
+				Config:   "/bin/sh",
+				Azure:    validAzure(),
+			},
+			valid: false,
+		},
 	}
 	for i, test := range tests {
 		ok, _ := test.c.validate()
@@ -1610,6 +1817,99 @@ func TestCloudProvider(t *testing.T) {
 	}
 }
 
+func TestArtifacts(t *testing.T) {
+	checksum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	tests := []struct {
+		a     Artifacts
+		valid bool
+	}{
+		{
+			a:     Artifacts{},
+			valid: true,
+		},
+		{
+			a:     Artifacts{Inspector: Artifact{Source: "/bin/sh"}},
+			valid: true,
+		},
+		{
+			a:     Artifacts{Inspector: Artifact{Source: "/bin/foo"}},
+			valid: false,
+		},
+		{
+			a:     Artifacts{Kuberang: Artifact{Source: "https://mirror.local/kuberang", SHA256: checksum}},
+			valid: true,
+		},
+		{
+			a:     Artifacts{Kuberang: Artifact{Source: "http://mirror.local/kuberang"}},
+			valid: true,
+		},
+		{
+			a:     Artifacts{Kuberang: Artifact{Source: "ftp://mirror.local/kuberang"}},
+			valid: false,
+		},
+		{
+			a:     Artifacts{Kuberang: Artifact{Source: "https://mirror.local/kuberang", SHA256: "abc"}},
+			valid: false,
+		},
+		{
+			a:     Artifacts{Inspector: Artifact{SHA256: checksum}},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		ok, _ := test.a.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expect %t, but got %t", i, test.valid, ok)
+		}
+	}
+}
+
+func TestParallelism(t *testing.T) {
+	tests := []struct {
+		p     Parallelism
+		valid bool
+	}{
+		{
+			p:     Parallelism{},
+			valid: true,
+		},
+		{
+			p:     Parallelism{Forks: 200, Serial: "25%", PlaySerial: map[string]string{"_docker.yaml": "10"}},
+			valid: true,
+		},
+		{
+			p:     Parallelism{Forks: -1},
+			valid: false,
+		},
+		{
+			p:     Parallelism{Serial: "0"},
+			valid: false,
+		},
+		{
+			p:     Parallelism{Serial: "150%"},
+			valid: false,
+		},
+		{
+			p:     Parallelism{Serial: "all"},
+			valid: false,
+		},
+		{
+			p:     Parallelism{PlaySerial: map[string]string{"docker": "10"}},
+			valid: false,
+		},
+		{
+			p:     Parallelism{PlaySerial: map[string]string{"_docker.yaml": ""}},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		ok, _ := test.p.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expect %t, but got %t", i, test.valid, ok)
+		}
+	}
+}
+
 func TestNodeLabels(t *testing.T) {
 	tests := []struct {
 		n     Node
@@ -2203,3 +2503,375 @@ func TestValidateFile(t *testing.T) {
 		}
 	}
 }
+
+func validVSphere() *VSphereCloudProvider {
+	return &VSphereCloudProvider{
+		Server:       "vcenter",
+		Port:         443,
+		Username:     "administrator",
+		Password:     "secret",
+		Datacenter:   "dc1",
+		Datastore:    "datastore1",
+		Folder:       "kubernetes",
+		StorageClass: "vsphere",
+		DiskFormat:   "thin",
+	}
+}
+
+func TestVSphereCloudProvider(t *testing.T) {
+	tests := []struct {
+		modify func(*VSphereCloudProvider)
+		valid  bool
+	}{
+		{modify: func(*VSphereCloudProvider) {}, valid: true},
+		{modify: func(vs *VSphereCloudProvider) { vs.Server = "" }, valid: false},
+		{modify: func(vs *VSphereCloudProvider) { vs.Password = "" }, valid: false},
+		{modify: func(vs *VSphereCloudProvider) { vs.Folder = "" }, valid: false},
+		{modify: func(vs *VSphereCloudProvider) { vs.Port = 70000 }, valid: false},
+		{modify: func(vs *VSphereCloudProvider) { vs.DiskFormat = "thick" }, valid: false},
+		{modify: func(vs *VSphereCloudProvider) { vs.StorageClass = "" }, valid: false},
+	}
+	for i, test := range tests {
+		vs := validVSphere()
+		test.modify(vs)
+		ok, errs := vs.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func validAzure() *AzureCloudProvider {
+	return &AzureCloudProvider{
+		Cloud:             "AzurePublicCloud",
+		TenantID:          "tenant",
+		SubscriptionID:    "subscription",
+		ClientID:          "client",
+		ClientSecret:      "secret",
+		ResourceGroup:     "kubernetes",
+		Location:          "eastus",
+		VNetName:          "vnet",
+		SubnetName:        "subnet",
+		SecurityGroupName: "nsg",
+		VMType:            "standard",
+		LoadBalancerSKU:   "basic",
+	}
+}
+
+func TestAzureCloudProvider(t *testing.T) {
+	tests := []struct {
+		modify func(*AzureCloudProvider)
+		valid  bool
+	}{
+		{modify: func(*AzureCloudProvider) {}, valid: true},
+		{modify: func(a *AzureCloudProvider) { a.Cloud = "Moon" }, valid: false},
+		{modify: func(a *AzureCloudProvider) { a.ClientSecret = "" }, valid: false},
+		{modify: func(a *AzureCloudProvider) { a.ClientID, a.ClientSecret, a.UseManagedIdentity = "", "", true }, valid: true},
+		{modify: func(a *AzureCloudProvider) { a.SubnetName = "" }, valid: false},
+		{modify: func(a *AzureCloudProvider) { a.VMType = "vmss" }, valid: false},
+		{modify: func(a *AzureCloudProvider) { a.VMType, a.PrimaryScaleSetName = "vmss", "workers" }, valid: true},
+		{modify: func(a *AzureCloudProvider) { a.LoadBalancerSKU = "premium" }, valid: false},
+		{modify: func(a *AzureCloudProvider) { a.MasterBackendPool = &AzureBackendPool{LoadBalancer: "lb", Name: "masters"} }, valid: true},
+		{modify: func(a *AzureCloudProvider) { a.MasterBackendPool = &AzureBackendPool{LoadBalancer: "lb"} }, valid: false},
+		{
+			modify: func(a *AzureCloudProvider) {
+				a.VMType, a.PrimaryScaleSetName = "vmss", "workers"
+				a.MasterBackendPool = &AzureBackendPool{LoadBalancer: "lb", Name: "masters"}
+			},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		a := validAzure()
+		test.modify(a)
+		ok, errs := a.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func validLocalVolumeProvisioner() *LocalVolumeProvisioner {
+	return &LocalVolumeProvisioner{
+		StorageClasses: []LocalStorageClass{
+			{Name: "local-ssd", HostDir: "/mnt/local-storage/local-ssd", VolumeMode: "Filesystem", ReclaimPolicy: "Delete", Default: true},
+			{Name: "local-nvme", HostDir: "/mnt/local-storage/local-nvme", VolumeMode: "Block", ReclaimPolicy: "Retain"},
+		},
+	}
+}
+
+func TestLocalVolumeProvisionerAddOn(t *testing.T) {
+	tests := []struct {
+		modify func(*LocalVolumeProvisioner)
+		valid  bool
+	}{
+		{modify: func(*LocalVolumeProvisioner) {}, valid: true},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses = nil }, valid: false},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses, l.Disable = nil, true }, valid: true},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses[1].Name = "Local_SSD" }, valid: false},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses[1].Name = "local-ssd" }, valid: false},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses[1].HostDir = "mnt/disks" }, valid: false},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses[1].HostDir = "/mnt/local-storage/local-ssd/" }, valid: false},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses[1].VolumeMode = "Raw" }, valid: false},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses[1].ReclaimPolicy = "Recycle" }, valid: false},
+		{modify: func(l *LocalVolumeProvisioner) { l.StorageClasses[1].Default = true }, valid: false},
+	}
+	for i, test := range tests {
+		l := validLocalVolumeProvisioner()
+		test.modify(l)
+		ok, errs := l.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func TestValidatePlanLocalVolumes(t *testing.T) {
+	tests := []struct {
+		modify func(*Plan)
+		valid  bool
+	}{
+		{
+			modify: func(p *Plan) {
+				p.Worker.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-ssd", Path: "/mnt/disks/ssd0"}, {StorageClass: "local-nvme", Path: "/dev/nvme0n1"}}
+			},
+			valid: true,
+		},
+		{
+			// the provisioner is not enabled
+			modify: func(p *Plan) {
+				p.AddOns.LocalVolumeProvisioner.Disable = true
+				p.Worker.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-ssd", Path: "/mnt/disks/ssd0"}}
+			},
+			valid: false,
+		},
+		{
+			modify: func(p *Plan) {
+				p.Worker.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-hdd", Path: "/mnt/disks/hdd0"}}
+			},
+			valid: false,
+		},
+		{
+			modify: func(p *Plan) {
+				p.Worker.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-ssd", Path: "mnt/disks/ssd0"}}
+			},
+			valid: false,
+		},
+		{
+			modify: func(p *Plan) {
+				p.Worker.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-ssd", Path: "/mnt/disks/ssd0"}, {StorageClass: "local-ssd", Path: "/mnt/disks/ssd0/"}}
+			},
+			valid: false,
+		},
+		{
+			// block device in a filesystem StorageClass
+			modify: func(p *Plan) {
+				p.Worker.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-ssd", Path: "/dev/sdb"}}
+			},
+			valid: false,
+		},
+		{
+			// directory in a block StorageClass
+			modify: func(p *Plan) {
+				p.Worker.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-nvme", Path: "/mnt/disks/nvme0"}}
+			},
+			valid: false,
+		},
+		{
+			// etcd nodes are not Kubernetes nodes
+			modify: func(p *Plan) {
+				p.Ingress = OptionalNodeGroup{}
+				p.Etcd.Nodes[0].LocalVolumes = []LocalVolume{{StorageClass: "local-ssd", Path: "/mnt/disks/ssd0"}}
+			},
+			valid: false,
+		},
+		{
+			// the vsphere StorageClass is the default
+			modify: func(p *Plan) {
+				p.Cluster.CloudProvider = CloudProvider{Provider: "vsphere", VSphere: validVSphere()}
+			},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		p := validPlan()
+		p.AddOns.LocalVolumeProvisioner = validLocalVolumeProvisioner()
+		test.modify(&p)
+		ok, errs := (&localVolumeGroup{Plan: &p}).validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func TestValidateLocalVolumesDefinedOnce(t *testing.T) {
+	volumes := []LocalVolume{{StorageClass: "local-ssd", Path: "/mnt/disks/ssd0"}}
+	nodes := []Node{
+		{Host: "node1", IP: "10.0.0.1", LocalVolumes: volumes},
+		{Host: "node1", IP: "10.0.0.1", LocalVolumes: volumes},
+	}
+	if errs := validateLocalVolumesDefinedOnce(nodes); len(errs) != 0 {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+	nodes[1].LocalVolumes = nil
+	if errs := validateLocalVolumesDefinedOnce(nodes); len(errs) != 1 {
+		t.Errorf("expected an error, got %v", errs)
+	}
+}
+
+func TestHeketiAddOn(t *testing.T) {
+	tests := []struct {
+		modify func(*Heketi)
+		valid  bool
+	}{
+		{modify: func(*Heketi) {}, valid: true},
+		{modify: func(h *Heketi) { h.URL = "https://heketi.example.com" }, valid: true},
+		{modify: func(h *Heketi) { h.URL = "" }, valid: false},
+		{modify: func(h *Heketi) { h.URL = "heketi:8080" }, valid: false},
+		{modify: func(h *Heketi) { h.URL, h.Disable = "", true }, valid: true},
+		{modify: func(h *Heketi) { h.StorageClass = "" }, valid: true},
+		{modify: func(h *Heketi) { h.StorageClass = "Gluster_FS" }, valid: false},
+		{modify: func(h *Heketi) { h.StorageClass, h.DefaultStorageClass = "", true }, valid: false},
+	}
+	for i, test := range tests {
+		h := &Heketi{URL: "http://heketi:8080", User: "admin", StorageClass: "glusterfs"}
+		test.modify(h)
+		ok, errs := h.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func TestValidatePlanHeketi(t *testing.T) {
+	tests := []struct {
+		modify func(*Plan)
+		valid  bool
+	}{
+		{modify: func(p *Plan) {}, valid: true},
+		{
+			// kismatic-managed storage nodes
+			modify: func(p *Plan) {
+				p.Storage = OptionalNodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "storage", IP: "10.0.0.10"}}}
+			},
+			valid: false,
+		},
+		{
+			modify: func(p *Plan) {
+				p.Storage = OptionalNodeGroup{ExpectedCount: 1, Nodes: []Node{{Host: "storage", IP: "10.0.0.10"}}}
+				p.AddOns.Heketi.Disable = true
+			},
+			valid: true,
+		},
+		{
+			modify: func(p *Plan) {
+				p.AddOns.Heketi.DefaultStorageClass = true
+				p.AddOns.LocalVolumeProvisioner = validLocalVolumeProvisioner()
+				p.AddOns.LocalVolumeProvisioner.StorageClasses[0].Default = true
+			},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		p := validPlan()
+		p.AddOns.Heketi = &Heketi{URL: "http://heketi:8080", User: "admin", StorageClass: "glusterfs"}
+		test.modify(&p)
+		ok, errs := (&heketiGroup{Plan: &p}).validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func TestValidateTenants(t *testing.T) {
+	validTenant := func() Tenant {
+		return Tenant{
+			Namespace:  "team-a",
+			Quota:      map[string]string{"requests.cpu": "4", "limits.memory": "8Gi", "pods": "20"},
+			LimitRange: &TenantLimitRange{Default: map[string]string{"cpu": "500m"}, Max: map[string]string{"memory": "1.5Gi"}},
+			RoleBindings: []TenantRoleBinding{
+				{ClusterRole: "admin", Groups: []string{"team-a"}},
+				{ClusterRole: "view", ServiceAccounts: []string{"monitoring:prometheus", "deployer"}},
+			},
+		}
+	}
+	tests := []struct {
+		modify func(*Tenant)
+		valid  bool
+	}{
+		{modify: func(t *Tenant) {}, valid: true},
+		{modify: func(t *Tenant) { t.Namespace = "" }, valid: false},
+		{modify: func(t *Tenant) { t.Namespace = "Team-A" }, valid: false},
+		{modify: func(t *Tenant) { t.Namespace = "team.a" }, valid: false},
+		{modify: func(t *Tenant) { t.Namespace = "a123456789a123456789a123456789a123456789a123456789a123456789abcd" }, valid: false},
+		{modify: func(t *Tenant) { t.Namespace = "kube-system" }, valid: false},
+		{modify: func(t *Tenant) { t.Quota["pods"] = "twenty" }, valid: false},
+		{modify: func(t *Tenant) { t.Quota[""] = "1" }, valid: false},
+		{modify: func(t *Tenant) { t.LimitRange.Min = map[string]string{"cpu": "-1"} }, valid: false},
+		{modify: func(t *Tenant) { t.LimitRange = nil }, valid: true},
+		{modify: func(t *Tenant) { t.RoleBindings[0].ClusterRole = "" }, valid: false},
+		{modify: func(t *Tenant) { t.RoleBindings[1].ClusterRole = "admin" }, valid: false},
+		{modify: func(t *Tenant) { t.RoleBindings[0].Groups = nil }, valid: false},
+		{modify: func(t *Tenant) { t.RoleBindings[1].ServiceAccounts = []string{"monitoring:"} }, valid: false},
+		{modify: func(t *Tenant) { t.RoleBindings[1].ServiceAccounts = []string{"Monitoring:prometheus"} }, valid: false},
+	}
+	for i, test := range tests {
+		tenant := validTenant()
+		test.modify(&tenant)
+		ok, errs := tenant.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func TestValidateTenantsDefinedOnce(t *testing.T) {
+	tg := tenantGroup{Tenants: []Tenant{{Namespace: "team-a"}, {Namespace: "team-b"}, {Namespace: "team-a"}}}
+	ok, errs := tg.validate()
+	if ok {
+		t.Fatal("expected duplicate tenant namespaces to be invalid")
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected 1 error, got %v", errs)
+	}
+}
+
+func TestValidateImageCredentialProviders(t *testing.T) {
+	validProvider := func() ImageCredentialProvider {
+		return ImageCredentialProvider{
+			Type:            "ecr",
+			Registries:      []string{"123456789012.dkr.ecr.us-east-1.amazonaws.com"},
+			RefreshInterval: "30m",
+		}
+	}
+	tests := []struct {
+		modify func(*ImageCredentialProvider)
+		valid  bool
+	}{
+		{modify: func(p *ImageCredentialProvider) {}, valid: true},
+		{modify: func(p *ImageCredentialProvider) { p.Type = "gcr"; p.Registries = []string{"gcr.io", "us.gcr.io"} }, valid: true},
+		{modify: func(p *ImageCredentialProvider) { p.Type = "quay" }, valid: false},
+		{modify: func(p *ImageCredentialProvider) { p.Registries = nil }, valid: false},
+		{modify: func(p *ImageCredentialProvider) { p.Registries = []string{"https://gcr.io"} }, valid: false},
+		{modify: func(p *ImageCredentialProvider) { p.Registries = []string{""} }, valid: false},
+		{modify: func(p *ImageCredentialProvider) { p.RefreshInterval = "30" }, valid: false},
+		{modify: func(p *ImageCredentialProvider) { p.RefreshInterval = "30s" }, valid: false},
+		{modify: func(p *ImageCredentialProvider) { p.Helper = Artifact{Source: "https://example.com/docker-credential-ecr-login"} }, valid: true},
+		{modify: func(p *ImageCredentialProvider) { p.Helper = Artifact{SHA256: "abc"} }, valid: false},
+	}
+	for i, test := range tests {
+		p := validProvider()
+		test.modify(&p)
+		ok, errs := p.validate()
+		if ok != test.valid {
+			t.Errorf("test %d: expected %v, got %v: %v", i, test.valid, ok, errs)
+		}
+	}
+}
+
+func TestValidateImageCredentialProvidersDefinedOnce(t *testing.T) {
+	p := validPlan()
+	provider := ImageCredentialProvider{Type: "gcr", Registries: []string{"gcr.io"}, RefreshInterval: "30m"}
+	p.Cluster.ImageCredentialProviders = []ImageCredentialProvider{provider, provider}
+	assertInvalidPlan(t, p)
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"regexp"
 	"strings"
 	"time"
 
@@ -13,19 +12,17 @@ import (
 
 var (
 	httpTimeout                  = 5 * time.Second
-	kubeReleaseRegex             = regexp.MustCompile(`^v1\.10\.(0|[1-9][0-9]*)$`)
 	kubernetesReleaseURL         = "https://storage.googleapis.com/kubernetes-release/release/stable-1.10.txt"
 	kubernetesVersionString      = "v1.10.3"
 	kubernetesMinorVersionString = "v1.10.x"
 	kubernetesVersion            = semver.Version{Major: 1, Minor: 10, Patch: 3} // build the struct directly to not get an error
+	// supportedKubernetesVersions are the Kubernetes versions that can be installed
+	supportedKubernetesVersions = semver.MustParseRange(">=1.10.0 <1.11.0")
 )
 
 func parseVersion(versionString string) (semver.Version, error) {
 	// Support a 'v' prefix
-	verString := versionString
-	if versionString[0] == 'v' {
-		verString = versionString[1:len(versionString)]
-	}
+	verString := strings.TrimPrefix(strings.TrimSpace(versionString), "v")
 	v, err := semver.Make(verString)
 	if err != nil {
 		return semver.Version{}, fmt.Errorf("Unable to parse version %q: %v", verString, err)
@@ -33,6 +30,32 @@ func parseVersion(versionString string) (semver.Version, error) {
 	return v, nil
 }
 
+// parseKubernetesVersion parses a Kubernetes version of the plan, which must
+// start with "v", and be a release supported by this version of KET
+func parseKubernetesVersion(version string) (semver.Version, error) {
+	if !strings.HasPrefix(version, "v") {
+		return semver.Version{}, fmt.Errorf("must start with \"v\", ie %q", kubernetesVersionString)
+	}
+	v, err := semver.Make(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("must be a valid semantic version, ie %q: %v", kubernetesVersionString, err)
+	}
+	if len(v.Pre) > 0 || len(v.Build) > 0 {
+		return semver.Version{}, fmt.Errorf("must be a release, without pre-release or build metadata, ie %q", kubernetesVersionString)
+	}
+	if !supportedKubernetesVersions(v) {
+		return semver.Version{}, fmt.Errorf("must be a %q version, ie %q", kubernetesMinorVersionString, kubernetesVersionString)
+	}
+	return v, nil
+}
+
+// kubernetesPackageVersions returns the versions of the Kubernetes packages
+// of the release, for yum and apt
+func kubernetesPackageVersions(v semver.Version) (yum string, deb string) {
+	release := semver.Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}.String()
+	return release + "-0", release + "-00"
+}
+
 // kubernetesStableVersion fetches the latest stable version
 // if an error occurs it will return the tested version
 func kubernetesLatestStableVersion() (semver.Version, string, error) {
@@ -53,19 +76,17 @@ func kubernetesLatestStableVersion() (semver.Version, string, error) {
 		return kubernetesVersion, kubernetesVersionString, fmt.Errorf("Error reading response %v", err)
 	}
 	latest := strings.Trim(string(body), " \t\n")
-	if !kubeReleaseRegex.MatchString(latest) {
-		return kubernetesVersion, kubernetesVersionString, fmt.Errorf("Invalid version format %q", latest)
-	}
-	parsedLatest, err := parseVersion(latest)
+	parsedLatest, err := parseKubernetesVersion(latest)
 	if err != nil {
-		return kubernetesVersion, kubernetesVersionString, fmt.Errorf("Could not parse version string %q", latest)
+		return kubernetesVersion, kubernetesVersionString, fmt.Errorf("Invalid version %q: %v", latest, err)
 	}
 	return parsedLatest, latest, nil
 }
 
 // validates that the version is the expected Minor version
 func kubernetesVersionValid(version string) bool {
-	return kubeReleaseRegex.MatchString(version)
+	_, err := parseKubernetesVersion(version)
+	return err == nil
 }
 
 // VersionOverrides returns a map of all image names and their versions that can be modified by the user
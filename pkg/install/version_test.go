@@ -0,0 +1,40 @@
+package install
+
+import "testing"
+
+func TestParseKubernetesVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		valid   bool
+		yum     string
+		deb     string
+	}{
+		{version: "v1.10.3", valid: true, yum: "1.10.3-0", deb: "1.10.3-00"},
+		{version: "v1.10.0", valid: true, yum: "1.10.0-0", deb: "1.10.0-00"},
+		{version: "v1.10.12", valid: true, yum: "1.10.12-0", deb: "1.10.12-00"},
+		{version: "1.10.3"},
+		{version: ""},
+		{version: "v"},
+		{version: "v1.10"},
+		{version: "v1.10.03"},
+		{version: "v1.10.3-beta.1"},
+		{version: "v1.10.3+build"},
+		{version: "v1.9.8"},
+		{version: "v1.11.0"},
+		{version: "v2.10.3"},
+	}
+	for _, test := range tests {
+		v, err := parseKubernetesVersion(test.version)
+		if test.valid != (err == nil) {
+			t.Errorf("%q: expected valid to be %v, but got error %v", test.version, test.valid, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		yum, deb := kubernetesPackageVersions(v)
+		if yum != test.yum || deb != test.deb {
+			t.Errorf("%q: expected package versions %q and %q, but got %q and %q", test.version, test.yum, test.deb, yum, deb)
+		}
+	}
+}
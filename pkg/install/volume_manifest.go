@@ -0,0 +1,108 @@
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Default values of the storage volumes declared in a volume manifest.
+// These match the defaults of the volume add command.
+const (
+	DefaultVolumeReplicaCount      = 2
+	DefaultVolumeDistributionCount = 1
+	DefaultVolumeStorageClass      = "kismatic"
+	DefaultVolumeReclaimPolicy     = "Retain"
+	DefaultVolumeAccessMode        = "ReadWriteMany"
+)
+
+// VolumeManifest declares the storage volumes that should exist on the cluster
+type VolumeManifest struct {
+	Volumes []VolumeSpec `yaml:"volumes"`
+}
+
+// VolumeSpec is the declaration of a storage volume in a volume manifest.
+// Unset fields take the default value used by the volume add command.
+type VolumeSpec struct {
+	// Name of the storage volume, and of the persistent volume
+	Name string `yaml:"name"`
+	// Size of the volume, in gigabytes
+	SizeGB int `yaml:"size_gb"`
+	// The number of times each file will be written
+	ReplicaCount int `yaml:"replica_count,omitempty"`
+	// The degree to which data will be distributed across the cluster
+	DistributionCount int `yaml:"distribution_count,omitempty"`
+	// The StorageClass to present for claims in Kubernetes
+	StorageClass string `yaml:"storage_class,omitempty"`
+	// Address wildcards permitted access to the volume in addition to Kubernetes nodes
+	AllowAddresses []string `yaml:"allow_addresses,omitempty"`
+	// The persistent volume reclaim policy
+	ReclaimPolicy string `yaml:"reclaim_policy,omitempty"`
+	// The access modes of the persistent volume
+	AccessModes []string `yaml:"access_modes,omitempty"`
+}
+
+// ReadVolumeManifest reads the volume manifest from the file system
+func ReadVolumeManifest(file string) (*VolumeManifest, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %v", err)
+	}
+	m := &VolumeManifest{}
+	if err = yaml.Unmarshal(d, m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal volume manifest: %v", err)
+	}
+	return m, nil
+}
+
+// StorageVolumes returns the storage volumes declared in the manifest, with
+// the default values set. An error is returned if a volume does not have a
+// name, or if more than one volume has the same name.
+func (m VolumeManifest) StorageVolumes() ([]StorageVolume, error) {
+	if len(m.Volumes) == 0 {
+		return nil, fmt.Errorf("the volume manifest does not declare any volumes")
+	}
+	seen := map[string]bool{}
+	var volumes []StorageVolume
+	for i, spec := range m.Volumes {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("the volume at position %d does not have a name", i+1)
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("the volume %q is declared more than once", spec.Name)
+		}
+		seen[spec.Name] = true
+		volumes = append(volumes, spec.storageVolume())
+	}
+	return volumes, nil
+}
+
+func (spec VolumeSpec) storageVolume() StorageVolume {
+	sv := StorageVolume{
+		Name:              spec.Name,
+		SizeGB:            spec.SizeGB,
+		ReplicateCount:    spec.ReplicaCount,
+		DistributionCount: spec.DistributionCount,
+		StorageClass:      spec.StorageClass,
+		AllowAddresses:    spec.AllowAddresses,
+		ReclaimPolicy:     spec.ReclaimPolicy,
+		AccessModes:       spec.AccessModes,
+	}
+	if sv.ReplicateCount == 0 {
+		sv.ReplicateCount = DefaultVolumeReplicaCount
+	}
+	if sv.DistributionCount == 0 {
+		sv.DistributionCount = DefaultVolumeDistributionCount
+	}
+	if sv.StorageClass == "" {
+		sv.StorageClass = DefaultVolumeStorageClass
+	}
+	if sv.ReclaimPolicy == "" {
+		sv.ReclaimPolicy = DefaultVolumeReclaimPolicy
+	}
+	if len(sv.AccessModes) == 0 {
+		sv.AccessModes = []string{DefaultVolumeAccessMode}
+	}
+	return sv
+}
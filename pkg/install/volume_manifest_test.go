@@ -0,0 +1,80 @@
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestReadVolumeManifest(t *testing.T) {
+	f, err := ioutil.TempFile("", "volumes")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	manifest := `volumes:
+- name: storage01
+  size_gb: 10
+- name: storage02
+  size_gb: 20
+  replica_count: 3
+  distribution_count: 2
+  storage_class: durable
+  allow_addresses:
+  - 10.10.*.*
+  reclaim_policy: Delete
+  access_modes:
+  - ReadWriteOnce
+`
+	if _, err := f.WriteString(manifest); err != nil {
+		t.Fatalf("error writing manifest: %v", err)
+	}
+	f.Close()
+
+	m, err := ReadVolumeManifest(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	volumes, err := m.StorageVolumes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []StorageVolume{
+		{
+			Name:              "storage01",
+			SizeGB:            10,
+			ReplicateCount:    2,
+			DistributionCount: 1,
+			StorageClass:      "kismatic",
+			ReclaimPolicy:     "Retain",
+			AccessModes:       []string{"ReadWriteMany"},
+		},
+		{
+			Name:              "storage02",
+			SizeGB:            20,
+			ReplicateCount:    3,
+			DistributionCount: 2,
+			StorageClass:      "durable",
+			AllowAddresses:    []string{"10.10.*.*"},
+			ReclaimPolicy:     "Delete",
+			AccessModes:       []string{"ReadWriteOnce"},
+		},
+	}
+	if !reflect.DeepEqual(volumes, expected) {
+		t.Errorf("expected %+v, got %+v", expected, volumes)
+	}
+}
+
+func TestVolumeManifestStorageVolumesInvalid(t *testing.T) {
+	tests := []VolumeManifest{
+		{},
+		{Volumes: []VolumeSpec{{SizeGB: 10}}},
+		{Volumes: []VolumeSpec{{Name: "storage01", SizeGB: 10}, {Name: "storage01", SizeGB: 20}}},
+	}
+	for i, m := range tests {
+		if _, err := m.StorageVolumes(); err == nil {
+			t.Errorf("test %d: expected an error", i)
+		}
+	}
+}
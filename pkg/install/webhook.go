@@ -0,0 +1,112 @@
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+	"github.com/apprenda/kismatic/pkg/util"
+)
+
+// The events of the lifecycle of the tasks sent to the notification webhook
+const (
+	TaskStarted   = "started"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+)
+
+// TaskNotification is the JSON payload posted to the notification webhook
+// when a task starts, succeeds or fails
+type TaskNotification struct {
+	// Event is started, succeeded or failed
+	Event    string    `json:"event"`
+	Task     string    `json:"task"`
+	Playbook string    `json:"playbook"`
+	Cluster  string    `json:"cluster"`
+	Time     time.Time `json:"time"`
+	// DurationSeconds is the duration of the task, once it ended
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+	// Error is the reason the task failed
+	Error string `json:"error,omitempty"`
+	// FailedHosts are the hosts on which the task failed
+	FailedHosts []string `json:"failedHosts,omitempty"`
+}
+
+func validateNotificationWebhook(webhook string) error {
+	if webhook == "" {
+		return nil
+	}
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return fmt.Errorf("invalid notification webhook %q: %v", webhook, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("notification webhook %q is not supported, the URL must be http or https", webhook)
+	}
+	return nil
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n webhookNotifier) notify(notification TaskNotification) error {
+	b, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("error marshaling notification: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("error creating notification request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("error sending notification: got status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// notifyTask posts the event of the task to the notification webhook, if
+// any. Dry runs are not notified. The operation goes on when the notification
+// cannot be sent.
+func (ae *ansibleExecutor) notifyTask(t task, event string, start time.Time, summary RunSummary, taskErr error) {
+	if ae.options.NotificationWebhook == "" || ae.options.DryRun {
+		return
+	}
+	n := TaskNotification{
+		Event:    event,
+		Task:     t.name,
+		Playbook: t.playbook,
+		Cluster:  t.plan.Cluster.Name,
+		Time:     time.Now(),
+	}
+	if event != TaskStarted {
+		n.DurationSeconds = n.Time.Sub(start).Seconds()
+		n.FailedHosts = summary.FailedHosts
+	}
+	if taskErr != nil {
+		n.Error = taskErr.Error()
+	}
+	notifier := webhookNotifier{url: ae.options.NotificationWebhook, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := notifier.notify(n); err != nil {
+		logging.Warn("error sending task notification", "task", t.name, "event", event, "error", err)
+		out := ae.stdout
+		if t.out != nil {
+			out = t.out
+		}
+		util.PrettyPrintWarn(out, "Could not send the %s notification of %q: %v", event, t.name, err)
+	}
+}
@@ -0,0 +1,103 @@
+package install
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type notificationRecorder struct {
+	mu            sync.Mutex
+	status        int
+	notifications []TaskNotification
+}
+
+func (r *notificationRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := TaskNotification{}
+	if err := json.NewDecoder(req.Body).Decode(&n); err == nil {
+		r.notifications = append(r.notifications, n)
+	}
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+}
+
+func TestExecuteNotifiesWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		events   []string
+	}{
+		{
+			name:   "succeeded",
+			events: []string{TaskStarted, TaskSucceeded},
+		},
+		{
+			name:     "failed",
+			failures: 1,
+			events:   []string{TaskStarted, TaskFailed},
+		},
+	}
+	for _, test := range tests {
+		recorder := &notificationRecorder{}
+		server := httptest.NewServer(recorder)
+		e := retryExecutor(t, &retryRunner{failing: []string{"worker2"}, failures: test.failures}, RetryPolicy{})
+		e.options.NotificationWebhook = server.URL
+		e.execute(hookTestTask(e))
+		server.Close()
+
+		var events []string
+		for _, n := range recorder.notifications {
+			events = append(events, n.Event)
+			if n.Task != "apply" || n.Playbook != "kubernetes.yaml" || n.Cluster != "prod" {
+				t.Errorf("%s: unexpected notification %+v", test.name, n)
+			}
+		}
+		if !reflect.DeepEqual(events, test.events) {
+			t.Errorf("%s: expected the events %v, got %v", test.name, test.events, events)
+			continue
+		}
+		end := recorder.notifications[1]
+		if test.failures > 0 && (end.Error == "" || !reflect.DeepEqual(end.FailedHosts, []string{"worker2"})) {
+			t.Errorf("%s: expected the error and the failed hosts, got %+v", test.name, end)
+		}
+		if test.failures == 0 && end.Error != "" {
+			t.Errorf("%s: unexpected error in %+v", test.name, end)
+		}
+	}
+}
+
+func TestExecuteIgnoresWebhookErrors(t *testing.T) {
+	recorder := &notificationRecorder{status: http.StatusInternalServerError}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+	e := retryExecutor(t, &retryRunner{}, RetryPolicy{})
+	e.options.NotificationWebhook = server.URL
+	if _, err := e.execute(hookTestTask(e)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateNotificationWebhook(t *testing.T) {
+	tests := map[string]bool{
+		"":                            true,
+		"https://hooks.example.com/x": true,
+		"http://10.0.0.1:8080/hook":   true,
+		"ftp://hooks.example.com":     false,
+		"hooks.example.com":           false,
+	}
+	for webhook, valid := range tests {
+		err := validateNotificationWebhook(webhook)
+		if valid && err != nil {
+			t.Errorf("%q: unexpected error: %v", webhook, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("%q: expected an error", webhook)
+		}
+	}
+}
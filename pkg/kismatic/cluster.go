@@ -0,0 +1,328 @@
+package kismatic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/install/explain"
+)
+
+// Progress is the progress of a step of an operation
+type Progress = explain.Progress
+
+// ResetOptions are the options of the reset of a cluster
+type ResetOptions = install.ResetOptions
+
+// RetryPolicy configures how the steps that fail on some nodes are retried
+type RetryPolicy = install.RetryPolicy
+
+// Hook is a local command or an extra playbook run before or after a step of
+// the operations
+type Hook = install.Hook
+
+// RunSummary is the outcome of the tasks run on the nodes by an operation
+type RunSummary = install.RunSummary
+
+// HostSummary counts the results of the tasks run on a node
+type HostSummary = install.HostSummary
+
+// Options configure the operations on a cluster
+type Options struct {
+	// GeneratedAssetsDirectory is where the certificates and the kubeconfig
+	// of the cluster are stored. Defaults to "generated".
+	GeneratedAssetsDirectory string
+	// RunsDirectory is where the information about each operation is kept.
+	// Defaults to "runs".
+	RunsDirectory string
+	// Out receives the console output of the operations. The output is
+	// discarded when nil.
+	Out io.Writer
+	// OutputFormat is the format of the console output. Defaults to "simple".
+	OutputFormat string
+	// Verbose enables the verbose output of the operations
+	Verbose bool
+	// Timeout is the maximum duration of each step of an operation. No
+	// timeout is enforced when zero.
+	Timeout time.Duration
+	// Context stops the running operation when it is done, and the run is
+	// recorded as aborted. The operations are never stopped when nil.
+	Context context.Context
+	// OnProgress is called with the progress of each step of the operations,
+	// when the step, a play or a task starts, and when the step ends
+	OnProgress func(Progress)
+	// RollbackOnFailure resets the nodes that were modified by a failed
+	// installation, so that they are not left half configured
+	RollbackOnFailure bool
+	// RestartComponents are the components, such as kubelet or etcd, that are
+	// restarted by the installations and the upgrades when RestartServices is
+	// not set. The options are listed by install.RestartComponents().
+	RestartComponents []string
+	// Retry is the policy applied when a step of an operation fails on some
+	// nodes. The steps are not retried by default.
+	Retry RetryPolicy
+	// ShredCredentials overwrites the files that hold the passwords and the
+	// SSH keys of the cluster before they are removed, once each step of an
+	// operation is done
+	ShredCredentials bool
+	// VaultClusterCatalog encrypts the variables passed to ansible with
+	// ansible-vault, using a random password for each step
+	VaultClusterCatalog bool
+	// Hooks are the commands and the playbooks run before and after the steps
+	// of the operations, which are named after the tasks of the executor
+	Hooks []Hook
+	// NotificationWebhook is a URL that is sent a JSON payload when each step
+	// of the operations starts, succeeds or fails
+	NotificationWebhook string
+}
+
+// InstallOptions are the options of the installation of a cluster
+type InstallOptions struct {
+	// RestartServices restarts the services of the cluster, even when their
+	// configuration did not change
+	RestartServices bool
+	// Limit restricts the installation to the nodes matching the host names,
+	// roles or node label selectors. Entries prefixed with ! exclude the
+	// nodes they match.
+	Limit []string
+	// Exclude excludes the nodes matching the host names, roles or node
+	// label selectors from the installation
+	Exclude []string
+	// SkipSmokeTest does not run the smoke test after the installation
+	SkipSmokeTest bool
+	// ResumeFrom is the run directory of a failed installation, which is
+	// resumed from the point where it failed. The most recent installation
+	// is resumed when set to "last".
+	ResumeFrom string
+}
+
+// UpgradeOptions are the options of the upgrade of a cluster
+type UpgradeOptions struct {
+	// Online upgrades the nodes while the workloads keep running, by
+	// draining the workers one at a time
+	Online bool
+	// MaxParallelWorkers is the number of workers upgraded at the same time
+	// during an offline upgrade. Defaults to 1.
+	MaxParallelWorkers int
+	// RestartServices restarts the services of the cluster, even when their
+	// configuration did not change
+	RestartServices bool
+	// SkipSmokeTest does not run the smoke test after the upgrade
+	SkipSmokeTest bool
+}
+
+// ClusterV1 is version 1 of the operations on a cluster
+type ClusterV1 interface {
+	// Plan returns the plan of the cluster
+	Plan() *Plan
+	// Validate returns a ValidationError if the plan is not valid
+	Validate() error
+	// GenerateCertificates generates the certificates and the kubeconfig of
+	// the cluster, reusing the existing ones
+	GenerateCertificates() error
+	// Install the cluster, generating the certificates first
+	Install(opts InstallOptions) error
+	// SmokeTest verifies that the cluster works
+	SmokeTest() error
+	// Reset removes the cluster from the nodes
+	Reset(opts ResetOptions) error
+}
+
+// ClusterV2 is version 2 of the operations on a cluster
+type ClusterV2 interface {
+	ClusterV1
+	// Upgrade the nodes that are not at the version of the plan, and then
+	// the cluster services
+	Upgrade(opts UpgradeOptions) error
+}
+
+// ClusterV3 is version 3 of the operations on a cluster
+type ClusterV3 interface {
+	ClusterV2
+	// RunSummary returns the number of tasks that succeeded, changed, were
+	// skipped or failed on each node during the last operation, along with
+	// the nodes on which it failed, whether the operation succeeded or not
+	RunSummary() RunSummary
+}
+
+type cluster struct {
+	plan     *Plan
+	options  Options
+	executor install.Executor
+	// summary of the last operation
+	summary RunSummary
+}
+
+// NewCluster returns the operations on the cluster of the plan
+func NewCluster(p *Plan, opts Options) (ClusterV1, error) {
+	return NewClusterV2(p, opts)
+}
+
+// NewClusterV2 returns version 2 of the operations on the cluster of the plan
+func NewClusterV2(p *Plan, opts Options) (ClusterV2, error) {
+	return NewClusterV3(p, opts)
+}
+
+// NewClusterV3 returns version 3 of the operations on the cluster of the plan
+func NewClusterV3(p *Plan, opts Options) (ClusterV3, error) {
+	if p == nil {
+		return nil, fmt.Errorf("the plan cannot be nil")
+	}
+	if opts.GeneratedAssetsDirectory == "" {
+		opts.GeneratedAssetsDirectory = "generated"
+	}
+	if opts.RunsDirectory == "" {
+		opts.RunsDirectory = "runs"
+	}
+	if opts.Out == nil {
+		opts.Out = ioutil.Discard
+	}
+	executor, err := install.NewExecutor(opts.Out, opts.Out, install.ExecutorOptions{
+		GeneratedAssetsDirectory: opts.GeneratedAssetsDirectory,
+		RunsDirectory:            opts.RunsDirectory,
+		OutputFormat:             opts.OutputFormat,
+		Verbose:                  opts.Verbose,
+		Timeout:                  opts.Timeout,
+		Context:                  opts.Context,
+		OnProgress:               opts.OnProgress,
+		RollbackOnFailure:        opts.RollbackOnFailure,
+		RestartComponents:        opts.RestartComponents,
+		Retry:                    opts.Retry,
+		ShredCredentials:         opts.ShredCredentials,
+		VaultClusterCatalog:      opts.VaultClusterCatalog,
+		Hooks:                    opts.Hooks,
+		NotificationWebhook:      opts.NotificationWebhook,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &cluster{plan: p, options: opts, executor: executor}, nil
+}
+
+func (c *cluster) Plan() *Plan {
+	return c.plan
+}
+
+func (c *cluster) Validate() error {
+	return ValidatePlan(c.plan)
+}
+
+func (c *cluster) GenerateCertificates() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if err := c.executor.GenerateCertificates(c.plan, false); err != nil {
+		return fmt.Errorf("error generating certificates: %v", err)
+	}
+	if err := install.GenerateKubeconfig(c.plan, c.options.GeneratedAssetsDirectory); err != nil {
+		return fmt.Errorf("error generating kubeconfig file: %v", err)
+	}
+	return nil
+}
+
+func (c *cluster) Install(opts InstallOptions) error {
+	summary, err := c.installCluster(opts)
+	c.summary = summary
+	return err
+}
+
+func (c *cluster) installCluster(opts InstallOptions) (RunSummary, error) {
+	if err := c.GenerateCertificates(); err != nil {
+		return RunSummary{}, err
+	}
+	summary, err := c.install(opts)
+	if err != nil {
+		return summary, fmt.Errorf("error installing: %v", err)
+	}
+	if opts.SkipSmokeTest || !c.plan.NetworkConfigured() {
+		return summary, nil
+	}
+	testSummary, err := c.smokeTest()
+	return summary.Merge(testSummary), err
+}
+
+func (c *cluster) install(opts InstallOptions) (RunSummary, error) {
+	limit := append(append([]string{}, opts.Limit...), install.ExcludeFromLimit(opts.Exclude...)...)
+	if opts.ResumeFrom == "" {
+		return c.executor.Install(c.plan, opts.RestartServices, limit...)
+	}
+	r, ok := c.executor.(install.ResumableExecutor)
+	if !ok {
+		return RunSummary{}, fmt.Errorf("the executor cannot resume installations")
+	}
+	return r.ResumeInstall(c.plan, opts.RestartServices, opts.ResumeFrom, limit...)
+}
+
+func (c *cluster) SmokeTest() error {
+	summary, err := c.smokeTest()
+	c.summary = summary
+	return err
+}
+
+func (c *cluster) smokeTest() (RunSummary, error) {
+	summary, err := c.executor.RunSmokeTest(c.plan)
+	if err != nil {
+		return summary, fmt.Errorf("error running smoke test: %v", err)
+	}
+	return summary, nil
+}
+
+func (c *cluster) Upgrade(opts UpgradeOptions) error {
+	summary, err := c.upgrade(opts)
+	c.summary = summary
+	return err
+}
+
+func (c *cluster) upgrade(opts UpgradeOptions) (RunSummary, error) {
+	if err := c.Validate(); err != nil {
+		return RunSummary{}, err
+	}
+	if opts.MaxParallelWorkers < 1 {
+		opts.MaxParallelWorkers = 1
+	}
+	cv, err := install.ListVersions(c.plan)
+	if err != nil {
+		return RunSummary{}, fmt.Errorf("error listing cluster versions: %v", err)
+	}
+	if err := c.executor.GenerateCertificates(c.plan, true); err != nil {
+		return RunSummary{}, fmt.Errorf("error generating certificates: %v", err)
+	}
+	if _, err := install.RegenerateKubeconfig(c.plan, c.options.GeneratedAssetsDirectory); err != nil {
+		return RunSummary{}, fmt.Errorf("error generating kubeconfig file: %v", err)
+	}
+	var summary RunSummary
+	toUpgrade, _ := install.NodesToUpgrade(*c.plan, cv)
+	if len(toUpgrade) > 0 {
+		nodesSummary, err := c.executor.UpgradeNodes(*c.plan, toUpgrade, opts.Online, opts.MaxParallelWorkers, opts.RestartServices)
+		summary = summary.Merge(nodesSummary)
+		if err != nil {
+			return summary, fmt.Errorf("error upgrading nodes: %v", err)
+		}
+	}
+	servicesSummary, err := c.executor.UpgradeClusterServices(*c.plan)
+	summary = summary.Merge(servicesSummary)
+	if err != nil {
+		return summary, fmt.Errorf("error upgrading cluster services: %v", err)
+	}
+	if opts.SkipSmokeTest || !c.plan.NetworkConfigured() {
+		return summary, nil
+	}
+	testSummary, err := c.smokeTest()
+	return summary.Merge(testSummary), err
+}
+
+func (c *cluster) Reset(opts ResetOptions) error {
+	summary, err := c.executor.Reset(c.plan, opts)
+	c.summary = summary
+	if err != nil {
+		return fmt.Errorf("error running reset: %v", err)
+	}
+	return nil
+}
+
+func (c *cluster) RunSummary() RunSummary {
+	return c.summary
+}
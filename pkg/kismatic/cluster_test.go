@@ -0,0 +1,82 @@
+package kismatic
+
+import (
+	"testing"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+// fakeExecutor records the operations run on the cluster. The operations
+// that are not used by the SDK are not implemented.
+type fakeExecutor struct {
+	install.Executor
+	calls   []string
+	summary install.RunSummary
+}
+
+func (e *fakeExecutor) Install(p *install.Plan, restartServices bool, nodes ...string) (install.RunSummary, error) {
+	e.calls = append(e.calls, "install")
+	return install.RunSummary{}, nil
+}
+
+func (e *fakeExecutor) RunSmokeTest(p *install.Plan) (install.RunSummary, error) {
+	e.calls = append(e.calls, "smoketest")
+	return e.summary, nil
+}
+
+func (e *fakeExecutor) Reset(p *install.Plan, opts install.ResetOptions, nodes ...string) (install.RunSummary, error) {
+	e.calls = append(e.calls, "reset")
+	return install.RunSummary{}, nil
+}
+
+func TestNewClusterRequiresPlan(t *testing.T) {
+	if _, err := NewCluster(nil, Options{}); err == nil {
+		t.Errorf("expected an error without a plan")
+	}
+}
+
+func TestClusterInstallValidatesPlan(t *testing.T) {
+	e := &fakeExecutor{}
+	c := &cluster{plan: &Plan{}, executor: e}
+	err := c.Install(InstallOptions{})
+	if _, ok := err.(ValidationError); !ok {
+		t.Errorf("expected a ValidationError, but got %v", err)
+	}
+	if len(e.calls) != 0 {
+		t.Errorf("expected no operations on an invalid plan, but got %v", e.calls)
+	}
+}
+
+func TestClusterSmokeTestAndReset(t *testing.T) {
+	e := &fakeExecutor{}
+	c := &cluster{plan: &Plan{}, executor: e}
+	if err := c.SmokeTest(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Reset(ResetOptions{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(e.calls) != 2 || e.calls[0] != "smoketest" || e.calls[1] != "reset" {
+		t.Errorf("unexpected operations %v", e.calls)
+	}
+}
+
+func TestClusterRunSummary(t *testing.T) {
+	e := &fakeExecutor{summary: install.RunSummary{FailedHosts: []string{"worker1"}}}
+	c := &cluster{plan: &Plan{}, executor: e}
+	if s := c.RunSummary(); len(s.Hosts) != 0 || len(s.FailedHosts) != 0 {
+		t.Errorf("expected an empty summary before the first operation, got %+v", s)
+	}
+	if err := c.SmokeTest(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := c.RunSummary(); len(s.FailedHosts) != 1 {
+		t.Errorf("expected the summary of the smoke test, got %+v", s)
+	}
+	if err := c.Reset(ResetOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := c.RunSummary(); len(s.FailedHosts) != 0 {
+		t.Errorf("expected the summary of the previous operation to be discarded, got %+v", s)
+	}
+}
@@ -0,0 +1,9 @@
+// Package kismatic is the SDK to embed Kismatic in other Go programs, such
+// as portals and operators. It exposes the loading and validation of plans,
+// and the operations on a cluster, without depending on the other packages
+// of Kismatic, whose APIs may change between releases.
+//
+// The interfaces of the package are versioned. A released interface, such as
+// ClusterV1, never changes: new operations are added to a new version of the
+// interface, which embeds the previous one.
+package kismatic
@@ -0,0 +1,69 @@
+package kismatic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+// Plan is the installation plan of a cluster
+type Plan = install.Plan
+
+// Node is a node of the plan
+type Node = install.Node
+
+// ValidationError is returned when a plan is not valid. It contains all the
+// problems found in the plan.
+type ValidationError struct {
+	Errs []error
+}
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("the plan is not valid: %s", strings.Join(msgs, "; "))
+}
+
+// LoadPlan reads the plan from the file
+func LoadPlan(file string) (*Plan, error) {
+	planner := &install.FilePlanner{File: file}
+	if !planner.PlanExists() {
+		return nil, fmt.Errorf("plan file %q does not exist", file)
+	}
+	p, err := planner.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %q: %v", file, err)
+	}
+	return p, nil
+}
+
+// ParsePlan returns the plan described by the YAML document, such as the
+// contents of a plan file
+func ParsePlan(b []byte) (*Plan, error) {
+	p, err := install.ParsePlan(b)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing plan: %v", err)
+	}
+	return p, nil
+}
+
+// WritePlan writes the plan to the file
+func WritePlan(p *Plan, file string) error {
+	planner := &install.FilePlanner{File: file}
+	if err := planner.Write(p); err != nil {
+		return fmt.Errorf("error writing plan file %q: %v", file, err)
+	}
+	return nil
+}
+
+// ValidatePlan returns a ValidationError if the plan is not valid. The
+// connections to the nodes are not validated.
+func ValidatePlan(p *Plan) error {
+	if ok, errs := install.ValidatePlan(p); !ok {
+		return ValidationError{Errs: errs}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package kismatic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPlanWritten(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kismatic-sdk-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "kismatic-cluster.yaml")
+
+	if _, err := LoadPlan(file); err == nil {
+		t.Errorf("expected an error loading a plan that does not exist")
+	}
+	p := &Plan{}
+	p.Cluster.Name = "sdk"
+	if err := WritePlan(p, file); err != nil {
+		t.Fatalf("unexpected error writing the plan: %v", err)
+	}
+	read, err := LoadPlan(file)
+	if err != nil {
+		t.Fatalf("unexpected error loading the plan: %v", err)
+	}
+	if read.Cluster.Name != "sdk" {
+		t.Errorf("expected the cluster name to be %q, but got %q", "sdk", read.Cluster.Name)
+	}
+}
+
+func TestValidatePlanReturnsValidationError(t *testing.T) {
+	err := ValidatePlan(&Plan{})
+	verr, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("expected a ValidationError, but got %v", err)
+	}
+	if len(verr.Errs) == 0 {
+		t.Errorf("expected the problems of the plan in the error")
+	}
+}
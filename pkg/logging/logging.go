@@ -0,0 +1,163 @@
+// Package logging records a structured log of the activity of the kismatic
+// process. Each entry is written as a single line of JSON, independently of the
+// output that is printed to the console.
+//
+// The package-level logger discards all entries until an output is set with
+// SetOutput.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Level of a log entry
+type Level string
+
+const (
+	// DebugLevel is used for detailed information about the decisions made by kismatic
+	DebugLevel Level = "debug"
+	// InfoLevel is used for the general activity of kismatic
+	InfoLevel Level = "info"
+	// WarnLevel is used for unexpected conditions that do not result in a failure
+	WarnLevel Level = "warn"
+	// ErrorLevel is used for failures
+	ErrorLevel Level = "error"
+)
+
+// Logger writes structured log entries. Fields are provided as alternating
+// keys and values.
+type Logger struct {
+	mu     *sync.Mutex
+	out    *io.Writer
+	fields []interface{}
+	now    func() time.Time
+}
+
+// New returns a logger that writes to the given writer
+func New(out io.Writer) *Logger {
+	return &Logger{
+		mu:  &sync.Mutex{},
+		out: &out,
+		now: time.Now,
+	}
+}
+
+// With returns a logger that includes the given fields in all entries. The
+// returned logger shares the output of the parent logger.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	return &Logger{
+		mu:     l.mu,
+		out:    l.out,
+		fields: fields,
+		now:    l.now,
+	}
+}
+
+// SetOutput sets the writer where log entries are written
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.out = out
+}
+
+// Debug logs an entry at the debug level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(DebugLevel, msg, keyvals)
+}
+
+// Info logs an entry at the info level
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(InfoLevel, msg, keyvals)
+}
+
+// Warn logs an entry at the warn level
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(WarnLevel, msg, keyvals)
+}
+
+// Error logs an entry at the error level
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(ErrorLevel, msg, keyvals)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if *l.out == ioutil.Discard {
+		return
+	}
+	entry := map[string]interface{}{}
+	addFields(entry, l.fields)
+	addFields(entry, keyvals)
+	entry["time"] = l.now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["msg"] = msg
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{
+			"time":  entry["time"],
+			"level": ErrorLevel,
+			"msg":   fmt.Sprintf("error encoding log entry %q: %v", msg, err),
+		})
+	}
+	(*l.out).Write(append(b, '\n'))
+}
+
+func addFields(entry map[string]interface{}, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 == len(keyvals) {
+			entry[key] = nil
+			break
+		}
+		switch v := keyvals[i+1].(type) {
+		case error:
+			entry[key] = v.Error()
+		case fmt.Stringer:
+			entry[key] = v.String()
+		default:
+			entry[key] = v
+		}
+	}
+}
+
+var std = New(ioutil.Discard)
+
+// SetOutput sets the output of the package-level logger
+func SetOutput(out io.Writer) {
+	std.SetOutput(out)
+}
+
+// With returns a logger that includes the given fields in all entries, and
+// writes to the output of the package-level logger
+func With(keyvals ...interface{}) *Logger {
+	return std.With(keyvals...)
+}
+
+// Debug logs an entry at the debug level using the package-level logger
+func Debug(msg string, keyvals ...interface{}) {
+	std.Debug(msg, keyvals...)
+}
+
+// Info logs an entry at the info level using the package-level logger
+func Info(msg string, keyvals ...interface{}) {
+	std.Info(msg, keyvals...)
+}
+
+// Warn logs an entry at the warn level using the package-level logger
+func Warn(msg string, keyvals ...interface{}) {
+	std.Warn(msg, keyvals...)
+}
+
+// Error logs an entry at the error level using the package-level logger
+func Error(msg string, keyvals ...interface{}) {
+	std.Error(msg, keyvals...)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerWritesJSONLines(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := New(out)
+	l.now = func() time.Time { return time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC) }
+	l.With("task", "apply").Info("running playbook", "playbook", "kubernetes.yaml")
+	l.Error("playbook failed", "error", errors.New("exit status 2"), "duration", 2*time.Second, "dangling")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log entries, but got %d: %s", len(lines), out.String())
+	}
+	var first, second map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("error unmarshaling first entry: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("error unmarshaling second entry: %v", err)
+	}
+	expectedFirst := map[string]interface{}{
+		"time":     "2018-01-02T03:04:05Z",
+		"level":    "info",
+		"msg":      "running playbook",
+		"task":     "apply",
+		"playbook": "kubernetes.yaml",
+	}
+	for k, v := range expectedFirst {
+		if first[k] != v {
+			t.Errorf("expected %q to be %v, but got %v", k, v, first[k])
+		}
+	}
+	if _, ok := second["task"]; ok {
+		t.Errorf("fields of a child logger leaked into the parent logger")
+	}
+	if second["error"] != "exit status 2" {
+		t.Errorf("expected error to be encoded as a string, but got %v", second["error"])
+	}
+	if second["duration"] != "2s" {
+		t.Errorf("expected duration to be encoded as a string, but got %v", second["duration"])
+	}
+	if v, ok := second["dangling"]; !ok || v != nil {
+		t.Errorf("expected dangling key to be recorded with a null value, but got %v", v)
+	}
+}
+
+func TestChildLoggerFollowsOutput(t *testing.T) {
+	l := New(&bytes.Buffer{})
+	child := l.With("component", "test")
+	out := &bytes.Buffer{}
+	l.SetOutput(out)
+	child.Debug("hello")
+	if !strings.Contains(out.String(), `"component":"test"`) {
+		t.Errorf("child logger did not write to the new output: %q", out.String())
+	}
+}
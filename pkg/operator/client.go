@@ -0,0 +1,223 @@
+package operator
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// Client of the Cluster custom resources of the Kubernetes API
+type Client interface {
+	// List the clusters of the namespace, or of all the namespaces when
+	// the namespace is empty
+	List(namespace string) (*ClusterList, error)
+	// Watch the changes to the clusters after the resource version. The
+	// channel is closed when the watch ends, or when stop is closed.
+	Watch(namespace, resourceVersion string, stop <-chan struct{}) (<-chan WatchEvent, error)
+	// UpdateStatus updates the status of the cluster
+	UpdateStatus(c *Cluster) error
+}
+
+// ClientConfig is the configuration of the connection to the Kubernetes API
+type ClientConfig struct {
+	// Server is the URL of the Kubernetes API
+	Server string
+	// Token is the bearer token used to authenticate
+	Token string
+	// CAFile is the CA certificate of the Kubernetes API
+	CAFile string
+	// CertFile and KeyFile are the client certificate used to authenticate,
+	// such as the admin certificate generated by kismatic
+	CertFile string
+	KeyFile  string
+}
+
+// InClusterConfig returns the configuration of the service account of the
+// pod the operator runs in
+func InClusterConfig() (*ClientConfig, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("the operator is not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account token: %v", err)
+	}
+	return &ClientConfig{
+		Server: "https://" + net.JoinHostPort(host, port),
+		Token:  strings.TrimSpace(string(token)),
+		CAFile: serviceAccountDir + "/ca.crt",
+	}, nil
+}
+
+type restClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// NewClient returns a client of the Cluster custom resources
+func NewClient(config ClientConfig) (Client, error) {
+	if config.Server == "" {
+		return nil, fmt.Errorf("the server of the Kubernetes API is required")
+	}
+	tlsConfig := &tls.Config{}
+	if config.CAFile != "" {
+		ca, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("the CA file %q does not contain a PEM certificate", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &restClient{
+		server: strings.TrimRight(config.Server, "/"),
+		token:  config.Token,
+		// No timeout is set, as the watches are long running requests
+		http: &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}},
+	}, nil
+}
+
+// apiError is returned when the Kubernetes API responds with an error status
+type apiError struct {
+	statusCode int
+	message    string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.statusCode == http.StatusNotFound
+}
+
+func (c *restClient) resourcePath(namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s/apis/%s/%s/%s", c.server, Group, Version, Resource)
+	}
+	return fmt.Sprintf("%s/apis/%s/%s/namespaces/%s/%s", c.server, Group, Version, namespace, Resource)
+}
+
+func (c *restClient) do(method, url string, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling the Kubernetes API: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, &apiError{
+			statusCode: resp.StatusCode,
+			message:    fmt.Sprintf("%s %s returned %s: %s", method, url, resp.Status, strings.TrimSpace(string(b))),
+		}
+	}
+	return resp, nil
+}
+
+func (c *restClient) List(namespace string) (*ClusterList, error) {
+	resp, err := c.do("GET", c.resourcePath(namespace), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	list := &ClusterList{}
+	if err := json.NewDecoder(resp.Body).Decode(list); err != nil {
+		return nil, fmt.Errorf("error decoding the list of clusters: %v", err)
+	}
+	return list, nil
+}
+
+func (c *restClient) Watch(namespace, resourceVersion string, stop <-chan struct{}) (<-chan WatchEvent, error) {
+	url := c.resourcePath(namespace) + "?watch=true"
+	if resourceVersion != "" {
+		url += "&resourceVersion=" + resourceVersion
+	}
+	resp, err := c.do("GET", url, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan WatchEvent)
+	done := make(chan struct{})
+	go func() {
+		// Closing the body ends the decoding of the stream
+		select {
+		case <-stop:
+		case <-done:
+		}
+		resp.Body.Close()
+	}()
+	go func() {
+		defer close(events)
+		defer close(done)
+		d := json.NewDecoder(resp.Body)
+		for {
+			var e WatchEvent
+			if err := d.Decode(&e); err != nil {
+				return
+			}
+			select {
+			case events <- e:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (c *restClient) UpdateStatus(cluster *Cluster) error {
+	patch := struct {
+		Status ClusterStatus `json:"status"`
+	}{Status: cluster.Status}
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("error marshaling status: %v", err)
+	}
+	url := c.resourcePath(cluster.Metadata.Namespace) + "/" + cluster.Metadata.Name
+	resp, err := c.do("PATCH", url+"/status", "application/merge-patch+json", b)
+	if isNotFound(err) {
+		// The status subresource of custom resources is alpha and disabled by
+		// default in Kubernetes 1.10, the status is then part of the resource.
+		// When the subresource is enabled, the resource ignores the status.
+		resp, err = c.do("PATCH", url, "application/merge-patch+json", b)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
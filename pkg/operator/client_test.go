@@ -0,0 +1,115 @@
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientListAndUpdateStatus(t *testing.T) {
+	var patched []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/apis/kismatic.apprenda.com/v1alpha1/namespaces/default/clusters" && r.URL.Query().Get("watch") == "true":
+			fmt.Fprintln(w, `{"type":"MODIFIED","object":{"metadata":{"name":"test","generation":2}}}`)
+		case r.Method == "GET" && r.URL.Path == "/apis/kismatic.apprenda.com/v1alpha1/namespaces/default/clusters":
+			fmt.Fprint(w, `{"metadata":{"resourceVersion":"10"},"items":[{"metadata":{"name":"test","namespace":"default","generation":1},"spec":{"plan":"cluster: {}"}}]}`)
+		case r.Method == "PATCH" && r.URL.Path == "/apis/kismatic.apprenda.com/v1alpha1/namespaces/default/clusters/test/status":
+			if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			patched, _ = ioutil.ReadAll(r.Body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := NewClient(ClientConfig{Server: server.URL, Token: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list, err := c.List("default")
+	if err != nil {
+		t.Fatalf("unexpected error listing clusters: %v", err)
+	}
+	if list.Metadata.ResourceVersion != "10" || len(list.Items) != 1 || list.Items[0].Spec.Plan != "cluster: {}" {
+		t.Errorf("unexpected list %+v", list)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	events, err := c.Watch("default", list.Metadata.ResourceVersion, stop)
+	if err != nil {
+		t.Fatalf("unexpected error watching clusters: %v", err)
+	}
+	e, ok := <-events
+	if !ok || e.Type != "MODIFIED" || e.Object.Metadata.Generation != 2 {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if _, ok := <-events; ok {
+		t.Errorf("expected the events to be closed when the watch ends")
+	}
+
+	cluster := list.Items[0]
+	cluster.Status.Phase = PhaseReady
+	if err := c.UpdateStatus(&cluster); err != nil {
+		t.Fatalf("unexpected error updating the status: %v", err)
+	}
+	var patch struct {
+		Status ClusterStatus `json:"status"`
+	}
+	if err := json.Unmarshal(patched, &patch); err != nil || patch.Status.Phase != PhaseReady {
+		t.Errorf("unexpected patch %s: %v", patched, err)
+	}
+
+	if _, err := c.List("other"); err == nil {
+		t.Errorf("expected an error when the API returns an error")
+	}
+}
+
+func TestClientUpdateStatusWithoutSubresource(t *testing.T) {
+	// Kubernetes 1.10 does not serve the status subresource of custom
+	// resources unless the CustomResourceSubresources feature is enabled
+	var patched []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" && r.URL.Path == "/apis/kismatic.apprenda.com/v1alpha1/namespaces/default/clusters/test" {
+			patched, _ = ioutil.ReadAll(r.Body)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c, err := NewClient(ClientConfig{Server: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cluster := Cluster{}
+	cluster.Metadata.Name = "test"
+	cluster.Metadata.Namespace = "default"
+	cluster.Status.Phase = PhaseReady
+	if err := c.UpdateStatus(&cluster); err != nil {
+		t.Fatalf("unexpected error updating the status: %v", err)
+	}
+	var patch struct {
+		Status ClusterStatus `json:"status"`
+	}
+	if err := json.Unmarshal(patched, &patch); err != nil || patch.Status.Phase != PhaseReady {
+		t.Errorf("unexpected patch %s: %v", patched, err)
+	}
+
+	// The cluster was deleted
+	cluster.Metadata.Name = "deleted"
+	if err := c.UpdateStatus(&cluster); !isNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/kismatic"
+	"github.com/apprenda/kismatic/pkg/logging"
+)
+
+// Controller installs and upgrades the clusters described by the Cluster
+// custom resources. The operations run one at a time.
+type Controller struct {
+	// Client of the Cluster custom resources
+	Client Client
+	// Namespace of the clusters. All the namespaces are watched when empty.
+	Namespace string
+	// WorkDirectory keeps the generated assets and the runs of each cluster,
+	// in a directory named after its namespace and name
+	WorkDirectory string
+	// Out receives the console output of the operations. The output is
+	// discarded when nil.
+	Out io.Writer
+	// RetryPeriod is the time to wait before listing the clusters again
+	// after the Kubernetes API failed
+	RetryPeriod time.Duration
+	// NewCluster returns the operations on a cluster. Defaults to
+	// kismatic.NewClusterV2.
+	NewCluster func(p *kismatic.Plan, opts kismatic.Options) (kismatic.ClusterV2, error)
+
+	mu sync.Mutex
+	// generations is the generation of each cluster that was last applied by
+	// the controller, so that the events caused by the updates to the
+	// status do not run the operations again
+	generations map[string]int64
+	now         func() time.Time
+}
+
+// Run the controller until stop is closed. The running operation is stopped
+// and recorded as aborted when stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) error {
+	if c.Client == nil {
+		return fmt.Errorf("the client of the Kubernetes API is required")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	for {
+		if err := c.listAndWatch(ctx, stop); err != nil {
+			logging.Error("error watching clusters", "error", err)
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(c.retryPeriod()):
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+	}
+}
+
+// listAndWatch reconciles all the clusters, and then the clusters that
+// change until the watch ends
+func (c *Controller) listAndWatch(ctx context.Context, stop <-chan struct{}) error {
+	list, err := c.Client.List(c.Namespace)
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		c.reconcile(ctx, &list.Items[i])
+	}
+	events, err := c.Client.Watch(c.Namespace, list.Metadata.ResourceVersion, stop)
+	if err != nil {
+		return err
+	}
+	for e := range events {
+		switch e.Type {
+		case "ADDED", "MODIFIED":
+			c.reconcile(ctx, &e.Object)
+		case "ERROR":
+			// The resource version expired, list the clusters again
+			return nil
+		}
+	}
+	return nil
+}
+
+func (c *Controller) reconcile(ctx context.Context, cluster *Cluster) {
+	if err := c.Reconcile(ctx, cluster); err != nil {
+		logging.Error("error reconciling cluster", "cluster", cluster.key(), "generation", cluster.Metadata.Generation, "error", err)
+	}
+}
+
+// Reconcile installs or upgrades the cluster, when the generation of its spec
+// was not applied yet, and reports the progress in its status. The cluster is
+// upgraded when the Kubernetes version of the plan differs from the version
+// in the status, and installed otherwise, which also applies the changes to
+// the plan.
+func (c *Controller) Reconcile(ctx context.Context, cluster *Cluster) error {
+	if !c.needsApply(cluster) {
+		return nil
+	}
+	log := logging.With("cluster", cluster.key(), "generation", cluster.Metadata.Generation)
+	status := &cluster.Status
+	now := c.clock()
+
+	plan, err := kismatic.ParsePlan([]byte(cluster.Spec.Plan))
+	if err == nil {
+		err = kismatic.ValidatePlan(plan)
+	}
+	if err != nil {
+		log.Warn("the plan of the cluster is not valid", "error", err)
+		status.Phase = PhaseFailed
+		status.ObservedGeneration = cluster.Metadata.Generation
+		status.setCondition(Condition{Type: ConditionValid, Status: ConditionFalse, Reason: "InvalidPlan", Message: err.Error()}, now)
+		status.setCondition(Condition{Type: ConditionProgressing, Status: ConditionFalse, Reason: "InvalidPlan"}, now)
+		return c.recordApplied(cluster)
+	}
+	status.setCondition(Condition{Type: ConditionValid, Status: ConditionTrue, Reason: "ValidPlan"}, now)
+
+	operation, phase := "Install", PhaseInstalling
+	if status.Version != "" && status.Version != plan.Cluster.Version {
+		operation, phase = "Upgrade", PhaseUpgrading
+	}
+	status.Phase = phase
+	status.setCondition(Condition{Type: ConditionProgressing, Status: ConditionTrue, Reason: operation + "Started"}, now)
+	if err := c.Client.UpdateStatus(cluster); err != nil {
+		return fmt.Errorf("error updating the status of the cluster: %v", err)
+	}
+
+	log.Info("running operation on cluster", "operation", operation)
+	err = c.run(ctx, cluster, plan, operation)
+	if ctx.Err() != nil {
+		// The status is left as is, so that the operation runs again when
+		// the controller restarts
+		return fmt.Errorf("the operation %s was aborted: %v", operation, ctx.Err())
+	}
+	now = c.clock()
+	status.ObservedGeneration = cluster.Metadata.Generation
+	if err != nil {
+		log.Error("operation failed", "operation", operation, "error", err)
+		status.Phase = PhaseFailed
+		status.setCondition(Condition{Type: ConditionProgressing, Status: ConditionFalse, Reason: operation + "Failed", Message: err.Error()}, now)
+		status.setCondition(Condition{Type: ConditionReady, Status: ConditionFalse, Reason: operation + "Failed", Message: err.Error()}, now)
+		return c.recordApplied(cluster)
+	}
+	log.Info("operation completed", "operation", operation)
+	status.Phase = PhaseReady
+	status.Version = plan.Cluster.Version
+	status.setCondition(Condition{Type: ConditionProgressing, Status: ConditionFalse, Reason: operation + "Completed"}, now)
+	status.setCondition(Condition{Type: ConditionReady, Status: ConditionTrue, Reason: operation + "Completed"}, now)
+	return c.recordApplied(cluster)
+}
+
+// run the operation on the cluster
+func (c *Controller) run(ctx context.Context, cluster *Cluster, plan *kismatic.Plan, operation string) error {
+	newCluster := c.NewCluster
+	if newCluster == nil {
+		newCluster = kismatic.NewClusterV2
+	}
+	out := c.Out
+	if out == nil {
+		out = ioutil.Discard
+	}
+	dir := filepath.Join(c.WorkDirectory, cluster.Metadata.Namespace, cluster.Metadata.Name)
+	ops, err := newCluster(plan, kismatic.Options{
+		GeneratedAssetsDirectory: filepath.Join(dir, "generated"),
+		RunsDirectory:            filepath.Join(dir, "runs"),
+		Out:                      out,
+		Context:                  ctx,
+	})
+	if err != nil {
+		return err
+	}
+	if operation == "Upgrade" {
+		return ops.Upgrade(kismatic.UpgradeOptions{})
+	}
+	return ops.Install(kismatic.InstallOptions{})
+}
+
+// needsApply returns true when the generation of the cluster was not applied
+// by the controller, or by a previous instance of the controller
+func (c *Controller) needsApply(cluster *Cluster) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen, ok := c.generations[cluster.key()]; ok {
+		return gen != cluster.Metadata.Generation
+	}
+	// A previous instance of the controller applied the generation, unless
+	// it stopped while running an operation
+	status := cluster.Status
+	done := status.Phase == PhaseReady || status.Phase == PhaseFailed
+	return !done || status.ObservedGeneration != cluster.Metadata.Generation
+}
+
+// recordApplied records that the generation of the cluster was applied, and
+// updates its status
+func (c *Controller) recordApplied(cluster *Cluster) error {
+	c.mu.Lock()
+	if c.generations == nil {
+		c.generations = map[string]int64{}
+	}
+	c.generations[cluster.key()] = cluster.Metadata.Generation
+	c.mu.Unlock()
+	if err := c.Client.UpdateStatus(cluster); err != nil {
+		return fmt.Errorf("error updating the status of the cluster: %v", err)
+	}
+	return nil
+}
+
+func (c *Controller) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Controller) retryPeriod() time.Duration {
+	if c.RetryPeriod > 0 {
+		return c.RetryPeriod
+	}
+	return 10 * time.Second
+}
@@ -0,0 +1,212 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/kismatic"
+)
+
+const testPlan = `cluster:
+  name: test
+  version: v1.10.3
+  networking:
+    pod_cidr_block: 172.16.0.0/16
+    service_cidr_block: 172.20.0.0/16
+  certificates:
+    expiry: 17250h
+  ssh:
+    user: root
+    ssh_key: /bin/sh
+    ssh_port: 22
+add_ons:
+  cni:
+    provider: calico
+    options:
+      calico:
+        mode: overlay
+        log_level: info
+etcd:
+  expected_count: 1
+  nodes:
+  - host: etcd01
+    ip: 192.168.205.10
+master:
+  expected_count: 1
+  load_balanced_fqdn: test
+  load_balanced_short_name: test
+  nodes:
+  - host: master01
+    ip: 192.168.205.11
+worker:
+  expected_count: 1
+  nodes:
+  - host: worker01
+    ip: 192.168.205.12
+`
+
+// fakeClient records the statuses of the clusters
+type fakeClient struct {
+	statuses []ClusterStatus
+}
+
+func (c *fakeClient) List(namespace string) (*ClusterList, error) {
+	return &ClusterList{}, nil
+}
+
+func (c *fakeClient) Watch(namespace, resourceVersion string, stop <-chan struct{}) (<-chan WatchEvent, error) {
+	events := make(chan WatchEvent)
+	close(events)
+	return events, nil
+}
+
+func (c *fakeClient) UpdateStatus(cluster *Cluster) error {
+	s := cluster.Status
+	s.Conditions = append([]Condition{}, s.Conditions...)
+	c.statuses = append(c.statuses, s)
+	return nil
+}
+
+// fakeCluster records the operations run on the cluster
+type fakeCluster struct {
+	kismatic.ClusterV2
+	operations []string
+	err        error
+}
+
+func (c *fakeCluster) Install(opts kismatic.InstallOptions) error {
+	c.operations = append(c.operations, "install")
+	return c.err
+}
+
+func (c *fakeCluster) Upgrade(opts kismatic.UpgradeOptions) error {
+	c.operations = append(c.operations, "upgrade")
+	return c.err
+}
+
+func newTestController(client *fakeClient, ops *fakeCluster) *Controller {
+	return &Controller{
+		Client: client,
+		NewCluster: func(p *kismatic.Plan, opts kismatic.Options) (kismatic.ClusterV2, error) {
+			return ops, nil
+		},
+		now: func() time.Time { return time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC) },
+	}
+}
+
+func TestReconcileInstallsAndUpgrades(t *testing.T) {
+	client := &fakeClient{}
+	ops := &fakeCluster{}
+	c := newTestController(client, ops)
+	cluster := &Cluster{
+		Metadata: ObjectMeta{Name: "test", Namespace: "default", Generation: 1},
+		Spec:     ClusterSpec{Plan: testPlan},
+	}
+	if err := c.Reconcile(context.Background(), cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(client.statuses) != 2 || client.statuses[0].Phase != PhaseInstalling {
+		t.Fatalf("expected the cluster to be reported as installing, got %+v", client.statuses)
+	}
+	if cluster.Status.Phase != PhaseReady || cluster.Status.Version != "v1.10.3" || cluster.Status.ObservedGeneration != 1 {
+		t.Errorf("unexpected status %+v", cluster.Status)
+	}
+	if cond := cluster.Status.condition(ConditionReady); cond == nil || cond.Status != ConditionTrue {
+		t.Errorf("expected the cluster to be ready, got %+v", cond)
+	}
+
+	// The events caused by the status updates do not run the operations again
+	if err := c.Reconcile(context.Background(), cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ops.operations) != 1 {
+		t.Errorf("expected a single operation, got %v", ops.operations)
+	}
+
+	// A new version of Kubernetes in the plan upgrades the cluster
+	cluster.Metadata.Generation = 2
+	cluster.Spec.Plan = testPlan + "\n"
+	cluster.Status.Version = "v1.9.6"
+	if err := c.Reconcile(context.Background(), cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ops.operations) != 2 || ops.operations[1] != "upgrade" {
+		t.Errorf("expected the cluster to be upgraded, got %v", ops.operations)
+	}
+	if cluster.Status.Version != "v1.10.3" {
+		t.Errorf("expected the version to be updated, got %q", cluster.Status.Version)
+	}
+}
+
+func TestReconcileReportsFailures(t *testing.T) {
+	client := &fakeClient{}
+	ops := &fakeCluster{err: errors.New("playbook failed")}
+	c := newTestController(client, ops)
+	cluster := &Cluster{
+		Metadata: ObjectMeta{Name: "test", Generation: 1},
+		Spec:     ClusterSpec{Plan: testPlan},
+	}
+	if err := c.Reconcile(context.Background(), cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cond := cluster.Status.condition(ConditionReady)
+	if cluster.Status.Phase != PhaseFailed || cond == nil || cond.Reason != "InstallFailed" || cond.Message != "playbook failed" {
+		t.Errorf("expected the failure to be reported, got %+v", cluster.Status)
+	}
+
+	// An invalid plan is reported without running any operation
+	cluster = &Cluster{
+		Metadata: ObjectMeta{Name: "invalid", Generation: 1},
+		Spec:     ClusterSpec{Plan: "cluster:\n  name: invalid\n"},
+	}
+	if err := c.Reconcile(context.Background(), cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cond = cluster.Status.condition(ConditionValid)
+	if cluster.Status.Phase != PhaseFailed || cond == nil || cond.Status != ConditionFalse {
+		t.Errorf("expected the plan to be reported as invalid, got %+v", cluster.Status)
+	}
+	if len(ops.operations) != 1 {
+		t.Errorf("expected no operation on the invalid plan, got %v", ops.operations)
+	}
+}
+
+func TestNeedsApplyAfterRestart(t *testing.T) {
+	tests := []struct {
+		status   ClusterStatus
+		expected bool
+	}{
+		{status: ClusterStatus{}, expected: true},
+		{status: ClusterStatus{Phase: PhaseReady, ObservedGeneration: 2}, expected: false},
+		{status: ClusterStatus{Phase: PhaseFailed, ObservedGeneration: 2}, expected: false},
+		{status: ClusterStatus{Phase: PhaseReady, ObservedGeneration: 1}, expected: true},
+		// the previous instance stopped while installing the cluster
+		{status: ClusterStatus{Phase: PhaseInstalling, ObservedGeneration: 2}, expected: true},
+	}
+	for _, test := range tests {
+		c := &Controller{}
+		cluster := &Cluster{Metadata: ObjectMeta{Name: "test", Generation: 2}, Status: test.status}
+		if got := c.needsApply(cluster); got != test.expected {
+			t.Errorf("status %+v: expected %v, got %v", test.status, test.expected, got)
+		}
+	}
+}
+
+func TestSetConditionKeepsTransitionTime(t *testing.T) {
+	first := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := &ClusterStatus{}
+	s.setCondition(Condition{Type: ConditionReady, Status: ConditionFalse, Reason: "InstallStarted"}, first)
+	s.setCondition(Condition{Type: ConditionReady, Status: ConditionFalse, Reason: "InstallFailed"}, first.Add(time.Hour))
+	if c := s.condition(ConditionReady); c.LastTransitionTime != first || c.Reason != "InstallFailed" {
+		t.Errorf("expected the transition time to be kept, got %+v", c)
+	}
+	s.setCondition(Condition{Type: ConditionReady, Status: ConditionTrue}, first.Add(2*time.Hour))
+	if c := s.condition(ConditionReady); c.LastTransitionTime != first.Add(2*time.Hour) {
+		t.Errorf("expected the transition time to be updated, got %+v", c)
+	}
+	if len(s.Conditions) != 1 {
+		t.Errorf("expected a single condition, got %+v", s.Conditions)
+	}
+}
@@ -0,0 +1,142 @@
+// Package operator implements a Kubernetes controller that installs and
+// upgrades the clusters described by Cluster custom resources, using the
+// kismatic SDK.
+package operator
+
+import (
+	"time"
+)
+
+const (
+	// Group is the API group of the Cluster custom resource
+	Group = "kismatic.apprenda.com"
+	// Version is the API version of the Cluster custom resource
+	Version = "v1alpha1"
+	// Resource is the plural name of the Cluster custom resource
+	Resource = "clusters"
+	// Kind of the Cluster custom resource
+	Kind = "Cluster"
+)
+
+// Phases of a cluster, reported in its status
+const (
+	PhasePending    = "Pending"
+	PhaseInstalling = "Installing"
+	PhaseUpgrading  = "Upgrading"
+	PhaseReady      = "Ready"
+	PhaseFailed     = "Failed"
+)
+
+// Types of the conditions of a cluster
+const (
+	// ConditionValid is true when the plan of the cluster is valid
+	ConditionValid = "Valid"
+	// ConditionProgressing is true while an operation runs on the cluster
+	ConditionProgressing = "Progressing"
+	// ConditionReady is true when the cluster matches the plan
+	ConditionReady = "Ready"
+)
+
+// Statuses of a condition
+const (
+	ConditionTrue  = "True"
+	ConditionFalse = "False"
+)
+
+// Cluster is a cluster managed by kismatic
+type Cluster struct {
+	APIVersion string        `json:"apiVersion,omitempty"`
+	Kind       string        `json:"kind,omitempty"`
+	Metadata   ObjectMeta    `json:"metadata"`
+	Spec       ClusterSpec   `json:"spec"`
+	Status     ClusterStatus `json:"status,omitempty"`
+}
+
+// ObjectMeta is the subset of the Kubernetes object metadata used by the
+// operator
+type ObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Generation      int64  `json:"generation,omitempty"`
+}
+
+// ClusterSpec is the desired state of the cluster
+type ClusterSpec struct {
+	// Plan is the plan file of the cluster, in YAML
+	Plan string `json:"plan"`
+}
+
+// ClusterStatus is the observed state of the cluster
+type ClusterStatus struct {
+	// Phase of the cluster
+	Phase string `json:"phase,omitempty"`
+	// ObservedGeneration is the generation of the spec that was last applied
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Version is the Kubernetes version the cluster was last installed or
+	// upgraded to
+	Version string `json:"version,omitempty"`
+	// Conditions of the cluster
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// Condition describes an aspect of the state of the cluster
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// ClusterList is a list of clusters
+type ClusterList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Items []Cluster `json:"items"`
+}
+
+// WatchEvent is a change to a cluster
+type WatchEvent struct {
+	// Type is ADDED, MODIFIED, DELETED or ERROR
+	Type   string  `json:"type"`
+	Object Cluster `json:"object"`
+}
+
+// key identifies the cluster among all the clusters
+func (c Cluster) key() string {
+	if c.Metadata.Namespace == "" {
+		return c.Metadata.Name
+	}
+	return c.Metadata.Namespace + "/" + c.Metadata.Name
+}
+
+// condition returns the condition of the given type, or nil if the cluster
+// does not have it
+func (s ClusterStatus) condition(condType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == condType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setCondition adds or updates the condition. The transition time is only
+// updated when the status of the condition changes.
+func (s *ClusterStatus) setCondition(c Condition, now time.Time) {
+	existing := s.condition(c.Type)
+	if existing == nil {
+		c.LastTransitionTime = now
+		s.Conditions = append(s.Conditions, c)
+		return
+	}
+	if existing.Status != c.Status {
+		existing.LastTransitionTime = now
+	}
+	existing.Status = c.Status
+	existing.Reason = c.Reason
+	existing.Message = c.Message
+}
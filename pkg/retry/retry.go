@@ -1,6 +1,10 @@
 package retry
 
-import "time"
+import (
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+)
 
 type retryMethod int
 
@@ -38,6 +42,7 @@ func retry(fn func() error, retries uint, method retryMethod) error {
 		case linear:
 			sleep = 1 * time.Second
 		}
+		logging.Warn("retrying after error", "error", err, "attempt", attempts+1, "retries", retries, "wait", sleep)
 		time.Sleep(sleep)
 		attempts++
 	}
@@ -0,0 +1,153 @@
+// Package scheduler runs recurring maintenance operations, such as the
+// collection of diagnostics, the backup of etcd or the audit of the
+// expiration of the certificates, against one or more clusters.
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config lists the clusters and the recurring jobs that are run against them
+type Config struct {
+	Clusters []Cluster `yaml:"clusters"`
+	Jobs     []Job     `yaml:"jobs"`
+	// Notify is run when a job fails
+	Notify Notify `yaml:"notify"`
+}
+
+// Cluster is a cluster managed by kismatic
+type Cluster struct {
+	Name string `yaml:"name"`
+	// PlanFile is the plan file of the cluster
+	PlanFile string `yaml:"plan_file"`
+	// GeneratedAssetsDirectory is where the assets of the cluster were
+	// generated. Defaults to "generated".
+	GeneratedAssetsDirectory string `yaml:"generated_assets_dir"`
+	// RunsDirectory is where the runs of the jobs are recorded. Defaults to
+	// "runs".
+	RunsDirectory string `yaml:"runs_dir"`
+}
+
+// Job is an operation that is run on a schedule
+type Job struct {
+	Name string `yaml:"name"`
+	// Schedule is a cron expression
+	Schedule string `yaml:"schedule"`
+	// Operation is the name of the operation that is run
+	Operation string `yaml:"operation"`
+	// Clusters are the names of the clusters the job is run against. The job
+	// is run against all the clusters when empty.
+	Clusters []string `yaml:"clusters"`
+	// ExpiryDays is the number of days before their expiration at which the
+	// certificates are reported by the certificates audit. Defaults to 30.
+	ExpiryDays int `yaml:"expiry_days"`
+}
+
+// Notify is the notification of the failed jobs
+type Notify struct {
+	// Command is run with sh when a job fails, with the KISMATIC_JOB,
+	// KISMATIC_CLUSTER, KISMATIC_OPERATION, KISMATIC_ERROR and
+	// KISMATIC_RUN_DIR environment variables
+	Command string `yaml:"command"`
+}
+
+// ReadConfig reads the configuration file, and sets the defaults
+func ReadConfig(file string) (*Config, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scheduler configuration %q: %v", file, err)
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("error parsing scheduler configuration %q: %v", file, err)
+	}
+	for i := range c.Clusters {
+		if c.Clusters[i].GeneratedAssetsDirectory == "" {
+			c.Clusters[i].GeneratedAssetsDirectory = "generated"
+		}
+		if c.Clusters[i].RunsDirectory == "" {
+			c.Clusters[i].RunsDirectory = "runs"
+		}
+	}
+	for i := range c.Jobs {
+		if c.Jobs[i].ExpiryDays == 0 {
+			c.Jobs[i].ExpiryDays = 30
+		}
+	}
+	return c, nil
+}
+
+// Validate the configuration against the operations that can be scheduled
+func (c *Config) Validate(operations map[string]Operation) error {
+	if len(c.Clusters) == 0 {
+		return fmt.Errorf("no clusters are defined")
+	}
+	clusters := map[string]bool{}
+	for _, cl := range c.Clusters {
+		if cl.Name == "" {
+			return fmt.Errorf("the name of the cluster with plan file %q is required", cl.PlanFile)
+		}
+		if clusters[cl.Name] {
+			return fmt.Errorf("the cluster %q is defined more than once", cl.Name)
+		}
+		if cl.PlanFile == "" {
+			return fmt.Errorf("the plan file of the cluster %q is required", cl.Name)
+		}
+		clusters[cl.Name] = true
+	}
+	jobs := map[string]bool{}
+	for _, j := range c.Jobs {
+		if j.Name == "" {
+			return fmt.Errorf("the name of the job with operation %q is required", j.Operation)
+		}
+		if jobs[j.Name] {
+			return fmt.Errorf("the job %q is defined more than once", j.Name)
+		}
+		jobs[j.Name] = true
+		if _, err := ParseSchedule(j.Schedule); err != nil {
+			return fmt.Errorf("job %q: %v", j.Name, err)
+		}
+		if _, ok := operations[j.Operation]; !ok {
+			return fmt.Errorf("job %q: operation %q is not supported. Options are %v", j.Name, j.Operation, operationNames(operations))
+		}
+		for _, name := range j.Clusters {
+			if !clusters[name] {
+				return fmt.Errorf("job %q: the cluster %q is not defined", j.Name, name)
+			}
+		}
+		if j.ExpiryDays < 0 {
+			return fmt.Errorf("job %q: expiry_days must be positive, got %d", j.Name, j.ExpiryDays)
+		}
+	}
+	return nil
+}
+
+// clusters returns the clusters the job is run against
+func (c *Config) clusters(j Job) []Cluster {
+	if len(j.Clusters) == 0 {
+		return c.Clusters
+	}
+	var clusters []Cluster
+	for _, cl := range c.Clusters {
+		for _, name := range j.Clusters {
+			if cl.Name == name {
+				clusters = append(clusters, cl)
+			}
+		}
+	}
+	return clusters
+}
+
+// operationNames returns the names of the operations, sorted
+func operationNames(operations map[string]Operation) []string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
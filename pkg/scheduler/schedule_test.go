@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@sometimes",
+	}
+	for _, expr := range tests {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("expected an error parsing %q", expr)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2018, time.June, 6, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.June, 6, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, time.June, 6, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2018, time.June, 7, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2018, time.June, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 3 * * 0", time.Date(2018, time.June, 10, 3, 0, 0, 0, time.UTC)},
+		{"0 3 * * 7", time.Date(2018, time.June, 10, 3, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2018, time.June, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 4 1 * *", time.Date(2018, time.July, 1, 4, 0, 0, 0, time.UTC)},
+		{"30 10 6 6 *", time.Date(2019, time.June, 6, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2018, time.June, 6, 13, 0, 0, 0, time.UTC)},
+		{"0,45 10 * * *", time.Date(2018, time.June, 6, 10, 45, 0, 0, time.UTC)},
+		// either the day of the month or the day of the week
+		{"0 0 15 * 5", time.Date(2018, time.June, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		s, err := ParseSchedule(test.expr)
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", test.expr, err)
+			continue
+		}
+		if next := s.Next(from); !next.Equal(test.expected) {
+			t.Errorf("%q: expected the next run at %v, but got %v", test.expr, test.expected, next)
+		}
+	}
+}
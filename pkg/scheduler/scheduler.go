@@ -0,0 +1,227 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/logging"
+)
+
+const (
+	// scheduledRunsDir is the directory of the runs directory of a cluster
+	// where the runs of the jobs are recorded
+	scheduledRunsDir = "scheduled"
+	resultFilename   = "result.json"
+	outputFilename   = "output.log"
+)
+
+// Operation runs a job against a cluster, writing its console output to out
+type Operation func(c Cluster, j Job, out io.Writer) error
+
+// Result is the outcome of a job on a cluster, recorded in its run directory
+type Result struct {
+	Job          string    `json:"job"`
+	Cluster      string    `json:"cluster"`
+	Operation    string    `json:"operation"`
+	StartTime    time.Time `json:"startTime"`
+	EndTime      time.Time `json:"endTime"`
+	Succeeded    bool      `json:"succeeded"`
+	Error        string    `json:"error,omitempty"`
+	RunDirectory string    `json:"runDirectory"`
+}
+
+// NextRun is the next time a job runs
+type NextRun struct {
+	Job  Job
+	Time time.Time
+}
+
+// Scheduler runs the jobs of the configuration on their schedule
+type Scheduler struct {
+	Config *Config
+	// Operations that can be run by the jobs, by name
+	Operations map[string]Operation
+	// Out receives a line for the start and the end of each job. The lines
+	// are discarded when nil.
+	Out io.Writer
+
+	now func() time.Time
+	// notify runs the notification command. Defaults to running it with sh.
+	notify func(command string, env []string) error
+}
+
+// NextRuns returns the next time each job runs after the given time, sorted
+// by time
+func (s *Scheduler) NextRuns(after time.Time) ([]NextRun, error) {
+	runs := []NextRun{}
+	for _, j := range s.Config.Jobs {
+		sched, err := ParseSchedule(j.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %v", j.Name, err)
+		}
+		runs = append(runs, NextRun{Job: j, Time: sched.Next(after)})
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, nil
+}
+
+// Run the jobs on their schedule until stop is closed. The jobs are run one
+// at a time, and the runs that are missed while another job is running are
+// skipped.
+func (s *Scheduler) Run(stop <-chan struct{}) error {
+	if err := s.Config.Validate(s.Operations); err != nil {
+		return err
+	}
+	if len(s.Config.Jobs) == 0 {
+		return fmt.Errorf("no jobs are defined")
+	}
+	next := map[string]time.Time{}
+	schedules := map[string]*Schedule{}
+	for _, j := range s.Config.Jobs {
+		schedules[j.Name], _ = ParseSchedule(j.Schedule)
+		next[j.Name] = schedules[j.Name].Next(s.clock())
+	}
+	for {
+		var earliest time.Time
+		for _, j := range s.Config.Jobs {
+			t := next[j.Name]
+			if !t.IsZero() && (earliest.IsZero() || t.Before(earliest)) {
+				earliest = t
+			}
+		}
+		if earliest.IsZero() {
+			return fmt.Errorf("none of the schedules of the jobs match a time in the next five years")
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(earliest.Sub(s.clock())):
+		}
+		for _, j := range s.Config.Jobs {
+			if next[j.Name].IsZero() || next[j.Name].After(earliest) {
+				continue
+			}
+			s.RunJob(j)
+			next[j.Name] = schedules[j.Name].Next(s.clock())
+		}
+	}
+}
+
+// RunJob runs the job against its clusters, one cluster at a time, and
+// records the results in the runs directory of each cluster. The notification
+// command is run for each cluster on which the job fails.
+func (s *Scheduler) RunJob(j Job) []Result {
+	var results []Result
+	for _, c := range s.Config.clusters(j) {
+		results = append(results, s.runJobOnCluster(j, c))
+	}
+	return results
+}
+
+func (s *Scheduler) runJobOnCluster(j Job, c Cluster) Result {
+	log := logging.With("job", j.Name, "cluster", c.Name, "operation", j.Operation)
+	start := s.clock()
+	r := Result{Job: j.Name, Cluster: c.Name, Operation: j.Operation, StartTime: start}
+	s.printf("%s: running job %q on cluster %q\n", start.Format(time.RFC3339), j.Name, c.Name)
+	log.Info("running scheduled job")
+
+	err := s.run(j, c, &r)
+	r.EndTime = s.clock()
+	r.Succeeded = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if r.RunDirectory != "" {
+		if werr := writeResult(r); werr != nil {
+			log.Warn("error recording the result of the job", "error", werr)
+		}
+	}
+	if err == nil {
+		log.Info("scheduled job succeeded", "duration", r.EndTime.Sub(start))
+		s.printf("%s: job %q succeeded on cluster %q\n", r.EndTime.Format(time.RFC3339), j.Name, c.Name)
+		return r
+	}
+	log.Error("scheduled job failed", "error", err, "duration", r.EndTime.Sub(start))
+	s.printf("%s: job %q failed on cluster %q: %v\n", r.EndTime.Format(time.RFC3339), j.Name, c.Name, err)
+	if nerr := s.sendNotification(r); nerr != nil {
+		log.Error("error notifying the failure of the job", "error", nerr)
+		s.printf("Could not notify the failure of the job %q: %v\n", j.Name, nerr)
+	}
+	return r
+}
+
+// run the operation of the job, with its output in the run directory
+func (s *Scheduler) run(j Job, c Cluster, r *Result) error {
+	op, ok := s.Operations[j.Operation]
+	if !ok {
+		return fmt.Errorf("operation %q is not supported", j.Operation)
+	}
+	dir := filepath.Join(c.RunsDirectory, scheduledRunsDir, j.Name, r.StartTime.Format("2006-01-02-15-04-05"))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return fmt.Errorf("error creating run directory: %v", err)
+	}
+	r.RunDirectory = dir
+	f, err := os.Create(filepath.Join(dir, outputFilename))
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	defer f.Close()
+	return op(c, j, f)
+}
+
+func writeResult(r Result) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling result: %v", err)
+	}
+	file := filepath.Join(r.RunDirectory, resultFilename)
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("error writing result to %s: %v", file, err)
+	}
+	return nil
+}
+
+// sendNotification runs the notification command of the configuration, if
+// any, for the failed job
+func (s *Scheduler) sendNotification(r Result) error {
+	command := s.Config.Notify.Command
+	if command == "" {
+		return nil
+	}
+	env := []string{
+		"KISMATIC_JOB=" + r.Job,
+		"KISMATIC_CLUSTER=" + r.Cluster,
+		"KISMATIC_OPERATION=" + r.Operation,
+		"KISMATIC_ERROR=" + r.Error,
+		"KISMATIC_RUN_DIR=" + r.RunDirectory,
+	}
+	if s.notify != nil {
+		return s.notify(command, env)
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running %q: %v: %s", command, err, out)
+	}
+	return nil
+}
+
+func (s *Scheduler) printf(format string, a ...interface{}) {
+	if s.Out != nil {
+		fmt.Fprintf(s.Out, format, a...)
+	}
+}
+
+func (s *Scheduler) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "schedule.yaml")
+	config := `clusters:
+- name: prod
+  plan_file: prod.yaml
+  runs_dir: prod-runs
+jobs:
+- name: nightly-diagnostics
+  schedule: "0 2 * * *"
+  operation: diagnose
+- name: monthly-certificates
+  schedule: "@monthly"
+  operation: certificates-audit
+  clusters: [prod]
+notify:
+  command: echo failed
+`
+	if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	c, err := ReadConfig(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &Config{
+		Clusters: []Cluster{{Name: "prod", PlanFile: "prod.yaml", GeneratedAssetsDirectory: "generated", RunsDirectory: "prod-runs"}},
+		Jobs: []Job{
+			{Name: "nightly-diagnostics", Schedule: "0 2 * * *", Operation: "diagnose", ExpiryDays: 30},
+			{Name: "monthly-certificates", Schedule: "@monthly", Operation: "certificates-audit", Clusters: []string{"prod"}, ExpiryDays: 30},
+		},
+		Notify: Notify{Command: "echo failed"},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, c)
+	}
+	ops := map[string]Operation{"diagnose": nil, "certificates-audit": nil}
+	if err := c.Validate(ops); err != nil {
+		t.Errorf("unexpected error validating the config: %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	ops := map[string]Operation{"diagnose": nil}
+	valid := func() *Config {
+		return &Config{
+			Clusters: []Cluster{{Name: "prod", PlanFile: "prod.yaml"}},
+			Jobs:     []Job{{Name: "diag", Schedule: "@daily", Operation: "diagnose"}},
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+	}{
+		{"no clusters", func(c *Config) { c.Clusters = nil }},
+		{"cluster without name", func(c *Config) { c.Clusters[0].Name = "" }},
+		{"cluster without plan", func(c *Config) { c.Clusters[0].PlanFile = "" }},
+		{"duplicate cluster", func(c *Config) { c.Clusters = append(c.Clusters, c.Clusters[0]) }},
+		{"duplicate job", func(c *Config) { c.Jobs = append(c.Jobs, c.Jobs[0]) }},
+		{"invalid schedule", func(c *Config) { c.Jobs[0].Schedule = "every day" }},
+		{"unsupported operation", func(c *Config) { c.Jobs[0].Operation = "upgrade" }},
+		{"unknown cluster", func(c *Config) { c.Jobs[0].Clusters = []string{"staging"} }},
+	}
+	if err := valid().Validate(ops); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, test := range tests {
+		c := valid()
+		test.modify(c)
+		if err := c.Validate(ops); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Date(2018, time.June, 6, 2, 0, 0, 0, time.UTC)
+	var notified [][]string
+	s := &Scheduler{
+		Config: &Config{
+			Clusters: []Cluster{
+				{Name: "prod", PlanFile: "prod.yaml", RunsDirectory: filepath.Join(dir, "prod")},
+				{Name: "staging", PlanFile: "staging.yaml", RunsDirectory: filepath.Join(dir, "staging")},
+				{Name: "dev", PlanFile: "dev.yaml", RunsDirectory: filepath.Join(dir, "dev")},
+			},
+			Notify: Notify{Command: "page"},
+		},
+		Operations: map[string]Operation{
+			"diagnose": func(c Cluster, j Job, out io.Writer) error {
+				fmt.Fprintf(out, "diagnosing %s", c.PlanFile)
+				if c.Name == "staging" {
+					return errors.New("node unreachable")
+				}
+				return nil
+			},
+		},
+		now: func() time.Time { return now },
+		notify: func(command string, env []string) error {
+			notified = append(notified, append([]string{command}, env...))
+			return nil
+		},
+	}
+	job := Job{Name: "nightly", Schedule: "0 2 * * *", Operation: "diagnose", Clusters: []string{"prod", "staging"}}
+	results := s.RunJob(job)
+	if len(results) != 2 {
+		t.Fatalf("expected the job to run on 2 clusters, but got %+v", results)
+	}
+	if !results[0].Succeeded || results[1].Succeeded || results[1].Error != "node unreachable" {
+		t.Errorf("unexpected results %+v", results)
+	}
+	for _, r := range results {
+		expectedDir := filepath.Join(dir, r.Cluster, "scheduled", "nightly", "2018-06-06-02-00-00")
+		if r.RunDirectory != expectedDir {
+			t.Errorf("expected the run directory %q, but got %q", expectedDir, r.RunDirectory)
+		}
+		out, err := ioutil.ReadFile(filepath.Join(r.RunDirectory, "output.log"))
+		if err != nil || !strings.Contains(string(out), "diagnosing "+r.Cluster) {
+			t.Errorf("expected the output of the operation to be recorded, got %q (%v)", out, err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(r.RunDirectory, "result.json"))
+		if err != nil {
+			t.Fatalf("error reading the result: %v", err)
+		}
+		var recorded Result
+		if err := json.Unmarshal(b, &recorded); err != nil {
+			t.Fatalf("error unmarshaling the result: %v", err)
+		}
+		if recorded.Succeeded != r.Succeeded || recorded.Job != "nightly" {
+			t.Errorf("unexpected recorded result %+v", recorded)
+		}
+	}
+	if len(notified) != 1 {
+		t.Fatalf("expected one notification, but got %v", notified)
+	}
+	for _, v := range []string{"page", "KISMATIC_CLUSTER=staging", "KISMATIC_JOB=nightly", "KISMATIC_ERROR=node unreachable"} {
+		found := false
+		for _, n := range notified[0] {
+			found = found || n == v
+		}
+		if !found {
+			t.Errorf("expected %q in the notification %v", v, notified[0])
+		}
+	}
+}
+
+func TestNextRuns(t *testing.T) {
+	s := &Scheduler{Config: &Config{Jobs: []Job{
+		{Name: "weekly", Schedule: "@weekly"},
+		{Name: "nightly", Schedule: "0 2 * * *"},
+	}}}
+	runs, err := s.NextRuns(time.Date(2018, time.June, 6, 10, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Job.Name != "nightly" || !runs[0].Time.Equal(time.Date(2018, time.June, 7, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next runs %+v", runs)
+	}
+}
@@ -0,0 +1,3 @@
+// Package selfupdate provides the functionality for checking for new
+// Kismatic releases and replacing the running installation with them.
+package selfupdate
@@ -0,0 +1,99 @@
+package selfupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+)
+
+const (
+	// DefaultReleaseChannel is the URL that is queried for the latest published release
+	DefaultReleaseChannel = "https://api.github.com/repos/apprenda/kismatic/releases/latest"
+	// DefaultDownloadURL is the base URL used for downloading release artifacts
+	DefaultDownloadURL = "https://github.com/apprenda/kismatic/releases/download"
+)
+
+var httpTimeout = 30 * time.Second
+
+// Release is a published version of Kismatic that can be downloaded
+type Release struct {
+	// Version of the release
+	Version semver.Version
+	// Tag that identifies the release in the release channel
+	Tag string
+	// ArtifactURL is the location of the release tarball
+	ArtifactURL string
+	// ChecksumURL is the location of the SHA256 checksum of the release tarball
+	ChecksumURL string
+	// SignatureURL is the location of the detached ECDSA signature of the
+	// SHA256 digest of the release tarball
+	SignatureURL string
+}
+
+// Channel is a source of Kismatic releases
+type Channel struct {
+	// URL that returns the latest release in the channel
+	URL string
+	// DownloadURL is the base URL for downloading release artifacts
+	DownloadURL string
+	// OS of the artifacts. Defaults to the OS of the running binary.
+	OS string
+}
+
+type latestRelease struct {
+	TagName string `json:"tag_name"`
+}
+
+// Latest queries the channel and returns the most recent release
+func (c Channel) Latest() (*Release, error) {
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Get(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error querying release channel: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release channel returned unexpected status code %d", resp.StatusCode)
+	}
+	lr := latestRelease{}
+	if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil {
+		return nil, fmt.Errorf("error decoding release channel response: %v", err)
+	}
+	return c.Release(lr.TagName)
+}
+
+// Release returns the release identified by the given tag
+func (c Channel) Release(tag string) (*Release, error) {
+	ver, err := parseVersion(tag)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(tag, "v") {
+		tag = "v" + tag
+	}
+	goos := c.OS
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	artifact := fmt.Sprintf("%s/%s/kismatic-%s-%s-amd64.tar.gz", strings.TrimSuffix(c.DownloadURL, "/"), tag, tag, goos)
+	return &Release{
+		Version:      ver,
+		Tag:          tag,
+		ArtifactURL:  artifact,
+		ChecksumURL:  artifact + ".sha256",
+		SignatureURL: artifact + ".sig",
+	}, nil
+}
+
+func parseVersion(s string) (semver.Version, error) {
+	v, err := semver.Make(strings.TrimPrefix(strings.TrimSpace(s), "v"))
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("invalid release version %q: %v", s, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,80 @@
+package selfupdate
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+)
+
+// ReleasePublicKey is the base64 encoded, DER encoded ECDSA public key that
+// signs the release artifacts. It is embedded in the binary with the linker
+// flag -X github.com/apprenda/kismatic/pkg/selfupdate.ReleasePublicKey=<key>
+var ReleasePublicKey string
+
+// maxSignatureSize is the maximum size of the signature of a release artifact
+const maxSignatureSize = 1024
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature, as written by
+// openssl dgst -sign
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// parsePublicKey returns the ECDSA public key encoded in the string
+func parsePublicKey(encoded string) (*ecdsa.PublicKey, error) {
+	if encoded == "" {
+		return nil, errors.New("this build of kismatic does not embed the public key that signs the releases, the release cannot be verified")
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the release public key: %v", err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the release public key: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("the release public key is a %T, not an ECDSA key", key)
+	}
+	return ecKey, nil
+}
+
+// verifySignature returns an error if the signature of the SHA256 digest of
+// the artifact was not made by the private key of the public key
+func verifySignature(publicKey *ecdsa.PublicKey, digest []byte, signature []byte) error {
+	sig := ecdsaSignature{}
+	rest, err := asn1.Unmarshal(signature, &sig)
+	if err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+		return errors.New("the signature is not a valid ECDSA signature")
+	}
+	if !ecdsa.Verify(publicKey, digest, sig.R, sig.S) {
+		return errors.New("the signature does not match the release public key")
+	}
+	return nil
+}
+
+// fetchSignature returns the detached signature published at the URL
+func fetchSignature(url string) ([]byte, error) {
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading signature %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading signature %s: unexpected status code %d", url, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading signature %s: %v", url, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,303 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Updater replaces an installation of Kismatic with a given release
+type Updater struct {
+	// InstallDir is the directory that contains the kismatic binary
+	// and the bundled assets (ansible, inspector, kuberang, etc.)
+	InstallDir string
+	// PublicKey is the base64 encoded, DER encoded ECDSA public key that
+	// signs the releases, such as ReleasePublicKey
+	PublicKey string
+	// Out is where progress messages are written
+	Out io.Writer
+}
+
+// Update downloads the release, verifies its checksum and its signature, and
+// swaps the contents of the installation directory with the contents of the
+// release. If any entry fails to be swapped, the entries that were already
+// replaced are restored. A RollbackError is returned when they cannot be
+// restored, and the previous installation is then kept in its backup
+// directory.
+func (u Updater) Update(r *Release) error {
+	publicKey, err := parsePublicKey(u.PublicKey)
+	if err != nil {
+		return err
+	}
+	staging, err := ioutil.TempDir(u.InstallDir, ".kismatic-update-")
+	if err != nil {
+		return fmt.Errorf("error creating staging directory: %v", err)
+	}
+	keepStaging := false
+	defer func() {
+		if !keepStaging {
+			os.RemoveAll(staging)
+		}
+	}()
+
+	fmt.Fprintf(u.Out, "Downloading %s\n", r.ArtifactURL)
+	tarball := filepath.Join(staging, "kismatic.tar.gz")
+	sum, err := download(r.ArtifactURL, tarball)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(u.Out, "Verifying checksum %s\n", r.ChecksumURL)
+	expected, err := fetchChecksum(r.ChecksumURL)
+	if err != nil {
+		return err
+	}
+	if sum != expected {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", r.ArtifactURL, expected, sum)
+	}
+	// The checksum is published along with the artifact, the signature
+	// proves that the artifact was published by the Kismatic maintainers
+	fmt.Fprintf(u.Out, "Verifying signature %s\n", r.SignatureURL)
+	signature, err := fetchSignature(r.SignatureURL)
+	if err != nil {
+		return err
+	}
+	digest, err := hex.DecodeString(sum)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(publicKey, digest, signature); err != nil {
+		return fmt.Errorf("error verifying the signature of %s: %v", r.ArtifactURL, err)
+	}
+
+	contents := filepath.Join(staging, "contents")
+	if err := extract(tarball, contents); err != nil {
+		return fmt.Errorf("error extracting release: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(contents, "kismatic")); err != nil {
+		return fmt.Errorf("release artifact does not contain the kismatic binary")
+	}
+
+	fmt.Fprintf(u.Out, "Installing %s into %s\n", r.Tag, u.InstallDir)
+	err = swap(contents, u.InstallDir, filepath.Join(staging, "backup"))
+	if _, ok := err.(RollbackError); ok {
+		keepStaging = true
+	}
+	return err
+}
+
+// download writes the contents of the URL to the file, and returns
+// the hex encoded SHA256 of the downloaded bytes
+func download(url, file string) (string, error) {
+	client := http.Client{Timeout: httpTimeout * 20}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading %s: unexpected status code %d", url, resp.StatusCode)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return "", fmt.Errorf("error creating %s: %v", file, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		return "", fmt.Errorf("error downloading %s: %v", url, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fetchChecksum returns the checksum published at the URL. Both the plain
+// format and the sha256sum format ("<checksum>  <filename>") are supported.
+func fetchChecksum(url string) (string, error) {
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("error downloading checksum %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading checksum %s: unexpected status code %d", url, resp.StatusCode)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("error reading checksum %s: %v", url, err)
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file %s is empty", url)
+	}
+	sum := strings.ToLower(fields[0])
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("checksum file %s does not contain a valid SHA256 checksum", url)
+	}
+	return sum, nil
+}
+
+// extract writes the entries of the tarball to the destination. Entries
+// whose path, or the target of whose link, is outside the destination are
+// rejected, as are entries written through a link that leads outside of it.
+func extract(tarball, dest string) error {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	dest = filepath.Clean(dest)
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, hdr.Name)
+		// Guard against entries that attempt to escape the destination
+		if !within(dest, target) {
+			return fmt.Errorf("invalid path %q in release artifact", hdr.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		// Guard against entries written through a link of a previous entry
+		parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+		if err != nil {
+			return err
+		}
+		if !within(root, parent) {
+			return fmt.Errorf("invalid path %q in release artifact, it is written through a link outside of the release", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || !within(dest, filepath.Join(filepath.Dir(target), hdr.Linkname)) {
+				return fmt.Errorf("invalid link %q to %q in release artifact", hdr.Name, hdr.Linkname)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// within returns true if the path is the directory or is inside of it
+func within(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// rename is replaced by the tests to simulate failures
+var rename = os.Rename
+
+// RollbackError is returned when the installation cannot be restored after
+// the release failed to be installed
+type RollbackError struct {
+	// Err is the error that made the update fail
+	Err error
+	// Failures are the errors restoring the entries of the installation
+	Failures []string
+	// BackupDir keeps the entries of the installation that were not restored
+	BackupDir string
+}
+
+func (e RollbackError) Error() string {
+	return fmt.Sprintf("%v. The installation could not be restored, the previous installation was kept in %s: %s", e.Err, e.BackupDir, strings.Join(e.Failures, "; "))
+}
+
+// swap moves the top-level entries of src into dest. Existing entries in dest
+// are moved to the backup directory, and restored if any of the moves fail.
+// A RollbackError is returned if they cannot be restored.
+func swap(src, dest, backup string) error {
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("error reading release contents: %v", err)
+	}
+	if err := os.MkdirAll(backup, 0700); err != nil {
+		return fmt.Errorf("error creating backup directory: %v", err)
+	}
+	type moved struct {
+		name      string
+		hadBackup bool
+		installed bool
+	}
+	var done []moved
+	rollback := func(cause error) error {
+		var failures []string
+		for i := len(done) - 1; i >= 0; i-- {
+			m := done[i]
+			if m.installed {
+				if err := os.RemoveAll(filepath.Join(dest, m.name)); err != nil {
+					failures = append(failures, fmt.Sprintf("error removing %q: %v", m.name, err))
+					continue
+				}
+			}
+			if m.hadBackup {
+				if err := rename(filepath.Join(backup, m.name), filepath.Join(dest, m.name)); err != nil {
+					failures = append(failures, fmt.Sprintf("error restoring %q: %v", m.name, err))
+				}
+			}
+		}
+		if len(failures) > 0 {
+			return RollbackError{Err: cause, Failures: failures, BackupDir: backup}
+		}
+		return cause
+	}
+	for _, e := range entries {
+		name := e.Name()
+		m := moved{name: name}
+		if _, err := os.Lstat(filepath.Join(dest, name)); err == nil {
+			if err := rename(filepath.Join(dest, name), filepath.Join(backup, name)); err != nil {
+				return rollback(fmt.Errorf("error backing up %q: %v", name, err))
+			}
+			m.hadBackup = true
+		}
+		if err := rename(filepath.Join(src, name), filepath.Join(dest, name)); err != nil {
+			// the backup of the entry is restored along with the others
+			done = append(done, m)
+			return rollback(fmt.Errorf("error installing %q: %v", name, err))
+		}
+		m.installed = true
+		done = append(done, m)
+	}
+	return nil
+}
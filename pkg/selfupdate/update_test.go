@@ -0,0 +1,338 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func releaseTarball(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(contents)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("error writing tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatalf("error writing tar contents: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// signingKey returns a new signing key, and its public key as it is embedded
+// in the binary
+func signingKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("error marshaling public key: %v", err)
+	}
+	return key, base64.StdEncoding.EncodeToString(der)
+}
+
+// sign returns the signature of the tarball, as written by openssl dgst -sign
+func sign(t *testing.T, key *ecdsa.PrivateKey, tarball []byte) []byte {
+	digest := sha256.Sum256(tarball)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("error signing: %v", err)
+	}
+	sig, err := asn1.Marshal(ecdsaSignature{R: r, S: s})
+	if err != nil {
+		t.Fatalf("error marshaling signature: %v", err)
+	}
+	return sig
+}
+
+func releaseServer(tarball []byte, checksum string, signature []byte) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name": "v1.11.0"}`)
+	})
+	mux.HandleFunc("/download/v1.11.0/kismatic-v1.11.0-linux-amd64.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tarball)
+	})
+	mux.HandleFunc("/download/v1.11.0/kismatic-v1.11.0-linux-amd64.tar.gz.sha256", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  kismatic-v1.11.0-linux-amd64.tar.gz\n", checksum)
+	})
+	mux.HandleFunc("/download/v1.11.0/kismatic-v1.11.0-linux-amd64.tar.gz.sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(signature)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestChannelLatest(t *testing.T) {
+	s := releaseServer(nil, "", nil)
+	defer s.Close()
+	c := Channel{URL: s.URL + "/latest", DownloadURL: s.URL + "/download", OS: "linux"}
+	r, err := c.Latest()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Version.String() != "1.11.0" {
+		t.Errorf("expected version 1.11.0, got %s", r.Version)
+	}
+	expectedURL := s.URL + "/download/v1.11.0/kismatic-v1.11.0-linux-amd64.tar.gz"
+	if r.ArtifactURL != expectedURL {
+		t.Errorf("expected artifact URL %s, got %s", expectedURL, r.ArtifactURL)
+	}
+}
+
+func TestUpdateReplacesInstallation(t *testing.T) {
+	tarball := releaseTarball(t, map[string]string{
+		"kismatic":                "new-binary",
+		"ansible/playbooks/a.yml": "new-playbook",
+	})
+	sum := sha256.Sum256(tarball)
+	key, publicKey := signingKey(t)
+	s := releaseServer(tarball, hex.EncodeToString(sum[:]), sign(t, key, tarball))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "ansible", "playbooks"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "kismatic"), []byte("old-binary"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "ansible", "playbooks", "old.yml"), []byte("old-playbook"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "kismatic-cluster.yaml"), []byte("plan"), 0644)
+
+	c := Channel{URL: s.URL + "/latest", DownloadURL: s.URL + "/download", OS: "linux"}
+	r, _ := c.Latest()
+	u := Updater{InstallDir: dir, PublicKey: publicKey, Out: ioutil.Discard}
+	if err := u.Update(r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "kismatic"))
+	if string(b) != "new-binary" {
+		t.Errorf("expected binary to be replaced, got %q", string(b))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ansible", "playbooks", "old.yml")); !os.IsNotExist(err) {
+		t.Errorf("expected bundled assets to be replaced, but old playbook still exists")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kismatic-cluster.yaml")); err != nil {
+		t.Errorf("expected files not in the release to be left alone: %v", err)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("expected staging directory to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestUpdateChecksumMismatch(t *testing.T) {
+	tarball := releaseTarball(t, map[string]string{"kismatic": "new-binary"})
+	key, publicKey := signingKey(t)
+	s := releaseServer(tarball, "0000000000000000000000000000000000000000000000000000000000000000", sign(t, key, tarball))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "kismatic"), []byte("old-binary"), 0755)
+
+	c := Channel{URL: s.URL + "/latest", DownloadURL: s.URL + "/download", OS: "linux"}
+	r, _ := c.Latest()
+	u := Updater{InstallDir: dir, PublicKey: publicKey, Out: ioutil.Discard}
+	if err := u.Update(r); err == nil {
+		t.Fatal("expected an error due to checksum mismatch, but didn't get one")
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "kismatic"))
+	if string(b) != "old-binary" {
+		t.Errorf("expected binary to be left alone, got %q", string(b))
+	}
+}
+
+// writeTarball writes a tarball of the entries to a temporary directory
+func writeTarball(t *testing.T, entries []*tar.Header) string {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, hdr := range entries {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("error writing tar header: %v", err)
+		}
+		if hdr.Size > 0 {
+			tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size)))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	file := filepath.Join(dir, "release.tar.gz")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("error writing tarball: %v", err)
+	}
+	return file
+}
+
+func TestExtractRejectsEscapingLinks(t *testing.T) {
+	outside, err := ioutil.TempDir("", "selfupdate-outside")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(outside)
+	tests := []struct {
+		name    string
+		entries []*tar.Header
+	}{
+		{
+			name: "absolute link",
+			entries: []*tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside},
+				{Name: "a/x", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+			},
+		},
+		{
+			name: "relative link",
+			entries: []*tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "../.."},
+				{Name: "a/x", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		tarball := writeTarball(t, test.entries)
+		defer os.RemoveAll(filepath.Dir(tarball))
+		dest := filepath.Join(filepath.Dir(tarball), "contents")
+		if err := extract(tarball, dest); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "x")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no file to be written outside of the destination", test.name)
+		}
+	}
+}
+
+func TestExtractLinksInsideRelease(t *testing.T) {
+	tarball := writeTarball(t, []*tar.Header{
+		{Name: "bin/kismatic", Typeflag: tar.TypeReg, Mode: 0755, Size: 1},
+		{Name: "kismatic", Typeflag: tar.TypeSymlink, Linkname: "bin/kismatic"},
+	})
+	defer os.RemoveAll(filepath.Dir(tarball))
+	dest := filepath.Join(filepath.Dir(tarball), "contents")
+	if err := extract(tarball, dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "kismatic")); err != nil {
+		t.Errorf("expected the link to be extracted: %v", err)
+	}
+}
+
+func TestUpdateVerifiesSignature(t *testing.T) {
+	tarball := releaseTarball(t, map[string]string{"kismatic": "new-binary"})
+	sum := sha256.Sum256(tarball)
+	_, publicKey := signingKey(t)
+	otherKey, _ := signingKey(t)
+	tests := []struct {
+		name      string
+		publicKey string
+		signature []byte
+		err       string
+	}{
+		{
+			name:      "no public key",
+			signature: sign(t, otherKey, tarball),
+			err:       "does not embed the public key",
+		},
+		{
+			name:      "signed by another key",
+			publicKey: publicKey,
+			signature: sign(t, otherKey, tarball),
+			err:       "does not match the release public key",
+		},
+		{
+			name:      "invalid signature",
+			publicKey: publicKey,
+			signature: []byte("signature"),
+			err:       "not a valid ECDSA signature",
+		},
+	}
+	for _, test := range tests {
+		s := releaseServer(tarball, hex.EncodeToString(sum[:]), test.signature)
+		dir, err := ioutil.TempDir("", "selfupdate-test")
+		if err != nil {
+			t.Fatalf("error creating temp dir: %v", err)
+		}
+		ioutil.WriteFile(filepath.Join(dir, "kismatic"), []byte("old-binary"), 0755)
+		c := Channel{URL: s.URL + "/latest", DownloadURL: s.URL + "/download", OS: "linux"}
+		r, _ := c.Latest()
+		u := Updater{InstallDir: dir, PublicKey: test.publicKey, Out: ioutil.Discard}
+		if err := u.Update(r); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+		if b, _ := ioutil.ReadFile(filepath.Join(dir, "kismatic")); string(b) != "old-binary" {
+			t.Errorf("%s: expected binary to be left alone, got %q", test.name, string(b))
+		}
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSwapReportsRollbackErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selfupdate-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	src, dest, backup := filepath.Join(dir, "src"), filepath.Join(dir, "dest"), filepath.Join(dir, "backup")
+	for _, d := range []string{src, dest} {
+		os.MkdirAll(d, 0755)
+		ioutil.WriteFile(filepath.Join(d, "ansible"), []byte(d), 0644)
+		ioutil.WriteFile(filepath.Join(d, "kismatic"), []byte(d), 0755)
+	}
+	// installing the binary fails, and so does restoring the assets
+	defer func() { rename = os.Rename }()
+	rename = func(from, to string) error {
+		if from == filepath.Join(src, "kismatic") || from == filepath.Join(backup, "ansible") {
+			return errors.New("rename failed")
+		}
+		return os.Rename(from, to)
+	}
+	err = swap(src, dest, backup)
+	rerr, ok := err.(RollbackError)
+	if !ok {
+		t.Fatalf("expected a RollbackError, got %v", err)
+	}
+	if len(rerr.Failures) != 1 || !strings.Contains(rerr.Failures[0], `"ansible"`) || rerr.BackupDir != backup {
+		t.Errorf("unexpected rollback error %+v", rerr)
+	}
+	// the binary, whose backup was restored, is left as it was
+	if b, _ := ioutil.ReadFile(filepath.Join(dest, "kismatic")); string(b) != dest {
+		t.Errorf("expected the binary to be restored, got %q", string(b))
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(backup, "ansible")); string(b) != dest {
+		t.Errorf("expected the assets to be kept in the backup directory, got %q", string(b))
+	}
+}
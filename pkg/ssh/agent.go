@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AgentSocketEnv is the environment variable used by ssh to find the agent
+const AgentSocketEnv = "SSH_AUTH_SOCK"
+
+var inProcessAgent struct {
+	sync.Mutex
+	keyring agent.Agent
+	socket  string
+}
+
+// AddKeyToAgent adds the unencrypted private key to an SSH agent that runs in
+// the current process, so that the key is only held in memory. The agent is
+// started on the first call, and SSH_AUTH_SOCK is set so that the ssh
+// processes started by KET, including ansible, authenticate with the agent.
+func AddKeyToAgent(key []byte) error {
+	priv, err := ssh.ParseRawPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("Parse SSH key error: %v", err)
+	}
+	inProcessAgent.Lock()
+	defer inProcessAgent.Unlock()
+	if inProcessAgent.keyring == nil {
+		keyring := agent.NewKeyring()
+		socket, err := serveAgent(keyring)
+		if err != nil {
+			return err
+		}
+		inProcessAgent.keyring = keyring
+		inProcessAgent.socket = socket
+	}
+	if err := inProcessAgent.keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		return fmt.Errorf("error adding SSH key to the agent: %v", err)
+	}
+	return os.Setenv(AgentSocketEnv, inProcessAgent.socket)
+}
+
+// AuthorizedKey returns the public key of the private key file, in the
+// authorized_keys format. When the file is empty, the public key of the first
+// key held by the SSH agent is returned.
+func AuthorizedKey(file string) (string, error) {
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			return "", fmt.Errorf("Parse SSH key error: %v", err)
+		}
+		return authorizedKey(signer.PublicKey()), nil
+	}
+	socket := os.Getenv(AgentSocketEnv)
+	if socket == "" {
+		return "", fmt.Errorf("no SSH key file was provided, and %s is not set", AgentSocketEnv)
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to SSH agent: %v", err)
+	}
+	defer conn.Close()
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return "", fmt.Errorf("error listing the keys of the SSH agent: %v", err)
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("the SSH agent does not hold any keys")
+	}
+	return authorizedKey(keys[0]), nil
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// serveAgent listens for agent requests on a unix socket in a new directory
+// that is only accessible by the current user, and returns the socket path
+func serveAgent(keyring agent.Agent) (string, error) {
+	dir, err := ioutil.TempDir("", "kismatic-agent")
+	if err != nil {
+		return "", fmt.Errorf("error creating SSH agent directory: %v", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", fmt.Errorf("error setting permissions of SSH agent directory: %v", err)
+	}
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return "", fmt.Errorf("error starting SSH agent: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				agent.ServeAgent(keyring, c)
+			}(conn)
+		}
+	}()
+	return socket, nil
+}
@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAddKeyToAgent(t *testing.T) {
+	defer os.Unsetenv(AgentSocketEnv)
+	var key []byte
+	for _, data := range testData {
+		if !data.encrypted {
+			key = data.pemData
+			break
+		}
+	}
+	if err := AddKeyToAgent(key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	socket := os.Getenv(AgentSocketEnv)
+	if socket == "" {
+		t.Fatalf("expected %s to be set", AgentSocketEnv)
+	}
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("error getting the agent socket: %v", err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		t.Errorf("expected %q to be a socket", socket)
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("error connecting to the agent: %v", err)
+	}
+	defer conn.Close()
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		t.Fatalf("error listing the keys of the agent: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("expected the agent to hold 1 key, got %d", len(keys))
+	}
+
+	if err := AddKeyToAgent([]byte("not a key")); err == nil {
+		t.Errorf("expected an error with an invalid key")
+	}
+}
+
+func TestAuthorizedKey(t *testing.T) {
+	defer os.Unsetenv(AgentSocketEnv)
+	var key []byte
+	for _, data := range testData {
+		if !data.encrypted {
+			key = data.pemData
+			break
+		}
+	}
+	f, err := ioutil.TempFile("", "authorized-key")
+	if err != nil {
+		t.Fatalf("error creating key file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(key); err != nil {
+		t.Fatalf("error writing key file: %v", err)
+	}
+	f.Close()
+
+	fromFile, err := AuthorizedKey(f.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(fromFile, "ssh-") {
+		t.Errorf("expected an authorized key, got %q", fromFile)
+	}
+
+	if err := AddKeyToAgent(key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fromAgent, err := AuthorizedKey("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fromAgent != fromFile {
+		t.Errorf("expected the agent key %q to be %q", fromAgent, fromFile)
+	}
+}
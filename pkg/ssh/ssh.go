@@ -45,10 +45,13 @@ func TestConnection(ip string, port int, user, key string) error {
 	return client.Shell(false, "exit")
 }
 
-// NewClient verifies ssh is available in the PATH and returns an SSH client
+// NewClient verifies ssh is available in the PATH and returns an SSH client.
+// When the key is empty, the client authenticates with the keys of the SSH agent.
 func NewClient(host string, port int, user string, key string) (Client, error) {
-	if err := ValidUnencryptedPrivateKey(key); err != nil {
-		return nil, err
+	if key != "" {
+		if err := ValidUnencryptedPrivateKey(key); err != nil {
+			return nil, err
+		}
 	}
 
 	sshBinaryPath, err := exec.LookPath("ssh")
@@ -65,7 +68,9 @@ func newExternalClient(sshBinaryPath string, user string, host string, port int,
 	// set port
 	args = append(args, "-p", fmt.Sprintf("%d", port))
 	// set key
-	args = append(args, "-i", key)
+	if key != "" {
+		args = append(args, "-i", key)
+	}
 
 	client := &ExternalClient{
 		BinaryPath: sshBinaryPath,
@@ -35,15 +35,37 @@ func NewCert(ca *CA, req csr.CertificateRequest, expiry time.Duration) (key, cer
 	if err != nil {
 		return nil, nil, fmt.Errorf("error processing CSR: %v", err)
 	}
+	cert, err = signCSR(ca, csrBytes, expiry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// NewCertForKey creates a new certificate for an existing private key using the
+// CertificateAuthority provided
+func NewCertForKey(ca *CA, key []byte, req csr.CertificateRequest, expiry time.Duration) ([]byte, error) {
+	priv, err := helpers.ParsePrivateKeyPEM(key)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %v", err)
+	}
+	csrBytes, err := csr.Generate(priv, &req)
+	if err != nil {
+		return nil, fmt.Errorf("error processing CSR: %v", err)
+	}
+	return signCSR(ca, csrBytes, expiry)
+}
+
+func signCSR(ca *CA, csrBytes []byte, expiry time.Duration) ([]byte, error) {
 	// Get CA private key
 	caPriv, err := helpers.ParsePrivateKeyPEMWithPassword(ca.Key, []byte(ca.Password))
 	if err != nil {
-		return nil, nil, fmt.Errorf("error parsing private key: %v", err)
+		return nil, fmt.Errorf("error parsing private key: %v", err)
 	}
 	// Parse CA Cert
 	caCert, err := helpers.ParseCertificatePEM(ca.Cert)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error parsing CA cert: %v", err)
+		return nil, fmt.Errorf("error parsing CA cert: %v", err)
 	}
 	sigAlgo := signer.DefaultSigAlgo(caPriv)
 	// Build CA configuration
@@ -55,17 +77,17 @@ func NewCert(ca *CA, req csr.CertificateRequest, expiry time.Duration) (key, cer
 	// Create signer using CA
 	s, err := local.NewSigner(caPriv, caCert, sigAlgo, caConfig)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error creating signer: %v", err)
+		return nil, fmt.Errorf("error creating signer: %v", err)
 	}
 	// Generate cert using CA signer
 	signReq := signer.SignRequest{
 		Request: string(csrBytes),
 	}
-	cert, err = s.Sign(signReq)
+	cert, err := s.Sign(signReq)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error signing certificate: %v", err)
+		return nil, fmt.Errorf("error signing certificate: %v", err)
 	}
-	return key, cert, nil
+	return cert, nil
 }
 
 // WriteCert writes cert and key files
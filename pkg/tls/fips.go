@@ -0,0 +1,45 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/cloudflare/cfssl/helpers"
+)
+
+// MinFIPSRSAKeySize is the minimum size, in bits, of the RSA keys that are
+// allowed in FIPS mode
+const MinFIPSRSAKeySize = 2048
+
+// FIPSCompliantKey returns an error if the PEM encoded private key is not an
+// RSA key of at least MinFIPSRSAKeySize bits
+func FIPSCompliantKey(key []byte) error {
+	priv, err := helpers.ParsePrivateKeyPEMWithPassword(key, nil)
+	if err != nil {
+		return fmt.Errorf("error parsing private key: %v", err)
+	}
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		if size := k.N.BitLen(); size < MinFIPSRSAKeySize {
+			return fmt.Errorf("RSA key size is %d bits, must be at least %d bits", size, MinFIPSRSAKeySize)
+		}
+		return nil
+	case *ecdsa.PrivateKey:
+		return fmt.Errorf("ECDSA keys are not allowed, only RSA keys are allowed")
+	default:
+		return fmt.Errorf("%T keys are not allowed, only RSA keys are allowed", priv)
+	}
+}
+
+// KeyFIPSCompliant reads the private key with the given name in the provided
+// directory, and returns an error if it is not FIPS compliant
+func KeyFIPSCompliant(name, dir string) error {
+	key, err := ioutil.ReadFile(filepath.Join(dir, keyName(name)))
+	if err != nil {
+		return fmt.Errorf("error reading private key: %v", err)
+	}
+	return FIPSCompliantKey(key)
+}
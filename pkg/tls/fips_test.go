@@ -0,0 +1,49 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestFIPSCompliantKey(t *testing.T) {
+	rsaKey := func(bits int) []byte {
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatalf("error generating RSA key: %v", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)})
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating ECDSA key: %v", err)
+	}
+	ecBytes, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("error marshaling ECDSA key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		key   []byte
+		valid bool
+	}{
+		{name: "RSA 2048", key: rsaKey(2048), valid: true},
+		{name: "RSA 1024", key: rsaKey(1024), valid: false},
+		{name: "ECDSA", key: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecBytes}), valid: false},
+		{name: "invalid", key: []byte("not a key"), valid: false},
+	}
+	for _, test := range tests {
+		err := FIPSCompliantKey(test.key)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxQueuedSpans is the number of ended spans that triggers an export
+const maxQueuedSpans = 512
+
+const scopeName = "github.com/apprenda/kismatic/pkg/tracing"
+
+type tracer struct {
+	cfg    config
+	client *http.Client
+
+	mu    sync.Mutex
+	queue []*Span
+	// exportErr is the first error that occurred while exporting spans in
+	// the background
+	exportErr error
+	// background exports that are in progress
+	pending sync.WaitGroup
+}
+
+func newTracer(cfg config) *tracer {
+	return &tracer{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.timeout},
+	}
+}
+
+func (t *tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	t.queue = append(t.queue, s)
+	if len(t.queue) < maxQueuedSpans {
+		t.mu.Unlock()
+		return
+	}
+	batch := t.queue
+	t.queue = nil
+	t.mu.Unlock()
+	// Export in the background, so that tracing does not slow down the execution
+	t.pending.Add(1)
+	go func() {
+		defer t.pending.Done()
+		if err := t.export(batch); err != nil {
+			t.mu.Lock()
+			if t.exportErr == nil {
+				t.exportErr = err
+			}
+			t.mu.Unlock()
+		}
+	}()
+}
+
+func (t *tracer) flush() error {
+	t.mu.Lock()
+	batch := t.queue
+	t.queue = nil
+	t.mu.Unlock()
+	err := t.export(batch)
+	t.pending.Wait()
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.exportErr
+}
+
+func (t *tracer) export(spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return fmt.Errorf("error encoding spans: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, t.cfg.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("error creating request to export spans: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.cfg.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error exporting spans to %q: %v", t.cfg.endpoint, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("error exporting spans to %q: got %s", t.cfg.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON encoding of spans.
+// See https://github.com/open-telemetry/opentelemetry-proto/tree/main/opentelemetry/proto/trace/v1
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+const (
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+func (t *tracer) encode(spans []*Span) otlpTraces {
+	var encoded []otlpSpan
+	for _, s := range spans {
+		s.mu.Lock()
+		es := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(s.start),
+			EndTimeUnixNano:   unixNano(s.end),
+			Attributes:        encodeAttributes(s.attrs),
+			Status:            otlpStatus{Code: statusCodeOK},
+		}
+		if s.parentID != [8]byte{} {
+			es.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for _, e := range s.events {
+			es.Events = append(es.Events, otlpEvent{
+				TimeUnixNano: unixNano(e.time),
+				Name:         e.name,
+				Attributes:   encodeAttributes(e.attrs),
+			})
+		}
+		if s.err != nil {
+			es.Status = otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+		}
+		s.mu.Unlock()
+		encoded = append(encoded, es)
+	}
+	return otlpTraces{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: encodeAttributes([]attribute{{key: "service.name", value: t.cfg.serviceName}}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: scopeName},
+						Spans: encoded,
+					},
+				},
+			},
+		},
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func encodeAttributes(attrs []attribute) []otlpKeyValue {
+	var kvs []otlpKeyValue
+	for _, a := range attrs {
+		kvs = append(kvs, otlpKeyValue{Key: a.key, Value: encodeValue(a.value)})
+	}
+	return kvs
+}
+
+func encodeValue(v interface{}) otlpAnyValue {
+	switch val := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &val}
+	case bool:
+		return otlpAnyValue{BoolValue: &val}
+	case int:
+		i := strconv.FormatInt(int64(val), 10)
+		return otlpAnyValue{IntValue: &i}
+	case int64:
+		i := strconv.FormatInt(val, 10)
+		return otlpAnyValue{IntValue: &i}
+	case float64:
+		return otlpAnyValue{DoubleValue: &val}
+	case []string:
+		arr := &otlpArrayValue{Values: []otlpAnyValue{}}
+		for _, s := range val {
+			arr.Values = append(arr.Values, encodeValue(s))
+		}
+		return otlpAnyValue{ArrayValue: arr}
+	case error:
+		s := val.Error()
+		return otlpAnyValue{StringValue: &s}
+	case fmt.Stringer:
+		s := val.String()
+		return otlpAnyValue{StringValue: &s}
+	default:
+		s := fmt.Sprint(val)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
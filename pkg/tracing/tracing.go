@@ -0,0 +1,239 @@
+// Package tracing records the phases of a kismatic execution as spans, and
+// exports them to an OpenTelemetry collector using the OTLP/HTTP protocol
+// with JSON encoding.
+//
+// Tracing is configured through the standard OpenTelemetry environment
+// variables, and is disabled unless an endpoint is set:
+//
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT  URL where spans are sent (e.g. http://collector:4318/v1/traces)
+//	OTEL_EXPORTER_OTLP_ENDPOINT         base URL of the collector. "/v1/traces" is appended.
+//	OTEL_EXPORTER_OTLP_HEADERS          comma-separated list of key=value headers to send
+//	OTEL_EXPORTER_OTLP_TIMEOUT          timeout for exporting spans, in milliseconds
+//	OTEL_SERVICE_NAME                   service name reported to the collector (defaults to "kismatic")
+//
+// All the span methods are safe to call on a nil span, which is what is
+// returned when tracing is disabled.
+package tracing
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is a named, timed operation
+type Span struct {
+	tracer   *tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+
+	mu     sync.Mutex
+	end    time.Time
+	attrs  []attribute
+	events []event
+	err    error
+	ended  bool
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+type event struct {
+	name  string
+	time  time.Time
+	attrs []attribute
+}
+
+// Child starts a span that is a child of this span. Attributes are provided as
+// alternating keys and values.
+func (s *Span) Child(name string, keyvals ...interface{}) *Span {
+	if s == nil {
+		return nil
+	}
+	c := &Span{
+		tracer:   s.tracer,
+		traceID:  s.traceID,
+		spanID:   newSpanID(),
+		parentID: s.spanID,
+		name:     name,
+		start:    time.Now(),
+	}
+	c.SetAttributes(keyvals...)
+	return c
+}
+
+// SetAttributes sets the given attributes on the span. Attributes are provided
+// as alternating keys and values.
+func (s *Span) SetAttributes(keyvals ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, toAttributes(keyvals)...)
+}
+
+// AddEvent records an event that occurred during the span
+func (s *Span) AddEvent(name string, keyvals ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event{name: name, time: time.Now(), attrs: toAttributes(keyvals)})
+}
+
+// RecordError marks the span as failed with the given error
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// End completes the span, and queues it for export. Calling End more than
+// once has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+func toAttributes(keyvals []interface{}) []attribute {
+	var attrs []attribute
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		attrs = append(attrs, attribute{key: fmt.Sprint(keyvals[i]), value: keyvals[i+1]})
+	}
+	return attrs
+}
+
+func newTraceID() [16]byte {
+	var id [16]byte
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	rand.Read(id[:])
+	return id
+}
+
+var (
+	mu   sync.Mutex
+	std  *tracer
+	root *Span
+)
+
+// Init configures tracing from the environment, and starts the root span of
+// the process. Tracing remains disabled if no endpoint is configured, or if the
+// configuration is invalid.
+func Init(name string, keyvals ...interface{}) error {
+	cfg, err := configFromEnv(os.Getenv)
+	if err != nil || cfg == nil {
+		return err
+	}
+	t := newTracer(*cfg)
+	mu.Lock()
+	defer mu.Unlock()
+	std = t
+	root = &Span{
+		tracer:  t,
+		traceID: newTraceID(),
+		spanID:  newSpanID(),
+		name:    name,
+		start:   time.Now(),
+	}
+	root.SetAttributes(keyvals...)
+	return nil
+}
+
+// Start starts a span that is a child of the root span. It returns nil if
+// tracing is disabled.
+func Start(name string, keyvals ...interface{}) *Span {
+	mu.Lock()
+	r := root
+	mu.Unlock()
+	return r.Child(name, keyvals...)
+}
+
+// Shutdown ends the root span, recording the given error if any, and exports
+// all pending spans.
+func Shutdown(err error) error {
+	mu.Lock()
+	r, t := root, std
+	root, std = nil, nil
+	mu.Unlock()
+	if t == nil {
+		return nil
+	}
+	r.RecordError(err)
+	r.End()
+	return t.flush()
+}
+
+type config struct {
+	endpoint    string
+	headers     map[string]string
+	timeout     time.Duration
+	serviceName string
+}
+
+func configFromEnv(getenv func(string) string) (*config, error) {
+	endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if p := getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" && p != "http/json" {
+		return nil, fmt.Errorf("OTLP protocol %q is not supported, only \"http/json\" is supported", p)
+	}
+	cfg := &config{
+		endpoint:    endpoint,
+		headers:     map[string]string{},
+		timeout:     10 * time.Second,
+		serviceName: "kismatic",
+	}
+	if h := getenv("OTEL_EXPORTER_OTLP_HEADERS"); h != "" {
+		for _, kv := range strings.Split(h, ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return nil, fmt.Errorf("invalid OTLP header %q, must be in the form key=value", kv)
+			}
+			cfg.headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	if t := getenv("OTEL_EXPORTER_OTLP_TIMEOUT"); t != "" {
+		var ms int
+		if _, err := fmt.Sscanf(t, "%d", &ms); err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid OTLP timeout %q, must be a positive number of milliseconds", t)
+		}
+		cfg.timeout = time.Duration(ms) * time.Millisecond
+	}
+	if s := getenv("OTEL_SERVICE_NAME"); s != "" {
+		cfg.serviceName = s
+	}
+	return cfg, nil
+}
@@ -0,0 +1,183 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		env      map[string]string
+		expected *config
+		valid    bool
+	}{
+		{
+			env:   map[string]string{},
+			valid: true,
+		},
+		{
+			env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318/"},
+			expected: &config{
+				endpoint:    "http://collector:4318/v1/traces",
+				headers:     map[string]string{},
+				timeout:     10 * time.Second,
+				serviceName: "kismatic",
+			},
+			valid: true,
+		},
+		{
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://ignored:4318",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://collector/traces",
+				"OTEL_EXPORTER_OTLP_HEADERS":         "api-key=secret, tenant = ops",
+				"OTEL_EXPORTER_OTLP_TIMEOUT":         "500",
+				"OTEL_SERVICE_NAME":                  "installer",
+			},
+			expected: &config{
+				endpoint:    "https://collector/traces",
+				headers:     map[string]string{"api-key": "secret", "tenant": "ops"},
+				timeout:     500 * time.Millisecond,
+				serviceName: "installer",
+			},
+			valid: true,
+		},
+		{
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+			},
+		},
+		{
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_EXPORTER_OTLP_HEADERS":  "novalue",
+			},
+		},
+		{
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+				"OTEL_EXPORTER_OTLP_TIMEOUT":  "-1",
+			},
+		},
+	}
+	for i, test := range tests {
+		cfg, err := configFromEnv(func(k string) string { return test.env[k] })
+		if !test.valid {
+			if err == nil {
+				t.Errorf("test %d: expected an error, but didn't get one", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if (cfg == nil) != (test.expected == nil) {
+			t.Errorf("test %d: expected config %v, but got %v", i, test.expected, cfg)
+			continue
+		}
+		if cfg == nil {
+			continue
+		}
+		if cfg.endpoint != test.expected.endpoint || cfg.timeout != test.expected.timeout || cfg.serviceName != test.expected.serviceName {
+			t.Errorf("test %d: expected config %+v, but got %+v", i, test.expected, cfg)
+		}
+		for k, v := range test.expected.headers {
+			if cfg.headers[k] != v {
+				t.Errorf("test %d: expected header %q to be %q, but got %q", i, k, v, cfg.headers[k])
+			}
+		}
+	}
+}
+
+func TestSpansAreExported(t *testing.T) {
+	var received otlpTraces
+	var apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("api-key")
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading request: %v", err)
+		}
+		if err := json.Unmarshal(b, &received); err != nil {
+			t.Errorf("error unmarshaling request: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", server.URL)
+	os.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=secret")
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_HEADERS")
+
+	if err := Init("kismatic install apply"); err != nil {
+		t.Fatalf("unexpected error initializing tracing: %v", err)
+	}
+	task := Start("apply", "playbook", "kubernetes.yaml")
+	play := task.Child("play", "hosts", []string{"master01", "worker01"})
+	play.AddEvent("host unreachable", "host", "worker01")
+	play.RecordError(errors.New("play failed"))
+	play.End()
+	task.End()
+	if err := Shutdown(nil); err != nil {
+		t.Fatalf("unexpected error shutting down tracing: %v", err)
+	}
+
+	if apiKey != "secret" {
+		t.Errorf("expected the api-key header to be sent")
+	}
+	if len(received.ResourceSpans) != 1 || len(received.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload: %+v", received)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, but got %d", len(spans))
+	}
+	byName := map[string]otlpSpan{}
+	for _, s := range spans {
+		byName[s.Name] = s
+		if s.TraceID != spans[0].TraceID {
+			t.Errorf("expected all spans to belong to the same trace")
+		}
+	}
+	root := byName["kismatic install apply"]
+	if root.ParentSpanID != "" {
+		t.Errorf("expected root span not to have a parent")
+	}
+	if byName["apply"].ParentSpanID != root.SpanID {
+		t.Errorf("expected task span to be a child of the root span")
+	}
+	if byName["play"].ParentSpanID != byName["apply"].SpanID {
+		t.Errorf("expected play span to be a child of the task span")
+	}
+	if byName["play"].Status.Code != statusCodeError || byName["play"].Status.Message != "play failed" {
+		t.Errorf("expected play span to have an error status, but got %+v", byName["play"].Status)
+	}
+	if len(byName["play"].Events) != 1 {
+		t.Errorf("expected play span to have 1 event, but got %d", len(byName["play"].Events))
+	}
+}
+
+func TestDisabledTracingIsNoop(t *testing.T) {
+	if err := Init("kismatic"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := Start("task")
+	if s != nil {
+		t.Errorf("expected a nil span when tracing is disabled")
+	}
+	child := s.Child("play")
+	child.SetAttributes("key", "value")
+	child.AddEvent("event")
+	child.RecordError(errors.New("error"))
+	child.End()
+	if err := Shutdown(nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -21,3 +21,43 @@ func BackupDirectory(sourceDir string, backupDir string) (bool, error) {
 	// Directory does not already exist, nothing to do
 	return backedup, nil
 }
+
+// ShredFile overwrites the contents of the file with zeros before removing it,
+// so that the credentials it contains are not left on the disk. A file that
+// does not exist is ignored.
+func ShredFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening %q: %v", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error reading %q: %v", path, err)
+	}
+	zeros := make([]byte, 4096)
+	for remaining := info.Size(); remaining > 0; remaining -= int64(len(zeros)) {
+		n := int64(len(zeros))
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := f.Write(zeros[:n]); err != nil {
+			f.Close()
+			return fmt.Errorf("error overwriting %q: %v", path, err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("error overwriting %q: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error overwriting %q: %v", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("error removing %q: %v", path, err)
+	}
+	return nil
+}
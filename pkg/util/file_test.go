@@ -40,3 +40,23 @@ func TestBackupClientDirectoryNotExists(t *testing.T) {
 		t.Errorf("Expected directory to not exist")
 	}
 }
+
+func TestShredFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "ket-shred-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	file := filepath.Join(tmpDir, "clustercatalog.yaml")
+	if err := ioutil.WriteFile(file, make([]byte, 10000), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if err := ShredFile(file); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed, got %v", err)
+	}
+	if err := ShredFile(file); err != nil {
+		t.Errorf("expected a missing file to be ignored, got %v", err)
+	}
+}
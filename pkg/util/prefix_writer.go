@@ -0,0 +1,75 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixWriter writes each line that is written to it to the underlying
+// writer, prefixed with the given prefix. Lines are written to the underlying
+// writer once they are complete, so that the output of multiple PrefixWriters
+// that share the underlying writer and the mutex is not interleaved.
+// A PrefixWriter is safe for concurrent use.
+type PrefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix []byte
+
+	bufMu sync.Mutex
+	buf   []byte
+}
+
+// NewPrefixWriter returns a PrefixWriter that writes to out. The mutex must be
+// shared by all the writers that write to out.
+func NewPrefixWriter(out io.Writer, mu *sync.Mutex, prefix string) *PrefixWriter {
+	return &PrefixWriter{
+		mu:     mu,
+		out:    out,
+		prefix: []byte(prefix),
+	}
+}
+
+// Write buffers p, and writes all the complete lines to the underlying writer
+func (w *PrefixWriter) Write(p []byte) (int, error) {
+	w.bufMu.Lock()
+	defer w.bufMu.Unlock()
+	w.buf = append(w.buf, p...)
+	i := bytes.LastIndexByte(w.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	lines := w.buf[:i+1]
+	if err := w.writeLines(lines); err != nil {
+		return 0, err
+	}
+	w.buf = append(w.buf[:0], w.buf[i+1:]...)
+	return len(p), nil
+}
+
+// Flush writes the incomplete line that is buffered, if any
+func (w *PrefixWriter) Flush() error {
+	w.bufMu.Lock()
+	defer w.bufMu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeLines(append(w.buf, '\n'))
+	w.buf = w.buf[:0]
+	return err
+}
+
+func (w *PrefixWriter) writeLines(lines []byte) error {
+	var out bytes.Buffer
+	for _, l := range bytes.SplitAfter(lines, []byte("\n")) {
+		if len(l) == 0 {
+			continue
+		}
+		out.Write(w.prefix)
+		out.Write(l)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.out.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	mu := &sync.Mutex{}
+	w := NewPrefixWriter(out, mu, "[node01] ")
+	fmt.Fprint(w, "Running task")
+	if out.Len() != 0 {
+		t.Errorf("expected incomplete lines to be buffered, but got %q", out.String())
+	}
+	fmt.Fprint(w, " [OK]\nsecond line\nthird")
+	expected := "[node01] Running task [OK]\n[node01] second line\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, but got %q", expected, out.String())
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("unexpected error flushing: %v", err)
+	}
+	expected += "[node01] third\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, but got %q", expected, out.String())
+	}
+}
+
+func TestPrefixWritersDoNotInterleaveLines(t *testing.T) {
+	out := &bytes.Buffer{}
+	mu := &sync.Mutex{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := NewPrefixWriter(out, mu, fmt.Sprintf("[%d] ", i))
+			for j := 0; j < 100; j++ {
+				fmt.Fprint(w, "part one, ")
+				fmt.Fprintln(w, "part two")
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, l := range bytes.Split(bytes.TrimSuffix(out.Bytes(), []byte("\n")), []byte("\n")) {
+		var i int
+		if _, err := fmt.Sscanf(string(l), "[%d] part one, part two", &i); err != nil {
+			t.Errorf("unexpected line %q", l)
+		}
+	}
+}
@@ -24,6 +24,12 @@ var Orange = color.New(color.FgRed, color.FgYellow)
 var Blue = color.New(color.FgCyan)
 var White = color.New(color.FgHiWhite)
 
+// SetColor enables or disables colored output. By default, output is colored
+// when stdout is a terminal.
+func SetColor(enabled bool) {
+	color.NoColor = !enabled
+}
+
 // PrettyPrintOk [OK](Green) with formatted string
 func PrettyPrintOk(out io.Writer, msg string, a ...interface{}) {
 	print(out, msg, okType, a...)
@@ -0,0 +1,182 @@
+package webui
+
+// indexHTML is the web application. It renders the plan and the history of
+// the runs from the API, and the progress of each node from the events
+// streamed over the websocket.
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Kismatic</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; }
+.ok { color: #2a7d2a; }
+.changed { color: #b5831b; }
+.failed, .unreachable, .error { color: #c0392b; }
+#output { background: #f4f4f4; padding: 1em; max-height: 20em; overflow: auto; white-space: pre-wrap; }
+button { margin-right: 1em; }
+</style>
+</head>
+<body>
+<h1>Cluster <span id="cluster"></span> <small id="version"></small></h1>
+
+<h2>Progress</h2>
+<p>Playbook: <span id="playbook">-</span> &mdash; Play: <span id="play">-</span></p>
+<table>
+<thead><tr><th>Node</th><th>IP</th><th>Roles</th><th>Last task</th><th>Status</th><th>OK</th><th>Changed</th><th>Failed</th><th>Skipped</th></tr></thead>
+<tbody id="nodes"></tbody>
+</table>
+
+<h2>Operations</h2>
+<p>
+<button onclick="start('preflight')">Run pre-flight checks</button>
+<button onclick="start('diagnose')">Collect diagnostics</button>
+<span id="operation"></span>
+</p>
+<pre id="output"></pre>
+
+<h2>Runs</h2>
+<table>
+<thead><tr><th>Started</th><th>Task</th><th>Playbook</th><th>Directory</th><th>Result</th></tr></thead>
+<tbody id="runs"></tbody>
+</table>
+
+<script>
+var nodes = {};
+
+function text(s) {
+  var d = document.createElement("div");
+  d.textContent = s == null ? "" : String(s);
+  return d.innerHTML;
+}
+
+function getJSON(path, done) {
+  var req = new XMLHttpRequest();
+  req.open("GET", path);
+  req.onload = function() { done(JSON.parse(req.responseText), req.status); };
+  req.send();
+}
+
+function renderNodes() {
+  var rows = "";
+  Object.keys(nodes).sort().forEach(function(host) {
+    var n = nodes[host];
+    rows += "<tr><td>" + text(host) + "</td><td>" + text(n.ip) + "</td><td>" + text((n.roles || []).join(", ")) +
+      "</td><td>" + text(n.task) + "</td><td class=\"" + text(n.status) + "\">" + text(n.status) +
+      "</td><td>" + n.ok + "</td><td>" + n.changed + "</td><td>" + n.failed + "</td><td>" + n.skipped + "</td></tr>";
+  });
+  document.getElementById("nodes").innerHTML = rows;
+}
+
+function node(host) {
+  if (!nodes[host]) {
+    nodes[host] = {ok: 0, changed: 0, failed: 0, skipped: 0};
+  }
+  return nodes[host];
+}
+
+function loadPlan() {
+  getJSON("/api/plan", function(plan, code) {
+    if (code != 200) {
+      document.getElementById("cluster").innerHTML = "<span class=\"error\">" + text(plan.error) + "</span>";
+      return;
+    }
+    document.getElementById("cluster").textContent = plan.cluster;
+    document.getElementById("version").textContent = plan.version;
+    plan.nodes.forEach(function(n) {
+      var s = node(n.host);
+      s.ip = n.ip;
+      s.roles = n.roles;
+    });
+    renderNodes();
+  });
+}
+
+function loadRuns() {
+  getJSON("/api/runs", function(runs, code) {
+    if (code != 200) {
+      return;
+    }
+    var rows = "";
+    runs.forEach(function(r) {
+      var result = r.aborted ? "aborted" : (r.timedOut ? "timed out" : (r.status || ""));
+      rows += "<tr><td>" + text(new Date(r.startTime).toLocaleString()) + "</td><td>" + text(r.task) + "</td><td>" +
+        text(r.playbook) + "</td><td>" + text(r.directory) + "</td><td>" + text(result) + "</td></tr>";
+    });
+    document.getElementById("runs").innerHTML = rows;
+  });
+}
+
+function loadOperation() {
+  getJSON("/api/operation", function(op) {
+    var s = "";
+    if (op.name) {
+      s = op.name + (op.running ? " is running" : (op.error ? " failed: " + op.error : " completed"));
+    }
+    document.getElementById("operation").textContent = s;
+    document.getElementById("output").textContent = op.output || "";
+  });
+}
+
+function start(name) {
+  var req = new XMLHttpRequest();
+  req.open("POST", "/api/operations/" + name);
+  req.setRequestHeader("X-Kismatic-UI", "1");
+  req.onload = function() {
+    if (req.status != 202) {
+      alert(JSON.parse(req.responseText).error);
+      return;
+    }
+    Object.keys(nodes).forEach(function(host) {
+      var n = nodes[host];
+      n.ok = n.changed = n.failed = n.skipped = 0;
+      n.task = n.status = "";
+    });
+    renderNodes();
+    loadOperation();
+  };
+  req.send();
+}
+
+function onEvent(e) {
+  if (e.type == "playbook_start") {
+    document.getElementById("playbook").textContent = e.playbook;
+  }
+  if (e.type == "play_start") {
+    document.getElementById("play").textContent = e.play;
+  }
+  if (e.type == "playbook_end") {
+    loadRuns();
+  }
+  if (e.type != "task_result" || !e.host) {
+    return;
+  }
+  var n = node(e.host);
+  n.task = e.task;
+  n.status = e.status;
+  if (e.status == "ok") n.ok++;
+  if (e.status == "changed") n.changed++;
+  if (e.status == "failed" || e.status == "unreachable") n.failed++;
+  if (e.status == "skipped") n.skipped++;
+  renderNodes();
+}
+
+function connect() {
+  var ws = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + "/events");
+  ws.onmessage = function(m) { onEvent(JSON.parse(m.data)); };
+  ws.onclose = function() { setTimeout(connect, 2000); };
+}
+
+loadPlan();
+loadRuns();
+loadOperation();
+connect();
+setInterval(loadOperation, 2000);
+</script>
+</body>
+</html>
+`
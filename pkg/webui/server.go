@@ -0,0 +1,274 @@
+// Package webui serves a local web application that shows the plan of a
+// cluster, the progress of the running operation on each node, and the
+// history of the operations recorded in the runs directory.
+package webui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/eventstream"
+	"github.com/apprenda/kismatic/pkg/install"
+	"github.com/apprenda/kismatic/pkg/logging"
+)
+
+// maxOutput is the size of the console output of an operation that is kept,
+// the beginning of the output is dropped when it is exceeded
+const maxOutput = 1 << 20
+
+// Operation runs on the cluster of the plan. The ansible events are written
+// to events, as lines of JSON, and the console output to out.
+type Operation func(plan *install.Plan, events io.Writer, out io.Writer) error
+
+// Server of the web application
+type Server struct {
+	// PlanFile is the plan of the cluster
+	PlanFile string
+	// RunsDirectory keeps the runs of the operations
+	RunsDirectory string
+	// Operations that can be started from the web application, keyed by name
+	Operations map[string]Operation
+	// AllowedHosts are the host names, e.g. bastion.example.com, the
+	// application can be reached with, in addition to localhost and the IP
+	// addresses
+	AllowedHosts []string
+
+	events *eventstream.Server
+	mu     sync.Mutex
+	status OperationStatus
+	output *bytes.Buffer
+}
+
+// NewServer returns the server of the web application
+func NewServer(planFile, runsDirectory string, operations map[string]Operation) *Server {
+	return &Server{
+		PlanFile:      planFile,
+		RunsDirectory: runsDirectory,
+		Operations:    operations,
+		events:        eventstream.NewServer(),
+		output:        &bytes.Buffer{},
+	}
+}
+
+// PlanSummary describes the cluster of the plan
+type PlanSummary struct {
+	Cluster string        `json:"cluster"`
+	Version string        `json:"version"`
+	Nodes   []NodeSummary `json:"nodes"`
+}
+
+// NodeSummary describes a node of the plan
+type NodeSummary struct {
+	Host  string   `json:"host"`
+	IP    string   `json:"ip"`
+	Roles []string `json:"roles"`
+}
+
+// Run is an operation recorded in the runs directory
+type Run struct {
+	Task      string            `json:"task"`
+	Directory string            `json:"directory"`
+	Playbook  string            `json:"playbook"`
+	StartTime time.Time         `json:"startTime"`
+	EndTime   *time.Time        `json:"endTime,omitempty"`
+	Status    install.RunStatus `json:"status,omitempty"`
+	TimedOut  bool              `json:"timedOut,omitempty"`
+	Aborted   bool              `json:"aborted,omitempty"`
+}
+
+// OperationStatus is the status of the operation started from the web
+// application that is running, or that ran last
+type OperationStatus struct {
+	Name      string     `json:"name,omitempty"`
+	Running   bool       `json:"running"`
+	StartTime *time.Time `json:"startTime,omitempty"`
+	EndTime   *time.Time `json:"endTime,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Output is the console output of the operation
+	Output string `json:"output,omitempty"`
+}
+
+// Handler returns the handler of the web application
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/api/plan", s.plan)
+	mux.HandleFunc("/api/runs", s.runs)
+	mux.HandleFunc("/api/operation", s.operation)
+	mux.HandleFunc("/api/operations/", s.startOperation)
+	mux.Handle(eventstream.Path, s.events)
+	s.events.AllowedHosts = s.AllowedHosts
+	return s.checkHost(mux)
+}
+
+// checkHost rejects the requests whose Host header is not localhost, an IP
+// address or one of the allowed hosts, so that the pages served from other
+// names that resolve to the address of the server cannot call the API
+func (s *Server) checkHost(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !eventstream.HostAllowed(r.Host, s.AllowedHosts) {
+			logging.Warn("rejecting request", "remoteAddr", r.RemoteAddr, "host", r.Host)
+			writeError(w, http.StatusForbidden, fmt.Errorf("the host %q is not allowed", r.Host))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, indexHTML)
+}
+
+func (s *Server) plan(w http.ResponseWriter, r *http.Request) {
+	p, err := s.readPlan()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	summary := PlanSummary{Cluster: p.Cluster.Name, Version: p.Cluster.Version, Nodes: []NodeSummary{}}
+	for _, n := range p.GetUniqueNodes() {
+		summary.Nodes = append(summary.Nodes, NodeSummary{Host: n.Host, IP: n.IP, Roles: p.GetRolesForIP(n.IP)})
+	}
+	writeJSON(w, summary)
+}
+
+func (s *Server) runs(w http.ResponseWriter, r *http.Request) {
+	runs, err := ListRuns(s.RunsDirectory)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, runs)
+}
+
+func (s *Server) operation(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := s.status
+	status.Output = s.output.String()
+	s.mu.Unlock()
+	writeJSON(w, status)
+}
+
+// startOperation starts the operation named in the path. The requests must
+// set the X-Kismatic-UI header, which cannot be set by the forms of other
+// sites without the approval of the server.
+func (s *Server) startOperation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+	if r.Header.Get("X-Kismatic-UI") == "" {
+		writeError(w, http.StatusForbidden, fmt.Errorf("the X-Kismatic-UI header is required"))
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/operations/")
+	op, ok := s.Operations[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown operation %q", name))
+		return
+	}
+	p, err := s.readPlan()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Running {
+		writeError(w, http.StatusConflict, fmt.Errorf("the operation %s is running", s.status.Name))
+		return
+	}
+	start := time.Now()
+	s.status = OperationStatus{Name: name, Running: true, StartTime: &start}
+	s.output.Reset()
+	go s.run(name, op, p)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) run(name string, op Operation, p *install.Plan) {
+	log := logging.With("operation", name)
+	log.Info("running operation")
+	err := op(p, s.events, outputWriter{s})
+	end := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.EndTime = &end
+	if err != nil {
+		log.Error("operation failed", "error", err)
+		s.status.Error = err.Error()
+		return
+	}
+	log.Info("operation completed")
+}
+
+func (s *Server) readPlan() (*install.Plan, error) {
+	fp := install.FilePlanner{File: s.PlanFile}
+	p, err := fp.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %q: %v", s.PlanFile, err)
+	}
+	return p, nil
+}
+
+// outputWriter keeps the console output of the running operation
+type outputWriter struct {
+	s *Server
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.output.Write(p)
+	if extra := w.s.output.Len() - maxOutput; extra > 0 {
+		w.s.output.Next(extra)
+	}
+	return len(p), nil
+}
+
+// ListRuns returns the runs recorded in the runs directory, the most recent
+// first
+func ListRuns(runsDirectory string) ([]Run, error) {
+	recorded, err := install.ListRuns(runsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	runs := []Run{}
+	for _, r := range recorded {
+		runs = append(runs, Run{
+			Task:      r.Task,
+			Directory: r.Directory,
+			Playbook:  r.Playbook,
+			StartTime: r.StartTime,
+			EndTime:   r.EndTime,
+			Status:    r.Status,
+			TimedOut:  r.TimedOut,
+			Aborted:   r.Aborted,
+		})
+	}
+	return runs, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Warn("error writing response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,203 @@
+package webui
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/install"
+)
+
+const testPlan = `cluster:
+  name: kubernetes
+  version: v1.10.5
+etcd:
+  expected_count: 1
+  nodes:
+  - host: node01
+    ip: 10.0.0.1
+master:
+  expected_count: 1
+  nodes:
+  - host: node01
+    ip: 10.0.0.1
+worker:
+  expected_count: 1
+  nodes:
+  - host: node02
+    ip: 10.0.0.2
+`
+
+func newTestServer(t *testing.T, operations map[string]Operation) (*Server, string) {
+	dir, err := ioutil.TempDir("", "webui-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	planFile := filepath.Join(dir, "kismatic-cluster.yaml")
+	if err := ioutil.WriteFile(planFile, []byte(testPlan), 0644); err != nil {
+		t.Fatalf("error writing plan: %v", err)
+	}
+	s := NewServer(planFile, filepath.Join(dir, "runs"), operations)
+	// The requests created with httptest.NewRequest are sent to example.com
+	s.AllowedHosts = []string{"example.com"}
+	return s, dir
+}
+
+func TestHandlerChecksHost(t *testing.T) {
+	s, dir := newTestServer(t, nil)
+	defer os.RemoveAll(dir)
+	h := s.Handler()
+	tests := []struct {
+		host   string
+		status int
+	}{
+		{"127.0.0.1:8080", http.StatusOK},
+		{"localhost:8080", http.StatusOK},
+		{"example.com", http.StatusOK},
+		{"rebind.example.com:8080", http.StatusForbidden},
+	}
+	for _, path := range []string{"/", "/api/plan", "/api/runs", "/api/operation"} {
+		for _, test := range tests {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Host = test.host
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != test.status {
+				t.Errorf("expected status %d for %s with host %q, got %d", test.status, path, test.host, rec.Code)
+			}
+		}
+	}
+}
+
+func TestPlanSummary(t *testing.T) {
+	s, dir := newTestServer(t, nil)
+	defer os.RemoveAll(dir)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/plan", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got PlanSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("error unmarshaling summary: %v", err)
+	}
+	expected := PlanSummary{
+		Cluster: "kubernetes",
+		Version: "v1.10.5",
+		Nodes: []NodeSummary{
+			{Host: "node01", IP: "10.0.0.1", Roles: []string{"master", "etcd"}},
+			{Host: "node02", IP: "10.0.0.2", Roles: []string{"worker"}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+// runManifestFilename is the manifest written by the executors in each run
+// directory
+const runManifestFilename = "run-manifest.json"
+
+func TestListRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webui-test")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Date(2018, 6, 1, 10, 0, 0, 0, time.UTC)
+	manifests := map[string]install.RunManifest{
+		"apply/2018-06-01-10-00-00":     {Task: "apply", Playbook: "kubernetes.yaml", StartTime: start},
+		"preflight/2018-06-01-09-00-00": {Task: "preflight", Playbook: "preflight.yaml", StartTime: start.Add(-time.Hour)},
+		"apply/2018-06-01-11-00-00":     {Task: "apply", Playbook: "kubernetes.yaml", StartTime: start.Add(time.Hour), Aborted: true},
+	}
+	for d, m := range manifests {
+		runDir := filepath.Join(dir, d)
+		os.MkdirAll(runDir, 0755)
+		b, _ := json.Marshal(m)
+		if err := ioutil.WriteFile(filepath.Join(runDir, runManifestFilename), b, 0644); err != nil {
+			t.Fatalf("error writing manifest: %v", err)
+		}
+	}
+	runs, err := ListRuns(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Run{
+		{Task: "apply", Directory: filepath.Join(dir, "apply/2018-06-01-11-00-00"), Playbook: "kubernetes.yaml", StartTime: start.Add(time.Hour), Aborted: true},
+		{Task: "apply", Directory: filepath.Join(dir, "apply/2018-06-01-10-00-00"), Playbook: "kubernetes.yaml", StartTime: start},
+		{Task: "preflight", Directory: filepath.Join(dir, "preflight/2018-06-01-09-00-00"), Playbook: "preflight.yaml", StartTime: start.Add(-time.Hour)},
+	}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, runs)
+	}
+}
+
+func TestStartOperation(t *testing.T) {
+	release := make(chan struct{})
+	var gotPlan *install.Plan
+	s, dir := newTestServer(t, map[string]Operation{
+		"preflight": func(p *install.Plan, events io.Writer, out io.Writer) error {
+			gotPlan = p
+			io.WriteString(out, "running pre-flight checks\n")
+			<-release
+			return errors.New("pre-flight checks failed")
+		},
+	})
+	defer os.RemoveAll(dir)
+	h := s.Handler()
+	post := func(path string, header bool) int {
+		req := httptest.NewRequest("POST", path, nil)
+		if header {
+			req.Header.Set("X-Kismatic-UI", "1")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	status := func() OperationStatus {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/operation", nil))
+		var s OperationStatus
+		json.Unmarshal(rec.Body.Bytes(), &s)
+		return s
+	}
+
+	if code := post("/api/operations/preflight", false); code != http.StatusForbidden {
+		t.Errorf("expected the request without the header to be forbidden, got %d", code)
+	}
+	if code := post("/api/operations/unknown", true); code != http.StatusNotFound {
+		t.Errorf("expected unknown operation to return 404, got %d", code)
+	}
+	if code := post("/api/operations/preflight", true); code != http.StatusAccepted {
+		t.Fatalf("expected the operation to start, got %d", code)
+	}
+	if code := post("/api/operations/preflight", true); code != http.StatusConflict {
+		t.Errorf("expected a conflict while the operation runs, got %d", code)
+	}
+	close(release)
+	var st OperationStatus
+	for i := 0; i < 100; i++ {
+		if st = status(); !st.Running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st.Running || st.Name != "preflight" || st.Error != "pre-flight checks failed" || st.EndTime == nil {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if !strings.Contains(st.Output, "running pre-flight checks") {
+		t.Errorf("expected the output of the operation, got %q", st.Output)
+	}
+	if gotPlan == nil || gotPlan.Cluster.Name != "kubernetes" {
+		t.Errorf("expected the operation to run with the plan, got %+v", gotPlan)
+	}
+}